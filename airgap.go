@@ -0,0 +1,141 @@
+package grail
+
+import (
+	"crypto/fips140"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+//
+// Air-gap mode
+//
+
+// AirGap configures WithAirGap.
+type AirGap struct {
+	// AllowedHosts are the hosts providers may connect to: "api.example.com"
+	// (any port), "api.example.com:443", or "*.example.com" (subdomains
+	// only). Matching is case-insensitive. With no hosts, every provider
+	// request is blocked.
+	AllowedHosts []string
+	// RequireFIPS fails every request unless Go's FIPS 140-3 mode is
+	// enabled (GODEBUG=fips140=on).
+	RequireFIPS bool
+}
+
+// WithAirGap restricts the client to approved hosts, for regulated
+// environments that must show no data leaves them:
+//
+//   - Provider HTTP requests to hosts outside cfg.AllowedHosts fail with
+//     Unauthorized before any data is sent, including SDK retries and
+//     follow-up downloads.
+//   - Providers whose transport can't be restricted (they don't implement
+//     TransportAware) are refused.
+//   - Implicit network helpers are disabled: InputFileFromURI and its
+//     variants fail with Unsupported, and WithStaleModelCheck is ignored.
+//   - ReviewWebhook notifications sent with the client's HTTP client are
+//     held to cfg.AllowedHosts too.
+//
+// The restriction applies to the client's own calls, not those of other
+// clients sharing its provider. Like WithTransportLogging, it's set up by
+// NewClient, so child clients created with With keep their parent's
+// allowlist; setting WithAirGap on a child alone leaves its calls
+// unrestricted, and the child refuses to generate.
+func WithAirGap(cfg AirGap) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.airGap = &cfg
+	})
+}
+
+// checkAirGap reports whether the client may generate under its air-gap
+// configuration.
+func (c *client) checkAirGap() error {
+	cfg := c.opts.airGap
+	if cfg == nil {
+		return nil
+	}
+	if cfg.RequireFIPS && !fips140.Enabled() {
+		return NewGrailError(Unsupported, "air-gap mode requires FIPS 140-3 mode (GODEBUG=fips140=on)")
+	}
+	if !c.egressGuarded {
+		return NewGrailError(Unsupported, fmt.Sprintf("air-gap mode: provider %s can't be restricted to allowed hosts", c.provider.Name())).
+			WithProviderName(c.provider.Name())
+	}
+	return nil
+}
+
+// blockedEgress surfaces a request the egress guard blocked, which provider
+// SDKs report as an opaque transport failure, as Unauthorized.
+func (c *client) blockedEgress(err error) error {
+	var blocked *egressBlockedError
+	if !errors.As(err, &blocked) {
+		return err
+	}
+	return NewGrailError(Unauthorized, blocked.Error()).WithCause(err).WithProviderName(c.provider.Name())
+}
+
+type egressBlockedError struct {
+	host string
+}
+
+func (e *egressBlockedError) Error() string {
+	return fmt.Sprintf("air-gap mode: host %q is not allowed", e.host)
+}
+
+// egressGuard fails round trips to hosts that aren't allowed.
+type egressGuard struct {
+	base    http.RoundTripper
+	allowed []string
+	log     func() *slog.Logger
+}
+
+func (g *egressGuard) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !hostAllowed(req.URL, g.allowed) {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		if log := g.log(); log != nil {
+			log.Warn("air-gap mode blocked a request", slog.String("method", req.Method), slog.String("host", req.URL.Host))
+		}
+		return nil, &egressBlockedError{host: req.URL.Host}
+	}
+	base := g.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// hostAllowed reports whether u's host matches an allowlist entry.
+func hostAllowed(u *url.URL, allowed []string) bool {
+	host, port := u.Hostname(), u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "http":
+			port = "80"
+		}
+	}
+	for _, a := range allowed {
+		ah, ap, err := net.SplitHostPort(a)
+		if err != nil {
+			ah, ap = strings.Trim(a, "[]"), ""
+		}
+		if ap != "" && ap != port {
+			continue
+		}
+		if suffix, ok := strings.CutPrefix(ah, "*"); ok && strings.HasPrefix(suffix, ".") {
+			if len(host) > len(suffix) && strings.HasSuffix(strings.ToLower(host), strings.ToLower(suffix)) {
+				return true
+			}
+		} else if strings.EqualFold(ah, host) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package grail_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/fake"
+	"github.com/montanaflynn/grail/providers/mock"
+	"github.com/montanaflynn/grail/providers/openai"
+)
+
+func TestAirGap(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"resp_1","object":"response","status":"completed","model":"gpt-5.4",
+			"output":[{"type":"message","id":"msg_1","role":"assistant","status":"completed",
+				"content":[{"type":"output_text","text":"hi","annotations":[]}]}]}`)
+	}))
+	defer srv.Close()
+	host := srv.Listener.Addr().String()
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("hello")}, Output: grail.OutputText()}
+
+	newClient := func(allowed ...string) grail.Client {
+		t.Helper()
+		p, err := openai.New(openai.WithAPIKey("dummy"), openai.WithBaseURL(srv.URL+"/v1/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return grail.NewClient(p, grail.WithAirGap(grail.AirGap{AllowedHosts: allowed}))
+	}
+
+	if _, err := newClient(host).Generate(context.Background(), req); err != nil {
+		t.Fatalf("expected an allowed host to work, got %v", err)
+	}
+	if _, err := newClient("127.0.0.1:1", "*.openai.com").Generate(context.Background(), req); grail.GetErrorCode(err) != grail.Unauthorized {
+		t.Fatalf("expected a blocked host to fail with Unauthorized, got %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected only the allowed request to reach the server, got %d", n)
+	}
+
+	// The restriction applies only to the client that asked for it, not to
+	// other clients sharing its provider.
+	p, err := openai.New(openai.WithAPIKey("dummy"), openai.WithBaseURL(srv.URL+"/v1/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	guarded := grail.NewClient(p, grail.WithAirGap(grail.AirGap{}))
+	if _, err := grail.NewClient(p).Generate(context.Background(), req); err != nil {
+		t.Fatalf("expected a client without air-gap mode to be unrestricted, got %v", err)
+	}
+	if _, err := guarded.Generate(context.Background(), req); grail.GetErrorCode(err) != grail.Unauthorized {
+		t.Fatalf("expected the air-gapped client to stay restricted, got %v", err)
+	}
+
+	c := newClient(host)
+	if _, err := c.InputFileFromURI(context.Background(), srv.URL+"/file"); grail.GetErrorCode(err) != grail.Unsupported {
+		t.Fatalf("expected URI downloads to be disabled, got %v", err)
+	}
+
+	// Providers whose transport can't be restricted are refused; the fake
+	// provider makes no requests and is allowed.
+	mockClient := grail.NewClient(&mock.Provider{}, grail.WithAirGap(grail.AirGap{}))
+	if _, err := mockClient.Generate(context.Background(), req); grail.GetErrorCode(err) != grail.Unsupported {
+		t.Fatalf("expected an unrestricted provider to be refused, got %v", err)
+	}
+	if _, err := grail.NewClient(fake.New(), grail.WithAirGap(grail.AirGap{})).Generate(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error from the fake provider: %v", err)
+	}
+}
+
+func TestAirGapHostMatching(t *testing.T) {
+	allowed := []string{"api.example.com", "*.corp.test", "proxy.test:8443", "[::1]"}
+	for raw, want := range map[string]bool{
+		"https://api.example.com/v1":      true,
+		"https://API.Example.com:9000/v1": true,
+		"https://gw.corp.test/v1":         true,
+		"https://corp.test/v1":            false,
+		"https://evilcorp.test/v1":        false,
+		"https://proxy.test:8443/v1":      true,
+		"https://proxy.test/v1":           false,
+		"http://[::1]:8080/v1":            true,
+		"https://example.com/v1":          false,
+	} {
+		u, _ := url.Parse(raw)
+		if got := grail.HostAllowed(u, allowed); got != want {
+			t.Errorf("HostAllowed(%s) = %v, want %v", raw, got, want)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package grail
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+//
+// Content-addressable attachment store
+//
+
+// FileUploader is an optional interface for providers that can upload a file
+// once and reference it by ID in later requests. openai.Provider implements
+// it with the Files API.
+type FileUploader interface {
+	UploadFile(ctx context.Context, data []byte, mime, name string) (id string, err error)
+}
+
+// uploadedFileInput is a file already uploaded to the provider.
+type uploadedFileInput struct {
+	ID              string
+	MIME            string
+	Name            string
+	Size            int64
+	CacheBreakpoint bool
+	Droppable       bool
+	Priority        int
+}
+
+func (uploadedFileInput) isInput() {}
+
+// AsUploadedFileInput returns the provider file ID, MIME type, and name of a
+// file input that an AttachmentStore replaced with its upload. Providers that
+// implement FileUploader must accept these inputs.
+func AsUploadedFileInput(input Input) (id, mime, name string, ok bool) {
+	if u, ok := input.(uploadedFileInput); ok {
+		return u.ID, u.MIME, u.Name, true
+	}
+	return "", "", "", false
+}
+
+// UploadFile uploads a file input with the provider's FileUploader and
+// returns an input that references the upload, for sending a file larger
+// than the provider takes inline, or one many requests use, by ID. The file
+// stays on the provider until deleted there. Providers that can't upload
+// fail with Unsupported.
+func (c *client) UploadFile(ctx context.Context, file Input) (Input, error) {
+	if err := c.life.enter(); err != nil {
+		return nil, err
+	}
+	defer c.life.leave()
+
+	fi, ok := file.(fileInput)
+	if !ok || len(fi.Data) == 0 {
+		return nil, NewGrailError(InvalidArgument, "file must be a file input with data")
+	}
+	up, ok := c.provider.(FileUploader)
+	if !ok {
+		name := c.provider.Name()
+		return nil, NewGrailError(Unsupported, fmt.Sprintf("provider %s does not support file uploads", name)).WithProviderName(name)
+	}
+	mime := fi.MIME
+	if mime == "" {
+		mime = SniffImageMIME(fi.Data)
+	}
+	id, err := up.UploadFile(c.withTransport(ctx), fi.Data, mime, fi.Name)
+	if err != nil {
+		return nil, NewGrailError(GetErrorCode(err), fmt.Sprintf("upload file: %v", err)).
+			WithCause(err).WithProviderName(c.provider.Name()).WithRetryable(IsRetryable(err))
+	}
+	return uploadedFileInput{ID: id, MIME: mime, Name: fi.Name, Size: int64(len(fi.Data)), CacheBreakpoint: fi.CacheBreakpoint, Droppable: fi.Droppable, Priority: fi.Priority}, nil
+}
+
+// DefaultUploadThreshold is the file size from which AttachmentStore uploads
+// files when its UploadThreshold is zero.
+const DefaultUploadThreshold = 1 << 20
+
+// AttachmentStore deduplicates file inputs by content hash across requests,
+// so a document referenced by many requests is held in memory once and, for
+// providers that implement FileUploader, uploaded once:
+//
+//	store := &grail.AttachmentStore{}
+//	client := grail.NewClient(provider, grail.WithAttachmentStore(store))
+//
+// Files at least UploadThreshold bytes are uploaded the first time a
+// request uses them and sent by file ID after that; concurrent requests
+// share one upload, and a failed upload fails the request and is retried by
+// the next one. Smaller files, and every file for providers that don't
+// upload, are sent inline as usual. Uploaded files stay on the provider
+// until deleted there; FileIDs lists them.
+//
+// On a client with a BlobStore (see WithBlobStore), each file is also saved
+// to it under its AttachmentBlobKey the first time a request uses it, so
+// transcripts' attachment refs can be resolved later; saves are shared and
+// retried like uploads.
+//
+// The store is keyed by AttachmentRef and never evicts, so use one per
+// working set (a batch run, a session) rather than one per process.
+type AttachmentStore struct {
+	UploadThreshold int64 // default DefaultUploadThreshold
+
+	mu      sync.Mutex
+	files   map[string][]byte
+	uploads map[string]*attachmentUpload // keyed by provider name and ref
+	saves   map[string]*attachmentUpload // to the client's BlobStore, keyed by blob key
+}
+
+type attachmentUpload struct {
+	done chan struct{}
+	id   string
+	err  error
+}
+
+// WithAttachmentStore routes the client's file inputs through store. Child
+// clients created with With share it.
+func WithAttachmentStore(store *AttachmentStore) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.attachments = store
+	})
+}
+
+// Len returns the number of distinct files held.
+func (s *AttachmentStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.files)
+}
+
+// FileIDs returns the IDs of the files uploaded to the named provider, keyed
+// by AttachmentRef.
+func (s *AttachmentStore) FileIDs(provider string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := map[string]string{}
+	for key, u := range s.uploads {
+		select {
+		case <-u.done:
+		default:
+			continue
+		}
+		if p, ref, ok := splitUploadKey(key); ok && p == provider && u.err == nil {
+			ids[ref] = u.id
+		}
+	}
+	return ids
+}
+
+// intern returns the store's copy of data, adding it if it's new.
+func (s *AttachmentStore) intern(ref string, data []byte) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stored, ok := s.files[ref]; ok {
+		return stored
+	}
+	if s.files == nil {
+		s.files = map[string][]byte{}
+	}
+	s.files[ref] = data
+	return data
+}
+
+// upload returns the provider's file ID for ref, uploading data if no
+// upload has succeeded or is in progress.
+func (s *AttachmentStore) upload(ctx context.Context, p Provider, up FileUploader, ref string, data []byte, mime, name string) (string, error) {
+	return s.once(ctx, &s.uploads, p.Name()+"\x00"+ref, "waiting for file upload", func() (string, error) {
+		return up.UploadFile(ctx, data, mime, name)
+	})
+}
+
+// save saves data to blobs under ref's AttachmentBlobKey, unless a save has
+// succeeded or is in progress.
+func (s *AttachmentStore) save(ctx context.Context, blobs BlobStore, ref string, data []byte, mime string) error {
+	key := AttachmentBlobKey(ref)
+	_, err := s.once(ctx, &s.saves, key, "waiting for file save", func() (string, error) {
+		return key, blobs.Put(ctx, key, data, mime)
+	})
+	return err
+}
+
+// once runs fn for key unless a run has succeeded or is in progress in
+// runs, and returns its result. Failed runs are forgotten, so the next call
+// retries.
+func (s *AttachmentStore) once(ctx context.Context, runs *map[string]*attachmentUpload, key, waiting string, fn func() (string, error)) (string, error) {
+	s.mu.Lock()
+	u, ok := (*runs)[key]
+	if !ok {
+		if *runs == nil {
+			*runs = map[string]*attachmentUpload{}
+		}
+		u = &attachmentUpload{done: make(chan struct{})}
+		(*runs)[key] = u
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		u.id, u.err = fn()
+		if u.err != nil {
+			s.mu.Lock()
+			delete(*runs, key)
+			s.mu.Unlock()
+		}
+		close(u.done)
+	}
+	select {
+	case <-u.done:
+		return u.id, u.err
+	case <-ctx.Done():
+		return "", NewGrailError(Timeout, waiting).WithCause(ctx.Err())
+	}
+}
+
+func splitUploadKey(key string) (provider, ref string, ok bool) {
+	return strings.Cut(key, "\x00")
+}
+
+// substitute replaces req's file inputs with the store's copies or uploads,
+// saving new files to blobs if it's non-nil.
+func (s *AttachmentStore) substitute(ctx context.Context, p Provider, blobs BlobStore, req Request) (Request, error) {
+	threshold := s.UploadThreshold
+	if threshold <= 0 {
+		threshold = DefaultUploadThreshold
+	}
+	up, canUpload := p.(FileUploader)
+	var inputs []Input
+	for i, in := range req.Inputs {
+		fi, ok := in.(fileInput)
+		if !ok {
+			continue
+		}
+		if inputs == nil {
+			inputs = append([]Input(nil), req.Inputs...)
+		}
+		ref := AttachmentRef(fi.Data)
+		fi.Data = s.intern(ref, fi.Data)
+		if blobs != nil {
+			if err := s.save(ctx, blobs, ref, fi.Data, fi.MIME); err != nil {
+				return req, NewGrailError(GetErrorCode(err), fmt.Sprintf("input %d: save file: %v", i, err)).
+					WithCause(err).WithRetryable(IsRetryable(err))
+			}
+		}
+		if !canUpload || int64(len(fi.Data)) < threshold {
+			inputs[i] = fi
+			continue
+		}
+		mime := fi.MIME
+		if mime == "" {
+			mime = SniffImageMIME(fi.Data)
+		}
+		id, err := s.upload(ctx, p, up, ref, fi.Data, mime, fi.Name)
+		if err != nil {
+			return req, NewGrailError(GetErrorCode(err), fmt.Sprintf("input %d: upload file: %v", i, err)).
+				WithCause(err).WithProviderName(p.Name()).WithRetryable(IsRetryable(err))
+		}
+		inputs[i] = uploadedFileInput{ID: id, MIME: mime, Name: fi.Name, Size: int64(len(fi.Data)), CacheBreakpoint: fi.CacheBreakpoint, Droppable: fi.Droppable, Priority: fi.Priority}
+	}
+	if inputs != nil {
+		req.Inputs = inputs
+	}
+	return req, nil
+}
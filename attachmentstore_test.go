@@ -0,0 +1,132 @@
+package grail_test
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+// uploadingProvider is a mock provider that implements grail.FileUploader.
+type uploadingProvider struct {
+	mock.Provider
+	uploads atomic.Int32
+	fail    atomic.Bool
+}
+
+func (p *uploadingProvider) UploadFile(ctx context.Context, data []byte, mime, name string) (string, error) {
+	p.uploads.Add(1)
+	if p.fail.Load() {
+		return "", grail.NewGrailError(grail.Unavailable, "upload failed")
+	}
+	return "file-" + name, nil
+}
+
+func TestAttachmentStore_Uploads(t *testing.T) {
+	var mu sync.Mutex
+	var ids []string
+	prov := &uploadingProvider{}
+	prov.GenerateFn = func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		for _, in := range req.Inputs {
+			if id, mime, _, ok := grail.AsUploadedFileInput(in); ok {
+				if mime != "application/pdf" {
+					t.Errorf("uploaded input MIME = %q", mime)
+				}
+				mu.Lock()
+				ids = append(ids, id)
+				mu.Unlock()
+			}
+		}
+		return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("ok")}}, nil
+	}
+	store := &grail.AttachmentStore{UploadThreshold: 4}
+	client := grail.NewClient(prov, grail.WithAttachmentStore(store))
+	generate := func(inputs ...grail.Input) error {
+		_, err := client.Generate(context.Background(), grail.Request{Inputs: inputs, Output: grail.OutputText()})
+		return err
+	}
+	pdf := func() grail.Input { return grail.InputPDF([]byte("%PDF-1.7 report"), grail.WithFileName("report.pdf")) }
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			if err := generate(grail.InputText("summarize"), pdf()); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+	if n := prov.uploads.Load(); n != 1 || len(ids) != 8 || ids[0] != "file-report.pdf" {
+		t.Fatalf("expected one shared upload used by all 8 requests, got %d uploads and IDs %v", n, ids)
+	}
+	if got := store.FileIDs("mock"); len(got) != 1 || got[grail.AttachmentRef([]byte("%PDF-1.7 report"))] != "file-report.pdf" {
+		t.Fatalf("FileIDs = %v", got)
+	}
+
+	// Files under the threshold are sent inline.
+	ids = nil
+	if err := generate(grail.InputText("tiny"), grail.InputFile([]byte("abc"), "text/plain")); err != nil || len(ids) != 0 {
+		t.Fatalf("expected a small file inline, got %v (err %v)", ids, err)
+	}
+
+	// A failed upload fails the request and is retried by the next one.
+	prov.fail.Store(true)
+	other := grail.InputPDF([]byte("%PDF-1.7 other"), grail.WithFileName("other.pdf"))
+	if err := generate(grail.InputText("x"), other); !grail.IsRetryable(err) {
+		t.Fatalf("expected a retryable upload error, got %v", err)
+	}
+	prov.fail.Store(false)
+	if err := generate(grail.InputText("x"), other); err != nil {
+		t.Fatal(err)
+	}
+	if n := prov.uploads.Load(); n != 3 {
+		t.Fatalf("expected the failed upload to be retried, got %d uploads", n)
+	}
+	if store.Len() != 3 {
+		t.Fatalf("Len = %d, want 3 distinct files", store.Len())
+	}
+}
+
+func TestAttachmentStore_DedupesInline(t *testing.T) {
+	var seen [][]byte
+	prov := &mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		data, _, _, _ := grail.AsFileInput(req.Inputs[1])
+		seen = append(seen, data)
+		return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("ok")}}, nil
+	}}
+	client := grail.NewClient(prov, grail.WithAttachmentStore(&grail.AttachmentStore{}))
+	for range 2 {
+		// Each request brings its own copy of the same bytes.
+		doc := bytes.Clone([]byte("%PDF-1.7 shared"))
+		if _, err := client.Generate(context.Background(), grail.Request{
+			Inputs: []grail.Input{grail.InputText("read"), grail.InputPDF(doc)},
+			Output: grail.OutputText(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(seen) != 2 || &seen[0][0] != &seen[1][0] {
+		t.Fatal("expected both requests to send the store's single copy")
+	}
+}
+
+func TestUploadFile(t *testing.T) {
+	ctx := context.Background()
+	in, err := grail.NewClient(&uploadingProvider{}).UploadFile(ctx, grail.InputPDF([]byte("%PDF-1.4"), grail.WithFileName("report.pdf")))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id, mime, name, ok := grail.AsUploadedFileInput(in); !ok || id != "file-report.pdf" || mime != "application/pdf" || name != "report.pdf" {
+		t.Fatalf("unexpected upload %q %q %q", id, mime, name)
+	}
+	if _, err := grail.NewClient(&mock.Provider{}).UploadFile(ctx, grail.InputPDF([]byte("%PDF-1.4"))); grail.GetErrorCode(err) != grail.Unsupported {
+		t.Errorf("expected Unsupported without uploads, got %v", err)
+	}
+	if _, err := grail.NewClient(&uploadingProvider{}).UploadFile(ctx, grail.InputText("hi")); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected InvalidArgument for text, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package grail
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+//
+// Per-attempt deadlines
+//
+
+// WithAttemptDeadlines splits the time left before a Generate call's deadline
+// across the HTTP attempts the provider's SDK makes, so a slow first attempt
+// can't use up the whole budget and leave none for the retry that would have
+// succeeded. Each attempt gets the time remaining divided by the attempts
+// left, counting the current one; the last gets all of it. attempts is the
+// most the provider's SDK makes per call: the OpenAI SDK makes 3 (a try and
+// two retries) by default. Calls without a deadline are unaffected.
+//
+// Like WithTransportLogging, it applies to the client's own calls, not those
+// of other clients sharing its provider, has no effect on providers that
+// don't implement TransportAware, and child clients created with With keep
+// their parent's setting.
+func WithAttemptDeadlines(attempts int) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.attemptDeadlines = attempts
+	})
+}
+
+type attemptBudgetKey struct{}
+
+// withAttemptBudget returns a context in which HTTP attempts are counted
+// against the deadline.
+func withAttemptBudget(ctx context.Context) context.Context {
+	if _, ok := ctx.Deadline(); !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, attemptBudgetKey{}, new(atomic.Int32))
+}
+
+// attemptBudget gives each round trip its share of the time before the
+// request context's deadline.
+type attemptBudget struct {
+	base     http.RoundTripper
+	attempts int
+}
+
+func (b *attemptBudget) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := b.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	ctx := req.Context()
+	n, ok := ctx.Value(attemptBudgetKey{}).(*atomic.Int32)
+	deadline, hasDeadline := ctx.Deadline()
+	if !ok || !hasDeadline {
+		return base.RoundTrip(req)
+	}
+	left := b.attempts - int(n.Add(1)) + 1
+	if left <= 1 {
+		return base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Until(deadline)/time.Duration(left))
+	res, err := base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return res, err
+	}
+	// The attempt lasts until its body is read, so streamed responses are
+	// bounded too.
+	res.Body = &cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
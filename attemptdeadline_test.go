@@ -0,0 +1,68 @@
+package grail_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/openai"
+)
+
+func TestAttemptDeadlines(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if calls.Add(1) == 1 {
+			<-r.Context().Done() // the first attempt hangs until abandoned
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"resp_1","object":"response","status":"completed","model":"gpt-5.4",
+			"output":[{"type":"message","id":"msg_1","role":"assistant","status":"completed",
+				"content":[{"type":"output_text","text":"hi","annotations":[]}]}]}`)
+	}))
+	defer srv.Close()
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("hello")}, Output: grail.OutputText()}
+
+	newProvider := func() grail.Provider {
+		t.Helper()
+		p, err := openai.New(openai.WithAPIKey("dummy"), openai.WithBaseURL(srv.URL+"/v1/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	generateWith := func(c grail.Client) error {
+		calls.Store(0)
+		ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+		defer cancel()
+		_, err := c.Generate(ctx, req)
+		return err
+	}
+	generate := func(opts ...grail.ClientOption) error {
+		return generateWith(grail.NewClient(newProvider(), opts...))
+	}
+
+	if err := generate(); err == nil {
+		t.Fatal("expected the hung first attempt to use up the deadline")
+	}
+	if err := generate(grail.WithAttemptDeadlines(3)); err != nil {
+		t.Fatalf("expected the retry to get its share of the deadline, got %v", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("expected 2 attempts, got %d", n)
+	}
+
+	// The split applies only to the client that asked for it, not to other
+	// clients sharing its provider.
+	p := newProvider()
+	grail.NewClient(p, grail.WithAttemptDeadlines(3))
+	if err := generateWith(grail.NewClient(p)); err == nil {
+		t.Fatal("expected a client without attempt deadlines to be unaffected")
+	}
+}
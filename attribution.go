@@ -0,0 +1,110 @@
+package grail
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/montanaflynn/grail/internal/imaging"
+)
+
+//
+// AI attribution
+//
+
+// WarningAttributionSkipped is set on responses with an image Attribute
+// couldn't embed attribution in, because its format has no supported text
+// metadata.
+const WarningAttributionSkipped = "attribution_skipped"
+
+// Attribution configures the disclosure Attribute adds to outputs.
+type Attribution struct {
+	// Disclosure is the statement added, e.g. "This content was generated
+	// by AI."
+	Disclosure string
+	// Model adds the names of the models that produced the response.
+	Model bool
+	// Timestamp adds when the response was generated, in UTC.
+	Timestamp bool
+	// Separator goes between a text output and its footer. It defaults to a
+	// blank line.
+	Separator string
+}
+
+// Attribute is a PostProcessor that discloses outputs as AI-generated, as
+// some jurisdictions require. Text outputs get a footer such as
+//
+//	This content was generated by AI. Model: gpt-5. Generated: 2026-10-17T09:30:00Z.
+//
+// and PNG and JPEG images get the same fields embedded as metadata (PNG
+// "Disclaimer", "Source" and "Creation Time" text chunks, or a JPEG comment).
+// Images in other formats are returned unchanged with a
+// WarningAttributionSkipped warning. JSON outputs are left alone, since a
+// footer would make them invalid.
+//
+// Put it after processors that rewrite text. WithImagePostProcessing runs
+// before post-processors, so its StripMetadata doesn't remove the fields.
+func Attribute(a Attribution) PostProcessor {
+	sep := a.Separator
+	if sep == "" {
+		sep = "\n\n"
+	}
+	return PostProcessor{
+		Text: func(ctx context.Context, text string) (string, error) {
+			var footer []string
+			for _, f := range a.fields(ctx, time.Now()) {
+				switch f.Key {
+				case "Disclaimer":
+					footer = append(footer, strings.TrimSuffix(f.Value, ".")+".")
+				case "Source":
+					footer = append(footer, "Model: "+f.Value+".")
+				case "Creation Time":
+					footer = append(footer, "Generated: "+f.Value+".")
+				}
+			}
+			if len(footer) == 0 {
+				return text, nil
+			}
+			return text + sep + strings.Join(footer, " "), nil
+		},
+		Image: func(ctx context.Context, data []byte, mime string) ([]byte, string, error) {
+			fields := a.fields(ctx, time.Now())
+			if len(fields) == 0 {
+				return data, mime, nil
+			}
+			out, ok := imaging.AddText(data, fields)
+			if !ok {
+				AddWarning(ctx, Warning{Code: WarningAttributionSkipped, Message: "can't embed attribution in " + mime + " images"})
+			}
+			return out, mime, nil
+		},
+	}
+}
+
+// fields returns the attribution for the response being processed, keyed by
+// registered PNG text keywords.
+func (a Attribution) fields(ctx context.Context, now time.Time) []imaging.TextField {
+	var fields []imaging.TextField
+	if a.Disclosure != "" {
+		fields = append(fields, imaging.TextField{Key: "Disclaimer", Value: a.Disclosure})
+	}
+	if a.Model {
+		info, _ := ctx.Value(providerInfoKey{}).(ProviderInfo)
+		var models []string
+		for _, m := range info.Models {
+			if m.Name != "" {
+				models = append(models, m.Name)
+			}
+		}
+		if len(models) == 0 && info.Name != "" {
+			models = append(models, info.Name)
+		}
+		if len(models) > 0 {
+			fields = append(fields, imaging.TextField{Key: "Source", Value: strings.Join(models, ", ")})
+		}
+	}
+	if a.Timestamp {
+		fields = append(fields, imaging.TextField{Key: "Creation Time", Value: now.UTC().Format(time.RFC3339)})
+	}
+	return fields
+}
@@ -0,0 +1,64 @@
+package grail_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestAttribute(t *testing.T) {
+	var pngBuf, jpegBuf bytes.Buffer
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	if err := png.Encode(&pngBuf, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(&jpegBuf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{
+				Provider: grail.ProviderInfo{Name: "mock", Models: []grail.ModelUse{{Role: "language", Name: "mock-1"}}},
+				Outputs: []grail.OutputPart{
+					grail.NewTextOutputPart("Hello."),
+					grail.NewImageOutputPart(pngBuf.Bytes(), "image/png", ""),
+					grail.NewImageOutputPart(jpegBuf.Bytes(), "image/jpeg", ""),
+					grail.NewImageOutputPart([]byte("GIF89a"), "image/gif", ""),
+				},
+			}, nil
+		},
+	}
+	client := grail.NewClient(prov, grail.WithPostProcessors(grail.Attribute(grail.Attribution{
+		Disclosure: "This content was generated by AI",
+		Model:      true,
+		Timestamp:  true,
+	})))
+	res, err := client.Generate(context.Background(), grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	text, _ := res.Text()
+	if !strings.HasPrefix(text, "Hello.\n\nThis content was generated by AI. Model: mock-1. Generated: ") {
+		t.Errorf("unexpected text %q", text)
+	}
+	imgs, _ := res.Images()
+	for i, mime := range []string{"png", "jpeg"} {
+		if !bytes.Contains(imgs[i], []byte("This content was generated by AI")) || !bytes.Contains(imgs[i], []byte("mock-1")) {
+			t.Errorf("expected attribution in the %s image", mime)
+		}
+		if _, _, err := image.Decode(bytes.NewReader(imgs[i])); err != nil {
+			t.Errorf("expected the %s image to still decode, got %v", mime, err)
+		}
+	}
+	if len(res.Warnings) != 1 || res.Warnings[0].Code != grail.WarningAttributionSkipped {
+		t.Errorf("expected the GIF to be skipped, got %+v", res.Warnings)
+	}
+}
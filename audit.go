@@ -0,0 +1,88 @@
+package grail
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+//
+// Audit logs
+//
+
+// AuditEntry records one Generate call. It holds no prompts or generated
+// content; Key ties it to the request without storing it.
+type AuditEntry struct {
+	Time      time.Time // when the call started
+	Duration  time.Duration
+	Key       string // the request's RequestKey
+	Provider  string
+	Model     string // the model that answered, or the one requested if none did
+	RequestID string // the provider's request ID
+	Metadata  map[string]string
+	Usage     Usage
+	Code      ErrorCode // empty if the call succeeded
+	Error     string
+}
+
+// AuditLog records every Generate call of a client (see WithAuditLog).
+// MemoryAuditLog keeps entries in memory; stores/postgres keeps them in a
+// database. Implementations must be safe for concurrent use.
+type AuditLog interface {
+	Record(ctx context.Context, e AuditEntry) error
+}
+
+// WithAuditLog records every Generate call in log, after review, whether it
+// succeeded or not. Entries are recorded before Generate returns; if log
+// fails, the failure is logged and the call's result is unchanged.
+func WithAuditLog(log AuditLog) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.auditLog = log
+	})
+}
+
+// MemoryAuditLog is an AuditLog held in memory. The zero value is ready to
+// use.
+type MemoryAuditLog struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+}
+
+// Record implements AuditLog.
+func (l *MemoryAuditLog) Record(ctx context.Context, e AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, e)
+	return nil
+}
+
+// Entries returns the recorded entries, oldest first.
+func (l *MemoryAuditLog) Entries() []AuditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]AuditEntry(nil), l.entries...)
+}
+
+// audit records a finished Generate call in the client's audit log.
+func (c *client) audit(ctx context.Context, req Request, res Response, err error, start time.Time) {
+	e := AuditEntry{
+		Time:      start,
+		Duration:  time.Since(start),
+		Key:       RequestKey(req),
+		Provider:  res.Provider.Name,
+		Model:     req.Model,
+		RequestID: res.RequestID,
+		Metadata:  mergeMetadata(MetadataFromContext(ctx), req.Metadata),
+		Usage:     res.Usage,
+	}
+	if len(res.Provider.Models) > 0 {
+		e.Model = res.Provider.Models[0].Name
+	}
+	if err != nil {
+		e.Code = GetErrorCode(err)
+		e.Error = err.Error()
+	}
+	if rerr := c.opts.auditLog.Record(context.WithoutCancel(ctx), e); rerr != nil && c.log != nil {
+		c.log.Warn("audit log record failed", "error", rerr, "key", e.Key)
+	}
+}
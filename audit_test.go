@@ -0,0 +1,52 @@
+package grail_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestAuditLog(t *testing.T) {
+	mp := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			if prompt, _ := grail.AsTextInput(req.Inputs[0]); prompt == "refuse" {
+				return grail.Response{}, grail.NewGrailError(grail.Refused, "no")
+			}
+			return grail.Response{
+				Outputs:   []grail.OutputPart{grail.NewTextOutputPart("ok")},
+				Usage:     grail.Usage{InputTokens: 3, OutputTokens: 1, TotalTokens: 4},
+				Provider:  grail.ProviderInfo{Name: "mock", Models: []grail.ModelUse{{Name: "m-1"}}},
+				RequestID: "req_1",
+			}, nil
+		},
+	}
+	log := &grail.MemoryAuditLog{}
+	client := grail.NewClient(mp, grail.WithAuditLog(log))
+	ctx := grail.ContextWithMetadata(context.Background(), "tenant", "acme")
+
+	ok := grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText(), Model: "m"}
+	if _, err := client.Generate(ctx, ok); err != nil {
+		t.Fatal(err)
+	}
+	refused := grail.Request{Inputs: []grail.Input{grail.InputText("refuse")}, Output: grail.OutputText(), Model: "m"}
+	if _, err := client.Generate(ctx, refused); err == nil {
+		t.Fatal("expected a refusal")
+	}
+
+	entries := log.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	e := entries[0]
+	if e.Key != grail.RequestKey(ok) || e.Provider != "mock" || e.Model != "m-1" || e.RequestID != "req_1" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if e.Usage.TotalTokens != 4 || e.Metadata["tenant"] != "acme" || e.Code != "" || e.Time.IsZero() {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if e := entries[1]; e.Code != grail.Refused || e.Error == "" || e.Model != "m" {
+		t.Errorf("expected the refusal to be recorded, got %+v", e)
+	}
+}
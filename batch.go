@@ -0,0 +1,96 @@
+package grail
+
+import (
+	"context"
+	"sync"
+)
+
+//
+// Batch generation
+//
+
+// BatchOptions configures GenerateBatch.
+type BatchOptions struct {
+	// Concurrency bounds the number of in-flight requests (default 4).
+	Concurrency int
+	// StopOnError cancels requests that have not started yet once any request
+	// fails. Their results carry the context error.
+	StopOnError bool
+	// Journal records requests before dispatch and their responses on
+	// completion. Requests it already records as completed are answered from
+	// it rather than generated again, so an interrupted batch can be resumed.
+	Journal *Journal
+}
+
+// BatchResult is the outcome of one request in a batch.
+type BatchResult struct {
+	Index    int // position of the request in the input slice
+	Response Response
+	Err      error
+	Resumed  bool // answered from BatchOptions.Journal
+}
+
+// GenerateBatch runs reqs through c with bounded concurrency and returns one
+// result per request, in input order. Individual failures are reported in
+// BatchResult.Err rather than aborting the batch (unless StopOnError is set).
+// With a journal, a request that can't be journaled fails rather than running
+// unrecorded, and a response that can't be journaled is returned with the
+// error.
+func GenerateBatch(ctx context.Context, c Client, reqs []Request, opts BatchOptions) []BatchResult {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var hashes []string
+	if opts.Journal != nil {
+		hashes = journalHashes(reqs)
+	}
+
+	results := make([]BatchResult, len(reqs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range reqs {
+		results[i].Index = i
+		if opts.Journal != nil {
+			if res, ok := opts.Journal.lookup(i, hashes[i]); ok {
+				results[i].Response, results[i].Resumed = res, true
+				continue
+			}
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := ctx.Err(); err != nil {
+				results[i].Err = err
+				return
+			}
+			if opts.Journal != nil {
+				if err := opts.Journal.submit(i, hashes[i]); err != nil {
+					results[i].Err = err
+					return
+				}
+			}
+			res, err := c.Generate(ctx, reqs[i])
+			if err == nil && opts.Journal != nil {
+				err = opts.Journal.complete(i, hashes[i], res)
+			}
+			results[i].Response, results[i].Err = res, err
+			if err != nil && opts.StopOnError {
+				cancel()
+			}
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,230 @@
+package grail
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+//
+// Blob storage
+//
+
+// BlobStore keeps files outside the process, such as in an S3, MinIO, or GCS
+// bucket (see stores/s3). A client configured WithBlobStore saves generated
+// images and videos and, with an AttachmentStore, input files to it;
+// BlobResultStore keeps activity results in it. MemoryBlobStore keeps blobs
+// in memory. Implementations must be safe for concurrent use.
+type BlobStore interface {
+	// Put saves data under key, replacing any blob there.
+	Put(ctx context.Context, key string, data []byte, mime string) error
+	// Get returns the blob under key, failing with NotFound if there is
+	// none.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// URL returns a URL that fetches the blob under key without credentials
+	// until ttl has passed, such as a presigned URL, for handing blobs to
+	// end users.
+	URL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// WarningBlobNotStored is set on a response whose image or video outputs
+// couldn't be saved to the client's BlobStore.
+const WarningBlobNotStored = "blob_not_stored"
+
+// WithBlobStore saves the image and video outputs of every successful
+// response to store before Generate returns, under their OutputBlobKey, so
+// Response.OutputURLs can hand them to end users. A failed save adds a
+// WarningBlobNotStored warning rather than failing the request. With an
+// AttachmentStore, input files are saved too (see AttachmentBlobKey). Child
+// clients created with With share the store.
+func WithBlobStore(store BlobStore) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.blobs = store
+	})
+}
+
+// OutputBlobKey returns the key an image or video output is saved under by
+// WithBlobStore: "outputs/" and its content hash, with an extension for its
+// type. Equal outputs share a key.
+func OutputBlobKey(part OutputPart) (key string, ok bool) {
+	data, _, ext, ok := blobOutput(part)
+	if !ok {
+		return "", false
+	}
+	return "outputs/" + strings.TrimPrefix(AttachmentRef(data), "sha256:") + ext, true
+}
+
+// AttachmentBlobKey returns the key an input file with the given
+// AttachmentRef is saved under by an AttachmentStore on a client with a
+// BlobStore: "attachments/" and its content hash.
+func AttachmentBlobKey(ref string) string {
+	return "attachments/" + strings.TrimPrefix(ref, "sha256:")
+}
+
+// blobOutput returns the data, MIME type, and file extension of an image or
+// video output.
+func blobOutput(part OutputPart) (data []byte, mime, ext string, ok bool) {
+	switch v := part.(type) {
+	case imageOutputPart:
+		mime = v.MIME
+		if mime == "" {
+			mime = SniffImageMIME(v.Data)
+		}
+		return v.Data, mime, imageExt(mime), true
+	case videoOutputPart:
+		mime = v.MIME
+		if mime == "" {
+			mime = "video/mp4"
+		}
+		return v.Data, mime, videoExt(mime), true
+	}
+	return nil, "", "", false
+}
+
+// OutputURLs returns a URL for each output that fetches it without
+// credentials until ttl has passed: image and video outputs saved by
+// WithBlobStore get their store's URL, and other outputs get "". It fails
+// with Unsupported if the response's client has no BlobStore.
+func (r Response) OutputURLs(ctx context.Context, ttl time.Duration) ([]string, error) {
+	if r.blobs == nil {
+		return nil, NewGrailError(Unsupported, "response outputs weren't saved to a blob store (see WithBlobStore)")
+	}
+	urls := make([]string, len(r.Outputs))
+	for i, part := range r.Outputs {
+		key, ok := OutputBlobKey(part)
+		if !ok {
+			continue
+		}
+		url, err := r.blobs.URL(ctx, key, ttl)
+		if err != nil {
+			return nil, err
+		}
+		urls[i] = url
+	}
+	return urls, nil
+}
+
+// saveOutputs saves res's image and video outputs to the client's blob
+// store, warning about any that fail.
+func (c *client) saveOutputs(ctx context.Context, res *Response) {
+	res.blobs = c.opts.blobs
+	saved := map[string]bool{}
+	for i, part := range res.Outputs {
+		data, mime, _, ok := blobOutput(part)
+		if !ok {
+			continue
+		}
+		key, _ := OutputBlobKey(part)
+		if saved[key] {
+			continue
+		}
+		saved[key] = true
+		if err := c.opts.blobs.Put(ctx, key, data, mime); err != nil {
+			res.Warnings = append(res.Warnings, Warning{
+				Code:    WarningBlobNotStored,
+				Message: fmt.Sprintf("output %d: %v", i, err),
+			})
+		}
+	}
+}
+
+// MemoryBlobStore is a BlobStore held in memory, for a single process and
+// for tests. Its URLs are data: URLs holding the blob, which don't expire.
+// The zero value is ready to use.
+type MemoryBlobStore struct {
+	mu    sync.Mutex
+	blobs map[string]memoryBlob
+}
+
+type memoryBlob struct {
+	data []byte
+	mime string
+}
+
+// Put implements BlobStore.
+func (s *MemoryBlobStore) Put(ctx context.Context, key string, data []byte, mime string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.blobs == nil {
+		s.blobs = map[string]memoryBlob{}
+	}
+	s.blobs[key] = memoryBlob{data: append([]byte(nil), data...), mime: mime}
+	return nil
+}
+
+// Get implements BlobStore.
+func (s *MemoryBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.blobs[key]
+	if !ok {
+		return nil, NewGrailError(NotFound, fmt.Sprintf("blob %q not found", key))
+	}
+	return append([]byte(nil), b.data...), nil
+}
+
+// URL implements BlobStore with a data: URL.
+func (s *MemoryBlobStore) URL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.blobs[key]
+	if !ok {
+		return "", NewGrailError(NotFound, fmt.Sprintf("blob %q not found", key))
+	}
+	mime := b.mime
+	if mime == "" {
+		mime = "application/octet-stream"
+	}
+	return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(b.data), nil
+}
+
+// Keys returns the keys of the stored blobs.
+func (s *MemoryBlobStore) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.blobs))
+	for k := range s.blobs {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// BlobResultStore is a ResultStore in a BlobStore, for activities whose
+// workers share a bucket rather than a database. Results are JSON
+// ResponseRecords under "results/" and their key.
+type BlobResultStore struct {
+	Store BlobStore
+	// Keys, if set, encrypts results with AES-GCM (see Seal), each bound to
+	// its key. Results that aren't encrypted with them can't be read.
+	Keys KeyProvider
+}
+
+var _ ResultStore = BlobResultStore{}
+
+// Get implements ResultStore.
+func (s BlobResultStore) Get(ctx context.Context, key string) (ResponseRecord, bool, error) {
+	data, err := s.Store.Get(ctx, "results/"+key+".json")
+	if GetErrorCode(err) == NotFound {
+		return ResponseRecord{}, false, nil
+	}
+	if err != nil {
+		return ResponseRecord{}, false, err
+	}
+	var rec ResponseRecord
+	if err := openJSON(s.Keys, data, &rec, "grail/result\x00"+key); err != nil {
+		return ResponseRecord{}, false, NewGrailError(Internal, fmt.Sprintf("decode result %q: %v", key, err)).WithCause(err)
+	}
+	return rec, true, nil
+}
+
+// Put implements ResultStore.
+func (s BlobResultStore) Put(ctx context.Context, key string, res ResponseRecord) error {
+	data, err := sealJSON(s.Keys, res, "grail/result\x00"+key)
+	if err != nil {
+		return NewGrailError(InvalidArgument, fmt.Sprintf("encode result %q: %v", key, err)).WithCause(err)
+	}
+	return s.Store.Put(ctx, "results/"+key+".json", data, "application/json")
+}
@@ -0,0 +1,139 @@
+package grail_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+// failingBlobs is a BlobStore that can't be reached.
+type failingBlobs struct{ grail.MemoryBlobStore }
+
+func (*failingBlobs) Put(ctx context.Context, key string, data []byte, mime string) error {
+	return grail.NewGrailError(grail.Unavailable, "bucket unreachable")
+}
+
+func TestBlobStore(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\nimage")
+	mp := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{Outputs: []grail.OutputPart{
+				grail.NewTextOutputPart("here it is"),
+				grail.NewImageOutputPart(png, "", ""),
+			}}, nil
+		},
+	}
+	blobs := &grail.MemoryBlobStore{}
+	client := grail.NewClient(mp, grail.WithBlobStore(blobs), grail.WithAttachmentStore(&grail.AttachmentStore{}))
+	doc := []byte("notes")
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("draw"), grail.InputFile(doc, "text/plain")}, Output: grail.OutputImage(grail.ImageSpec{})}
+	ctx := context.Background()
+
+	res, err := client.Generate(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, ok := grail.OutputBlobKey(res.Outputs[1])
+	if !ok || !strings.HasPrefix(key, "outputs/") || !strings.HasSuffix(key, ".png") {
+		t.Fatalf("unexpected output key %q", key)
+	}
+	if data, err := blobs.Get(ctx, key); err != nil || string(data) != string(png) {
+		t.Errorf("expected the image to be saved, got %q (%v)", data, err)
+	}
+	if data, err := blobs.Get(ctx, grail.AttachmentBlobKey(grail.AttachmentRef(doc))); err != nil || string(data) != "notes" {
+		t.Errorf("expected the input file to be saved, got %q (%v)", data, err)
+	}
+	urls, err := res.OutputURLs(ctx, time.Hour)
+	if err != nil || len(urls) != 2 || urls[0] != "" || !strings.HasPrefix(urls[1], "data:image/png;base64,") {
+		t.Errorf("unexpected URLs %q (%v)", urls, err)
+	}
+
+	// A failed save warns without failing the request.
+	res, err = grail.NewClient(mp, grail.WithBlobStore(&failingBlobs{})).Generate(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Warnings) != 1 || res.Warnings[0].Code != grail.WarningBlobNotStored {
+		t.Errorf("expected a blob warning, got %+v", res.Warnings)
+	}
+
+	res, _ = grail.NewClient(mp).Generate(ctx, req)
+	if _, err := res.OutputURLs(ctx, time.Hour); grail.GetErrorCode(err) != grail.Unsupported {
+		t.Errorf("expected Unsupported without a blob store, got %v", err)
+	}
+}
+
+func TestBlobStore_AttachmentSaveFails(t *testing.T) {
+	mp := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("ok")}}, nil
+		},
+	}
+	client := grail.NewClient(mp, grail.WithBlobStore(&failingBlobs{}), grail.WithAttachmentStore(&grail.AttachmentStore{}))
+	req := grail.Request{Inputs: []grail.Input{grail.InputFile([]byte("notes"), "text/plain")}, Output: grail.OutputText()}
+	_, err := client.Generate(context.Background(), req)
+	if grail.GetErrorCode(err) != grail.Unavailable || !grail.IsRetryable(err) {
+		t.Errorf("expected a retryable save failure, got %v", err)
+	}
+}
+
+func TestBlobResultStore(t *testing.T) {
+	results := grail.BlobResultStore{Store: &grail.MemoryBlobStore{}}
+	ctx := context.Background()
+	if _, ok, err := results.Get(ctx, "k"); ok || err != nil {
+		t.Fatalf("expected no result, got %v %v", ok, err)
+	}
+	rec := grail.NewResponseRecord(grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("hi")}})
+	if err := results.Put(ctx, "k", rec); err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := results.Get(ctx, "k")
+	if !ok || err != nil {
+		t.Fatalf("Get = %v, %v", ok, err)
+	}
+	if text, _ := got.Response().Text(); text != "hi" {
+		t.Errorf("unexpected result %q", text)
+	}
+	if _, _, err := (grail.BlobResultStore{Store: &failingGetBlobs{}}).Get(ctx, "k"); err == nil {
+		t.Error("expected the store's error")
+	}
+}
+
+func TestBlobResultStore_Encryption(t *testing.T) {
+	blobs := &grail.MemoryBlobStore{}
+	results := grail.BlobResultStore{Store: blobs, Keys: grail.StaticKey(bytes.Repeat([]byte{9}, 32))}
+	ctx := context.Background()
+	rec := grail.NewResponseRecord(grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("secret")}})
+	if err := results.Put(ctx, "k", rec); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := blobs.Get(ctx, "results/k.json")
+	if bytes.Contains(data, []byte("secret")) {
+		t.Fatalf("result stored in plaintext: %s", data)
+	}
+	got, ok, err := results.Get(ctx, "k")
+	if text, _ := got.Response().Text(); !ok || err != nil || text != "secret" {
+		t.Fatalf("Get = %q, %v, %v", text, ok, err)
+	}
+
+	// A result can't be passed off under another key, or read without keys.
+	blobs.Put(ctx, "results/other.json", data, "application/json")
+	if _, _, err := results.Get(ctx, "other"); err == nil {
+		t.Error("expected a result moved to another key to fail")
+	}
+	if _, _, err := (grail.BlobResultStore{Store: blobs}).Get(ctx, "k"); err == nil {
+		t.Error("expected reading without keys to fail")
+	}
+}
+
+type failingGetBlobs struct{ grail.MemoryBlobStore }
+
+func (*failingGetBlobs) Get(ctx context.Context, key string) ([]byte, error) {
+	return nil, errors.New("boom")
+}
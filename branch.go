@@ -0,0 +1,124 @@
+package grail
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"reflect"
+	"time"
+)
+
+//
+// Conversation branches
+//
+
+// Branch returns a copy of the transcript's first n turns, for continuing
+// the conversation differently from there. n must fall between exchanges (be
+// even), since each exchange is a user turn and its reply. Attachment
+// contents are shared with t.
+func (t *Transcript) Branch(n int) (*Transcript, error) {
+	if n < 0 || n > len(t.Turns) || n%2 != 0 {
+		return nil, NewGrailError(InvalidArgument, fmt.Sprintf("can't branch at turn %d of %d; branch between exchanges", n, len(t.Turns)))
+	}
+	b := &Transcript{
+		Version:     t.Version,
+		CreatedAt:   time.Now().UTC(),
+		Turns:       append([]Turn(nil), t.Turns[:n]...),
+		Attachments: maps.Clone(t.Attachments),
+		data:        maps.Clone(t.data),
+	}
+	return b, nil
+}
+
+// Branch starts an alternate continuation of the conversation from before
+// turn n of its transcript, leaving s as it is. For "edit your message and
+// regenerate", branch at the edited user turn and send the new message:
+//
+//	branch, err := session.Branch(ctx, turn)
+//	res, err := branch.Send(ctx, grail.InputText(edited))
+//
+// The branch starts with s's options, with opts applied on top, so it can
+// try another model or provider options from the branch point. Conversation
+// context follows the branch when s uses WithSessionHistory; other state
+// strategies can't be rewound, so the branch needs its own WithSessionState
+// in opts. A session kept in a HistoryStore needs WithSessionStore with a
+// new, empty conversation, which is seeded with the branch's history.
+func (s *Session) Branch(ctx context.Context, n int, opts ...SessionOption) (*Session, error) {
+	s.mu.Lock()
+	t, err := s.transcript.Branch(n)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// Options the branch doesn't override are inherited, but state and
+	// stores hold the parent's conversation and can't be shared.
+	so := s.opts
+	so.transcript, so.state, so.store, so.conversation = nil, nil, nil, ""
+	for _, opt := range opts {
+		if opt != nil {
+			opt.applySessionOpt(&so)
+		}
+	}
+	if so.state == nil && s.opts.state != nil {
+		if _, ok := s.opts.state.(*historyState); !ok {
+			return nil, NewGrailError(InvalidArgument, fmt.Sprintf("session state %T can't follow a branch; give the branch its own with WithSessionState", s.opts.state))
+		}
+		so.state = &historyState{}
+	}
+	if h, ok := so.state.(*historyState); ok {
+		h.restore(t)
+	}
+
+	if so.store == nil && s.opts.store != nil {
+		return nil, NewGrailError(InvalidArgument, "branch needs its own conversation; give it one with WithSessionStore")
+	}
+	if so.store != nil {
+		existing, err := so.store.Load(ctx, so.conversation)
+		if err != nil {
+			return nil, err
+		}
+		if len(existing) > 0 {
+			return nil, NewGrailError(InvalidArgument, fmt.Sprintf("conversation %q already has messages", so.conversation))
+		}
+		history, _ := t.History()
+		if len(history) > 0 {
+			if err := so.store.Append(ctx, so.conversation, history...); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &Session{client: s.client, opts: so, transcript: t}, nil
+}
+
+// Fork starts an alternate continuation of the whole conversation so far,
+// such as to try the next message two ways. It's Branch at the end of the
+// transcript.
+func (s *Session) Fork(ctx context.Context, opts ...SessionOption) (*Session, error) {
+	s.mu.Lock()
+	n := len(s.transcript.Turns)
+	s.mu.Unlock()
+	return s.Branch(ctx, n, opts...)
+}
+
+// BranchComparison lines up two branches of a conversation.
+type BranchComparison struct {
+	Common int    // the number of turns the branches share
+	A, B   []Turn // each branch's turns after the shared ones
+}
+
+// CompareBranches compares the transcripts of two branches of a
+// conversation, such as ones made with Session.Branch to A/B test a model or
+// provider options. Turns are the same if they have the same role and parts;
+// when they were sent and how they were served is ignored.
+func CompareBranches(a, b *Transcript) BranchComparison {
+	n := 0
+	for n < len(a.Turns) && n < len(b.Turns) {
+		ta, tb := a.Turns[n], b.Turns[n]
+		if ta.Role != tb.Role || !reflect.DeepEqual(ta.Parts, tb.Parts) {
+			break
+		}
+		n++
+	}
+	return BranchComparison{Common: n, A: a.Turns[n:], B: b.Turns[n:]}
+}
@@ -0,0 +1,89 @@
+package grail_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestSessionBranch(t *testing.T) {
+	var got grail.Request
+	mp := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			got = req
+			text, _ := grail.AsTextInput(req.Inputs[0])
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart(req.Model + " re: " + text)}}, nil
+		},
+	}
+	ctx := context.Background()
+	s := grail.NewSession(grail.NewClient(historyProvider{mp}), grail.WithSessionHistory())
+	for _, msg := range []string{"one", "two", "three"} {
+		if _, err := s.Send(ctx, grail.InputText(msg)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// Edit the second message.
+	edited, err := s.Branch(ctx, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := edited.Send(ctx, grail.InputText("two, edited")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got.History) != 2 {
+		t.Fatalf("expected the branch's history to stop at the branch point, got %d messages", len(got.History))
+	}
+	if len(s.Transcript().Turns) != 6 || len(edited.Transcript().Turns) != 4 {
+		t.Fatalf("expected the original to be left alone, got %d and %d turns", len(s.Transcript().Turns), len(edited.Transcript().Turns))
+	}
+	cmp := grail.CompareBranches(s.Transcript(), edited.Transcript())
+	if cmp.Common != 2 || len(cmp.A) != 4 || len(cmp.B) != 2 || cmp.B[0].Parts[0].Text != "two, edited" {
+		t.Errorf("unexpected comparison %+v", cmp)
+	}
+
+	// A/B the next turn with another model.
+	fork, err := s.Fork(ctx, grail.WithSessionRequest(grail.Request{Model: "b"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res, err := fork.Send(ctx, grail.InputText("four"))
+	if text, _ := res.Text(); err != nil || text != "b re: four" || len(got.History) != 6 {
+		t.Errorf("expected the fork to continue the whole conversation with model b, got %q and %d messages (%v)", text, len(got.History), err)
+	}
+
+	if _, err := s.Branch(ctx, 3); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected InvalidArgument mid-exchange, got %v", err)
+	}
+	counted := grail.NewSession(grail.NewClient(mp), grail.WithSessionState(&turnCounter{}))
+	if _, err := counted.Fork(ctx); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected InvalidArgument for a state that can't branch, got %v", err)
+	}
+}
+
+func TestSessionBranchStore(t *testing.T) {
+	prov, _ := scripted("ok")
+	store := &grail.MemoryHistoryStore{}
+	ctx := context.Background()
+	s := grail.NewSession(grail.NewClient(prov), grail.WithSessionStore(store, "main"))
+	for _, msg := range []string{"one", "two"} {
+		if _, err := s.Send(ctx, grail.InputText(msg)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if _, err := s.Branch(ctx, 2); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected the branch to need its own conversation, got %v", err)
+	}
+	if _, err := s.Branch(ctx, 2, grail.WithSessionStore(store, "alt")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if msgs, _ := store.Load(ctx, "alt"); len(msgs) != 2 {
+		t.Errorf("expected the branch's conversation to be seeded, got %d messages", len(msgs))
+	}
+	if _, err := s.Branch(ctx, 2, grail.WithSessionStore(store, "alt")); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected InvalidArgument for a conversation in use, got %v", err)
+	}
+}
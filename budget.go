@@ -0,0 +1,264 @@
+package grail
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+//
+// Spending budgets
+//
+
+// WarningBudgetNotRecorded is set on a response whose cost couldn't be added
+// to its Budget because the budget's State failed.
+const WarningBudgetNotRecorded = "budget_not_recorded"
+
+// Budget caps what requests spend per period, in USD. Install its
+// middleware:
+//
+//	budget := &grail.Budget{Limit: 500, Prices: prices, ScopeKey: grail.DefaultTenantKey}
+//	client := grail.NewClient(provider, grail.WithMiddleware(budget.Middleware()))
+//
+// Once a scope has spent its limit in the current period, its requests fail
+// with BudgetExceeded until the next period. Spend is counted as responses
+// arrive, so requests already running when the limit is reached can take it
+// over by what they cost. Responses from models missing from Prices cost
+// nothing.
+//
+// Spend is kept per process unless State is shared, such as a
+// stores/redis.State every replica of a service uses.
+type Budget struct {
+	Limit  float64 // USD per period
+	Prices PriceTable
+	// Period returns the budget period containing t (default the calendar
+	// month, in UTC).
+	Period func(t time.Time) Period
+	// ScopeKey is the metadata key whose values get budgets of their own,
+	// such as DefaultTenantKey. Without one, all requests share one budget.
+	ScopeKey string
+	// Name tells apart budgets kept in the same State (default "default").
+	Name string
+	// State keeps what's been spent (default a MemoryState). If it fails,
+	// requests fail with its error.
+	State SharedState
+
+	once  sync.Once
+	state SharedState
+}
+
+// Middleware returns the middleware that enforces the budget and counts
+// spend.
+func (b *Budget) Middleware() Middleware {
+	return func(next GenerateFunc) GenerateFunc {
+		return func(ctx context.Context, req Request) (Response, error) {
+			scope := ""
+			if b.ScopeKey != "" {
+				scope = req.Metadata[b.ScopeKey]
+			}
+			key, ttl := b.key(scope)
+			spent, err := b.store().Incr(ctx, key, 0, ttl)
+			if err != nil {
+				return Response{}, err
+			}
+			if spent >= b.Limit {
+				msg := fmt.Sprintf("budget of $%.2f spent ($%.2f)", b.Limit, spent)
+				if scope != "" {
+					msg = fmt.Sprintf("%s budget of $%.2f spent ($%.2f)", scope, b.Limit, spent)
+				}
+				return Response{}, NewGrailError(BudgetExceeded, msg).WithDetail("budget", b.name()).WithDetail("scope", scope)
+			}
+
+			res, err := next(ctx, req)
+			if err != nil {
+				return res, err
+			}
+			model := req.Model
+			if len(res.Provider.Models) > 0 {
+				model = res.Provider.Models[0].Name
+			}
+			if p, ok := b.Prices[model]; ok {
+				if cost := p.Cost(res.Usage); cost > 0 {
+					if _, err := b.store().Incr(context.WithoutCancel(ctx), key, cost, ttl); err != nil {
+						res.Warnings = append(res.Warnings, Warning{Code: WarningBudgetNotRecorded, Message: err.Error()})
+					}
+				}
+			}
+			return res, nil
+		}
+	}
+}
+
+// Spent returns what scope has spent in the current period. Without a
+// ScopeKey, the scope is "".
+func (b *Budget) Spent(ctx context.Context, scope string) (float64, error) {
+	key, ttl := b.key(scope)
+	return b.store().Incr(ctx, key, 0, ttl)
+}
+
+func (b *Budget) store() SharedState {
+	b.once.Do(func() {
+		b.state = b.State
+		if b.state == nil {
+			b.state = NewMemoryState()
+		}
+	})
+	return b.state
+}
+
+func (b *Budget) name() string {
+	if b.Name != "" {
+		return b.Name
+	}
+	return "default"
+}
+
+// key returns the state key of scope's spend in the current period, and how
+// long it must be kept.
+func (b *Budget) key(scope string) (string, time.Duration) {
+	now := time.Now().UTC()
+	period := Month(now)
+	if b.Period != nil {
+		period = b.Period(now)
+	}
+	var ttl time.Duration
+	if !period.End.IsZero() {
+		// Kept a day past the period, for reporting.
+		ttl = period.End.Sub(now) + 24*time.Hour
+	}
+	return fmt.Sprintf("grail:budget:%s:%s:%d", b.name(), scope, period.Start.Unix()), ttl
+}
+
+// WithBudget caps what the client spends, in USD. A request whose estimated
+// cost is over maxPerRequest, or would take what the client has spent over
+// maxPerClient, fails with BudgetExceeded before it's sent, unless dropping
+// inputs marked WithDroppable brings it under; the error's "budget" detail
+// is "request" or "client". Zero leaves a limit off.
+//
+// Requests are estimated from their input tokens, counted as the Scheduler
+// counts them, at the price of the model they'll use (see WithPrices), since
+// their output isn't known until it's generated. A request's estimate is
+// held against maxPerClient while it runs, so concurrent requests can't all
+// spend the same remainder, and replaced with its response's Cost when it
+// arrives; the client can go over maxPerClient by what its last requests
+// cost over their estimates. Responses count when the call fails after the
+// provider answered, such as when constraints or post-processing reject
+// them. Requests to models without a price are only refused once
+// maxPerClient is spent. Child clients created with With share the client's
+// spend. For budgets shared between replicas or kept per tenant, use Budget.
+func WithBudget(maxPerRequest, maxPerClient float64) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.budget = &clientBudget{perRequest: maxPerRequest, perClient: maxPerClient}
+	})
+}
+
+// clientBudget is the limits and spend of WithBudget.
+type clientBudget struct {
+	perRequest float64
+	perClient  float64
+
+	mu    sync.Mutex
+	spent float64 // including the estimates of running requests
+}
+
+// checkBudget fails if req's estimated cost is over the client's budget,
+// after dropping what droppable inputs it takes to fit, and otherwise holds
+// the estimate against the client's budget until the call settles it.
+func (c *client) checkBudget(req Request) (Request, []Warning, *budgetHold, error) {
+	b := c.opts.budget
+	// Requests that leave the model to the provider are priced at the one
+	// it uses by default, and usage is counted against the first model, as
+	// UsageTracker does.
+	p, ok := c.priceOf(strings.Split(c.describeModels(req), ",")[0])
+	if !ok {
+		hold, err := b.reserve(0)
+		return req, nil, hold, err
+	}
+	var warnings []Warning
+	if err := c.overBudget(req, p); err != nil {
+		fits := func(r Request) bool { return c.overBudget(r, p) == nil }
+		trimmed, w, ok := trimInputs(req, "request's estimated cost is over budget", fits)
+		if !ok {
+			return req, nil, nil, err
+		}
+		req, warnings = trimmed, w
+	}
+	hold, err := b.reserve(p.Cost(Usage{InputTokens: estimateTokens(req)}))
+	return req, warnings, hold, err
+}
+
+// overBudget returns the error for req if its estimated cost at price p is
+// over the client's budget.
+func (c *client) overBudget(req Request, p Price) error {
+	b := c.opts.budget
+	estimate := p.Cost(Usage{InputTokens: estimateTokens(req)})
+	if b.perRequest > 0 && estimate > b.perRequest {
+		return NewGrailError(BudgetExceeded, fmt.Sprintf("request's estimated cost of $%.4f is over its budget of $%.2f", estimate, b.perRequest)).
+			WithDetail("budget", "request")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.overClient(estimate)
+}
+
+// overClient returns the error for a request estimated to cost estimate if
+// it would take the client over its budget. b.mu must be held.
+func (b *clientBudget) overClient(estimate float64) error {
+	if b.perClient > 0 && b.spent+estimate > b.perClient {
+		return NewGrailError(BudgetExceeded, fmt.Sprintf("client budget of $%.2f spent ($%.2f, and $%.4f estimated for the request)", b.perClient, b.spent, estimate)).
+			WithDetail("budget", "client")
+	}
+	return nil
+}
+
+// reserve holds estimate against the client's budget, unless it would take
+// the client over.
+func (b *clientBudget) reserve(estimate float64) (*budgetHold, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.overClient(estimate); err != nil {
+		return nil, err
+	}
+	b.spent += estimate
+	return &budgetHold{b: b, estimate: estimate}, nil
+}
+
+// budgetHold is a running request's estimate, counted as spent until the
+// request settles it.
+type budgetHold struct {
+	b        *clientBudget
+	estimate float64
+	settled  bool
+}
+
+// settle replaces the estimate with what the request cost: its response's
+// cost if the response has usage and a price, nothing if the call failed
+// without usage, and otherwise the estimate.
+func (h *budgetHold) settle(cost *Cost, u Usage, err error) {
+	spent := h.estimate
+	switch {
+	case cost != nil && u != (Usage{}):
+		spent = cost.Total
+	case err != nil:
+		spent = 0
+	}
+	h.add(spent)
+}
+
+// release uncounts the estimate of a request that didn't reach the
+// provider. It does nothing once the request is settled.
+func (h *budgetHold) release() {
+	h.add(0)
+}
+
+func (h *budgetHold) add(cost float64) {
+	if h.settled {
+		return
+	}
+	h.settled = true
+	h.b.mu.Lock()
+	defer h.b.mu.Unlock()
+	h.b.spent += cost - h.estimate
+}
@@ -0,0 +1,222 @@
+package grail_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestBudget(t *testing.T) {
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{
+				Outputs:  []grail.OutputPart{grail.NewTextOutputPart("ok")},
+				Usage:    grail.Usage{InputTokens: 500_000, OutputTokens: 100_000, TotalTokens: 600_000},
+				Provider: grail.ProviderInfo{Models: []grail.ModelUse{{Name: req.Model}}},
+			}, nil
+		},
+	}
+	budget := &grail.Budget{
+		Limit:    2,
+		Prices:   grail.PriceTable{"priced": {InputPerMTok: 2, OutputPerMTok: 5}}, // $1.50 a request
+		ScopeKey: grail.DefaultTenantKey,
+	}
+	client := grail.NewClient(prov, grail.WithMiddleware(budget.Middleware()))
+	ctx := context.Background()
+	generate := func(tenant, model string) error {
+		_, err := client.Generate(ctx, grail.Request{
+			Inputs:   []grail.Input{grail.InputText("hi")},
+			Output:   grail.OutputText(),
+			Model:    model,
+			Metadata: map[string]string{grail.DefaultTenantKey: tenant},
+		})
+		return err
+	}
+
+	for i := range 2 {
+		if err := generate("acme", "priced"); err != nil {
+			t.Fatalf("request %d within budget: %v", i, err)
+		}
+	}
+	if spent, err := budget.Spent(ctx, "acme"); err != nil || spent != 3 {
+		t.Errorf("expected $3 spent, got %v (%v)", spent, err)
+	}
+	err := generate("acme", "priced")
+	if grail.GetErrorCode(err) != grail.BudgetExceeded || grail.IsRetryable(err) {
+		t.Fatalf("expected a non-retryable budget error, got %v", err)
+	}
+	if ge, ok := err.(grail.GrailError); !ok || ge.Details()["scope"] != "acme" {
+		t.Errorf("expected the scope in the error details, got %v", err)
+	}
+
+	// Other tenants have budgets of their own, and unpriced models are free.
+	if err := generate("globex", "priced"); err != nil {
+		t.Errorf("expected another tenant's request to go through, got %v", err)
+	}
+	for range 3 {
+		if err := generate("initech", "unpriced"); err != nil {
+			t.Fatalf("expected unpriced requests to cost nothing, got %v", err)
+		}
+	}
+}
+
+func TestWithBudget(t *testing.T) {
+	var calls int
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			calls++
+			return grail.Response{
+				Outputs:  []grail.OutputPart{grail.NewTextOutputPart("ok")},
+				Usage:    grail.Usage{InputTokens: 1000, OutputTokens: 100_000, TotalTokens: 101_000},
+				Provider: grail.ProviderInfo{Models: []grail.ModelUse{{Name: req.Model}}},
+			}, nil
+		},
+	}
+	// $1 per million input tokens (so the estimate is tiny) and $10 per
+	// million output tokens: $1.001 a request.
+	client := grail.NewClient(prov,
+		grail.WithPrices(grail.PriceTable{"priced": {InputPerMTok: 1, OutputPerMTok: 10}}),
+		grail.WithBudget(0.01, 2.5),
+	)
+	ctx := context.Background()
+	generate := func(c grail.Client, model, prompt string) error {
+		_, err := c.Generate(ctx, grail.Request{Inputs: []grail.Input{grail.InputText(prompt)}, Output: grail.OutputText(), Model: model})
+		return err
+	}
+
+	// About 40K estimated tokens cost $0.04, over the per-request limit.
+	err := generate(client, "priced", strings.Repeat("long prompt ", 14_000))
+	if ge, ok := err.(grail.GrailError); !ok || ge.Code() != grail.BudgetExceeded || ge.Details()["budget"] != "request" {
+		t.Fatalf("expected the request budget to be exceeded, got %v", err)
+	}
+	if calls != 0 {
+		t.Fatal("expected the request not to be sent")
+	}
+
+	// Requests are let through while the client has spent under $2.50, so
+	// the third takes it over; a child client shares the spend. Requests to
+	// unpriced models are let through until then.
+	if err := generate(client, "unpriced", "hi"); err != nil {
+		t.Errorf("expected unpriced models not to be limited, got %v", err)
+	}
+	if err := generate(client, "priced", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	child := client.With()
+	if err := generate(child, "priced", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(child, "priced", "hi"); err != nil {
+		t.Fatalf("expected spend under the limit to be allowed, got %v", err)
+	}
+	err = generate(client, "priced", "hi")
+	if ge, ok := err.(grail.GrailError); !ok || ge.Code() != grail.BudgetExceeded || ge.Details()["budget"] != "client" {
+		t.Errorf("expected the client budget to be exceeded, got %v", err)
+	}
+	if err := generate(client, "unpriced", "hi"); grail.GetErrorCode(err) != grail.BudgetExceeded {
+		t.Errorf("expected unpriced models to be refused once the budget is spent, got %v", err)
+	}
+}
+
+// defaultModelProvider describes the model it uses for requests without one.
+type defaultModelProvider struct {
+	mock.Provider
+}
+
+func (p *defaultModelProvider) DescribeModels(req grail.Request) string {
+	if req.Model != "" {
+		return req.Model
+	}
+	return "priced"
+}
+
+func TestWithBudget_Spend(t *testing.T) {
+	ctx := context.Background()
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()}
+	usage := grail.Usage{InputTokens: 1000, OutputTokens: 100_000, TotalTokens: 101_000}
+	prices := grail.WithPrices(grail.PriceTable{"priced": {InputPerMTok: 1, OutputPerMTok: 10}})
+
+	// Requests leaving the model to the provider are held to the price of
+	// its default.
+	var calls atomic.Int32
+	prov := &defaultModelProvider{mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		calls.Add(1)
+		return grail.Response{
+			Outputs:  []grail.OutputPart{grail.NewTextOutputPart("ok")},
+			Usage:    usage,
+			Provider: grail.ProviderInfo{Models: []grail.ModelUse{{Name: "priced"}}},
+		}, nil
+	}}}
+	client := grail.NewClient(prov, prices, grail.WithBudget(0, 1.5))
+	for range 2 {
+		client.Generate(ctx, req)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("expected 2 calls, got %d", n)
+	}
+	if _, err := client.Generate(ctx, req); grail.GetErrorCode(err) != grail.BudgetExceeded {
+		t.Fatalf("expected the default model's spend to be limited, got %v", err)
+	}
+
+	// Running requests hold their estimate, so concurrent requests can't all
+	// spend the same remainder: at $1 per million input tokens, 10 requests
+	// of about 100K tokens each fit a $0.45 budget 4 at a time.
+	calls.Store(0)
+	release := make(chan struct{})
+	slow := &mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		calls.Add(1)
+		<-release
+		return grail.Response{}, grail.NewGrailError(grail.Unavailable, "overloaded")
+	}}
+	client = grail.NewClient(slow, prices, grail.WithBudget(0, 0.45))
+	big := grail.Request{Inputs: []grail.Input{grail.InputText(strings.Repeat("long prompt ", 33_000))}, Output: grail.OutputText(), Model: "priced"}
+	var wg sync.WaitGroup
+	var refused atomic.Int32
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Generate(ctx, big); grail.GetErrorCode(err) == grail.BudgetExceeded {
+				refused.Add(1)
+			}
+		}()
+	}
+	for calls.Load()+refused.Load() < 10 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	if n := calls.Load(); n != 4 {
+		t.Errorf("expected 4 requests to be let through, got %d", n)
+	}
+	// Failed calls without usage cost nothing.
+	if _, err := client.Generate(ctx, big); grail.GetErrorCode(err) == grail.BudgetExceeded {
+		t.Errorf("expected failed calls' holds to be released, got %v", err)
+	}
+
+	// A call that fails after the provider answered is counted.
+	rejecting := &mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("ok")}, Usage: usage}, nil
+	}}
+	client = grail.NewClient(rejecting, prices, grail.WithBudget(0, 1.5),
+		grail.WithPostProcessors(grail.PostProcessor{Text: func(ctx context.Context, text string) (string, error) {
+			return "", errors.New("rejected")
+		}}),
+	)
+	req.Model = "priced"
+	for range 2 {
+		if _, err := client.Generate(ctx, req); err == nil || grail.GetErrorCode(err) == grail.BudgetExceeded {
+			t.Fatalf("expected the post-processor to fail the call, got %v", err)
+		}
+	}
+	if _, err := client.Generate(ctx, req); grail.GetErrorCode(err) != grail.BudgetExceeded {
+		t.Errorf("expected failed calls with usage to be counted, got %v", err)
+	}
+}
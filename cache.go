@@ -0,0 +1,41 @@
+package grail
+
+//
+// Prompt cache breakpoints
+//
+
+// InputOpt is an option accepted by both InputText and the file inputs.
+type InputOpt interface {
+	FileOpt
+	TextOpt
+}
+
+type cacheBreakpointOpt struct{}
+
+func (cacheBreakpointOpt) applyFileOpt(fo *fileOpt) { fo.cacheBreakpoint = true }
+func (cacheBreakpointOpt) applyTextOpt(to *textOpt) { to.cacheBreakpoint = true }
+
+// WithCacheBreakpoint marks the end of a static prompt prefix (a system
+// prompt, reference documents) that providers with explicit prompt caching
+// should cache, so later requests sharing the prefix reuse it. Everything up
+// to and including the marked input is the prefix. Providers that cache
+// prefixes automatically (OpenAI, Gemini) ignore the marker; it's honored by
+// providers that need explicit cache-control markers.
+func WithCacheBreakpoint() InputOpt {
+	return cacheBreakpointOpt{}
+}
+
+// IsCacheBreakpoint reports whether input was marked with WithCacheBreakpoint.
+func IsCacheBreakpoint(input Input) bool {
+	switch v := input.(type) {
+	case textInput:
+		return v.CacheBreakpoint
+	case fileInput:
+		return v.CacheBreakpoint
+	case fileReaderInput:
+		return v.CacheBreakpoint
+	case uploadedFileInput:
+		return v.CacheBreakpoint
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+package grail_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+)
+
+func TestCacheBreakpoint(t *testing.T) {
+	inputs := []grail.Input{
+		grail.InputText("system prompt", grail.WithCacheBreakpoint()),
+		grail.InputPDF([]byte("%PDF"), grail.WithFileName("ref.pdf"), grail.WithCacheBreakpoint()),
+		grail.InputFileReader(strings.NewReader("notes"), 5, "text/plain", grail.WithCacheBreakpoint()),
+		grail.InputText("question"),
+	}
+	for i, want := range []bool{true, true, true, false} {
+		if got := grail.IsCacheBreakpoint(inputs[i]); got != want {
+			t.Errorf("input %d: IsCacheBreakpoint = %v, want %v", i, got, want)
+		}
+	}
+	if _, _, name, _ := grail.AsFileInput(inputs[1]); name != "ref.pdf" {
+		t.Fatalf("expected other options to still apply, got name %q", name)
+	}
+}
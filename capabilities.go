@@ -0,0 +1,144 @@
+package grail
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+//
+// Provider capabilities
+//
+
+// ProviderCapabilities describes what a provider supports independent of any
+// particular model, so clients and routers can make dispatch decisions without
+// type-asserting optional interfaces. Model-level capabilities are described
+// by ModelCapabilities.
+type ProviderCapabilities struct {
+	TextOutput  bool
+	ImageOutput bool
+	VideoOutput bool
+	JSONOutput  bool
+	// NativeJSON reports that JSON output is constrained by the provider
+	// (a JSON mode or response schema) rather than only validated afterwards.
+	NativeJSON bool
+
+	// InputMIMETypes lists the file input types the provider accepts, with
+	// "type/*" wildcards. Text inputs are always accepted; an empty list means
+	// text only.
+	InputMIMETypes []string
+	// MaxFileSize is the largest single file the provider accepts inline, in
+	// bytes. Zero means grail's MaxFileSize.
+	MaxFileSize int64
+
+	Streaming    bool // incremental output delivery
+	Tools        bool // tool / function calling
+	History      bool // earlier turns sent as messages (Request.History)
+	Logprobs     bool // token log probabilities for ConfidenceLogprobs
+	ModelListing bool // implements ModelLister
+}
+
+// CapabilityReporter is an optional interface for providers to describe their
+// capabilities. Clients enforce reported capabilities before dispatch.
+type CapabilityReporter interface {
+	Capabilities() ProviderCapabilities
+}
+
+// AcceptsMIME reports whether a file input of the given MIME type is accepted.
+func (pc ProviderCapabilities) AcceptsMIME(mime string) bool {
+	mime = strings.ToLower(mime)
+	for _, pattern := range pc.InputMIMETypes {
+		pattern = strings.ToLower(pattern)
+		if pattern == "*/*" || pattern == mime {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(mime, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsOutput reports whether the provider can produce out.
+func (pc ProviderCapabilities) SupportsOutput(out Output) bool {
+	switch out.(type) {
+	case textOutput:
+		return pc.TextOutput
+	case imageOutput:
+		return pc.ImageOutput
+	case videoOutput:
+		return pc.VideoOutput
+	case jsonOutput:
+		return pc.JSONOutput
+	}
+	return false
+}
+
+// Capabilities returns the provider's reported capabilities. ok is false when
+// the provider doesn't implement CapabilityReporter.
+func (c *client) Capabilities() (ProviderCapabilities, bool) {
+	cr, ok := c.provider.(CapabilityReporter)
+	if !ok {
+		return ProviderCapabilities{}, false
+	}
+	return cr.Capabilities(), true
+}
+
+// checkCapabilities rejects requests the provider has declared it can't
+// serve, dropping droppable inputs it can't take.
+func (c *client) checkCapabilities(req Request) (Request, []Warning, error) {
+	caps, ok := c.Capabilities()
+	if !ok {
+		return req, nil, nil
+	}
+	name := c.provider.Name()
+	if !caps.SupportsOutput(req.Output) {
+		return req, nil, NewGrailError(Unsupported, fmt.Sprintf("provider %s does not support %s output", name, getOutputType(req.Output))).WithProviderName(name)
+	}
+	if !caps.Tools && usesTools(req) {
+		return req, nil, NewGrailError(Unsupported, fmt.Sprintf("provider %s does not support tool calling", name)).WithProviderName(name)
+	}
+	check := func(in Input) (ErrorCode, string) { return checkInputCaps(caps, name, in) }
+	inputs, warnings, err := filterInputs(req.Inputs, "", name, check)
+	if err != nil {
+		return req, nil, err
+	}
+	req.Inputs = inputs
+	for i, m := range req.History {
+		inputs, dropped, err := filterInputs(m.Inputs, fmt.Sprintf("history message %d: ", i), name, check)
+		if err != nil {
+			return req, nil, err
+		}
+		if len(dropped) > 0 {
+			req.History = slices.Clone(req.History)
+			req.History[i].Inputs = inputs
+			warnings = append(warnings, dropped...)
+		}
+	}
+	return req, warnings, nil
+}
+
+// checkInputCaps checks a file input against the provider's accepted types
+// and sizes, returning why it can't be sent.
+func checkInputCaps(caps ProviderCapabilities, name string, in Input) (ErrorCode, string) {
+	var mime string
+	var size int64
+	switch v := in.(type) {
+	case fileInput:
+		mime, size = v.MIME, int64(len(v.Data))
+		if mime == "" {
+			mime = sniffImageMIME(v.Data)
+		}
+	case fileReaderInput:
+		mime, size = v.MIME, v.Size
+	default:
+		return "", ""
+	}
+	if !caps.AcceptsMIME(mime) {
+		return Unsupported, fmt.Sprintf("provider %s does not accept %s files", name, mime)
+	}
+	if caps.MaxFileSize > 0 && size > caps.MaxFileSize {
+		return InvalidArgument, fmt.Sprintf("file size %d exceeds provider %s maximum of %d bytes", size, name, caps.MaxFileSize)
+	}
+	return "", ""
+}
@@ -0,0 +1,63 @@
+package grail_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+type imageOnlyProvider struct{ *mock.Provider }
+
+func (imageOnlyProvider) Capabilities() grail.ProviderCapabilities {
+	return grail.ProviderCapabilities{ImageOutput: true, InputMIMETypes: []string{"image/*"}, MaxFileSize: 16}
+}
+
+func TestProviderCapabilities(t *testing.T) {
+	prov := imageOnlyProvider{&mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewImageOutputPart([]byte("img"), "image/png", "")}}, nil
+		},
+	}}
+	client := grail.NewClient(prov)
+
+	caps, ok := client.Capabilities()
+	if !ok || !caps.ImageOutput || caps.TextOutput {
+		t.Fatalf("unexpected capabilities: %+v (ok=%v)", caps, ok)
+	}
+	if !caps.AcceptsMIME("image/png") || caps.AcceptsMIME("application/pdf") {
+		t.Fatalf("unexpected MIME matching")
+	}
+
+	png := []byte("\x89PNG\r\n\x1a\n")
+	tests := []struct {
+		name string
+		req  grail.Request
+		code grail.ErrorCode
+	}{
+		{"unsupported output", grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()}, grail.Unsupported},
+		{"unsupported video", grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputVideo(grail.VideoSpec{})}, grail.Unsupported},
+		{"unsupported input", grail.Request{Inputs: []grail.Input{grail.InputPDF([]byte("%PDF"))}, Output: grail.OutputImage(grail.ImageSpec{})}, grail.Unsupported},
+		{"file too large", grail.Request{Inputs: []grail.Input{grail.InputImage(append(png, make([]byte, 16)...))}, Output: grail.OutputImage(grail.ImageSpec{})}, grail.InvalidArgument},
+		{"supported", grail.Request{Inputs: []grail.Input{grail.InputText("hi"), grail.InputImage(png)}, Output: grail.OutputImage(grail.ImageSpec{})}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.Generate(context.Background(), tt.req)
+			if tt.code == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if grail.GetErrorCode(err) != tt.code {
+				t.Fatalf("expected %s, got %v", tt.code, err)
+			}
+		})
+	}
+
+	if _, ok := grail.NewClient(&mock.Provider{}).Capabilities(); ok {
+		t.Fatalf("expected no capabilities for a provider that doesn't report them")
+	}
+}
@@ -0,0 +1,104 @@
+package grail
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+//
+// Fault injection
+//
+
+// Chaos configures fault injection for testing how an application handles
+// provider failures. Rates are probabilities from 0 to 1, rolled
+// independently for every call.
+type Chaos struct {
+	LatencyRate float64       // delay the call
+	MaxLatency  time.Duration // delays are uniform in [0, MaxLatency] (default 2s)
+
+	RateLimitRate float64 // fail with a retryable RateLimited error
+	TruncateRate  float64 // cut text outputs off partway through
+	MalformedRate float64 // corrupt JSON outputs so they no longer parse
+
+	// Seed makes the injected faults reproducible; zero picks a random seed.
+	Seed uint64
+}
+
+// ChaosMiddleware returns middleware (see WithMiddleware) that injects the
+// faults described by cfg. Injected errors and faults are meant for tests and
+// staging; don't install it in production.
+func ChaosMiddleware(cfg Chaos) Middleware {
+	if cfg.MaxLatency <= 0 {
+		cfg.MaxLatency = 2 * time.Second
+	}
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	var mu sync.Mutex
+	rng := rand.New(rand.NewPCG(seed, seed))
+	roll := func(rate float64) bool {
+		if rate <= 0 {
+			return false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return rng.Float64() < rate
+	}
+	fraction := func() float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		return rng.Float64()
+	}
+
+	return func(next GenerateFunc) GenerateFunc {
+		return func(ctx context.Context, req Request) (Response, error) {
+			if roll(cfg.LatencyRate) {
+				t := time.NewTimer(time.Duration(fraction() * float64(cfg.MaxLatency)))
+				select {
+				case <-ctx.Done():
+					t.Stop()
+					return Response{}, NewGrailError(Timeout, "chaos: injected latency").WithCause(ctx.Err())
+				case <-t.C:
+				}
+			}
+			if roll(cfg.RateLimitRate) {
+				return Response{}, NewGrailError(RateLimited, "chaos: injected rate limit").WithRetryable(true)
+			}
+
+			res, err := next(ctx, req)
+			if err != nil {
+				return res, err
+			}
+			// Copy so the faults don't reach the provider's own response.
+			res.Outputs = append([]OutputPart(nil), res.Outputs...)
+			for i, part := range res.Outputs {
+				switch p := part.(type) {
+				case textOutputPart:
+					if roll(cfg.TruncateRate) {
+						r := []rune(p.Text)
+						res.Outputs[i] = textOutputPart{Text: string(r[:int(fraction()*float64(len(r)))])}
+					}
+				case jsonOutputPart:
+					if roll(cfg.MalformedRate) {
+						res.Outputs[i] = jsonOutputPart{JSON: malformJSON(p.JSON, fraction())}
+					}
+				}
+			}
+			return res, nil
+		}
+	}
+}
+
+// malformJSON truncates data at f of its length, as a cut-off stream would,
+// making sure the result no longer parses.
+func malformJSON(data []byte, f float64) []byte {
+	out := append([]byte(nil), data[:int(f*float64(len(data)))]...)
+	if json.Valid(out) {
+		out = append(out, ',')
+	}
+	return out
+}
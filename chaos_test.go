@@ -0,0 +1,86 @@
+package grail_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestChaosMiddleware(t *testing.T) {
+	calls := 0
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			calls++
+			if _, _, ok := grail.GetJSONOutput(req.Output); ok {
+				return grail.Response{Outputs: []grail.OutputPart{grail.NewJSONOutputPart([]byte(`{"answer":42}`))}}, nil
+			}
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("a complete sentence")}}, nil
+		},
+	}
+	newClient := func(cfg grail.Chaos) grail.Client {
+		cfg.Seed = 1
+		return grail.NewClient(prov, grail.WithMiddleware(grail.ChaosMiddleware(cfg)))
+	}
+	text := grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()}
+	jsonReq := grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputJSON(nil)}
+
+	_, err := newClient(grail.Chaos{RateLimitRate: 1}).Generate(context.Background(), text)
+	if grail.GetErrorCode(err) != grail.RateLimited || !grail.IsRetryable(err) || calls != 0 {
+		t.Fatalf("expected retryable rate limit before the provider call, got %v after %d calls", err, calls)
+	}
+
+	res, err := newClient(grail.Chaos{TruncateRate: 1}).Generate(context.Background(), text)
+	if got, _ := res.Text(); err != nil || len(got) >= len("a complete sentence") {
+		t.Fatalf("expected truncated text, got %q (%v)", got, err)
+	}
+
+	res, err = newClient(grail.Chaos{MalformedRate: 1}).Generate(context.Background(), jsonReq)
+	var v any
+	if err != nil || res.DecodeJSON(&v) == nil {
+		t.Fatalf("expected malformed JSON, got %v (%v)", v, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = newClient(grail.Chaos{LatencyRate: 1, MaxLatency: time.Hour}).Generate(ctx, text)
+	if grail.GetErrorCode(err) != grail.Timeout {
+		t.Fatalf("expected injected latency to run into the deadline, got %v", err)
+	}
+
+	res, err = newClient(grail.Chaos{}).Generate(context.Background(), jsonReq)
+	if err != nil || res.DecodeJSON(&v) != nil {
+		t.Fatalf("expected no faults at zero rates, got %v", err)
+	}
+	if b, _ := json.Marshal(v); string(b) != `{"answer":42}` {
+		t.Fatalf("unexpected JSON %s", b)
+	}
+}
+
+func TestWithMiddleware_Order(t *testing.T) {
+	var order []string
+	trace := func(name string) grail.Middleware {
+		return func(next grail.GenerateFunc) grail.GenerateFunc {
+			return func(ctx context.Context, req grail.Request) (grail.Response, error) {
+				order = append(order, name)
+				return next(ctx, req)
+			}
+		}
+	}
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			order = append(order, "provider")
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("ok")}}, nil
+		},
+	}
+	client := grail.NewClient(prov, grail.WithMiddleware(trace("outer")), grail.WithMiddleware(trace("inner")))
+	if _, err := client.Generate(context.Background(), grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()}); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 3 || order[0] != "outer" || order[1] != "inner" || order[2] != "provider" {
+		t.Fatalf("unexpected middleware order %v", order)
+	}
+}
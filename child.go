@@ -0,0 +1,96 @@
+package grail
+
+//
+// Scoped child clients
+//
+
+// With returns a child client for a subsystem. The child starts from this
+// client's options, including its default request, and applies opts on top.
+// The provider is shared rather than reconfigured: a logger set on the child
+// applies to client-level logs only, and provider-level logs keep going to the
+// logger the provider was created with.
+func (c *client) With(opts ...ClientOption) Client {
+	co := c.opts
+	if co.defaults != nil {
+		// Copy so option helpers that edit the defaults don't affect the parent.
+		def := *co.defaults
+		def.Metadata = copyMetadata(def.Metadata)
+		co.defaults = &def
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt.applyClientOpt(&co)
+		}
+	}
+	child := newClient(co)
+	child.provider = c.provider
+	child.transport = c.transport
+	child.countAttempts = c.countAttempts
+	child.egressGuarded = c.egressGuarded
+	child.tlsEnforced = c.tlsEnforced
+	child.budgetAttempts = c.budgetAttempts
+	child.life = c.life
+	c.life.addHooks(co.closeHooks[len(c.opts.closeHooks):])
+	child.stats = c.stats
+	child.modelCheck = c.modelCheck
+	child.events = c.events
+	return child
+}
+
+// WithDefaultModel sets the model used when a request sets neither Model nor
+// Tier, keeping any other defaults.
+func WithDefaultModel(model string) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		def := co.defaultRequest()
+		def.Model, def.Tier = model, ""
+	})
+}
+
+// WithDefaultTier sets the tier used when a request sets neither Model nor
+// Tier, keeping any other defaults.
+func WithDefaultTier(tier ModelTier) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		def := co.defaultRequest()
+		def.Model, def.Tier = "", tier
+	})
+}
+
+// WithDefaultPriority sets the priority of requests that don't set one,
+// keeping any other defaults. Use it for clients dedicated to batch work.
+func WithDefaultPriority(p Priority) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.defaultRequest().Priority = p
+	})
+}
+
+// WithDefaultMetadata adds metadata to every request, keeping any other
+// defaults. Request metadata wins over these values.
+func WithDefaultMetadata(md map[string]string) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		def := co.defaultRequest()
+		if def.Metadata == nil {
+			def.Metadata = make(map[string]string, len(md))
+		}
+		for k, v := range md {
+			def.Metadata[k] = v
+		}
+	})
+}
+
+func (co *clientOpt) defaultRequest() *Request {
+	if co.defaults == nil {
+		co.defaults = &Request{}
+	}
+	return co.defaults
+}
+
+func copyMetadata(md map[string]string) map[string]string {
+	if md == nil {
+		return nil
+	}
+	out := make(map[string]string, len(md))
+	for k, v := range md {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,39 @@
+package grail_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestClientWith(t *testing.T) {
+	var got grail.Request
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			got = req
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("ok")}}, nil
+		},
+	}
+	parent := grail.NewClient(prov, grail.WithDefaultRequest(grail.Request{
+		Output:   grail.OutputText(),
+		Metadata: map[string]string{"app": "demo"},
+	}))
+	child := parent.With(grail.WithDefaultTier(grail.ModelTierFast), grail.WithDefaultMetadata(map[string]string{"subsystem": "search"}))
+
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("hi")}}
+	if _, err := child.Generate(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Tier != grail.ModelTierFast || got.Metadata["app"] != "demo" || got.Metadata["subsystem"] != "search" {
+		t.Fatalf("child defaults not applied: tier=%q metadata=%v", got.Tier, got.Metadata)
+	}
+
+	if _, err := parent.Generate(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Tier != "" || got.Metadata["subsystem"] != "" {
+		t.Fatalf("child options leaked into parent: tier=%q metadata=%v", got.Tier, got.Metadata)
+	}
+}
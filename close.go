@@ -0,0 +1,123 @@
+package grail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+//
+// Graceful shutdown
+//
+
+// lifecycle tracks in-flight calls for a client and the children sharing its
+// provider, so closing any of them drains them all.
+type lifecycle struct {
+	mu       sync.Mutex
+	closed   bool
+	inflight int
+	drained  chan struct{} // closed once closed and inflight reaches zero
+	release  sync.Once
+	err      error
+	hooks    []func(ctx context.Context) error // of every client in the family
+}
+
+// addHooks registers the close hooks a client added to its family's.
+func (l *lifecycle) addHooks(hooks []func(ctx context.Context) error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, hooks...)
+}
+
+// enter registers an in-flight call, failing once the client is closed.
+func (l *lifecycle) enter() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return NewGrailError(Unavailable, "client is closed")
+	}
+	l.inflight++
+	return nil
+}
+
+func (l *lifecycle) leave() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inflight--
+	if l.closed && l.inflight == 0 {
+		close(l.drained)
+	}
+}
+
+// WithCloseHook runs fn when the client is closed, after in-flight calls have
+// drained (or the close deadline passed). Use it to flush metrics, audit logs,
+// or other buffers fed by the client. Hooks run in the order they were added.
+// Closing any client in a family runs the hooks of all of them: those of
+// child clients from With as well as their parent's.
+func WithCloseHook(fn func(ctx context.Context) error) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.closeHooks = append(co.closeHooks[:len(co.closeHooks):len(co.closeHooks)], fn)
+	})
+}
+
+// Close stops the client from accepting new calls, waits for in-flight calls
+// (including requests queued by a Scheduler) until they finish or ctx is done,
+// then runs close hooks, closes the provider if it implements io.Closer, and
+// closes idle HTTP connections. Child clients from With share the provider, so
+// closing any client in the family closes them all.
+//
+// Close returns a Timeout error if ctx ended before calls drained; resources
+// are released regardless. Calling Close again waits for the same drain and
+// returns the same release error.
+func (c *client) Close(ctx context.Context) error {
+	l := c.life
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		l.drained = make(chan struct{})
+		if l.inflight == 0 {
+			close(l.drained)
+		}
+	}
+	drained := l.drained
+	l.mu.Unlock()
+
+	var drainErr error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		l.mu.Lock()
+		n := l.inflight
+		l.mu.Unlock()
+		drainErr = NewGrailError(Timeout, fmt.Sprintf("close: %d calls still in flight", n)).WithCause(ctx.Err())
+	}
+
+	l.release.Do(func() {
+		l.mu.Lock()
+		hooks := l.hooks
+		l.mu.Unlock()
+		var errs []error
+		for _, hook := range hooks {
+			if err := hook(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if closer, ok := c.provider.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if c.httpClient != nil {
+			c.httpClient.CloseIdleConnections()
+		}
+		if err := errors.Join(errs...); err != nil {
+			l.err = NewGrailError(Internal, fmt.Sprintf("close: %v", err)).WithCause(err)
+		}
+	})
+	if drainErr != nil {
+		return drainErr
+	}
+	return l.err
+}
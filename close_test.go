@@ -0,0 +1,94 @@
+package grail_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+type closingProvider struct {
+	*mock.Provider
+	closed int
+}
+
+func (p *closingProvider) Close() error {
+	p.closed++
+	return nil
+}
+
+func TestClient_Close(t *testing.T) {
+	started, unblock := make(chan struct{}), make(chan struct{})
+	prov := &closingProvider{Provider: &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			close(started)
+			<-unblock
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("ok")}}, nil
+		},
+	}}
+	hooks := 0
+	client := grail.NewClient(prov, grail.WithCloseHook(func(context.Context) error {
+		hooks++
+		return nil
+	}))
+	child := client.With(grail.WithDefaultModel("m"))
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := child.Generate(context.Background(), req)
+		done <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := client.Close(ctx); grail.GetErrorCode(err) != grail.Timeout {
+		t.Fatalf("expected timeout while a call is in flight, got %v", err)
+	}
+	if _, err := client.Generate(context.Background(), req); grail.GetErrorCode(err) != grail.Unavailable {
+		t.Fatalf("expected closed client to refuse calls, got %v", err)
+	}
+	if _, err := child.Generate(context.Background(), req); grail.GetErrorCode(err) != grail.Unavailable {
+		t.Fatalf("expected child of closed client to refuse calls, got %v", err)
+	}
+
+	close(unblock)
+	if err := <-done; err != nil {
+		t.Fatalf("expected in-flight call to finish, got %v", err)
+	}
+	if err := client.Close(context.Background()); err != nil {
+		t.Fatalf("expected second close to succeed once drained, got %v", err)
+	}
+	if hooks != 1 || prov.closed != 1 {
+		t.Fatalf("expected resources released once, got %d hooks and %d provider closes", hooks, prov.closed)
+	}
+}
+
+func TestClient_CloseHooks_Family(t *testing.T) {
+	var ran []string
+	hook := func(name string) grail.ClientOption {
+		return grail.WithCloseHook(func(context.Context) error {
+			ran = append(ran, name)
+			return nil
+		})
+	}
+	parent := grail.NewClient(&mock.Provider{}, hook("parent"))
+	a := parent.With(hook("a"))
+	parent.With(hook("b"))
+	a.With(hook("a1"))
+
+	// Closing a child runs the hooks of the whole family, each once.
+	if err := a.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := parent.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(ran, ","); got != "parent,a,b,a1" {
+		t.Fatalf("expected every client's hook to run once, got %s", got)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/montanaflynn/grail"
+)
+
+// runChat is an interactive chat REPL built on grail.Session. Replies are
+// printed as they're generated. Earlier turns are replayed to the model as
+// context, so the conversation carries over when switching providers mid-way.
+//
+// Commands:
+//
+//	/model NAME        use a specific model (empty to go back to the provider default)
+//	/tier best|fast    use the provider's best or fast model
+//	/attach PATH       attach a file (image, PDF, text) to the next message
+//	/provider NAME     switch to gemini, openai, or fake, keeping the conversation
+//	/save PATH         save the transcript, attachments included
+//	/reset             start a new conversation
+//	/help, /quit
+//
+// With -session, the conversation is loaded from the file on start and saved
+// after every turn. With -json, each reply is printed as a line of JSON once
+// it's complete, and everything else goes to stderr.
+func runChat(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("chat", flag.ExitOnError)
+	var mf modelFlags
+	mf.register(fs)
+	sessionPath := fs.String("session", "", "transcript file to resume and save the conversation in")
+	jsonOut := fs.Bool("json", false, "print each reply as a line of JSON, and everything else to stderr")
+	if done, err := mf.parse(ctx, fs, args); done || err != nil {
+		return err
+	}
+
+	c := &chat{providerName: mf.provider, sessionPath: *sessionPath, model: mf.model, tier: grail.ModelTier(mf.tier), info: os.Stdout}
+	if *jsonOut {
+		c.json, c.info = true, os.Stderr
+	}
+	transcript := grail.NewTranscript()
+	if c.sessionPath != "" {
+		if f, err := os.Open(c.sessionPath); err == nil {
+			transcript, err = grail.ImportTranscript(f, nil)
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("load session: %w", err)
+			}
+			fmt.Fprintf(c.info, "resumed %d turns from %s\n", len(transcript.Turns), c.sessionPath)
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("load session: %w", err)
+		}
+	}
+	if err := c.connect(ctx, c.providerName, transcript); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.info, "chatting with %s; /help for commands\n", c.providerName)
+	in := bufio.NewScanner(os.Stdin)
+	for {
+		if !c.json {
+			fmt.Print("> ")
+		}
+		if !in.Scan() {
+			if !c.json {
+				fmt.Println()
+			}
+			return in.Err()
+		}
+		line := strings.TrimSpace(in.Text())
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "/"):
+			if quit := c.command(ctx, line); quit {
+				return nil
+			}
+		default:
+			c.send(ctx, line)
+		}
+	}
+}
+
+type chat struct {
+	providerName string
+	sessionPath  string
+	session      *grail.Session
+	model        string
+	tier         grail.ModelTier
+	attachments  []grail.Input
+
+	// With json set, replies are printed as ResponseJSON lines and
+	// everything else goes to info, stderr.
+	json bool
+	info io.Writer
+}
+
+// connect starts a session with the named provider, continuing transcript.
+func (c *chat) connect(ctx context.Context, name string, transcript *grail.Transcript) error {
+	client, err := newClient(ctx, name)
+	if err != nil {
+		return err
+	}
+	c.providerName = name
+	c.session = grail.NewSession(client, grail.WithSessionHistory(), grail.WithSessionTranscript(transcript))
+	return nil
+}
+
+func (c *chat) send(ctx context.Context, text string) {
+	inputs := append([]grail.Input{grail.InputText(text)}, c.attachments...)
+	c.attachments = nil // sent or failed, they don't carry over to the next message
+	req := grail.Request{
+		Inputs: inputs,
+		Output: grail.OutputText(),
+		Model:  c.model,
+		Tier:   c.tier,
+	}
+	if c.json {
+		res, err := c.session.Generate(ctx, req)
+		if err := writeJSON(os.Stdout, c.providerName, res, err, ""); err != nil {
+			fmt.Fprintf(c.info, "error: %v\n", err)
+		}
+		if err != nil {
+			return
+		}
+	} else {
+		streamed := false
+		ctx = grail.ContextWithTextStream(ctx, func(text string) {
+			streamed = true
+			fmt.Print(text)
+		})
+		_, err := c.session.Generate(ctx, req)
+		if streamed {
+			fmt.Println()
+		}
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			return
+		}
+	}
+	if c.sessionPath != "" {
+		if err := saveTranscript(c.session.Transcript(), c.sessionPath); err != nil {
+			fmt.Fprintf(c.info, "save session: %v\n", err)
+		}
+	}
+}
+
+// command runs a slash command and reports whether to quit.
+func (c *chat) command(ctx context.Context, line string) bool {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case "/quit", "/exit":
+		return true
+	case "/help":
+		fmt.Fprintln(c.info, "/model NAME, /tier best|fast, /attach PATH, /provider NAME, /save PATH, /reset, /quit")
+	case "/model":
+		c.model, c.tier = arg, ""
+		fmt.Fprintf(c.info, "model: %s\n", orDefault(arg))
+	case "/tier":
+		if arg != string(grail.ModelTierBest) && arg != string(grail.ModelTierFast) {
+			fmt.Fprintln(c.info, "usage: /tier best|fast")
+			break
+		}
+		c.model, c.tier = "", grail.ModelTier(arg)
+		fmt.Fprintf(c.info, "tier: %s\n", arg)
+	case "/attach":
+		in, err := grail.InputFileFromPath(arg, grail.WithFileName(filepath.Base(arg)))
+		if err != nil {
+			fmt.Fprintf(c.info, "attach: %v\n", err)
+			break
+		}
+		c.attachments = append(c.attachments, in)
+		fmt.Fprintf(c.info, "attached %s to the next message\n", filepath.Base(arg))
+	case "/provider":
+		// Model names don't carry over between providers.
+		if err := c.connect(ctx, arg, c.session.Transcript()); err != nil {
+			fmt.Fprintln(c.info, err)
+			break
+		}
+		c.model, c.tier = "", ""
+		fmt.Fprintf(c.info, "switched to %s\n", arg)
+	case "/save":
+		if arg == "" {
+			fmt.Fprintln(c.info, "usage: /save PATH")
+			break
+		}
+		if err := saveTranscript(c.session.Transcript(), arg); err != nil {
+			fmt.Fprintf(c.info, "save: %v\n", err)
+			break
+		}
+		fmt.Fprintf(c.info, "saved %s\n", arg)
+	case "/reset":
+		c.session.Reset()
+		c.attachments = nil
+		fmt.Fprintln(c.info, "new conversation")
+	default:
+		fmt.Fprintf(c.info, "unknown command %s; /help for commands\n", name)
+	}
+	return false
+}
+
+func orDefault(model string) string {
+	if model == "" {
+		return "provider default"
+	}
+	return model
+}
+
+// saveTranscript writes t with its attachments, replacing path atomically.
+func saveTranscript(t *grail.Transcript, path string) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := t.Export(f, true); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/montanaflynn/grail"
+)
+
+// runCompletion prints a bash or zsh script completing the -model and -tier
+// flags of every command with the models the selected provider lists:
+//
+//	source <(grail completion bash)
+//	grail chat -provider openai -model gpt-<TAB>
+func runCompletion(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: grail completion bash|zsh")
+	}
+	return grail.WriteCompletionScript(os.Stdout, args[0], "grail")
+}
+
+// modelFlags are the flags every command takes to pick the provider and
+// model, and the -complete flag the completion script calls it with.
+type modelFlags struct {
+	provider string
+	model    string
+	tier     string
+	complete string
+}
+
+func (f *modelFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.provider, "provider", "gemini", "provider: gemini, openai, or fake")
+	fs.StringVar(&f.model, "model", "", "model to use (default: the provider's)")
+	fs.StringVar(&f.tier, "tier", "", "model tier to use: best or fast")
+	fs.StringVar(&f.complete, "complete", "", "print completions for the model or tier flag value given as the argument and exit (see grail completion)")
+}
+
+// parse parses args and checks the tier. When called by the completion
+// script, it prints the completions and reports done.
+func (f *modelFlags) parse(ctx context.Context, fs *flag.FlagSet, args []string) (done bool, err error) {
+	fs.Parse(args)
+	if f.complete != "" {
+		printCompletions(ctx, f.provider, f.complete, fs.Arg(0))
+		return true, nil
+	}
+	if f.tier != "" && f.tier != string(grail.ModelTierBest) && f.tier != string(grail.ModelTierFast) {
+		return false, fmt.Errorf("unknown tier %q", f.tier)
+	}
+	return false, nil
+}
+
+// printCompletions prints the values of the model or tier flag starting with
+// prefix, one per line. Errors print nothing, leaving the shell without
+// suggestions.
+func printCompletions(ctx context.Context, providerName, flagName, prefix string) {
+	var values []string
+	switch flagName {
+	case "tier":
+		values = grail.CompleteTiers(prefix)
+	case "model":
+		client, err := newClient(ctx, providerName)
+		if err != nil {
+			return
+		}
+		values, _ = grail.CompleteModels(ctx, client, prefix)
+	}
+	for _, v := range values {
+		fmt.Println(v)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/montanaflynn/grail"
+)
+
+// runGenerate answers one prompt, given as arguments or on stdin. Text is
+// printed as it's generated; with -image, images are saved in -images and
+// their paths printed. With -json, the response, or the error, is printed as
+// one line of JSON for jq and scripts:
+//
+//	grail generate -provider fake -json "Describe a sunset" | jq -r .outputs[0].text
+func runGenerate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	var mf modelFlags
+	mf.register(fs)
+	image := fs.Bool("image", false, "generate an image rather than text")
+	images := fs.String("images", ".", "directory to save images in")
+	jsonOut := fs.Bool("json", false, "print the response as a line of JSON")
+	var attachments []string
+	fs.Func("attach", "attach a file (image, PDF, text); may be repeated", func(path string) error {
+		attachments = append(attachments, path)
+		return nil
+	})
+	if done, err := mf.parse(ctx, fs, args); done || err != nil {
+		return err
+	}
+
+	prompt := strings.Join(fs.Args(), " ")
+	if prompt == "" {
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("read prompt: %w", err)
+		}
+		prompt = strings.TrimSpace(string(b))
+	}
+	if prompt == "" {
+		return errors.New("no prompt given")
+	}
+	req := grail.Request{
+		Inputs: []grail.Input{grail.InputText(prompt)},
+		Output: grail.OutputText(),
+		Model:  mf.model,
+		Tier:   grail.ModelTier(mf.tier),
+	}
+	if *image {
+		req.Output = grail.OutputImage(grail.ImageSpec{})
+	}
+	for _, path := range attachments {
+		in, err := grail.InputFileFromPath(path, grail.WithFileName(filepath.Base(path)))
+		if err != nil {
+			return fmt.Errorf("attach: %w", err)
+		}
+		req.Inputs = append(req.Inputs, in)
+	}
+
+	client, err := newClient(ctx, mf.provider)
+	if *jsonOut {
+		var res grail.Response
+		if err == nil {
+			res, err = client.Generate(ctx, req)
+		}
+		if werr := writeJSON(os.Stdout, mf.provider, res, err, *images); werr != nil {
+			return werr
+		}
+		if err != nil {
+			os.Exit(1)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	streamed := false
+	ctx = grail.ContextWithTextStream(ctx, func(text string) {
+		streamed = true
+		fmt.Print(text)
+	})
+	res, err := client.Generate(ctx, req)
+	if streamed {
+		fmt.Println()
+	}
+	if err != nil {
+		return err
+	}
+	if !*image {
+		return nil
+	}
+	// Images are saved as for -json, named by content hash.
+	out, err := grail.NewResponseJSON(res, *images)
+	if err != nil {
+		return err
+	}
+	for _, part := range out.Outputs {
+		if part.File != "" {
+			fmt.Println(part.File)
+		}
+	}
+	return nil
+}
+
+// writeJSON prints res, or err in its place, as a line of JSON, saving
+// images in imageDir (embedding them if it's empty).
+func writeJSON(w io.Writer, provider string, res grail.Response, err error, imageDir string) error {
+	if err != nil {
+		return grail.ErrorJSON(provider, err).Write(w)
+	}
+	out, err := grail.NewResponseJSON(res, imageDir)
+	if err != nil {
+		return err
+	}
+	return out.Write(w)
+}
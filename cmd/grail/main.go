@@ -0,0 +1,102 @@
+// Grail is a command-line client for grail's providers.
+//
+// Usage:
+//
+//	grail chat [-provider NAME] [-model NAME] [-tier best|fast] [-session FILE] [-json]
+//	grail generate [-provider NAME] [-model NAME] [-tier best|fast] [-image] [-attach FILE]... [-json] [PROMPT]
+//	grail completion bash|zsh
+//
+// Commands:
+//
+//	chat        chat interactively, with replies streamed as they're generated
+//	generate    answer one prompt, from the arguments or stdin
+//	completion  print a shell script completing -model and -tier
+//
+// With -json, every command prints each response, or the error in its place,
+// as one line of JSON (grail.ResponseJSON), so its output composes with jq
+// and shell pipelines; anything else it has to say goes to stderr.
+//
+// Providers are gemini (the default, with GEMINI_API_KEY), openai (with
+// OPENAI_API_KEY), and fake, which makes up answers offline. The -model and
+// -tier flags complete in bash and zsh with the models the selected provider
+// lists:
+//
+//	source <(grail completion bash)
+//
+// Install with:
+//
+//	go install github.com/montanaflynn/grail/cmd/grail@latest
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/fake"
+	"github.com/montanaflynn/grail/providers/gemini"
+	"github.com/montanaflynn/grail/providers/openai"
+)
+
+const usage = `usage: grail <command> [flags]
+
+commands:
+  chat        chat interactively, with replies streamed as they're generated
+  generate    answer one prompt, from the arguments or stdin
+  completion  print a bash or zsh script completing -model and -tier
+
+Every command takes -json to print responses as lines of JSON.
+
+Run grail <command> -h for a command's flags.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	ctx := context.Background()
+	cmd, args := os.Args[1], os.Args[2:]
+	var err error
+	switch cmd {
+	case "chat":
+		err = runChat(ctx, args)
+	case "generate":
+		err = runGenerate(ctx, args)
+	case "completion":
+		err = runCompletion(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "grail: unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "grail %s: %v\n", cmd, err)
+		os.Exit(1)
+	}
+}
+
+// newClient returns a client for the named provider that logs errors only.
+func newClient(ctx context.Context, name string) (grail.Client, error) {
+	var (
+		provider grail.Provider
+		err      error
+	)
+	switch name {
+	case "gemini":
+		provider, err = gemini.New(ctx)
+	case "openai":
+		provider, err = openai.New()
+	case "fake":
+		provider = fake.New()
+	default:
+		return nil, fmt.Errorf("unknown provider %q", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("new %s provider: %w", name, err)
+	}
+	return grail.NewClient(provider, grail.WithLoggerFormat("text", grail.LoggerLevels["error"])), nil
+}
@@ -0,0 +1,81 @@
+package grail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//
+// Shell completion
+//
+
+// CompleteModels returns the names of the models c lists with ListModels
+// that start with prefix, sorted, for completing a model flag.
+func CompleteModels(ctx context.Context, c Client, prefix string) ([]string, error) {
+	models, err := c.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var names []string
+	for _, m := range models {
+		if strings.HasPrefix(m.Name, prefix) && !seen[m.Name] {
+			seen[m.Name] = true
+			names = append(names, m.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// CompleteTiers returns the model tiers that start with prefix, for
+// completing a tier flag.
+func CompleteTiers(prefix string) []string {
+	var tiers []string
+	for _, t := range []ModelTier{ModelTierBest, ModelTierFast} {
+		if strings.HasPrefix(string(t), prefix) {
+			tiers = append(tiers, string(t))
+		}
+	}
+	return tiers
+}
+
+var completionFuncName = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// WriteCompletionScript writes a bash or zsh script completing the -model and
+// -tier flags of the command prog. To complete a value, the script runs the
+// command with the words typed so far followed by
+//
+//	-complete model|tier -- PREFIX
+//
+// and offers each line it prints, so subcommands and flags such as -provider
+// typed earlier apply. Commands handle -complete by printing CompleteModels
+// or CompleteTiers, as cmd/grail does.
+func WriteCompletionScript(w io.Writer, shell, prog string) error {
+	fn := "_" + completionFuncName.ReplaceAllString(prog, "_") + "_grail_complete"
+	var b strings.Builder
+	switch shell {
+	case "bash":
+	case "zsh":
+		b.WriteString("autoload -U +X bashcompinit && bashcompinit\n")
+	default:
+		return NewGrailError(InvalidArgument, fmt.Sprintf("unsupported shell %q (want bash or zsh)", shell))
+	}
+	fmt.Fprintf(&b, `%[1]s() {
+	local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}
+	case $prev in
+	-model|--model|-tier|--tier) ;;
+	*) return ;;
+	esac
+	local IFS=$'\n'
+	COMPREPLY=($("${COMP_WORDS[0]}" "${COMP_WORDS[@]:1:COMP_CWORD-2}" -complete "${prev##*-}" -- "$cur" 2>/dev/null))
+}
+complete -o default -F %[1]s %[2]s
+`, fn, prog)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
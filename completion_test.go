@@ -0,0 +1,49 @@
+package grail_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/fake"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestCompleteModels(t *testing.T) {
+	client := grail.NewClient(fake.New())
+	got, err := grail.CompleteModels(context.Background(), client, "fake-t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []string{"fake-text"}) {
+		t.Fatalf("got %v", got)
+	}
+
+	if _, err := grail.CompleteModels(context.Background(), grail.NewClient(&mock.Provider{}), ""); grail.GetErrorCode(err) != grail.Unsupported {
+		t.Fatalf("want Unsupported for a provider without ListModels, got %v", err)
+	}
+}
+
+func TestCompleteTiers(t *testing.T) {
+	if got := grail.CompleteTiers(""); !reflect.DeepEqual(got, []string{"best", "fast"}) {
+		t.Fatalf("got %v", got)
+	}
+	if got := grail.CompleteTiers("f"); !reflect.DeepEqual(got, []string{"fast"}) {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestWriteCompletionScript(t *testing.T) {
+	var b strings.Builder
+	if err := grail.WriteCompletionScript(&b, "zsh", "my-chat"); err != nil {
+		t.Fatal(err)
+	}
+	if s := b.String(); !strings.Contains(s, "bashcompinit") || !strings.Contains(s, "complete -o default -F _my_chat_grail_complete my-chat") {
+		t.Fatalf("unexpected script:\n%s", s)
+	}
+	if err := grail.WriteCompletionScript(&b, "fish", "chat"); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Fatalf("want InvalidArgument for fish, got %v", err)
+	}
+}
@@ -0,0 +1,297 @@
+package grail
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/montanaflynn/grail/internal/jsonschema"
+)
+
+//
+// Field confidence
+//
+
+// ConfidenceMethod is a way of scoring how sure a model is of each field of a
+// JSON output.
+type ConfidenceMethod string
+
+const (
+	// ConfidenceReported asks the model to rate each field alongside its
+	// answer. It works with any provider but is only as calibrated as the
+	// model's own judgment.
+	ConfidenceReported ConfidenceMethod = "reported"
+	// ConfidenceLogprobs scores each field by the probability the model gave
+	// the tokens of its value. It needs a provider that returns token log
+	// probabilities (ProviderCapabilities.Logprobs).
+	ConfidenceLogprobs ConfidenceMethod = "logprobs"
+)
+
+// WarningConfidenceUnavailable is set on responses missing some of the
+// confidence scores asked for, such as when the model left them out or the
+// provider returned no token log probabilities.
+const WarningConfidenceUnavailable = "confidence_unavailable"
+
+// WithConfidence asks for a confidence score from 0 to 1 for each top-level
+// field of a JSON output, so low-confidence extractions can be routed to
+// human review:
+//
+//	res, err := client.Generate(ctx, grail.Request{
+//		Inputs: inputs,
+//		Output: grail.OutputJSON(invoiceSchema, grail.WithConfidence()),
+//	})
+//	invoice := Invoice{}
+//	scores, err := res.DecodeJSONWithConfidence(&invoice)
+//
+// Without methods, ConfidenceReported is used. With several, each field gets
+// the lowest of its scores. ConfidenceReported needs an object schema with
+// properties; the response's JSON is the answer alone, with the scores read
+// from Response.Confidence.
+func WithConfidence(methods ...ConfidenceMethod) JSONOpt {
+	if len(methods) == 0 {
+		methods = []ConfidenceMethod{ConfidenceReported}
+	}
+	return jsonOptFunc(func(jo *jsonOpt) {
+		jo.confidence = append([]ConfidenceMethod(nil), methods...)
+	})
+}
+
+// GetConfidenceMethods returns the confidence methods asked for on a JSON
+// output, for providers to request what they need, such as token log
+// probabilities.
+func GetConfidenceMethods(output Output) []ConfidenceMethod {
+	if out, ok := output.(jsonOutput); ok {
+		return out.Confidence
+	}
+	return nil
+}
+
+// TokenLogprob is the log probability of one generated token.
+type TokenLogprob struct {
+	Token   string
+	Logprob float64
+}
+
+type jsonPartOpt struct{ logprobs []TokenLogprob }
+
+type jsonPartOptFunc func(*jsonPartOpt)
+
+func (f jsonPartOptFunc) applyJSONPartOpt(jo *jsonPartOpt) { f(jo) }
+
+// WithTokenLogprobs attaches the log probabilities of the tokens that make up
+// a JSON output part, in order, for ConfidenceLogprobs.
+func WithTokenLogprobs(logprobs []TokenLogprob) JSONPartOpt {
+	return jsonPartOptFunc(func(jo *jsonPartOpt) {
+		jo.logprobs = logprobs
+	})
+}
+
+// Confidence returns the per-field confidence scores of a JSON output asked
+// for with WithConfidence, keyed by top-level field name. ok is false if the
+// response has none.
+func (r Response) Confidence() (scores map[string]float64, ok bool) {
+	for _, part := range r.Outputs {
+		if jp, ok := part.(jsonOutputPart); ok && jp.Confidence != nil {
+			return jp.Confidence, true
+		}
+	}
+	return nil, false
+}
+
+// DecodeJSONWithConfidence decodes the JSON output into dst like DecodeJSON
+// and returns its per-field confidence scores, which are nil if the response
+// has none.
+func (r Response) DecodeJSONWithConfidence(dst any) (map[string]float64, error) {
+	if err := r.DecodeJSON(dst); err != nil {
+		return nil, err
+	}
+	scores, _ := r.Confidence()
+	return scores, nil
+}
+
+// LowConfidence returns the fields whose confidence score is below threshold,
+// sorted by name.
+func (r Response) LowConfidence(threshold float64) []string {
+	scores, _ := r.Confidence()
+	var fields []string
+	for field, score := range scores {
+		if score < threshold {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// prepareConfidence checks that the provider can score out's fields and, for
+// ConfidenceReported, wraps the schema so the answer comes with its scores.
+func (c *client) prepareConfidence(req Request, out jsonOutput) (Request, error) {
+	if slices.Contains(out.Confidence, ConfidenceLogprobs) {
+		if caps, ok := c.Capabilities(); ok && !caps.Logprobs {
+			name := c.provider.Name()
+			return req, NewGrailError(Unsupported, fmt.Sprintf("provider %s does not return token log probabilities for confidence scoring", name)).WithProviderName(name)
+		}
+	}
+	if !slices.Contains(out.Confidence, ConfidenceReported) {
+		return req, nil
+	}
+	schema, err := jsonschema.Normalize(out.Schema)
+	if err != nil {
+		return req, NewGrailError(InvalidArgument, fmt.Sprintf("invalid JSON schema: %v", err)).WithCause(err)
+	}
+	props, _ := schema["properties"].(map[string]any)
+	if len(props) == 0 {
+		return req, NewGrailError(InvalidArgument, "reported confidence needs an object schema with properties")
+	}
+	fields := make([]string, 0, len(props))
+	scores := make(map[string]any, len(props))
+	for field := range props {
+		fields = append(fields, field)
+		scores[field] = map[string]any{"type": "number", "minimum": 0, "maximum": 1}
+	}
+	sort.Strings(fields)
+	out.Schema = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"data": schema,
+			"confidence": map[string]any{
+				"type":                 "object",
+				"description":          "How sure you are of each field of data, from 0 (a guess) to 1 (certain).",
+				"properties":           scores,
+				"required":             fields,
+				"additionalProperties": false,
+			},
+		},
+		"required":             []string{"data", "confidence"},
+		"additionalProperties": false,
+	}
+	req.Output = out
+	return req, nil
+}
+
+// confidenceInstructions asks for the reported confidence wrapper, for
+// providers that aren't given the schema.
+func confidenceInstructions(out jsonOutput) string {
+	wrapper, _ := out.Schema.(map[string]any)
+	props, _ := wrapper["properties"].(map[string]any)
+	conf, _ := props["confidence"].(map[string]any)
+	fields, _ := conf["required"].([]string)
+	return `Respond with a JSON object with two fields: "data", holding your answer, and "confidence", ` +
+		`an object giving for each field of data (` + strings.Join(fields, ", ") + `) a number from 0 to 1 ` +
+		`for how sure you are of it, where 0 is a guess and 1 is certain.`
+}
+
+// scoreConfidence unwraps a reported confidence response and scores the
+// fields of res's JSON output by methods.
+func scoreConfidence(res *Response, methods []ConfidenceMethod) {
+	for i, part := range res.Outputs {
+		jp, ok := part.(jsonOutputPart)
+		if !ok {
+			continue
+		}
+		data, start := jp.JSON, 0
+		var reported map[string]float64
+		var missing []string
+		if slices.Contains(methods, ConfidenceReported) {
+			var wrapper struct {
+				Data       json.RawMessage    `json:"data"`
+				Confidence map[string]float64 `json:"confidence"`
+			}
+			spans, err := fieldSpans(jp.JSON)
+			if err == nil && json.Unmarshal(jp.JSON, &wrapper) == nil && wrapper.Data != nil && wrapper.Confidence != nil {
+				data, start = wrapper.Data, spans["data"][0]
+				reported = wrapper.Confidence
+				for field, score := range reported {
+					reported[field] = min(max(score, 0), 1)
+				}
+			} else {
+				missing = append(missing, "the model's response has no confidence scores")
+			}
+		}
+		var scored map[string]float64
+		if slices.Contains(methods, ConfidenceLogprobs) {
+			if scored = logprobScores(jp.JSON, jp.Logprobs, start, len(data)); scored == nil {
+				missing = append(missing, "the provider returned no token log probabilities")
+			}
+		}
+
+		scores := reported
+		for field, score := range scored {
+			if prev, ok := scores[field]; ok {
+				score = min(prev, score)
+			}
+			if scores == nil {
+				scores = map[string]float64{}
+			}
+			scores[field] = score
+		}
+		res.Outputs[i] = jsonOutputPart{JSON: data, Confidence: scores}
+		if len(missing) > 0 {
+			res.Warnings = append(res.Warnings, Warning{Code: WarningConfidenceUnavailable, Message: strings.Join(missing, "; ")})
+		}
+		return
+	}
+}
+
+// logprobScores scores each field of the JSON object at text[start:start+n]
+// by the geometric mean probability of the tokens that overlap its value. It
+// returns nil if the tokens don't spell out text.
+func logprobScores(text []byte, tokens []TokenLogprob, start, n int) map[string]float64 {
+	if len(tokens) == 0 {
+		return nil
+	}
+	offsets := make([]int, len(tokens)+1)
+	for i, t := range tokens {
+		offsets[i+1] = offsets[i] + len(t.Token)
+	}
+	if offsets[len(tokens)] != len(text) {
+		return nil
+	}
+	spans, err := fieldSpans(text[start : start+n])
+	if err != nil {
+		return nil
+	}
+	scores := make(map[string]float64, len(spans))
+	for field, span := range spans {
+		lo, hi := start+span[0], start+span[1]
+		var sum float64
+		var count int
+		for i, t := range tokens {
+			if offsets[i] < hi && offsets[i+1] > lo {
+				sum += t.Logprob
+				count++
+			}
+		}
+		if count > 0 {
+			scores[field] = math.Exp(sum / float64(count))
+		}
+	}
+	return scores
+}
+
+// fieldSpans returns the byte range of each top-level field's value in a JSON
+// object.
+func fieldSpans(data []byte) (map[string][2]int, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("not a JSON object")
+	}
+	spans := map[string][2]int{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		end := int(dec.InputOffset())
+		spans[tok.(string)] = [2]int{end - len(raw), end}
+	}
+	return spans, nil
+}
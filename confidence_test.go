@@ -0,0 +1,118 @@
+package grail_test
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+var invoiceSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"number": map[string]any{"type": "string"},
+		"total":  map[string]any{"type": "number"},
+	},
+	"required": []string{"number", "total"},
+}
+
+type logprobProvider struct{ *mock.Provider }
+
+func (logprobProvider) Capabilities() grail.ProviderCapabilities {
+	return grail.ProviderCapabilities{TextOutput: true, JSONOutput: true, NativeJSON: true, Logprobs: true}
+}
+
+func TestConfidenceReported(t *testing.T) {
+	prov, reqs := scripted(`{"data": {"number": "INV-7", "total": 120.5}, "confidence": {"number": 0.95, "total": 0.4}}`)
+	res, err := grail.NewClient(prov).Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("Extract the invoice.")},
+		Output: grail.OutputJSON(invoiceSchema, grail.WithConfidence()),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var invoice struct {
+		Number string
+		Total  float64
+	}
+	scores, err := res.DecodeJSONWithConfidence(&invoice)
+	if err != nil || invoice.Number != "INV-7" {
+		t.Fatalf("expected the unwrapped answer, got %+v (%v)", invoice, err)
+	}
+	if scores["number"] != 0.95 || scores["total"] != 0.4 {
+		t.Errorf("unexpected scores %v", scores)
+	}
+	if low := res.LowConfidence(0.5); len(low) != 1 || low[0] != "total" {
+		t.Errorf("expected total to be low confidence, got %v", low)
+	}
+
+	sent := (*reqs)[0]
+	if last, _ := grail.AsTextInput(sent.Inputs[len(sent.Inputs)-1]); !strings.Contains(last, "number, total") {
+		t.Errorf("expected instructions naming the fields, got %q", last)
+	}
+	schema, _, _ := grail.GetJSONOutput(sent.Output)
+	if props := schema.(map[string]any)["properties"].(map[string]any); props["confidence"] == nil {
+		t.Errorf("expected the schema to ask for confidence, got %v", schema)
+	}
+
+	// A model that leaves the scores out is reported, not failed.
+	prov, _ = scripted(`{"number": "INV-7", "total": 120.5}`)
+	res, err = grail.NewClient(prov).Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("Extract the invoice.")},
+		Output: grail.OutputJSON(invoiceSchema, grail.WithConfidence(), grail.WithStrictJSON(false)),
+	})
+	if _, ok := res.Confidence(); err != nil || ok {
+		t.Fatalf("expected no scores, got %v", err)
+	}
+	if len(res.Warnings) != 1 || res.Warnings[0].Code != grail.WarningConfidenceUnavailable {
+		t.Errorf("expected confidence_unavailable, got %+v", res.Warnings)
+	}
+
+	if _, err := grail.NewClient(prov).Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("Extract the invoice.")},
+		Output: grail.OutputJSON(nil, grail.WithConfidence()),
+	}); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected InvalidArgument without properties, got %v", err)
+	}
+}
+
+func TestConfidenceLogprobs(t *testing.T) {
+	tokens := []grail.TokenLogprob{
+		{Token: `{"number": "`, Logprob: 0},
+		{Token: `INV`, Logprob: math.Log(0.9)},
+		{Token: `-7", `, Logprob: math.Log(0.9)},
+		{Token: `"total": `, Logprob: 0},
+		{Token: `12`, Logprob: math.Log(0.25)},
+		{Token: `0}`, Logprob: math.Log(0.25)},
+	}
+	var text string
+	for _, tok := range tokens {
+		text += tok.Token
+	}
+	prov := logprobProvider{&mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewJSONOutputPart([]byte(text), grail.WithTokenLogprobs(tokens))}}, nil
+		},
+	}}
+	res, err := grail.NewClient(prov).Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("Extract the invoice.")},
+		Output: grail.OutputJSON(invoiceSchema, grail.WithConfidence(grail.ConfidenceLogprobs)),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	scores, ok := res.Confidence()
+	if !ok || math.Abs(scores["number"]-math.Pow(0.81, 1.0/3)) > 1e-9 || math.Abs(scores["total"]-0.25) > 1e-9 {
+		t.Errorf("unexpected scores %v", scores)
+	}
+
+	if _, err := grail.NewClient(validatedJSONProvider{&mock.Provider{}}).Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("Extract the invoice.")},
+		Output: grail.OutputJSON(invoiceSchema, grail.WithConfidence(grail.ConfidenceLogprobs)),
+	}); grail.GetErrorCode(err) != grail.Unsupported {
+		t.Errorf("expected Unsupported without log probabilities, got %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+package grail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/montanaflynn/grail/internal/jsonschema"
+)
+
+//
+// Output constraints
+//
+
+// DefaultOutputRetries is the number of times a response that breaks its
+// output's constraints is retried when WithOutputRetries isn't set.
+const DefaultOutputRetries = 2
+
+// WarningOutputRetried is set on responses that only met their output's
+// constraints after a retry. The message gives the number of retries.
+const WarningOutputRetried = "output_retried"
+
+type textOutputOptFunc func(*textOutput)
+
+func (f textOutputOptFunc) applyTextOutputOpt(to *textOutput) { f(to) }
+
+// WithEnum restricts a text output to one of values, for classification and
+// other closed-set answers:
+//
+//	grail.OutputText(grail.WithEnum("positive", "negative", "neutral"))
+//
+// The response text is exactly one of the values: surrounding whitespace,
+// quotes, and a final period are dropped, and case is ignored in matching.
+// Providers with a native enum mode (Gemini) are constrained by it; others
+// are instructed and their answers validated, with retries (see
+// WithOutputRetries).
+func WithEnum(values ...string) TextOutputOpt {
+	return textOutputOptFunc(func(to *textOutput) {
+		to.Enum = append([]string(nil), values...)
+	})
+}
+
+// WithPattern restricts a text output to answers that match pattern, a Go
+// regular expression that must match the whole answer, for formats like ISO
+// dates ("\d{4}-\d{2}-\d{2}"). Answers are instructed, validated, and
+// retried as with WithEnum; the response text is trimmed of surrounding
+// whitespace.
+func WithPattern(pattern string) TextOutputOpt {
+	return textOutputOptFunc(func(to *textOutput) {
+		to.Pattern = pattern
+	})
+}
+
+// GetTextConstraints returns the constraints on a text output, for providers
+// that can enforce them natively. ok is false for other outputs.
+func GetTextConstraints(output Output) (enum []string, pattern string, ok bool) {
+	if to, ok := output.(textOutput); ok {
+		return to.Enum, to.Pattern, true
+	}
+	return nil, "", false
+}
+
+// WithOutputRetries sets how many times a response that breaks its output's
+// constraints is retried (default DefaultOutputRetries). Constraints are
+// text outputs' WithEnum and WithPattern, and strict JSON schemas on
+// providers whose JSON output isn't constrained natively. Each retry shows the
+// model its invalid answer and what was wrong with it; usage is summed across
+// attempts. Zero disables retries: a response that breaks the constraints
+// fails with OutputInvalid.
+func WithOutputRetries(n int) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.outputRetries = &n
+	})
+}
+
+func validateTextOutput(out textOutput) error {
+	for i, v := range out.Enum {
+		if strings.TrimSpace(v) == "" {
+			return NewGrailError(InvalidArgument, fmt.Sprintf("output enum value %d is empty", i))
+		}
+	}
+	if out.Pattern != "" {
+		if _, err := regexp.Compile(out.Pattern); err != nil {
+			return NewGrailError(InvalidArgument, fmt.Sprintf("invalid output pattern: %v", err)).WithCause(err)
+		}
+	}
+	return nil
+}
+
+// constraintInstructions returns the prompt describing out's constraints, or
+// "" if it has none.
+func constraintInstructions(out textOutput) string {
+	switch {
+	case len(out.Enum) > 0:
+		return "Respond with only one of these values, exactly as written, and nothing else: " + strings.Join(out.Enum, ", ") + "."
+	case out.Pattern != "":
+		return "Respond with only the answer, with no other text. The answer must match this regular expression: " + out.Pattern
+	}
+	return ""
+}
+
+// constrainedText checks text against out's constraints and returns it in
+// canonical form: trimmed, and for enums, the matching value as given.
+func constrainedText(out textOutput, text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if len(out.Enum) > 0 {
+		answer := strings.TrimSpace(strings.TrimSuffix(strings.Trim(text, "\"'`*"), "."))
+		for _, v := range out.Enum {
+			if strings.EqualFold(answer, strings.TrimSpace(v)) {
+				return v, nil
+			}
+		}
+		return "", fmt.Errorf("%q is not one of %s", text, strings.Join(out.Enum, ", "))
+	}
+	if out.Pattern != "" {
+		re, err := regexp.Compile(`^(?:` + out.Pattern + `)$`)
+		if err != nil {
+			return "", err
+		}
+		if !re.MatchString(text) {
+			return "", fmt.Errorf("%q does not match the pattern %s", text, out.Pattern)
+		}
+	}
+	return text, nil
+}
+
+// hasConstraints reports whether the client must check p's responses.
+func (c *client) hasConstraints(p preparedRequest) bool {
+	switch out := p.req.Output.(type) {
+	case textOutput:
+		if p.fallback {
+			return p.jsonOut.Strict && p.jsonOut.Schema != nil
+		}
+		return len(out.Enum) > 0 || out.Pattern != ""
+	case jsonOutput:
+		if !out.Strict || out.Schema == nil {
+			return false
+		}
+		caps, ok := c.Capabilities()
+		return ok && !caps.NativeJSON
+	}
+	return false
+}
+
+// checkConstraints returns an error describing how res breaks p's
+// constraints, first putting a valid constrained text answer in canonical
+// form.
+func (c *client) checkConstraints(p preparedRequest, res *Response) error {
+	if p.fallback {
+		text, _ := res.Text()
+		data, ok := ExtractJSON(text)
+		if !ok {
+			return fmt.Errorf("no JSON found in the response")
+		}
+		return jsonschema.Validate(p.jsonOut.Schema, data)
+	}
+	switch out := p.req.Output.(type) {
+	case textOutput:
+		for i, part := range res.Outputs {
+			if tp, ok := part.(textOutputPart); ok {
+				text, err := constrainedText(out, tp.Text)
+				if err != nil {
+					return err
+				}
+				res.Outputs[i] = textOutputPart{Text: text}
+			}
+		}
+		if _, ok := res.Text(); !ok && len(res.ToolCalls()) == 0 {
+			return fmt.Errorf("the response has no text")
+		}
+	case jsonOutput:
+		for _, part := range res.Outputs {
+			if jp, ok := part.(jsonOutputPart); ok {
+				return jsonschema.Validate(out.Schema, jp.JSON)
+			}
+		}
+		if len(res.ToolCalls()) == 0 {
+			return fmt.Errorf("the response has no JSON")
+		}
+	}
+	return nil
+}
+
+// enforceConstraints retries p until its response meets the output's
+// constraints or the retries run out, returning the last response with usage
+// summed across attempts. A JSON fallback response that still breaks its
+// schema is returned without error for finishJSONFallback to report.
+func (c *client) enforceConstraints(ctx context.Context, p preparedRequest, res Response) (Response, error) {
+	retries := DefaultOutputRetries
+	if c.opts.outputRetries != nil {
+		retries = max(*c.opts.outputRetries, 0)
+	}
+	// Corrections go before the instructions the client appended, so the
+	// instructions stay last.
+	req := p.req
+	inputs, tail := req.Inputs, []Input(nil)
+	if out, ok := req.Output.(textOutput); p.fallback || ok && constraintInstructions(out) != "" {
+		inputs, tail = inputs[:len(inputs)-1:len(inputs)-1], inputs[len(inputs)-1:]
+	}
+	for attempt := 0; ; attempt++ {
+		violation := c.checkConstraints(p, &res)
+		if violation == nil {
+			if attempt > 0 {
+				res.Warnings = append(res.Warnings, Warning{Code: WarningOutputRetried, Message: fmt.Sprintf("output met its constraints after %d retries", attempt)})
+			}
+			return res, nil
+		}
+		if attempt == retries {
+			if p.fallback {
+				return res, nil
+			}
+			return res, NewGrailError(OutputInvalid, fmt.Sprintf("output breaks its constraints: %v", violation)).
+				WithCause(violation).WithProviderName(res.Provider.Name).WithRequestID(res.RequestID)
+		}
+		if c.log != nil {
+			c.log.Debug("retrying output that breaks its constraints", slog.Int("attempt", attempt+1), slog.String("violation", violation.Error()))
+		}
+		inputs = append(inputs, retryInputs(res, violation)...)
+		req.Inputs = append(inputs[:len(inputs):len(inputs)], tail...)
+		next, err := c.callProvider(ctx, req)
+		next.Usage = res.Usage.Add(next.Usage)
+		if err != nil {
+			return next, err
+		}
+		res = next
+	}
+}
+
+// retryInputs show the model its invalid answer and what's wrong with it.
+func retryInputs(res Response, violation error) []Input {
+	var previous string
+	for _, part := range res.Outputs {
+		switch v := part.(type) {
+		case textOutputPart:
+			previous = v.Text
+		case jsonOutputPart:
+			previous = string(v.JSON)
+		}
+	}
+	return []Input{
+		InputText("Your previous answer was:\n\n" + previous),
+		InputText(fmt.Sprintf("That answer is invalid: %v. Answer again, following the instructions exactly.", violation)),
+	}
+}
@@ -0,0 +1,132 @@
+package grail_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+type validatedJSONProvider struct{ *mock.Provider }
+
+func (validatedJSONProvider) Capabilities() grail.ProviderCapabilities {
+	return grail.ProviderCapabilities{TextOutput: true, JSONOutput: true}
+}
+
+// scripted returns a provider that replies with each text in turn and records
+// the requests it gets.
+func scripted(replies ...string) (*mock.Provider, *[]grail.Request) {
+	var reqs []grail.Request
+	return &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			reply := replies[min(len(reqs), len(replies)-1)]
+			reqs = append(reqs, req)
+			usage := grail.Usage{InputTokens: 5, OutputTokens: 1}
+			if _, _, isJSON := grail.GetJSONOutput(req.Output); isJSON {
+				return grail.Response{Usage: usage, Outputs: []grail.OutputPart{grail.NewJSONOutputPart([]byte(reply))}}, nil
+			}
+			return grail.Response{Usage: usage, Outputs: []grail.OutputPart{grail.NewTextOutputPart(reply)}}, nil
+		},
+	}, &reqs
+}
+
+func TestOutputEnum(t *testing.T) {
+	prov, reqs := scripted("I think it's positive overall.", " \"Positive.\"\n")
+	client := grail.NewClient(prov)
+	res, err := client.Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("Classify: I love it")},
+		Output: grail.OutputText(grail.WithEnum("positive", "negative", "neutral")),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text, _ := res.Text(); text != "positive" {
+		t.Errorf("expected the canonical enum value, got %q", text)
+	}
+	if len(*reqs) != 2 || res.Usage.InputTokens != 10 {
+		t.Fatalf("expected one retry with usage summed, got %d calls and %+v", len(*reqs), res.Usage)
+	}
+	if len(res.Warnings) != 1 || res.Warnings[0].Code != grail.WarningOutputRetried {
+		t.Errorf("expected output_retried warning, got %+v", res.Warnings)
+	}
+
+	first := (*reqs)[0].Inputs
+	if last, _ := grail.AsTextInput(first[len(first)-1]); !strings.Contains(last, "positive, negative, neutral") {
+		t.Errorf("expected enum instructions, got %q", last)
+	}
+	retry := (*reqs)[1].Inputs
+	if len(retry) != 4 {
+		t.Fatalf("expected the prompt, the invalid answer, the correction, and the instructions, got %d inputs", len(retry))
+	}
+	if correction, _ := grail.AsTextInput(retry[2]); !strings.Contains(correction, "is not one of") {
+		t.Errorf("expected the violation in the retry, got %q", correction)
+	}
+}
+
+func TestOutputPattern(t *testing.T) {
+	prov, reqs := scripted("March 3rd, 2026")
+	client := grail.NewClient(prov, grail.WithOutputRetries(0))
+	_, err := client.Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("When is the launch?")},
+		Output: grail.OutputText(grail.WithPattern(`\d{4}-\d{2}-\d{2}`)),
+	})
+	if grail.GetErrorCode(err) != grail.OutputInvalid || len(*reqs) != 1 {
+		t.Fatalf("expected OutputInvalid without retrying, got %v after %d calls", err, len(*reqs))
+	}
+
+	prov, _ = scripted(" 2026-03-03 ")
+	res, err := grail.NewClient(prov).Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("When is the launch?")},
+		Output: grail.OutputText(grail.WithPattern(`\d{4}-\d{2}-\d{2}`)),
+	})
+	if text, _ := res.Text(); err != nil || text != "2026-03-03" {
+		t.Fatalf("expected the trimmed date, got %q (%v)", text, err)
+	}
+
+	if _, err := grail.NewClient(prov).Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("When?")},
+		Output: grail.OutputText(grail.WithPattern(`(`)),
+	}); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected InvalidArgument for a bad pattern, got %v", err)
+	}
+}
+
+func TestOutputJSONConstraints(t *testing.T) {
+	schema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"date":   map[string]any{"type": "string", "format": "date"},
+			"status": map[string]any{"type": "string", "enum": []string{"open", "closed"}},
+			"code":   map[string]any{"type": "string", "pattern": "^[A-Z]{3}$"},
+		},
+		"required": []string{"date", "status", "code"},
+	}
+	mp, reqs := scripted(
+		`{"date": "03/03/2026", "status": "open", "code": "ABC"}`,
+		`{"date": "2026-03-03", "status": "open", "code": "abc"}`,
+		`{"date": "2026-03-03", "status": "open", "code": "ABC"}`,
+	)
+	client := grail.NewClient(validatedJSONProvider{mp})
+	res, err := client.Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("Extract the ticket.")},
+		Output: grail.OutputJSON(schema),
+	})
+	if err != nil || len(*reqs) != 3 {
+		t.Fatalf("expected success on the third call, got %v after %d calls", err, len(*reqs))
+	}
+	var got struct{ Code string }
+	if err := res.DecodeJSON(&got); err != nil || got.Code != "ABC" {
+		t.Errorf("expected the valid response, got %+v (%v)", got, err)
+	}
+
+	// Non-strict JSON isn't checked.
+	mp, reqs = scripted(`{"date": "soon"}`)
+	if _, err := grail.NewClient(validatedJSONProvider{mp}).Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("Extract the ticket.")},
+		Output: grail.OutputJSON(schema, grail.WithStrictJSON(false)),
+	}); err != nil || len(*reqs) != 1 {
+		t.Errorf("expected non-strict JSON to pass unchecked, got %v after %d calls", err, len(*reqs))
+	}
+}
@@ -0,0 +1,44 @@
+package grail
+
+import "maps"
+
+//
+// Response cost estimates
+//
+
+// WithPrices sets the prices responses' Cost is estimated with, adding to
+// or replacing the provider's own (see PriceLister). Provider packages ship
+// list prices, which go stale; set prices here to correct them or to apply
+// negotiated rates.
+func WithPrices(prices PriceTable) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.prices = maps.Clone(prices)
+	})
+}
+
+// price estimates the cost of res, priced at the model that answered, or nil
+// if that model has no price.
+func (c *client) price(req Request, res Response) *Cost {
+	model := req.Model
+	if len(res.Provider.Models) > 0 {
+		model = res.Provider.Models[0].Name
+	}
+	p, ok := c.priceOf(model)
+	if !ok {
+		return nil
+	}
+	cost := p.Estimate(res.Usage)
+	return &cost
+}
+
+// priceOf returns the price of model, from WithPrices or the provider.
+func (c *client) priceOf(model string) (Price, bool) {
+	if p, ok := c.opts.prices[model]; ok {
+		return p, true
+	}
+	if lister, ok := c.provider.(PriceLister); ok {
+		p, ok := lister.Prices()[model]
+		return p, ok
+	}
+	return Price{}, false
+}
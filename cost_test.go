@@ -0,0 +1,78 @@
+package grail_test
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestPriceEstimate(t *testing.T) {
+	p := grail.Price{InputPerMTok: 2, OutputPerMTok: 10, CachedInputPerMTok: 0.5, ImageOutputPerMTok: 100}
+	c := p.Estimate(grail.Usage{
+		InputTokens:       1_000_000,
+		CachedInputTokens: 200_000,
+		ImageInputTokens:  100_000, // no image input price, so at the input price
+		OutputTokens:      300_000,
+		ImageOutputTokens: 100_000,
+	})
+	// Input: 1M at $2, less 200K cached saving $1.50/M. Output: 200K text at
+	// $10/M and 100K image at $100/M.
+	near := func(got, want float64) bool { return math.Abs(got-want) < 1e-9 }
+	if !near(c.Input, 1.7) || !near(c.Output, 12) || !near(c.Total, 13.7) || !near(c.Savings, 0.3) {
+		t.Errorf("unexpected cost %+v", c)
+	}
+	if !near(p.Cost(grail.Usage{InputTokens: 1_000_000}), 2) {
+		t.Error("expected Cost to match the estimate's total")
+	}
+}
+
+// pricedProvider publishes its prices, as provider packages do.
+type pricedProvider struct {
+	*mock.Provider
+}
+
+func (pricedProvider) Prices() grail.PriceTable {
+	return grail.PriceTable{"listed": {InputPerMTok: 1, OutputPerMTok: 2}, "both": {InputPerMTok: 1}}
+}
+
+func TestResponseCost(t *testing.T) {
+	prov := pricedProvider{&mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{
+				Outputs:  []grail.OutputPart{grail.NewTextOutputPart("ok")},
+				Usage:    grail.Usage{InputTokens: 1_000_000, OutputTokens: 1_000_000, TotalTokens: 2_000_000},
+				Provider: grail.ProviderInfo{Name: "mock", Models: []grail.ModelUse{{Name: req.Model}}},
+			}, nil
+		},
+	}}
+	tracker := grail.NewUsageTracker(nil)
+	client := grail.NewClient(prov,
+		grail.WithPrices(grail.PriceTable{"both": {InputPerMTok: 5}}),
+		grail.WithUsageTracker(tracker),
+	)
+
+	for model, want := range map[string]float64{"listed": 3, "both": 5} {
+		res, err := client.Generate(context.Background(), grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText(), Model: model})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Cost == nil || res.Cost.Total != want {
+			t.Errorf("%s: expected $%v, got %+v", model, want, res.Cost)
+		}
+	}
+	res, err := client.Generate(context.Background(), grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText(), Model: "unlisted"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Cost != nil {
+		t.Errorf("expected no cost for an unpriced model, got %+v", res.Cost)
+	}
+
+	// The tracker has no prices of its own, so it counts responses' costs.
+	if rep := tracker.Report(grail.Period{}); rep.Total.Cost != 8 {
+		t.Errorf("expected the tracker to total $8, got $%v", rep.Total.Cost)
+	}
+}
@@ -0,0 +1,112 @@
+package grail
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/montanaflynn/grail/internal/imaging"
+)
+
+//
+// Content credentials (C2PA / SynthID)
+//
+
+// ContentCredentials describes provenance metadata attached to a generated image.
+type ContentCredentials struct {
+	// C2PA is the raw C2PA manifest store (JUMBF) embedded in the image bytes,
+	// or nil if the image carries no manifest. OpenAI image models embed one.
+	C2PA []byte
+	// SynthID reports that the provider marked the image with an invisible
+	// SynthID watermark. The watermark lives in the pixels and survives
+	// metadata stripping; Gemini image models always apply it.
+	SynthID bool
+}
+
+// HasC2PA reports whether a C2PA manifest is embedded in the image.
+func (c ContentCredentials) HasC2PA() bool { return len(c.C2PA) > 0 }
+
+// WithSynthID marks an image or video output part as carrying a SynthID
+// watermark. Providers use this when their models watermark every output.
+func WithSynthID() ImagePartOpt {
+	return imagePartOptFunc(func(io *imagePartOpt) {
+		io.synthID = true
+	})
+}
+
+func contentCredentials(part imageOutputPart) ContentCredentials {
+	manifest, _ := imaging.FindC2PA(part.Data)
+	return ContentCredentials{
+		C2PA:    manifest,
+		SynthID: part.SynthID,
+	}
+}
+
+// StripContentCredentials returns a copy of an encoded PNG, JPEG, or WebP image
+// with its C2PA manifest removed. Pixel data is not re-encoded, so invisible
+// watermarks such as SynthID are unaffected.
+func StripContentCredentials(data []byte) []byte {
+	return imaging.StripC2PA(data)
+}
+
+//
+// Saving image outputs
+//
+
+type SaveOpt interface{ applySaveOpt(*saveOpt) }
+
+// WithContentCredentials controls whether the C2PA manifest is kept when an
+// image is saved (default: retained).
+func WithContentCredentials(retain bool) SaveOpt {
+	return saveOptFunc(func(so *saveOpt) {
+		so.stripCredentials = !retain
+	})
+}
+
+type saveOpt struct {
+	stripCredentials bool
+	manifest         *Manifest
+	manifestOpts     []ManifestOption
+}
+
+type saveOptFunc func(*saveOpt)
+
+func (f saveOptFunc) applySaveOpt(so *saveOpt) {
+	f(so)
+}
+
+func newSaveOpt(opts []SaveOpt) *saveOpt {
+	so := &saveOpt{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt.applySaveOpt(so)
+		}
+	}
+	return so
+}
+
+// Bytes returns the encoded image, applying the given save options.
+func (i ImageOutputInfo) Bytes(opts ...SaveOpt) []byte {
+	return i.bytes(newSaveOpt(opts))
+}
+
+func (i ImageOutputInfo) bytes(so *saveOpt) []byte {
+	if so.stripCredentials {
+		return StripContentCredentials(i.Data)
+	}
+	return i.Data
+}
+
+// Save writes the image to path, applying the given save options.
+func (i ImageOutputInfo) Save(path string, opts ...SaveOpt) error {
+	so := newSaveOpt(opts)
+	data := i.bytes(so)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return NewGrailError(Internal, fmt.Sprintf("failed to save image: %v", err)).WithCause(err)
+	}
+	if so.manifest != nil {
+		m := *so.manifest
+		m.MIME = i.MIME
+		return WriteManifest(path, data, m, so.manifestOpts...)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package grail_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+)
+
+// pngWithChunk encodes a 1x1 PNG and inserts an extra chunk before IEND.
+func pngWithChunk(t *testing.T, typ string, body []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	data := buf.Bytes()
+	iend := len(data) - 12
+
+	chunk := make([]byte, 8, 12+len(body))
+	binary.BigEndian.PutUint32(chunk[0:4], uint32(len(body)))
+	copy(chunk[4:8], typ)
+	chunk = append(chunk, body...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	out := append([]byte(nil), data[:iend]...)
+	out = append(out, chunk...)
+	return append(out, data[iend:]...)
+}
+
+func TestContentCredentials(t *testing.T) {
+	manifest := []byte("\x00\x00\x00\x10jumbc2pa manifest")
+	data := pngWithChunk(t, "caBX", manifest)
+
+	res := grail.Response{
+		Outputs: []grail.OutputPart{
+			grail.NewImageOutputPart(data, "image/png", "", grail.WithSynthID()),
+		},
+	}
+	infos := res.ImageOutputs()
+	if len(infos) != 1 {
+		t.Fatalf("expected 1 image, got %d", len(infos))
+	}
+	cc := infos[0].Credentials
+	if !cc.HasC2PA() || !bytes.Equal(cc.C2PA, manifest) {
+		t.Fatalf("expected C2PA manifest %q, got %q", manifest, cc.C2PA)
+	}
+	if !cc.SynthID {
+		t.Fatalf("expected SynthID flag")
+	}
+
+	t.Run("retained by default", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "out.png")
+		if err := infos[0].Save(path); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+		saved, _ := os.ReadFile(path)
+		if !bytes.Equal(saved, data) {
+			t.Fatalf("expected saved bytes to match original")
+		}
+	})
+
+	t.Run("stripped on request", func(t *testing.T) {
+		stripped := infos[0].Bytes(grail.WithContentCredentials(false))
+		if bytes.Contains(stripped, []byte("caBX")) {
+			t.Fatalf("expected caBX chunk to be removed")
+		}
+		if _, err := png.Decode(bytes.NewReader(stripped)); err != nil {
+			t.Fatalf("stripped image no longer decodes: %v", err)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package grail
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+)
+
+//
+// Data URIs
+//
+
+// InputFileFromDataURI decodes an RFC 2397 data URI
+// ("data:image/png;base64,iVBOR...") into a file input. The MIME type
+// defaults to text/plain as the RFC specifies; for a data URI without a type
+// whose payload is an image, the sniffed image type is used instead.
+func InputFileFromDataURI(uri string, opts ...FileOpt) (Input, error) {
+	data, mimeType, err := parseDataURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	return InputFile(data, mimeType, opts...), nil
+}
+
+func parseDataURI(uri string) ([]byte, string, error) {
+	if len(uri) < 5 || !strings.EqualFold(uri[:5], "data:") {
+		return nil, "", NewGrailError(InvalidArgument, "data URI must start with \"data:\"")
+	}
+	header, payload, ok := strings.Cut(uri[5:], ",")
+	if !ok {
+		return nil, "", NewGrailError(InvalidArgument, "data URI has no \",\" before its data")
+	}
+
+	isBase64 := false
+	if h, found := strings.CutSuffix(header, ";base64"); found {
+		header, isBase64 = h, true
+	}
+
+	var data []byte
+	if isBase64 {
+		// Tolerate line breaks and missing padding, both common in the wild.
+		payload = strings.NewReplacer("\n", "", "\r", "", " ", "").Replace(payload)
+		var err error
+		if data, err = base64.StdEncoding.DecodeString(payload); err != nil {
+			if data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(payload, "=")); err != nil {
+				return nil, "", NewGrailError(InvalidArgument, fmt.Sprintf("data URI: invalid base64: %v", err)).WithCause(err)
+			}
+		}
+	} else {
+		s, err := url.PathUnescape(payload)
+		if err != nil {
+			return nil, "", NewGrailError(InvalidArgument, fmt.Sprintf("data URI: invalid percent-encoding: %v", err)).WithCause(err)
+		}
+		data = []byte(s)
+	}
+
+	mimeType := "text/plain"
+	if header != "" && !strings.HasPrefix(header, ";") {
+		mt, _, err := mime.ParseMediaType(header)
+		if err != nil {
+			return nil, "", NewGrailError(InvalidArgument, fmt.Sprintf("data URI: invalid media type %q: %v", header, err)).WithCause(err)
+		}
+		mimeType = mt
+	} else if sniffed := SniffImageMIME(data); sniffed != "" {
+		mimeType = sniffed
+	}
+	return data, mimeType, nil
+}
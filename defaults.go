@@ -0,0 +1,43 @@
+package grail
+
+//
+// Default request options
+//
+
+// WithDefaultRequest merges def into every request passed to Generate, so
+// applications can set a system prompt, model, tier, output, provider options,
+// or metadata once:
+//
+//   - Inputs in def are prepended to the request's inputs (e.g. a system prompt).
+//   - Output, Model, and Tier apply when the request leaves them unset. A
+//     request that sets Model or Tier overrides both defaults.
+//   - ProviderOptions in def are applied before the request's own, so the
+//     request wins when both set the same option.
+//   - Metadata keys are merged; the request's values win.
+func WithDefaultRequest(def Request) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		def.Metadata = copyMetadata(def.Metadata)
+		co.defaults = &def
+	})
+}
+
+func mergeRequest(def, req Request) Request {
+	if len(def.Inputs) > 0 {
+		req.Inputs = append(append(make([]Input, 0, len(def.Inputs)+len(req.Inputs)), def.Inputs...), req.Inputs...)
+	}
+	if req.Output == nil {
+		req.Output = def.Output
+	}
+	if req.Model == "" && req.Tier == "" {
+		req.Model = def.Model
+		req.Tier = def.Tier
+	}
+	if req.Priority == PriorityNormal {
+		req.Priority = def.Priority
+	}
+	if len(def.ProviderOptions) > 0 {
+		req.ProviderOptions = append(append(make([]ProviderOption, 0, len(def.ProviderOptions)+len(req.ProviderOptions)), def.ProviderOptions...), req.ProviderOptions...)
+	}
+	req.Metadata = mergeMetadata(def.Metadata, req.Metadata)
+	return req
+}
@@ -0,0 +1,56 @@
+package grail_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestWithDefaultRequest(t *testing.T) {
+	var got grail.Request
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			got = req
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("ok")}}, nil
+		},
+	}
+	client := grail.NewClient(prov, grail.WithDefaultRequest(grail.Request{
+		Inputs:   []grail.Input{grail.InputText("You are terse.")},
+		Output:   grail.OutputText(),
+		Model:    "default-model",
+		Metadata: map[string]string{"app": "demo", "env": "dev"},
+	}))
+
+	_, err := client.Generate(context.Background(), grail.Request{
+		Inputs:   []grail.Input{grail.InputText("hi")},
+		Metadata: map[string]string{"env": "prod"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got.Inputs) != 2 {
+		t.Fatalf("expected default input prepended, got %d inputs", len(got.Inputs))
+	}
+	if system, _ := grail.AsTextInput(got.Inputs[0]); system != "You are terse." {
+		t.Fatalf("expected system prompt first, got %q", system)
+	}
+	if !grail.IsTextOutput(got.Output) || got.Model != "default-model" {
+		t.Fatalf("expected default output and model, got %+v", got)
+	}
+	if got.Metadata["app"] != "demo" || got.Metadata["env"] != "prod" {
+		t.Fatalf("unexpected merged metadata: %v", got.Metadata)
+	}
+
+	// A request tier overrides the default model.
+	if _, err := client.Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("hi")},
+		Tier:   grail.ModelTierFast,
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Model != "" || got.Tier != grail.ModelTierFast {
+		t.Fatalf("expected request tier to override default model, got model=%q tier=%q", got.Model, got.Tier)
+	}
+}
@@ -0,0 +1,519 @@
+package grail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+)
+
+//
+// Durable execution
+//
+
+// Durable workflow engines such as Temporal and Inngest record each
+// activity's input and output, retry activities that fail or time out, and
+// replay workflow code deterministically. RequestRecord and ResponseRecord
+// are requests and responses as plain JSON-serializable data for activity
+// inputs and outputs, RequestKey identifies a request deterministically, and
+// Activity runs records idempotently with heartbeats.
+
+// RecordPart is one input or output of a RequestRecord or ResponseRecord.
+type RecordPart struct {
+	Type    string          `json:"type"` // "text", "json", "file", "uploaded_file", "image", "video", "tool_call", or "tool_result"
+	Text    string          `json:"text,omitempty"`
+	JSON    json.RawMessage `json:"json,omitempty"` // JSON output or tool call arguments
+	CallID  string          `json:"call_id,omitempty"`
+	FileID  string          `json:"file_id,omitempty"` // an uploaded file's provider ID
+	Data    []byte          `json:"data,omitempty"`
+	MIME    string          `json:"mime,omitempty"`
+	Name    string          `json:"name,omitempty"`
+	Size    int64           `json:"size,omitempty"`
+	SynthID bool            `json:"synth_id,omitempty"`
+
+	CacheBreakpoint bool               `json:"cache_breakpoint,omitempty"`
+	Droppable       bool               `json:"droppable,omitempty"` // see WithDroppable
+	Priority        int                `json:"priority,omitempty"`
+	Confidence      map[string]float64 `json:"confidence,omitempty"`
+}
+
+// MessageRecord is a Message of a RequestRecord's history.
+type MessageRecord struct {
+	Role   TurnRole     `json:"role"`
+	Inputs []RecordPart `json:"inputs"`
+}
+
+// OutputRecord is a RequestRecord's Output.
+type OutputRecord struct {
+	Type       string             `json:"type"`              // "text", "json", "image", or "video"
+	Enum       []string           `json:"enum,omitempty"`    // text
+	Pattern    string             `json:"pattern,omitempty"` // text
+	Schema     json.RawMessage    `json:"schema,omitempty"`  // JSON
+	Strict     bool               `json:"strict,omitempty"`  // JSON
+	Confidence []ConfidenceMethod `json:"confidence,omitempty"`
+	Image      *ImageSpec         `json:"image,omitempty"`
+	Video      *VideoSpec         `json:"video,omitempty"`
+}
+
+// OptionRecord is a provider option of a RequestRecord: its Go type, such
+// as "openai.TextOptions", and its JSON encoding.
+type OptionRecord struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// RequestRecord is a Request as plain data, for passing requests to
+// activities and recording them in workflow history.
+type RequestRecord struct {
+	// Key identifies the request's content (see RequestKey), so an activity
+	// retried with the same record can be recognized.
+	Key      string            `json:"key"`
+	Inputs   []RecordPart      `json:"inputs"`
+	History  []MessageRecord   `json:"history,omitempty"`
+	Output   OutputRecord      `json:"output"`
+	Model    string            `json:"model,omitempty"`
+	Tier     ModelTier         `json:"tier,omitempty"`
+	Priority Priority          `json:"priority,omitempty"`
+	Options  []OptionRecord    `json:"options,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Tools    []Tool            `json:"tools,omitempty"`
+}
+
+// ResponseRecord is a Response as plain data, for returning responses from
+// activities. Batch journals store responses in the same form.
+type ResponseRecord struct {
+	Outputs   []RecordPart `json:"outputs"`
+	Usage     Usage        `json:"usage"`
+	Cost      *Cost        `json:"cost,omitempty"`
+	Provider  ProviderInfo `json:"provider"`
+	RequestID string       `json:"request_id,omitempty"`
+	Warnings  []Warning    `json:"warnings,omitempty"`
+	// Request is the request that produced the response, so the rebuilt
+	// response can be regenerated (see Client.Regenerate). It's nil for
+	// responses without one, and for requests that can't be recorded.
+	Request *RequestRecord `json:"request,omitempty"`
+}
+
+// RequestKey returns a deterministic key for req's content: its inputs,
+// history, tools, output, model selection, provider options, and metadata.
+// Equal requests get equal keys across processes and replays, so the key
+// can serve as an idempotency key. Streamed file inputs count by name, MIME
+// type, and size.
+func RequestKey(req Request) string {
+	return requestHash(req)
+}
+
+// NewRequestRecord records req. Streamed file inputs are read into the
+// record. Provider options must be structs with only exported fields, so
+// they survive a JSON round trip; others are an InvalidArgument error.
+func NewRequestRecord(req Request) (RequestRecord, error) {
+	inputs, err := readStreamedInputs(req.Inputs)
+	if err != nil {
+		return RequestRecord{}, err
+	}
+	req.Inputs = inputs
+
+	rec := RequestRecord{
+		Key:      requestHash(req),
+		Model:    req.Model,
+		Tier:     req.Tier,
+		Priority: req.Priority,
+		Metadata: copyMetadata(req.Metadata),
+		Tools:    req.Tools,
+	}
+	if rec.Inputs, err = inputRecords(req.Inputs); err != nil {
+		return RequestRecord{}, err
+	}
+	for _, m := range req.History {
+		parts, err := inputRecords(m.Inputs)
+		if err != nil {
+			return RequestRecord{}, err
+		}
+		rec.History = append(rec.History, MessageRecord{Role: m.Role, Inputs: parts})
+	}
+	if rec.Output, err = outputRecord(req.Output); err != nil {
+		return RequestRecord{}, err
+	}
+	for _, opt := range req.ProviderOptions {
+		or, err := optionRecord(opt)
+		if err != nil {
+			return RequestRecord{}, err
+		}
+		rec.Options = append(rec.Options, or)
+	}
+	return rec, nil
+}
+
+// OptionDecoder turns an OptionRecord back into a provider option.
+type OptionDecoder func(typ string, value json.RawMessage) (ProviderOption, error)
+
+// ProviderOptionTypes returns an OptionDecoder for the types of the given
+// options, such as ProviderOptionTypes(openai.TextOptions{},
+// gemini.TextOptions{}).
+func ProviderOptionTypes(samples ...ProviderOption) OptionDecoder {
+	types := map[string]reflect.Type{}
+	for _, s := range samples {
+		types[fmt.Sprintf("%T", s)] = reflect.TypeOf(s)
+	}
+	return func(typ string, value json.RawMessage) (ProviderOption, error) {
+		t, ok := types[typ]
+		if !ok {
+			return nil, NewGrailError(InvalidArgument, fmt.Sprintf("unknown provider option type %s", typ))
+		}
+		ptr := t.Kind() == reflect.Pointer
+		if ptr {
+			t = t.Elem()
+		}
+		v := reflect.New(t)
+		if err := json.Unmarshal(value, v.Interface()); err != nil {
+			return nil, NewGrailError(InvalidArgument, fmt.Sprintf("decode provider option %s: %v", typ, err)).WithCause(err)
+		}
+		if ptr {
+			return v.Interface().(ProviderOption), nil
+		}
+		return v.Elem().Interface().(ProviderOption), nil
+	}
+}
+
+// Request rebuilds the request. Provider options are decoded with options,
+// which may be nil if the record has none.
+func (r RequestRecord) Request(options OptionDecoder) (Request, error) {
+	req := Request{
+		Inputs:   recordInputs(r.Inputs),
+		Model:    r.Model,
+		Tier:     r.Tier,
+		Priority: r.Priority,
+		Metadata: copyMetadata(r.Metadata),
+		Tools:    r.Tools,
+	}
+	for _, m := range r.History {
+		req.History = append(req.History, Message{Role: m.Role, Inputs: recordInputs(m.Inputs)})
+	}
+	var err error
+	if req.Output, err = r.Output.output(); err != nil {
+		return Request{}, err
+	}
+	if len(r.Options) > 0 && options == nil {
+		return Request{}, NewGrailError(InvalidArgument, "request record has provider options; decode them with an OptionDecoder")
+	}
+	for _, or := range r.Options {
+		opt, err := options(or.Type, or.Value)
+		if err != nil {
+			return Request{}, err
+		}
+		req.ProviderOptions = append(req.ProviderOptions, opt)
+	}
+	return req, nil
+}
+
+// readStreamedInputs replaces streamed file inputs with the bytes they
+// stream.
+func readStreamedInputs(inputs []Input) ([]Input, error) {
+	out, copied := inputs, false
+	for i, in := range inputs {
+		v, ok := in.(fileReaderInput)
+		if !ok {
+			continue
+		}
+		data, err := io.ReadAll(v.R)
+		if err != nil {
+			return nil, NewGrailError(InvalidArgument, fmt.Sprintf("read input %d: %v", i, err)).WithCause(err)
+		}
+		if !copied {
+			out, copied = append([]Input(nil), inputs...), true
+		}
+		out[i] = fileInput{Data: data, MIME: v.MIME, Name: v.Name, CacheBreakpoint: v.CacheBreakpoint, Droppable: v.Droppable, Priority: v.Priority}
+	}
+	return out, nil
+}
+
+func inputRecords(inputs []Input) ([]RecordPart, error) {
+	parts := make([]RecordPart, 0, len(inputs))
+	for i, in := range inputs {
+		switch v := in.(type) {
+		case textInput:
+			parts = append(parts, RecordPart{Type: "text", Text: v.Text, CacheBreakpoint: v.CacheBreakpoint, Droppable: v.Droppable, Priority: v.Priority})
+		case fileInput:
+			parts = append(parts, RecordPart{Type: "file", Data: v.Data, MIME: v.MIME, Name: v.Name, CacheBreakpoint: v.CacheBreakpoint, Droppable: v.Droppable, Priority: v.Priority})
+		case uploadedFileInput:
+			parts = append(parts, RecordPart{Type: "uploaded_file", FileID: v.ID, MIME: v.MIME, Name: v.Name, Size: v.Size, CacheBreakpoint: v.CacheBreakpoint, Droppable: v.Droppable, Priority: v.Priority})
+		case toolResultInput:
+			parts = append(parts, RecordPart{Type: "tool_result", CallID: v.Call.ID, Name: v.Call.Name, JSON: v.Call.Arguments, Data: v.Call.Signature, Text: v.Output})
+		default:
+			return nil, NewGrailError(InvalidArgument, fmt.Sprintf("input %d: can't record %T", i, in))
+		}
+	}
+	return parts, nil
+}
+
+func recordInputs(parts []RecordPart) []Input {
+	inputs := make([]Input, 0, len(parts))
+	for _, p := range parts {
+		switch p.Type {
+		case "text":
+			inputs = append(inputs, textInput{Text: p.Text, CacheBreakpoint: p.CacheBreakpoint, Droppable: p.Droppable, Priority: p.Priority})
+		case "file":
+			inputs = append(inputs, fileInput{Data: p.Data, MIME: p.MIME, Name: p.Name, CacheBreakpoint: p.CacheBreakpoint, Droppable: p.Droppable, Priority: p.Priority})
+		case "uploaded_file":
+			inputs = append(inputs, uploadedFileInput{ID: p.FileID, MIME: p.MIME, Name: p.Name, Size: p.Size, CacheBreakpoint: p.CacheBreakpoint, Droppable: p.Droppable, Priority: p.Priority})
+		case "tool_result":
+			inputs = append(inputs, toolResultInput{Call: ToolCall{ID: p.CallID, Name: p.Name, Arguments: p.JSON, Signature: p.Data}, Output: p.Text})
+		}
+	}
+	return inputs
+}
+
+func outputRecord(out Output) (OutputRecord, error) {
+	switch v := out.(type) {
+	case textOutput:
+		return OutputRecord{Type: "text", Enum: v.Enum, Pattern: v.Pattern}, nil
+	case jsonOutput:
+		rec := OutputRecord{Type: "json", Strict: v.Strict, Confidence: v.Confidence}
+		if v.Schema != nil {
+			schema, err := json.Marshal(v.Schema)
+			if err != nil {
+				return OutputRecord{}, NewGrailError(InvalidArgument, fmt.Sprintf("encode JSON schema: %v", err)).WithCause(err)
+			}
+			rec.Schema = schema
+		}
+		return rec, nil
+	case imageOutput:
+		return OutputRecord{Type: "image", Image: &v.Spec}, nil
+	case videoOutput:
+		return OutputRecord{Type: "video", Video: &v.Spec}, nil
+	case nil:
+		return OutputRecord{}, nil
+	}
+	return OutputRecord{}, NewGrailError(InvalidArgument, fmt.Sprintf("can't record output %T", out))
+}
+
+func (o OutputRecord) output() (Output, error) {
+	switch o.Type {
+	case "text":
+		return textOutput{Enum: o.Enum, Pattern: o.Pattern}, nil
+	case "json":
+		out := jsonOutput{Strict: o.Strict, Confidence: o.Confidence}
+		if len(o.Schema) > 0 {
+			var schema map[string]any
+			if err := json.Unmarshal(o.Schema, &schema); err != nil {
+				return nil, NewGrailError(InvalidArgument, fmt.Sprintf("decode JSON schema: %v", err)).WithCause(err)
+			}
+			out.Schema = schema
+		}
+		return out, nil
+	case "image":
+		if o.Image == nil {
+			return imageOutput{}, nil
+		}
+		return imageOutput{Spec: *o.Image}, nil
+	case "video":
+		if o.Video == nil {
+			return videoOutput{}, nil
+		}
+		return videoOutput{Spec: *o.Video}, nil
+	case "":
+		return nil, nil
+	}
+	return nil, NewGrailError(InvalidArgument, fmt.Sprintf("unknown output type %q", o.Type))
+}
+
+func optionRecord(opt ProviderOption) (OptionRecord, error) {
+	typ := fmt.Sprintf("%T", opt)
+	t := reflect.TypeOf(opt)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return OptionRecord{}, NewGrailError(InvalidArgument, fmt.Sprintf("provider option %s can't be recorded; use a struct option", typ))
+	}
+	for i := range t.NumField() {
+		if !t.Field(i).IsExported() {
+			return OptionRecord{}, NewGrailError(InvalidArgument, fmt.Sprintf("provider option %s has unexported fields and can't be recorded", typ))
+		}
+	}
+	value, err := json.Marshal(opt)
+	if err != nil {
+		return OptionRecord{}, NewGrailError(InvalidArgument, fmt.Sprintf("encode provider option %s: %v", typ, err)).WithCause(err)
+	}
+	return OptionRecord{Type: typ, Value: value}, nil
+}
+
+// NewResponseRecord records res, with the request that produced it when it
+// can be recorded.
+func NewResponseRecord(res Response) ResponseRecord {
+	rr := ResponseRecord{
+		Usage:     res.Usage,
+		Cost:      res.Cost,
+		Provider:  res.Provider,
+		RequestID: res.RequestID,
+		Warnings:  res.Warnings,
+		Request:   res.requestRecord(),
+	}
+	for _, out := range res.Outputs {
+		switch v := out.(type) {
+		case textOutputPart:
+			rr.Outputs = append(rr.Outputs, RecordPart{Type: "text", Text: v.Text})
+		case jsonOutputPart:
+			rr.Outputs = append(rr.Outputs, RecordPart{Type: "json", JSON: json.RawMessage(v.JSON), Confidence: v.Confidence})
+		case imageOutputPart:
+			rr.Outputs = append(rr.Outputs, RecordPart{Type: "image", Data: v.Data, MIME: v.MIME, Name: v.Name, SynthID: v.SynthID})
+		case videoOutputPart:
+			rr.Outputs = append(rr.Outputs, RecordPart{Type: "video", Data: v.Data, MIME: v.MIME, Name: v.Name, SynthID: v.SynthID})
+		case toolCallOutputPart:
+			rr.Outputs = append(rr.Outputs, RecordPart{Type: "tool_call", CallID: v.Call.ID, Name: v.Call.Name, JSON: v.Call.Arguments, Data: v.Call.Signature})
+		}
+	}
+	return rr
+}
+
+// Response rebuilds the response.
+func (r ResponseRecord) Response() Response {
+	res := Response{
+		Usage:     r.Usage,
+		Cost:      r.Cost,
+		Provider:  r.Provider,
+		RequestID: r.RequestID,
+		Warnings:  r.Warnings,
+		recorded:  r.Request,
+	}
+	for _, p := range r.Outputs {
+		switch p.Type {
+		case "text":
+			res.Outputs = append(res.Outputs, textOutputPart{Text: p.Text})
+		case "json":
+			res.Outputs = append(res.Outputs, jsonOutputPart{JSON: []byte(p.JSON), Confidence: p.Confidence})
+		case "image":
+			res.Outputs = append(res.Outputs, imageOutputPart{Data: p.Data, MIME: p.MIME, Name: p.Name, SynthID: p.SynthID})
+		case "video":
+			res.Outputs = append(res.Outputs, videoOutputPart{Data: p.Data, MIME: p.MIME, Name: p.Name, SynthID: p.SynthID})
+		case "tool_call":
+			res.Outputs = append(res.Outputs, toolCallOutputPart{Call: ToolCall{ID: p.CallID, Name: p.Name, Arguments: p.JSON, Signature: p.Data}})
+		}
+	}
+	return res
+}
+
+// ResultStore keeps completed activity results by request key, so a retried
+// activity whose earlier attempt finished generating (but didn't report it,
+// say because its worker died) doesn't generate and pay again.
+type ResultStore interface {
+	Get(ctx context.Context, key string) (res ResponseRecord, ok bool, err error)
+	Put(ctx context.Context, key string, res ResponseRecord) error
+}
+
+// WarningResultNotStored is set on an activity's response when its
+// ResultStore failed to store it.
+const WarningResultNotStored = "result_not_stored"
+
+// Activity stages reported to heartbeats.
+const (
+	ActivityStarted = "started"
+	ActivityRunning = "running"
+	ActivityReused  = "reused" // the result was found in the ResultStore
+	ActivityDone    = "done"
+)
+
+// ActivityProgress is a heartbeat's details.
+type ActivityProgress struct {
+	Key     string        `json:"key"`
+	Stage   string        `json:"stage"`
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// Activity runs request records as durable workflow activities:
+//
+//	act := grail.Activity{
+//		Client:  client,
+//		Options: grail.ProviderOptionTypes(openai.TextOptions{}),
+//		Heartbeat: func(ctx context.Context, p grail.ActivityProgress) {
+//			activity.RecordHeartbeat(ctx, p)
+//		},
+//	}
+//	worker.RegisterActivityWithOptions(act.Generate, activity.RegisterOptions{Name: "grail.Generate"})
+//
+// Workflows build a RequestRecord with NewRequestRecord, execute the activity
+// with it, and rebuild its ResponseRecord with Response. Errors are returned
+// as the client returned them; map the ones grail.IsRetryable rejects to the
+// engine's non-retryable errors to stop it retrying them.
+type Activity struct {
+	Client Client
+	// Options decodes the records' provider options.
+	Options OptionDecoder
+	// Results, if set, makes the activity idempotent: a record whose key
+	// already has a result is answered from the store.
+	Results ResultStore
+	// Heartbeat is called when the activity starts, every
+	// HeartbeatInterval while the request runs (long for video generation),
+	// and when it's done.
+	Heartbeat         func(ctx context.Context, p ActivityProgress)
+	HeartbeatInterval time.Duration // default 10s
+}
+
+// Generate runs the request rec records.
+func (a Activity) Generate(ctx context.Context, rec RequestRecord) (ResponseRecord, error) {
+	req, err := rec.Request(a.Options)
+	if err != nil {
+		return ResponseRecord{}, err
+	}
+	key := rec.Key
+	if key == "" {
+		key = requestHash(req)
+	}
+	start := time.Now()
+	beat := func(stage string) {
+		if a.Heartbeat != nil {
+			a.Heartbeat(ctx, ActivityProgress{Key: key, Stage: stage, Elapsed: time.Since(start)})
+		}
+	}
+
+	if a.Results != nil {
+		res, ok, err := a.Results.Get(ctx, key)
+		if err != nil {
+			return ResponseRecord{}, err
+		}
+		if ok {
+			beat(ActivityReused)
+			return res, nil
+		}
+	}
+
+	beat(ActivityStarted)
+	interval := a.HeartbeatInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	if a.Heartbeat != nil {
+		wg.Go(func() {
+			t := time.NewTicker(interval)
+			defer t.Stop()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-t.C:
+					beat(ActivityRunning)
+				}
+			}
+		})
+	}
+	res, err := a.Client.Generate(ctx, req)
+	close(stop)
+	wg.Wait()
+	if err != nil {
+		return ResponseRecord{}, err
+	}
+
+	out := NewResponseRecord(res)
+	if a.Results != nil {
+		if err := a.Results.Put(ctx, key, out); err != nil {
+			out.Warnings = append(out.Warnings, Warning{Code: WarningResultNotStored, Message: err.Error()})
+		}
+	}
+	beat(ActivityDone)
+	return out, nil
+}
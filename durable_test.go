@@ -0,0 +1,141 @@
+package grail_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+type recordOption struct {
+	Effort string
+	Store  *bool
+}
+
+func (recordOption) ApplyProviderOption() {}
+
+type opaqueOption struct{ effort string }
+
+func (opaqueOption) ApplyProviderOption() {}
+
+func TestRequestRecord(t *testing.T) {
+	store := true
+	req := grail.Request{
+		History: []grail.Message{grail.UserMessage(grail.InputText("hi")), grail.AssistantMessage("hello")},
+		Inputs: []grail.Input{
+			grail.InputText("Describe this file.", grail.WithCacheBreakpoint()),
+			grail.InputFileReader(bytes.NewReader([]byte("%PDF")), 4, "application/pdf", grail.WithFileName("a.pdf")),
+		},
+		Output:          grail.OutputJSON(map[string]any{"type": "object"}),
+		Tier:            grail.ModelTierFast,
+		Priority:        grail.PriorityBackground,
+		ProviderOptions: []grail.ProviderOption{recordOption{Effort: "low", Store: &store}},
+		Metadata:        map[string]string{"job": "42"},
+	}
+	rec, err := grail.NewRequestRecord(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded grail.RequestRecord
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := decoded.Request(nil); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected options to need a decoder, got %v", err)
+	}
+	got, err := decoded.Request(grail.ProviderOptionTypes(recordOption{}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, mime, name, ok := grail.AsFileInput(got.Inputs[1]); !ok || string(data) != "%PDF" || mime != "application/pdf" || name != "a.pdf" {
+		t.Errorf("expected the streamed file to be recorded, got %q %q", data, mime)
+	}
+	if !grail.IsCacheBreakpoint(got.Inputs[0]) || len(got.History) != 2 || got.Priority != grail.PriorityBackground {
+		t.Errorf("unexpected request %+v", got)
+	}
+	if opt, ok := got.ProviderOptions[0].(recordOption); !ok || opt.Effort != "low" || opt.Store == nil || !*opt.Store {
+		t.Errorf("unexpected provider option %#v", got.ProviderOptions[0])
+	}
+	if grail.RequestKey(got) != rec.Key {
+		t.Errorf("expected the rebuilt request to keep its key")
+	}
+	if again, _ := grail.NewRequestRecord(got); again.Key != rec.Key {
+		t.Errorf("expected equal requests to get equal keys")
+	}
+
+	req.ProviderOptions = []grail.ProviderOption{opaqueOption{"low"}}
+	if _, err := grail.NewRequestRecord(req); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected an option with unexported fields to be rejected, got %v", err)
+	}
+}
+
+type memoryResults map[string]grail.ResponseRecord
+
+func (m memoryResults) Get(ctx context.Context, key string) (grail.ResponseRecord, bool, error) {
+	res, ok := m[key]
+	return res, ok, nil
+}
+
+func (m memoryResults) Put(ctx context.Context, key string, res grail.ResponseRecord) error {
+	m[key] = res
+	return nil
+}
+
+func TestActivity(t *testing.T) {
+	var calls atomic.Int32
+	mp := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			calls.Add(1)
+			time.Sleep(30 * time.Millisecond)
+			return grail.Response{
+				Outputs: []grail.OutputPart{grail.NewTextOutputPart("done")},
+				Usage:   grail.Usage{InputTokens: 3, OutputTokens: 1, TotalTokens: 4},
+			}, nil
+		},
+	}
+	var stages []string
+	act := grail.Activity{
+		Client:            grail.NewClient(mp),
+		Results:           memoryResults{},
+		HeartbeatInterval: 5 * time.Millisecond,
+		Heartbeat: func(ctx context.Context, p grail.ActivityProgress) {
+			if len(stages) == 0 || stages[len(stages)-1] != p.Stage {
+				stages = append(stages, p.Stage)
+			}
+		},
+	}
+	rec, err := grail.NewRequestRecord(grail.Request{Inputs: []grail.Input{grail.InputText("go")}, Output: grail.OutputText()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out, err := act.Generate(context.Background(), rec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text, _ := out.Response().Text(); text != "done" || out.Usage.TotalTokens != 4 {
+		t.Errorf("unexpected response %+v", out)
+	}
+	want := []string{grail.ActivityStarted, grail.ActivityRunning, grail.ActivityDone}
+	if len(stages) != len(want) || stages[0] != want[0] || stages[1] != want[1] || stages[2] != want[2] {
+		t.Errorf("expected heartbeats %q, got %q", want, stages)
+	}
+
+	// A retried activity is answered from the result store.
+	stages = nil
+	if _, err := act.Generate(context.Background(), rec); err != nil || calls.Load() != 1 {
+		t.Errorf("expected the stored result to be reused, got %d calls (%v)", calls.Load(), err)
+	}
+	if len(stages) != 1 || stages[0] != grail.ActivityReused {
+		t.Errorf("expected a reused heartbeat, got %q", stages)
+	}
+}
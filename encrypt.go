@@ -0,0 +1,173 @@
+package grail
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+)
+
+//
+// Encryption at rest
+//
+
+// KeyProvider supplies AES keys for encrypting persisted requests and
+// responses. Keys are 16, 24, or 32 bytes (AES-128, AES-192, or AES-256) and
+// identified by an ID stored with the ciphertext, so keys can be rotated:
+// new data is sealed with the current key and older data opens with the key
+// it names. Implementations may fetch keys from a KMS or secret store; they
+// should cache them, as they're asked for a key on every write.
+//
+// These persist prompts and responses encrypted under a KeyProvider: batch
+// journals (WithJournalEncryption), result files (WithResultEncryption),
+// manifest sidecars (WithManifestEncryption), HTTPCache and SemanticCache
+// entries and BlobResultStore results (their Keys fields), and the
+// stores/sqlite and stores/postgres stores (their WithEncryption options).
+// Other persisted data is written as given: attachments in an
+// AttachmentStore, audit log entries, saved outputs, and queue messages. Seal
+// them where they need it.
+type KeyProvider interface {
+	// EncryptionKey returns the current key and its ID.
+	EncryptionKey() (id string, key []byte, err error)
+	// DecryptionKey returns the key with the given ID.
+	DecryptionKey(id string) ([]byte, error)
+}
+
+// KeyRing is a KeyProvider holding its keys in memory.
+type KeyRing struct {
+	Current string            // ID of the key to encrypt with
+	Keys    map[string][]byte // keys by ID, including retired ones still needed to decrypt
+}
+
+// StaticKey returns a KeyProvider with a single key, with ID "default".
+func StaticKey(key []byte) KeyProvider {
+	return KeyRing{Current: "default", Keys: map[string][]byte{"default": key}}
+}
+
+func (r KeyRing) EncryptionKey() (string, []byte, error) {
+	key, err := r.DecryptionKey(r.Current)
+	return r.Current, key, err
+}
+
+func (r KeyRing) DecryptionKey(id string) ([]byte, error) {
+	key, ok := r.Keys[id]
+	if !ok {
+		return nil, NewGrailError(InvalidArgument, fmt.Sprintf("unknown encryption key %q", id))
+	}
+	return key, nil
+}
+
+// sealMagic starts every sealed message, versioning the format:
+//
+//	magic | id length (1 byte) | key id | nonce (12 bytes) | AES-GCM ciphertext
+var sealMagic = []byte("GRL1")
+
+// Seal encrypts plaintext with AES-GCM under kp's current key. aad is
+// authenticated but not encrypted; Open must be given the same aad, which
+// binds the ciphertext to its context (say, a file name) so it can't be
+// swapped for another.
+func Seal(kp KeyProvider, plaintext, aad []byte) ([]byte, error) {
+	id, key, err := kp.EncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(id) > 255 {
+		return nil, NewGrailError(InvalidArgument, "encryption key ID is longer than 255 bytes")
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(sealMagic)+1+len(id)+gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	out = append(out, sealMagic...)
+	out = append(out, byte(len(id)))
+	out = append(out, id...)
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, NewGrailError(Internal, fmt.Sprintf("generate nonce: %v", err)).WithCause(err)
+	}
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plaintext, aad), nil
+}
+
+// Open decrypts a message sealed by Seal, looking up the key it was sealed
+// with in kp. It fails if the message was modified or aad differs.
+func Open(kp KeyProvider, sealed, aad []byte) ([]byte, error) {
+	rest, ok := bytes.CutPrefix(sealed, sealMagic)
+	if !ok || len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+		return nil, NewGrailError(InvalidArgument, "not a sealed message")
+	}
+	id, rest := string(rest[1:1+int(rest[0])]), rest[1+int(rest[0]):]
+	key, err := kp.DecryptionKey(id)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < gcm.NonceSize() {
+		return nil, NewGrailError(InvalidArgument, "sealed message is truncated")
+	}
+	plaintext, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], aad)
+	if err != nil {
+		return nil, NewGrailError(InvalidArgument, fmt.Sprintf("decrypt with key %q: %v", id, err)).WithCause(err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, NewGrailError(InvalidArgument, fmt.Sprintf("invalid encryption key: %v", err)).WithCause(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, NewGrailError(Internal, fmt.Sprintf("init AES-GCM: %v", err)).WithCause(err)
+	}
+	return gcm, nil
+}
+
+// sealedRecord is an encrypted JSON record as persisted: the record's JSON,
+// sealed.
+type sealedRecord struct {
+	Sealed []byte `json:"sealed"`
+}
+
+// sealJSON encodes v as JSON, sealed under keys with aad if keys is set.
+func sealJSON(keys KeyProvider, v any, aad string) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || keys == nil {
+		return data, err
+	}
+	sealed, err := Seal(keys, data, []byte(aad))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(sealedRecord{Sealed: sealed})
+}
+
+// openJSON decodes data, as written by sealJSON, into v. With keys set, data
+// must be sealed, so a plaintext record put in its place is refused; without
+// them, it must not be.
+func openJSON(keys KeyProvider, data []byte, v any, aad string) error {
+	var rec sealedRecord
+	sealed := json.Unmarshal(data, &rec) == nil && rec.Sealed != nil
+	switch {
+	case keys == nil && sealed:
+		return NewGrailError(InvalidArgument, "record is encrypted")
+	case keys != nil && !sealed:
+		return NewGrailError(InvalidArgument, "record is not encrypted")
+	case keys != nil:
+		var err error
+		if data, err = Open(keys, rec.Sealed, []byte(aad)); err != nil {
+			return err
+		}
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return NewGrailError(InvalidArgument, fmt.Sprintf("decode record: %v", err)).WithCause(err)
+	}
+	return nil
+}
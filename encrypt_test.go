@@ -0,0 +1,100 @@
+package grail_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestSealOpen(t *testing.T) {
+	old := bytes.Repeat([]byte{1}, 32)
+	ring := grail.KeyRing{Current: "k1", Keys: map[string][]byte{"k1": old}}
+	sealed, err := grail.Seal(ring, []byte("a secret prompt"), []byte("ctx"))
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if bytes.Contains(sealed, []byte("secret")) {
+		t.Fatal("sealed message contains plaintext")
+	}
+
+	// Rotating keys keeps old messages readable.
+	ring = grail.KeyRing{Current: "k2", Keys: map[string][]byte{"k1": old, "k2": bytes.Repeat([]byte{2}, 16)}}
+	if got, err := grail.Open(ring, sealed, []byte("ctx")); err != nil || string(got) != "a secret prompt" {
+		t.Fatalf("open: %q, %v", got, err)
+	}
+	if _, err := grail.Open(ring, sealed, []byte("other")); err == nil {
+		t.Fatal("expected a different aad to fail")
+	}
+	sealed[len(sealed)-1] ^= 1
+	if _, err := grail.Open(ring, sealed, []byte("ctx")); err == nil {
+		t.Fatal("expected a modified message to fail")
+	}
+	if _, err := grail.Seal(grail.StaticKey([]byte("short")), nil, nil); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Fatalf("expected an invalid key to fail, got %v", err)
+	}
+}
+
+func TestJournalEncryption(t *testing.T) {
+	calls := 0
+	prov := &mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		calls++
+		return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("the confidential answer")}}, nil
+	}}
+	reqs := []grail.Request{{Inputs: []grail.Input{grail.InputText("the confidential question")}, Output: grail.OutputText()}}
+	path := filepath.Join(t.TempDir(), "batch.journal")
+	keys := grail.StaticKey(bytes.Repeat([]byte{7}, 32))
+
+	j, err := grail.OpenJournal(path, grail.WithJournalEncryption(keys))
+	if err != nil {
+		t.Fatalf("open journal: %v", err)
+	}
+	grail.GenerateBatch(context.Background(), grail.NewClient(prov), reqs, grail.BatchOptions{Journal: j})
+	j.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("confidential")) {
+		t.Fatalf("journal contains plaintext: %s", data)
+	}
+	if _, err := grail.OpenJournal(path); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Fatalf("expected opening without keys to fail, got %v", err)
+	}
+
+	j, err = grail.OpenJournal(path, grail.WithJournalEncryption(keys))
+	if err != nil {
+		t.Fatalf("reopen journal: %v", err)
+	}
+	defer j.Close()
+	results := grail.GenerateBatch(context.Background(), grail.NewClient(prov), reqs, grail.BatchOptions{Journal: j})
+	if text, _ := results[0].Response.Text(); !results[0].Resumed || text != "the confidential answer" || calls != 1 {
+		t.Fatalf("expected the result to resume from the journal, got %+v after %d calls", results[0], calls)
+	}
+
+	// Records are bound to their journal's path.
+	moved := filepath.Join(t.TempDir(), "batch.journal")
+	if err := os.WriteFile(moved, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := grail.OpenJournal(moved, grail.WithJournalEncryption(keys)); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Fatalf("expected records moved to another journal to fail, got %v", err)
+	}
+
+	// A plaintext record can't be slipped into an encrypted journal.
+	plain := filepath.Join(t.TempDir(), "plain.journal")
+	pj, err := grail.OpenJournal(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grail.GenerateBatch(context.Background(), grail.NewClient(prov), reqs, grail.BatchOptions{Journal: pj})
+	pj.Close()
+	if _, err := grail.OpenJournal(plain, grail.WithJournalEncryption(keys)); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Fatalf("expected plaintext records to fail with keys, got %v", err)
+	}
+}
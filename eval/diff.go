@@ -0,0 +1,237 @@
+package eval
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/internal/imaging"
+)
+
+// ResponseDiff is a machine-readable comparison of two responses' outputs.
+// Sections are present only when at least one response has that kind of
+// output.
+type ResponseDiff struct {
+	// Identical is true when every output is byte-for-byte equal.
+	Identical bool        `json:"identical"`
+	Text      *TextDiff   `json:"text,omitempty"`
+	JSON      *JSONDiff   `json:"json,omitempty"`
+	Images    []ImageDiff `json:"images,omitempty"`
+}
+
+// TextDiff compares text outputs.
+type TextDiff struct {
+	// Similarity is the word-level similarity from 0 (nothing in common) to
+	// 1 (same words in the same order).
+	Similarity float64 `json:"similarity"`
+	LengthA    int     `json:"length_a"`
+	LengthB    int     `json:"length_b"`
+}
+
+// JSONDiff compares JSON outputs structurally.
+type JSONDiff struct {
+	Equal   bool         `json:"equal"` // semantically equal, ignoring formatting and key order
+	Changes []JSONChange `json:"changes,omitempty"`
+}
+
+// JSONChange is one difference between two JSON documents.
+type JSONChange struct {
+	Path string `json:"path"` // e.g. "$.items[2].name"
+	Op   string `json:"op"`   // "added", "removed", or "changed"
+	A    any    `json:"a,omitempty"`
+	B    any    `json:"b,omitempty"`
+}
+
+// ImageDiff compares the images at the same position in both responses.
+type ImageDiff struct {
+	Index int `json:"index"`
+	// Op is "added" or "removed" when only one response has an image at
+	// Index, and "compared" otherwise.
+	Op        string `json:"op"`
+	SameBytes bool   `json:"same_bytes"`
+	// Distance is the Hamming distance between the images' perceptual
+	// hashes, from 0 (visually the same) to 64, or -1 if either image
+	// couldn't be decoded.
+	Distance int `json:"distance"`
+	// Similarity is 1 - Distance/64, or 0 when Distance is -1.
+	Similarity float64 `json:"similarity"`
+}
+
+// Diff compares the outputs of a and b: text by word similarity, JSON by
+// structure, and images by perceptual hash distance.
+func Diff(a, b grail.Response) ResponseDiff {
+	d := ResponseDiff{Identical: true}
+
+	textA, okA := a.Text()
+	textB, okB := b.Text()
+	if okA || okB {
+		d.Text = &TextDiff{
+			Similarity: TextSimilarity(textA, textB),
+			LengthA:    len(textA),
+			LengthB:    len(textB),
+		}
+		d.Identical = d.Identical && okA == okB && textA == textB
+	}
+
+	jsonA, okA := jsonOutput(a)
+	jsonB, okB := jsonOutput(b)
+	if okA || okB {
+		d.JSON = diffJSON(jsonA, jsonB)
+		d.Identical = d.Identical && okA == okB && bytes.Equal(jsonA, jsonB)
+	}
+
+	imagesA, imagesB := a.ImageOutputs(), b.ImageOutputs()
+	for i := 0; i < max(len(imagesA), len(imagesB)); i++ {
+		switch {
+		case i >= len(imagesA):
+			d.Images = append(d.Images, ImageDiff{Index: i, Op: "added", Distance: -1})
+			d.Identical = false
+		case i >= len(imagesB):
+			d.Images = append(d.Images, ImageDiff{Index: i, Op: "removed", Distance: -1})
+			d.Identical = false
+		default:
+			id := diffImage(imagesA[i].Data, imagesB[i].Data)
+			id.Index = i
+			d.Images = append(d.Images, id)
+			d.Identical = d.Identical && id.SameBytes
+		}
+	}
+	return d
+}
+
+func jsonOutput(r grail.Response) (json.RawMessage, bool) {
+	var raw json.RawMessage
+	if err := r.DecodeJSON(&raw); err != nil {
+		return nil, false
+	}
+	return raw, true
+}
+
+func diffImage(a, b []byte) ImageDiff {
+	d := ImageDiff{Op: "compared", SameBytes: bytes.Equal(a, b), Distance: -1}
+	if d.SameBytes {
+		d.Distance, d.Similarity = 0, 1
+		return d
+	}
+	ha, errA := imaging.DHash(a)
+	hb, errB := imaging.DHash(b)
+	if errA == nil && errB == nil {
+		d.Distance = imaging.Distance(ha, hb)
+		d.Similarity = 1 - float64(d.Distance)/64
+	}
+	return d
+}
+
+// TextSimilarity returns the word-level similarity of a and b from 0 to 1:
+// twice the length of their longest common word subsequence divided by their
+// total word count. Two empty texts are fully similar.
+func TextSimilarity(a, b string) float64 {
+	wa, wb := strings.Fields(a), strings.Fields(b)
+	if len(wa)+len(wb) == 0 {
+		return 1
+	}
+	// Longest common subsequence over words, one row at a time.
+	prev := make([]int, len(wb)+1)
+	cur := make([]int, len(wb)+1)
+	for i := range wa {
+		for j := range wb {
+			switch {
+			case wa[i] == wb[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] >= cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return 2 * float64(prev[len(wb)]) / float64(len(wa)+len(wb))
+}
+
+// diffJSON compares two JSON documents. A missing or unparseable side is
+// treated as absent.
+func diffJSON(a, b json.RawMessage) *JSONDiff {
+	va, okA := decodeJSON(a)
+	vb, okB := decodeJSON(b)
+	var changes []JSONChange
+	switch {
+	case okA && okB:
+		changes = compareJSON("$", va, vb, nil)
+	case okA:
+		changes = []JSONChange{{Path: "$", Op: "removed", A: va}}
+	case okB:
+		changes = []JSONChange{{Path: "$", Op: "added", B: vb}}
+	}
+	return &JSONDiff{Equal: len(changes) == 0 && okA == okB, Changes: changes}
+}
+
+func decodeJSON(data json.RawMessage) (any, bool) {
+	if len(data) == 0 {
+		return nil, false
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+func compareJSON(path string, a, b any, changes []JSONChange) []JSONChange {
+	switch va := a.(type) {
+	case map[string]any:
+		vb, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(va)+len(vb))
+		for k := range va {
+			keys = append(keys, k)
+		}
+		for k := range vb {
+			if _, ok := va[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := path + "." + k
+			x, inA := va[k]
+			y, inB := vb[k]
+			switch {
+			case !inB:
+				changes = append(changes, JSONChange{Path: p, Op: "removed", A: x})
+			case !inA:
+				changes = append(changes, JSONChange{Path: p, Op: "added", B: y})
+			default:
+				changes = compareJSON(p, x, y, changes)
+			}
+		}
+		return changes
+	case []any:
+		vb, ok := b.([]any)
+		if !ok {
+			break
+		}
+		for i := 0; i < max(len(va), len(vb)); i++ {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(vb):
+				changes = append(changes, JSONChange{Path: p, Op: "removed", A: va[i]})
+			case i >= len(va):
+				changes = append(changes, JSONChange{Path: p, Op: "added", B: vb[i]})
+			default:
+				changes = compareJSON(p, va[i], vb[i], changes)
+			}
+		}
+		return changes
+	default:
+		if a == b {
+			return changes
+		}
+	}
+	return append(changes, JSONChange{Path: path, Op: "changed", A: a, B: b})
+}
@@ -0,0 +1,99 @@
+package eval_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/eval"
+)
+
+// gradientPNG draws a horizontal gradient, brightening to the right unless
+// reversed, offset by bias.
+func gradientPNG(t *testing.T, reversed bool, bias uint8) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 64, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 64; x++ {
+			v := x * 3
+			if reversed {
+				v = (63 - x) * 3
+			}
+			img.SetGray(x, y, color.Gray{Y: uint8(v) + bias})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDiff(t *testing.T) {
+	a := grail.Response{Outputs: []grail.OutputPart{
+		grail.NewTextOutputPart("the quick brown fox"),
+		grail.NewImageOutputPart(gradientPNG(t, false, 0), "image/png", ""),
+		grail.NewImageOutputPart(gradientPNG(t, false, 0), "image/png", ""),
+	}}
+	b := grail.Response{Outputs: []grail.OutputPart{
+		grail.NewTextOutputPart("the quick red fox"),
+		grail.NewImageOutputPart(gradientPNG(t, false, 10), "image/png", ""),
+		grail.NewImageOutputPart(gradientPNG(t, true, 0), "image/png", ""),
+		grail.NewImageOutputPart(gradientPNG(t, true, 0), "image/png", ""),
+	}}
+
+	d := eval.Diff(a, b)
+	if d.Identical {
+		t.Fatalf("expected differences")
+	}
+	if d.Text == nil || d.Text.Similarity != 0.75 {
+		t.Fatalf("expected text similarity 0.75, got %+v", d.Text)
+	}
+	if len(d.Images) != 3 {
+		t.Fatalf("expected 3 image diffs, got %+v", d.Images)
+	}
+	if img := d.Images[0]; img.SameBytes || img.Distance != 0 {
+		t.Fatalf("brightened image should hash the same: %+v", img)
+	}
+	if img := d.Images[1]; img.Distance < 32 {
+		t.Fatalf("mirrored image should be far apart: %+v", img)
+	}
+	if d.Images[2].Op != "added" {
+		t.Fatalf("expected added image, got %+v", d.Images[2])
+	}
+
+	if !eval.Diff(a, a).Identical {
+		t.Fatalf("a response should be identical to itself")
+	}
+}
+
+func TestDiffJSON(t *testing.T) {
+	a := grail.Response{Outputs: []grail.OutputPart{grail.NewJSONOutputPart([]byte(`{"name":"grail","tags":["go","ai"],"v":1}`))}}
+	b := grail.Response{Outputs: []grail.OutputPart{grail.NewJSONOutputPart([]byte(`{"tags":["go"],"v":2,"new":true,"name":"grail"}`))}}
+
+	d := eval.Diff(a, b)
+	if d.JSON == nil || d.JSON.Equal {
+		t.Fatalf("expected JSON differences, got %+v", d.JSON)
+	}
+	want := []eval.JSONChange{
+		{Path: "$.new", Op: "added", B: true},
+		{Path: "$.tags[1]", Op: "removed", A: "ai"},
+		{Path: "$.v", Op: "changed", A: 1.0, B: 2.0},
+	}
+	if len(d.JSON.Changes) != len(want) {
+		t.Fatalf("expected %d changes, got %+v", len(want), d.JSON.Changes)
+	}
+	for i, c := range d.JSON.Changes {
+		if c != want[i] {
+			t.Fatalf("change %d: got %+v, want %+v", i, c, want[i])
+		}
+	}
+
+	reordered := grail.Response{Outputs: []grail.OutputPart{grail.NewJSONOutputPart([]byte(`{"v":1, "tags":["go","ai"], "name":"grail"}`))}}
+	if d := eval.Diff(a, reordered); !d.JSON.Equal || d.Identical {
+		t.Fatalf("reordered JSON should be equal but not identical: %+v", d)
+	}
+}
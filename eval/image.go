@@ -0,0 +1,197 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/montanaflynn/grail"
+)
+
+// DefaultImageCriteria are used by CompareImages when no criteria are given.
+var DefaultImageCriteria = []string{
+	"adherence to the prompt",
+	"visual quality and absence of artifacts",
+	"composition and aesthetics",
+}
+
+// Preference is a judge's structured verdict on a pair of candidates.
+type Preference struct {
+	Winner     Choice
+	Confidence float64 // 0 to 1, as reported by the judge
+	Rationale  string
+	Criteria   []CriterionVerdict
+
+	// Consistent is false when SwapPositions was set and the two orderings
+	// produced different winners.
+	Consistent bool
+	Usage      grail.Usage
+}
+
+// CriterionVerdict is the winner for a single criterion.
+type CriterionVerdict struct {
+	Criterion string `json:"criterion"`
+	Winner    Choice `json:"winner"`
+	Rationale string `json:"rationale"`
+}
+
+var preferenceSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"winner":     map[string]any{"type": "string", "enum": []string{"a", "b", "tie"}},
+		"confidence": map[string]any{"type": "number"},
+		"rationale":  map[string]any{"type": "string"},
+		"criteria": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"criterion": map[string]any{"type": "string"},
+					"winner":    map[string]any{"type": "string", "enum": []string{"a", "b", "tie"}},
+					"rationale": map[string]any{"type": "string"},
+				},
+				"required": []string{"criterion", "winner", "rationale"},
+			},
+		},
+	},
+	"required": []string{"winner", "confidence", "rationale", "criteria"},
+}
+
+// CompareImages asks the judge which of two images better satisfies the
+// criteria. prompt is the prompt both images were generated from and may be
+// empty. Image A is presented first unless the comparison is swapped.
+func (j Judge) CompareImages(ctx context.Context, prompt string, a, b []byte, criteria ...string) (Preference, error) {
+	if len(a) == 0 || len(b) == 0 {
+		return Preference{}, grail.NewGrailError(grail.InvalidArgument, "both images must be non-empty")
+	}
+	if len(criteria) == 0 {
+		criteria = DefaultImageCriteria
+	}
+	instructions := comparisonInstructions("images", prompt, criteria)
+	return j.compare(ctx, func(first, second []byte) []grail.Input {
+		return []grail.Input{
+			grail.InputText(instructions),
+			grail.InputText("Image A:"),
+			grail.InputImage(first),
+			grail.InputText("Image B:"),
+			grail.InputImage(second),
+		}
+	}, a, b)
+}
+
+// compare runs a pairwise judgment, and its mirror when SwapPositions is set.
+func (j Judge) compare(ctx context.Context, inputs func(first, second []byte) []grail.Input, a, b []byte) (Preference, error) {
+	p, err := j.judgePair(ctx, inputs(a, b))
+	if err != nil || !j.SwapPositions {
+		return p, err
+	}
+	swapped, err := j.judgePair(ctx, inputs(b, a))
+	if err != nil {
+		return Preference{}, err
+	}
+	swapped.Winner = swapped.Winner.swap()
+	for i := range swapped.Criteria {
+		swapped.Criteria[i].Winner = swapped.Criteria[i].Winner.swap()
+	}
+	return mergePreferences(p, swapped), nil
+}
+
+func (j Judge) judgePair(ctx context.Context, inputs []grail.Input) (Preference, error) {
+	res, err := j.generate(ctx, inputs, preferenceSchema)
+	if err != nil {
+		return Preference{}, err
+	}
+	var out struct {
+		Winner     Choice             `json:"winner"`
+		Confidence float64            `json:"confidence"`
+		Rationale  string             `json:"rationale"`
+		Criteria   []CriterionVerdict `json:"criteria"`
+	}
+	if err := res.DecodeJSON(&out); err != nil {
+		return Preference{}, grail.NewGrailError(grail.OutputInvalid, fmt.Sprintf("decode judgment: %v", err)).WithCause(err)
+	}
+	winner, ok := normalizeChoice(out.Winner)
+	if !ok {
+		return Preference{}, grail.NewGrailError(grail.OutputInvalid, fmt.Sprintf("judge returned unknown winner %q", out.Winner))
+	}
+	p := Preference{
+		Winner:     winner,
+		Confidence: clamp01(out.Confidence),
+		Rationale:  out.Rationale,
+		Consistent: true,
+		Usage:      res.Usage,
+	}
+	for _, c := range out.Criteria {
+		if c.Winner, ok = normalizeChoice(c.Winner); ok {
+			p.Criteria = append(p.Criteria, c)
+		}
+	}
+	return p, nil
+}
+
+// mergePreferences combines a judgment with its swapped mirror. Agreeing
+// verdicts average their confidence; disagreeing ones become a tie.
+func mergePreferences(p, swapped Preference) Preference {
+	out := Preference{
+		Winner:     p.Winner,
+		Confidence: (p.Confidence + swapped.Confidence) / 2,
+		Rationale:  p.Rationale + "\n\n(swapped) " + swapped.Rationale,
+		Consistent: p.Winner == swapped.Winner,
+		Usage:      p.Usage.Add(swapped.Usage),
+	}
+	if !out.Consistent {
+		out.Winner = ChoiceTie
+		out.Confidence = 0
+	}
+	mirrored := make(map[string]Choice, len(swapped.Criteria))
+	for _, c := range swapped.Criteria {
+		mirrored[c.Criterion] = c.Winner
+	}
+	for _, c := range p.Criteria {
+		if w, ok := mirrored[c.Criterion]; ok && w != c.Winner {
+			c.Winner = ChoiceTie
+		}
+		out.Criteria = append(out.Criteria, c)
+	}
+	return out
+}
+
+func comparisonInstructions(kind, prompt string, criteria []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "You are an impartial judge comparing two %s, A and B.", kind)
+	if prompt != "" {
+		fmt.Fprintf(&b, " Both were produced for this prompt:\n\n%s\n\n", prompt)
+	} else {
+		b.WriteString(" ")
+	}
+	b.WriteString("Judge them on these criteria:\n")
+	for _, c := range criteria {
+		fmt.Fprintf(&b, "- %s\n", c)
+	}
+	b.WriteString("Do not let the order of presentation influence you. Respond with only a JSON object with the fields " +
+		"winner (\"a\", \"b\", or \"tie\"), confidence (0 to 1), rationale (string), and criteria (array of objects " +
+		"with criterion, winner, and rationale, one per criterion above).")
+	return b.String()
+}
+
+func normalizeChoice(c Choice) (Choice, bool) {
+	switch Choice(strings.ToLower(strings.TrimSpace(string(c)))) {
+	case ChoiceA:
+		return ChoiceA, true
+	case ChoiceB:
+		return ChoiceB, true
+	case ChoiceTie:
+		return ChoiceTie, true
+	}
+	return "", false
+}
+
+func clamp01(f float64) float64 {
+	switch {
+	case f < 0:
+		return 0
+	case f > 1:
+		return 1
+	}
+	return f
+}
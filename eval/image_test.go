@@ -0,0 +1,96 @@
+package eval_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/eval"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func solidPNG(t *testing.T, c color.Color) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCompareImages(t *testing.T) {
+	red := solidPNG(t, color.RGBA{R: 255, A: 255})
+	blue := solidPNG(t, color.RGBA{B: 255, A: 255})
+
+	// judgeFn answers with the winner picked by pick, given the first image shown.
+	judgeFn := func(pick func(first []byte) string) *mock.Provider {
+		return &mock.Provider{
+			GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+				if req.Tier != grail.ModelTierBest {
+					t.Fatalf("expected best tier, got %q", req.Tier)
+				}
+				first, _, _, _ := grail.AsFileInput(req.Inputs[2])
+				w := pick(first)
+				return grail.Response{
+					Outputs: []grail.OutputPart{grail.NewJSONOutputPart([]byte(
+						`{"winner":"` + w + `","confidence":0.8,"rationale":"r","criteria":[{"criterion":"quality","winner":"` + w + `","rationale":"r"}]}`,
+					))},
+					Usage: grail.Usage{InputTokens: 10},
+				}, nil
+			},
+		}
+	}
+
+	t.Run("consistent preference survives swapping", func(t *testing.T) {
+		prefersRed := judgeFn(func(first []byte) string {
+			if bytes.Equal(first, red) {
+				return "a"
+			}
+			return "b"
+		})
+		judge := eval.Judge{Client: grail.NewClient(prefersRed), SwapPositions: true}
+		p, err := judge.CompareImages(context.Background(), "a red square", red, blue, "quality")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if p.Winner != eval.ChoiceA || !p.Consistent {
+			t.Fatalf("expected consistent win for A, got %+v", p)
+		}
+		if len(p.Criteria) != 1 || p.Criteria[0].Winner != eval.ChoiceA {
+			t.Fatalf("unexpected criteria: %+v", p.Criteria)
+		}
+		if p.Usage.InputTokens != 20 {
+			t.Fatalf("expected usage from both calls, got %+v", p.Usage)
+		}
+	})
+
+	t.Run("position bias becomes a tie", func(t *testing.T) {
+		prefersFirst := judgeFn(func([]byte) string { return "a" })
+		judge := eval.Judge{Client: grail.NewClient(prefersFirst), SwapPositions: true}
+		p, err := judge.CompareImages(context.Background(), "", red, blue)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if p.Winner != eval.ChoiceTie || p.Consistent {
+			t.Fatalf("expected inconsistent tie, got %+v", p)
+		}
+	})
+
+	t.Run("invalid winner rejected", func(t *testing.T) {
+		judge := eval.Judge{Client: grail.NewClient(judgeFn(func([]byte) string { return "c" }))}
+		_, err := judge.CompareImages(context.Background(), "", red, blue)
+		if grail.GetErrorCode(err) != grail.OutputInvalid {
+			t.Fatalf("expected output_invalid, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,62 @@
+// Package eval provides model-graded evaluators for comparing and scoring
+// generated outputs, for automated A/B testing of models and prompts; an
+// Optimizer that rewrites prompts against an eval set; and Diff, a model-free
+// structural comparison of two responses.
+package eval
+
+import (
+	"context"
+
+	"github.com/montanaflynn/grail"
+)
+
+// Judge is the model that grades outputs.
+type Judge struct {
+	Client grail.Client
+	Model  string          // explicit judge model (optional)
+	Tier   grail.ModelTier // judge tier when Model is empty (default best)
+
+	// SwapPositions runs every pairwise comparison a second time with the
+	// candidates in reverse order, to cancel out the judge's position bias.
+	// A comparison whose two orderings disagree is reported as a tie.
+	SwapPositions bool
+	// Samples is the number of independent judgments averaged by ScoreText,
+	// to smooth out judge variance (default 1).
+	Samples int
+}
+
+// Choice is the outcome of a pairwise comparison.
+type Choice string
+
+const (
+	ChoiceA   Choice = "a"
+	ChoiceB   Choice = "b"
+	ChoiceTie Choice = "tie"
+)
+
+// swap returns the choice as seen with the candidates in reverse order.
+func (c Choice) swap() Choice {
+	switch c {
+	case ChoiceA:
+		return ChoiceB
+	case ChoiceB:
+		return ChoiceA
+	}
+	return c
+}
+
+func (j Judge) generate(ctx context.Context, inputs []grail.Input, schema any) (grail.Response, error) {
+	if j.Client == nil {
+		return grail.Response{}, grail.NewGrailError(grail.InvalidArgument, "judge client must be set")
+	}
+	tier := j.Tier
+	if tier == "" && j.Model == "" {
+		tier = grail.ModelTierBest
+	}
+	return j.Client.Generate(ctx, grail.Request{
+		Inputs: inputs,
+		Output: grail.OutputJSON(schema),
+		Model:  j.Model,
+		Tier:   tier,
+	})
+}
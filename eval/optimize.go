@@ -0,0 +1,207 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/montanaflynn/grail"
+)
+
+// InputPlaceholder marks where a prompt under optimization takes each case's
+// input. Prompts without it get the input appended.
+const InputPlaceholder = "{{input}}"
+
+// Case is one example in an eval set.
+type Case struct {
+	Input     string
+	Reference string // optional reference answer for the judge
+}
+
+// Optimizer improves a prompt by having a best-tier model rewrite it, running
+// each rewrite over an eval set, and keeping the best-scoring variant.
+type Optimizer struct {
+	// Client, Model, and Tier run the prompt under optimization.
+	Client grail.Client
+	Model  string
+	Tier   grail.ModelTier
+
+	// Judge scores each output against Rubric, with the case's reference
+	// answer, if any.
+	Judge  Judge
+	Rubric Rubric
+
+	// Rewriter rewrites prompts at ModelTierBest (default Judge.Client).
+	Rewriter grail.Client
+	// Rounds is the number of rewrite rounds (default 3), each starting from
+	// the best prompt so far.
+	Rounds int
+	// Candidates is the number of rewrites tried per round (default 2).
+	Candidates int
+}
+
+// Optimization is the outcome of Optimize.
+type Optimization struct {
+	Best      string  // the best-scoring prompt, which may be the original
+	BestScore float64 // its mean normalized score, from 0 to 1
+	History   []Attempt
+	Usage     grail.Usage // across runs, judging, and rewriting
+}
+
+// Attempt is one prompt Optimize evaluated.
+type Attempt struct {
+	Round  int // 0 for the original prompt
+	Prompt string
+	Score  float64   // mean normalized score over the eval set
+	Scores []float64 // normalized score per case
+}
+
+// Optimize evaluates prompt over cases, then rewrites it for the configured
+// number of rounds, telling the rewriter where the best prompt so far scored
+// worst and why. Rewrites that drop InputPlaceholder from a prompt that had
+// it are discarded.
+func (o Optimizer) Optimize(ctx context.Context, prompt string, cases []Case) (Optimization, error) {
+	if o.Client == nil {
+		return Optimization{}, grail.NewGrailError(grail.InvalidArgument, "optimizer client must be set")
+	}
+	if len(cases) == 0 {
+		return Optimization{}, grail.NewGrailError(grail.InvalidArgument, "at least one eval case is required")
+	}
+	rounds, candidates := o.Rounds, o.Candidates
+	if rounds <= 0 {
+		rounds = 3
+	}
+	if candidates <= 0 {
+		candidates = 2
+	}
+
+	var out Optimization
+	best, feedback, err := o.evaluate(ctx, 0, prompt, cases, &out.Usage)
+	if err != nil {
+		return out, err
+	}
+	out.History = append(out.History, best)
+	for round := 1; round <= rounds; round++ {
+		from, fromFeedback := best, feedback
+		for range candidates {
+			candidate, err := o.rewrite(ctx, from, fromFeedback, &out.Usage)
+			if err != nil {
+				return out, err
+			}
+			if strings.Contains(prompt, InputPlaceholder) && !strings.Contains(candidate, InputPlaceholder) {
+				continue
+			}
+			a, fb, err := o.evaluate(ctx, round, candidate, cases, &out.Usage)
+			if err != nil {
+				return out, err
+			}
+			out.History = append(out.History, a)
+			if a.Score > best.Score {
+				best, feedback = a, fb
+			}
+		}
+	}
+	out.Best, out.BestScore = best.Prompt, best.Score
+	return out, nil
+}
+
+// feedback is what the rewriter learns about a case.
+type feedback struct {
+	input, output, rationale string
+	score                    float64
+}
+
+func (o Optimizer) evaluate(ctx context.Context, round int, prompt string, cases []Case, usage *grail.Usage) (Attempt, []feedback, error) {
+	a := Attempt{Round: round, Prompt: prompt}
+	var fbs []feedback
+	var sum float64
+	for _, c := range cases {
+		filled := fill(prompt, c.Input)
+		res, err := o.Client.Generate(ctx, grail.Request{
+			Inputs: []grail.Input{grail.InputText(filled)},
+			Output: grail.OutputText(),
+			Model:  o.Model,
+			Tier:   o.Tier,
+		})
+		if err != nil {
+			return a, nil, err
+		}
+		*usage = usage.Add(res.Usage)
+		text, _ := res.Text()
+		rubric := o.Rubric
+		if c.Reference != "" {
+			rubric.Reference = c.Reference
+		}
+		score, err := o.Judge.ScoreText(ctx, rubric, filled, text)
+		*usage = usage.Add(score.Usage)
+		if err != nil {
+			return a, nil, err
+		}
+		a.Scores = append(a.Scores, score.Normalized)
+		sum += score.Normalized
+		fbs = append(fbs, feedback{input: c.Input, output: text, rationale: score.Rationale, score: score.Normalized})
+	}
+	a.Score = sum / float64(len(cases))
+	sort.SliceStable(fbs, func(i, j int) bool { return fbs[i].score < fbs[j].score })
+	return a, fbs, nil
+}
+
+func fill(prompt, input string) string {
+	if strings.Contains(prompt, InputPlaceholder) {
+		return strings.ReplaceAll(prompt, InputPlaceholder, input)
+	}
+	return prompt + "\n\n" + input
+}
+
+var rewriteSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"analysis": map[string]any{"type": "string"},
+		"prompt":   map[string]any{"type": "string"},
+	},
+	"required": []string{"analysis", "prompt"},
+}
+
+// rewrite asks for an improved version of a, shown its weakest cases.
+func (o Optimizer) rewrite(ctx context.Context, a Attempt, fbs []feedback, usage *grail.Usage) (string, error) {
+	client := o.Rewriter
+	if client == nil {
+		client = o.Judge.Client
+	}
+	if client == nil {
+		return "", grail.NewGrailError(grail.InvalidArgument, "rewriter or judge client must be set")
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "You are improving a prompt for a language model. Graded against this rubric:\n\n%s\n\n", o.Rubric.Criteria)
+	fmt.Fprintf(&b, "it scored %.2f out of 1 on average.\n\nPrompt:\n%s\n\n", a.Score, a.Prompt)
+	b.WriteString("Its weakest results:\n")
+	for _, fb := range fbs[:min(3, len(fbs))] {
+		fmt.Fprintf(&b, "\nInput: %s\nOutput: %s\nScore: %.2f\nGrader's rationale: %s\n", fb.input, fb.output, fb.score, fb.rationale)
+	}
+	b.WriteString("\nAnalyze what the prompt gets wrong, then write an improved prompt that fixes it without overfitting to these inputs.")
+	if strings.Contains(a.Prompt, InputPlaceholder) {
+		fmt.Fprintf(&b, " Keep the placeholder %s where the input goes.", InputPlaceholder)
+	}
+	b.WriteString(" Respond with only a JSON object with the fields analysis (string) and prompt (string).")
+
+	res, err := client.Generate(ctx, grail.Request{
+		Inputs: []grail.Input{grail.InputText(b.String())},
+		Output: grail.OutputJSON(rewriteSchema),
+		Tier:   grail.ModelTierBest,
+	})
+	if err != nil {
+		return "", err
+	}
+	*usage = usage.Add(res.Usage)
+	var rewritten struct {
+		Prompt string `json:"prompt"`
+	}
+	if err := res.DecodeJSON(&rewritten); err != nil {
+		return "", grail.NewGrailError(grail.OutputInvalid, fmt.Sprintf("decode rewritten prompt: %v", err)).WithCause(err)
+	}
+	if strings.TrimSpace(rewritten.Prompt) == "" {
+		return "", grail.NewGrailError(grail.OutputInvalid, "rewriter returned an empty prompt")
+	}
+	return rewritten.Prompt, nil
+}
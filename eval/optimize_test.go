@@ -0,0 +1,74 @@
+package eval_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/eval"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestOptimize(t *testing.T) {
+	rewrites := 0
+	prov := &mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		prompt, _ := grail.AsTextInput(req.Inputs[0])
+		text := func(s string) (grail.Response, error) {
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart(s)}}, nil
+		}
+		jsonOut := func(s string) (grail.Response, error) {
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewJSONOutputPart([]byte(s))}}, nil
+		}
+		switch {
+		case strings.HasPrefix(prompt, "You are improving"):
+			if req.Tier != grail.ModelTierBest {
+				t.Fatalf("rewrites should use the best tier, got %q", req.Tier)
+			}
+			rewrites++
+			if rewrites == 1 {
+				return jsonOut(`{"analysis":"drop it","prompt":"no placeholder"}`)
+			}
+			return jsonOut(fmt.Sprintf(`{"analysis":"be careful","prompt":"Answer carefully (v%d): {{input}}"}`, rewrites))
+		case strings.HasPrefix(prompt, "You are an impartial grader"):
+			if strings.Contains(prompt, "CAREFUL") {
+				return jsonOut(`{"rationale":"good","score":9}`)
+			}
+			return jsonOut(`{"rationale":"sloppy","score":3}`)
+		default:
+			if req.Model != "target" {
+				t.Fatalf("expected the target model, got %q", req.Model)
+			}
+			if strings.Contains(prompt, "carefully") {
+				return text("CAREFUL answer")
+			}
+			return text("answer")
+		}
+	}}
+	client := grail.NewClient(prov)
+	opt := eval.Optimizer{
+		Client:     client,
+		Model:      "target",
+		Judge:      eval.Judge{Client: client},
+		Rubric:     eval.Rubric{Criteria: "Careful answers."},
+		Rounds:     2,
+		Candidates: 2,
+	}
+
+	res, err := opt.Optimize(context.Background(), "Answer: {{input}}", []eval.Case{{Input: "2+2"}, {Input: "3+3", Reference: "6"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(res.Best, "Answer carefully") || res.BestScore != 8.0/9 {
+		t.Fatalf("unexpected best %q (%v)", res.Best, res.BestScore)
+	}
+	// The original plus three rewrites; the one without the placeholder is discarded.
+	if len(res.History) != 4 || res.History[0].Round != 0 || res.History[0].Score != 2.0/9 || len(res.History[1].Scores) != 2 {
+		t.Fatalf("unexpected history %+v", res.History)
+	}
+
+	if _, err := opt.Optimize(context.Background(), "Answer", nil); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Fatalf("expected InvalidArgument without cases, got %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/montanaflynn/grail"
+)
+
+// Rubric describes how ScoreText grades a response.
+type Rubric struct {
+	// Criteria explains what a good response looks like, e.g. "The answer is
+	// factually correct, cites the source, and is under 100 words."
+	Criteria string
+	// Levels optionally describes specific scores, e.g. {1: "wrong", 5: "correct
+	// but incomplete", 10: "correct and complete"}.
+	Levels map[int]string
+	// Max is the top of the 1..Max scale (default 10).
+	Max int
+	// Reference is an optional reference answer to grade against.
+	Reference string
+}
+
+// Score is a judge's grade for a single response.
+type Score struct {
+	Value      float64 // mean score on the rubric's 1..Max scale
+	Max        int
+	Normalized float64 // (Value-1)/(Max-1), from 0 to 1
+	Rationale  string  // rationale of the first judgment
+	Samples    []float64
+	Usage      grail.Usage
+}
+
+var scoreSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"rationale": map[string]any{"type": "string"},
+		"score":     map[string]any{"type": "number"},
+	},
+	"required": []string{"rationale", "score"},
+}
+
+// ScoreText grades response (produced for prompt, which may be empty) against
+// the rubric. The judge is asked for its rationale before the score, and scores
+// outside the scale are rejected. With Judge.Samples > 1, the judgments are
+// averaged.
+func (j Judge) ScoreText(ctx context.Context, rubric Rubric, prompt, response string) (Score, error) {
+	if strings.TrimSpace(rubric.Criteria) == "" {
+		return Score{}, grail.NewGrailError(grail.InvalidArgument, "rubric criteria must not be empty")
+	}
+	if rubric.Max <= 1 {
+		rubric.Max = 10
+	}
+	samples := j.Samples
+	if samples <= 0 {
+		samples = 1
+	}
+
+	inputs := []grail.Input{grail.InputText(scoreInstructions(rubric, prompt, response))}
+	out := Score{Max: rubric.Max}
+	var sum float64
+	for i := 0; i < samples; i++ {
+		res, err := j.generate(ctx, inputs, scoreSchema)
+		if err != nil {
+			return out, err
+		}
+		out.Usage = out.Usage.Add(res.Usage)
+		var judged struct {
+			Rationale string  `json:"rationale"`
+			Score     float64 `json:"score"`
+		}
+		if err := res.DecodeJSON(&judged); err != nil {
+			return out, grail.NewGrailError(grail.OutputInvalid, fmt.Sprintf("decode score: %v", err)).WithCause(err)
+		}
+		if math.IsNaN(judged.Score) || judged.Score < 1 || judged.Score > float64(rubric.Max) {
+			return out, grail.NewGrailError(grail.OutputInvalid, fmt.Sprintf("judge score %v is outside 1..%d", judged.Score, rubric.Max))
+		}
+		if i == 0 {
+			out.Rationale = judged.Rationale
+		}
+		out.Samples = append(out.Samples, judged.Score)
+		sum += judged.Score
+	}
+	out.Value = sum / float64(samples)
+	out.Normalized = (out.Value - 1) / float64(rubric.Max-1)
+	return out, nil
+}
+
+// CompareText asks the judge which of two responses to prompt better satisfies
+// the criteria. It honors Judge.SwapPositions like CompareImages.
+func (j Judge) CompareText(ctx context.Context, prompt, a, b string, criteria ...string) (Preference, error) {
+	if len(criteria) == 0 {
+		return Preference{}, grail.NewGrailError(grail.InvalidArgument, "at least one criterion is required")
+	}
+	instructions := comparisonInstructions("responses", prompt, criteria) +
+		" Do not prefer a response because it is longer."
+	return j.compare(ctx, func(first, second []byte) []grail.Input {
+		return []grail.Input{
+			grail.InputText(instructions),
+			grail.InputText("Response A:\n" + string(first)),
+			grail.InputText("Response B:\n" + string(second)),
+		}
+	}, []byte(a), []byte(b))
+}
+
+func scoreInstructions(r Rubric, prompt, response string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "You are an impartial grader. Score the response below on a scale from 1 to %d using this rubric:\n\n%s\n", r.Max, r.Criteria)
+	if len(r.Levels) > 0 {
+		b.WriteString("\nScore levels:\n")
+		for s := r.Max; s >= 1; s-- {
+			if desc, ok := r.Levels[s]; ok {
+				fmt.Fprintf(&b, "- %d: %s\n", s, desc)
+			}
+		}
+	}
+	if prompt != "" {
+		fmt.Fprintf(&b, "\nPrompt:\n%s\n", prompt)
+	}
+	if r.Reference != "" {
+		fmt.Fprintf(&b, "\nReference answer:\n%s\n", r.Reference)
+	}
+	fmt.Fprintf(&b, "\nResponse:\n%s\n\n", response)
+	b.WriteString("Explain your reasoning first, then give the score. Do not reward length for its own sake. " +
+		"Respond with only a JSON object with the fields rationale (string) and score (number).")
+	return b.String()
+}
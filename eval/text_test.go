@@ -0,0 +1,72 @@
+package eval_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/eval"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestScoreText(t *testing.T) {
+	scores := []string{"8", "6"}
+	var calls int
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			prompt, _ := grail.AsTextInput(req.Inputs[0])
+			if !strings.Contains(prompt, "1 to 10") {
+				t.Fatalf("unexpected judge prompt: %s", prompt)
+			}
+			if req.Model != "judge-model" {
+				t.Fatalf("expected judge model, got %q", req.Model)
+			}
+			s := scores[calls%len(scores)]
+			calls++
+			return grail.Response{Outputs: []grail.OutputPart{
+				grail.NewJSONOutputPart([]byte(`{"rationale":"mostly right","score":` + s + `}`)),
+			}}, nil
+		},
+	}
+	judge := eval.Judge{Client: grail.NewClient(prov), Model: "judge-model", Samples: 2}
+
+	score, err := judge.ScoreText(context.Background(), eval.Rubric{Criteria: "Correct capital city."}, "Capital of France?", "Paris")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if score.Value != 7 || len(score.Samples) != 2 {
+		t.Fatalf("expected mean 7 over 2 samples, got %+v", score)
+	}
+	if score.Normalized != 6.0/9 {
+		t.Fatalf("unexpected normalized score %v", score.Normalized)
+	}
+
+	scores = []string{"11"}
+	if _, err := judge.ScoreText(context.Background(), eval.Rubric{Criteria: "x"}, "", "y"); grail.GetErrorCode(err) != grail.OutputInvalid {
+		t.Fatalf("expected output_invalid for out-of-range score, got %v", err)
+	}
+}
+
+func TestCompareText(t *testing.T) {
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			first, _ := grail.AsTextInput(req.Inputs[1])
+			w := "b"
+			if strings.Contains(first, "concise") {
+				w = "a"
+			}
+			return grail.Response{Outputs: []grail.OutputPart{
+				grail.NewJSONOutputPart([]byte(`{"winner":"` + w + `","confidence":1,"rationale":"r","criteria":[]}`)),
+			}}, nil
+		},
+	}
+	judge := eval.Judge{Client: grail.NewClient(prov), SwapPositions: true}
+	p, err := judge.CompareText(context.Background(), "Explain Go", "a long ramble", "a concise answer", "clarity")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Winner != eval.ChoiceB || !p.Consistent {
+		t.Fatalf("expected consistent win for B, got %+v", p)
+	}
+}
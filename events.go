@@ -0,0 +1,212 @@
+package grail
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//
+// Lifecycle events
+//
+
+// Event is a step in a Generate call: RequestStarted, ProviderCalled,
+// RetryScheduled, and then ResponseReady or RequestFailed. Switch on its type.
+type Event interface {
+	eventInfo() EventInfo
+}
+
+// EventInfo is embedded in every event.
+type EventInfo struct {
+	Call uint64 // identifies the Generate call, unique per EventBus
+	Time time.Time
+}
+
+func (e EventInfo) eventInfo() EventInfo { return e }
+
+// RequestStarted is emitted when a Generate call begins, with the request as
+// the caller passed it.
+type RequestStarted struct {
+	EventInfo
+	Request Request
+}
+
+// ProviderCalled is emitted each time the request is handed to the provider
+// (through any middleware), as middleware sees it.
+type ProviderCalled struct {
+	EventInfo
+	Request Request
+}
+
+// RetryScheduled is emitted before the provider is tried again within a
+// call: when its SDK makes another HTTP attempt (for providers that
+// implement TransportAware), or when WithModelFallback retries with another
+// model.
+type RetryScheduled struct {
+	EventInfo
+	Attempt int    // HTTP attempt about to be made, from 2; 0 for a model fallback
+	Model   string // the fallback model; empty for HTTP retries
+	Reason  error  // why the last attempt failed, if known
+}
+
+// ResponseReady is emitted when a Generate call succeeds, with the response
+// the caller receives.
+type ResponseReady struct {
+	EventInfo
+	Request  Request
+	Response Response
+	Duration time.Duration
+}
+
+// RequestFailed is emitted when a Generate call fails.
+type RequestFailed struct {
+	EventInfo
+	Request  Request
+	Err      error
+	Duration time.Duration
+}
+
+// EventBus delivers a client's events to its subscribers. Child clients from
+// With share their parent's bus. Events are delivered synchronously on the
+// goroutine making the call, in order, so subscribers must be quick and must
+// not call the client; hand events to a channel for slow work.
+type EventBus struct {
+	mu     sync.RWMutex
+	subs   map[uint64]func(Event)
+	nextID uint64
+	active atomic.Int32
+	calls  atomic.Uint64
+}
+
+// Subscribe calls fn with every event until unsubscribe is called.
+func (b *EventBus) Subscribe(fn func(Event)) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs == nil {
+		b.subs = map[uint64]func(Event){}
+	}
+	b.nextID++
+	id := b.nextID
+	b.subs[id] = fn
+	b.active.Add(1)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subs, id)
+			b.active.Add(-1)
+		})
+	}
+}
+
+func (b *EventBus) emit(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, fn := range b.subs {
+		fn(e)
+	}
+}
+
+type eventCallKey struct{}
+
+// eventCall tracks a Generate call that's being observed.
+type eventCall struct {
+	id       uint64
+	bus      *EventBus // the calling client's bus
+	mu       sync.Mutex
+	lastReq  string // method and URL of the last HTTP attempt
+	attempts int    // consecutive HTTP attempts at lastReq
+	lastErr  error  // outcome of the last HTTP attempt
+}
+
+// start begins observing a call, if anyone is subscribed.
+func (b *EventBus) start(ctx context.Context, req Request) context.Context {
+	if b.active.Load() == 0 {
+		return ctx
+	}
+	call := &eventCall{id: b.calls.Add(1), bus: b}
+	b.emit(RequestStarted{EventInfo: EventInfo{Call: call.id, Time: time.Now()}, Request: req})
+	return context.WithValue(ctx, eventCallKey{}, call)
+}
+
+// observed returns the call ctx is observed as, if any.
+func observed(ctx context.Context) (*eventCall, bool) {
+	call, ok := ctx.Value(eventCallKey{}).(*eventCall)
+	return call, ok
+}
+
+func (b *EventBus) finish(ctx context.Context, req Request, res Response, err error, d time.Duration) {
+	call, ok := observed(ctx)
+	if !ok {
+		return
+	}
+	info := EventInfo{Call: call.id, Time: time.Now()}
+	if err != nil {
+		b.emit(RequestFailed{EventInfo: info, Request: req, Err: err, Duration: d})
+		return
+	}
+	b.emit(ResponseReady{EventInfo: info, Request: req, Response: res, Duration: d})
+}
+
+func (b *EventBus) providerCalled(ctx context.Context, req Request) {
+	call, ok := observed(ctx)
+	if !ok {
+		return
+	}
+	call.mu.Lock()
+	call.lastReq, call.attempts = "", 0
+	call.mu.Unlock()
+	b.emit(ProviderCalled{EventInfo: EventInfo{Call: call.id, Time: time.Now()}, Request: req})
+}
+
+func (b *EventBus) fallback(ctx context.Context, model string, reason error) {
+	if call, ok := observed(ctx); ok {
+		b.emit(RetryScheduled{EventInfo: EventInfo{Call: call.id, Time: time.Now()}, Model: model, Reason: reason})
+	}
+}
+
+// eventTransport reports repeated HTTP requests as retries. Providers may make
+// several different requests per call (uploads, then generation), so only a
+// request to the same method and URL as the one before it counts.
+type eventTransport struct {
+	base http.RoundTripper
+}
+
+func (t *eventTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	call, ok := observed(req.Context())
+	if !ok {
+		return base.RoundTrip(req)
+	}
+	key := req.Method + " " + req.URL.String()
+	call.mu.Lock()
+	if key != call.lastReq {
+		call.lastReq, call.attempts = key, 0
+	}
+	call.attempts++
+	n, reason := call.attempts, call.lastErr
+	call.mu.Unlock()
+	if n > 1 {
+		call.bus.emit(RetryScheduled{EventInfo: EventInfo{Call: call.id, Time: time.Now()}, Attempt: n, Reason: reason})
+	}
+	res, err := base.RoundTrip(req)
+	call.mu.Lock()
+	switch {
+	case err != nil:
+		call.lastErr = err
+	case res.StatusCode >= 400:
+		code, retryable := CodeFromHTTPStatus(res.StatusCode)
+		call.lastErr = NewGrailError(code, fmt.Sprintf("HTTP %d", res.StatusCode)).WithRetryable(retryable)
+	default:
+		call.lastErr = nil
+	}
+	call.mu.Unlock()
+	return res, err
+}
@@ -0,0 +1,133 @@
+package grail_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+	"github.com/montanaflynn/grail/providers/openai"
+)
+
+func TestEvents(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After-Ms", "1")
+			http.Error(w, `{"error":{"message":"overloaded"}}`, http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"resp_1","object":"response","status":"completed","model":"gpt-5.4",
+			"output":[{"type":"message","id":"msg_1","role":"assistant","status":"completed",
+				"content":[{"type":"output_text","text":"hi","annotations":[]}]}]}`)
+	}))
+	defer srv.Close()
+	p, err := openai.New(openai.WithAPIKey("dummy"), openai.WithBaseURL(srv.URL+"/v1/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := grail.NewClient(p)
+
+	var got []string
+	unsubscribe := client.With().Events().Subscribe(func(e grail.Event) {
+		switch e := e.(type) {
+		case grail.RequestStarted:
+			got = append(got, fmt.Sprintf("started %d", e.Call))
+		case grail.ProviderCalled:
+			got = append(got, fmt.Sprintf("called %d", len(e.Request.Inputs)))
+		case grail.RetryScheduled:
+			got = append(got, fmt.Sprintf("retry %d %s", e.Attempt, grail.GetErrorCode(e.Reason)))
+		case grail.ResponseReady:
+			text, _ := e.Response.Text()
+			got = append(got, "ready "+text)
+		case grail.RequestFailed:
+			got = append(got, "failed "+string(grail.GetErrorCode(e.Err)))
+		}
+	})
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("hello")}, Output: grail.OutputText()}
+	if _, err := client.Generate(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"started 1", "called 1", "retry 2 unavailable", "ready hi"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("events = %q, want %q", got, want)
+	}
+
+	unsubscribe()
+	client.Generate(context.Background(), req)
+	if len(got) != len(want) {
+		t.Fatalf("events delivered after unsubscribe: %q", got)
+	}
+}
+
+func TestEventsFailure(t *testing.T) {
+	client := grail.NewClient(&mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		return grail.Response{}, grail.NewGrailError(grail.RateLimited, "slow down")
+	}})
+	var failed *grail.RequestFailed
+	client.Events().Subscribe(func(e grail.Event) {
+		if e, ok := e.(grail.RequestFailed); ok {
+			failed = &e
+		}
+	})
+	client.Generate(context.Background(), grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()})
+	if failed == nil || !grail.IsRateLimited(failed.Err) {
+		t.Fatalf("expected a RequestFailed event, got %+v", failed)
+	}
+}
+
+func TestEventsSharedProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"resp_1","object":"response","status":"completed","model":"gpt-5.4",
+			"output":[{"type":"message","id":"msg_1","role":"assistant","status":"completed",
+				"content":[{"type":"output_text","text":"hi","annotations":[]}]}]}`)
+	}))
+	defer srv.Close()
+	p, err := openai.New(openai.WithAPIKey("dummy"), openai.WithBaseURL(srv.URL+"/v1/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := grail.NewClient(p), grail.NewClient(p)
+
+	// Each client sees only its own calls, and one HTTP request per call is
+	// never reported as a retry.
+	var retries, aCalls, bCalls int
+	a.Events().Subscribe(func(e grail.Event) {
+		switch e.(type) {
+		case grail.RetryScheduled:
+			retries++
+		case grail.RequestStarted:
+			aCalls++
+		}
+	})
+	b.Events().Subscribe(func(e grail.Event) {
+		switch e.(type) {
+		case grail.RetryScheduled:
+			retries++
+		case grail.RequestStarted:
+			bCalls++
+		}
+	})
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("hello")}, Output: grail.OutputText()}
+	if _, err := b.Generate(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Generate(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if retries != 0 {
+		t.Errorf("expected no retries, got %d", retries)
+	}
+	if aCalls != 1 || bCalls != 1 {
+		t.Errorf("expected one call per client, got %d and %d", aCalls, bCalls)
+	}
+}
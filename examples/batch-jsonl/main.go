@@ -0,0 +1,125 @@
+// Batch-jsonl demonstrates dataset-scale processing: it reads request specs from a JSONL file,
+// runs them through GenerateBatch, and appends one result per line to an output JSONL file.
+// With -journal, an interrupted run can be started again and skips requests that already
+// completed. Image results are saved to the -images directory. Set GRAIL_JOURNAL_KEY to a
+// hex-encoded 32-byte key to encrypt the journal. With -plan, it prints what the run would do
+// (requests and estimated tokens per model, files to send, requests that would fail) and exits.
+//
+// Each input line is a grail.RequestSpec, for example:
+//
+//	{"id": "haiku", "prompt": "Write a haiku about {{.topic}}.", "vars": {"topic": "autumn"}}
+//	{"id": "report", "prompt": "Summarize this report.", "attachments": ["report.pdf"]}
+//	{"id": "fox", "prompt": "A watercolor fox", "output": "image"}
+//
+// Usage:
+//
+//	go run examples/batch-jsonl/main.go -in requests.jsonl
+//	go run examples/batch-jsonl/main.go -in requests.jsonl -plan
+//	go run examples/batch-jsonl/main.go -in requests.jsonl -provider openai -journal run.journal
+//	go run examples/batch-jsonl/main.go -in requests.jsonl -provider fake -out results.jsonl
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/fake"
+	"github.com/montanaflynn/grail/providers/gemini"
+	"github.com/montanaflynn/grail/providers/openai"
+)
+
+func main() {
+	ctx := context.Background()
+
+	in := flag.String("in", "", "JSONL file of request specs (required)")
+	out := flag.String("out", "examples-output/batch-results.jsonl", "JSONL file results are appended to")
+	images := flag.String("images", "examples-output", "directory for image results")
+	journal := flag.String("journal", "", "journal file for resuming interrupted runs")
+	providerName := flag.String("provider", "gemini", "provider: gemini, openai, or fake")
+	concurrency := flag.Int("concurrency", 4, "maximum concurrent requests")
+	plan := flag.Bool("plan", false, "print the plan for the run and exit without generating")
+	flag.Parse()
+	if *in == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var (
+		provider grail.Provider
+		err      error
+	)
+	switch *providerName {
+	case "gemini":
+		provider, err = gemini.New(ctx)
+	case "openai":
+		provider, err = openai.New()
+	case "fake":
+		provider = fake.New()
+	default:
+		log.Fatalf("unknown provider %q", *providerName)
+	}
+	if err != nil {
+		log.Fatalf("new %s provider: %v", *providerName, err)
+	}
+	client := grail.NewClient(provider)
+
+	reqs, err := grail.LoadRequests(*in, grail.RequestFileOptions{})
+	if err != nil {
+		log.Fatalf("load requests: %v", err)
+	}
+
+	if *plan {
+		if err := grail.PlanBatch(ctx, client, reqs, grail.PlanOptions{}).Write(os.Stdout); err != nil {
+			log.Fatalf("print plan: %v", err)
+		}
+		return
+	}
+
+	opts := grail.BatchOptions{Concurrency: *concurrency}
+	if *journal != "" {
+		var jopts []grail.JournalOption
+		if k := os.Getenv("GRAIL_JOURNAL_KEY"); k != "" {
+			key, err := hex.DecodeString(k)
+			if err != nil {
+				log.Fatalf("GRAIL_JOURNAL_KEY: %v", err)
+			}
+			jopts = append(jopts, grail.WithJournalEncryption(grail.StaticKey(key)))
+		}
+		j, err := grail.OpenJournal(*journal, jopts...)
+		if err != nil {
+			log.Fatalf("open journal: %v", err)
+		}
+		defer j.Close()
+		if n := j.Completed(); n > 0 {
+			fmt.Printf("resuming: %d of %d requests already completed\n", n, len(reqs))
+		}
+		opts.Journal = j
+	}
+
+	if err := os.MkdirAll(*images, 0o755); err != nil {
+		log.Fatalf("make image dir: %v", err)
+	}
+	f, err := os.OpenFile(*out, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		log.Fatalf("open results: %v", err)
+	}
+	defer f.Close()
+	w := grail.NewResultWriter(f, *images)
+
+	failed := 0
+	for i, r := range grail.GenerateBatch(ctx, client, reqs, opts) {
+		if r.Err != nil {
+			failed++
+			log.Printf("request %s: %v", reqs[i].Metadata["id"], r.Err)
+		}
+		if err := w.Write(reqs[i], r); err != nil {
+			log.Fatalf("write result: %v", err)
+		}
+	}
+	fmt.Printf("wrote %d results to %s (%d failed)\n", len(reqs), *out, failed)
+}
@@ -7,6 +7,10 @@
 //	go run examples/text-generation/main.go -openai
 //	go run examples/text-generation/main.go -gemini
 //	go run examples/text-generation/main.go -openai -gemini -debug
+//	go run examples/text-generation/main.go -openai -gemini -json | jq -r .outputs[0].text
+//
+// With -json, each provider's response (or error) is printed as one line of JSON and logs go
+// to stderr.
 package main
 
 import (
@@ -31,13 +35,18 @@ func main() {
 	openaiFlag := flag.Bool("openai", false, "use OpenAI provider")
 	geminiFlag := flag.Bool("gemini", false, "use Gemini provider")
 	debugFlag := flag.Bool("debug", false, "enable debug logging")
+	jsonFlag := flag.Bool("json", false, "print responses as JSON lines")
 	flag.Parse()
 
 	level := slog.LevelInfo
 	if *debugFlag {
 		level = slog.LevelDebug
 	}
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+	logOut := os.Stdout
+	if *jsonFlag {
+		logOut = os.Stderr
+	}
+	logger := slog.New(slog.NewTextHandler(logOut, &slog.HandlerOptions{
 		Level: level,
 	}))
 
@@ -46,7 +55,7 @@ func main() {
 
 	type result struct {
 		provider string
-		text     string
+		res      grail.Response
 		err      error
 	}
 
@@ -57,8 +66,8 @@ func main() {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			text, err := generateWithProvider(ctx, logger, "gemini", "GEMINI_API_KEY")
-			resultsCh <- result{provider: "gemini", text: text, err: err}
+			res, err := generateWithProvider(ctx, logger, "gemini", "GEMINI_API_KEY")
+			resultsCh <- result{provider: "gemini", res: res, err: err}
 		}()
 	}
 
@@ -66,8 +75,8 @@ func main() {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			text, err := generateWithProvider(ctx, logger, "openai", "OPENAI_API_KEY")
-			resultsCh <- result{provider: "openai", text: text, err: err}
+			res, err := generateWithProvider(ctx, logger, "openai", "OPENAI_API_KEY")
+			resultsCh <- result{provider: "openai", res: res, err: err}
 		}()
 	}
 
@@ -76,20 +85,37 @@ func main() {
 		close(resultsCh)
 	}()
 
+	failed := false
 	for res := range resultsCh {
+		if *jsonFlag {
+			var out grail.ResponseJSON
+			if res.err != nil {
+				out, failed = grail.ErrorJSON(res.provider, res.err), true
+			} else {
+				out, _ = grail.NewResponseJSON(res.res, "") // text only, nothing to save
+			}
+			if err := out.Write(os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+			continue
+		}
 		if res.err != nil {
 			log.Printf("%s: generate text error: %v", res.provider, res.err)
 			continue
 		}
-		if res.text == "" {
+		text, _ := res.res.Text()
+		if text == "" {
 			log.Printf("%s: empty text response", res.provider)
 			continue
 		}
-		fmt.Printf("[%s] %s\n", res.provider, res.text)
+		fmt.Printf("[%s] %s\n", res.provider, text)
+	}
+	if failed {
+		os.Exit(1)
 	}
 }
 
-func generateWithProvider(ctx context.Context, logger *slog.Logger, providerName, envKey string) (string, error) {
+func generateWithProvider(ctx context.Context, logger *slog.Logger, providerName, envKey string) (grail.Response, error) {
 	key := os.Getenv(envKey)
 
 	var (
@@ -108,26 +134,21 @@ func generateWithProvider(ctx context.Context, logger *slog.Logger, providerName
 			openai.WithAPIKey(key),
 		)
 	default:
-		return "", fmt.Errorf("unknown provider %q", providerName)
+		return grail.Response{}, fmt.Errorf("unknown provider %q", providerName)
 	}
 	if err != nil {
-		return "", fmt.Errorf("new %s provider: %w", providerName, err)
+		return grail.Response{}, fmt.Errorf("new %s provider: %w", providerName, err)
 	}
 
 	client := grail.NewClient(provider, grail.WithLogger(logger))
 	return generateText(ctx, client)
 }
 
-func generateText(ctx context.Context, client grail.Client) (string, error) {
-	res, err := client.Generate(ctx, grail.Request{
+func generateText(ctx context.Context, client grail.Client) (grail.Response, error) {
+	return client.Generate(ctx, grail.Request{
 		Inputs: []grail.Input{
 			grail.InputText("Explain how AI works in a few words"),
 		},
 		Output: grail.OutputText(),
 	})
-	if err != nil {
-		return "", err
-	}
-	text, _ := res.Text()
-	return text, nil
 }
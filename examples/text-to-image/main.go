@@ -1,6 +1,8 @@
 // Text-to-image demonstrates image generation from text prompts.
 // It can run with OpenAI, Gemini, ModelsLab, or all providers in parallel, generating images
-// from text descriptions and saving them to the examples-output directory.
+// from text descriptions and saving them to the examples-output directory, each with a JSON
+// manifest recording the request that produced it. The fake provider needs no API key and
+// produces the same stub image on every run.
 //
 // Usage:
 //
@@ -8,6 +10,11 @@
 //	go run examples/text-to-image/main.go -openai
 //	go run examples/text-to-image/main.go -modelslab
 //	go run examples/text-to-image/main.go -gemini -debug
+//	go run examples/text-to-image/main.go -fake
+//	go run examples/text-to-image/main.go -fake -json | jq -r .outputs[].file
+//
+// With -json, each provider's response (or error) is printed as one line of JSON, with images
+// saved under examples-output by content hash and referenced by path, and logs go to stderr.
 package main
 
 import (
@@ -22,6 +29,7 @@ import (
 	"log/slog"
 
 	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/fake"
 	"github.com/montanaflynn/grail/providers/gemini"
 	"github.com/montanaflynn/grail/providers/modelslab"
 	"github.com/montanaflynn/grail/providers/openai"
@@ -34,38 +42,46 @@ func main() {
 	openaiFlag := flag.Bool("openai", false, "use OpenAI provider")
 	geminiFlag := flag.Bool("gemini", false, "use Gemini provider")
 	modelslabFlag := flag.Bool("modelslab", false, "use ModelsLab provider")
+	fakeFlag := flag.Bool("fake", false, "use the fake provider (deterministic stub images, no API key)")
 	debugFlag := flag.Bool("debug", false, "enable debug logging")
+	jsonFlag := flag.Bool("json", false, "print responses as JSON lines")
 	flag.Parse()
 
 	level := slog.LevelInfo
 	if *debugFlag {
 		level = slog.LevelDebug
 	}
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+	logOut := os.Stdout
+	if *jsonFlag {
+		logOut = os.Stderr
+	}
+	logger := slog.New(slog.NewTextHandler(logOut, &slog.HandlerOptions{
 		Level: level,
 	}))
 
 	// Determine which providers to run.
-	noneSet := !*openaiFlag && !*geminiFlag && !*modelslabFlag
+	noneSet := !*openaiFlag && !*geminiFlag && !*modelslabFlag && !*fakeFlag
 	runOpenAI := *openaiFlag
 	runGemini := *geminiFlag || noneSet // default gemini if none set
 	runModelsLab := *modelslabFlag
+	runFake := *fakeFlag
 
 	type result struct {
 		provider string
-		images   []grail.ImageOutputInfo
+		req      grail.Request
+		res      grail.Response
 		err      error
 	}
 
 	var wg sync.WaitGroup
-	resultsCh := make(chan result, 2)
+	resultsCh := make(chan result, 4)
 
 	if runGemini {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			images, err := generateWithProvider(ctx, logger, "gemini", "GEMINI_API_KEY")
-			resultsCh <- result{provider: "gemini", images: images, err: err}
+			req, res, err := generateWithProvider(ctx, logger, "gemini", "GEMINI_API_KEY")
+			resultsCh <- result{provider: "gemini", req: req, res: res, err: err}
 		}()
 	}
 
@@ -73,8 +89,8 @@ func main() {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			images, err := generateWithProvider(ctx, logger, "openai", "OPENAI_API_KEY")
-			resultsCh <- result{provider: "openai", images: images, err: err}
+			req, res, err := generateWithProvider(ctx, logger, "openai", "OPENAI_API_KEY")
+			resultsCh <- result{provider: "openai", req: req, res: res, err: err}
 		}()
 	}
 
@@ -82,8 +98,17 @@ func main() {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			images, err := generateWithProvider(ctx, logger, "modelslab", "MODELSLAB_API_KEY")
-			resultsCh <- result{provider: "modelslab", images: images, err: err}
+			req, res, err := generateWithProvider(ctx, logger, "modelslab", "MODELSLAB_API_KEY")
+			resultsCh <- result{provider: "modelslab", req: req, res: res, err: err}
+		}()
+	}
+
+	if runFake {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, res, err := generateWithProvider(ctx, logger, "fake", "")
+			resultsCh <- result{provider: "fake", req: req, res: res, err: err}
 		}()
 	}
 
@@ -92,22 +117,41 @@ func main() {
 		close(resultsCh)
 	}()
 
+	failed := false
 	for res := range resultsCh {
+		if *jsonFlag {
+			err := res.err
+			var out grail.ResponseJSON
+			if err == nil {
+				out, err = grail.NewResponseJSON(res.res, "examples-output")
+			}
+			if err != nil {
+				out = grail.ErrorJSON(res.provider, err)
+			}
+			failed = failed || out.Error != ""
+			if err := out.Write(os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+			continue
+		}
 		if res.err != nil {
 			log.Printf("%s: generate image error: %v", res.provider, res.err)
 			continue
 		}
-		if len(res.images) == 0 {
+		if len(res.res.ImageOutputs()) == 0 {
 			log.Printf("%s: no image returned", res.provider)
 			continue
 		}
-		if err := saveImages("examples-output", fmt.Sprintf("text-to-image-%s", res.provider), res.images); err != nil {
+		if err := saveImages("examples-output", fmt.Sprintf("text-to-image-%s", res.provider), res.req, res.res); err != nil {
 			log.Printf("%s: save images: %v", res.provider, err)
 		}
 	}
+	if failed {
+		os.Exit(1)
+	}
 }
 
-func generateWithProvider(ctx context.Context, logger *slog.Logger, providerName, envKey string) ([]grail.ImageOutputInfo, error) {
+func generateWithProvider(ctx context.Context, logger *slog.Logger, providerName, envKey string) (grail.Request, grail.Response, error) {
 	var (
 		provider grail.Provider
 		err      error
@@ -127,29 +171,30 @@ func generateWithProvider(ctx context.Context, logger *slog.Logger, providerName
 		provider, err = modelslab.New(
 			modelslab.WithAPIKey(os.Getenv(envKey)),
 		)
+	case "fake":
+		provider = fake.New()
 	default:
-		return nil, fmt.Errorf("unknown provider %q", providerName)
+		return grail.Request{}, grail.Response{}, fmt.Errorf("unknown provider %q", providerName)
 	}
 	if err != nil {
-		return nil, fmt.Errorf("new %s provider: %w", providerName, err)
+		return grail.Request{}, grail.Response{}, fmt.Errorf("new %s provider: %w", providerName, err)
 	}
 
 	client := grail.NewClient(provider, grail.WithLogger(logger))
-	res, err := client.Generate(ctx, grail.Request{
+	req := grail.Request{
 		Inputs: []grail.Input{
 			grail.InputText("An image of a cozy cabin in the woods at dusk, in watercolor style"),
 			grail.InputText("With the words Merry Christmas written in the top right corner"),
 		},
 		Output: grail.OutputImage(grail.ImageSpec{Count: 1}),
-	})
-	if err != nil {
-		return nil, err
 	}
-	return res.ImageOutputs(), nil
+	res, err := client.Generate(ctx, req)
+	return req, res, err
 }
 
-// saveImages writes all returned images to disk with numbered filenames.
-func saveImages(dir, base string, imgs []grail.ImageOutputInfo) error {
+// saveImages writes all returned images to disk with numbered filenames, each
+// with a manifest sidecar.
+func saveImages(dir, base string, req grail.Request, res grail.Response) error {
 	extFromMIME := func(mime string) string {
 		switch mime {
 		case "image/jpeg", "image/jpg":
@@ -166,10 +211,11 @@ func saveImages(dir, base string, imgs []grail.ImageOutputInfo) error {
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return fmt.Errorf("make output dir: %w", err)
 	}
-	for i, img := range imgs {
+	manifest := grail.NewManifest(req, res)
+	for i, img := range res.ImageOutputs() {
 		ext := extFromMIME(img.MIME)
 		outPath := filepath.Join(dir, fmt.Sprintf("%s-%02d%s", base, i+1, ext))
-		if err := os.WriteFile(outPath, img.Data, 0o644); err != nil {
+		if err := img.Save(outPath, grail.WithManifest(manifest)); err != nil {
 			return fmt.Errorf("write image %d: %w", i, err)
 		}
 		fmt.Printf("saved image %d to %s (mime=%s, bytes=%d)\n", i+1, outPath, img.MIME, len(img.Data))
@@ -0,0 +1,33 @@
+package grail
+
+import "time"
+
+// Exported for fuzz tests in grail_test.
+var DetectMIMEFromPath = detectMIMEFromPath
+
+// Exported for air-gap tests in grail_test.
+var HostAllowed = hostAllowed
+
+// ReviewWaiters is exported for review gate tests in grail_test.
+func ReviewWaiters(g *ReviewGate) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.waiters)
+}
+
+// EstimateTokens is exported for token estimate tests in grail_test.
+var EstimateTokens = estimateTokens
+
+// SetSchedulerClock is exported for scheduler tests in grail_test.
+func SetSchedulerClock(s *Scheduler, now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// SchedulerQueues is exported for scheduler tests in grail_test.
+func SchedulerQueues(s *Scheduler) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queues)
+}
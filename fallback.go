@@ -0,0 +1,61 @@
+package grail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+//
+// Model fallback
+//
+
+// WarningModelFallback is set on responses generated with a fallback model
+// (see WithModelFallback). The message names both models.
+const WarningModelFallback = "model_fallback"
+
+// WithModelFallback retries requests whose model the provider reports as not
+// found (NotFound, typically a retired or misspelled model) once with the
+// provider's default model for the request's tier (ModelTierBest if unset),
+// recording the substitution in a WarningModelFallback warning. It applies to
+// providers that implement ModelResolver.
+func WithModelFallback() ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.modelFallback = true
+	})
+}
+
+func (c *client) retryWithFallbackModel(ctx context.Context, req Request, err error) (Response, error) {
+	if !IsNotFound(err) || req.Model == "" || c.streamStarted(ctx) {
+		return Response{}, err
+	}
+	resolver, ok := c.provider.(ModelResolver)
+	if !ok {
+		return Response{}, err
+	}
+	tier := req.Tier
+	if tier == "" {
+		tier = ModelTierBest
+	}
+	fallback, rerr := resolver.ResolveModel(roleFromOutput(req.Output), tier)
+	if rerr != nil || fallback == "" || fallback == req.Model {
+		return Response{}, err
+	}
+
+	if c.log != nil {
+		c.log.Warn("model not found; falling back to provider default",
+			slog.String("model", req.Model), slog.String("fallback", fallback), slog.String("error", err.Error()))
+	}
+	c.events.fallback(ctx, fallback, err)
+	missing := req.Model
+	req.Model = fallback
+	res, err := c.callProvider(ctx, req)
+	if err != nil {
+		return res, err
+	}
+	res.Warnings = append(res.Warnings, Warning{
+		Code:    WarningModelFallback,
+		Message: fmt.Sprintf("model %q not found; used %q", missing, fallback),
+	})
+	return res, nil
+}
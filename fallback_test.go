@@ -0,0 +1,51 @@
+package grail_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+type resolvingProvider struct {
+	mock.Provider
+}
+
+func (resolvingProvider) ResolveModel(role grail.ModelRole, tier grail.ModelTier) (string, error) {
+	return string(role) + "-" + string(tier), nil
+}
+
+func TestModelFallback(t *testing.T) {
+	var models []string
+	prov := &resolvingProvider{mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		models = append(models, req.Model)
+		if req.Model == "retired" {
+			return grail.Response{}, grail.NewGrailError(grail.NotFound, "model retired not found")
+		}
+		return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("ok")}}, nil
+	}}}
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText(), Model: "retired"}
+
+	_, err := grail.NewClient(prov).Generate(context.Background(), req)
+	if !grail.IsNotFound(err) || len(models) != 1 {
+		t.Fatalf("expected NotFound without fallback, got %v after %v", err, models)
+	}
+
+	models = nil
+	res, err := grail.NewClient(prov, grail.WithModelFallback()).Generate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(models) != 2 || models[1] != "text-best" {
+		t.Fatalf("expected a retry with the best text model, got %v", models)
+	}
+	if len(res.Warnings) != 1 || res.Warnings[0].Code != grail.WarningModelFallback ||
+		res.Warnings[0].Message != `model "retired" not found; used "text-best"` {
+		t.Fatalf("unexpected warnings %+v", res.Warnings)
+	}
+
+	if code, retryable := grail.CodeFromHTTPStatus(404); code != grail.NotFound || retryable {
+		t.Fatalf("expected 404 to map to NotFound, got %s (retryable %v)", code, retryable)
+	}
+}
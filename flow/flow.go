@@ -0,0 +1,457 @@
+// Package flow runs multi-step generation jobs defined as a graph: each node
+// is a Generate call or a Go function, takes the typed outputs of the nodes
+// it depends on, and has its own retry and model settings. Independent nodes
+// run concurrently, and every run produces a trace of what each node did.
+//
+//	g := flow.New()
+//	topic := flow.Param[string](g, "topic")
+//	outline := flow.Generate[Outline](g, "outline", client, func(in flow.Inputs) (grail.Request, error) {
+//		return grail.Request{
+//			Inputs: []grail.Input{grail.InputText("Outline an article on " + flow.Get(in, topic))},
+//			Output: grail.OutputJSON(outlineSchema),
+//		}, nil
+//	}, flow.After(topic), flow.Tier(grail.ModelTierBest))
+//	draft := flow.Generate[string](g, "draft", client, func(in flow.Inputs) (grail.Request, error) {
+//		...
+//	}, flow.After(outline), flow.Retries(2, time.Second))
+//
+//	run, err := g.Run(ctx, flow.Set(topic, "tide pools"))
+//	article, _ := flow.Output(run, draft)
+//
+// A Graph is safe to run concurrently once built.
+package flow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/montanaflynn/grail"
+)
+
+// Graph is a workflow of nodes. Nodes can only depend on nodes added before
+// them, so a graph never has cycles.
+type Graph struct {
+	nodes []*node
+	index map[string]*node
+	err   error // the first error made building the graph, returned by Run
+}
+
+type node struct {
+	name  string
+	param bool
+	deps  []string
+	cfg   config
+	run   func(ctx context.Context, in Inputs, cfg config) (any, grail.Response, error)
+}
+
+// New returns an empty graph.
+func New() *Graph {
+	return &Graph{index: map[string]*node{}}
+}
+
+// Ref refers to a node's output of type T.
+type Ref[T any] struct {
+	name  string
+	graph *Graph
+}
+
+// Name returns the node's name.
+func (r Ref[T]) Name() string { return r.name }
+
+func (r Ref[T]) ref() (string, *Graph) { return r.name, r.graph }
+
+// Dep is a node another node depends on: any Ref.
+type Dep interface{ ref() (string, *Graph) }
+
+// Inputs holds the outputs of a node's dependencies.
+type Inputs struct {
+	values map[string]any
+}
+
+// Get returns the output of ref, which must be a dependency of the node
+// reading it. Reading another node's output panics.
+func Get[T any](in Inputs, ref Ref[T]) T {
+	v, ok := in.values[ref.name]
+	if !ok {
+		panic(fmt.Sprintf("flow: %s is not a dependency of this node; add it with After", ref.name))
+	}
+	return v.(T)
+}
+
+//
+// Node options
+//
+
+// Option configures a node.
+type Option interface{ apply(*config) }
+
+type config struct {
+	deps     []Dep
+	retries  int
+	backoff  time.Duration
+	retryIf  func(error) bool
+	model    string
+	tier     grail.ModelTier
+	timeout  time.Duration
+	metadata map[string]string
+}
+
+type optionFunc func(*config)
+
+func (f optionFunc) apply(c *config) { f(c) }
+
+// After makes the node depend on deps: it runs once they've all succeeded,
+// and reads their outputs with Get.
+func After(deps ...Dep) Option {
+	return optionFunc(func(c *config) {
+		c.deps = append(c.deps, deps...)
+	})
+}
+
+// Retries retries a failed node up to n more times, waiting backoff before
+// the first retry and doubling it each time. Only errors grail.IsRetryable
+// accepts are retried, unless RetryIf says otherwise.
+func Retries(n int, backoff time.Duration) Option {
+	return optionFunc(func(c *config) {
+		c.retries, c.backoff = n, backoff
+	})
+}
+
+// RetryIf sets which of the node's errors are retried.
+func RetryIf(fn func(error) bool) Option {
+	return optionFunc(func(c *config) {
+		c.retryIf = fn
+	})
+}
+
+// Model sets the model a Generate node's requests use, overriding the one
+// its build function sets.
+func Model(name string) Option {
+	return optionFunc(func(c *config) {
+		c.model = name
+	})
+}
+
+// Tier sets the model tier a Generate node's requests use, when they don't
+// name a model.
+func Tier(t grail.ModelTier) Option {
+	return optionFunc(func(c *config) {
+		c.tier = t
+	})
+}
+
+// Timeout bounds each attempt of the node.
+func Timeout(d time.Duration) Option {
+	return optionFunc(func(c *config) {
+		c.timeout = d
+	})
+}
+
+// Metadata adds metadata to a Generate node's requests, for usage
+// attribution. The node's name is always added as "flow_node".
+func Metadata(md map[string]string) Option {
+	return optionFunc(func(c *config) {
+		if c.metadata == nil {
+			c.metadata = map[string]string{}
+		}
+		for k, v := range md {
+			c.metadata[k] = v
+		}
+	})
+}
+
+//
+// Nodes
+//
+
+// Param adds a node whose value is given to Run with Set, for the inputs of
+// a repeatable job.
+func Param[T any](g *Graph, name string) Ref[T] {
+	g.add(&node{name: name, param: true})
+	return Ref[T]{name: name, graph: g}
+}
+
+// Func adds a node that runs fn.
+func Func[T any](g *Graph, name string, fn func(ctx context.Context, in Inputs) (T, error), opts ...Option) Ref[T] {
+	g.add(&node{name: name, cfg: newConfig(opts), run: func(ctx context.Context, in Inputs, _ config) (any, grail.Response, error) {
+		v, err := fn(ctx, in)
+		return v, grail.Response{}, err
+	}})
+	return Ref[T]{name: name, graph: g}
+}
+
+// Generate adds a node that sends the request build returns to c (with text
+// output if it sets none), and outputs the response as a T: the text for a
+// string, the response itself for a grail.Response, and the decoded JSON
+// output otherwise.
+func Generate[T any](g *Graph, name string, c grail.Client, build func(in Inputs) (grail.Request, error), opts ...Option) Ref[T] {
+	g.add(&node{name: name, cfg: newConfig(opts), run: func(ctx context.Context, in Inputs, cfg config) (any, grail.Response, error) {
+		var out T
+		req, err := build(in)
+		if err != nil {
+			return out, grail.Response{}, err
+		}
+		if req.Output == nil {
+			req.Output = grail.OutputText()
+		}
+		if cfg.model != "" {
+			req.Model = cfg.model
+		}
+		if req.Tier == "" {
+			req.Tier = cfg.tier
+		}
+		md := map[string]string{"flow_node": name}
+		for k, v := range cfg.metadata {
+			md[k] = v
+		}
+		for k, v := range req.Metadata {
+			md[k] = v
+		}
+		req.Metadata = md
+
+		res, err := c.Generate(ctx, req)
+		if err != nil {
+			return out, res, err
+		}
+		switch p := any(&out).(type) {
+		case *grail.Response:
+			*p = res
+		case *string:
+			*p, _ = res.Text()
+		default:
+			if err := res.DecodeJSON(&out); err != nil {
+				return out, res, grail.NewGrailError(grail.OutputInvalid, fmt.Sprintf("decode output as %T: %v", out, err)).
+					WithCause(err).WithProviderName(res.Provider.Name).WithRequestID(res.RequestID)
+			}
+		}
+		return out, res, nil
+	}})
+	return Ref[T]{name: name, graph: g}
+}
+
+func newConfig(opts []Option) config {
+	var cfg config
+	for _, opt := range opts {
+		if opt != nil {
+			opt.apply(&cfg)
+		}
+	}
+	if cfg.retryIf == nil {
+		cfg.retryIf = grail.IsRetryable
+	}
+	return cfg
+}
+
+func (g *Graph) add(n *node) {
+	if g.index == nil {
+		g.index = map[string]*node{}
+	}
+	if g.err != nil {
+		return
+	}
+	switch {
+	case n.name == "":
+		g.err = grail.NewGrailError(grail.InvalidArgument, "flow: node name must not be empty")
+		return
+	case g.index[n.name] != nil:
+		g.err = grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("flow: duplicate node %q", n.name))
+		return
+	}
+	for _, d := range n.cfg.deps {
+		name, dg := d.ref()
+		if dg != g {
+			g.err = grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("flow: node %q depends on %q from another graph", n.name, name))
+			return
+		}
+		n.deps = append(n.deps, name)
+	}
+	g.nodes = append(g.nodes, n)
+	g.index[n.name] = n
+}
+
+//
+// Running
+//
+
+// Binding gives a Param its value for a run.
+type Binding struct {
+	name  string
+	value any
+}
+
+// Set binds ref's value for a run.
+func Set[T any](ref Ref[T], value T) Binding {
+	return Binding{name: ref.name, value: value}
+}
+
+// Status is how a node's run ended.
+type Status string
+
+const (
+	Succeeded Status = "succeeded"
+	Failed    Status = "failed"
+	Skipped   Status = "skipped" // a dependency failed or the run was canceled
+)
+
+// NodeTrace records one node's part in a run.
+type NodeTrace struct {
+	Node     string
+	Status   Status
+	Start    time.Time
+	Duration time.Duration
+	Attempts int
+	Model    string      // the model a Generate node's last attempt used
+	Usage    grail.Usage // summed across a Generate node's attempts
+	Err      error       // every attempt's error, joined
+}
+
+// Execution is the outcome of a run.
+type Execution struct {
+	Trace []NodeTrace // in the order nodes were added
+	Usage grail.Usage // summed across every node
+
+	values map[string]any
+}
+
+// Output returns ref's output from the run. ok is false if the node didn't
+// succeed.
+func Output[T any](e *Execution, ref Ref[T]) (v T, ok bool) {
+	if e == nil {
+		return v, false
+	}
+	raw, ok := e.values[ref.name]
+	if !ok {
+		return v, false
+	}
+	return raw.(T), true
+}
+
+// Run runs the graph with params bound by bindings. Nodes run as soon as
+// their dependencies succeed. When a node fails after its retries, the run is
+// canceled, nodes that haven't started are skipped, and the node's error is
+// returned with the execution so far.
+func (g *Graph) Run(ctx context.Context, bindings ...Binding) (*Execution, error) {
+	if g.err != nil {
+		return nil, g.err
+	}
+	bound := make(map[string]any, len(bindings))
+	for _, b := range bindings {
+		n := g.index[b.name]
+		if n == nil || !n.param {
+			return nil, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("flow: no param %q", b.name))
+		}
+		bound[b.name] = b.value
+	}
+	for _, n := range g.nodes {
+		if _, ok := bound[n.name]; n.param && !ok {
+			return nil, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("flow: param %q isn't set", n.name))
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	exec := &Execution{Trace: make([]NodeTrace, len(g.nodes)), values: map[string]any{}}
+	done := make(map[string]chan struct{}, len(g.nodes))
+	for _, n := range g.nodes {
+		done[n.name] = make(chan struct{})
+	}
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	for i, n := range g.nodes {
+		wg.Go(func() {
+			defer close(done[n.name])
+			trace := &exec.Trace[i]
+			trace.Node = n.name
+
+			in := Inputs{values: make(map[string]any, len(n.deps))}
+			for _, d := range n.deps {
+				<-done[d]
+				mu.Lock()
+				v, ok := exec.values[d]
+				mu.Unlock()
+				if !ok {
+					trace.Status = Skipped
+					return
+				}
+				in.values[d] = v
+			}
+			if ctx.Err() != nil {
+				trace.Status = Skipped
+				return
+			}
+			if n.param {
+				trace.Status, trace.Start = Succeeded, time.Now()
+				mu.Lock()
+				exec.values[n.name] = bound[n.name]
+				mu.Unlock()
+				return
+			}
+
+			v, err := g.runNode(ctx, n, in, trace)
+			mu.Lock()
+			defer mu.Unlock()
+			exec.Usage = exec.Usage.Add(trace.Usage)
+			if err != nil {
+				trace.Status, trace.Err = Failed, err
+				if firstErr == nil {
+					firstErr = grail.NewGrailError(grail.GetErrorCode(err), fmt.Sprintf("flow: node %s failed: %v", n.name, err)).
+						WithCause(err).WithDetail("node", n.name)
+					cancel()
+				}
+				return
+			}
+			trace.Status = Succeeded
+			exec.values[n.name] = v
+		})
+	}
+	wg.Wait()
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = grail.NewGrailError(grail.Timeout, "flow: run canceled").WithCause(ctx.Err())
+	}
+	return exec, firstErr
+}
+
+// runNode runs a node with its retries, recording them in trace.
+func (g *Graph) runNode(ctx context.Context, n *node, in Inputs, trace *NodeTrace) (any, error) {
+	trace.Start = time.Now()
+	defer func() { trace.Duration = time.Since(trace.Start) }()
+
+	var errs []error
+	backoff := n.cfg.backoff
+	for {
+		trace.Attempts++
+		actx, cancel := ctx, context.CancelFunc(func() {})
+		if n.cfg.timeout > 0 {
+			actx, cancel = context.WithTimeout(ctx, n.cfg.timeout)
+		}
+		v, res, err := n.run(actx, in, n.cfg)
+		cancel()
+		trace.Usage = trace.Usage.Add(res.Usage)
+		if len(res.Provider.Models) > 0 {
+			trace.Model = res.Provider.Models[0].Name
+		}
+		if err == nil {
+			return v, nil
+		}
+		errs = append(errs, err)
+		if trace.Attempts > n.cfg.retries || !n.cfg.retryIf(err) || ctx.Err() != nil {
+			if len(errs) == 1 {
+				return nil, err
+			}
+			return nil, grail.NewGrailError(grail.GetErrorCode(err), fmt.Sprintf("%d attempts: %v", trace.Attempts, err)).WithCause(errors.Join(errs...))
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.Join(append(errs, ctx.Err())...)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
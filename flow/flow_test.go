@@ -0,0 +1,122 @@
+package flow_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/flow"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+type outline struct {
+	Sections []string `json:"sections"`
+}
+
+func TestRun(t *testing.T) {
+	var flaky atomic.Int32
+	var models []string
+	mp := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			prompt, _ := grail.AsTextInput(req.Inputs[0])
+			models = append(models, req.Model+"/"+string(req.Tier)+"/"+req.Metadata["flow_node"])
+			switch {
+			case strings.HasPrefix(prompt, "Outline"):
+				return grail.Response{
+					Outputs: []grail.OutputPart{grail.NewJSONOutputPart([]byte(`{"sections":["intro","tides"]}`))},
+					Usage:   grail.Usage{InputTokens: 10, OutputTokens: 5, TotalTokens: 15},
+				}, nil
+			default:
+				if flaky.Add(1) == 1 {
+					return grail.Response{}, grail.NewGrailError(grail.Unavailable, "overloaded")
+				}
+				return grail.Response{
+					Outputs: []grail.OutputPart{grail.NewTextOutputPart("Draft: " + strings.TrimPrefix(prompt, "Draft "))},
+					Usage:   grail.Usage{InputTokens: 20, OutputTokens: 40, TotalTokens: 60},
+				}, nil
+			}
+		},
+	}
+	client := grail.NewClient(mp)
+
+	g := flow.New()
+	topic := flow.Param[string](g, "topic")
+	plan := flow.Generate[outline](g, "outline", client, func(in flow.Inputs) (grail.Request, error) {
+		return grail.Request{
+			Inputs: []grail.Input{grail.InputText("Outline " + flow.Get(in, topic))},
+			Output: grail.OutputJSON(map[string]any{"type": "object"}),
+		}, nil
+	}, flow.After(topic), flow.Tier(grail.ModelTierBest))
+	count := flow.Func(g, "count", func(ctx context.Context, in flow.Inputs) (int, error) {
+		return len(flow.Get(in, plan).Sections), nil
+	}, flow.After(plan))
+	draft := flow.Generate[string](g, "draft", client, func(in flow.Inputs) (grail.Request, error) {
+		return grail.Request{Inputs: []grail.Input{grail.InputText("Draft " + strings.Join(flow.Get(in, plan).Sections, ", "))}}, nil
+	}, flow.After(plan), flow.Model("mock-fast"), flow.Retries(2, time.Millisecond))
+
+	run, err := g.Run(context.Background(), flow.Set(topic, "tide pools"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n, ok := flow.Output(run, count); !ok || n != 2 {
+		t.Errorf("expected 2 sections, got %d", n)
+	}
+	if text, ok := flow.Output(run, draft); !ok || text != "Draft: intro, tides" {
+		t.Errorf("unexpected draft %q", text)
+	}
+	if models[0] != "/best/outline" || models[len(models)-1] != "mock-fast//draft" {
+		t.Errorf("expected per-node model config, got %q", models)
+	}
+
+	if len(run.Trace) != 4 {
+		t.Fatalf("expected a trace of 4 nodes, got %+v", run.Trace)
+	}
+	tr := run.Trace[3]
+	if tr.Node != "draft" || tr.Status != flow.Succeeded || tr.Attempts != 2 || tr.Err != nil || tr.Usage.TotalTokens != 60 {
+		t.Errorf("unexpected draft trace %+v", tr)
+	}
+	if run.Usage.TotalTokens != 75 {
+		t.Errorf("expected usage summed across nodes, got %+v", run.Usage)
+	}
+
+	// Graphs are reusable; a missing param fails before anything runs.
+	if _, err := g.Run(context.Background()); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected an unset param error, got %v", err)
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	var attempts atomic.Int32
+	g := flow.New()
+	fail := flow.Func(g, "fail", func(ctx context.Context, in flow.Inputs) (string, error) {
+		attempts.Add(1)
+		return "", errors.New("bad input")
+	}, flow.Retries(3, time.Millisecond))
+	after := flow.Func(g, "after", func(ctx context.Context, in flow.Inputs) (string, error) {
+		return flow.Get(in, fail), nil
+	}, flow.After(fail))
+
+	run, err := g.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "node fail") {
+		t.Fatalf("expected the failing node's error, got %v", err)
+	}
+	if attempts.Load() != 1 {
+		t.Errorf("expected a non-retryable error not to be retried, got %d attempts", attempts.Load())
+	}
+	if run.Trace[0].Status != flow.Failed || run.Trace[1].Status != flow.Skipped {
+		t.Errorf("unexpected trace %+v", run.Trace)
+	}
+	if _, ok := flow.Output(run, after); ok {
+		t.Error("expected no output from a skipped node")
+	}
+
+	other := flow.New()
+	flow.Func(other, "x", func(ctx context.Context, in flow.Inputs) (int, error) { return 0, nil }, flow.After(fail))
+	if _, err := other.Run(context.Background()); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected a cross-graph dependency error, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package grail_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func FuzzSniffImageMIME(f *testing.F) {
+	for _, seed := range [][]byte{
+		nil, []byte("\x89PNG\r\n\x1a\n"), {0xFF, 0xD8, 0xFF}, []byte("GIF89a"), []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), []byte("%PDF-1.7"),
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		mime := grail.SniffImageMIME(data)
+		if mime != "" && !strings.HasPrefix(mime, "image/") {
+			t.Fatalf("sniffed non-image type %q", mime)
+		}
+	})
+}
+
+func FuzzDetectMIMEFromPath(f *testing.F) {
+	for _, seed := range []string{"", ".", "file", "/tmp/file", "photo.PNG", "archive.tar.gz", "dir.d/file", "..", "a.b/c.pdf"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, path string) {
+		if grail.DetectMIMEFromPath(path) == "" {
+			t.Fatalf("empty MIME type for %q", path)
+		}
+	})
+}
+
+func FuzzGenerateValidation(f *testing.F) {
+	f.Add("hello", []byte("\x89PNG\r\n\x1a\n"), "")
+	f.Add("", []byte{}, "application/pdf")
+	f.Add("describe", []byte("%PDF-1.4"), "application/pdf")
+	f.Add("x", []byte("not an image"), "image/png")
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("ok")}}, nil
+		},
+	}
+	client := grail.NewClient(prov, grail.WithLogger(nil))
+	f.Fuzz(func(t *testing.T, text string, data []byte, mime string) {
+		inputs := []grail.Input{grail.InputText(text), grail.InputFile(data, mime)}
+		if mime == "" {
+			inputs = append(inputs, grail.InputImage(data))
+		}
+		_, err := client.Generate(context.Background(), grail.Request{Inputs: inputs, Output: grail.OutputText()})
+		if err != nil && grail.GetErrorCode(err) != grail.InvalidArgument {
+			t.Fatalf("expected success or invalid argument, got %v", err)
+		}
+	})
+}
+
+func FuzzInputFileFromDataURI(f *testing.F) {
+	for _, seed := range []string{
+		"data:,", "data:text/plain,hello%20world", "data:image/png;base64,iVBORw0KGgo=", "data:;base64,R0lGODlh",
+		"DATA:application/json;charset=utf-8,%7B%7D", "data:image/png;base64", "data:text/plain;base64,%%%", "data:a/b;x=\"y,z",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, uri string) {
+		in, err := grail.InputFileFromDataURI(uri)
+		if err != nil {
+			if grail.GetErrorCode(err) != grail.InvalidArgument {
+				t.Fatalf("expected invalid argument, got %v", err)
+			}
+			return
+		}
+		if _, mime, _, ok := grail.AsFileInput(in); !ok || mime == "" {
+			t.Fatalf("expected a typed file input from %q", uri)
+		}
+	})
+}
+
+func FuzzDataURIRoundTrip(f *testing.F) {
+	f.Add([]byte("hello"), "text/plain")
+	f.Add([]byte("\x89PNG\r\n\x1a\n"), "image/png")
+	f.Fuzz(func(t *testing.T, data []byte, mime string) {
+		if strings.ContainsAny(mime, ",;") || !strings.Contains(mime, "/") {
+			t.Skip()
+		}
+		in, err := grail.InputFileFromDataURI("data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data))
+		if err != nil {
+			// Not every fuzzed string is a valid media type.
+			return
+		}
+		got, _, _, _ := grail.AsFileInput(in)
+		if !bytes.Equal(got, data) {
+			t.Fatalf("round trip changed data: %q -> %q", data, got)
+		}
+	})
+}
@@ -20,7 +20,21 @@
 //   - providers - All providers (https://pkg.go.dev/github.com/montanaflynn/grail/providers)
 //   - providers/openai - OpenAI provider (https://pkg.go.dev/github.com/montanaflynn/grail/providers/openai)
 //   - providers/gemini - Google Gemini provider (https://pkg.go.dev/github.com/montanaflynn/grail/providers/gemini)
+//   - providers/ollama - Ollama provider for local models (https://pkg.go.dev/github.com/montanaflynn/grail/providers/ollama)
 //   - providers/mock - Mock provider (https://pkg.go.dev/github.com/montanaflynn/grail/providers/mock)
+//   - styles - Image style presets for WithStyle (https://pkg.go.dev/github.com/montanaflynn/grail/styles)
+//   - promptlint - Prompt linting (https://pkg.go.dev/github.com/montanaflynn/grail/promptlint)
+//   - vision - Image understanding helpers such as alt text and UI accessibility audits (https://pkg.go.dev/github.com/montanaflynn/grail/vision)
+//
+// WebAssembly:
+//
+// The client and providers build for js/wasm and wasip1/wasm. In browsers and
+// edge runtimes, Go's default HTTP transport uses fetch, and every network
+// call can be routed through a custom *http.Client: the client's
+// WithHTTPClient for URI downloads, and each provider's WithHTTPClient for API
+// calls. Helpers that touch the filesystem (InputFileFromPath, Save, journals,
+// wire dumps) compile everywhere but fail at run time where there is no
+// filesystem.
 package grail
 
 import (
@@ -32,8 +46,13 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/montanaflynn/grail/internal/httplog"
 )
 
 //
@@ -45,12 +64,15 @@ type ErrorCode string
 const (
 	InvalidArgument ErrorCode = "invalid_argument"
 	Unauthorized    ErrorCode = "unauthorized"
+	NotFound        ErrorCode = "not_found" // e.g. the requested model doesn't exist
 	RateLimited     ErrorCode = "rate_limited"
 	Timeout         ErrorCode = "timeout"
 	Unavailable     ErrorCode = "unavailable"
 	Unsupported     ErrorCode = "unsupported"
 	Refused         ErrorCode = "refused"
 	OutputInvalid   ErrorCode = "output_invalid"
+	PendingReview   ErrorCode = "pending_review"  // held by a ReviewGate; see ReviewID
+	BudgetExceeded  ErrorCode = "budget_exceeded" // a Budget's limit is spent
 	Internal        ErrorCode = "internal"
 )
 
@@ -60,6 +82,9 @@ type GrailError interface {
 	Retryable() bool
 	ProviderName() string
 	RequestID() string
+	// Details carries diagnostic context, such as the stack of a recovered
+	// panic. It may be nil.
+	Details() map[string]string
 }
 
 type grailError struct {
@@ -69,6 +94,7 @@ type grailError struct {
 	retryable    bool
 	providerName string
 	requestID    string
+	details      map[string]string
 }
 
 func (e *grailError) Error() string {
@@ -101,6 +127,10 @@ func (e *grailError) RequestID() string {
 	return e.requestID
 }
 
+func (e *grailError) Details() map[string]string {
+	return e.details
+}
+
 func NewGrailError(code ErrorCode, message string) *grailError {
 	return &grailError{
 		code:    code,
@@ -128,6 +158,14 @@ func (e *grailError) WithRequestID(id string) *grailError {
 	return e
 }
 
+func (e *grailError) WithDetail(key, value string) *grailError {
+	if e.details == nil {
+		e.details = map[string]string{}
+	}
+	e.details[key] = value
+	return e
+}
+
 func IsRetryable(err error) bool {
 	var ge GrailError
 	if errors.As(err, &ge) {
@@ -152,6 +190,30 @@ func IsRefused(err error) bool {
 	return GetErrorCode(err) == Refused
 }
 
+func IsNotFound(err error) bool {
+	return GetErrorCode(err) == NotFound
+}
+
+// CodeFromHTTPStatus maps a provider API's HTTP status to an error code, and
+// reports whether a request failing with it is worth retrying.
+func CodeFromHTTPStatus(status int) (code ErrorCode, retryable bool) {
+	switch {
+	case status == http.StatusBadRequest, status == http.StatusRequestEntityTooLarge, status == http.StatusUnprocessableEntity:
+		return InvalidArgument, false
+	case status == http.StatusNotFound:
+		return NotFound, false
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return Unauthorized, false
+	case status == http.StatusTooManyRequests:
+		return RateLimited, true
+	case status == http.StatusRequestTimeout, status == http.StatusGatewayTimeout:
+		return Timeout, true
+	case status >= 500:
+		return Unavailable, true
+	}
+	return Internal, false
+}
+
 func GetErrorCode(err error) ErrorCode {
 	var ge GrailError
 	if err == nil {
@@ -171,6 +233,28 @@ type Usage struct {
 	InputTokens  int
 	OutputTokens int
 	TotalTokens  int
+	// CachedInputTokens is the part of InputTokens read from the provider's
+	// prompt cache, which is billed at a discount. Zero if the provider
+	// doesn't report it.
+	CachedInputTokens int `json:",omitempty"`
+	// ImageInputTokens and ImageOutputTokens are the parts of InputTokens and
+	// OutputTokens that were images, which some models bill at their own
+	// rates. Zero if the provider doesn't report them.
+	ImageInputTokens  int `json:",omitempty"`
+	ImageOutputTokens int `json:",omitempty"`
+}
+
+// Add returns the sum of u and o, for aggregating usage across multiple calls.
+func (u Usage) Add(o Usage) Usage {
+	return Usage{
+		InputTokens:  u.InputTokens + o.InputTokens,
+		OutputTokens: u.OutputTokens + o.OutputTokens,
+		TotalTokens:  u.TotalTokens + o.TotalTokens,
+
+		CachedInputTokens: u.CachedInputTokens + o.CachedInputTokens,
+		ImageInputTokens:  u.ImageInputTokens + o.ImageInputTokens,
+		ImageOutputTokens: u.ImageOutputTokens + o.ImageOutputTokens,
+	}
 }
 
 type Warning struct {
@@ -186,11 +270,19 @@ type ProviderInfo struct {
 	Name   string
 	Route  string // provider-defined (e.g. "responses", "images")
 	Models []ModelUse
+	// Region is the data residency region or location of the endpoint that
+	// served the request (e.g. "eu", "europe-west4"), as configured on the
+	// provider. Empty for the provider's default, global endpoint.
+	Region string `json:",omitempty"`
 }
 
 type ModelUse struct {
 	Role string // "language", "image_generation", "moderation", etc.
-	Name string // provider-native model identifier
+	Name string // provider-native model identifier, as requested
+	// Version is the model snapshot the provider reports serving the request
+	// (e.g. "gpt-5.2-2025-12-11" for "gpt-5.2"), for reproducibility
+	// records. Empty if the provider doesn't report one.
+	Version string `json:",omitempty"`
 }
 
 // ModelRole describes the primary function of a model.
@@ -199,6 +291,7 @@ type ModelRole string
 const (
 	ModelRoleText  ModelRole = "text"  // Text/language generation
 	ModelRoleImage ModelRole = "image" // Image generation
+	ModelRoleVideo ModelRole = "video" // Video generation
 )
 
 // ModelTier describes the quality/speed trade-off of a model.
@@ -213,7 +306,7 @@ const (
 // Providers export these as package-level variables for easy reference.
 type Model struct {
 	Name         string            // Model identifier (e.g., "gpt-5.4", "gemini-3.1-pro-preview")
-	Role         ModelRole         // text or image
+	Role         ModelRole         // text, image, or video
 	Tier         ModelTier         // best or fast
 	Capabilities ModelCapabilities // What the model can do
 }
@@ -225,6 +318,7 @@ func (m Model) String() string { return m.Name }
 type ModelCapabilities struct {
 	TextGeneration     bool // Can generate text from text input
 	ImageGeneration    bool // Can generate images from text input
+	VideoGeneration    bool // Can generate videos from text or image input
 	ImageUnderstanding bool // Can understand/describe images
 	PDFUnderstanding   bool // Can understand/extract from PDFs
 	JSONOutput         bool // Can output structured JSON
@@ -253,19 +347,31 @@ type ModelCatalog interface {
 type Input interface{ isInput() }
 
 type textInput struct {
-	Text string
+	Text            string
+	CacheBreakpoint bool
+	Droppable       bool
+	Priority        int
 }
 
 func (textInput) isInput() {}
 
-func InputText(s string) Input {
-	return textInput{Text: s}
+func InputText(s string, opts ...TextOpt) Input {
+	to := &textOpt{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt.applyTextOpt(to)
+		}
+	}
+	return textInput{Text: s, CacheBreakpoint: to.cacheBreakpoint, Droppable: to.droppable, Priority: to.priority}
 }
 
 type fileInput struct {
-	Data []byte
-	MIME string
-	Name string // optional filename
+	Data            []byte
+	MIME            string
+	Name            string // optional filename
+	CacheBreakpoint bool
+	Droppable       bool
+	Priority        int
 }
 
 func (fileInput) isInput() {}
@@ -285,6 +391,8 @@ func InputFile(data []byte, mime string, opts ...FileOpt) Input {
 	if fo.name != "" {
 		fi.Name = fo.name
 	}
+	fi.CacheBreakpoint = fo.cacheBreakpoint
+	fi.Droppable, fi.Priority = fo.droppable, fo.priority
 	return fi
 }
 
@@ -299,10 +407,13 @@ func InputImage(data []byte, opts ...FileOpt) Input {
 }
 
 type fileReaderInput struct {
-	R    io.Reader
-	Size int64 // -1 if unknown
-	MIME string
-	Name string
+	R               io.Reader
+	Size            int64 // -1 if unknown
+	MIME            string
+	Name            string
+	CacheBreakpoint bool
+	Droppable       bool
+	Priority        int
 }
 
 func (fileReaderInput) isInput() {}
@@ -322,6 +433,8 @@ func InputFileReader(r io.Reader, size int64, mime string, opts ...FileOpt) Inpu
 	if fo.name != "" {
 		fri.Name = fo.name
 	}
+	fri.CacheBreakpoint = fo.cacheBreakpoint
+	fri.Droppable, fri.Priority = fo.droppable, fo.priority
 	return fri
 }
 
@@ -356,12 +469,24 @@ func NewTextOutputPart(text string) OutputPart {
 	return textOutputPart{Text: text}
 }
 
-func NewImageOutputPart(data []byte, mime, name string) OutputPart {
-	return imageOutputPart{Data: data, MIME: mime, Name: name}
+func NewImageOutputPart(data []byte, mime, name string, opts ...ImagePartOpt) OutputPart {
+	o := &imagePartOpt{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt.applyImagePartOpt(o)
+		}
+	}
+	return imageOutputPart{Data: data, MIME: mime, Name: name, SynthID: o.synthID}
 }
 
-func NewJSONOutputPart(jsonData []byte) OutputPart {
-	return jsonOutputPart{JSON: jsonData}
+func NewJSONOutputPart(jsonData []byte, opts ...JSONPartOpt) OutputPart {
+	jo := &jsonPartOpt{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt.applyJSONPartOpt(jo)
+		}
+	}
+	return jsonOutputPart{JSON: jsonData, Logprobs: jo.logprobs}
 }
 
 // Output type checking helpers for providers
@@ -390,12 +515,21 @@ func GetJSONOutput(output Output) (schema any, strict bool, ok bool) {
 
 type Output interface{ isOutput() }
 
-type textOutput struct{}
+type textOutput struct {
+	Enum    []string // the answer must be one of these (see WithEnum)
+	Pattern string   // the answer must match this regexp (see WithPattern)
+}
 
 func (textOutput) isOutput() {}
 
-func OutputText() Output {
-	return textOutput{}
+func OutputText(opts ...TextOutputOpt) Output {
+	to := textOutput{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt.applyTextOutputOpt(&to)
+		}
+	}
+	return to
 }
 
 type ImageSpec struct {
@@ -413,8 +547,9 @@ func OutputImage(spec ImageSpec) Output {
 }
 
 type jsonOutput struct {
-	Schema any
-	Strict bool // default true
+	Schema     any
+	Strict     bool               // default true
+	Confidence []ConfidenceMethod // score each field (see WithConfidence)
 }
 
 func (jsonOutput) isOutput() {}
@@ -433,6 +568,7 @@ func OutputJSON(schema any, opts ...JSONOpt) Output {
 	if joOpt.strict != nil {
 		jo.Strict = *joOpt.strict
 	}
+	jo.Confidence = joOpt.confidence
 	return jo
 }
 
@@ -449,15 +585,20 @@ type textOutputPart struct {
 func (textOutputPart) isOutputPart() {}
 
 type imageOutputPart struct {
-	Data []byte
-	MIME string
-	Name string
+	Data      []byte
+	MIME      string
+	Name      string
+	SynthID   bool       // provider reports an invisible SynthID watermark
+	Thumbnail []byte     // set by image post-processing
+	Hash      *ImageHash // set by image post-processing
 }
 
 func (imageOutputPart) isOutputPart() {}
 
 type jsonOutputPart struct {
-	JSON []byte
+	JSON       []byte
+	Logprobs   []TokenLogprob     // set by providers for ConfidenceLogprobs
+	Confidence map[string]float64 // set by the client (see WithConfidence)
 }
 
 func (jsonOutputPart) isOutputPart() {}
@@ -471,8 +612,11 @@ type Request struct {
 	Output          Output
 	Model           string    // Optional: explicit model name (highest priority)
 	Tier            ModelTier // Optional: tier-based selection (if Model not set)
+	Priority        Priority  // Optional: scheduling priority when quota is constrained
 	ProviderOptions []ProviderOption
 	Metadata        map[string]string
+	Tools           []Tool    // Optional: functions the model may call
+	History         []Message // Optional: earlier turns of the conversation, oldest first
 }
 
 type Response struct {
@@ -481,6 +625,15 @@ type Response struct {
 	Provider  ProviderInfo
 	RequestID string
 	Warnings  []Warning
+	// Cost is the response's estimated cost, from its usage and the price of
+	// the model that answered (see WithPrices). Nil if that model has no
+	// price.
+	Cost *Cost
+
+	request   *Request          // the request as the caller sent it, for Regenerate
+	recorded  *RequestRecord    // the request recorded with a ResponseRecord
+	proofread []ProofreadResult // see WithProofreading
+	blobs     BlobStore         // outputs were saved to it (see WithBlobStore)
 }
 
 func (r Response) Text() (string, bool) {
@@ -492,6 +645,18 @@ func (r Response) Text() (string, bool) {
 	return "", false
 }
 
+// Texts returns every text output part, for providers that return several
+// candidates for one request.
+func (r Response) Texts() []string {
+	var texts []string
+	for _, part := range r.Outputs {
+		if textPart, ok := part.(textOutputPart); ok {
+			texts = append(texts, textPart.Text)
+		}
+	}
+	return texts
+}
+
 func (r Response) Images() ([][]byte, bool) {
 	var images [][]byte
 	for _, part := range r.Outputs {
@@ -507,7 +672,14 @@ func (r Response) ImageOutputs() []ImageOutputInfo {
 	var infos []ImageOutputInfo
 	for _, part := range r.Outputs {
 		if imgPart, ok := part.(imageOutputPart); ok {
-			infos = append(infos, ImageOutputInfo(imgPart))
+			infos = append(infos, ImageOutputInfo{
+				Data:        imgPart.Data,
+				MIME:        imgPart.MIME,
+				Name:        imgPart.Name,
+				Credentials: contentCredentials(imgPart),
+				Thumbnail:   imgPart.Thumbnail,
+				Hash:        imgPart.Hash,
+			})
 		}
 	}
 	return infos
@@ -515,9 +687,12 @@ func (r Response) ImageOutputs() []ImageOutputInfo {
 
 // ImageOutputInfo contains image data with MIME and optional name.
 type ImageOutputInfo struct {
-	Data []byte
-	MIME string
-	Name string
+	Data        []byte
+	MIME        string
+	Name        string
+	Credentials ContentCredentials // content-credential metadata (C2PA, SynthID)
+	Thumbnail   []byte             // same format as Data (PNG for WebP); set by WithImagePostProcessing
+	Hash        *ImageHash         // perceptual hashes; set by WithImagePostProcessing with PerceptualHash
 }
 
 func (r Response) DecodeJSON(dst any) error {
@@ -542,7 +717,11 @@ type ProviderOption interface {
 //
 
 type FileOpt interface{ applyFileOpt(*fileOpt) }
+type TextOpt interface{ applyTextOpt(*textOpt) }
 type JSONOpt interface{ applyJSONOpt(*jsonOpt) }
+type TextOutputOpt interface{ applyTextOutputOpt(*textOutput) }
+type ImagePartOpt interface{ applyImagePartOpt(*imagePartOpt) }
+type JSONPartOpt interface{ applyJSONPartOpt(*jsonPartOpt) }
 
 func WithFileName(name string) FileOpt {
 	return fileOptFunc(func(fo *fileOpt) {
@@ -556,7 +735,18 @@ func WithStrictJSON(strict bool) JSONOpt {
 	})
 }
 
-type fileOpt struct{ name string }
+type fileOpt struct {
+	name            string
+	cacheBreakpoint bool
+	droppable       bool
+	priority        int
+}
+
+type textOpt struct {
+	cacheBreakpoint bool
+	droppable       bool
+	priority        int
+}
 
 type fileOptFunc func(*fileOpt)
 
@@ -564,7 +754,18 @@ func (f fileOptFunc) applyFileOpt(fo *fileOpt) {
 	f(fo)
 }
 
-type jsonOpt struct{ strict *bool }
+type imagePartOpt struct{ synthID bool }
+
+type imagePartOptFunc func(*imagePartOpt)
+
+func (f imagePartOptFunc) applyImagePartOpt(o *imagePartOpt) {
+	f(o)
+}
+
+type jsonOpt struct {
+	strict     *bool
+	confidence []ConfidenceMethod
+}
 
 type jsonOptFunc func(*jsonOpt)
 
@@ -593,6 +794,40 @@ type Client interface {
 	// GetModel returns the model matching the given role and tier.
 	// Returns an error if no matching model is found.
 	GetModel(ctx context.Context, role ModelRole, tier ModelTier) (Model, error)
+
+	// Capabilities returns the provider's capabilities; ok is false when the
+	// provider doesn't report them.
+	Capabilities() (caps ProviderCapabilities, ok bool)
+
+	// With returns a child client that shares this client's provider (and its
+	// connection pools) but applies opts on top of this client's options.
+	With(opts ...ClientOption) Client
+
+	// Close stops accepting new calls, waits for in-flight ones until ctx is
+	// done, and releases the provider's resources.
+	Close(ctx context.Context) error
+
+	// Regenerate revises prev's output according to feedback by sending the
+	// request that produced it again with the output and feedback appended.
+	Regenerate(ctx context.Context, prev Response, feedback string) (Response, error)
+
+	// Transcribe transcribes speech from an audio file input, with the
+	// provider's speech-to-text endpoint if it has one (see Transcriber).
+	Transcribe(ctx context.Context, audio Input, opts ...TranscribeOpt) (Transcription, error)
+
+	// Speak turns text into speech with the provider's text-to-speech
+	// endpoint (see SpeechSynthesizer).
+	Speak(ctx context.Context, text string, opts ...SpeakOpt) (Speech, error)
+
+	// UploadFile uploads a file input to the provider (see FileUploader)
+	// and returns an input that references it by ID.
+	UploadFile(ctx context.Context, file Input) (Input, error)
+
+	// Stats returns a snapshot of the client's activity (see Handler).
+	Stats() ClientStats
+
+	// Events returns the bus the client's lifecycle events are delivered on.
+	Events() *EventBus
 }
 
 type ClientOption interface{ applyClientOpt(*clientOpt) }
@@ -623,10 +858,40 @@ type ProviderExecutor interface {
 }
 
 type clientOpt struct {
-	httpClient       *http.Client
-	downloadMaxBytes int64
-	downloadTimeout  time.Duration
-	logger           *slog.Logger
+	httpClient        *http.Client
+	downloadMaxBytes  int64
+	downloadTimeout   time.Duration
+	logger            *slog.Logger
+	imageProcessing   *ImageProcessing
+	defaults          *Request
+	transportLogLevel *slog.Level
+	wireDumpDir       string
+	sizeLimits        *SizeLimits
+	imageSafety       *ImageSafety
+	usageTracker      *UsageTracker
+	scheduler         *Scheduler
+	closeHooks        []func(context.Context) error
+	middleware        []Middleware
+	postProcessors    []PostProcessor
+	staleModelCheck   bool
+	modelFallback     bool
+	airGap            *AirGap
+	attemptDeadlines  int
+	style             string
+	attachments       *AttachmentStore
+	outputRetries     *int
+	reviewGate        *ReviewGate
+	locale            string
+	proofreading      *Proofreading
+	auditLog          AuditLog
+	prices            PriceTable
+	blobs             BlobStore
+	budget            *clientBudget
+	tlsPolicy         *TLSPolicy
+	pdfImages         *PDFImageFallback
+	metrics           MetricsRecorder
+	textConverters    map[string]TextConverter
+	optionDecoder     OptionDecoder
 }
 
 type clientOptFunc func(*clientOpt)
@@ -659,6 +924,12 @@ type ModelDescriber interface {
 	DescribeModels(req Request) string
 }
 
+// PriceLister is an optional interface for providers to publish the prices
+// of their models, so responses get an estimated Cost (see WithPrices).
+type PriceLister interface {
+	Prices() PriceTable
+}
+
 // WithLogger sets a custom logger for client-level logs.
 func WithLogger(l *slog.Logger) ClientOption {
 	return clientOptFunc(func(co *clientOpt) {
@@ -698,11 +969,27 @@ func WithLoggerFormat(format string, level LoggerLevel) ClientOption {
 }
 
 type client struct {
+	opts             clientOpt // options the client was built from, for With
 	provider         ProviderExecutor
 	httpClient       *http.Client
 	downloadMaxBytes int64
 	downloadTimeout  time.Duration
 	log              *slog.Logger
+	imageProcessing  *ImageProcessing
+	defaults         *Request
+	countAttempts    bool // number HTTP attempts per Generate for transport logging
+	budgetAttempts   bool // split the deadline across HTTP attempts
+	egressGuarded    bool // provider transport restricted by WithAirGap
+	tlsEnforced      bool // provider transport configured by WithTLSPolicy
+	sizeLimits       *SizeLimits
+	imageSafety      *ImageSafety
+	usageTracker     *UsageTracker
+	scheduler        *Scheduler
+	life             *lifecycle      // shared with children
+	stats            *clientStats    // shared with children
+	modelCheck       *sync.Once      // shared with children
+	events           *EventBus       // shared with children
+	transport        *transportScope // shared with children
 }
 
 func NewClient(p Provider, opts ...ClientOption) Client {
@@ -718,39 +1005,117 @@ func NewClient(p Provider, opts ...ClientOption) Client {
 		}
 	}
 
+	c := newClient(*co)
+
 	executor, ok := p.(ProviderExecutor)
 	if !ok {
 		// This should not happen in practice, but handle gracefully
-		return &client{
-			provider:         nil,
-			httpClient:       co.httpClient,
-			downloadMaxBytes: co.downloadMaxBytes,
-			downloadTimeout:  co.downloadTimeout,
-			log:              co.logger,
-		}
+		return c
 	}
+	c.provider = executor
 
 	if la, ok := p.(LoggerAware); ok {
 		la.SetLogger(co.logger)
 	}
 
+	if co.tlsPolicy != nil {
+		c.enforceTLS(*co.tlsPolicy)
+	}
+	c.installTransport(p, co)
+
+	return c
+}
+
+func newClient(co clientOpt) *client {
 	return &client{
-		provider:         executor,
+		opts:             co,
 		httpClient:       co.httpClient,
 		downloadMaxBytes: co.downloadMaxBytes,
 		downloadTimeout:  co.downloadTimeout,
 		log:              co.logger,
+		imageProcessing:  co.imageProcessing,
+		defaults:         co.defaults,
+		sizeLimits:       co.sizeLimits,
+		imageSafety:      co.imageSafety,
+		usageTracker:     co.usageTracker,
+		scheduler:        co.scheduler,
+		life:             &lifecycle{hooks: slices.Clip(co.closeHooks)},
+		stats:            &clientStats{},
+		modelCheck:       &sync.Once{},
+		events:           &EventBus{},
 	}
 }
 
 func (c *client) Generate(ctx context.Context, req Request) (Response, error) {
-	if err := validateRequest(req); err != nil {
+	if err := c.life.enter(); err != nil {
 		return Response{}, err
 	}
+	defer c.life.leave()
+	c.checkModelsOnce()
+
+	start := time.Now()
+	ctx = c.events.start(ctx, req)
+	ctx, stream := c.takeTextStream(ctx)
+	res, err := c.generate(ctx, req)
+	if err == nil {
+		res.request = &req
+	}
+	res, err = c.review(ctx, req, res, err)
+	if err == nil && stream != nil {
+		stream.finish(req, res)
+	}
+	c.stats.record(time.Since(start), res, err)
+	if c.opts.metrics != nil {
+		c.recordMetrics(req, res, err, time.Since(start))
+	}
+	c.events.finish(ctx, req, res, err, time.Since(start))
+	if c.opts.auditLog != nil {
+		c.audit(ctx, req, res, err, start)
+	}
+	return res, err
+}
+
+func (c *client) Events() *EventBus {
+	return c.events
+}
+
+// preparedRequest is a request ready for dispatch: defaults applied, model
+// resolved, and pre-flight checks passed.
+type preparedRequest struct {
+	req        Request
+	fallback   bool               // JSON is extracted from a text response
+	jsonOut    jsonOutput         // the original output, with fallback
+	confidence []ConfidenceMethod // fields are scored (see WithConfidence)
+	locale     *locale            // text is formatted for it (see WithLocale)
+	warnings   []Warning          // set on the response, such as for dropped inputs
+}
+
+// prepare applies defaults and model selection to req and runs the checks
+// that don't need the provider to answer.
+func (c *client) prepare(ctx context.Context, req Request) (preparedRequest, error) {
+	req.Metadata = mergeMetadata(MetadataFromContext(ctx), req.Metadata)
+	if c.defaults != nil {
+		req = mergeRequest(*c.defaults, req)
+	}
+
+	if err := validateRequest(req); err != nil {
+		return preparedRequest{}, err
+	}
 
 	if c.provider == nil {
-		return Response{}, NewGrailError(Internal, "provider executor not available")
+		return preparedRequest{}, NewGrailError(Internal, "provider executor not available")
+	}
+	if err := c.checkAirGap(); err != nil {
+		return preparedRequest{}, err
+	}
+	if err := c.checkTLSPolicy(); err != nil {
+		return preparedRequest{}, err
 	}
+	req, err := c.applyStyle(req)
+	if err != nil {
+		return preparedRequest{}, err
+	}
+	var p preparedRequest
 
 	// Resolve model selection: Model > Tier > Provider default
 	if req.Model == "" && req.Tier != "" {
@@ -758,17 +1123,109 @@ func (c *client) Generate(ctx context.Context, req Request) (Response, error) {
 		if resolver, ok := c.provider.(ModelResolver); ok {
 			resolved, err := resolver.ResolveModel(role, req.Tier)
 			if err != nil {
-				return Response{}, NewGrailError(InvalidArgument, fmt.Sprintf("failed to resolve model for role=%s tier=%s: %v", role, req.Tier, err)).WithCause(err)
+				return preparedRequest{}, NewGrailError(InvalidArgument, fmt.Sprintf("failed to resolve model for role=%s tier=%s: %v", role, req.Tier, err)).WithCause(err)
 			}
 			req.Model = resolved
 		}
 	}
 
+	// Providers that can't take earlier turns as messages get them written
+	// out at the start of the prompt.
+	if len(req.History) > 0 {
+		if caps, ok := c.Capabilities(); !ok || !caps.History {
+			req.Inputs = append(historyPreamble(req.History), req.Inputs...)
+			req.History = nil
+		}
+	}
+
+	// Confidence scores are asked for with the answer or read from token log
+	// probabilities.
+	if out, ok := req.Output.(jsonOutput); ok && len(out.Confidence) > 0 {
+		if req, err = c.prepareConfidence(req, out); err != nil {
+			return preparedRequest{}, err
+		}
+		p.confidence = out.Confidence
+	}
+
+	// Models without JSON output get schema instructions in the prompt and
+	// have their JSON extracted from the text response.
+	if p.fallback = c.needsJSONFallback(req); p.fallback {
+		p.jsonOut = req.Output.(jsonOutput)
+		req = jsonFallbackRequest(req, p.jsonOut)
+	} else if out, ok := req.Output.(jsonOutput); ok && slices.Contains(out.Confidence, ConfidenceReported) {
+		// The fallback's instructions carry the wrapped schema; other
+		// providers may not pass it to the model.
+		if caps, ok := c.Capabilities(); !ok || !caps.NativeJSON {
+			req.Inputs = append(req.Inputs[:len(req.Inputs):len(req.Inputs)], InputText(confidenceInstructions(out)))
+		}
+	}
+	// Constrained text answers are asked for explicitly, whether or not the
+	// provider also enforces them.
+	if out, ok := req.Output.(textOutput); ok {
+		if instructions := constraintInstructions(out); instructions != "" {
+			req.Inputs = append(req.Inputs[:len(req.Inputs):len(req.Inputs)], InputText(instructions))
+		} else if c.opts.locale != "" {
+			if p.locale, err = parseLocale(c.opts.locale); err != nil {
+				return preparedRequest{}, err
+			}
+			req.Inputs = append(req.Inputs[:len(req.Inputs):len(req.Inputs)], InputText(p.locale.instructions()))
+		}
+	}
+
+	var warnings []Warning
+	if c.opts.pdfImages != nil {
+		if target, ok := c.pdfImagesTarget(req); ok {
+			if req, warnings, err = c.rasterizePDFs(ctx, req, target); err != nil {
+				return preparedRequest{}, err
+			}
+			p.warnings = append(p.warnings, warnings...)
+		}
+	}
+	if c.opts.textConverters != nil {
+		if req, warnings, err = c.convertFiles(ctx, req); err != nil {
+			return preparedRequest{}, err
+		}
+		p.warnings = append(p.warnings, warnings...)
+	}
+	if req, warnings, err = c.checkCapabilities(req); err != nil {
+		return preparedRequest{}, err
+	}
+	p.warnings = append(p.warnings, warnings...)
+
 	// Validate model capabilities if model is specified and provider supports model listing
 	if req.Model != "" {
-		if err := c.validateModelCapabilities(req); err != nil {
+		if req, warnings, err = c.validateModelCapabilities(req); err != nil {
+			return preparedRequest{}, err
+		}
+		p.warnings = append(p.warnings, warnings...)
+	}
+
+	if c.sizeLimits != nil {
+		if req, warnings, err = c.checkRequestSize(req, *c.sizeLimits); err != nil {
+			return preparedRequest{}, err
+		}
+		p.warnings = append(p.warnings, warnings...)
+	}
+	p.req = req
+	return p, nil
+}
+
+func (c *client) generate(ctx context.Context, req Request) (Response, error) {
+	ctx = c.withTransport(ctx)
+	p, err := c.prepare(ctx, req)
+	if err != nil {
+		return Response{}, err
+	}
+	req, fallback, jsonOut, warnings := p.req, p.fallback, p.jsonOut, p.warnings
+	var hold *budgetHold
+	if c.opts.budget != nil {
+		var trimmed []Warning
+		if req, trimmed, hold, err = c.checkBudget(req); err != nil {
 			return Response{}, err
 		}
+		// Calls that fail before reaching the provider cost nothing.
+		defer hold.release()
+		warnings = append(warnings, trimmed...)
 	}
 
 	if c.log != nil {
@@ -777,48 +1234,140 @@ func (c *client) Generate(ctx context.Context, req Request) (Response, error) {
 		if describer, ok := c.provider.(ModelDescriber); ok {
 			models = describer.DescribeModels(req)
 		}
-		c.log.Info("generate request",
+		attrs := []any{
 			slog.Int("inputs", len(req.Inputs)),
 			slog.String("output_type", getOutputType(req.Output)),
 			slog.String("model", models),
-		)
+		}
+		if len(req.Metadata) > 0 {
+			attrs = append(attrs, metadataAttr(req.Metadata))
+		}
+		c.log.Info("generate request", attrs...)
 	}
 
-	return c.provider.DoGenerate(ctx, req)
-}
+	var release func(Usage)
+	if c.scheduler != nil {
+		var err error
+		if release, err = c.scheduler.Acquire(ctx, req.Model, estimateTokens(req), req.Priority); err != nil {
+			return Response{}, NewGrailError(Timeout, fmt.Sprintf("waiting for quota: %v", err)).WithCause(err)
+		}
+	}
 
-// validateModelCapabilities checks if the requested model supports the required capabilities.
-func (c *client) validateModelCapabilities(req Request) error {
-	lister, ok := c.provider.(ModelLister)
-	if !ok {
-		// Provider doesn't support model listing, skip validation
-		return nil
+	// Uploads happen before attempts are counted, so they don't take the
+	// generation's share of the deadline.
+	if c.opts.attachments != nil {
+		if req, err = c.opts.attachments.substitute(ctx, c.provider, c.opts.blobs, req); err != nil {
+			if release != nil {
+				release(Usage{})
+			}
+			return Response{}, err
+		}
 	}
 
-	models, err := lister.ListModels(context.Background())
+	if c.countAttempts {
+		ctx = httplog.WithAttemptCounter(ctx)
+	}
+	if c.budgetAttempts {
+		ctx = withAttemptBudget(ctx)
+	}
+
+	res, err := c.callProvider(ctx, req)
+	if err != nil && c.egressGuarded {
+		err = c.blockedEgress(err)
+	}
+	if err != nil && c.tlsEnforced {
+		err = c.tlsRejected(err)
+	}
+	if err != nil && c.opts.modelFallback {
+		res, err = c.retryWithFallbackModel(ctx, req, err)
+	}
+	if err == nil && c.hasConstraints(p) {
+		p.req = req
+		res, err = c.enforceConstraints(ctx, p, res)
+	}
+	if release != nil {
+		release(res.Usage)
+	}
+	if hold != nil {
+		hold.settle(c.price(req, res), res.Usage, err)
+	}
 	if err != nil {
-		// Can't list models, skip validation
-		return nil
+		return res, err
 	}
 
-	// Find the model by name
-	var model *Model
-	for i := range models {
-		if models[i].Name == req.Model {
-			model = &models[i]
-			break
+	if fallback {
+		if err := finishJSONFallback(&res, jsonOut); err != nil {
+			return Response{}, err
+		}
+	}
+	if len(p.confidence) > 0 {
+		scoreConfidence(&res, p.confidence)
+	}
+
+	if c.imageSafety != nil {
+		if err := checkImageSafety(ctx, &res, *c.imageSafety); err != nil {
+			return Response{}, err
+		}
+	}
+
+	if c.imageProcessing != nil {
+		if err := processImageOutputs(&res, *c.imageProcessing); err != nil {
+			return Response{}, err
+		}
+	}
+
+	pp := c.opts.postProcessors
+	if p.locale != nil {
+		// Locale formatting runs first, so processors see the final text.
+		pp = append([]PostProcessor{p.locale.postProcessor()}, pp...)
+	}
+	if len(pp) > 0 {
+		// Processors see the merged request metadata, not just the context's.
+		ppCtx := context.WithValue(ctx, metadataKey{}, req.Metadata)
+		if err := postProcess(ppCtx, &res, pp); err != nil {
+			return Response{}, err
+		}
+	}
+
+	if c.opts.proofreading != nil {
+		if out, ok := req.Output.(textOutput); ok && constraintInstructions(out) == "" {
+			if err := c.proofread(ctx, req, &res, *c.opts.proofreading); err != nil {
+				return Response{}, err
+			}
 		}
 	}
 
+	res.Warnings = append(res.Warnings, warnings...)
+	if c.sizeLimits != nil {
+		if err := c.checkResponseSize(&res, *c.sizeLimits); err != nil {
+			return Response{}, err
+		}
+	}
+
+	if c.opts.blobs != nil {
+		c.saveOutputs(ctx, &res)
+	}
+	res.Cost = c.price(req, res)
+	if c.usageTracker != nil {
+		c.usageTracker.Record(req, res)
+	}
+
+	return res, nil
+}
+
+// validateModelCapabilities checks if the requested model supports the required capabilities,
+// dropping droppable inputs it can't understand.
+func (c *client) validateModelCapabilities(req Request) (Request, []Warning, error) {
+	model := c.lookupModel(req.Model)
 	if model == nil {
 		// Model not in catalog, skip validation (might be a custom/new model)
-		return nil
+		return req, nil, nil
 	}
 
 	// Check capabilities based on output type
 	if IsTextOutput(req.Output) {
 		if !model.Capabilities.TextGeneration {
-			return NewGrailError(InvalidArgument,
+			return req, nil, NewGrailError(InvalidArgument,
 				fmt.Sprintf("model %q does not support text generation; try a text model like one with TextGeneration capability", req.Model))
 		}
 	}
@@ -827,37 +1376,68 @@ func (c *client) validateModelCapabilities(req Request) error {
 		// Skip check if the model is a text model (used for orchestration in some providers like OpenAI)
 		// where the actual image model is specified in ProviderOptions
 		if !model.Capabilities.ImageGeneration && !model.Capabilities.TextGeneration {
-			return NewGrailError(InvalidArgument,
+			return req, nil, NewGrailError(InvalidArgument,
 				fmt.Sprintf("model %q does not support image generation; try an image model like one with ImageGeneration capability", req.Model))
 		}
 	}
 
+	if _, isVideo := GetVideoSpec(req.Output); isVideo {
+		if !model.Capabilities.VideoGeneration {
+			return req, nil, NewGrailError(InvalidArgument,
+				fmt.Sprintf("model %q does not support video generation; try a model with VideoGeneration capability", req.Model))
+		}
+	}
+
 	if _, _, isJSON := GetJSONOutput(req.Output); isJSON {
 		if !model.Capabilities.JSONOutput {
-			return NewGrailError(InvalidArgument,
+			return req, nil, NewGrailError(InvalidArgument,
 				fmt.Sprintf("model %q does not support JSON output; try a model with JSONOutput capability", req.Model))
 		}
 	}
 
-	// Validate input capabilities
-	for _, input := range req.Inputs {
-		if data, mime, _, isFile := AsFileInput(input); isFile {
-			// Check for image input
-			if mime == "" {
-				mime = SniffImageMIME(data)
-			}
-			if strings.HasPrefix(mime, "image/") && !model.Capabilities.ImageUnderstanding {
-				return NewGrailError(InvalidArgument,
-					fmt.Sprintf("model %q does not support image understanding; try a model with ImageUnderstanding capability", req.Model))
-			}
-			// Check for PDF input
-			if mime == "application/pdf" && !model.Capabilities.PDFUnderstanding {
-				return NewGrailError(InvalidArgument,
-					fmt.Sprintf("model %q does not support PDF understanding; try a model with PDFUnderstanding capability", req.Model))
-			}
+	// Validate input capabilities, dropping droppable inputs the model can't
+	// understand
+	inputs, warnings, err := filterInputs(req.Inputs, "", "", func(input Input) (ErrorCode, string) {
+		data, mime, _, isFile := AsFileInput(input)
+		if !isFile {
+			return "", ""
+		}
+		// Check for image input
+		if mime == "" {
+			mime = SniffImageMIME(data)
+		}
+		if strings.HasPrefix(mime, "image/") && !model.Capabilities.ImageUnderstanding {
+			return InvalidArgument, fmt.Sprintf("model %q does not support image understanding; try a model with ImageUnderstanding capability", req.Model)
 		}
+		// Check for PDF input
+		if mime == "application/pdf" && !model.Capabilities.PDFUnderstanding {
+			return InvalidArgument, fmt.Sprintf("model %q does not support PDF understanding; try a model with PDFUnderstanding capability", req.Model)
+		}
+		return "", ""
+	})
+	if err != nil {
+		return req, nil, err
 	}
+	req.Inputs = inputs
+	return req, warnings, nil
+}
 
+// lookupModel returns the catalog entry for name, or nil if the provider
+// doesn't list models or doesn't know the model.
+func (c *client) lookupModel(name string) *Model {
+	lister, ok := c.provider.(ModelLister)
+	if !ok {
+		return nil
+	}
+	models, err := lister.ListModels(c.withTransport(context.Background()))
+	if err != nil {
+		return nil
+	}
+	for i := range models {
+		if models[i].Name == name {
+			return &models[i]
+		}
+	}
 	return nil
 }
 
@@ -871,7 +1451,7 @@ func (c *client) ListModels(ctx context.Context) ([]Model, error) {
 		return nil, NewGrailError(Unsupported, fmt.Sprintf("provider %s does not support model listing", c.provider.Name()))
 	}
 
-	return lister.ListModels(ctx)
+	return lister.ListModels(c.withTransport(ctx))
 }
 
 func (c *client) GetModel(ctx context.Context, role ModelRole, tier ModelTier) (Model, error) {
@@ -902,6 +1482,9 @@ func (c *client) InputPDFFromURI(ctx context.Context, uri string, opts ...FileOp
 }
 
 func (c *client) downloadFile(ctx context.Context, uri string, expectedMIME string, opts ...FileOpt) (Input, error) {
+	if c.opts.airGap != nil {
+		return nil, NewGrailError(Unsupported, "URI downloads are disabled in air-gap mode")
+	}
 	ctx, cancel := context.WithTimeout(ctx, c.downloadTimeout)
 	defer cancel()
 
@@ -1017,14 +1600,37 @@ func validateRequest(req Request) error {
 		return NewGrailError(InvalidArgument, "output must be specified")
 	}
 
-	for i, input := range req.Inputs {
+	if err := validateTools(req); err != nil {
+		return err
+	}
+	if out, ok := req.Output.(textOutput); ok {
+		if err := validateTextOutput(out); err != nil {
+			return err
+		}
+	}
+	if out, ok := req.Output.(videoOutput); ok {
+		if err := validateVideoOutput(out); err != nil {
+			return err
+		}
+	}
+
+	if err := validateInputs(req.Inputs, ""); err != nil {
+		return err
+	}
+	return validateHistory(req.History)
+}
+
+// validateInputs checks file inputs' sizes and types, prefixing errors with
+// prefix.
+func validateInputs(inputs []Input, prefix string) error {
+	for i, input := range inputs {
 		switch v := input.(type) {
 		case fileInput:
 			if len(v.Data) == 0 {
-				return NewGrailError(InvalidArgument, fmt.Sprintf("input %d: file data is empty", i))
+				return NewGrailError(InvalidArgument, fmt.Sprintf("%sinput %d: file data is empty", prefix, i))
 			}
 			if len(v.Data) > MaxFileSize {
-				return NewGrailError(InvalidArgument, fmt.Sprintf("input %d: file size %d exceeds maximum %d bytes", i, len(v.Data), MaxFileSize))
+				return NewGrailError(InvalidArgument, fmt.Sprintf("%sinput %d: file size %d exceeds maximum %d bytes", prefix, i, len(v.Data), MaxFileSize))
 			}
 
 			// Handle empty MIME (e.g., from ImageInput - means it should be an image)
@@ -1034,24 +1640,24 @@ func validateRequest(req Request) error {
 				mime = sniffImageMIME(v.Data)
 				if mime == "" || !strings.HasPrefix(mime, "image/") {
 					// Empty MIME from ImageInput means it should be an image
-					return NewGrailError(InvalidArgument, fmt.Sprintf("input %d: expected image/*, got %s", i, mime))
+					return NewGrailError(InvalidArgument, fmt.Sprintf("%sinput %d: expected image/*, got %s", prefix, i, mime))
 				}
 			}
 
 			// Special validation for PDFs
 			if mime == "application/pdf" {
 				if len(v.Data) > MaxPDFSize {
-					return NewGrailError(InvalidArgument, fmt.Sprintf("input %d: PDF file size %d exceeds maximum %d bytes", i, len(v.Data), MaxPDFSize))
+					return NewGrailError(InvalidArgument, fmt.Sprintf("%sinput %d: PDF file size %d exceeds maximum %d bytes", prefix, i, len(v.Data), MaxPDFSize))
 				}
 			}
 		case textInput:
 			// Text input is always valid
 		case fileReaderInput:
 			if v.MIME == "" {
-				return NewGrailError(InvalidArgument, fmt.Sprintf("input %d: MIME type must be specified", i))
+				return NewGrailError(InvalidArgument, fmt.Sprintf("%sinput %d: MIME type must be specified", prefix, i))
 			}
 			if v.Size > 0 && v.Size > MaxFileSize {
-				return NewGrailError(InvalidArgument, fmt.Sprintf("input %d: file size %d exceeds maximum %d bytes", i, v.Size, MaxFileSize))
+				return NewGrailError(InvalidArgument, fmt.Sprintf("%sinput %d: file size %d exceeds maximum %d bytes", prefix, i, v.Size, MaxFileSize))
 			}
 		}
 	}
@@ -1069,6 +1675,8 @@ func getOutputType(output Output) string {
 		return "text"
 	case imageOutput:
 		return "image"
+	case videoOutput:
+		return "video"
 	case jsonOutput:
 		return "json"
 	default:
@@ -1084,6 +1692,9 @@ func roleFromOutput(output Output) ModelRole {
 	if _, isImage := GetImageSpec(output); isImage {
 		return ModelRoleImage
 	}
+	if _, isVideo := GetVideoSpec(output); isVideo {
+		return ModelRoleVideo
+	}
 	// JSON output also uses text models
 	return ModelRoleText
 }
@@ -1117,7 +1728,7 @@ func sniffImageMIME(data []byte) string {
 }
 
 func detectMIMEFromPath(path string) string {
-	ext := strings.ToLower(path[strings.LastIndex(path, "."):])
+	ext := strings.ToLower(filepath.Ext(path))
 	switch ext {
 	case ".pdf":
 		return "application/pdf"
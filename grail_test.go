@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/montanaflynn/grail"
@@ -320,3 +321,66 @@ func TestResponseHelpers(t *testing.T) {
 		}
 	})
 }
+
+func TestGenerateBatch(t *testing.T) {
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			text, _ := grail.AsTextInput(req.Inputs[0])
+			if text == "fail" {
+				return grail.Response{}, grail.NewGrailError(grail.Unavailable, "down")
+			}
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart(text)}}, nil
+		},
+	}
+	client := grail.NewClient(prov)
+
+	prompts := []string{"a", "fail", "c", "d"}
+	reqs := make([]grail.Request, len(prompts))
+	for i, p := range prompts {
+		reqs[i] = grail.Request{Inputs: []grail.Input{grail.InputText(p)}, Output: grail.OutputText()}
+	}
+
+	results := grail.GenerateBatch(context.Background(), client, reqs, grail.BatchOptions{Concurrency: 2})
+	if len(results) != len(prompts) {
+		t.Fatalf("expected %d results, got %d", len(prompts), len(results))
+	}
+	for i, r := range results {
+		if r.Index != i {
+			t.Fatalf("result %d has index %d", i, r.Index)
+		}
+		if prompts[i] == "fail" {
+			if grail.GetErrorCode(r.Err) != grail.Unavailable {
+				t.Fatalf("expected unavailable for failing request, got %v", r.Err)
+			}
+			continue
+		}
+		if text, _ := r.Response.Text(); text != prompts[i] {
+			t.Fatalf("result %d: expected %q, got %q", i, prompts[i], text)
+		}
+	}
+}
+
+func TestGeneratePanicRecovery(t *testing.T) {
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			var m map[string]int
+			m["boom"]++
+			return grail.Response{}, nil
+		},
+	}
+	client := grail.NewClient(prov)
+	_, err := client.Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("hi")},
+		Output: grail.OutputText(),
+	})
+	var ge grail.GrailError
+	if !errors.As(err, &ge) || ge.Code() != grail.Internal {
+		t.Fatalf("expected internal error from panic, got %v", err)
+	}
+	if !strings.Contains(ge.Details()["panic"], "nil map") || !strings.Contains(ge.Details()["stack"], "grail_test.TestGeneratePanicRecovery") {
+		t.Fatalf("expected panic value and stack in details, got %v", ge.Details())
+	}
+	if st := client.Stats(); st.InFlight != 0 || st.Errors[grail.Internal] != 1 {
+		t.Fatalf("expected the panicked call to be accounted for, got %+v", st)
+	}
+}
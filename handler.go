@@ -0,0 +1,74 @@
+package grail
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+//
+// Operational HTTP endpoints
+//
+
+// Handler returns an http.Handler with operational endpoints for c:
+//
+//	GET /healthz  200 {"status":"ok"}, or 503 {"status":"closed"} once c is closed
+//	GET /stats    c.Stats() as JSON: requests, errors, usage, latency, circuit state
+//	GET /models   c.ListModels as JSON
+//
+// Mount it under a prefix with http.StripPrefix.
+func Handler(c Client) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		st := c.Stats()
+		switch {
+		case st.Closed:
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "closed"})
+		case st.Circuit == CircuitOpen:
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "circuit_open"})
+		default:
+			writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		}
+	})
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, c.Stats())
+	})
+	mux.HandleFunc("GET /models", func(w http.ResponseWriter, r *http.Request) {
+		models, err := c.ListModels(r.Context())
+		if err != nil {
+			writeJSON(w, httpStatus(err), map[string]string{"error": err.Error(), "code": string(GetErrorCode(err))})
+			return
+		}
+		writeJSON(w, http.StatusOK, models)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// httpStatus maps an error's code to the HTTP status a handler reports it with.
+func httpStatus(err error) int {
+	switch GetErrorCode(err) {
+	case InvalidArgument:
+		return http.StatusBadRequest
+	case Unauthorized:
+		return http.StatusUnauthorized
+	case NotFound:
+		return http.StatusNotFound
+	case RateLimited, BudgetExceeded:
+		return http.StatusTooManyRequests
+	case Timeout:
+		return http.StatusGatewayTimeout
+	case Unsupported:
+		return http.StatusNotImplemented
+	case Unavailable:
+		return http.StatusServiceUnavailable
+	case PendingReview:
+		return http.StatusAccepted
+	default:
+		return http.StatusBadGateway
+	}
+}
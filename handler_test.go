@@ -0,0 +1,70 @@
+package grail_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestHandler(t *testing.T) {
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			if text, _ := grail.AsTextInput(req.Inputs[0]); text == "fail" {
+				return grail.Response{}, grail.NewGrailError(grail.RateLimited, "slow down")
+			}
+			return grail.Response{
+				Outputs: []grail.OutputPart{grail.NewTextOutputPart("ok")},
+				Usage:   grail.Usage{InputTokens: 3, OutputTokens: 4, TotalTokens: 7},
+			}, nil
+		},
+	}
+	client := grail.NewClient(prov)
+	for _, msg := range []string{"one", "two", "fail"} {
+		client.Generate(context.Background(), grail.Request{Inputs: []grail.Input{grail.InputText(msg)}, Output: grail.OutputText()})
+	}
+
+	srv := httptest.NewServer(grail.Handler(client))
+	defer srv.Close()
+	get := func(path string, v any) int {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if v != nil {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatalf("%s: %v", path, err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	if code := get("/healthz", nil); code != http.StatusOK {
+		t.Fatalf("expected healthy client, got %d", code)
+	}
+
+	var st grail.ClientStats
+	get("/stats", &st)
+	if st.Provider != "mock" || st.Requests != 3 || st.Errors[grail.RateLimited] != 1 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+	if st.Usage.TotalTokens != 14 || st.Latency.Samples != 3 {
+		t.Fatalf("expected usage of successful calls and latency of all calls, got %+v", st)
+	}
+
+	var body map[string]string
+	if code := get("/models", &body); code != http.StatusNotImplemented || body["code"] != string(grail.Unsupported) {
+		t.Fatalf("expected unsupported model listing, got %d %v", code, body)
+	}
+
+	client.Close(context.Background())
+	if code := get("/healthz", nil); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected closed client to be unhealthy, got %d", code)
+	}
+}
@@ -0,0 +1,186 @@
+package grail
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+//
+// Conversation history
+//
+
+// Message is an earlier turn of a conversation, sent with a request in
+// Request.History. User messages hold inputs like a request's; assistant
+// messages hold the model's replies as text inputs.
+type Message struct {
+	Role   TurnRole
+	Inputs []Input
+}
+
+// UserMessage returns a user turn with the given inputs.
+func UserMessage(inputs ...Input) Message {
+	return Message{Role: TurnUser, Inputs: inputs}
+}
+
+// AssistantMessage returns an assistant turn that replied with text.
+func AssistantMessage(text string) Message {
+	return Message{Role: TurnAssistant, Inputs: []Input{InputText(text)}}
+}
+
+// ResponseMessage returns res as an assistant turn, for continuing a
+// conversation after it. Text and JSON outputs become the turn's text; image
+// outputs and tool calls are left out.
+func ResponseMessage(res Response) Message {
+	m := Message{Role: TurnAssistant}
+	for _, part := range res.Outputs {
+		switch v := part.(type) {
+		case textOutputPart:
+			m.Inputs = append(m.Inputs, InputText(v.Text))
+		case jsonOutputPart:
+			m.Inputs = append(m.Inputs, InputText(string(v.JSON)))
+		}
+	}
+	return m
+}
+
+func validateHistory(history []Message) error {
+	for i, m := range history {
+		if m.Role != TurnUser && m.Role != TurnAssistant {
+			return NewGrailError(InvalidArgument, fmt.Sprintf("history message %d: unknown role %q", i, m.Role))
+		}
+		if len(m.Inputs) == 0 {
+			return NewGrailError(InvalidArgument, fmt.Sprintf("history message %d: inputs must not be empty", i))
+		}
+		for j, in := range m.Inputs {
+			switch v := in.(type) {
+			case textInput:
+			case toolResultInput:
+				if m.Role == TurnAssistant {
+					return NewGrailError(InvalidArgument, fmt.Sprintf("history message %d: input %d: assistant messages must be text", i, j))
+				}
+				if v.Call.Name == "" {
+					return NewGrailError(InvalidArgument, fmt.Sprintf("history message %d: input %d: tool result has no tool name", i, j))
+				}
+			case fileReaderInput:
+				return NewGrailError(InvalidArgument, fmt.Sprintf("history message %d: input %d: streamed file inputs can't be sent as history", i, j))
+			default:
+				if m.Role == TurnAssistant {
+					return NewGrailError(InvalidArgument, fmt.Sprintf("history message %d: input %d: assistant messages must be text", i, j))
+				}
+			}
+		}
+		if err := validateInputs(m.Inputs, fmt.Sprintf("history message %d: ", i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// historyPreamble writes history out as the start of a prompt, for providers
+// that can't take earlier turns as messages. User files are sent again in
+// place.
+func historyPreamble(history []Message) []Input {
+	inputs := []Input{InputText("Conversation so far:")}
+	for _, m := range history {
+		speaker := "User: "
+		if m.Role == TurnAssistant {
+			speaker = "Assistant: "
+		}
+		for _, in := range m.Inputs {
+			switch v := in.(type) {
+			case textInput:
+				inputs = append(inputs, InputText(speaker+v.Text))
+			case toolResultInput:
+				inputs = append(inputs, InputText(fmt.Sprintf("Tool %s returned: %s", v.Call.Name, v.Output)))
+			default:
+				inputs = append(inputs, in)
+			}
+		}
+	}
+	return append(inputs, InputText("Reply to the user's next message."))
+}
+
+// History rebuilds the conversation in the transcript as messages, for
+// continuing it with Request.History. Turns without text or files, such as
+// ones holding only tool calls, are left out. If a turn's attachments
+// aren't available, only its text is kept and the error is returned along
+// with the rest of the history.
+func (t *Transcript) History() ([]Message, error) {
+	var history []Message
+	var errs []error
+	for i, turn := range t.Turns {
+		var inputs []Input
+		if turn.Role == TurnUser {
+			var err error
+			if inputs, err = t.Inputs(i); err != nil {
+				errs = append(errs, fmt.Errorf("turn %d: %w", i, err))
+				inputs = nil
+				for _, p := range turn.Parts {
+					if p.Type == "text" {
+						inputs = append(inputs, InputText(p.Text))
+					}
+				}
+			}
+		} else {
+			for _, p := range turn.Parts {
+				switch p.Type {
+				case "text":
+					inputs = append(inputs, InputText(p.Text))
+				case "json":
+					inputs = append(inputs, InputText(string(p.JSON)))
+				}
+			}
+		}
+		if len(inputs) > 0 {
+			history = append(history, Message{Role: turn.Role, Inputs: inputs})
+		}
+	}
+	return history, errors.Join(errs...)
+}
+
+// WithSessionHistory carries context between turns by sending the earlier
+// turns of the conversation as Request.History, so the model sees the whole
+// conversation whichever provider it's sent to. Combined with
+// WithSessionTranscript, the history starts from the transcript's turns.
+func WithSessionHistory() SessionOption {
+	return WithSessionState(&historyState{})
+}
+
+// historyState is the SessionState behind WithSessionHistory.
+type historyState struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (h *historyState) PrepareRequest(req *Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.messages) > 0 {
+		req.History = append(append([]Message(nil), h.messages...), req.History...)
+	}
+}
+
+func (h *historyState) RecordResponse(res Response) {
+	req, err := res.Request(nil)
+	if err != nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messages = append(h.messages, turnMessages(req.Inputs, res)...)
+}
+
+func (h *historyState) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messages = nil
+}
+
+// restore starts the history from a transcript's turns.
+func (h *historyState) restore(t *Transcript) {
+	history, _ := t.History()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messages = history
+}
@@ -0,0 +1,108 @@
+package grail_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+type historyProvider struct{ *mock.Provider }
+
+func (historyProvider) Capabilities() grail.ProviderCapabilities {
+	return grail.ProviderCapabilities{TextOutput: true, History: true}
+}
+
+func TestHistory(t *testing.T) {
+	var got grail.Request
+	mp := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			got = req
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("Ada.")}}, nil
+		},
+	}
+	req := grail.Request{
+		History: []grail.Message{
+			grail.UserMessage(grail.InputText("My name is Ada.")),
+			grail.AssistantMessage("Nice to meet you, Ada!"),
+		},
+		Inputs: []grail.Input{grail.InputText("What's my name?")},
+		Output: grail.OutputText(),
+	}
+
+	if _, err := grail.NewClient(historyProvider{mp}).Generate(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got.History) != 2 || len(got.Inputs) != 1 {
+		t.Fatalf("expected the history to be passed through, got %d messages and %d inputs", len(got.History), len(got.Inputs))
+	}
+
+	// Providers without history support get the turns written out.
+	if _, err := grail.NewClient(mp).Generate(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got.History) != 0 || len(got.Inputs) != 5 {
+		t.Fatalf("expected the history in the prompt, got %d messages and %d inputs", len(got.History), len(got.Inputs))
+	}
+	if text, _ := grail.AsTextInput(got.Inputs[2]); text != "Assistant: Nice to meet you, Ada!" {
+		t.Errorf("unexpected assistant turn %q", text)
+	}
+	if text, _ := grail.AsTextInput(got.Inputs[4]); text != "What's my name?" {
+		t.Errorf("expected the current message last, got %q", text)
+	}
+
+	for _, history := range [][]grail.Message{
+		{{Role: "system", Inputs: []grail.Input{grail.InputText("hi")}}},
+		{grail.UserMessage()},
+		{{Role: grail.TurnAssistant, Inputs: []grail.Input{grail.InputFile([]byte("notes"), "text/plain")}}},
+	} {
+		req.History = history
+		if _, err := grail.NewClient(mp).Generate(context.Background(), req); grail.GetErrorCode(err) != grail.InvalidArgument {
+			t.Errorf("expected InvalidArgument for %+v, got %v", history, err)
+		}
+	}
+}
+
+func TestSessionHistory(t *testing.T) {
+	var got grail.Request
+	mp := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			got = req
+			text, _ := grail.AsTextInput(req.Inputs[0])
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("re: " + text)}}, nil
+		},
+	}
+	client := grail.NewClient(historyProvider{mp})
+	ctx := context.Background()
+
+	s := grail.NewSession(client, grail.WithSessionHistory())
+	for _, msg := range []string{"one", "two", "three"} {
+		if _, err := s.Send(ctx, grail.InputText(msg)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(got.History) != 4 {
+		t.Fatalf("expected the two earlier turns as history, got %d messages", len(got.History))
+	}
+	if text, _ := grail.AsTextInput(got.History[3].Inputs[0]); got.History[3].Role != grail.TurnAssistant || text != "re: two" {
+		t.Errorf("unexpected last message %+v", got.History[3])
+	}
+
+	// A session continuing the transcript picks up its history.
+	resumed := grail.NewSession(client, grail.WithSessionHistory(), grail.WithSessionTranscript(s.Transcript()))
+	if _, err := resumed.Send(ctx, grail.InputText("four")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got.History) != 6 {
+		t.Fatalf("expected the transcript's turns as history, got %d messages", len(got.History))
+	}
+	if text, _ := grail.AsTextInput(got.History[0].Inputs[0]); text != "one" {
+		t.Errorf("expected the first turn first, got %q", text)
+	}
+
+	resumed.Reset()
+	if _, err := resumed.Send(ctx, grail.InputText("five")); err != nil || len(got.History) != 0 {
+		t.Fatalf("expected no history after Reset, got %d messages (%v)", len(got.History), err)
+	}
+}
@@ -0,0 +1,164 @@
+package grail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+//
+// Conversation stores
+//
+
+// HistoryStore persists conversations as messages, keyed by an ID the app
+// chooses, so chat apps can resume them across process restarts. The
+// stores/sqlite package has an implementation backed by a SQLite file;
+// MemoryHistoryStore keeps conversations in memory. Implementations must be
+// safe for concurrent use.
+type HistoryStore interface {
+	// Append adds messages to the end of a conversation, creating it if
+	// needed.
+	Append(ctx context.Context, conversation string, msgs ...Message) error
+	// Load returns a conversation's messages, oldest first. An unknown
+	// conversation has none.
+	Load(ctx context.Context, conversation string) ([]Message, error)
+	// Trim keeps only the last keep messages of a conversation, removing it
+	// entirely if keep <= 0.
+	Trim(ctx context.Context, conversation string, keep int) error
+}
+
+// WithSessionStore keeps the session's conversation in store under the given
+// ID: each turn is sent with the conversation's messages as Request.History,
+// and appended to it once it succeeds. A session opened on an existing
+// conversation carries on from it. Use it instead of WithSessionHistory, not
+// with it. Session.Reset leaves the store alone; Trim the conversation to
+// clear it.
+func WithSessionStore(store HistoryStore, conversation string) SessionOption {
+	return sessionOptFunc(func(so *sessionOpt) {
+		so.store = store
+		so.conversation = conversation
+	})
+}
+
+// turnMessages returns a turn as the user and assistant messages to continue
+// the conversation with. Streamed files, which the turn consumed, and turns
+// with nothing left to send are left out.
+func turnMessages(inputs []Input, res Response) []Message {
+	user := Message{Role: TurnUser}
+	for _, in := range inputs {
+		if _, ok := in.(fileReaderInput); !ok {
+			user.Inputs = append(user.Inputs, in)
+		}
+	}
+	var msgs []Message
+	for _, m := range []Message{user, ResponseMessage(res)} {
+		if len(m.Inputs) > 0 {
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs
+}
+
+// MemoryHistoryStore is a HistoryStore held in memory, for tests and
+// processes that don't need conversations to outlive them. The zero value is
+// ready to use.
+type MemoryHistoryStore struct {
+	mu            sync.Mutex
+	conversations map[string][]Message
+}
+
+// Append implements HistoryStore.
+func (s *MemoryHistoryStore) Append(ctx context.Context, conversation string, msgs ...Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conversations == nil {
+		s.conversations = map[string][]Message{}
+	}
+	s.conversations[conversation] = append(s.conversations[conversation], msgs...)
+	return nil
+}
+
+// Load implements HistoryStore.
+func (s *MemoryHistoryStore) Load(ctx context.Context, conversation string) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.conversations[conversation]...), nil
+}
+
+// Trim implements HistoryStore.
+func (s *MemoryHistoryStore) Trim(ctx context.Context, conversation string, keep int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msgs := s.conversations[conversation]
+	switch {
+	case keep <= 0:
+		delete(s.conversations, conversation)
+	case keep < len(msgs):
+		s.conversations[conversation] = append([]Message(nil), msgs[len(msgs)-keep:]...)
+	}
+	return nil
+}
+
+// messageJSON is the encoding of a Message, for stores that persist them.
+type messageJSON struct {
+	Role  TurnRole      `json:"role"`
+	Parts []messagePart `json:"parts"`
+}
+
+type messagePart struct {
+	Type      string          `json:"type"`           // "text", "file", "uploaded_file", or "tool_result"
+	Text      string          `json:"text,omitempty"` // text, or a tool result's output
+	Data      []byte          `json:"data,omitempty"`
+	MIME      string          `json:"mime,omitempty"`
+	Name      string          `json:"name,omitempty"` // file or tool name
+	ID        string          `json:"id,omitempty"`   // uploaded file or tool call ID
+	JSON      json.RawMessage `json:"json,omitempty"` // tool call arguments
+	Signature []byte          `json:"signature,omitempty"`
+}
+
+// MarshalJSON encodes the message with its files inline, for HistoryStore
+// implementations. Streamed file inputs can't be encoded.
+func (m Message) MarshalJSON() ([]byte, error) {
+	out := messageJSON{Role: m.Role, Parts: make([]messagePart, 0, len(m.Inputs))}
+	for i, in := range m.Inputs {
+		switch v := in.(type) {
+		case textInput:
+			out.Parts = append(out.Parts, messagePart{Type: "text", Text: v.Text})
+		case fileInput:
+			out.Parts = append(out.Parts, messagePart{Type: "file", Data: v.Data, MIME: v.MIME, Name: v.Name})
+		case uploadedFileInput:
+			out.Parts = append(out.Parts, messagePart{Type: "uploaded_file", ID: v.ID, MIME: v.MIME, Name: v.Name})
+		case toolResultInput:
+			out.Parts = append(out.Parts, messagePart{Type: "tool_result", Text: v.Output, Name: v.Call.Name, ID: v.Call.ID, JSON: v.Call.Arguments, Signature: v.Call.Signature})
+		default:
+			return nil, fmt.Errorf("input %d: %T can't be encoded", i, in)
+		}
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes a message encoded with MarshalJSON.
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var in messageJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	*m = Message{Role: in.Role}
+	for i, p := range in.Parts {
+		switch p.Type {
+		case "text":
+			m.Inputs = append(m.Inputs, textInput{Text: p.Text})
+		case "file":
+			m.Inputs = append(m.Inputs, fileInput{Data: p.Data, MIME: p.MIME, Name: p.Name})
+		case "uploaded_file":
+			m.Inputs = append(m.Inputs, uploadedFileInput{ID: p.ID, MIME: p.MIME, Name: p.Name})
+		case "tool_result":
+			call := ToolCall{ID: p.ID, Name: p.Name, Arguments: p.JSON, Signature: p.Signature}
+			m.Inputs = append(m.Inputs, toolResultInput{Call: call, Output: p.Text})
+		default:
+			return fmt.Errorf("part %d: unknown type %q", i, p.Type)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package grail_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestSessionStore(t *testing.T) {
+	var got grail.Request
+	prov := historyProvider{&mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			got = req
+			text, _ := grail.AsTextInput(req.Inputs[0])
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("re: " + text)}}, nil
+		},
+	}}
+	client := grail.NewClient(prov)
+	ctx := context.Background()
+	store := &grail.MemoryHistoryStore{}
+
+	s := grail.NewSession(client, grail.WithSessionStore(store, "ada"))
+	for _, msg := range []string{"one", "two"} {
+		if _, err := s.Send(ctx, grail.InputText(msg)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// A new session, as after a restart, carries on from the store.
+	resumed := grail.NewSession(client, grail.WithSessionStore(store, "ada"))
+	if _, err := resumed.Send(ctx, grail.InputText("three")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got.History) != 4 {
+		t.Fatalf("expected the stored turns as history, got %d messages", len(got.History))
+	}
+	if msgs, _ := store.Load(ctx, "ada"); len(msgs) != 6 {
+		t.Errorf("expected 6 stored messages, got %d", len(msgs))
+	}
+
+	if err := store.Trim(ctx, "ada", 2); err != nil {
+		t.Fatal(err)
+	}
+	msgs, _ := store.Load(ctx, "ada")
+	if text, _ := grail.AsTextInput(msgs[0].Inputs[0]); len(msgs) != 2 || text != "three" {
+		t.Errorf("expected the last turn after Trim, got %d messages starting %q", len(msgs), text)
+	}
+	store.Trim(ctx, "ada", 0)
+	if msgs, _ := store.Load(ctx, "ada"); len(msgs) != 0 {
+		t.Errorf("expected the conversation to be removed, got %d messages", len(msgs))
+	}
+}
+
+func TestMessageJSON(t *testing.T) {
+	in := grail.UserMessage(
+		grail.InputText("What's in this file?"),
+		grail.InputFile([]byte("notes"), "text/plain", grail.WithFileName("notes.txt")),
+		grail.InputToolResult(grail.ToolCall{ID: "1", Name: "get_weather", Arguments: []byte(`{"city":"Paris"}`)}, "sunny"),
+	)
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var out grail.Message
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.Role != grail.TurnUser || len(out.Inputs) != 3 {
+		t.Fatalf("unexpected message %+v", out)
+	}
+	if fileData, mime, name, ok := grail.AsFileInput(out.Inputs[1]); !ok || string(fileData) != "notes" || mime != "text/plain" || name != "notes.txt" {
+		t.Errorf("unexpected file %q %q %q", fileData, mime, name)
+	}
+	if call, output, ok := grail.AsToolResultInput(out.Inputs[2]); !ok || call.Name != "get_weather" || string(call.Arguments) != `{"city":"Paris"}` || output != "sunny" {
+		t.Errorf("unexpected tool result %+v %q", call, output)
+	}
+
+	if _, err := json.Marshal(grail.UserMessage(grail.InputFileReader(nil, 0, "text/plain"))); err == nil {
+		t.Error("expected streamed files to fail to encode")
+	}
+}
@@ -0,0 +1,212 @@
+package grail
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//
+// HTTP response cache
+//
+
+// HTTPCache is an http.RoundTripper that replays the response to an
+// identical earlier request instead of sending it again, for test suites and
+// demos that repeat the same provider calls. Use it with a provider's
+// WithHTTPClient option:
+//
+//	cache := &grail.HTTPCache{TTL: time.Hour, Dir: "testdata/http-cache"}
+//	provider, err := openai.New(openai.WithHTTPClient(cache.Client()))
+//
+// Requests match on method, URL, body, and credential headers, so calls made
+// with different API keys never share responses. Only 2xx responses are
+// cached, and their bodies are read in full before they're returned, so
+// streamed responses arrive all at once. Replayed responses carry the header
+// X-Grail-Cache: hit.
+//
+// It caches regardless of what the request asks for: non-deterministic calls
+// (sampling at a nonzero temperature, image generation) return the first
+// response until it expires. The zero value caches in memory without expiry.
+type HTTPCache struct {
+	Base http.RoundTripper // defaults to http.DefaultTransport
+	TTL  time.Duration     // how long responses are replayed; zero for no expiry
+	// Dir, if set, persists responses as files in the directory, so they're
+	// replayed across runs. Files hold response bodies unencrypted unless
+	// Keys is set. Dir is created readable only by its owner, and expired
+	// files are removed when they're next looked up.
+	Dir string
+	// Keys, if set, encrypts the files in Dir with AES-GCM (see Seal), each
+	// bound to its request. Files that aren't encrypted with them are
+	// ignored.
+	Keys KeyProvider
+
+	mu      sync.Mutex
+	entries map[string]httpCacheEntry
+	hits    atomic.Int64
+	misses  atomic.Int64
+}
+
+type httpCacheEntry struct {
+	Time   time.Time   `json:"time"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// credentialHeaders are part of the cache key, hashed with the rest of it.
+var credentialHeaders = []string{"Authorization", "X-Api-Key", "X-Goog-Api-Key", "Api-Key"}
+
+// Client returns an HTTP client that sends requests through c.
+func (c *HTTPCache) Client() *http.Client {
+	return &http.Client{Transport: c}
+}
+
+// Stats returns how many requests were replayed and how many were sent.
+func (c *HTTPCache) Stats() (hits, misses int64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+// Clear drops every cached response, including those in Dir.
+func (c *HTTPCache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+	if c.Dir == "" {
+		return nil
+	}
+	files, err := filepath.Glob(filepath.Join(c.Dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := os.Remove(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *HTTPCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	key := httpCacheKey(req, body)
+	if e, ok := c.lookup(key); ok {
+		c.hits.Add(1)
+		return e.response(req), nil
+	}
+	c.misses.Add(1)
+
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	base := c.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(out)
+	if err != nil || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	c.store(key, httpCacheEntry{Time: time.Now(), Status: resp.StatusCode, Header: resp.Header.Clone(), Body: respBody})
+	return resp, nil
+}
+
+func httpCacheKey(req *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", req.Method, req.URL.String())
+	for _, name := range credentialHeaders {
+		fmt.Fprintf(h, "%s: %s\n", name, strings.Join(req.Header.Values(name), ","))
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *HTTPCache) lookup(key string) (httpCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok && c.Dir != "" {
+		if data, err := os.ReadFile(filepath.Join(c.Dir, key+".json")); err == nil {
+			ok = openJSON(c.Keys, data, &e, httpCacheAAD(key)) == nil
+		}
+	}
+	if !ok {
+		return httpCacheEntry{}, false
+	}
+	if c.TTL > 0 && time.Since(e.Time) > c.TTL {
+		delete(c.entries, key)
+		if c.Dir != "" {
+			os.Remove(filepath.Join(c.Dir, key+".json"))
+		}
+		return httpCacheEntry{}, false
+	}
+	return e, true
+}
+
+func (c *HTTPCache) store(key string, e httpCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = map[string]httpCacheEntry{}
+	}
+	c.entries[key] = e
+	if c.Dir == "" {
+		return
+	}
+	// Persisting is best effort: a response that can't be written is still
+	// cached in memory.
+	data, err := sealJSON(c.Keys, e, httpCacheAAD(key))
+	if err != nil || os.MkdirAll(c.Dir, 0o700) != nil {
+		return
+	}
+	tmp := filepath.Join(c.Dir, key+".tmp")
+	if os.WriteFile(tmp, data, 0o600) == nil {
+		os.Rename(tmp, filepath.Join(c.Dir, key+".json"))
+	}
+}
+
+// httpCacheAAD binds an encrypted cache file to the request it answers.
+func httpCacheAAD(key string) string {
+	return "grail/httpcache\x00" + key
+}
+
+func (e httpCacheEntry) response(req *http.Request) *http.Response {
+	header := e.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("X-Grail-Cache", "hit")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+		StatusCode:    e.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,168 @@
+package grail_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+)
+
+func TestHTTPCache(t *testing.T) {
+	var calls atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		if string(body) == "fail" {
+			http.Error(w, "nope", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"call":%d}`, n)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	cache := &grail.HTTPCache{Dir: dir}
+	post := func(c *http.Client, body, key string) (string, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		got, _ := io.ReadAll(resp.Body)
+		return string(got), resp.Header.Get("X-Grail-Cache")
+	}
+
+	client := cache.Client()
+	if got, hit := post(client, "a", "k1"); got != `{"call":1}` || hit != "" {
+		t.Fatalf("first call: %s %q", got, hit)
+	}
+	if got, hit := post(client, "a", "k1"); got != `{"call":1}` || hit != "hit" {
+		t.Fatalf("repeated call not replayed: %s %q", got, hit)
+	}
+	if got, _ := post(client, "b", "k1"); got != `{"call":2}` {
+		t.Fatalf("different body replayed: %s", got)
+	}
+	if got, _ := post(client, "a", "k2"); got != `{"call":3}` {
+		t.Fatalf("different API key replayed: %s", got)
+	}
+	post(client, "fail", "k1")
+	post(client, "fail", "k1")
+	if calls.Load() != 5 {
+		t.Fatalf("errors must not be cached, server saw %d calls", calls.Load())
+	}
+	if hits, misses := cache.Stats(); hits != 1 || misses != 5 {
+		t.Fatalf("stats = %d hits, %d misses", hits, misses)
+	}
+
+	// A new cache over the same directory replays from disk.
+	if got, hit := post((&grail.HTTPCache{Dir: dir}).Client(), "a", "k1"); got != `{"call":1}` || hit != "hit" {
+		t.Fatalf("not replayed from disk: %s %q", got, hit)
+	}
+
+	// Expired responses are fetched again.
+	expiring := &grail.HTTPCache{TTL: time.Nanosecond}
+	post(expiring.Client(), "a", "k1")
+	time.Sleep(time.Millisecond)
+	if got, hit := post(expiring.Client(), "a", "k1"); hit != "" || got == `{"call":6}` {
+		t.Fatalf("expired response replayed: %s %q", got, hit)
+	}
+
+	if err := cache.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if _, hit := post(client, "a", "k1"); hit != "" {
+		t.Fatal("cleared response replayed")
+	}
+}
+
+func TestHTTPCache_Encryption(t *testing.T) {
+	var calls atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"secret":%d}`, calls.Add(1))
+	}))
+	defer srv.Close()
+	get := func(c *grail.HTTPCache) (string, string) {
+		t.Helper()
+		resp, err := c.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		got, _ := io.ReadAll(resp.Body)
+		return string(got), resp.Header.Get("X-Grail-Cache")
+	}
+
+	dir := t.TempDir()
+	keys := grail.StaticKey(bytes.Repeat([]byte{3}, 32))
+	get(&grail.HTTPCache{Dir: dir, Keys: keys})
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("expected one cache file, got %v", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	if bytes.Contains(data, []byte("secret")) {
+		t.Fatalf("cache file holds plaintext: %s", data)
+	}
+	if got, hit := get(&grail.HTTPCache{Dir: dir, Keys: keys}); got != `{"secret":1}` || hit != "hit" {
+		t.Fatalf("not replayed from encrypted disk cache: %s %q", got, hit)
+	}
+	if _, hit := get(&grail.HTTPCache{Dir: dir}); hit != "" {
+		t.Fatal("encrypted file replayed without keys")
+	}
+
+	// Without keys the response was stored in plaintext again, which a cache
+	// with keys ignores.
+	if _, hit := get(&grail.HTTPCache{Dir: dir, Keys: keys}); hit != "" {
+		t.Fatal("plaintext file replayed with keys")
+	}
+}
+
+func TestHTTPCache_DirExpiry(t *testing.T) {
+	var calls atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) > 1 {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	dir := filepath.Join(t.TempDir(), "cache")
+	cache := &grail.HTTPCache{Dir: dir, TTL: time.Millisecond}
+	resp, err := cache.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if fi, err := os.Stat(dir); err != nil || fi.Mode().Perm() != 0o700 {
+		t.Fatalf("expected a private cache directory, got %v (%v)", fi.Mode(), err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(files) != 1 {
+		t.Fatalf("expected one cache file, got %v", files)
+	}
+
+	// An expired response's file is removed, not just forgotten.
+	time.Sleep(5 * time.Millisecond)
+	resp, err = (&grail.HTTPCache{Dir: dir, TTL: time.Millisecond}).Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(files) != 0 {
+		t.Fatalf("expected the expired file to be removed, got %v", files)
+	}
+}
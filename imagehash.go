@@ -0,0 +1,51 @@
+package grail
+
+import (
+	"fmt"
+
+	"github.com/montanaflynn/grail/internal/imaging"
+)
+
+//
+// Perceptual image hashing
+//
+
+// ImageHash holds 64-bit perceptual hashes of an image. Unlike a content hash,
+// visually similar images (re-encoded, resized, lightly edited) have hashes a
+// small Hamming distance apart, so they can key deduplication and similarity
+// caches.
+type ImageHash struct {
+	// DHash is a difference hash: cheap, and sensitive to gradients and
+	// composition.
+	DHash uint64 `json:"dhash"`
+	// PHash is a DCT-based hash: robust to compression, scaling, and small
+	// color changes.
+	PHash uint64 `json:"phash"`
+}
+
+// HashImage computes the perceptual hashes of encoded image data.
+func HashImage(data []byte) (ImageHash, error) {
+	d, p, err := imaging.Hashes(data)
+	if err != nil {
+		return ImageHash{}, NewGrailError(InvalidArgument, fmt.Sprintf("hash image: %v", err)).WithCause(err)
+	}
+	return ImageHash{DHash: d, PHash: p}, nil
+}
+
+// Distance returns the Hamming distance between the PHashes of h and o, from
+// 0 (visually the same) to 64. Distances up to about 10 usually indicate the
+// same image.
+func (h ImageHash) Distance(o ImageHash) int {
+	return imaging.Distance(h.PHash, o.PHash)
+}
+
+// DHashDistance returns the Hamming distance between the DHashes of h and o.
+func (h ImageHash) DHashDistance(o ImageHash) int {
+	return imaging.Distance(h.DHash, o.DHash)
+}
+
+// String renders the hashes as "<dhash>:<phash>" in hex, for use as a cache
+// or map key.
+func (h ImageHash) String() string {
+	return fmt.Sprintf("%016x:%016x", h.DHash, h.PHash)
+}
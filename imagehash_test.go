@@ -0,0 +1,96 @@
+package grail_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+// scene draws a bright disc on a dark background, at the given size and
+// horizontal offset.
+func scene(w, h, offset int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	cx, cy, r := w/2+offset, h/2, h/3
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{R: uint8(x * 255 / w), G: 40, B: 60, A: 255}
+			if (x-cx)*(x-cx)+(y-cy)*(y-cy) < r*r {
+				c = color.RGBA{R: 250, G: 240, B: 200, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestHashImage(t *testing.T) {
+	encode := func(img image.Image, asJPEG bool) []byte {
+		var buf bytes.Buffer
+		var err error
+		if asJPEG {
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 60})
+		} else {
+			err = png.Encode(&buf, img)
+		}
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	original, err := grail.HashImage(encode(scene(128, 96, 0), false))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resized, _ := grail.HashImage(encode(scene(64, 48, 0), true))
+	moved, _ := grail.HashImage(encode(scene(128, 96, -40), false))
+
+	if d := original.Distance(resized); d > 10 {
+		t.Fatalf("resized JPEG should be near the original, distance %d", d)
+	}
+	if near, far := original.Distance(resized), original.Distance(moved); far <= near {
+		t.Fatalf("moved subject should be farther than resized copy: %d <= %d", far, near)
+	}
+	if len(original.String()) != 33 {
+		t.Fatalf("unexpected key %q", original.String())
+	}
+
+	if _, err := grail.HashImage([]byte("not an image")); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Fatalf("expected invalid_argument, got %v", err)
+	}
+}
+
+func TestImagePostProcessingHash(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, scene(64, 48, 0)); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewImageOutputPart(buf.Bytes(), "image/png", "")}}, nil
+		},
+	}
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("draw")}, Output: grail.OutputImage(grail.ImageSpec{Count: 1})}
+
+	res, _ := grail.NewClient(prov).Generate(context.Background(), req)
+	if res.ImageOutputs()[0].Hash != nil {
+		t.Fatalf("hashing should be opt-in")
+	}
+
+	client := grail.NewClient(prov, grail.WithImagePostProcessing(grail.ImageProcessing{PerceptualHash: true}))
+	res, err := client.Generate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want, _ := grail.HashImage(buf.Bytes())
+	if got := res.ImageOutputs()[0].Hash; got == nil || *got != want {
+		t.Fatalf("expected hash %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,109 @@
+package grail
+
+import (
+	"fmt"
+
+	"github.com/montanaflynn/grail/internal/imaging"
+)
+
+//
+// Image post-processing
+//
+
+// ImageProcessing configures client-side post-processing of image outputs.
+// Steps run in order: resize/convert, strip metadata, thumbnail, then hashing.
+type ImageProcessing struct {
+	// StripMetadata removes EXIF, XMP, text chunks, and C2PA manifests.
+	StripMetadata bool
+	// Format re-encodes images as "png", "jpeg", or "gif". Empty keeps the
+	// provider's format (WebP outputs that must be re-encoded become PNG).
+	Format string
+	// JPEGQuality sets the quality (1-100) used when encoding JPEG.
+	JPEGQuality int
+	// MaxWidth and MaxHeight downscale images to fit, preserving aspect ratio.
+	MaxWidth  int
+	MaxHeight int
+	// ThumbnailWidth and ThumbnailHeight, when either is set, produce a
+	// thumbnail in ImageOutputInfo.Thumbnail.
+	ThumbnailWidth  int
+	ThumbnailHeight int
+	// PerceptualHash computes ImageOutputInfo.Hash for deduplicating and
+	// comparing outputs by visual similarity.
+	PerceptualHash bool
+}
+
+// WithImagePostProcessing applies p to every image output before Generate returns.
+func WithImagePostProcessing(p ImageProcessing) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.imageProcessing = &p
+	})
+}
+
+func processImageOutputs(res *Response, p ImageProcessing) error {
+	for i, part := range res.Outputs {
+		img, ok := part.(imageOutputPart)
+		if !ok {
+			continue
+		}
+		processed, err := processImage(img, p)
+		if err != nil {
+			return NewGrailError(OutputInvalid, fmt.Sprintf("output %d: image post-processing failed: %v", i, err)).
+				WithCause(err).WithProviderName(res.Provider.Name).WithRequestID(res.RequestID)
+		}
+		res.Outputs[i] = processed
+	}
+	return nil
+}
+
+func processImage(img imageOutputPart, p ImageProcessing) (imageOutputPart, error) {
+	if p.Format != "" || p.MaxWidth > 0 || p.MaxHeight > 0 {
+		data, mime, err := imaging.Transform(img.Data, imaging.Options{
+			Format:    p.Format,
+			Quality:   p.JPEGQuality,
+			MaxWidth:  p.MaxWidth,
+			MaxHeight: p.MaxHeight,
+		})
+		if err != nil {
+			return img, err
+		}
+		img.Data, img.MIME = data, mime
+	}
+
+	if p.StripMetadata {
+		img.Data = imaging.StripMetadata(img.Data)
+	}
+
+	if p.ThumbnailWidth > 0 || p.ThumbnailHeight > 0 {
+		thumb, _, err := imaging.Transform(img.Data, imaging.Options{
+			Format:    formatFromMIME(img.MIME),
+			Quality:   p.JPEGQuality,
+			MaxWidth:  p.ThumbnailWidth,
+			MaxHeight: p.ThumbnailHeight,
+		})
+		if err != nil {
+			return img, err
+		}
+		img.Thumbnail = imaging.StripMetadata(thumb)
+	}
+
+	if p.PerceptualHash {
+		h, err := HashImage(img.Data)
+		if err != nil {
+			return img, err
+		}
+		img.Hash = &h
+	}
+
+	return img, nil
+}
+
+func formatFromMIME(mime string) string {
+	switch mime {
+	case "image/jpeg":
+		return "jpeg"
+	case "image/gif":
+		return "gif"
+	default:
+		return "png"
+	}
+}
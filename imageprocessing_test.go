@@ -0,0 +1,78 @@
+package grail_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestImagePostProcessing(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 64, 32))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	src := pngWithChunk(t, "tEXt", []byte("Comment\x00secret"))
+
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{
+				Outputs: []grail.OutputPart{
+					grail.NewImageOutputPart(buf.Bytes(), "image/png", ""),
+					grail.NewImageOutputPart(src, "image/png", ""),
+				},
+			}, nil
+		},
+	}
+
+	t.Run("resize, convert, and thumbnail", func(t *testing.T) {
+		client := grail.NewClient(prov, grail.WithImagePostProcessing(grail.ImageProcessing{
+			Format:         "jpeg",
+			MaxWidth:       32,
+			ThumbnailWidth: 8,
+		}))
+		res, err := client.Generate(context.Background(), grail.Request{
+			Inputs: []grail.Input{grail.InputText("draw")},
+			Output: grail.OutputImage(grail.ImageSpec{Count: 1}),
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		info := res.ImageOutputs()[0]
+		if info.MIME != "image/jpeg" {
+			t.Fatalf("expected image/jpeg, got %s", info.MIME)
+		}
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(info.Data))
+		if err != nil || format != "jpeg" || cfg.Width != 32 || cfg.Height != 16 {
+			t.Fatalf("expected 32x16 jpeg, got %dx%d %s (%v)", cfg.Width, cfg.Height, format, err)
+		}
+		thumb, _, err := image.DecodeConfig(bytes.NewReader(info.Thumbnail))
+		if err != nil || thumb.Width != 8 || thumb.Height != 4 {
+			t.Fatalf("expected 8x4 thumbnail, got %dx%d (%v)", thumb.Width, thumb.Height, err)
+		}
+	})
+
+	t.Run("strip metadata", func(t *testing.T) {
+		client := grail.NewClient(prov, grail.WithImagePostProcessing(grail.ImageProcessing{
+			StripMetadata: true,
+		}))
+		res, err := client.Generate(context.Background(), grail.Request{
+			Inputs: []grail.Input{grail.InputText("draw")},
+			Output: grail.OutputImage(grail.ImageSpec{Count: 1}),
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		data := res.ImageOutputs()[1].Data
+		if bytes.Contains(data, []byte("secret")) {
+			t.Fatalf("expected text chunk to be stripped")
+		}
+		if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+			t.Fatalf("stripped image no longer decodes: %v", err)
+		}
+	})
+}
@@ -0,0 +1,200 @@
+// Package imageutil provides helpers built on top of grail image generation.
+//
+// Example usage:
+//
+//	anim, err := imageutil.Animate(ctx, client, grail.Request{
+//		Inputs: []grail.Input{grail.InputText("A paper boat drifting across a pond")},
+//	}, imageutil.AnimateOptions{Frames: 6})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	os.WriteFile("boat.gif", anim.Data, 0644)
+package imageutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/gif"
+	"sync"
+	"time"
+
+	"github.com/montanaflynn/grail"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // register WebP decoding for frames
+
+	_ "image/jpeg" // register JPEG decoding for frames
+	_ "image/png"  // register PNG decoding for frames
+)
+
+// AnimateOptions configures Animate.
+type AnimateOptions struct {
+	// Frames is the number of frames to generate (default 4).
+	Frames int
+	// FrameInputs builds the inputs for frame i of n. The default appends a
+	// short instruction describing the frame's position in the sequence to
+	// the base request inputs, so each generation varies slightly.
+	FrameInputs func(base []grail.Input, i, n int) []grail.Input
+	// Delay is the display time of each frame (default 200ms).
+	Delay time.Duration
+	// LoopCount is the number of times the animation repeats; 0 loops forever
+	// and -1 plays it once.
+	LoopCount int
+	// Concurrency bounds how many frames are generated at once (default 1).
+	Concurrency int
+	// Format selects the container; only "gif" (the default) is supported.
+	Format string
+}
+
+// Animation is the result of Animate.
+type Animation struct {
+	Data   []byte                  // encoded animation
+	MIME   string                  // e.g. "image/gif"
+	Frames []grail.ImageOutputInfo // the individual generated frames, in order
+	Usage  grail.Usage             // summed across all frame generations
+}
+
+// Animate generates a sequence of images from req and assembles them into an
+// animated image. req.Output is ignored; each frame is requested as a single
+// image using req's model, tier, and provider options.
+func Animate(ctx context.Context, client grail.Client, req grail.Request, opts AnimateOptions) (Animation, error) {
+	if opts.Format != "" && opts.Format != "gif" {
+		return Animation{}, grail.NewGrailError(grail.Unsupported, fmt.Sprintf("animation format %q is not supported (use \"gif\")", opts.Format))
+	}
+	n := opts.Frames
+	if n <= 0 {
+		n = 4
+	}
+	frameInputs := opts.FrameInputs
+	if frameInputs == nil {
+		frameInputs = defaultFrameInputs
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	frames := make([]grail.ImageOutputInfo, n)
+	usages := make([]grail.Usage, n)
+	errs := make([]error, n)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+
+			frameReq := req
+			frameReq.Inputs = frameInputs(req.Inputs, i, n)
+			frameReq.Output = grail.OutputImage(grail.ImageSpec{Count: 1})
+			res, err := client.Generate(ctx, frameReq)
+			if err != nil {
+				errs[i] = err
+				cancel()
+				return
+			}
+			infos := res.ImageOutputs()
+			if len(infos) == 0 {
+				errs[i] = grail.NewGrailError(grail.OutputInvalid, fmt.Sprintf("frame %d: no image returned", i))
+				cancel()
+				return
+			}
+			frames[i] = infos[0]
+			usages[i] = res.Usage
+		}(i)
+	}
+	wg.Wait()
+
+	// Report the first real failure rather than a cancellation it caused.
+	var firstErr error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if firstErr == nil || (errors.Is(firstErr, context.Canceled) && !errors.Is(err, context.Canceled)) {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return Animation{}, firstErr
+	}
+
+	images := make([][]byte, n)
+	var usage grail.Usage
+	for i, f := range frames {
+		images[i] = f.Data
+		usage = usage.Add(usages[i])
+	}
+
+	data, err := EncodeGIF(images, opts.Delay, opts.LoopCount)
+	if err != nil {
+		return Animation{}, err
+	}
+	return Animation{Data: data, MIME: "image/gif", Frames: frames, Usage: usage}, nil
+}
+
+func defaultFrameInputs(base []grail.Input, i, n int) []grail.Input {
+	inputs := append([]grail.Input(nil), base...)
+	return append(inputs, grail.InputText(fmt.Sprintf(
+		"This is frame %d of %d in an animation. Keep the subject, style, and composition consistent with the other frames, advancing the motion slightly.",
+		i+1, n)))
+}
+
+// EncodeGIF assembles encoded PNG, JPEG, GIF, or WebP frames into an animated
+// GIF. Frames are scaled to the size of the first frame and quantized to a
+// shared palette. A zero delay defaults to 200ms per frame. loopCount follows
+// the GIF convention: 0 loops forever and -1 plays once.
+func EncodeGIF(frames [][]byte, delay time.Duration, loopCount int) ([]byte, error) {
+	if len(frames) == 0 {
+		return nil, grail.NewGrailError(grail.InvalidArgument, "at least one frame is required")
+	}
+	if delay <= 0 {
+		delay = 200 * time.Millisecond
+	}
+	// GIF delays are in hundredths of a second.
+	centis := max(1, int(delay/(10*time.Millisecond)))
+
+	anim := &gif.GIF{LoopCount: loopCount}
+
+	var bounds image.Rectangle
+	for i, data := range frames {
+		src, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("frame %d: decode failed: %v", i, err)).WithCause(err)
+		}
+		if i == 0 {
+			bounds = image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy())
+		}
+		dst := image.NewPaletted(bounds, palette.Plan9)
+		if src.Bounds().Size() == bounds.Size() {
+			draw.FloydSteinberg.Draw(dst, bounds, src, src.Bounds().Min)
+		} else {
+			scaled := image.NewRGBA(bounds)
+			draw.CatmullRom.Scale(scaled, bounds, src, src.Bounds(), draw.Src, nil)
+			draw.FloydSteinberg.Draw(dst, bounds, scaled, image.Point{})
+		}
+		anim.Image = append(anim.Image, dst)
+		anim.Delay = append(anim.Delay, centis)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		return nil, grail.NewGrailError(grail.Internal, fmt.Sprintf("encode gif: %v", err)).WithCause(err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,92 @@
+package imageutil_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"sync/atomic"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/imageutil"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func solidPNG(t *testing.T, w, h int, c color.Color) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestAnimate(t *testing.T) {
+	var calls atomic.Int32
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			n := calls.Add(1)
+			if len(req.Inputs) != 2 {
+				t.Errorf("expected base input plus frame instruction, got %d inputs", len(req.Inputs))
+			}
+			// Vary frame size to exercise scaling to the first frame's bounds.
+			size := 16 * int(n)
+			return grail.Response{
+				Outputs: []grail.OutputPart{
+					grail.NewImageOutputPart(solidPNG(t, size, size, color.RGBA{R: uint8(n * 60), A: 255}), "image/png", ""),
+				},
+				Usage: grail.Usage{TotalTokens: 10},
+			}, nil
+		},
+	}
+	client := grail.NewClient(prov)
+
+	anim, err := imageutil.Animate(context.Background(), client, grail.Request{
+		Inputs: []grail.Input{grail.InputText("a bouncing ball")},
+	}, imageutil.AnimateOptions{Frames: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if anim.MIME != "image/gif" || len(anim.Frames) != 3 {
+		t.Fatalf("expected 3-frame gif, got %s with %d frames", anim.MIME, len(anim.Frames))
+	}
+	if anim.Usage.TotalTokens != 30 {
+		t.Fatalf("expected summed usage 30, got %d", anim.Usage.TotalTokens)
+	}
+
+	decoded, err := gif.DecodeAll(bytes.NewReader(anim.Data))
+	if err != nil {
+		t.Fatalf("decode gif: %v", err)
+	}
+	if len(decoded.Image) != 3 {
+		t.Fatalf("expected 3 frames, got %d", len(decoded.Image))
+	}
+	first, _, err := image.DecodeConfig(bytes.NewReader(anim.Frames[0].Data))
+	if err != nil {
+		t.Fatalf("decode first frame: %v", err)
+	}
+	for i, frame := range decoded.Image {
+		if frame.Bounds().Dx() != first.Width {
+			t.Fatalf("frame %d: expected width %d, got %d", i, first.Width, frame.Bounds().Dx())
+		}
+	}
+}
+
+func TestAnimateUnsupportedFormat(t *testing.T) {
+	client := grail.NewClient(&mock.Provider{})
+	_, err := imageutil.Animate(context.Background(), client, grail.Request{
+		Inputs: []grail.Input{grail.InputText("x")},
+	}, imageutil.AnimateOptions{Format: "webp"})
+	if grail.GetErrorCode(err) != grail.Unsupported {
+		t.Fatalf("expected unsupported, got %v", err)
+	}
+}
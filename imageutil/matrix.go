@@ -0,0 +1,242 @@
+package imageutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/montanaflynn/grail"
+)
+
+// Matrix describes a batch of image generations expanded from a prompt
+// template across every combination of its variables.
+type Matrix struct {
+	// Prompt is a text/template rendered once per combination, e.g.
+	// "A {{.style}} illustration of {{.subject}}".
+	Prompt string
+	// Variables maps each template variable to its values. The matrix is the
+	// cartesian product of all values.
+	Variables map[string][]string
+	// Filename is a text/template for output file names (without extension),
+	// e.g. "{{.subject}}-{{.style}}". The default numbers items in order.
+	// Names are sanitized to letters, digits, '-', '_', and '.', so they
+	// stay inside OutputDir. A name that's already taken, say by values
+	// that sanitize alike ("org/model" and "org-model") or that differ only
+	// in case, gets the item's number appended.
+	Filename string
+	// OutputDir receives the images and manifest.json. It is created if needed.
+	OutputDir string
+	// Request supplies the model, tier, provider options, and output spec.
+	// Its Inputs are prepended to each rendered prompt. The default output is
+	// a single image.
+	Request grail.Request
+	// Concurrency bounds in-flight generations (default 4).
+	Concurrency int
+}
+
+// Manifest records what GenerateMatrix produced.
+type Manifest struct {
+	Prompt    string              `json:"prompt"`
+	Variables map[string][]string `json:"variables"`
+	CreatedAt time.Time           `json:"created_at"`
+	Items     []ManifestItem      `json:"items"`
+}
+
+// ManifestItem is one combination of the matrix.
+type ManifestItem struct {
+	Vars   map[string]string `json:"vars"`
+	Prompt string            `json:"prompt"`
+	Files  []string          `json:"files,omitempty"` // relative to OutputDir
+	Models []grail.ModelUse  `json:"models,omitempty"`
+	Usage  grail.Usage       `json:"usage"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// ManifestFile is the name of the manifest written to Matrix.OutputDir.
+const ManifestFile = "manifest.json"
+
+// GenerateMatrix renders every combination of m.Variables into a prompt, runs
+// the generations with bounded concurrency, writes the images to m.OutputDir,
+// and writes a manifest describing each item. Failed items are recorded in the
+// manifest rather than aborting the run; the returned error covers template,
+// filesystem, and manifest failures only.
+func GenerateMatrix(ctx context.Context, client grail.Client, m Matrix) (Manifest, error) {
+	promptTmpl, err := template.New("prompt").Option("missingkey=error").Parse(m.Prompt)
+	if err != nil {
+		return Manifest{}, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("parse prompt template: %v", err)).WithCause(err)
+	}
+	var nameTmpl *template.Template
+	if m.Filename != "" {
+		nameTmpl, err = template.New("filename").Option("missingkey=error").Parse(m.Filename)
+		if err != nil {
+			return Manifest{}, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("parse filename template: %v", err)).WithCause(err)
+		}
+	}
+	if m.OutputDir == "" {
+		return Manifest{}, grail.NewGrailError(grail.InvalidArgument, "output directory must be specified")
+	}
+	if err := os.MkdirAll(m.OutputDir, 0o755); err != nil {
+		return Manifest{}, grail.NewGrailError(grail.Internal, fmt.Sprintf("create output directory: %v", err)).WithCause(err)
+	}
+
+	combos := expandMatrix(m.Variables)
+	manifest := Manifest{
+		Prompt:    m.Prompt,
+		Variables: m.Variables,
+		CreatedAt: time.Now().UTC(),
+		Items:     make([]ManifestItem, len(combos)),
+	}
+	reqs := make([]grail.Request, len(combos))
+	names := make([]string, len(combos))
+	for i, vars := range combos {
+		prompt, err := render(promptTmpl, vars)
+		if err != nil {
+			return Manifest{}, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("render prompt %d: %v", i, err)).WithCause(err)
+		}
+		names[i] = fmt.Sprintf("%03d", i+1)
+		if nameTmpl != nil {
+			name, err := render(nameTmpl, vars)
+			if err != nil {
+				return Manifest{}, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("render filename %d: %v", i, err)).WithCause(err)
+			}
+			names[i] = sanitizeFilename(name)
+		}
+
+		req := m.Request
+		req.Inputs = append(append([]grail.Input(nil), m.Request.Inputs...), grail.InputText(prompt))
+		if req.Output == nil {
+			req.Output = grail.OutputImage(grail.ImageSpec{Count: 1})
+		}
+		reqs[i] = req
+		manifest.Items[i] = ManifestItem{Vars: vars, Prompt: prompt}
+	}
+
+	results := grail.GenerateBatch(ctx, client, reqs, grail.BatchOptions{Concurrency: m.Concurrency})
+	// Files are named in item order, so the same matrix names them the same
+	// way every run.
+	sort.Slice(results, func(i, j int) bool { return results[i].Index < results[j].Index })
+	used := map[string]bool{strings.ToLower(ManifestFile): true}
+	for _, r := range results {
+		item := &manifest.Items[r.Index]
+		if r.Err != nil {
+			item.Error = r.Err.Error()
+			continue
+		}
+		item.Usage = r.Response.Usage
+		item.Models = r.Response.Provider.Models
+		images := r.Response.ImageOutputs()
+		for j, img := range images {
+			name := names[r.Index]
+			if len(images) > 1 {
+				name = fmt.Sprintf("%s-%02d", name, j+1)
+			}
+			name = claimFilename(used, name, extFromMIME(img.MIME), r.Index)
+			if err := os.WriteFile(filepath.Join(m.OutputDir, name), img.Data, 0o644); err != nil {
+				return manifest, grail.NewGrailError(grail.Internal, fmt.Sprintf("write %s: %v", name, err)).WithCause(err)
+			}
+			item.Files = append(item.Files, name)
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, grail.NewGrailError(grail.Internal, fmt.Sprintf("encode manifest: %v", err)).WithCause(err)
+	}
+	if err := os.WriteFile(filepath.Join(m.OutputDir, ManifestFile), data, 0o644); err != nil {
+		return manifest, grail.NewGrailError(grail.Internal, fmt.Sprintf("write manifest: %v", err)).WithCause(err)
+	}
+	return manifest, nil
+}
+
+// expandMatrix returns the cartesian product of vars, iterating variable names
+// in sorted order so the expansion is deterministic.
+func expandMatrix(vars map[string][]string) []map[string]string {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	combos := []map[string]string{{}}
+	for _, k := range keys {
+		var next []map[string]string
+		for _, c := range combos {
+			for _, v := range vars[k] {
+				nc := make(map[string]string, len(c)+1)
+				for ck, cv := range c {
+					nc[ck] = cv
+				}
+				nc[k] = v
+				next = append(next, nc)
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+func render(t *template.Template, vars map[string]string) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func sanitizeFilename(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '-'
+	}, strings.TrimSpace(s))
+	s = strings.Trim(s, ".-")
+	if s == "" {
+		return "image"
+	}
+	// Leave room for the suffixes and extension within common 255-byte
+	// name limits.
+	if len(s) > maxFilename {
+		s = s[:maxFilename]
+	}
+	return s
+}
+
+const maxFilename = 200
+
+// claimFilename returns name+ext, or, if that's taken, name with item i's
+// number (and a counter, if need be) appended, and marks it taken. File
+// systems may ignore case, so names differing only in case are the same.
+func claimFilename(used map[string]bool, name, ext string, i int) string {
+	file := name + ext
+	for n := 1; used[strings.ToLower(file)]; n++ {
+		file = fmt.Sprintf("%s-%03d%s", name, i+1, ext)
+		if n > 1 {
+			file = fmt.Sprintf("%s-%03d-%d%s", name, i+1, n, ext)
+		}
+	}
+	used[strings.ToLower(file)] = true
+	return file
+}
+
+func extFromMIME(mime string) string {
+	switch mime {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/webp":
+		return ".webp"
+	case "image/gif":
+		return ".gif"
+	case "image/png":
+		return ".png"
+	default:
+		return ".bin"
+	}
+}
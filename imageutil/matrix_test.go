@@ -0,0 +1,122 @@
+package imageutil_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/imageutil"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestGenerateMatrix(t *testing.T) {
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			prompt, _ := grail.AsTextInput(req.Inputs[len(req.Inputs)-1])
+			if strings.Contains(prompt, "fox") && strings.Contains(prompt, "neon") {
+				return grail.Response{}, errors.New("boom")
+			}
+			return grail.Response{
+				Outputs: []grail.OutputPart{grail.NewImageOutputPart([]byte(prompt), "image/png", "")},
+			}, nil
+		},
+	}
+	client := grail.NewClient(prov)
+	dir := t.TempDir()
+
+	manifest, err := imageutil.GenerateMatrix(context.Background(), client, imageutil.Matrix{
+		Prompt: "A {{.style}} drawing of a {{.subject}}",
+		Variables: map[string][]string{
+			"style":   {"watercolor", "neon"},
+			"subject": {"cat", "fox"},
+		},
+		Filename:  "{{.subject}} {{.style}}",
+		OutputDir: dir,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(manifest.Items) != 4 {
+		t.Fatalf("expected 4 items, got %d", len(manifest.Items))
+	}
+
+	var failed int
+	for _, item := range manifest.Items {
+		if item.Error != "" {
+			failed++
+			continue
+		}
+		if len(item.Files) != 1 {
+			t.Fatalf("expected 1 file for %v, got %v", item.Vars, item.Files)
+		}
+		want := item.Vars["subject"] + "-" + item.Vars["style"] + ".png"
+		if item.Files[0] != want {
+			t.Fatalf("expected file %q, got %q", want, item.Files[0])
+		}
+		data, err := os.ReadFile(filepath.Join(dir, item.Files[0]))
+		if err != nil || string(data) != item.Prompt {
+			t.Fatalf("unexpected file contents %q (%v)", data, err)
+		}
+	}
+	if failed != 1 {
+		t.Fatalf("expected 1 failed item, got %d", failed)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, imageutil.ManifestFile))
+	if err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+	var onDisk imageutil.Manifest
+	if err := json.Unmarshal(raw, &onDisk); err != nil || len(onDisk.Items) != 4 {
+		t.Fatalf("unexpected manifest on disk (%v): %s", err, raw)
+	}
+}
+
+func TestGenerateMatrix_FileNames(t *testing.T) {
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			prompt, _ := grail.AsTextInput(req.Inputs[len(req.Inputs)-1])
+			return grail.Response{
+				Outputs: []grail.OutputPart{grail.NewImageOutputPart([]byte(prompt), "image/png", "")},
+			}, nil
+		},
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	models := []string{"org/model", "org-model", "ORG-MODEL", "../../escape", "manifest", "org-model-001"}
+	manifest, err := imageutil.GenerateMatrix(context.Background(), grail.NewClient(prov), imageutil.Matrix{
+		Prompt:    "{{.model}}",
+		Variables: map[string][]string{"model": models},
+		Filename:  "{{.model}}",
+		OutputDir: out,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, item := range manifest.Items {
+		if item.Error != "" || len(item.Files) != 1 {
+			t.Fatalf("unexpected item %+v", item)
+		}
+		name := item.Files[0]
+		if seen[strings.ToLower(name)] || filepath.Base(name) != name || strings.EqualFold(name, imageutil.ManifestFile) {
+			t.Fatalf("file %q collides or leaves the output directory (%v)", name, item.Files)
+		}
+		seen[strings.ToLower(name)] = true
+		if data, err := os.ReadFile(filepath.Join(out, name)); err != nil || string(data) != item.Prompt {
+			t.Fatalf("%s holds %q, want %q (%v)", name, data, item.Prompt, err)
+		}
+	}
+	if got := []string{manifest.Items[0].Files[0], manifest.Items[1].Files[0], manifest.Items[3].Files[0]}; got[0] != "org-model.png" ||
+		got[1] != "org-model-002.png" || got[2] != "escape.png" {
+		t.Fatalf("unexpected names %v", got)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("expected only the output directory in its parent, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,132 @@
+package grail
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+//
+// Input priorities
+//
+
+// WarningInputDropped is set on a response whose request had an input marked
+// WithDroppable dropped. Its message says which input and why.
+const WarningInputDropped = "input_dropped"
+
+type droppableOpt struct{ priority int }
+
+func (o droppableOpt) applyFileOpt(fo *fileOpt) { fo.droppable, fo.priority = true, o.priority }
+func (o droppableOpt) applyTextOpt(to *textOpt) { to.droppable, to.priority = true, o.priority }
+
+// WithDroppable marks an input the request can do without, such as
+// supporting context or an optional attachment, so the client drops it
+// rather than failing the request. Inputs are required by default. A
+// droppable input is dropped, with a WarningInputDropped warning, when:
+//
+//   - the provider doesn't accept its type or size, or the model can't
+//     understand it, such as a PDF for a model without PDFUnderstanding;
+//   - the request is over WithSizeLimits' MaxRequestBytes (unless WarnOnly
+//     is set), or its estimated cost is over a WithBudget limit. Droppable
+//     inputs are dropped lowest priority first, and later inputs of equal
+//     priority first, until the request fits; if it doesn't fit without
+//     all of them, none are dropped and the request fails.
+//
+// A required input the provider or model can't take fails the request; the
+// error's "input" detail is its index.
+func WithDroppable(priority int) InputOpt {
+	return droppableOpt{priority: priority}
+}
+
+// InputPriority returns the priority input was marked WithDroppable with.
+// droppable is false for required inputs.
+func InputPriority(input Input) (priority int, droppable bool) {
+	switch v := input.(type) {
+	case textInput:
+		return v.Priority, v.Droppable
+	case fileInput:
+		return v.Priority, v.Droppable
+	case fileReaderInput:
+		return v.Priority, v.Droppable
+	case uploadedFileInput:
+		return v.Priority, v.Droppable
+	}
+	return 0, false
+}
+
+// filterInputs drops the droppable inputs check rejects, with a warning for
+// each, and fails if it rejects a required one. check returns the error code
+// and reason an input is rejected with, or "" to accept it. Errors and
+// warnings are prefixed with prefix and the input's index.
+func filterInputs(inputs []Input, prefix, provider string, check func(in Input) (ErrorCode, string)) ([]Input, []Warning, error) {
+	var kept []Input
+	var warnings []Warning
+	for i, in := range inputs {
+		code, reason := check(in)
+		if reason == "" {
+			if kept != nil {
+				kept = append(kept, in)
+			}
+			continue
+		}
+		if _, ok := InputPriority(in); !ok {
+			err := NewGrailError(code, fmt.Sprintf("%sinput %d: %s", prefix, i, reason)).WithDetail("input", strconv.Itoa(i))
+			if provider != "" {
+				err = err.WithProviderName(provider)
+			}
+			return nil, nil, err
+		}
+		if kept == nil {
+			kept = append(make([]Input, 0, len(inputs)), inputs[:i]...)
+		}
+		warnings = append(warnings, droppedWarning(prefix, i, reason))
+	}
+	if kept == nil {
+		return inputs, nil, nil
+	}
+	return kept, warnings, nil
+}
+
+// trimInputs drops req's droppable inputs, lowest priority first, until fits
+// accepts the request. If it doesn't accept it with all of them dropped, ok
+// is false and req is returned unchanged.
+func trimInputs(req Request, reason string, fits func(Request) bool) (trimmed Request, warnings []Warning, ok bool) {
+	var order []int
+	for i, in := range req.Inputs {
+		if _, droppable := InputPriority(in); droppable {
+			order = append(order, i)
+		}
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		pa, _ := InputPriority(req.Inputs[a])
+		pb, _ := InputPriority(req.Inputs[b])
+		if c := cmp.Compare(pa, pb); c != 0 {
+			return c
+		}
+		return cmp.Compare(b, a)
+	})
+
+	dropped := make([]bool, len(req.Inputs))
+	for _, i := range order {
+		dropped[i] = true
+		trimmed = req
+		trimmed.Inputs = nil
+		warnings = nil
+		for j, in := range req.Inputs {
+			if dropped[j] {
+				warnings = append(warnings, droppedWarning("", j, reason))
+				continue
+			}
+			trimmed.Inputs = append(trimmed.Inputs, in)
+		}
+		if fits(trimmed) {
+			return trimmed, warnings, true
+		}
+	}
+	return req, nil, false
+}
+
+func droppedWarning(prefix string, i int, reason string) Warning {
+	return Warning{Code: WarningInputDropped, Message: fmt.Sprintf("%sinput %d dropped: %s", prefix, i, reason)}
+}
@@ -0,0 +1,119 @@
+package grail_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestDroppableInputs(t *testing.T) {
+	var sent []grail.Input
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			sent = req.Inputs
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewImageOutputPart([]byte("img"), "image/png", "")}}, nil
+		},
+	}
+	ctx := context.Background()
+	texts := func() []string {
+		var out []string
+		for _, in := range sent {
+			if s, ok := grail.AsTextInput(in); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	dropped := func(res grail.Response) []string {
+		var out []string
+		for _, w := range res.Warnings {
+			if w.Code == grail.WarningInputDropped {
+				out = append(out, w.Message)
+			}
+		}
+		return out
+	}
+
+	if p, ok := grail.InputPriority(grail.InputText("a", grail.WithDroppable(3))); !ok || p != 3 {
+		t.Errorf("InputPriority = %d, %v", p, ok)
+	}
+	if _, ok := grail.InputPriority(grail.InputPDF([]byte("%PDF"))); ok {
+		t.Error("expected inputs to be required by default")
+	}
+
+	// A droppable input the provider doesn't accept is dropped; a required
+	// one fails the request.
+	client := grail.NewClient(imageOnlyProvider{prov})
+	res, err := client.Generate(ctx, grail.Request{
+		Inputs: []grail.Input{grail.InputText("draw it"), grail.InputPDF([]byte("%PDF"), grail.WithDroppable(0))},
+		Output: grail.OutputImage(grail.ImageSpec{}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 {
+		t.Errorf("expected the PDF to be dropped, sent %d inputs", len(sent))
+	}
+	if w := dropped(res); len(w) != 1 || !strings.HasPrefix(w[0], "input 1 dropped: provider mock does not accept application/pdf") {
+		t.Errorf("unexpected warnings %q", w)
+	}
+	_, err = client.Generate(ctx, grail.Request{
+		Inputs: []grail.Input{grail.InputText("draw it"), grail.InputPDF([]byte("%PDF"))},
+		Output: grail.OutputImage(grail.ImageSpec{}),
+	})
+	if ge, ok := err.(grail.GrailError); !ok || ge.Code() != grail.Unsupported || ge.Details()["input"] != "1" {
+		t.Errorf("expected the required PDF to fail the request, got %v", err)
+	}
+
+	// Over a size limit, droppable inputs go lowest priority first, and the
+	// later of equal priorities first, until the request fits.
+	sized := grail.NewClient(prov, grail.WithSizeLimits(grail.SizeLimits{MaxRequestBytes: 60}))
+	req := grail.Request{
+		Inputs: []grail.Input{
+			grail.InputText(strings.Repeat("a", 20)),
+			grail.InputText(strings.Repeat("b", 30), grail.WithDroppable(1)),
+			grail.InputText(strings.Repeat("c", 30), grail.WithDroppable(0)),
+			grail.InputText(strings.Repeat("d", 30), grail.WithDroppable(1)),
+			grail.InputText(strings.Repeat("e", 10), grail.WithDroppable(0)),
+		},
+		Output: grail.OutputImage(grail.ImageSpec{}),
+	}
+	res, err = sized.Generate(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(texts(), ""); got != strings.Repeat("a", 20)+strings.Repeat("b", 30) {
+		t.Errorf("unexpected inputs sent %q", got)
+	}
+	if w := dropped(res); len(w) != 3 || !strings.HasPrefix(w[0], "input 2 dropped: request size") || !strings.HasPrefix(w[2], "input 4 dropped") {
+		t.Errorf("unexpected warnings %q", w)
+	}
+	req.Inputs[0] = grail.InputText(strings.Repeat("a", 61))
+	sent = nil
+	if _, err := sized.Generate(ctx, req); grail.GetErrorCode(err) != grail.InvalidArgument || sent != nil {
+		t.Errorf("expected required inputs over the limit to fail the request, got %v", err)
+	}
+
+	// Over a budget, droppable inputs are dropped to fit too.
+	budgeted := grail.NewClient(prov,
+		grail.WithPrices(grail.PriceTable{"priced": {InputPerMTok: 1, OutputPerMTok: 10}}),
+		grail.WithBudget(0.01, 0),
+	)
+	res, err = budgeted.Generate(ctx, grail.Request{
+		Inputs: []grail.Input{
+			grail.InputText("summarize"),
+			grail.InputText(strings.Repeat("background ", 14_000), grail.WithDroppable(0)),
+		},
+		Output: grail.OutputImage(grail.ImageSpec{}),
+		Model:  "priced",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := texts(); len(got) != 1 || got[0] != "summarize" || len(dropped(res)) != 1 {
+		t.Errorf("expected the background to be dropped, sent %q (warnings %q)", got, dropped(res))
+	}
+}
@@ -0,0 +1,167 @@
+package httplog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync/atomic"
+	"time"
+)
+
+// Dumper writes every request and response it carries to a JSON file in Dir,
+// named by the provider's request ID. Bodies are kept whole, so the dump can
+// be replayed; credentials in headers, query parameters, and JSON fields are
+// redacted.
+type Dumper struct {
+	Base     http.RoundTripper // defaults to http.DefaultTransport
+	Dir      string
+	Provider string
+	// OnError is called when a dump can't be written. The round trip itself
+	// is never failed by a dump error.
+	OnError func(error)
+
+	seq atomic.Int64
+}
+
+// Dump is the content of one dump file.
+type Dump struct {
+	Provider  string        `json:"provider"`
+	Time      time.Time     `json:"time"`
+	RequestID string        `json:"request_id,omitempty"`
+	Duration  time.Duration `json:"duration_ns"`
+	Request   DumpMessage   `json:"request"`
+	Response  *DumpMessage  `json:"response,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// DumpMessage is one side of a round trip. Body is inlined as JSON when it
+// parses, and as a string otherwise.
+type DumpMessage struct {
+	Method  string          `json:"method,omitempty"`
+	URL     string          `json:"url,omitempty"`
+	Status  int             `json:"status,omitempty"`
+	Headers http.Header     `json:"headers,omitempty"`
+	Body    json.RawMessage `json:"body,omitempty"`
+}
+
+var sensitiveHeader = regexp.MustCompile(`(?i)^(authorization|x-api-key|x-goog-api-key|api-key|cookie|set-cookie)$`)
+
+func (d *Dumper) base() http.RoundTripper {
+	if d.Base != nil {
+		return d.Base
+	}
+	return http.DefaultTransport
+}
+
+// RoundTrip implements http.RoundTripper.
+func (d *Dumper) RoundTrip(req *http.Request) (*http.Response, error) {
+	dump := &Dump{
+		Provider: d.Provider,
+		Time:     time.Now().UTC(),
+		Request:  DumpMessage{Method: req.Method, URL: RedactURL(req.URL), Headers: redactHeaders(req.Header)},
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		dump.Request.Body = dumpBody(body)
+	}
+
+	start := time.Now()
+	res, err := d.base().RoundTrip(req)
+	dump.Duration = time.Since(start)
+	if err != nil {
+		dump.Error = err.Error()
+		d.write(dump)
+		return res, err
+	}
+	dump.RequestID = requestID(res.Header)
+	dump.Response = &DumpMessage{Status: res.StatusCode, Headers: redactHeaders(res.Header)}
+	if res.Body == nil {
+		d.write(dump)
+		return res, nil
+	}
+	// Write the dump once the SDK has read the body, so streamed responses
+	// aren't buffered up front.
+	res.Body = &bodyDumper{ReadCloser: res.Body, done: func(body []byte) {
+		dump.Response.Body = dumpBody(body)
+		d.write(dump)
+	}}
+	return res, nil
+}
+
+func (d *Dumper) write(dump *Dump) {
+	name := dump.RequestID
+	if name == "" {
+		name = fmt.Sprintf("%s-%d", dump.Time.Format("20060102T150405.000000000"), d.seq.Add(1))
+	}
+	name = filepath.Base(d.Provider + "-" + name)
+	data, err := json.MarshalIndent(dump, "", "  ")
+	if err == nil {
+		if err = os.MkdirAll(d.Dir, 0o700); err == nil {
+			err = os.WriteFile(filepath.Join(d.Dir, name+".json"), data, 0o600)
+		}
+	}
+	if err != nil && d.OnError != nil {
+		d.OnError(fmt.Errorf("write wire dump: %w", err))
+	}
+}
+
+type bodyDumper struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	done func([]byte)
+	sent bool
+}
+
+func (b *bodyDumper) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *bodyDumper) Close() error {
+	b.finish()
+	return b.ReadCloser.Close()
+}
+
+func (b *bodyDumper) finish() {
+	if !b.sent {
+		b.sent = true
+		b.done(b.buf.Bytes())
+	}
+}
+
+func redactHeaders(h http.Header) http.Header {
+	out := h.Clone()
+	for k := range out {
+		if sensitiveHeader.MatchString(k) {
+			out[k] = []string{"REDACTED"}
+		}
+	}
+	return out
+}
+
+// dumpBody returns body as inline JSON with secret fields redacted, or as a
+// JSON string when it isn't JSON.
+func dumpBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		return json.RawMessage(secretField.ReplaceAll(body, []byte(`$1"REDACTED"`)))
+	}
+	s, _ := json.Marshal(string(body))
+	return s
+}
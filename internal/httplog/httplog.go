@@ -0,0 +1,218 @@
+// Package httplog provides an http.RoundTripper that logs provider HTTP
+// traffic through slog. Providers install it beneath their SDK clients so the
+// actual wire requests show up in grail's logs with consistent attributes.
+package httplog
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMaxBody is the number of body bytes logged per request or response.
+const DefaultMaxBody = 2048
+
+// Transport logs each request and response at Level. Credentials in headers
+// and query parameters are never logged. Bodies are logged truncated to
+// MaxBody bytes, with base64 payloads elided, only when the logger is enabled
+// at Level.
+type Transport struct {
+	Base     http.RoundTripper   // defaults to http.DefaultTransport; change it with WrapBase once in use
+	Logger   func() *slog.Logger // looked up per request so providers can swap loggers
+	Provider string
+	Level    slog.Level
+	MaxBody  int  // 0 disables body logging
+	Attempts bool // number round trips made with a WithAttemptCounter context
+
+	mu sync.RWMutex // guards Base against WrapBase
+}
+
+// Wrap returns a copy of hc (or a new client) whose transport is t, with t
+// sitting on top of hc's original transport.
+func Wrap(hc *http.Client, t *Transport) *http.Client {
+	var out http.Client
+	if hc != nil {
+		out = *hc
+	}
+	t.Base = out.Transport
+	out.Transport = t
+	return &out
+}
+
+// WrapBase replaces the transport beneath t with wrap(base), letting the
+// client layer its own instrumentation under a provider's transport without
+// rebuilding the SDK client. It's safe to call while t is serving requests.
+func (t *Transport) WrapBase(wrap func(http.RoundTripper) http.RoundTripper) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t.Base = wrap(base)
+}
+
+type attemptKey struct{}
+
+// WithAttemptCounter returns a context in which each HTTP round trip made with
+// it is numbered, so SDK-internal retries show up as attempt 2, 3, ...
+func WithAttemptCounter(ctx context.Context) context.Context {
+	return context.WithValue(ctx, attemptKey{}, new(atomic.Int32))
+}
+
+func (t *Transport) base() http.RoundTripper {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var log *slog.Logger
+	if t.Logger != nil {
+		log = t.Logger()
+	}
+	ctx := req.Context()
+	if log == nil || !log.Enabled(ctx, t.Level) {
+		return t.base().RoundTrip(req)
+	}
+
+	attrs := []slog.Attr{
+		slog.String("provider", t.Provider),
+		slog.String("method", req.Method),
+		slog.String("url", RedactURL(req.URL)),
+	}
+	if n, ok := ctx.Value(attemptKey{}).(*atomic.Int32); ok && t.Attempts {
+		attrs = append(attrs, slog.Int("attempt", int(n.Add(1))))
+	}
+	prefix := len(attrs)
+	if t.MaxBody > 0 && req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		attrs = append(attrs, slog.String("body", Summarize(body, t.MaxBody)))
+	}
+	log.LogAttrs(ctx, t.Level, "http request", attrs...)
+
+	start := time.Now()
+	res, err := t.base().RoundTrip(req)
+	attrs = attrs[:prefix]
+	attrs = append(attrs, slog.Duration("duration", time.Since(start)))
+	if err != nil {
+		log.LogAttrs(ctx, t.Level, "http error", append(attrs, slog.String("error", err.Error()))...)
+		return res, err
+	}
+	attrs = append(attrs, slog.Int("status", res.StatusCode))
+	if id := requestID(res.Header); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	if t.MaxBody > 0 && res.Body != nil {
+		// Log the body when the SDK finishes reading it, so streamed
+		// responses aren't buffered.
+		res.Body = &bodyLogger{ReadCloser: res.Body, log: log, level: t.Level, attrs: attrs, max: t.MaxBody, req: req}
+		return res, nil
+	}
+	log.LogAttrs(ctx, t.Level, "http response", attrs...)
+	return res, nil
+}
+
+type bodyLogger struct {
+	io.ReadCloser
+	log    *slog.Logger
+	level  slog.Level
+	attrs  []slog.Attr
+	max    int
+	req    *http.Request
+	buf    bytes.Buffer
+	logged bool
+}
+
+func (b *bodyLogger) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := b.max*4 - b.buf.Len(); room > 0 {
+		// Keep more than max so base64 elision sees whole runs.
+		b.buf.Write(p[:min(n, room)])
+	}
+	if err == io.EOF {
+		b.flush()
+	}
+	return n, err
+}
+
+func (b *bodyLogger) Close() error {
+	b.flush()
+	return b.ReadCloser.Close()
+}
+
+func (b *bodyLogger) flush() {
+	if b.logged {
+		return
+	}
+	b.logged = true
+	b.log.LogAttrs(b.req.Context(), b.level, "http response", append(b.attrs, slog.String("body", Summarize(b.buf.Bytes(), b.max)))...)
+}
+
+var (
+	sensitiveParams = []string{"key", "api_key", "access_token"}
+	base64Run       = regexp.MustCompile(`[A-Za-z0-9+/=_-]{200,}`)
+	secretField     = regexp.MustCompile(`("(?:key|api_?key|access_token|authorization)"\s*:\s*)"[^"]*"`)
+)
+
+// RedactURL renders u with credential query parameters replaced.
+func RedactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	q := u.Query()
+	changed := false
+	for _, k := range sensitiveParams {
+		if q.Has(k) {
+			q.Set(k, "REDACTED")
+			changed = true
+		}
+	}
+	if !changed {
+		return u.String()
+	}
+	r := *u
+	r.RawQuery = q.Encode()
+	return r.String()
+}
+
+// Summarize renders a body for logging: long base64 runs (inline images and
+// PDFs) are elided, secret-looking JSON fields are redacted, and the result is
+// truncated to max bytes.
+func Summarize(body []byte, max int) string {
+	s := base64Run.ReplaceAllStringFunc(string(body), func(m string) string {
+		return "<" + strconv.Itoa(len(m)) + " bytes elided>"
+	})
+	s = secretField.ReplaceAllString(s, `$1"REDACTED"`)
+	if len(s) > max {
+		s = s[:max] + "…(truncated)"
+	}
+	return strings.TrimSpace(s)
+}
+
+func requestID(h http.Header) string {
+	for _, k := range []string{"X-Request-Id", "X-Goog-Request-Id"} {
+		if v := h.Get(k); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package httplog_test
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail/internal/httplog"
+)
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), "hello") {
+			t.Errorf("request body not forwarded: %q", body)
+		}
+		w.Header().Set("X-Request-Id", "req-1")
+		io.WriteString(w, `{"ok":true}`)
+	}))
+	defer srv.Close()
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	hc := httplog.Wrap(nil, &httplog.Transport{
+		Logger:   func() *slog.Logger { return logger },
+		Provider: "test",
+		Level:    slog.LevelDebug,
+		MaxBody:  httplog.DefaultMaxBody,
+	})
+
+	payload := `{"api_key":"secret","prompt":"hello","image":"` + strings.Repeat("A", 500) + `"}`
+	res, err := hc.Post(srv.URL+"/v1/generate?key=secret", "application/json", strings.NewReader(payload))
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	io.ReadAll(res.Body)
+	res.Body.Close()
+
+	out := logs.String()
+	for _, want := range []string{"http request", "http response", "provider=test", "status=200", "request_id=req-1", `\"ok\":true`, "500 bytes elided"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in logs:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "secret") {
+		t.Fatalf("credentials leaked into logs:\n%s", out)
+	}
+}
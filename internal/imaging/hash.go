@@ -0,0 +1,122 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/bits"
+	"sort"
+
+	"golang.org/x/image/draw"
+)
+
+// DHash computes a 64-bit difference hash of an encoded image: the image is
+// reduced to 9x8 grayscale and each bit records whether a pixel is brighter
+// than its right neighbour. Near-identical images have hashes a small Hamming
+// distance apart.
+func DHash(data []byte) (uint64, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("decode image: %w", err)
+	}
+	return dhash(img), nil
+}
+
+func dhash(img image.Image) uint64 {
+	g := grayscale(img, 9, 8)
+	var h uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			h <<= 1
+			if g.GrayAt(x, y).Y > g.GrayAt(x+1, y).Y {
+				h |= 1
+			}
+		}
+	}
+	return h
+}
+
+// PHash computes a 64-bit perceptual hash of an encoded image: the image is
+// reduced to 32x32 grayscale, transformed with a 2D DCT, and each bit records
+// whether one of the 8x8 lowest-frequency coefficients is above their median.
+// It is more robust than DHash to re-encoding, scaling, and small edits.
+func PHash(data []byte) (uint64, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("decode image: %w", err)
+	}
+	return phash(img), nil
+}
+
+// Hashes computes both DHash and PHash, decoding the image once.
+func Hashes(data []byte) (d, p uint64, err error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, fmt.Errorf("decode image: %w", err)
+	}
+	return dhash(img), phash(img), nil
+}
+
+func phash(img image.Image) uint64 {
+	const n = 32
+	g := grayscale(img, n, n)
+	var px [n][n]float64
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			px[y][x] = float64(g.GrayAt(x, y).Y)
+		}
+	}
+
+	// Only the 8x8 low-frequency corner of the DCT is needed.
+	var cos [8][n]float64
+	for u := 0; u < 8; u++ {
+		for x := 0; x < n; x++ {
+			cos[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * n))
+		}
+	}
+	var coef [64]float64
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			var sum float64
+			for y := 0; y < n; y++ {
+				for x := 0; x < n; x++ {
+					sum += px[y][x] * cos[u][x] * cos[v][y]
+				}
+			}
+			coef[v*8+u] = sum
+		}
+	}
+
+	// The DC term is the average brightness and would dominate the median.
+	sorted := append([]float64(nil), coef[1:]...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+	var h uint64
+	for _, c := range coef {
+		h <<= 1
+		if c > median {
+			h |= 1
+		}
+	}
+	return h
+}
+
+// Distance returns the Hamming distance between two hashes (0 to 64).
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// grayscale scales img to w x h and converts it to 8-bit gray.
+func grayscale(img image.Image, w, h int) *image.Gray {
+	scaled := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.BiLinear.Scale(scaled, scaled.Bounds(), img, img.Bounds(), draw.Src, nil)
+	g := image.NewGray(scaled.Bounds())
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			g.Set(x, y, color.GrayModel.Convert(scaled.At(x, y)))
+		}
+	}
+	return g
+}
@@ -0,0 +1,207 @@
+// Package imaging contains byte-level helpers for inspecting and rewriting
+// encoded images. It deliberately has no dependency on the grail package so
+// that both the core client and helper packages can share it.
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// FindC2PA returns the raw C2PA manifest store (a JUMBF box) embedded in a
+// PNG, JPEG, or WebP image. It returns false if no manifest is present or the
+// format is not recognized.
+func FindC2PA(data []byte) ([]byte, bool) {
+	switch {
+	case isPNG(data):
+		return findPNGChunk(data, "caBX")
+	case isJPEG(data):
+		return findJPEGJUMBF(data)
+	case isWebP(data):
+		return findRIFFChunk(data, "C2PA")
+	}
+	return nil, false
+}
+
+// StripC2PA returns a copy of data with any embedded C2PA manifest removed.
+// Images without a manifest (or in an unrecognized format) are returned as-is.
+func StripC2PA(data []byte) []byte {
+	switch {
+	case isPNG(data):
+		return filterPNGChunks(data, func(typ string) bool { return typ != "caBX" })
+	case isJPEG(data):
+		return filterJPEGSegments(data, func(marker byte, payload []byte) bool {
+			return !(marker == 0xEB && isC2PAJUMBF(payload))
+		})
+	case isWebP(data):
+		return filterRIFFChunks(data, func(fourCC string) bool { return fourCC != "C2PA" })
+	}
+	return data
+}
+
+func isPNG(data []byte) bool {
+	return bytes.HasPrefix(data, pngSignature)
+}
+
+func isJPEG(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0xFF && data[1] == 0xD8
+}
+
+func isWebP(data []byte) bool {
+	return len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP"
+}
+
+//
+// PNG
+//
+
+// walkPNG calls fn for each chunk with its type and the full chunk bytes
+// (length, type, data, and CRC). Walking stops at the first malformed chunk.
+func walkPNG(data []byte, fn func(typ string, chunk, body []byte)) {
+	off := len(pngSignature)
+	for off+12 <= len(data) {
+		n := int(binary.BigEndian.Uint32(data[off : off+4]))
+		end := off + 12 + n
+		if n < 0 || end > len(data) {
+			return
+		}
+		fn(string(data[off+4:off+8]), data[off:end], data[off+8:off+8+n])
+		off = end
+	}
+}
+
+func findPNGChunk(data []byte, typ string) ([]byte, bool) {
+	var found []byte
+	walkPNG(data, func(t string, _, body []byte) {
+		if found == nil && t == typ {
+			found = append([]byte(nil), body...)
+		}
+	})
+	return found, found != nil
+}
+
+func filterPNGChunks(data []byte, keep func(typ string) bool) []byte {
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+	walkPNG(data, func(t string, chunk, _ []byte) {
+		if keep(t) {
+			out = append(out, chunk...)
+		}
+	})
+	return out
+}
+
+//
+// JPEG
+//
+
+// walkJPEG calls fn for each marker segment that precedes the image data,
+// passing the marker byte, the full segment bytes, and the segment payload.
+// It returns the offset of the start-of-scan marker (or len(data) if none was
+// found) so callers can copy the entropy-coded remainder verbatim.
+func walkJPEG(data []byte, fn func(marker byte, segment, payload []byte)) int {
+	off := 2
+	for off+4 <= len(data) {
+		if data[off] != 0xFF {
+			return off
+		}
+		marker := data[off+1]
+		if marker == 0xDA || marker == 0xD9 {
+			return off
+		}
+		n := int(binary.BigEndian.Uint16(data[off+2 : off+4]))
+		end := off + 2 + n
+		if n < 2 || end > len(data) {
+			return off
+		}
+		fn(marker, data[off:end], data[off+4:end])
+		off = end
+	}
+	return len(data)
+}
+
+// isC2PAJUMBF reports whether an APP11 payload is a JPEG XT JUMBF packet that
+// carries a C2PA manifest store.
+func isC2PAJUMBF(payload []byte) bool {
+	return len(payload) >= 8 && string(payload[0:2]) == "JP" && bytes.Contains(payload, []byte("c2pa"))
+}
+
+func findJPEGJUMBF(data []byte) ([]byte, bool) {
+	// C2PA manifests larger than a single segment are split across APP11
+	// packets. Every packet starts with the JPEG XT header (CI, En, Z); packets
+	// after the first also repeat the box header (LBox, TBox), which is dropped
+	// when reassembling.
+	var store []byte
+	var instance []byte
+	walkJPEG(data, func(marker byte, _, payload []byte) {
+		if marker != 0xEB || len(payload) < 8 || string(payload[0:2]) != "JP" {
+			return
+		}
+		en := payload[2:4]
+		seq := binary.BigEndian.Uint32(payload[4:8])
+		switch {
+		case seq == 1 && instance == nil && bytes.Contains(payload, []byte("c2pa")):
+			instance = append([]byte(nil), en...)
+			store = append(store, payload[8:]...)
+		case instance != nil && bytes.Equal(en, instance) && seq > 1 && len(payload) >= 16:
+			store = append(store, payload[16:]...)
+		}
+	})
+	return store, store != nil
+}
+
+func filterJPEGSegments(data []byte, keep func(marker byte, payload []byte) bool) []byte {
+	out := make([]byte, 0, len(data))
+	out = append(out, data[0:2]...)
+	rest := walkJPEG(data, func(marker byte, segment, payload []byte) {
+		if keep(marker, payload) {
+			out = append(out, segment...)
+		}
+	})
+	return append(out, data[rest:]...)
+}
+
+//
+// WebP (RIFF)
+//
+
+func walkRIFF(data []byte, fn func(fourCC string, chunk, body []byte)) {
+	off := 12
+	for off+8 <= len(data) {
+		n := int(binary.LittleEndian.Uint32(data[off+4 : off+8]))
+		end := off + 8 + n
+		if n < 0 || end > len(data) {
+			return
+		}
+		padded := end
+		if n%2 == 1 && padded < len(data) {
+			padded++
+		}
+		fn(string(data[off:off+4]), data[off:padded], data[off+8:end])
+		off = padded
+	}
+}
+
+func findRIFFChunk(data []byte, fourCC string) ([]byte, bool) {
+	var found []byte
+	walkRIFF(data, func(cc string, _, body []byte) {
+		if found == nil && cc == fourCC {
+			found = append([]byte(nil), body...)
+		}
+	})
+	return found, found != nil
+}
+
+func filterRIFFChunks(data []byte, keep func(fourCC string) bool) []byte {
+	out := make([]byte, 12, len(data))
+	copy(out, data[0:12])
+	walkRIFF(data, func(cc string, chunk, _ []byte) {
+		if keep(cc) {
+			out = append(out, chunk...)
+		}
+	})
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out
+}
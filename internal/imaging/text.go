@@ -0,0 +1,84 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"strings"
+)
+
+// TextField is a keyword and value embedded in an image with AddText.
+type TextField struct {
+	Key   string
+	Value string
+}
+
+// AddText returns a copy of data with fields embedded as metadata: PNG iTXt
+// chunks placed before IEND, or for JPEG a single comment (COM) segment of
+// "Key: Value" lines after the JFIF header. It returns false, with data
+// unchanged, for other formats.
+func AddText(data []byte, fields []TextField) ([]byte, bool) {
+	switch {
+	case isPNG(data):
+		var chunks []byte
+		for _, f := range fields {
+			chunks = appendPNGChunk(chunks, "iTXt", iTXt(f))
+		}
+		out := make([]byte, 0, len(data)+len(chunks))
+		out = append(out, pngSignature...)
+		walkPNG(data, func(t string, chunk, _ []byte) {
+			if t == "IEND" {
+				out = append(out, chunks...)
+			}
+			out = append(out, chunk...)
+		})
+		return out, true
+	case isJPEG(data):
+		lines := make([]string, len(fields))
+		for i, f := range fields {
+			lines[i] = f.Key + ": " + f.Value
+		}
+		comment := []byte(strings.Join(lines, "\n"))
+		if len(comment) > 0xFFFF-2 {
+			comment = comment[:0xFFFF-2]
+		}
+		segment := []byte{0xFF, 0xFE, 0, 0}
+		binary.BigEndian.PutUint16(segment[2:], uint16(len(comment)+2))
+		segment = append(segment, comment...)
+
+		out := make([]byte, 0, len(data)+len(segment))
+		out = append(out, data[0:2]...)
+		inserted := false
+		rest := walkJPEG(data, func(marker byte, seg, _ []byte) {
+			if !inserted && marker != 0xE0 {
+				out = append(out, segment...)
+				inserted = true
+			}
+			out = append(out, seg...)
+		})
+		if !inserted {
+			out = append(out, segment...)
+		}
+		return append(out, data[rest:]...), true
+	}
+	return data, false
+}
+
+// iTXt encodes an uncompressed international text chunk body. Keywords are
+// limited to 79 bytes by the PNG spec.
+func iTXt(f TextField) []byte {
+	key := f.Key
+	if len(key) > 79 {
+		key = key[:79]
+	}
+	body := append([]byte(key), 0, 0, 0) // separator, no compression, method 0
+	body = append(body, 0, 0)            // empty language tag and translated keyword
+	return append(body, f.Value...)
+}
+
+func appendPNGChunk(out []byte, typ string, body []byte) []byte {
+	out = binary.BigEndian.AppendUint32(out, uint32(len(body)))
+	start := len(out)
+	out = append(out, typ...)
+	out = append(out, body...)
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(out[start:]))
+}
@@ -0,0 +1,182 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // register WebP decoding
+)
+
+// StripMetadata returns a copy of data without ancillary metadata (EXIF, XMP,
+// IPTC, text comments, and C2PA manifests). Color-management information such
+// as ICC profiles is kept so the image renders identically. Pixel data is not
+// re-encoded. Unrecognized formats are returned unchanged.
+func StripMetadata(data []byte) []byte {
+	switch {
+	case isPNG(data):
+		return filterPNGChunks(data, func(typ string) bool {
+			switch typ {
+			case "tEXt", "zTXt", "iTXt", "eXIf", "tIME", "caBX":
+				return false
+			}
+			return true
+		})
+	case isJPEG(data):
+		return filterJPEGSegments(data, func(marker byte, _ []byte) bool {
+			switch {
+			case marker == 0xE0, marker == 0xE2, marker == 0xEE:
+				// JFIF, ICC profile, and Adobe color transform.
+				return true
+			case marker >= 0xE1 && marker <= 0xEF, marker == 0xFE:
+				return false
+			}
+			return true
+		})
+	case isWebP(data):
+		out := filterRIFFChunks(data, func(fourCC string) bool {
+			return fourCC != "EXIF" && fourCC != "XMP " && fourCC != "C2PA"
+		})
+		// Clear the EXIF and XMP presence flags in the extended header.
+		if len(out) >= 21 && string(out[12:16]) == "VP8X" {
+			out[20] &^= 0x0C
+		}
+		return out
+	}
+	return data
+}
+
+// Options controls Transform.
+type Options struct {
+	Format    string // "png", "jpeg", or "gif"; empty keeps the source format
+	Quality   int    // JPEG quality (1-100); 0 uses the encoder default
+	MaxWidth  int    // 0 means unbounded
+	MaxHeight int    // 0 means unbounded
+}
+
+// Transform decodes data, downscales it to fit within the configured bounds
+// (preserving aspect ratio), and re-encodes it. It returns the new bytes and
+// MIME type. Images already within bounds and in the requested format are
+// returned unchanged. WebP sources that need re-encoding are written as PNG.
+func Transform(data []byte, opts Options) ([]byte, string, error) {
+	img, srcFormat, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("decode image: %w", err)
+	}
+
+	format := opts.Format
+	if format == "" {
+		format = srcFormat
+	}
+	if format == "jpg" {
+		format = "jpeg"
+	}
+
+	resized := fit(img, opts.MaxWidth, opts.MaxHeight)
+	if resized == img && format == srcFormat {
+		return data, MIMEType(format), nil
+	}
+	if format == "webp" {
+		// There is no WebP encoder in the standard library; fall back to PNG.
+		format = "png"
+	}
+
+	out, err := encode(resized, format, opts.Quality)
+	if err != nil {
+		return nil, "", err
+	}
+	return out, MIMEType(format), nil
+}
+
+// MIMEType returns the MIME type for an image format name.
+func MIMEType(format string) string {
+	switch format {
+	case "jpeg", "jpg":
+		return "image/jpeg"
+	case "gif":
+		return "image/gif"
+	case "webp":
+		return "image/webp"
+	default:
+		return "image/png"
+	}
+}
+
+// fit scales img down to fit within maxW x maxH. It returns img itself when no
+// scaling is needed.
+func fit(img image.Image, maxW, maxH int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return img
+	}
+	scale := 1.0
+	if maxW > 0 && w > maxW {
+		scale = float64(maxW) / float64(w)
+	}
+	if maxH > 0 && h > maxH {
+		if s := float64(maxH) / float64(h); s < scale {
+			scale = s
+		}
+	}
+	if scale >= 1 {
+		return img
+	}
+	nw := max(1, int(float64(w)*scale+0.5))
+	nh := max(1, int(float64(h)*scale+0.5))
+	dst := image.NewRGBA(image.Rect(0, 0, nw, nh))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+func encode(img image.Image, format string, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		o := &jpeg.Options{Quality: jpeg.DefaultQuality}
+		if quality > 0 && quality <= 100 {
+			o.Quality = quality
+		}
+		err = jpeg.Encode(&buf, img, o)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		return nil, fmt.Errorf("unsupported output format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encode %s: %w", format, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Blur returns data heavily blurred so its content is unrecognizable, at the
+// original size and in the original format (PNG for WebP). The image is
+// reduced to about 16 pixels across and scaled back up.
+func Blur(data []byte) ([]byte, string, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("decode image: %w", err)
+	}
+	b := img.Bounds()
+	scale := max(1, max(b.Dx(), b.Dy())/16)
+	small := image.NewRGBA(image.Rect(0, 0, max(1, b.Dx()/scale), max(1, b.Dy()/scale)))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), img, b, draw.Src, nil)
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.BiLinear.Scale(out, out.Bounds(), small, small.Bounds(), draw.Src, nil)
+
+	if format == "webp" {
+		format = "png"
+	}
+	encoded, err := encode(out, format, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	return encoded, MIMEType(format), nil
+}
@@ -0,0 +1,197 @@
+// Package jsonschema validates decoded JSON values against the subset of JSON
+// Schema that grail's structured outputs use: type, properties, required,
+// items, enum, pattern, and the date, date-time, and time formats. Unknown
+// keywords and formats are ignored, so schemas written for providers'
+// structured output features validate without changes.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Normalize converts a schema given as any Go value (a map, a struct with
+// JSON tags, or raw JSON bytes) into its generic decoded form, so nested
+// values are always map[string]any and []any.
+func Normalize(schema any) (map[string]any, error) {
+	switch s := schema.(type) {
+	case nil:
+		return nil, nil
+	case json.RawMessage:
+		return decode(s)
+	case []byte:
+		return decode(s)
+	case string:
+		return decode([]byte(s))
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("encode schema: %w", err)
+	}
+	return decode(data)
+}
+
+func decode(data []byte) (map[string]any, error) {
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("decode schema: %w", err)
+	}
+	return m, nil
+}
+
+// Validate checks data, a JSON document, against schema.
+func Validate(schema any, data []byte) error {
+	s, err := Normalize(schema)
+	if err != nil {
+		return err
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return validate(s, v, "$")
+}
+
+func validate(s map[string]any, v any, path string) error {
+	if s == nil {
+		return nil
+	}
+	if enum, ok := s["enum"].([]any); ok && !inEnum(enum, v) {
+		return fmt.Errorf("%s: value %v is not one of %v", path, v, enum)
+	}
+	if t, ok := s["type"]; ok && !matchesType(t, v) {
+		return fmt.Errorf("%s: expected %v, got %s", path, t, typeName(v))
+	}
+	switch val := v.(type) {
+	case string:
+		if pattern, ok := s["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("%s: invalid pattern %q: %w", path, pattern, err)
+			}
+			if !re.MatchString(val) {
+				return fmt.Errorf("%s: %q does not match pattern %q", path, val, pattern)
+			}
+		}
+		if format, ok := s["format"].(string); ok && !matchesFormat(format, val) {
+			return fmt.Errorf("%s: %q is not a valid %s", path, val, format)
+		}
+	case map[string]any:
+		if req, ok := s["required"].([]any); ok {
+			for _, r := range req {
+				if name, ok := r.(string); ok {
+					if _, present := val[name]; !present {
+						return fmt.Errorf("%s: missing required property %q", path, name)
+					}
+				}
+			}
+		}
+		if props, ok := s["properties"].(map[string]any); ok {
+			for name, ps := range props {
+				sub, ok := ps.(map[string]any)
+				if pv, present := val[name]; ok && present {
+					if err := validate(sub, pv, path+"."+name); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	case []any:
+		if items, ok := s["items"].(map[string]any); ok {
+			for i, item := range val {
+				if err := validate(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func matchesType(t any, v any) bool {
+	switch tt := t.(type) {
+	case string:
+		return matchesTypeName(tt, v)
+	case []any:
+		for _, name := range tt {
+			if s, ok := name.(string); ok && matchesTypeName(s, v) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func matchesTypeName(name string, v any) bool {
+	switch strings.ToLower(name) {
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "null":
+		return v == nil
+	}
+	return true
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// timeFormats are the layouts of the string formats checked, from RFC 3339.
+var timeFormats = map[string]string{
+	"date":      time.DateOnly,
+	"date-time": time.RFC3339,
+	"time":      "15:04:05Z07:00",
+}
+
+func matchesFormat(format, v string) bool {
+	layout, ok := timeFormats[format]
+	if !ok {
+		return true
+	}
+	_, err := time.Parse(layout, v)
+	return err == nil
+}
+
+func inEnum(enum []any, v any) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(v) && typeName(e) == typeName(v) {
+			return true
+		}
+	}
+	return false
+}
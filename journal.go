@@ -0,0 +1,260 @@
+package grail
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+//
+// Batch journals
+//
+
+// Journal is a write-ahead log for GenerateBatch. Each request is recorded
+// before it's dispatched and again, with its response, when it completes, so
+// a batch job that crashes can be run again with the same journal and pick
+// up where it left off: completed requests are answered from the journal
+// instead of being generated again. Failed and interrupted requests are
+// retried.
+//
+// Requests are matched by their position in the batch and a hash of their
+// content, so editing a request invalidates its journaled result. A journal
+// is a JSON Lines file and belongs to one batch.
+//
+// Journals hold prompts and generated content in plaintext unless opened with
+// WithJournalEncryption.
+type Journal struct {
+	mu   sync.Mutex
+	f    *os.File
+	keys KeyProvider
+	aad  []byte // binds sealed records to the journal's path
+	done map[int]journalRecord
+}
+
+// JournalOption configures OpenJournal.
+type JournalOption interface{ applyJournalOpt(*journalOpt) }
+
+type journalOpt struct {
+	keys KeyProvider
+}
+
+type journalOptFunc func(*journalOpt)
+
+func (f journalOptFunc) applyJournalOpt(jo *journalOpt) { f(jo) }
+
+// WithJournalEncryption encrypts journal records with AES-GCM under keys (see
+// Seal), so prompts and responses aren't written to disk in plaintext. Each
+// record is sealed whole, request hash included, and bound to the journal's
+// absolute path, so records can't be moved between journals. An encrypted
+// journal must be opened at the same path with the keys it was written with,
+// and opening a journal with keys fails if it holds plaintext records.
+func WithJournalEncryption(keys KeyProvider) JournalOption {
+	return journalOptFunc(func(jo *journalOpt) {
+		jo.keys = keys
+	})
+}
+
+type journalRecord struct {
+	Op       string          `json:"op"` // "submit" or "done"
+	Index    int             `json:"index"`
+	Hash     string          `json:"hash"`
+	Time     time.Time       `json:"time"`
+	Response *ResponseRecord `json:"response,omitempty"`
+}
+
+// OpenJournal opens the journal at path, creating it if needed, and loads
+// the requests it records as completed. A partly written last line, as left
+// by a crash, is ignored.
+func OpenJournal(path string, opts ...JournalOption) (*Journal, error) {
+	var jo journalOpt
+	for _, opt := range opts {
+		opt.applyJournalOpt(&jo)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, NewGrailError(Internal, fmt.Sprintf("open journal: %v", err)).WithCause(err)
+	}
+	j := &Journal{f: f, keys: jo.keys, aad: journalAAD(path), done: map[int]journalRecord{}}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<30)
+	for sc.Scan() {
+		line := sc.Bytes()
+		var sealed sealedRecord
+		switch {
+		case json.Unmarshal(line, &sealed) == nil && sealed.Sealed != nil:
+			if j.keys == nil {
+				f.Close()
+				return nil, NewGrailError(InvalidArgument, "journal is encrypted; open it with WithJournalEncryption")
+			}
+			if line, err = Open(j.keys, sealed.Sealed, j.aad); err != nil {
+				f.Close()
+				return nil, NewGrailError(InvalidArgument, fmt.Sprintf("read journal: %v", err)).WithCause(err)
+			}
+		case j.keys != nil && json.Valid(line):
+			f.Close()
+			return nil, NewGrailError(InvalidArgument, "journal holds unencrypted records; it can't be opened with WithJournalEncryption")
+		}
+		var rec journalRecord
+		if json.Unmarshal(line, &rec) != nil {
+			continue
+		}
+		if rec.Op == "done" && rec.Response != nil {
+			j.done[rec.Index] = rec
+		}
+	}
+	if err := sc.Err(); err != nil {
+		f.Close()
+		return nil, NewGrailError(Internal, fmt.Sprintf("read journal: %v", err)).WithCause(err)
+	}
+	// Terminate a partial last line so the next record starts on its own.
+	if fi, err := f.Stat(); err == nil && fi.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, fi.Size()-1); err == nil && last[0] != '\n' {
+			if _, err := f.Write([]byte{'\n'}); err != nil {
+				f.Close()
+				return nil, NewGrailError(Internal, fmt.Sprintf("write journal: %v", err)).WithCause(err)
+			}
+		}
+	}
+	return j, nil
+}
+
+// journalAAD is the additional data sealed journal records are bound to: the
+// journal's absolute path.
+func journalAAD(path string) []byte {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return []byte("grail/journal\x00" + path)
+}
+
+// Completed returns the number of requests the journal records as completed.
+func (j *Journal) Completed() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.done)
+}
+
+// Close closes the journal file.
+func (j *Journal) Close() error {
+	return j.f.Close()
+}
+
+// lookup returns the journaled response for request i, if it completed with
+// the same content.
+func (j *Journal) lookup(i int, hash string) (Response, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	rec, ok := j.done[i]
+	if !ok || rec.Hash != hash {
+		return Response{}, false
+	}
+	return rec.Response.Response(), true
+}
+
+func (j *Journal) submit(i int, hash string) error {
+	return j.write(journalRecord{Op: "submit", Index: i, Hash: hash})
+}
+
+func (j *Journal) complete(i int, hash string, res Response) error {
+	rr := NewResponseRecord(res)
+	rec := journalRecord{Op: "done", Index: i, Hash: hash, Response: &rr}
+	if err := j.write(rec); err != nil {
+		return err
+	}
+	j.mu.Lock()
+	j.done[i] = rec
+	j.mu.Unlock()
+	return nil
+}
+
+// write appends rec and syncs it to disk before returning.
+func (j *Journal) write(rec journalRecord) error {
+	rec.Time = time.Now().UTC()
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return NewGrailError(Internal, fmt.Sprintf("encode journal record: %v", err)).WithCause(err)
+	}
+	if j.keys != nil {
+		sealed, err := Seal(j.keys, line, j.aad)
+		if err != nil {
+			return err
+		}
+		if line, err = json.Marshal(sealedRecord{Sealed: sealed}); err != nil {
+			return NewGrailError(Internal, fmt.Sprintf("encode journal record: %v", err)).WithCause(err)
+		}
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.f.Write(append(line, '\n')); err != nil {
+		return NewGrailError(Internal, fmt.Sprintf("write journal: %v", err)).WithCause(err)
+	}
+	if err := j.f.Sync(); err != nil {
+		return NewGrailError(Internal, fmt.Sprintf("sync journal: %v", err)).WithCause(err)
+	}
+	return nil
+}
+
+// requestHash identifies a request's content: its inputs, tools, output,
+// model selection, provider options, and metadata. Streamed file inputs can't be
+// read without consuming them, so they count by name, MIME type, and size.
+func requestHash(req Request) string {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	encodeInputs := func(inputs []Input) {
+		for _, in := range inputs {
+			switch v := in.(type) {
+			case textInput:
+				enc.Encode([]any{"text", v.Text})
+			case fileInput:
+				enc.Encode([]any{"file", AttachmentRef(v.Data), v.MIME, v.Name})
+			case fileReaderInput:
+				enc.Encode([]any{"reader", v.Name, v.MIME, v.Size})
+			case toolResultInput:
+				enc.Encode([]any{"tool_result", v.Call, v.Output})
+			}
+		}
+	}
+	for _, m := range req.History {
+		enc.Encode([]any{"message", m.Role})
+		encodeInputs(m.Inputs)
+	}
+	if len(req.History) > 0 {
+		enc.Encode([]any{"message", TurnUser})
+	}
+	encodeInputs(req.Inputs)
+	for _, t := range req.Tools {
+		enc.Encode([]any{"tool", t.Name, t.Description, t.Parameters})
+	}
+	enc.Encode([]any{"output", fmt.Sprintf("%T", req.Output), req.Output})
+	enc.Encode([]any{"model", req.Model, req.Tier})
+	for _, opt := range req.ProviderOptions {
+		enc.Encode([]any{"option", fmt.Sprintf("%T", opt), opt})
+	}
+	keys := make([]string, 0, len(req.Metadata))
+	for k := range req.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		enc.Encode([]any{"metadata", k, req.Metadata[k]})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// journalHashes hashes every request in a batch up front, before any of them
+// run and consume streamed inputs.
+func journalHashes(reqs []Request) []string {
+	hashes := make([]string, len(reqs))
+	for i, req := range reqs {
+		hashes[i] = requestHash(req)
+	}
+	return hashes
+}
@@ -0,0 +1,87 @@
+package grail_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestGenerateBatchJournal(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls []string
+		down  = true
+	)
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			text, _ := grail.AsTextInput(req.Inputs[0])
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, text)
+			if text == "c" && down {
+				return grail.Response{}, grail.NewGrailError(grail.Unavailable, "down")
+			}
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("re: " + text)}, RequestID: "req-" + text}, nil
+		},
+	}
+	client := grail.NewClient(prov)
+	newReqs := func(prompts ...string) []grail.Request {
+		reqs := make([]grail.Request, len(prompts))
+		for i, p := range prompts {
+			reqs[i] = grail.Request{Inputs: []grail.Input{grail.InputText(p)}, Output: grail.OutputText()}
+		}
+		return reqs
+	}
+	path := filepath.Join(t.TempDir(), "batch.journal")
+	run := func(reqs []grail.Request) []grail.BatchResult {
+		t.Helper()
+		j, err := grail.OpenJournal(path)
+		if err != nil {
+			t.Fatalf("open journal: %v", err)
+		}
+		defer j.Close()
+		calls = nil
+		return grail.GenerateBatch(context.Background(), client, reqs, grail.BatchOptions{Concurrency: 1, Journal: j})
+	}
+
+	results := run(newReqs("a", "b", "c"))
+	if grail.GetErrorCode(results[2].Err) != grail.Unavailable || len(calls) != 3 {
+		t.Fatalf("expected the first run to call every request and fail c, got %v after %v", results[2].Err, calls)
+	}
+
+	// A crash mid-write leaves a partial line, which must not break resuming.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"done","index":0,"hash":`)
+	f.Close()
+
+	down = false
+	results = run(newReqs("a", "B", "c"))
+	if len(calls) != 2 || calls[0] != "B" || calls[1] != "c" {
+		t.Fatalf("expected only the edited and failed requests to run, got %v", calls)
+	}
+	for i, want := range []string{"re: a", "re: B", "re: c"} {
+		if text, _ := results[i].Response.Text(); results[i].Err != nil || text != want {
+			t.Fatalf("result %d: expected %q, got %q (%v)", i, want, text, results[i].Err)
+		}
+	}
+	if !results[0].Resumed || results[1].Resumed || results[0].Response.RequestID != "req-a" {
+		t.Fatalf("expected only the first result to be resumed, got %+v", results)
+	}
+
+	j, err := grail.OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if j.Completed() != 3 {
+		t.Fatalf("expected 3 completed requests, got %d", j.Completed())
+	}
+}
@@ -0,0 +1,131 @@
+package grail
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/montanaflynn/grail/internal/jsonschema"
+)
+
+//
+// JSON fallback for text-only models
+//
+
+// WarningJSONFallback is set on responses whose JSON was extracted from a text
+// response because the provider or model has no JSON output mode.
+const WarningJSONFallback = "json_fallback"
+
+// needsJSONFallback reports whether req asks for JSON from a provider or model
+// that can only produce text. Providers and models that don't describe their
+// capabilities are assumed to support JSON.
+func (c *client) needsJSONFallback(req Request) bool {
+	if _, ok := req.Output.(jsonOutput); !ok {
+		return false
+	}
+	if caps, ok := c.Capabilities(); ok && !caps.JSONOutput {
+		return caps.TextOutput
+	}
+	if req.Model == "" {
+		return false
+	}
+	model := c.lookupModel(req.Model)
+	return model != nil && !model.Capabilities.JSONOutput && model.Capabilities.TextGeneration
+}
+
+// jsonFallbackRequest rewrites a JSON request as a text request whose prompt
+// asks for the JSON, and the schema if there is one, explicitly.
+func jsonFallbackRequest(req Request, out jsonOutput) Request {
+	var b strings.Builder
+	b.WriteString("Respond only with a single JSON value inside a ```json code block, with no other text.")
+	if out.Schema != nil {
+		if schema, err := json.MarshalIndent(out.Schema, "", "  "); err == nil {
+			b.WriteString(" The JSON must conform to this JSON Schema:\n\n")
+			b.Write(schema)
+		}
+	}
+	req.Inputs = append(append([]Input(nil), req.Inputs...), InputText(b.String()))
+	req.Output = OutputText()
+	return req
+}
+
+// finishJSONFallback replaces the text output of a fallback response with the
+// JSON it contains. In strict mode the JSON must also satisfy the schema.
+func finishJSONFallback(res *Response, out jsonOutput) error {
+	text, _ := res.Text()
+	data, ok := ExtractJSON(text)
+	if !ok {
+		return NewGrailError(OutputInvalid, "no JSON found in text response").WithProviderName(res.Provider.Name)
+	}
+	msg := "JSON extracted from a text response; the model has no JSON output mode"
+	if out.Schema != nil {
+		if err := jsonschema.Validate(out.Schema, data); err != nil {
+			if out.Strict {
+				return NewGrailError(OutputInvalid, fmt.Sprintf("extracted JSON does not match schema: %v", err)).WithCause(err).WithProviderName(res.Provider.Name)
+			}
+			msg += fmt.Sprintf(" (schema mismatch: %v)", err)
+		}
+	}
+
+	outputs := make([]OutputPart, 0, len(res.Outputs))
+	outputs = append(outputs, NewJSONOutputPart(data))
+	for _, p := range res.Outputs {
+		if _, isText := p.(textOutputPart); !isText {
+			outputs = append(outputs, p)
+		}
+	}
+	res.Outputs = outputs
+	res.Warnings = append(res.Warnings, Warning{Code: WarningJSONFallback, Message: msg})
+	return nil
+}
+
+// ExtractJSON returns the first JSON object or array in text. Fenced code
+// blocks are preferred; otherwise the first balanced {...} or [...] that parses
+// is returned.
+func ExtractJSON(text string) ([]byte, bool) {
+	for _, b := range CodeBlocks(text) {
+		if block := strings.TrimSpace(b.Code); json.Valid([]byte(block)) {
+			return []byte(block), true
+		}
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] != '{' && text[i] != '[' {
+			continue
+		}
+		if end := matchingBracket(text, i); end > 0 && json.Valid([]byte(text[i:end])) {
+			return []byte(text[i:end]), true
+		}
+	}
+	return nil, false
+}
+
+// matchingBracket returns the index just past the bracket closing the one at
+// start, skipping brackets inside strings, or -1 if it isn't closed.
+func matchingBracket(text string, start int) int {
+	depth := 0
+	inString, escaped := false, false
+	for i := start; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+		case ch == '"':
+			inString = true
+		case ch == '{' || ch == '[':
+			depth++
+		case ch == '}' || ch == ']':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
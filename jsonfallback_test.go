@@ -0,0 +1,86 @@
+package grail_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+type textOnlyProvider struct{ *mock.Provider }
+
+func (textOnlyProvider) Capabilities() grail.ProviderCapabilities {
+	return grail.ProviderCapabilities{TextOutput: true}
+}
+
+func TestJSONFallback(t *testing.T) {
+	schema := map[string]any{
+		"type":     "object",
+		"required": []string{"name"},
+		"properties": map[string]any{
+			"name": map[string]any{"type": "string"},
+		},
+	}
+	reply := "Sure! Here it is:\n```json\n{\"name\": \"grail\"}\n```\nAnything else?"
+	prov := textOnlyProvider{&mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			if !grail.IsTextOutput(req.Output) {
+				t.Fatalf("expected fallback to request text output")
+			}
+			last, _ := grail.AsTextInput(req.Inputs[len(req.Inputs)-1])
+			if !strings.Contains(last, "JSON Schema") || !strings.Contains(last, `"required"`) {
+				t.Fatalf("expected schema instructions, got %q", last)
+			}
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart(reply)}}, nil
+		},
+	}}
+	client := grail.NewClient(prov)
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("name this library")}, Output: grail.OutputJSON(schema)}
+
+	res, err := client.Generate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got struct{ Name string }
+	if err := res.DecodeJSON(&got); err != nil || got.Name != "grail" {
+		t.Fatalf("expected extracted JSON, got %+v (%v)", got, err)
+	}
+	if len(res.Warnings) != 1 || res.Warnings[0].Code != grail.WarningJSONFallback {
+		t.Fatalf("expected json_fallback warning, got %+v", res.Warnings)
+	}
+
+	reply = `{"title": "grail"}`
+	if _, err := client.Generate(context.Background(), req); grail.GetErrorCode(err) != grail.OutputInvalid {
+		t.Fatalf("expected output_invalid for schema mismatch, got %v", err)
+	}
+	res, err = client.Generate(context.Background(), grail.Request{Inputs: req.Inputs, Output: grail.OutputJSON(schema, grail.WithStrictJSON(false))})
+	if err != nil || len(res.Warnings) != 1 {
+		t.Fatalf("expected non-strict mismatch to warn, got %+v (%v)", res.Warnings, err)
+	}
+
+	reply = "I can't do that."
+	if _, err := client.Generate(context.Background(), req); grail.GetErrorCode(err) != grail.OutputInvalid {
+		t.Fatalf("expected output_invalid without JSON, got %v", err)
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"```\n[1, 2]\n```", "[1, 2]"},
+		{`The answer is {"a": "}"} and {"b": 2}`, `{"a": "}"}`},
+		{"see [note] then {\"ok\": true}", `{"ok": true}`},
+	}
+	for _, tt := range tests {
+		got, ok := grail.ExtractJSON(tt.text)
+		if !ok || string(got) != tt.want {
+			t.Errorf("ExtractJSON(%q) = %q, %v; want %q", tt.text, got, ok, tt.want)
+		}
+	}
+	if _, ok := grail.ExtractJSON("no json here"); ok {
+		t.Errorf("expected no JSON")
+	}
+}
@@ -0,0 +1,273 @@
+package grail
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//
+// Lexicon filtering
+//
+
+// LexiconAction is what happens to output that matches a lexicon.
+type LexiconAction int
+
+const (
+	// LexiconWarn returns the output unchanged with a warning.
+	LexiconWarn LexiconAction = iota
+	// LexiconMask replaces every letter and digit of each match with the
+	// lexicon's mask character.
+	LexiconMask
+	// LexiconBlock fails the request with Refused.
+	LexiconBlock
+)
+
+// Warning codes set by lexicon filters. The message names the lexicon and the
+// matched terms.
+const (
+	WarningLexiconMatched = "lexicon_matched"
+	WarningLexiconMasked  = "lexicon_masked"
+)
+
+// Lexicon is a list of words or phrases to catch in output, such as
+// profanity or competitor brand names. Terms match case-insensitively and
+// only as whole words, so "ass" doesn't match "class".
+type Lexicon struct {
+	Name   string // used in warnings and errors
+	Terms  []string
+	Action LexiconAction
+	Mask   rune // for LexiconMask; defaults to '*'
+}
+
+// LexiconFilterConfig configures LexiconFilter.
+type LexiconFilterConfig struct {
+	// Lexicons apply to every request.
+	Lexicons []Lexicon
+	// Tenants adds lexicons for requests whose TenantKey metadata value
+	// matches a key, on top of Lexicons.
+	Tenants map[string][]Lexicon
+	// TenantKey is the metadata key that names the tenant. Defaults to
+	// "tenant".
+	TenantKey string
+}
+
+// LexiconFilter returns a post-processor that checks text outputs, and the
+// string values of JSON outputs, against lexicons. Lexicons apply in order:
+// the first blocking match fails the request, and masked terms are hidden from
+// later lexicons. It complements provider moderation for consumer-facing
+// applications that need their own word lists.
+func LexiconFilter(cfg LexiconFilterConfig) PostProcessor {
+	key := cfg.TenantKey
+	if key == "" {
+		key = "tenant"
+	}
+	compile := func(ls []Lexicon) []*lexiconMatcher {
+		ms := make([]*lexiconMatcher, 0, len(ls))
+		for _, l := range ls {
+			if m := newLexiconMatcher(l); m != nil {
+				ms = append(ms, m)
+			}
+		}
+		return ms
+	}
+	global := compile(cfg.Lexicons)
+	tenants := make(map[string][]*lexiconMatcher, len(cfg.Tenants))
+	for t, ls := range cfg.Tenants {
+		tenants[t] = append(global[:len(global):len(global)], compile(ls)...)
+	}
+	matchers := func(ctx context.Context) []*lexiconMatcher {
+		if ms, ok := tenants[MetadataFromContext(ctx)[key]]; ok {
+			return ms
+		}
+		return global
+	}
+
+	return PostProcessor{
+		Text: func(ctx context.Context, text string) (string, error) {
+			sc := lexiconScan{matchers: matchers(ctx)}
+			out, err := sc.filter(text)
+			if err != nil {
+				return text, err
+			}
+			sc.warn(ctx)
+			return out, nil
+		},
+		JSON: func(ctx context.Context, data []byte) ([]byte, error) {
+			sc := lexiconScan{matchers: matchers(ctx)}
+			if len(sc.matchers) == 0 {
+				return data, nil
+			}
+			dec := json.NewDecoder(bytes.NewReader(data))
+			dec.UseNumber()
+			var v any
+			if err := dec.Decode(&v); err != nil {
+				// Invalid JSON is left to the caller's decoding to report.
+				return data, nil
+			}
+			v, err := sc.filterJSON(v)
+			if err != nil {
+				return data, err
+			}
+			sc.warn(ctx)
+			if !sc.masked {
+				return data, nil
+			}
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			if err := enc.Encode(v); err != nil {
+				return data, err
+			}
+			return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+		},
+	}
+}
+
+type lexiconMatcher struct {
+	Lexicon
+	re *regexp.Regexp
+}
+
+func newLexiconMatcher(l Lexicon) *lexiconMatcher {
+	terms := make([]string, 0, len(l.Terms))
+	for _, t := range l.Terms {
+		if f := strings.Fields(t); len(f) > 0 {
+			for i := range f {
+				f[i] = regexp.QuoteMeta(f[i])
+			}
+			terms = append(terms, strings.Join(f, `\s+`))
+		}
+	}
+	if len(terms) == 0 {
+		return nil
+	}
+	// Longest first, so phrases win over the words they contain.
+	sort.SliceStable(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+	if l.Mask == 0 {
+		l.Mask = '*'
+	}
+	return &lexiconMatcher{Lexicon: l, re: regexp.MustCompile(`(?i)(?:` + strings.Join(terms, "|") + `)`)}
+}
+
+// find returns the whole-word matches in s.
+func (m *lexiconMatcher) find(s string) [][]int {
+	var out [][]int
+	for _, loc := range m.re.FindAllStringIndex(s, -1) {
+		first, _ := utf8.DecodeRuneInString(s[loc[0]:])
+		last, _ := utf8.DecodeLastRuneInString(s[:loc[1]])
+		before, _ := utf8.DecodeLastRuneInString(s[:loc[0]])
+		after, _ := utf8.DecodeRuneInString(s[loc[1]:])
+		if (loc[0] > 0 && isWordRune(before) && isWordRune(first)) ||
+			(loc[1] < len(s) && isWordRune(after) && isWordRune(last)) {
+			continue
+		}
+		out = append(out, loc)
+	}
+	return out
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// lexiconScan applies lexicons to the strings of one output part and
+// collects what matched.
+type lexiconScan struct {
+	matchers []*lexiconMatcher
+	hits     map[*lexiconMatcher]map[string]bool
+	masked   bool
+}
+
+func (sc *lexiconScan) filter(s string) (string, error) {
+	for _, m := range sc.matchers {
+		locs := m.find(s)
+		if len(locs) == 0 {
+			continue
+		}
+		if m.Action == LexiconBlock {
+			return s, NewGrailError(Refused, fmt.Sprintf("output blocked by lexicon %q", m.Name)).
+				WithDetail("lexicon", m.Name)
+		}
+		if sc.hits == nil {
+			sc.hits = map[*lexiconMatcher]map[string]bool{}
+		}
+		if sc.hits[m] == nil {
+			sc.hits[m] = map[string]bool{}
+		}
+		for _, loc := range locs {
+			sc.hits[m][strings.ToLower(s[loc[0]:loc[1]])] = true
+		}
+		if m.Action == LexiconMask {
+			s = maskMatches(s, locs, m.Mask)
+			sc.masked = true
+		}
+	}
+	return s, nil
+}
+
+// filterJSON filters the string values, not the keys, of a decoded JSON value.
+func (sc *lexiconScan) filterJSON(v any) (any, error) {
+	var err error
+	switch v := v.(type) {
+	case string:
+		return sc.filter(v)
+	case []any:
+		for i := range v {
+			if v[i], err = sc.filterJSON(v[i]); err != nil {
+				return v, err
+			}
+		}
+	case map[string]any:
+		for k := range v {
+			if v[k], err = sc.filterJSON(v[k]); err != nil {
+				return v, err
+			}
+		}
+	}
+	return v, nil
+}
+
+// warn adds a warning per lexicon that matched, in lexicon order.
+func (sc *lexiconScan) warn(ctx context.Context) {
+	for _, m := range sc.matchers {
+		hits := sc.hits[m]
+		if len(hits) == 0 {
+			continue
+		}
+		terms := make([]string, 0, len(hits))
+		for t := range hits {
+			terms = append(terms, fmt.Sprintf("%q", t))
+		}
+		sort.Strings(terms)
+		code := WarningLexiconMatched
+		if m.Action == LexiconMask {
+			code = WarningLexiconMasked
+		}
+		AddWarning(ctx, Warning{Code: code, Message: fmt.Sprintf("lexicon %q matched %s", m.Name, strings.Join(terms, ", "))})
+	}
+}
+
+// maskMatches replaces the letters and digits in s's locs with r.
+func maskMatches(s string, locs [][]int, r rune) string {
+	var b strings.Builder
+	prev := 0
+	for _, loc := range locs {
+		b.WriteString(s[prev:loc[0]])
+		for _, c := range s[loc[0]:loc[1]] {
+			if unicode.IsLetter(c) || unicode.IsDigit(c) {
+				c = r
+			}
+			b.WriteRune(c)
+		}
+		prev = loc[1]
+	}
+	b.WriteString(s[prev:])
+	return b.String()
+}
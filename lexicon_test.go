@@ -0,0 +1,61 @@
+package grail_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestLexiconFilter(t *testing.T) {
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			if _, _, ok := grail.GetJSONOutput(req.Output); ok {
+				return grail.Response{Outputs: []grail.OutputPart{grail.NewJSONOutputPart([]byte(`{"darn":"Darn <it>","n":1.50}`))}}, nil
+			}
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("Darn, this classic Acme  Widget beats the rest. darn!")}}, nil
+		},
+	}
+	client := grail.NewClient(prov, grail.WithPostProcessors(grail.LexiconFilter(grail.LexiconFilterConfig{
+		Lexicons: []grail.Lexicon{
+			{Name: "profanity", Terms: []string{"darn", "ass"}, Action: grail.LexiconMask},
+		},
+		Tenants: map[string][]grail.Lexicon{
+			"globex":  {{Name: "competitors", Terms: []string{"acme widget"}, Action: grail.LexiconBlock}},
+			"initech": {{Name: "brands", Terms: []string{"Acme"}}},
+		},
+	})))
+	text := grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()}
+	generate := func(tenant string, req grail.Request) (grail.Response, error) {
+		return client.Generate(grail.ContextWithMetadata(context.Background(), "tenant", tenant), req)
+	}
+
+	res, err := generate("", text)
+	if got, _ := res.Text(); err != nil || got != "****, this classic Acme  Widget beats the rest. ****!" {
+		t.Fatalf("expected masked profanity only, got %q (%v)", got, err)
+	}
+	if len(res.Warnings) != 1 || res.Warnings[0].Code != grail.WarningLexiconMasked || res.Warnings[0].Message != `lexicon "profanity" matched "darn"` {
+		t.Fatalf("unexpected warnings %+v", res.Warnings)
+	}
+
+	res, err = generate("initech", text)
+	if len(res.Warnings) != 2 || res.Warnings[1].Code != grail.WarningLexiconMatched || res.Warnings[1].Message != `lexicon "brands" matched "acme"` {
+		t.Fatalf("expected a brand warning, got %+v (%v)", res.Warnings, err)
+	}
+
+	_, err = generate("globex", text)
+	if grail.GetErrorCode(err) != grail.Refused {
+		t.Fatalf("expected Refused for a blocked phrase, got %v", err)
+	}
+
+	res, err = generate("", grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputJSON(nil)})
+	var v struct {
+		Darn string          `json:"darn"`
+		N    json.RawMessage `json:"n"`
+	}
+	if err != nil || res.DecodeJSON(&v) != nil || v.Darn != "**** <it>" || string(v.N) != "1.50" {
+		t.Fatalf("expected masked string values with keys and numbers intact, got %+v (%v)", v, err)
+	}
+}
@@ -0,0 +1,135 @@
+package grail
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+//
+// Request/response size guardrails
+//
+
+// SizeLimits caps the total size of a request and a response. Per-file limits
+// (MaxFileSize, MaxPDFSize) alone don't stop a request that carries many large
+// files.
+type SizeLimits struct {
+	// MaxRequestBytes caps the encoded size of all inputs combined: text as
+	// UTF-8 and files as base64, the way providers send them inline.
+	// Streamed inputs of unknown size are not counted. Zero means no limit.
+	MaxRequestBytes int64
+	// MaxResponseBytes caps the combined size of all output parts. Zero means
+	// no limit.
+	MaxResponseBytes int64
+	// WarnOnly logs and records a Warning on the response instead of failing.
+	WarnOnly bool
+}
+
+// Warning codes recorded when SizeLimits.WarnOnly is set.
+const (
+	WarningRequestTooLarge  = "request_too_large"
+	WarningResponseTooLarge = "response_too_large"
+)
+
+// WithSizeLimits enforces l on every Generate call. Oversized requests fail
+// with InvalidArgument before reaching the provider, unless dropping inputs
+// marked WithDroppable brings them under the limit; oversized responses fail
+// with OutputInvalid.
+func WithSizeLimits(l SizeLimits) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.sizeLimits = &l
+	})
+}
+
+// EncodedRequestSize returns the approximate number of bytes req's inputs and
+// history occupy on the wire, counting files as base64.
+func EncodedRequestSize(req Request) int64 {
+	n := inputsSize(req.Inputs)
+	for _, m := range req.History {
+		n += inputsSize(m.Inputs)
+	}
+	return n
+}
+
+func inputsSize(inputs []Input) int64 {
+	var n int64
+	for _, in := range inputs {
+		switch v := in.(type) {
+		case textInput:
+			n += int64(len(v.Text))
+		case fileInput:
+			n += base64Len(int64(len(v.Data)))
+		case fileReaderInput:
+			if v.Size > 0 {
+				n += base64Len(v.Size)
+			}
+		case toolResultInput:
+			n += int64(len(v.Call.Arguments) + len(v.Output))
+		}
+	}
+	return n
+}
+
+// ResponseSize returns the combined size of res's output parts in bytes.
+func ResponseSize(res Response) int64 {
+	var n int64
+	for _, out := range res.Outputs {
+		switch v := out.(type) {
+		case textOutputPart:
+			n += int64(len(v.Text))
+		case imageOutputPart:
+			n += int64(len(v.Data))
+		case videoOutputPart:
+			n += int64(len(v.Data))
+		case jsonOutputPart:
+			n += int64(len(v.JSON))
+		case toolCallOutputPart:
+			n += int64(len(v.Call.Arguments))
+		}
+	}
+	return n
+}
+
+func base64Len(n int64) int64 { return (n + 2) / 3 * 4 }
+
+// checkRequestSize returns an error, or a warning when l.WarnOnly is set, if
+// req exceeds l. Otherwise, droppable inputs are dropped until req fits.
+func (c *client) checkRequestSize(req Request, l SizeLimits) (Request, []Warning, error) {
+	if l.MaxRequestBytes <= 0 {
+		return req, nil, nil
+	}
+	size := EncodedRequestSize(req)
+	if size <= l.MaxRequestBytes {
+		return req, nil, nil
+	}
+	msg := fmt.Sprintf("request size %d bytes exceeds limit of %d bytes", size, l.MaxRequestBytes)
+	if !l.WarnOnly {
+		fits := func(r Request) bool { return EncodedRequestSize(r) <= l.MaxRequestBytes }
+		if trimmed, warnings, ok := trimInputs(req, fmt.Sprintf("request size exceeds limit of %d bytes", l.MaxRequestBytes), fits); ok {
+			return trimmed, warnings, nil
+		}
+		return req, nil, NewGrailError(InvalidArgument, msg)
+	}
+	if c.log != nil {
+		c.log.Warn(msg, slog.Int64("size", size), slog.Int64("limit", l.MaxRequestBytes))
+	}
+	return req, []Warning{{Code: WarningRequestTooLarge, Message: msg}}, nil
+}
+
+func (c *client) checkResponseSize(res *Response, l SizeLimits) error {
+	if l.MaxResponseBytes <= 0 {
+		return nil
+	}
+	size := ResponseSize(*res)
+	if size <= l.MaxResponseBytes {
+		return nil
+	}
+	msg := fmt.Sprintf("response size %d bytes exceeds limit of %d bytes", size, l.MaxResponseBytes)
+	if !l.WarnOnly {
+		return NewGrailError(OutputInvalid, msg).WithProviderName(res.Provider.Name).WithRequestID(res.RequestID)
+	}
+	if c.log != nil {
+		c.log.Warn(msg, slog.Int64("size", size), slog.Int64("limit", l.MaxResponseBytes))
+	}
+	res.Warnings = append(res.Warnings, Warning{Code: WarningResponseTooLarge, Message: msg})
+	return nil
+}
@@ -0,0 +1,56 @@
+package grail_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestSizeLimits(t *testing.T) {
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart(strings.Repeat("x", 100))}}, nil
+		},
+	}
+	pdf := grail.InputPDF(make([]byte, 30)) // 40 bytes as base64
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("0123456789"), pdf}, Output: grail.OutputText()}
+
+	if got := grail.EncodedRequestSize(req); got != 50 {
+		t.Fatalf("expected encoded size 50, got %d", got)
+	}
+
+	t.Run("request rejected", func(t *testing.T) {
+		client := grail.NewClient(prov, grail.WithSizeLimits(grail.SizeLimits{MaxRequestBytes: 49}))
+		if _, err := client.Generate(context.Background(), req); grail.GetErrorCode(err) != grail.InvalidArgument {
+			t.Fatalf("expected invalid_argument, got %v", err)
+		}
+	})
+
+	t.Run("response rejected", func(t *testing.T) {
+		client := grail.NewClient(prov, grail.WithSizeLimits(grail.SizeLimits{MaxRequestBytes: 50, MaxResponseBytes: 99}))
+		if _, err := client.Generate(context.Background(), req); grail.GetErrorCode(err) != grail.OutputInvalid {
+			t.Fatalf("expected output_invalid, got %v", err)
+		}
+	})
+
+	t.Run("warn only", func(t *testing.T) {
+		client := grail.NewClient(prov, grail.WithSizeLimits(grail.SizeLimits{MaxRequestBytes: 1, MaxResponseBytes: 1, WarnOnly: true}))
+		res, err := client.Generate(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(res.Warnings) != 2 || res.Warnings[0].Code != grail.WarningRequestTooLarge || res.Warnings[1].Code != grail.WarningResponseTooLarge {
+			t.Fatalf("unexpected warnings: %+v", res.Warnings)
+		}
+	})
+
+	t.Run("warn only without logger", func(t *testing.T) {
+		client := grail.NewClient(prov, grail.WithLogger(nil), grail.WithSizeLimits(grail.SizeLimits{MaxRequestBytes: 1, MaxResponseBytes: 1, WarnOnly: true}))
+		if res, err := client.Generate(context.Background(), req); err != nil || len(res.Warnings) != 2 {
+			t.Fatalf("expected two warnings, got %+v, %v", res.Warnings, err)
+		}
+	})
+}
@@ -0,0 +1,265 @@
+package grail
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+//
+// Locale formatting
+//
+
+// WarningLocaleMismatch is set on responses with numbers that don't look
+// formatted for the client's locale but couldn't safely be rewritten, such as
+// "3.5" in a de-DE response, which may be a version number. The message lists
+// them.
+const WarningLocaleMismatch = "locale_mismatch"
+
+// WithLocale formats numbers, dates, and currency amounts in text outputs for
+// the BCP 47 locale tag, such as "de-DE", for apps serving formatted content
+// to international users. The model is asked to write them the locale's way,
+// and the response is checked locally:
+//
+//   - ISO dates (2026-01-31) are rewritten in the locale's date format.
+//   - Grouped numbers and currency amounts written another way are rewritten
+//     with the locale's separators, and currency symbols moved to the side
+//     the locale puts them.
+//   - Other numbers that don't fit the locale are left as they are and
+//     reported with WarningLocaleMismatch.
+//
+// Separators come from CLDR; digits are grouped in threes. JSON, image, and
+// constrained text outputs are unaffected. Use it on a child client to
+// localize some requests:
+//
+//	german := client.With(grail.WithLocale("de-DE"))
+//
+// Requests fail with InvalidArgument if tag isn't a valid locale.
+func WithLocale(tag string) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.locale = tag
+	})
+}
+
+// locale is how a locale writes numbers, dates, and currency amounts.
+type locale struct {
+	tag         string
+	decimal     string
+	group       string
+	date        string // time layout
+	symbolAfter bool   // "3,50 €" rather than "€3.50"
+}
+
+// localeDates are date layouts by language, or language and region where
+// they differ. Locales not listed use ISO dates.
+var localeDates = map[string]string{
+	"en-US": "01/02/2006", "en-CA": "2006-01-02", "en": "02/01/2006",
+	"de": "02.01.2006", "fr": "02/01/2006", "es": "02/01/2006", "it": "02/01/2006",
+	"pt": "02/01/2006", "nl": "02-01-2006", "da": "02.01.2006", "nb": "02.01.2006",
+	"fi": "2.1.2006", "pl": "02.01.2006", "cs": "02. 01. 2006", "ru": "02.01.2006",
+	"uk": "02.01.2006", "tr": "02.01.2006", "el": "02/01/2006", "ja": "2006/01/02",
+	"zh": "2006/01/02", "ko": "2006. 01. 02.", "sv": "2006-01-02", "hu": "2006. 01. 02.",
+}
+
+// symbolAfterLanguages put currency symbols after the amount.
+var symbolAfterLanguages = map[string]bool{
+	"de": true, "fr": true, "es": true, "it": true, "pl": true, "ru": true, "uk": true,
+	"sv": true, "fi": true, "cs": true, "sk": true, "da": true, "nb": true, "hu": true,
+	"ro": true, "bg": true, "hr": true, "sl": true, "lt": true, "lv": true, "et": true,
+	"el": true, "tr": true, "pt-PT": true,
+}
+
+func parseLocale(tag string) (*locale, error) {
+	t, err := language.Parse(tag)
+	if err != nil {
+		return nil, NewGrailError(InvalidArgument, fmt.Sprintf("invalid locale %q: %v", tag, err)).WithCause(err)
+	}
+	base, _ := t.Base()
+	region, _ := t.Region()
+	lang, full := base.String(), base.String()+"-"+region.String()
+
+	// CLDR's rendering of 1234.5 gives the separators.
+	sample := []rune(message.NewPrinter(t).Sprint(number.Decimal(1234.5)))
+	l := &locale{tag: tag, decimal: string(sample[len(sample)-2]), group: string(sample[1]), date: "2006-01-02"}
+	if layout, ok := localeDates[full]; ok {
+		l.date = layout
+	} else if layout, ok := localeDates[lang]; ok {
+		l.date = layout
+	}
+	l.symbolAfter = symbolAfterLanguages[full] || symbolAfterLanguages[lang]
+	return l, nil
+}
+
+// instructions asks the model to format for the locale.
+func (l *locale) instructions() string {
+	date := time.Date(2026, time.January, 31, 0, 0, 0, 0, time.UTC).Format(l.date)
+	return fmt.Sprintf("Format numbers, dates, and currency amounts for the %s locale: write numbers like %s, dates like %s, and amounts like %s.",
+		l.tag, l.formatNumber("1234567", "5", true), date, l.formatAmount("€", "1234", "50", true))
+}
+
+func (l *locale) formatNumber(whole, frac string, grouped bool) string {
+	var b strings.Builder
+	for i, r := range whole {
+		if grouped && i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(l.group)
+		}
+		b.WriteRune(r)
+	}
+	if frac != "" {
+		b.WriteString(l.decimal + frac)
+	}
+	return b.String()
+}
+
+func (l *locale) formatAmount(symbol, whole, frac string, grouped bool) string {
+	if l.symbolAfter {
+		return l.formatNumber(whole, frac, grouped) + "\u00a0" + symbol
+	}
+	return symbol + l.formatNumber(whole, frac, grouped)
+}
+
+const currencySymbols = `$€£¥₹`
+
+var (
+	localeNumberRe = regexp.MustCompile(`(?:([` + currencySymbols + `])[ \x{00A0}]?)?` +
+		`(\d{1,3}(?:[,.'\x{00A0}\x{202F}\x{2019}]\d{3})+(?:[.,]\d+)?|\d+(?:[.,]\d+)?)` +
+		`(?:[ \x{00A0}]?([` + currencySymbols + `]))?`)
+	isoDateRe = regexp.MustCompile(`\b(\d{4}-\d{2}-\d{2})\b`)
+)
+
+// normalize rewrites text for the locale and returns the numbers it left
+// alone that don't fit it.
+func (l *locale) normalize(text string) (string, []string) {
+	var mismatched []string
+	var b strings.Builder
+	last := 0
+	for _, m := range localeNumberRe.FindAllStringSubmatchIndex(text, -1) {
+		start, end := m[0], m[1]
+		if !numberBoundary(text, start, end) {
+			continue
+		}
+		raw := text[m[4]:m[5]]
+		var before, after string
+		if m[2] >= 0 {
+			before = text[m[2]:m[3]]
+		}
+		if m[6] >= 0 {
+			after = text[m[6]:m[7]]
+		}
+		if before != "" && after != "" {
+			continue
+		}
+		symbol := before + after
+		whole, frac, grouped, ambiguous := splitNumber(raw)
+		if ambiguous && symbol != "" {
+			// Amounts rarely have three decimals: "$1,234" is grouped.
+			whole, frac, grouped, ambiguous = strings.Map(keepDigits, raw), "", true, false
+		}
+		if ambiguous || (whole == raw && symbol == "") {
+			continue
+		}
+
+		var replacement string
+		switch {
+		case symbol != "":
+			replacement = l.formatAmount(symbol, whole, frac, grouped)
+			if replacement == strings.ReplaceAll(text[start:end], " ", "\u00a0") {
+				continue
+			}
+		case grouped:
+			if replacement = l.formatNumber(whole, frac, true); replacement == raw {
+				continue
+			}
+		default:
+			if l.formatNumber(whole, frac, false) != raw {
+				mismatched = append(mismatched, raw)
+			}
+			continue
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(replacement)
+		last = end
+	}
+	b.WriteString(text[last:])
+
+	text = isoDateRe.ReplaceAllStringFunc(b.String(), func(s string) string {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return s
+		}
+		return d.Format(l.date)
+	})
+	return text, mismatched
+}
+
+// numberBoundary reports whether text[start:end] stands alone, rather than
+// being part of a word, version, or address like "v1.2" or "10.0.0.1".
+func numberBoundary(text string, start, end int) bool {
+	if r, _ := utf8.DecodeLastRuneInString(text[:start]); start > 0 && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == ',') {
+		return false
+	}
+	r, size := utf8.DecodeRuneInString(text[end:])
+	if end < len(text) && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+		return false
+	}
+	if (r == '.' || r == ',') && end+size < len(text) {
+		next, _ := utf8.DecodeRuneInString(text[end+size:])
+		return !unicode.IsDigit(next)
+	}
+	return true
+}
+
+// splitNumber reads a number written with any separators into its whole
+// and fractional digits. A single separator followed by three digits, as in
+// "1,234", could be either and is ambiguous.
+func splitNumber(s string) (whole, frac string, grouped, ambiguous bool) {
+	var seps []rune
+	lastSep := -1
+	for i, r := range s {
+		if !unicode.IsDigit(r) {
+			seps = append(seps, r)
+			lastSep = i
+		}
+	}
+	switch {
+	case len(seps) == 0:
+		return s, "", false, false
+	case len(seps) == 1:
+		_, size := utf8.DecodeRuneInString(s[lastSep:])
+		if len(s)-lastSep-size == 3 {
+			return s, "", false, true
+		}
+		return s[:lastSep], s[lastSep+size:], false, false
+	case seps[len(seps)-1] != seps[0]:
+		// The last, different separator is the decimal one.
+		_, size := utf8.DecodeRuneInString(s[lastSep:])
+		return strings.Map(keepDigits, s[:lastSep]), s[lastSep+size:], true, false
+	}
+	return strings.Map(keepDigits, s), "", true, false
+}
+
+func keepDigits(r rune) rune {
+	if unicode.IsDigit(r) {
+		return r
+	}
+	return -1
+}
+
+// postProcessor normalizes text outputs for the locale.
+func (l *locale) postProcessor() PostProcessor {
+	return PostProcessor{Text: func(ctx context.Context, text string) (string, error) {
+		text, mismatched := l.normalize(text)
+		if len(mismatched) > 0 {
+			AddWarning(ctx, Warning{Code: WarningLocaleMismatch, Message: fmt.Sprintf("numbers not formatted for %s: %s", l.tag, strings.Join(mismatched, ", "))})
+		}
+		return text, nil
+	}}
+}
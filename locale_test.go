@@ -0,0 +1,56 @@
+package grail_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+)
+
+func TestLocale(t *testing.T) {
+	tests := []struct {
+		locale, reply, want, mismatched string
+	}{
+		{"de-DE", "Total: $1,234.56 due 2026-10-17.", "Total: 1.234,56 $ due 17.10.2026.", ""},
+		{"de-DE", "Population 1,234,567, or 1.234.567,5 counted.", "Population 1.234.567, or 1.234.567,5 counted.", ""},
+		{"de-DE", "Python 3.11 on 10.0.0.1 (v1.2) uses 1,234 MB.", "Python 3.11 on 10.0.0.1 (v1.2) uses 1,234 MB.", "3.11"},
+		{"en-US", "It costs 3,50 € or 1.234.567,5 in total on 2026-01-31T10:00.", "It costs €3.50 or 1,234,567.5 in total on 2026-01-31T10:00.", ""},
+		{"en-US", "Shipped 2026-01-31.", "Shipped 01/31/2026.", ""},
+		{"fr-FR", "Le total est de 1\u00a0234,50 € pour 1,234,567 pièces.", "Le total est de 1\u00a0234,50 € pour 1\u00a0234\u00a0567 pièces.", ""},
+	}
+	for _, tt := range tests {
+		prov, reqs := scripted(tt.reply)
+		res, err := grail.NewClient(prov, grail.WithLocale(tt.locale)).Generate(context.Background(), grail.Request{
+			Inputs: []grail.Input{grail.InputText("Summarize the invoice.")},
+			Output: grail.OutputText(),
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.locale, err)
+		}
+		if got, _ := res.Text(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.locale, got, tt.want)
+		}
+		var warned string
+		for _, w := range res.Warnings {
+			if w.Code == grail.WarningLocaleMismatch {
+				warned = w.Message
+			}
+		}
+		if (tt.mismatched == "") != (warned == "") || !strings.Contains(warned, tt.mismatched) {
+			t.Errorf("%s: expected mismatch %q, got warning %q", tt.locale, tt.mismatched, warned)
+		}
+		sent := (*reqs)[0]
+		if last, _ := grail.AsTextInput(sent.Inputs[len(sent.Inputs)-1]); !strings.Contains(last, tt.locale) {
+			t.Errorf("%s: expected locale instructions, got %q", tt.locale, last)
+		}
+	}
+
+	prov, _ := scripted("hi")
+	if _, err := grail.NewClient(prov, grail.WithLocale("not a locale")).Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("hi")},
+		Output: grail.OutputText(),
+	}); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected InvalidArgument for a bad locale, got %v", err)
+	}
+}
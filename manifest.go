@@ -0,0 +1,177 @@
+package grail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//
+// Generation manifests
+//
+
+// ManifestVersion is the format version written by WriteManifest.
+const ManifestVersion = 1
+
+// Manifest records how a saved output was generated, so artifacts on disk stay
+// traceable to the requests that produced them. It is written as a JSON
+// sidecar next to the output (photo.png gets photo.png.json).
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// The saved output.
+	File string `json:"file"` // base name
+	MIME string `json:"mime,omitempty"`
+	Size int    `json:"size"`
+	Hash string `json:"hash"` // "sha256:<hex>", as AttachmentRef
+
+	// The request.
+	Prompt   string                     `json:"prompt,omitempty"` // text inputs, one per line
+	Files    []TurnPart                 `json:"files,omitempty"`  // file inputs, by reference
+	Model    string                     `json:"model,omitempty"`  // as requested
+	Tier     ModelTier                  `json:"tier,omitempty"`
+	Options  map[string]json.RawMessage `json:"options,omitempty"` // provider options, keyed by Go type
+	Metadata map[string]string          `json:"metadata,omitempty"`
+
+	// The response.
+	Provider  string     `json:"provider,omitempty"`
+	Models    []ModelUse `json:"models,omitempty"`
+	RequestID string     `json:"request_id,omitempty"`
+	Usage     Usage      `json:"usage"`
+}
+
+// NewManifest describes the request and response behind an output. The output
+// fields are filled in by WriteManifest. Provider options that can't be
+// encoded as JSON are left out.
+func NewManifest(req Request, res Response) Manifest {
+	m := Manifest{
+		Version:   ManifestVersion,
+		CreatedAt: time.Now().UTC(),
+		Model:     req.Model,
+		Tier:      req.Tier,
+		Metadata:  copyMetadata(req.Metadata),
+		Provider:  res.Provider.Name,
+		Models:    res.Provider.Models,
+		RequestID: res.RequestID,
+		Usage:     res.Usage,
+	}
+	var prompt []string
+	for _, in := range req.Inputs {
+		switch v := in.(type) {
+		case textInput:
+			prompt = append(prompt, v.Text)
+		case fileInput:
+			m.Files = append(m.Files, TurnPart{Type: "file", Ref: AttachmentRef(v.Data), MIME: v.MIME, Name: v.Name})
+		case fileReaderInput:
+			m.Files = append(m.Files, TurnPart{Type: "file", MIME: v.MIME, Name: v.Name})
+		}
+	}
+	m.Prompt = strings.Join(prompt, "\n")
+	for _, opt := range req.ProviderOptions {
+		if opt == nil {
+			continue
+		}
+		data, err := json.Marshal(opt)
+		if err != nil {
+			continue
+		}
+		if m.Options == nil {
+			m.Options = map[string]json.RawMessage{}
+		}
+		m.Options[fmt.Sprintf("%T", opt)] = data
+	}
+	return m
+}
+
+// ManifestOption configures WriteManifest and ReadManifest.
+type ManifestOption interface{ applyManifestOpt(*manifestOpt) }
+
+type manifestOpt struct {
+	keys KeyProvider
+}
+
+type manifestOptFunc func(*manifestOpt)
+
+func (f manifestOptFunc) applyManifestOpt(mo *manifestOpt) { f(mo) }
+
+func newManifestOpt(opts []ManifestOption) manifestOpt {
+	var mo manifestOpt
+	for _, opt := range opts {
+		opt.applyManifestOpt(&mo)
+	}
+	return mo
+}
+
+// WithManifestEncryption encrypts the sidecar with AES-GCM under keys (see
+// Seal), since it holds the prompt. The sidecar is bound to its output's file
+// name, so it can't be passed off as another output's; read it with
+// ReadManifest and the same keys.
+func WithManifestEncryption(keys KeyProvider) ManifestOption {
+	return manifestOptFunc(func(mo *manifestOpt) {
+		mo.keys = keys
+	})
+}
+
+func manifestAAD(file string) string {
+	return "grail/manifest\x00" + file
+}
+
+// WriteManifest writes m, completed with the name, size, and hash of data, to
+// path + ".json". data is the output as saved at path.
+func WriteManifest(path string, data []byte, m Manifest, opts ...ManifestOption) error {
+	mo := newManifestOpt(opts)
+	if m.Version == 0 {
+		m.Version = ManifestVersion
+	}
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = time.Now().UTC()
+	}
+	m.File, m.Size, m.Hash = filepath.Base(path), len(data), AttachmentRef(data)
+	out, err := json.MarshalIndent(m, "", "  ")
+	if err == nil && mo.keys != nil {
+		out, err = sealJSON(mo.keys, m, manifestAAD(m.File))
+	}
+	if err != nil {
+		return NewGrailError(Internal, fmt.Sprintf("encode manifest: %v", err)).WithCause(err)
+	}
+	if err := os.WriteFile(path+".json", append(out, '\n'), 0o644); err != nil {
+		return NewGrailError(Internal, fmt.Sprintf("failed to save manifest: %v", err)).WithCause(err)
+	}
+	return nil
+}
+
+// ReadManifest reads the manifest of the output at path from its sidecar. An
+// encrypted sidecar is read with WithManifestEncryption; with it, the sidecar
+// must be encrypted.
+func ReadManifest(path string, opts ...ManifestOption) (Manifest, error) {
+	mo := newManifestOpt(opts)
+	data, err := os.ReadFile(path + ".json")
+	if err != nil {
+		code := Internal
+		if errors.Is(err, fs.ErrNotExist) {
+			code = NotFound
+		}
+		return Manifest{}, NewGrailError(code, fmt.Sprintf("read manifest: %v", err)).WithCause(err)
+	}
+	var m Manifest
+	if err := openJSON(mo.keys, data, &m, manifestAAD(filepath.Base(path))); err != nil {
+		return Manifest{}, err
+	}
+	return m, nil
+}
+
+// WithManifest writes m as a sidecar when an image is saved (see
+// WriteManifest). Build m with NewManifest from the image's request and
+// response.
+func WithManifest(m Manifest, opts ...ManifestOption) SaveOpt {
+	return saveOptFunc(func(so *saveOpt) {
+		so.manifest = &m
+		so.manifestOpts = opts
+	})
+}
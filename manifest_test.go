@@ -0,0 +1,97 @@
+package grail_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/fake"
+)
+
+func TestSaveWithManifest(t *testing.T) {
+	req := grail.Request{
+		Inputs: []grail.Input{
+			grail.InputText("a lighthouse"),
+			grail.InputFile([]byte("%PDF-1.7"), "application/pdf", grail.WithFileName("brief.pdf")),
+			grail.InputText("at night"),
+		},
+		Output:          grail.OutputImage(grail.ImageSpec{Count: 1}),
+		ProviderOptions: []grail.ProviderOption{fake.ImageOptions{Width: 8, Height: 8}},
+		Metadata:        map[string]string{"job": "covers"},
+	}
+	res, err := grail.NewClient(fake.New()).Generate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	img := res.ImageOutputs()[0]
+
+	path := filepath.Join(t.TempDir(), "cover.png")
+	if err := img.Save(path, grail.WithManifest(grail.NewManifest(req, res))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(path + ".json")
+	if err != nil {
+		t.Fatalf("expected manifest sidecar: %v", err)
+	}
+	var m grail.Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("decode manifest: %v", err)
+	}
+	if m.Version != grail.ManifestVersion || m.File != "cover.png" || m.MIME != "image/png" ||
+		m.Size != len(img.Data) || m.Hash != grail.AttachmentRef(img.Data) {
+		t.Fatalf("unexpected output fields: %+v", m)
+	}
+	if m.Prompt != "a lighthouse\nat night" || len(m.Files) != 1 || m.Files[0].Name != "brief.pdf" || m.Metadata["job"] != "covers" {
+		t.Fatalf("unexpected request fields: %+v", m)
+	}
+	var opts fake.ImageOptions
+	if err := json.Unmarshal(m.Options["fake.ImageOptions"], &opts); err != nil || opts.Width != 8 || opts.Height != 8 {
+		t.Fatalf("unexpected options: %s", data)
+	}
+	if m.Provider != "fake" || len(m.Models) == 0 || m.Models[0].Name != fake.ImageModel.Name {
+		t.Fatalf("unexpected response fields: %+v", m)
+	}
+}
+
+func TestManifestEncryption(t *testing.T) {
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("a secret lighthouse")}, Output: grail.OutputImage(grail.ImageSpec{Count: 1})}
+	res, err := grail.NewClient(fake.New()).Generate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	img := res.ImageOutputs()[0]
+	keys := grail.StaticKey(bytes.Repeat([]byte{6}, 32))
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cover.png")
+	if err := img.Save(path, grail.WithManifest(grail.NewManifest(req, res), grail.WithManifestEncryption(keys))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(path + ".json")
+	if err != nil || bytes.Contains(data, []byte("secret")) {
+		t.Fatalf("expected an encrypted sidecar, got %s (%v)", data, err)
+	}
+	m, err := grail.ReadManifest(path, grail.WithManifestEncryption(keys))
+	if err != nil || m.Prompt != "a secret lighthouse" || m.File != "cover.png" {
+		t.Fatalf("ReadManifest = %+v, %v", m, err)
+	}
+	if _, err := grail.ReadManifest(path); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Fatalf("expected reading without keys to fail, got %v", err)
+	}
+
+	// A sidecar can't be passed off as another output's.
+	other := filepath.Join(dir, "other.png")
+	if err := os.WriteFile(other+".json", data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := grail.ReadManifest(other, grail.WithManifestEncryption(keys)); err == nil {
+		t.Fatal("expected a sidecar moved to another output to fail")
+	}
+	if _, err := grail.ReadManifest(filepath.Join(dir, "missing.png")); grail.GetErrorCode(err) != grail.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+}
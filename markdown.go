@@ -0,0 +1,166 @@
+package grail
+
+import (
+	"regexp"
+	"strings"
+)
+
+//
+// Markdown helpers
+//
+
+// CodeBlock is a fenced code block found in text output.
+type CodeBlock struct {
+	Lang string // first word of the info string, lowercased; may be empty
+	Code string // contents without the fences
+}
+
+// CodeBlocks returns the fenced code blocks (``` or ~~~) in text, in order.
+// A block left open at the end of the text, as in a truncated response, runs
+// to the end.
+func CodeBlocks(text string) []CodeBlock {
+	var (
+		blocks []CodeBlock
+		cur    *CodeBlock
+		body   []string
+		fence  string
+	)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		trimmed := strings.TrimLeft(line, " ")
+		if cur == nil {
+			if len(line)-len(trimmed) > 3 {
+				continue
+			}
+			f := fenceRun(trimmed)
+			if f == "" {
+				continue
+			}
+			info := strings.TrimSpace(trimmed[len(f):])
+			if f[0] == '`' && strings.Contains(info, "`") {
+				continue // inline code, not a fence
+			}
+			lang, _, _ := strings.Cut(info, " ")
+			cur, fence, body = &CodeBlock{Lang: strings.ToLower(lang)}, f, nil
+			continue
+		}
+		if f := fenceRun(trimmed); f != "" && f[0] == fence[0] && len(f) >= len(fence) && strings.TrimSpace(trimmed[len(f):]) == "" {
+			cur.Code = strings.Join(body, "\n")
+			blocks = append(blocks, *cur)
+			cur = nil
+			continue
+		}
+		body = append(body, line)
+	}
+	if cur != nil {
+		cur.Code = strings.Join(body, "\n")
+		blocks = append(blocks, *cur)
+	}
+	return blocks
+}
+
+// fenceRun returns the run of three or more backticks or tildes line starts
+// with, or "".
+func fenceRun(line string) string {
+	if len(line) < 3 || (line[0] != '`' && line[0] != '~') {
+		return ""
+	}
+	n := 0
+	for n < len(line) && line[n] == line[0] {
+		n++
+	}
+	if n < 3 {
+		return ""
+	}
+	return line[:n]
+}
+
+// CodeBlocks returns the fenced code blocks in the text output.
+func (r Response) CodeBlocks() []CodeBlock {
+	text, _ := r.Text()
+	return CodeBlocks(text)
+}
+
+// Code returns the contents of the first code block tagged lang (compared
+// case-insensitively). An empty lang matches any block, and if the text has
+// no fenced blocks at all, Code("") returns the whole text trimmed, since
+// models often answer with bare code.
+func (r Response) Code(lang string) (string, bool) {
+	text, ok := r.Text()
+	if !ok {
+		return "", false
+	}
+	blocks := CodeBlocks(text)
+	lang = strings.ToLower(lang)
+	for _, b := range blocks {
+		if lang == "" || b.Lang == lang {
+			return b.Code, true
+		}
+	}
+	if lang == "" && len(blocks) == 0 {
+		return strings.TrimSpace(text), true
+	}
+	return "", false
+}
+
+// Markdown returns the text output with any fence wrapping the entire answer
+// (```markdown ... ```, which some models add) removed. Fenced blocks inside
+// the document are left alone.
+func (r Response) Markdown() (string, bool) {
+	text, ok := r.Text()
+	if !ok {
+		return "", false
+	}
+	trimmed := strings.TrimSpace(text)
+	first, rest, ok := strings.Cut(trimmed, "\n")
+	f := fenceRun(first)
+	if !ok || f == "" || !strings.HasSuffix(rest, f) {
+		return trimmed, true
+	}
+	body := strings.TrimSuffix(rest, f)
+	if body != "" && !strings.HasSuffix(body, "\n") {
+		return trimmed, true
+	}
+	// A markdown-tagged fence may contain nested blocks, so only the outer
+	// fence is inspected. An untagged one must be the only block, otherwise
+	// the text merely starts and ends with code.
+	switch strings.ToLower(strings.TrimSpace(first[len(f):])) {
+	case "markdown", "md":
+		return strings.TrimSpace(body), true
+	case "":
+		if len(CodeBlocks(trimmed)) == 1 {
+			return strings.TrimSpace(body), true
+		}
+	}
+	return trimmed, true
+}
+
+var (
+	mdFence      = regexp.MustCompile("(?m)^[ \t]*(```|~~~).*$\n?")
+	mdHeading    = regexp.MustCompile(`(?m)^[ \t]{0,3}#{1,6}[ \t]+(.*?)[ \t#]*$`)
+	mdQuote      = regexp.MustCompile(`(?m)^[ \t]{0,3}>[ \t]?`)
+	mdRule       = regexp.MustCompile(`(?m)^[ \t]{0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$\n?`)
+	mdBullet     = regexp.MustCompile(`(?m)^([ \t]*)[*+][ \t]+`)
+	mdImage      = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink       = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	mdBold       = regexp.MustCompile(`(\*\*|__)(\S(?:.*?\S)?)(\*\*|__)`)
+	mdItalic     = regexp.MustCompile(`(^|[^\w*])[*_](\S(?:[^*_]*?\S)?)[*_]($|[^\w*])`)
+	mdStrike     = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+	mdInlineCode = regexp.MustCompile("`([^`]+)`")
+)
+
+// stripMarkdown removes Markdown markup from s, keeping the text it wraps.
+func stripMarkdown(s string) string {
+	s = mdFence.ReplaceAllString(s, "")
+	s = mdRule.ReplaceAllString(s, "")
+	s = mdHeading.ReplaceAllString(s, "$1")
+	s = mdQuote.ReplaceAllString(s, "")
+	s = mdBullet.ReplaceAllString(s, "$1- ")
+	s = mdImage.ReplaceAllString(s, "$1")
+	s = mdLink.ReplaceAllString(s, "$1")
+	s = mdInlineCode.ReplaceAllString(s, "$1")
+	s = mdBold.ReplaceAllString(s, "$2")
+	s = mdStrike.ReplaceAllString(s, "$1")
+	s = mdItalic.ReplaceAllString(s, "$1$2$3")
+	return s
+}
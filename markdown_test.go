@@ -0,0 +1,51 @@
+package grail_test
+
+import (
+	"testing"
+
+	"github.com/montanaflynn/grail"
+)
+
+func textResponse(text string) grail.Response {
+	return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart(text)}}
+}
+
+func TestResponseCode(t *testing.T) {
+	res := textResponse("Here you go:\n\n```Go\nfmt.Println(\"hi\")\n```\n\nAnd the shell:\n~~~sh title=run\ngo run .\n~~~\n")
+
+	if code, ok := res.Code("go"); !ok || code != `fmt.Println("hi")` {
+		t.Fatalf("Code(go) = %q, %v", code, ok)
+	}
+	if code, ok := res.Code("sh"); !ok || code != "go run ." {
+		t.Fatalf("Code(sh) = %q, %v", code, ok)
+	}
+	if _, ok := res.Code("python"); ok {
+		t.Fatalf("expected no python block")
+	}
+	if code, _ := res.Code(""); code != `fmt.Println("hi")` {
+		t.Fatalf("Code(\"\") should return the first block, got %q", code)
+	}
+	if code, ok := textResponse("  SELECT 1;\n").Code(""); !ok || code != "SELECT 1;" {
+		t.Fatalf("bare code should be returned trimmed, got %q, %v", code, ok)
+	}
+	if blocks := textResponse("```json\n{\"truncated\":").CodeBlocks(); len(blocks) != 1 || blocks[0].Lang != "json" {
+		t.Fatalf("expected unclosed block to run to the end, got %+v", blocks)
+	}
+}
+
+func TestResponseMarkdown(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"```markdown\n# Title\n\n```go\nx := 1\n```\n```", "# Title\n\n```go\nx := 1\n```"},
+		{"```\n# Title\n```", "# Title"},
+		{"# Title\nbody", "# Title\nbody"},
+		{"```go\na\n```\ntext\n```go\nb\n```", "```go\na\n```\ntext\n```go\nb\n```"},
+		{"```\na\n```\ntext\n```\nb\n```", "```\na\n```\ntext\n```\nb\n```"},
+	}
+	for _, tt := range tests {
+		if got, _ := textResponse(tt.text).Markdown(); got != tt.want {
+			t.Errorf("Markdown(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package grail
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+)
+
+//
+// Context metadata
+//
+
+type metadataKey struct{}
+
+// ContextWithMetadata returns a context carrying metadata key-value pairs,
+// given as alternating keys and values. Pairs accumulate across calls, with
+// later values for a key replacing earlier ones. A trailing key without a
+// value is stored with an empty value.
+//
+// Clients merge context metadata into every request's Metadata (values set on
+// the request win) and include it in their log lines, so HTTP middleware can
+// tag all downstream generations with user or trace IDs.
+func ContextWithMetadata(ctx context.Context, kv ...string) context.Context {
+	md := copyMetadata(MetadataFromContext(ctx))
+	if md == nil {
+		md = make(map[string]string, len(kv)/2+1)
+	}
+	for i := 0; i < len(kv); i += 2 {
+		var v string
+		if i+1 < len(kv) {
+			v = kv[i+1]
+		}
+		md[kv[i]] = v
+	}
+	return context.WithValue(ctx, metadataKey{}, md)
+}
+
+// MetadataFromContext returns the metadata stored by ContextWithMetadata, or
+// nil. The returned map must not be modified.
+func MetadataFromContext(ctx context.Context) map[string]string {
+	md, _ := ctx.Value(metadataKey{}).(map[string]string)
+	return md
+}
+
+// mergeMetadata returns md with base's keys added where md doesn't set them.
+func mergeMetadata(base, md map[string]string) map[string]string {
+	if len(base) == 0 {
+		return md
+	}
+	out := make(map[string]string, len(base)+len(md))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range md {
+		out[k] = v
+	}
+	return out
+}
+
+// metadataAttr renders metadata as a log group with keys in sorted order.
+func metadataAttr(md map[string]string) slog.Attr {
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attrs := make([]any, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, slog.String(k, md[k]))
+	}
+	return slog.Group("metadata", attrs...)
+}
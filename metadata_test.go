@@ -0,0 +1,48 @@
+package grail_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestContextMetadata(t *testing.T) {
+	var got grail.Request
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			got = req
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("ok")}}, nil
+		},
+	}
+	var logs bytes.Buffer
+	client := grail.NewClient(prov,
+		grail.WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		grail.WithDefaultMetadata(map[string]string{"app": "demo", "user_id": "default"}),
+	)
+
+	ctx := grail.ContextWithMetadata(context.Background(), "user_id", "u-1", "trace_id", "t-1")
+	ctx = grail.ContextWithMetadata(ctx, "trace_id", "t-2")
+	_, err := client.Generate(ctx, grail.Request{
+		Inputs:   []grail.Input{grail.InputText("hi")},
+		Output:   grail.OutputText(),
+		Metadata: map[string]string{"route": "/chat"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]string{"app": "demo", "user_id": "u-1", "trace_id": "t-2", "route": "/chat"}
+	for k, v := range want {
+		if got.Metadata[k] != v {
+			t.Fatalf("metadata %q: expected %q, got %q (%v)", k, v, got.Metadata[k], got.Metadata)
+		}
+	}
+	if !strings.Contains(logs.String(), "metadata.trace_id=t-2") {
+		t.Fatalf("expected metadata in log line, got %q", logs.String())
+	}
+}
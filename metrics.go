@@ -0,0 +1,64 @@
+package grail
+
+import (
+	"errors"
+	"time"
+)
+
+//
+// Metrics
+//
+
+// MetricLabels say which provider and model a metric is for.
+type MetricLabels struct {
+	Provider string
+	// Model is the model that answered, or the requested one if the call
+	// failed before one did ("" for the provider's default).
+	Model string
+}
+
+// MetricsRecorder receives a client's request metrics, for monitoring
+// request rates, token spend, and errors across a fleet, such as a
+// metrics/prometheus.Recorder. Methods are called synchronously at the end
+// of each Generate call, so they must be quick. Implementations must be safe
+// for concurrent use.
+type MetricsRecorder interface {
+	// ObserveLatency records how long a Generate call took, whether or not
+	// it succeeded; the number of observations is the request count.
+	ObserveLatency(labels MetricLabels, d time.Duration)
+	// AddTokens records the tokens a successful call used.
+	AddTokens(labels MetricLabels, usage Usage)
+	// IncErrors counts a failed call by its error code.
+	IncErrors(labels MetricLabels, code ErrorCode)
+}
+
+// WithMetrics reports every Generate call's latency, token usage, and
+// errors to r. Child clients created with With report to it too.
+func WithMetrics(r MetricsRecorder) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.metrics = r
+	})
+}
+
+// recordMetrics reports a finished Generate call to the client's
+// MetricsRecorder.
+func (c *client) recordMetrics(req Request, res Response, err error, d time.Duration) {
+	labels := MetricLabels{Provider: res.Provider.Name, Model: req.Model}
+	if labels.Provider == "" && c.provider != nil {
+		labels.Provider = c.provider.Name()
+		var ge GrailError
+		if errors.As(err, &ge) && ge.ProviderName() != "" {
+			labels.Provider = ge.ProviderName()
+		}
+	}
+	if len(res.Provider.Models) > 0 {
+		labels.Model = res.Provider.Models[0].Name
+	}
+	m := c.opts.metrics
+	m.ObserveLatency(labels, d)
+	if err != nil {
+		m.IncErrors(labels, GetErrorCode(err))
+		return
+	}
+	m.AddTokens(labels, res.Usage)
+}
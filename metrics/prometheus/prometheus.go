@@ -0,0 +1,119 @@
+// Package prometheus is a grail.MetricsRecorder exporting Prometheus
+// metrics, for monitoring request rates, token spend, and errors by
+// provider and model:
+//
+//	recorder := prometheus.New(prometheus.Options{})
+//	goprom.MustRegister(recorder)
+//	client := grail.NewClient(provider, grail.WithMetrics(recorder))
+//
+//	http.Handle("/metrics", promhttp.Handler())
+//
+// The metrics, with "provider" and "model" labels, are:
+//
+//	grail_request_duration_seconds  histogram of Generate calls' latency;
+//	                                its _count is the request count
+//	grail_tokens_total              tokens used by successful calls, by
+//	                                "type": input, output, or cached_input
+//	                                (the part of input read from a cache)
+//	grail_errors_total              failed calls, by error "code"
+package prometheus
+
+import (
+	"time"
+
+	"github.com/montanaflynn/grail"
+	goprom "github.com/prometheus/client_golang/prometheus"
+)
+
+// Options configures New.
+type Options struct {
+	// Namespace prefixes metric names (default "grail").
+	Namespace string
+	// Buckets are the latency histogram's buckets in seconds (default
+	// 0.1 to about 100 seconds, doubling).
+	Buckets []float64
+	// ConstLabels are added to every metric, such as the service's name.
+	ConstLabels goprom.Labels
+}
+
+// Recorder is a grail.MetricsRecorder and a prometheus.Collector of its
+// metrics. Register it with a prometheus.Registerer. It is safe for
+// concurrent use.
+type Recorder struct {
+	latency *goprom.HistogramVec
+	tokens  *goprom.CounterVec
+	errors  *goprom.CounterVec
+}
+
+var (
+	_ grail.MetricsRecorder = (*Recorder)(nil)
+	_ goprom.Collector      = (*Recorder)(nil)
+)
+
+// New returns a recorder of the metrics opts describes.
+func New(opts Options) *Recorder {
+	if opts.Namespace == "" {
+		opts.Namespace = "grail"
+	}
+	if opts.Buckets == nil {
+		opts.Buckets = goprom.ExponentialBuckets(0.1, 2, 11)
+	}
+	return &Recorder{
+		latency: goprom.NewHistogramVec(goprom.HistogramOpts{
+			Namespace:   opts.Namespace,
+			Name:        "request_duration_seconds",
+			Help:        "Latency of Generate calls, successful or not.",
+			Buckets:     opts.Buckets,
+			ConstLabels: opts.ConstLabels,
+		}, []string{"provider", "model"}),
+		tokens: goprom.NewCounterVec(goprom.CounterOpts{
+			Namespace:   opts.Namespace,
+			Name:        "tokens_total",
+			Help:        "Tokens used by successful Generate calls, by type: input, output, or cached_input.",
+			ConstLabels: opts.ConstLabels,
+		}, []string{"provider", "model", "type"}),
+		errors: goprom.NewCounterVec(goprom.CounterOpts{
+			Namespace:   opts.Namespace,
+			Name:        "errors_total",
+			Help:        "Failed Generate calls, by error code.",
+			ConstLabels: opts.ConstLabels,
+		}, []string{"provider", "model", "code"}),
+	}
+}
+
+// ObserveLatency implements grail.MetricsRecorder.
+func (r *Recorder) ObserveLatency(labels grail.MetricLabels, d time.Duration) {
+	r.latency.WithLabelValues(labels.Provider, labels.Model).Observe(d.Seconds())
+}
+
+// AddTokens implements grail.MetricsRecorder.
+func (r *Recorder) AddTokens(labels grail.MetricLabels, usage grail.Usage) {
+	for typ, n := range map[string]int{
+		"input":        usage.InputTokens,
+		"output":       usage.OutputTokens,
+		"cached_input": usage.CachedInputTokens,
+	} {
+		if n > 0 {
+			r.tokens.WithLabelValues(labels.Provider, labels.Model, typ).Add(float64(n))
+		}
+	}
+}
+
+// IncErrors implements grail.MetricsRecorder.
+func (r *Recorder) IncErrors(labels grail.MetricLabels, code grail.ErrorCode) {
+	r.errors.WithLabelValues(labels.Provider, labels.Model, string(code)).Inc()
+}
+
+// Describe implements prometheus.Collector.
+func (r *Recorder) Describe(ch chan<- *goprom.Desc) {
+	r.latency.Describe(ch)
+	r.tokens.Describe(ch)
+	r.errors.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (r *Recorder) Collect(ch chan<- goprom.Metric) {
+	r.latency.Collect(ch)
+	r.tokens.Collect(ch)
+	r.errors.Collect(ch)
+}
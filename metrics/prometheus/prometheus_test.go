@@ -0,0 +1,53 @@
+package prometheus_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/metrics/prometheus"
+	"github.com/montanaflynn/grail/providers/mock"
+	goprom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRecorder(t *testing.T) {
+	recorder := prometheus.New(prometheus.Options{ConstLabels: goprom.Labels{"service": "test"}})
+	reg := goprom.NewPedanticRegistry()
+	reg.MustRegister(recorder)
+
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			if req.Model == "broken" {
+				return grail.Response{}, grail.NewGrailError(grail.RateLimited, "slow down").WithProviderName("mock")
+			}
+			return grail.Response{
+				Outputs:  []grail.OutputPart{grail.NewTextOutputPart("ok")},
+				Usage:    grail.Usage{InputTokens: 10, OutputTokens: 5, TotalTokens: 15, CachedInputTokens: 4},
+				Provider: grail.ProviderInfo{Name: "mock", Models: []grail.ModelUse{{Name: "m-2024"}}},
+			}, nil
+		},
+	}
+	client := grail.NewClient(prov, grail.WithMetrics(recorder))
+	ctx := context.Background()
+	for _, model := range []string{"m", "m", "broken"} {
+		client.Generate(ctx, grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText(), Model: model})
+	}
+
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP grail_errors_total Failed Generate calls, by error code.
+# TYPE grail_errors_total counter
+grail_errors_total{code="rate_limited",model="broken",provider="mock",service="test"} 1
+# HELP grail_tokens_total Tokens used by successful Generate calls, by type: input, output, or cached_input.
+# TYPE grail_tokens_total counter
+grail_tokens_total{model="m-2024",provider="mock",service="test",type="cached_input"} 8
+grail_tokens_total{model="m-2024",provider="mock",service="test",type="input"} 20
+grail_tokens_total{model="m-2024",provider="mock",service="test",type="output"} 10
+`), "grail_errors_total", "grail_tokens_total"); err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(recorder, "grail_request_duration_seconds"); n != 2 {
+		t.Errorf("expected latency for 2 provider/model pairs, got %d", n)
+	}
+}
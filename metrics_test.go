@@ -0,0 +1,78 @@
+package grail_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+type memoryMetrics struct {
+	mu        sync.Mutex
+	latencies map[grail.MetricLabels]int
+	tokens    map[grail.MetricLabels]grail.Usage
+	errors    map[grail.MetricLabels][]grail.ErrorCode
+}
+
+func (m *memoryMetrics) ObserveLatency(l grail.MetricLabels, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latencies[l]++
+}
+
+func (m *memoryMetrics) AddTokens(l grail.MetricLabels, u grail.Usage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[l] = m.tokens[l].Add(u)
+}
+
+func (m *memoryMetrics) IncErrors(l grail.MetricLabels, code grail.ErrorCode) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors[l] = append(m.errors[l], code)
+}
+
+func TestWithMetrics(t *testing.T) {
+	metrics := &memoryMetrics{
+		latencies: map[grail.MetricLabels]int{},
+		tokens:    map[grail.MetricLabels]grail.Usage{},
+		errors:    map[grail.MetricLabels][]grail.ErrorCode{},
+	}
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			if req.Model == "broken" {
+				return grail.Response{}, grail.NewGrailError(grail.Unavailable, "down").WithProviderName("upstream")
+			}
+			return grail.Response{
+				Outputs:  []grail.OutputPart{grail.NewTextOutputPart("ok")},
+				Usage:    grail.Usage{InputTokens: 3, OutputTokens: 2, TotalTokens: 5},
+				Provider: grail.ProviderInfo{Name: "mock", Models: []grail.ModelUse{{Name: "m-2024"}}},
+			}, nil
+		},
+	}
+	client := grail.NewClient(prov, grail.WithMetrics(metrics))
+	ctx := context.Background()
+	generate := func(c grail.Client, model string) {
+		c.Generate(ctx, grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText(), Model: model})
+	}
+	generate(client, "m")
+	generate(client.With(), "m")
+	generate(client, "broken")
+
+	// Successes are labeled with the model that answered, and failures with
+	// the requested model and the failing provider.
+	ok := grail.MetricLabels{Provider: "mock", Model: "m-2024"}
+	failed := grail.MetricLabels{Provider: "upstream", Model: "broken"}
+	if metrics.latencies[ok] != 2 || metrics.latencies[failed] != 1 {
+		t.Errorf("unexpected latencies %v", metrics.latencies)
+	}
+	if u := metrics.tokens[ok]; u.InputTokens != 6 || u.OutputTokens != 4 || len(metrics.tokens) != 1 {
+		t.Errorf("unexpected tokens %v", metrics.tokens)
+	}
+	if codes := metrics.errors[failed]; len(codes) != 1 || codes[0] != grail.Unavailable || len(metrics.errors) != 1 {
+		t.Errorf("unexpected errors %v", metrics.errors)
+	}
+}
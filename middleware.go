@@ -0,0 +1,60 @@
+package grail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+)
+
+//
+// Middleware
+//
+
+// GenerateFunc is the signature of a provider call.
+type GenerateFunc func(ctx context.Context, req Request) (Response, error)
+
+// Middleware wraps provider calls. It sees the request after the client has
+// applied defaults, resolved the model, and validated it, and the response
+// before the client post-processes it (JSON fallback, image safety and
+// processing, size limits, usage tracking).
+type Middleware func(next GenerateFunc) GenerateFunc
+
+// WithMiddleware wraps every provider call made by the client in mw. The first
+// middleware added is the outermost.
+func WithMiddleware(mw ...Middleware) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.middleware = append(co.middleware[:len(co.middleware):len(co.middleware)], mw...)
+	})
+}
+
+// chain returns the provider call wrapped in the client's middleware.
+func (c *client) chain() GenerateFunc {
+	call := GenerateFunc(c.doGenerate)
+	for i := len(c.opts.middleware) - 1; i >= 0; i-- {
+		if mw := c.opts.middleware[i]; mw != nil {
+			call = mw(call)
+		}
+	}
+	return call
+}
+
+// callProvider runs the middleware chain and provider call, converting a panic
+// into an Internal error so a provider or SDK bug fails one request instead of
+// the whole process. The panic value and stack are in the error's Details.
+func (c *client) callProvider(ctx context.Context, req Request) (res Response, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			res = Response{}
+			err = NewGrailError(Internal, fmt.Sprintf("provider %s panicked: %v", c.provider.Name(), r)).
+				WithProviderName(c.provider.Name()).
+				WithDetail("panic", fmt.Sprint(r)).
+				WithDetail("stack", string(debug.Stack()))
+			if c.log != nil {
+				c.log.Error("provider panic recovered", slog.String("provider", c.provider.Name()), slog.String("panic", fmt.Sprint(r)))
+			}
+		}
+	}()
+	c.events.providerCalled(ctx, req)
+	return c.chain()(ctx, req)
+}
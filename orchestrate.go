@@ -0,0 +1,266 @@
+package grail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+//
+// Orchestration
+//
+
+// Agent is a specialized prompt: instructions sent ahead of a task's inputs,
+// with its own model choice. Agents are run by FanOut.
+type Agent struct {
+	Name            string // identifies the agent's output to the synthesizer
+	Instructions    string
+	Model           string
+	Tier            ModelTier
+	Output          Output // default OutputText()
+	ProviderOptions []ProviderOption
+}
+
+// request builds the agent's request for inputs.
+func (a Agent) request(inputs []Input, metadata map[string]string) Request {
+	req := Request{
+		Output:          a.Output,
+		Model:           a.Model,
+		Tier:            a.Tier,
+		ProviderOptions: a.ProviderOptions,
+		Metadata:        copyMetadata(metadata),
+	}
+	if req.Output == nil {
+		req.Output = OutputText()
+	}
+	if a.Instructions != "" {
+		req.Inputs = append(req.Inputs, InputText(a.Instructions))
+	}
+	req.Inputs = append(req.Inputs, inputs...)
+	return req
+}
+
+// AgentResult is one agent's part in a FanOut run.
+type AgentResult struct {
+	Agent    string
+	Response Response
+	Err      error
+}
+
+// FanOutResult is the outcome of FanOut.Run.
+type FanOutResult struct {
+	Agents    []AgentResult // in the order of FanOut.Agents
+	Synthesis Response
+	Usage     Usage // summed across every agent and the synthesis
+}
+
+// FanOut sends a task to several specialized agents at once, then has a
+// synthesizer combine what they produced:
+//
+//	review := grail.FanOut{
+//		Agents: []grail.Agent{
+//			{Name: "security", Instructions: "Review this change for security issues."},
+//			{Name: "performance", Instructions: "Review this change for performance issues."},
+//			{Name: "style", Instructions: "Review this change for readability.", Tier: grail.ModelTierFast},
+//		},
+//		Synthesizer: grail.Agent{Instructions: "Merge these reviews into one list, most important first."},
+//	}
+//	result, err := review.Run(ctx, client, grail.InputText(diff))
+//
+// The synthesizer is sent its instructions, the task's inputs, and each
+// agent's text or JSON output labeled with its name. Without instructions
+// it's asked to combine them into one response to the task.
+type FanOut struct {
+	Agents      []Agent
+	Synthesizer Agent
+	// Concurrency bounds the agents running at once (default all of them).
+	Concurrency int
+	// AllowPartial synthesizes from the agents that succeeded when others
+	// fail, as long as one succeeded. By default any failure fails the run.
+	AllowPartial bool
+	// Metadata is set on every request, for usage attribution.
+	Metadata map[string]string
+}
+
+const defaultSynthesis = "Several specialists worked on the task below. Combine their outputs, which follow it, into one response to the task."
+
+// Run runs the agents on inputs and synthesizes their outputs. On error the
+// result holds what completed.
+func (f FanOut) Run(ctx context.Context, c Client, inputs ...Input) (FanOutResult, error) {
+	if len(f.Agents) == 0 {
+		return FanOutResult{}, NewGrailError(InvalidArgument, "fan-out needs at least one agent")
+	}
+	reqs := make([]Request, len(f.Agents))
+	for i, a := range f.Agents {
+		reqs[i] = a.request(inputs, f.Metadata)
+	}
+	concurrency := f.Concurrency
+	if concurrency <= 0 {
+		concurrency = len(reqs)
+	}
+
+	var result FanOutResult
+	var failed []string
+	for i, br := range GenerateBatch(ctx, c, reqs, BatchOptions{Concurrency: concurrency, StopOnError: !f.AllowPartial}) {
+		name := f.Agents[i].Name
+		if name == "" {
+			name = fmt.Sprintf("agent %d", i+1)
+		}
+		result.Agents = append(result.Agents, AgentResult{Agent: name, Response: br.Response, Err: br.Err})
+		result.Usage = result.Usage.Add(br.Response.Usage)
+		if br.Err != nil {
+			failed = append(failed, name)
+		}
+	}
+	if len(failed) > 0 && (!f.AllowPartial || len(failed) == len(f.Agents)) {
+		// Agents canceled because another failed aren't the cause.
+		var first error
+		for _, ar := range result.Agents {
+			if ar.Err != nil && (first == nil || errors.Is(first, context.Canceled) && !errors.Is(ar.Err, context.Canceled)) {
+				first = ar.Err
+			}
+		}
+		return result, NewGrailError(GetErrorCode(first), fmt.Sprintf("fan-out: %s failed: %v", strings.Join(failed, ", "), first)).WithCause(first)
+	}
+
+	synthesizer := f.Synthesizer
+	if synthesizer.Instructions == "" {
+		synthesizer.Instructions = defaultSynthesis
+	}
+	synth := synthesizer.request(inputs, f.Metadata)
+	for _, ar := range result.Agents {
+		if ar.Err != nil {
+			continue
+		}
+		synth.Inputs = append(synth.Inputs, InputText(fmt.Sprintf("Output of %s:\n\n%s", ar.Agent, outputText(ar.Response))))
+	}
+	res, err := c.Generate(ctx, synth)
+	result.Usage = result.Usage.Add(res.Usage)
+	if err != nil {
+		return result, err
+	}
+	result.Synthesis = res
+	return result, nil
+}
+
+// outputText returns res's text and JSON outputs, for passing them on to
+// another prompt.
+func outputText(res Response) string {
+	var parts []string
+	for _, part := range res.Outputs {
+		switch v := part.(type) {
+		case textOutputPart:
+			parts = append(parts, v.Text)
+		case jsonOutputPart:
+			parts = append(parts, string(v.JSON))
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Stage is a step of a multi-step workflow that turns an I into an O. Stages
+// are usually made with GenerateStage, adapted from Go functions, and
+// composed with Then and Map:
+//
+//	outline := grail.GenerateStage(client, func(topic string) (grail.Request, error) {
+//		return grail.Request{Inputs: []grail.Input{grail.InputText("Outline an article on " + topic)}, Output: grail.OutputJSON(outlineSchema)}, nil
+//	})
+//	draft := grail.GenerateStage(client, func(o Outline) (grail.Request, error) { ... })
+//	article, err := grail.Then(outline, draft)(ctx, "tide pools")
+type Stage[I, O any] func(ctx context.Context, in I) (O, error)
+
+// GenerateStage returns a stage that builds a request from its input,
+// generates, and returns the output as an O: the text for a string, the
+// response itself for a Response, and the decoded JSON output otherwise.
+func GenerateStage[I, O any](c Client, build func(in I) (Request, error)) Stage[I, O] {
+	return func(ctx context.Context, in I) (O, error) {
+		var out O
+		req, err := build(in)
+		if err != nil {
+			return out, err
+		}
+		res, err := c.Generate(ctx, req)
+		if err != nil {
+			return out, err
+		}
+		switch p := any(&out).(type) {
+		case *Response:
+			*p = res
+		case *string:
+			text, ok := res.Text()
+			if !ok {
+				text = outputText(res)
+			}
+			*p = text
+		default:
+			if err := res.DecodeJSON(&out); err != nil {
+				return out, NewGrailError(OutputInvalid, fmt.Sprintf("decode stage output as %T: %v", out, err)).
+					WithCause(err).WithProviderName(res.Provider.Name).WithRequestID(res.RequestID)
+			}
+		}
+		return out, nil
+	}
+}
+
+// Then returns a stage that runs first and passes its output to second.
+func Then[A, B, C any](first Stage[A, B], second Stage[B, C]) Stage[A, C] {
+	return func(ctx context.Context, in A) (C, error) {
+		mid, err := first(ctx, in)
+		if err != nil {
+			var zero C
+			return zero, err
+		}
+		return second(ctx, mid)
+	}
+}
+
+// Map returns a stage that runs stage on every element of its input at once,
+// at most concurrency at a time (all of them if concurrency is zero), and
+// returns the outputs in input order. The first failure cancels the rest and
+// is returned with the failing element's index.
+func Map[I, O any](stage Stage[I, O], concurrency int) Stage[[]I, []O] {
+	return func(ctx context.Context, ins []I) ([]O, error) {
+		if concurrency <= 0 || concurrency > len(ins) {
+			concurrency = max(len(ins), 1)
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		outs := make([]O, len(ins))
+		sem := make(chan struct{}, concurrency)
+		var once sync.Once
+		var firstErr error
+		var wg sync.WaitGroup
+		for i, in := range ins {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			wg.Go(func() {
+				defer func() { <-sem }()
+				out, err := stage(ctx, in)
+				if err != nil {
+					once.Do(func() {
+						firstErr = NewGrailError(GetErrorCode(err), fmt.Sprintf("element %d: %v", i, err)).WithCause(err)
+						cancel()
+					})
+					return
+				}
+				outs[i] = out
+			})
+		}
+		wg.Wait()
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, NewGrailError(Timeout, "map canceled").WithCause(err)
+		}
+		return outs, nil
+	}
+}
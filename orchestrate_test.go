@@ -0,0 +1,124 @@
+package grail_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestFanOut(t *testing.T) {
+	var synthesis []string
+	mp := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			first, _ := grail.AsTextInput(req.Inputs[0])
+			switch {
+			case strings.HasPrefix(first, "Review for"):
+				if strings.Contains(first, "broken") {
+					return grail.Response{}, grail.NewGrailError(grail.Unavailable, "overloaded")
+				}
+				return grail.Response{
+					Outputs: []grail.OutputPart{grail.NewTextOutputPart(strings.TrimPrefix(first, "Review for ") + " looks fine")},
+					Usage:   grail.Usage{InputTokens: 10, OutputTokens: 5, TotalTokens: 15},
+				}, nil
+			default:
+				for _, in := range req.Inputs {
+					text, _ := grail.AsTextInput(in)
+					synthesis = append(synthesis, text)
+				}
+				return grail.Response{
+					Outputs: []grail.OutputPart{grail.NewTextOutputPart("all good")},
+					Usage:   grail.Usage{InputTokens: 30, OutputTokens: 2, TotalTokens: 32},
+				}, nil
+			}
+		},
+	}
+	client := grail.NewClient(mp)
+	ctx := context.Background()
+
+	f := grail.FanOut{
+		Agents: []grail.Agent{
+			{Name: "security", Instructions: "Review for security"},
+			{Name: "style", Instructions: "Review for style"},
+		},
+		Synthesizer: grail.Agent{Instructions: "Merge the reviews."},
+	}
+	result, err := f.Run(ctx, client, grail.InputText("the diff"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text, _ := result.Synthesis.Text(); text != "all good" || len(result.Agents) != 2 || result.Agents[1].Agent != "style" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	want := []string{"Merge the reviews.", "the diff", "Output of security:\n\nsecurity looks fine", "Output of style:\n\nstyle looks fine"}
+	if strings.Join(synthesis, "|") != strings.Join(want, "|") {
+		t.Errorf("unexpected synthesis inputs %q", synthesis)
+	}
+	if result.Usage.TotalTokens != 62 {
+		t.Errorf("expected usage summed across agents and synthesis, got %+v", result.Usage)
+	}
+
+	f.Agents = append(f.Agents, grail.Agent{Name: "flaky", Instructions: "Review for broken things"})
+	if _, err := f.Run(ctx, client, grail.InputText("the diff")); grail.GetErrorCode(err) != grail.Unavailable || !strings.Contains(err.Error(), "flaky") {
+		t.Errorf("expected the failing agent's error, got %v", err)
+	}
+	synthesis = nil
+	f.AllowPartial = true
+	result, err = f.Run(ctx, client, grail.InputText("the diff"))
+	if err != nil || result.Agents[2].Err == nil || len(synthesis) != 4 {
+		t.Errorf("expected a partial synthesis without the failed agent, got %q (%v)", synthesis, err)
+	}
+}
+
+func TestStages(t *testing.T) {
+	var calls atomic.Int32
+	mp := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			calls.Add(1)
+			text, _ := grail.AsTextInput(req.Inputs[0])
+			if _, _, ok := grail.GetJSONOutput(req.Output); ok {
+				return grail.Response{Outputs: []grail.OutputPart{grail.NewJSONOutputPart([]byte(`{"sections":["intro","tides","outro"]}`))}}, nil
+			}
+			if text == "fail" {
+				return grail.Response{}, grail.NewGrailError(grail.Refused, "no")
+			}
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("draft of " + text)}}, nil
+		},
+	}
+	client := grail.NewClient(mp)
+
+	type outline struct {
+		Sections []string `json:"sections"`
+	}
+	plan := grail.GenerateStage[string, outline](client, func(topic string) (grail.Request, error) {
+		return grail.Request{Inputs: []grail.Input{grail.InputText("Outline " + topic)}, Output: grail.OutputJSON(map[string]any{"type": "object"})}, nil
+	})
+	sections := grail.Stage[outline, []string](func(ctx context.Context, o outline) ([]string, error) {
+		return o.Sections, nil
+	})
+	draft := grail.GenerateStage[string, string](client, func(section string) (grail.Request, error) {
+		return grail.Request{Inputs: []grail.Input{grail.InputText(section)}, Output: grail.OutputText()}, nil
+	})
+
+	article := grail.Then(grail.Then(plan, sections), grail.Map(draft, 2))
+	got, err := article(context.Background(), "tide pools")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(got, ", ") != "draft of intro, draft of tides, draft of outro" || calls.Load() != 4 {
+		t.Errorf("unexpected drafts %q after %d calls", got, calls.Load())
+	}
+
+	_, err = grail.Map(draft, 0)(context.Background(), []string{"ok", "fail"})
+	if grail.GetErrorCode(err) != grail.Refused || !strings.Contains(err.Error(), "element 1") {
+		t.Errorf("expected the failing element's error, got %v", err)
+	}
+	var ge grail.GrailError
+	if !errors.As(err, &ge) {
+		t.Errorf("expected a GrailError, got %T", err)
+	}
+}
@@ -0,0 +1,132 @@
+package grail
+
+import (
+	"context"
+	"fmt"
+)
+
+//
+// PDF-to-image fallback
+//
+
+// WarningPDFRasterized is set on a response whose request had PDF inputs
+// sent as page images by WithPDFImageFallback.
+const WarningPDFRasterized = "pdf_rasterized"
+
+// PDFRasterizer renders PDF pages as images, such as pdfraster.Poppler.
+type PDFRasterizer interface {
+	// RasterizePDF returns the first maxPages pages of pdf (all of them if
+	// maxPages is zero) as PNG or JPEG images, in page order.
+	RasterizePDF(ctx context.Context, pdf []byte, maxPages int) ([][]byte, error)
+}
+
+// PDFRasterizerFunc adapts a function to a PDFRasterizer.
+type PDFRasterizerFunc func(ctx context.Context, pdf []byte, maxPages int) ([][]byte, error)
+
+// RasterizePDF implements PDFRasterizer.
+func (f PDFRasterizerFunc) RasterizePDF(ctx context.Context, pdf []byte, maxPages int) ([][]byte, error) {
+	return f(ctx, pdf, maxPages)
+}
+
+// PDFImageFallback configures WithPDFImageFallback.
+type PDFImageFallback struct {
+	Rasterizer PDFRasterizer
+	// MaxPages caps the pages sent per PDF (default 20). Later pages are
+	// left out.
+	MaxPages int
+}
+
+// WithPDFImageFallback sends PDF inputs as images of their pages when the
+// request's model doesn't understand PDFs but understands images (per its
+// ModelCapabilities), or the provider doesn't accept PDFs but accepts
+// images (per its ProviderCapabilities). Each PDF is replaced by a line
+// naming it followed by its pages, rasterized client-side by f.Rasterizer,
+// and the response gets a WarningPDFRasterized warning. Page images keep the
+// PDF's WithDroppable marking. Streamed PDFs (InputFileReader) aren't
+// converted.
+//
+// Models read page images less reliably than PDFs, whose text they get
+// exactly, so prefer a PDF-capable model where one is available.
+func WithPDFImageFallback(f PDFImageFallback) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.pdfImages = &f
+	})
+}
+
+// pdfImagesTarget returns what can't take req's PDFs but can take images:
+// the model or the provider. ok is false if PDFs can be sent, or images
+// can't.
+func (c *client) pdfImagesTarget(req Request) (target string, ok bool) {
+	pdfs, images := true, true
+	if req.Model != "" {
+		if m := c.lookupModel(req.Model); m != nil {
+			pdfs, images = m.Capabilities.PDFUnderstanding, m.Capabilities.ImageUnderstanding
+			target = fmt.Sprintf("model %q", req.Model)
+		}
+	}
+	if caps, ok := c.Capabilities(); ok && pdfs && !caps.AcceptsMIME("application/pdf") {
+		pdfs, target = false, "provider "+c.provider.Name()
+		images = images && caps.AcceptsMIME("image/png")
+	}
+	return target, !pdfs && images
+}
+
+// rasterizePDFs replaces req's PDF inputs with images of their pages, since
+// target can't take PDFs.
+func (c *client) rasterizePDFs(ctx context.Context, req Request, target string) (Request, []Warning, error) {
+	f := c.opts.pdfImages
+	maxPages := f.MaxPages
+	if maxPages <= 0 {
+		maxPages = 20
+	}
+	var inputs []Input
+	var warnings []Warning
+	for i, in := range req.Inputs {
+		fi, ok := in.(fileInput)
+		if !ok || fi.MIME != "application/pdf" {
+			if inputs != nil {
+				inputs = append(inputs, in)
+			}
+			continue
+		}
+		pages, err := f.Rasterizer.RasterizePDF(ctx, fi.Data, maxPages)
+		if err != nil {
+			if ctx.Err() != nil {
+				return req, nil, ctx.Err()
+			}
+			return req, nil, NewGrailError(InvalidArgument, fmt.Sprintf("input %d: rasterize PDF: %v", i, err)).WithCause(err)
+		}
+		if len(pages) > maxPages {
+			pages = pages[:maxPages]
+		}
+		if inputs == nil {
+			inputs = append(make([]Input, 0, len(req.Inputs)+len(pages)), req.Inputs[:i]...)
+		}
+		name := "a PDF document"
+		if fi.Name != "" {
+			name = fmt.Sprintf("the PDF document %q", fi.Name)
+		}
+		inputs = append(inputs, textInput{
+			Text:      fmt.Sprintf("The following %d images are the pages of %s.", len(pages), name),
+			Droppable: fi.Droppable,
+			Priority:  fi.Priority,
+		})
+		for j, page := range pages {
+			inputs = append(inputs, fileInput{
+				Data:            page,
+				MIME:            SniffImageMIME(page),
+				CacheBreakpoint: fi.CacheBreakpoint && j == len(pages)-1,
+				Droppable:       fi.Droppable,
+				Priority:        fi.Priority,
+			})
+		}
+		warnings = append(warnings, Warning{
+			Code:    WarningPDFRasterized,
+			Message: fmt.Sprintf("input %d: PDF sent as %d page images, since %s doesn't take PDFs", i, len(pages), target),
+		})
+	}
+	if inputs != nil {
+		req.Inputs = inputs
+	}
+	return req, warnings, nil
+}
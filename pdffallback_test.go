@@ -0,0 +1,75 @@
+package grail_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestPDFImageFallback(t *testing.T) {
+	var sent []grail.Input
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			sent = req.Inputs
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewImageOutputPart([]byte("img"), "image/png", "")}}, nil
+		},
+	}
+	var rasterized, maxPages int
+	fallback := grail.WithPDFImageFallback(grail.PDFImageFallback{
+		MaxPages: 2,
+		Rasterizer: grail.PDFRasterizerFunc(func(ctx context.Context, pdf []byte, max int) ([][]byte, error) {
+			rasterized++
+			maxPages = max
+			if string(pdf) == "%PDF broken" {
+				return nil, errors.New("syntax error")
+			}
+			png := []byte("\x89PNG\r\n\x1a\n")
+			return [][]byte{append(png, '1'), append(png, '2'), append(png, '3')}, nil
+		}),
+	})
+	ctx := context.Background()
+	req := func(pdf string) grail.Request {
+		return grail.Request{
+			Inputs: []grail.Input{grail.InputText("draw the chart"), grail.InputPDF([]byte(pdf), grail.WithFileName("q3.pdf"))},
+			Output: grail.OutputImage(grail.ImageSpec{}),
+		}
+	}
+
+	// The provider takes images but not PDFs: the PDF goes as its first
+	// pages, after a line naming it.
+	res, err := grail.NewClient(imageOnlyProvider{prov}, fallback).Generate(ctx, req("%PDF"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rasterized != 1 || maxPages != 2 {
+		t.Fatalf("expected 1 rasterization of at most 2 pages, got %d of %d", rasterized, maxPages)
+	}
+	if len(sent) != 4 {
+		t.Fatalf("expected the prompt, a label, and 2 pages, got %d inputs", len(sent))
+	}
+	if label, _ := grail.AsTextInput(sent[1]); label != `The following 2 images are the pages of the PDF document "q3.pdf".` {
+		t.Errorf("unexpected label %q", label)
+	}
+	if data, mime, _, ok := grail.AsFileInput(sent[3]); !ok || mime != "image/png" || data[len(data)-1] != '2' {
+		t.Errorf("unexpected page %q (%s)", data, mime)
+	}
+	if len(res.Warnings) != 1 || res.Warnings[0].Code != grail.WarningPDFRasterized {
+		t.Errorf("unexpected warnings %+v", res.Warnings)
+	}
+
+	if _, err := grail.NewClient(imageOnlyProvider{prov}, fallback).Generate(ctx, req("%PDF broken")); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected a failed rasterization to fail the request, got %v", err)
+	}
+
+	// Providers that take PDFs, or don't say, get them as they are.
+	rasterized = 0
+	if _, err := grail.NewClient(prov, fallback).Generate(ctx, req("%PDF")); err != nil {
+		t.Fatal(err)
+	}
+	if _, mime, _, _ := grail.AsFileInput(sent[1]); rasterized != 0 || mime != "application/pdf" {
+		t.Errorf("expected the PDF to be sent as is")
+	}
+}
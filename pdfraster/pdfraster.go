@@ -0,0 +1,88 @@
+// Package pdfraster renders PDF pages as images for
+// grail.WithPDFImageFallback, so PDFs can be sent to models that only
+// understand images:
+//
+//	client := grail.NewClient(provider, grail.WithPDFImageFallback(grail.PDFImageFallback{
+//		Rasterizer: pdfraster.Poppler{DPI: 150},
+//	}))
+//
+// Poppler runs pdftoppm from poppler-utils (apt install poppler-utils, brew
+// install poppler), so pages are rendered locally rather than by a service.
+package pdfraster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/montanaflynn/grail"
+)
+
+// Poppler is a grail.PDFRasterizer running poppler's pdftoppm, which
+// renders pages as PNGs. The zero value renders at 150 DPI with pdftoppm
+// from PATH.
+type Poppler struct {
+	Path string // the pdftoppm binary (default "pdftoppm")
+	DPI  int    // resolution (default 150); 100 to 200 suits most models
+}
+
+var _ grail.PDFRasterizer = Poppler{}
+
+// RasterizePDF implements grail.PDFRasterizer.
+func (p Poppler) RasterizePDF(ctx context.Context, pdf []byte, maxPages int) ([][]byte, error) {
+	path := p.Path
+	if path == "" {
+		path = "pdftoppm"
+	}
+	dpi := p.DPI
+	if dpi <= 0 {
+		dpi = 150
+	}
+	dir, err := os.MkdirTemp("", "grail-pdfraster-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	args := []string{"-png", "-r", strconv.Itoa(dpi)}
+	if maxPages > 0 {
+		args = append(args, "-l", strconv.Itoa(maxPages))
+	}
+	args = append(args, "-", filepath.Join(dir, "page"))
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdin = bytes.NewReader(pdf)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("pdftoppm: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("pdftoppm: %w", err)
+	}
+
+	// Pages are written as page-1.png, page-2.png, ..., with the numbers
+	// zero-padded to the same width, so they sort in page order.
+	names, err := filepath.Glob(filepath.Join(dir, "page-*.png"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(names)
+	pages := make([][]byte, 0, len(names))
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, data)
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("pdftoppm: no pages rendered")
+	}
+	return pages, nil
+}
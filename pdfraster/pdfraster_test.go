@@ -0,0 +1,63 @@
+package pdfraster_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/pdfraster"
+)
+
+// twoPagePDF returns a PDF with two blank pages.
+func twoPagePDF() []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 72 72] >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 72 72] >>",
+	}
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func TestPoppler(t *testing.T) {
+	if _, err := exec.LookPath("pdftoppm"); err != nil {
+		t.Skip("pdftoppm not installed")
+	}
+	ctx := context.Background()
+	pages, err := pdfraster.Poppler{DPI: 72}.RasterizePDF(ctx, twoPagePDF(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 2 || grail.SniffImageMIME(pages[0]) != "image/png" {
+		t.Fatalf("expected 2 PNG pages, got %d", len(pages))
+	}
+	if pages, err := (pdfraster.Poppler{DPI: 72}).RasterizePDF(ctx, twoPagePDF(), 1); err != nil || len(pages) != 1 {
+		t.Errorf("expected 1 page, got %d (%v)", len(pages), err)
+	}
+	if _, err := (pdfraster.Poppler{}).RasterizePDF(ctx, []byte("not a PDF"), 0); err == nil {
+		t.Error("expected an invalid PDF to fail")
+	}
+}
+
+func TestPoppler_MissingBinary(t *testing.T) {
+	_, err := pdfraster.Poppler{Path: "grail-no-such-pdftoppm"}.RasterizePDF(context.Background(), twoPagePDF(), 0)
+	if err == nil {
+		t.Fatal("expected a missing binary to fail")
+	}
+}
@@ -0,0 +1,286 @@
+package pipelines
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/montanaflynn/grail"
+)
+
+// AskStrategy is how AskDocument presents a document to the model.
+type AskStrategy string
+
+const (
+	// AskAuto picks a strategy from the document's size and type.
+	AskAuto AskStrategy = ""
+	// AskDirect sends the whole document with the question.
+	AskDirect AskStrategy = "direct"
+	// AskRetrieve splits a text document into chunks, ranks them against the
+	// question, and sends only the most relevant ones.
+	AskRetrieve AskStrategy = "retrieve"
+	// AskUpload uploads a file document to the provider (see
+	// grail.FileUploader) and sends it by ID.
+	AskUpload AskStrategy = "upload"
+)
+
+// AskOptions configures AskDocument.
+type AskOptions struct {
+	Strategy    AskStrategy     // default AskAuto
+	Tier        grail.ModelTier // model tier for answering (default: provider default)
+	DirectLimit int             // max characters of text sent directly (default 200000)
+	ChunkSize   int             // characters per chunk when retrieving (default 4000)
+	TopK        int             // chunks sent when retrieving (default 5)
+}
+
+// Answer is the result of AskDocument.
+type Answer struct {
+	Text     string      // the answer
+	Strategy AskStrategy // the strategy that was used
+	Chunks   []string    // retrieved chunks, in ranked order (AskRetrieve only)
+	Cited    []int       // indexes into Chunks that the model cited (AskRetrieve only)
+	FileID   string      // the document's provider file ID (AskUpload only)
+	Usage    grail.Usage
+}
+
+const defaultDirectLimit = 200000
+
+// AskDocument answers a question about doc, which may be a text, PDF, or image
+// input. Text documents larger than opts.DirectLimit are answered from the
+// chunks most relevant to the question. File documents larger than the
+// provider takes inline (its ProviderCapabilities.MaxFileSize, or
+// grail.MaxFileSize) are uploaded with Client.UploadFile and sent by ID,
+// which fails with Unsupported for providers that can't upload; the upload
+// stays on the provider, with its ID in Answer.FileID. Everything else is
+// sent directly. PDF and image documents are checked against the answering
+// model's capabilities first, when the provider can list its models.
+func AskDocument(ctx context.Context, client grail.Client, doc grail.Input, question string, opts AskOptions) (Answer, error) {
+	if opts.DirectLimit <= 0 {
+		opts.DirectLimit = defaultDirectLimit
+	}
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = 4000
+	}
+	if opts.TopK <= 0 {
+		opts.TopK = 5
+	}
+	if strings.TrimSpace(question) == "" {
+		return Answer{}, grail.NewGrailError(grail.InvalidArgument, "question must not be empty")
+	}
+
+	text, isText := grail.AsTextInput(doc)
+	if !isText {
+		if opts.Strategy == AskRetrieve {
+			return Answer{}, grail.NewGrailError(grail.Unsupported, "retrieval requires a text document")
+		}
+		if err := checkDocumentCapability(ctx, client, doc, opts.Tier); err != nil {
+			return Answer{}, err
+		}
+		if opts.Strategy == AskUpload || (opts.Strategy == AskAuto && tooLargeInline(client, doc)) {
+			return askUpload(ctx, client, doc, question, opts)
+		}
+		return askDirect(ctx, client, doc, question, opts)
+	}
+	if opts.Strategy == AskUpload {
+		return Answer{}, grail.NewGrailError(grail.Unsupported, "uploading requires a file document")
+	}
+
+	strategy := opts.Strategy
+	if strategy == AskAuto {
+		strategy = AskDirect
+		if len([]rune(text)) > opts.DirectLimit {
+			strategy = AskRetrieve
+		}
+	}
+	if strategy == AskDirect {
+		return askDirect(ctx, client, doc, question, opts)
+	}
+	return askRetrieve(ctx, client, text, question, opts)
+}
+
+func askDirect(ctx context.Context, client grail.Client, doc grail.Input, question string, o AskOptions) (Answer, error) {
+	res, err := client.Generate(ctx, grail.Request{
+		Inputs: []grail.Input{
+			grail.InputText("Answer the question using only the document below. If the document does not contain the answer, say so."),
+			doc,
+			grail.InputText("Question: " + question),
+		},
+		Output: grail.OutputText(),
+		Tier:   o.Tier,
+	})
+	if err != nil {
+		return Answer{}, err
+	}
+	text, _ := res.Text()
+	return Answer{Text: text, Strategy: AskDirect, Usage: res.Usage}, nil
+}
+
+// tooLargeInline reports whether doc is a file larger than the client's
+// provider accepts inline.
+func tooLargeInline(client grail.Client, doc grail.Input) bool {
+	data, _, _, ok := grail.AsFileInput(doc)
+	if !ok {
+		return false
+	}
+	limit := int64(grail.MaxFileSize)
+	if caps, ok := client.Capabilities(); ok && caps.MaxFileSize > 0 {
+		limit = caps.MaxFileSize
+	}
+	return int64(len(data)) > limit
+}
+
+func askUpload(ctx context.Context, client grail.Client, doc grail.Input, question string, o AskOptions) (Answer, error) {
+	uploaded, err := client.UploadFile(ctx, doc)
+	if err != nil {
+		return Answer{}, err
+	}
+	ans, err := askDirect(ctx, client, uploaded, question, o)
+	ans.FileID, _, _, _ = grail.AsUploadedFileInput(uploaded)
+	if err != nil {
+		return ans, err
+	}
+	ans.Strategy = AskUpload
+	return ans, nil
+}
+
+var askRetrieveSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"answer": map[string]any{"type": "string"},
+		"cited":  map[string]any{"type": "array", "items": map[string]any{"type": "integer"}},
+	},
+	"required": []string{"answer", "cited"},
+}
+
+func askRetrieve(ctx context.Context, client grail.Client, text, question string, o AskOptions) (Answer, error) {
+	chunks := RankChunks(ChunkText(text, o.ChunkSize), question)
+	if len(chunks) > o.TopK {
+		chunks = chunks[:o.TopK]
+	}
+
+	var b strings.Builder
+	b.WriteString("Answer the question using only the numbered excerpts below. If they do not contain the answer, say so. " +
+		"Respond with only a JSON object with the fields answer (string) and cited (array of the excerpt numbers you used).\n")
+	for i, c := range chunks {
+		fmt.Fprintf(&b, "\n[excerpt %d]\n%s\n", i, c)
+	}
+	fmt.Fprintf(&b, "\nQuestion: %s", question)
+
+	res, err := client.Generate(ctx, grail.Request{
+		Inputs: []grail.Input{grail.InputText(b.String())},
+		Output: grail.OutputJSON(askRetrieveSchema),
+		Tier:   o.Tier,
+	})
+	if err != nil {
+		return Answer{}, err
+	}
+	var out struct {
+		Answer string `json:"answer"`
+		Cited  []int  `json:"cited"`
+	}
+	if err := res.DecodeJSON(&out); err != nil {
+		return Answer{}, grail.NewGrailError(grail.OutputInvalid, fmt.Sprintf("decode answer: %v", err)).WithCause(err)
+	}
+	ans := Answer{Text: out.Answer, Strategy: AskRetrieve, Chunks: chunks, Usage: res.Usage}
+	for _, c := range out.Cited {
+		if c >= 0 && c < len(chunks) {
+			ans.Cited = append(ans.Cited, c)
+		}
+	}
+	return ans, nil
+}
+
+// checkDocumentCapability verifies that the text model for tier can read the
+// document's file type. It is a no-op when the provider cannot list models.
+func checkDocumentCapability(ctx context.Context, client grail.Client, doc grail.Input, tier grail.ModelTier) error {
+	data, mime, _, ok := grail.AsFileInput(doc)
+	if !ok {
+		return nil
+	}
+	if mime == "" {
+		mime = grail.SniffImageMIME(data)
+	}
+	if tier == "" {
+		tier = grail.ModelTierBest
+	}
+	model, err := client.GetModel(ctx, grail.ModelRoleText, tier)
+	if err != nil {
+		return nil
+	}
+	switch {
+	case mime == "application/pdf" && !model.Capabilities.PDFUnderstanding:
+		return grail.NewGrailError(grail.Unsupported, fmt.Sprintf("model %q cannot read PDF documents", model.Name))
+	case strings.HasPrefix(mime, "image/") && !model.Capabilities.ImageUnderstanding:
+		return grail.NewGrailError(grail.Unsupported, fmt.Sprintf("model %q cannot read image documents", model.Name))
+	}
+	return nil
+}
+
+// RankChunks orders chunks by lexical relevance to query (TF-IDF over
+// lowercase word tokens), most relevant first. Ties keep document order.
+func RankChunks(chunks []string, query string) []string {
+	terms := tokenize(query)
+	if len(terms) == 0 || len(chunks) == 0 {
+		return chunks
+	}
+
+	tfs := make([]map[string]int, len(chunks))
+	df := map[string]int{}
+	for i, c := range chunks {
+		tf := map[string]int{}
+		for _, tok := range tokenize(c) {
+			tf[tok]++
+		}
+		for tok := range tf {
+			df[tok]++
+		}
+		tfs[i] = tf
+	}
+
+	type scored struct {
+		idx   int
+		score float64
+	}
+	scores := make([]scored, len(chunks))
+	n := float64(len(chunks))
+	for i, tf := range tfs {
+		var s float64
+		for _, term := range terms {
+			if c := tf[term]; c > 0 {
+				s += (1 + math.Log(float64(c))) * math.Log(1+n/float64(df[term]))
+			}
+		}
+		scores[i] = scored{idx: i, score: s}
+	}
+	sort.SliceStable(scores, func(a, b int) bool { return scores[a].score > scores[b].score })
+
+	out := make([]string, len(chunks))
+	for i, s := range scores {
+		out[i] = chunks[s.idx]
+	}
+	return out
+}
+
+var stopwords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "was": true, "were": true,
+	"what": true, "which": true, "who": true, "how": true, "why": true, "when": true,
+	"does": true, "did": true, "this": true, "that": true, "with": true, "from": true,
+	"into": true, "about": true, "has": true, "have": true, "had": true, "not": true,
+	"but": true, "can": true, "its": true, "their": true, "there": true, "they": true,
+}
+
+func tokenize(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	out := fields[:0]
+	for _, f := range fields {
+		if len(f) >= 3 && !stopwords[f] {
+			out = append(out, f)
+		}
+	}
+	return out
+}
@@ -0,0 +1,144 @@
+package pipelines_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/pipelines"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+// listingProvider adds a model catalog to the mock provider.
+type listingProvider struct {
+	*mock.Provider
+	models []grail.Model
+}
+
+func (p listingProvider) ListModels(ctx context.Context) ([]grail.Model, error) {
+	return p.models, nil
+}
+
+// uploadingProvider is a mock provider that uploads files and takes at
+// most 16 bytes inline.
+type uploadingProvider struct {
+	*mock.Provider
+}
+
+func (p *uploadingProvider) UploadFile(ctx context.Context, data []byte, mime, name string) (string, error) {
+	return "file-" + name, nil
+}
+
+func (p *uploadingProvider) Capabilities() grail.ProviderCapabilities {
+	return grail.ProviderCapabilities{TextOutput: true, InputMIMETypes: []string{"application/pdf"}, MaxFileSize: 16}
+}
+
+func TestRankChunks(t *testing.T) {
+	chunks := []string{
+		"The harbor is quiet in winter.",
+		"Bitcoin mining secures the ledger with proof of work.",
+		"Gardens need water and sunlight.",
+	}
+	ranked := pipelines.RankChunks(chunks, "How does proof of work secure bitcoin?")
+	if ranked[0] != chunks[1] {
+		t.Fatalf("expected mining chunk first, got %q", ranked[0])
+	}
+}
+
+func TestAskDocument(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("small text is sent directly", func(t *testing.T) {
+		client := grail.NewClient(&mock.Provider{
+			GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+				if !grail.IsTextOutput(req.Output) {
+					t.Fatalf("expected text output for direct strategy")
+				}
+				return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("42")}}, nil
+			},
+		})
+		ans, err := pipelines.AskDocument(ctx, client, grail.InputText("The answer is 42."), "What is the answer?", pipelines.AskOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ans.Strategy != pipelines.AskDirect || ans.Text != "42" {
+			t.Fatalf("unexpected answer: %+v", ans)
+		}
+	})
+
+	t.Run("large text uses retrieval", func(t *testing.T) {
+		doc := strings.Repeat("Filler paragraph about nothing in particular.\n\n", 50) + "The launch code is zebra.\n\n"
+		var prompt string
+		client := grail.NewClient(&mock.Provider{
+			GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+				prompt, _ = grail.AsTextInput(req.Inputs[0])
+				return grail.Response{Outputs: []grail.OutputPart{
+					grail.NewJSONOutputPart([]byte(`{"answer":"zebra","cited":[0,99]}`)),
+				}}, nil
+			},
+		})
+		ans, err := pipelines.AskDocument(ctx, client, grail.InputText(doc), "What is the launch code?",
+			pipelines.AskOptions{DirectLimit: 500, ChunkSize: 100, TopK: 2})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ans.Strategy != pipelines.AskRetrieve || ans.Text != "zebra" {
+			t.Fatalf("unexpected answer: %+v", ans)
+		}
+		if len(ans.Chunks) != 2 || !strings.Contains(ans.Chunks[0], "zebra") {
+			t.Fatalf("expected relevant chunk ranked first, got %q", ans.Chunks)
+		}
+		if len(ans.Cited) != 1 || !strings.Contains(prompt, "[excerpt 1]") {
+			t.Fatalf("unexpected citations %v or prompt %q", ans.Cited, prompt)
+		}
+	})
+
+	t.Run("large file is uploaded", func(t *testing.T) {
+		var sent grail.Input
+		prov := &uploadingProvider{Provider: &mock.Provider{
+			GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+				sent = req.Inputs[1]
+				return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("Q3")}}, nil
+			},
+		}}
+		client := grail.NewClient(prov)
+		pdf := grail.InputPDF([]byte("%PDF-1.4 quarterly report"), grail.WithFileName("report.pdf"))
+		ans, err := pipelines.AskDocument(ctx, client, pdf, "Which quarter?", pipelines.AskOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if id, _, _, ok := grail.AsUploadedFileInput(sent); !ok || ans.Strategy != pipelines.AskUpload || ans.FileID != id || id != "file-report.pdf" {
+			t.Fatalf("expected the PDF sent by file ID, got %+v", ans)
+		}
+
+		// Files within the limit are sent directly unless asked otherwise.
+		small := grail.InputPDF([]byte("%PDF"))
+		if ans, err := pipelines.AskDocument(ctx, client, small, "Which quarter?", pipelines.AskOptions{}); err != nil || ans.Strategy != pipelines.AskDirect {
+			t.Fatalf("expected a small PDF sent directly, got %+v (%v)", ans, err)
+		}
+		if ans, err := pipelines.AskDocument(ctx, client, small, "Which quarter?", pipelines.AskOptions{Strategy: pipelines.AskUpload}); err != nil || ans.Strategy != pipelines.AskUpload {
+			t.Fatalf("expected an upload when asked, got %+v (%v)", ans, err)
+		}
+		if _, err := pipelines.AskDocument(ctx, grail.NewClient(prov.Provider), pdf, "Which quarter?", pipelines.AskOptions{Strategy: pipelines.AskUpload}); grail.GetErrorCode(err) != grail.Unsupported {
+			t.Fatalf("expected Unsupported without uploads, got %v", err)
+		}
+	})
+
+	t.Run("PDF rejected when model lacks PDF support", func(t *testing.T) {
+		prov := listingProvider{
+			Provider: &mock.Provider{
+				GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+					t.Fatalf("provider should not be called")
+					return grail.Response{}, nil
+				},
+			},
+			models: []grail.Model{{Name: "txt", Role: grail.ModelRoleText, Tier: grail.ModelTierBest,
+				Capabilities: grail.ModelCapabilities{TextGeneration: true}}},
+		}
+		_, err := pipelines.AskDocument(ctx, grail.NewClient(prov), grail.InputPDF([]byte("%PDF-1.4")), "What?", pipelines.AskOptions{})
+		if grail.GetErrorCode(err) != grail.Unsupported {
+			t.Fatalf("expected unsupported, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package pipelines
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultChunkSize is the default maximum number of characters per text chunk.
+const DefaultChunkSize = 12000
+
+// ChunkText splits text into chunks of at most size characters, preferring
+// paragraph, then line, then word boundaries. Paragraphs longer than size are
+// split on the next best boundary; words longer than size are split hard.
+func ChunkText(text string, size int) []string {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+
+	var chunks []string
+	var cur strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			chunks = append(chunks, s)
+		}
+		cur.Reset()
+	}
+	add := func(piece, sep string) {
+		if cur.Len() > 0 && utf8.RuneCountInString(cur.String())+len(sep)+utf8.RuneCountInString(piece) > size {
+			flush()
+		}
+		if cur.Len() > 0 {
+			cur.WriteString(sep)
+		}
+		cur.WriteString(piece)
+	}
+
+	for _, para := range strings.Split(text, "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		if utf8.RuneCountInString(para) <= size {
+			add(para, "\n\n")
+			continue
+		}
+		for _, line := range strings.Split(para, "\n") {
+			if utf8.RuneCountInString(line) <= size {
+				add(line, "\n")
+				continue
+			}
+			for _, word := range strings.Fields(line) {
+				for utf8.RuneCountInString(word) > size {
+					r := []rune(word)
+					add(string(r[:size]), " ")
+					word = string(r[size:])
+				}
+				add(word, " ")
+			}
+		}
+	}
+	flush()
+	return chunks
+}
@@ -0,0 +1,126 @@
+// Package pipelines provides ready-made multi-step workflows built on grail.Client.
+// Each pipeline returns its intermediate results alongside the final output so
+// callers can inspect, log, or cache every step.
+//
+// Example usage:
+//
+//	res, err := pipelines.PDFToInfographic(ctx, client, pdfData, "flat vector, pastel palette")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	fmt.Println(res.KeyPoints.Title)
+//	os.WriteFile("infographic.png", res.Images[0].Data, 0644)
+package pipelines
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/montanaflynn/grail"
+)
+
+// DefaultInfographicStyle is used when PDFToInfographic is called with an empty style.
+const DefaultInfographicStyle = "clean modern flat design with clear sections, simple icons, and a limited color palette"
+
+// KeyPoints is the structured summary extracted from a document.
+type KeyPoints struct {
+	Title   string     `json:"title"`
+	Summary string     `json:"summary"`
+	Points  []KeyPoint `json:"points"`
+}
+
+// KeyPoint is a single section of an infographic.
+type KeyPoint struct {
+	Heading string `json:"heading"`
+	Detail  string `json:"detail"`
+}
+
+// InfographicResult contains the final images and every intermediate step.
+type InfographicResult struct {
+	KeyPoints   KeyPoints               // step 1: extracted key points
+	Extraction  grail.Response          // step 1: raw extraction response
+	ImagePrompt string                  // step 2: prompt sent to the image model
+	Images      []grail.ImageOutputInfo // step 2: generated infographic(s)
+	Generation  grail.Response          // step 2: raw image response
+}
+
+var keyPointsSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"title":   map[string]any{"type": "string"},
+		"summary": map[string]any{"type": "string"},
+		"points": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"heading": map[string]any{"type": "string"},
+					"detail":  map[string]any{"type": "string"},
+				},
+				"required": []string{"heading", "detail"},
+			},
+		},
+	},
+	"required": []string{"title", "summary", "points"},
+}
+
+// PDFToInfographic extracts key points from a PDF as JSON, then generates an
+// infographic image from them in the given style. Both steps use the client's
+// default models for their output type.
+func PDFToInfographic(ctx context.Context, client grail.Client, pdf []byte, style string) (InfographicResult, error) {
+	if strings.TrimSpace(style) == "" {
+		style = DefaultInfographicStyle
+	}
+
+	extraction, err := client.Generate(ctx, grail.Request{
+		Inputs: []grail.Input{
+			grail.InputText("Extract the key points from this document for an infographic. " +
+				"Return a short title, a one-sentence summary, and 4 to 6 points, each with a heading of at most five words " +
+				"and a detail of at most twenty words. Respond with only a JSON object with the fields title, summary, and points " +
+				"(an array of objects with heading and detail)."),
+			grail.InputPDF(pdf, grail.WithFileName("document.pdf")),
+		},
+		Output: grail.OutputJSON(keyPointsSchema),
+	})
+	if err != nil {
+		return InfographicResult{}, err
+	}
+
+	result := InfographicResult{Extraction: extraction}
+	if err := extraction.DecodeJSON(&result.KeyPoints); err != nil {
+		return result, grail.NewGrailError(grail.OutputInvalid, fmt.Sprintf("decode key points: %v", err)).WithCause(err)
+	}
+	if len(result.KeyPoints.Points) == 0 {
+		return result, grail.NewGrailError(grail.OutputInvalid, "no key points extracted from document")
+	}
+
+	result.ImagePrompt = infographicPrompt(result.KeyPoints, style)
+	generation, err := client.Generate(ctx, grail.Request{
+		Inputs: []grail.Input{grail.InputText(result.ImagePrompt)},
+		Output: grail.OutputImage(grail.ImageSpec{Count: 1}),
+	})
+	if err != nil {
+		return result, err
+	}
+	result.Generation = generation
+	result.Images = generation.ImageOutputs()
+	if len(result.Images) == 0 {
+		return result, grail.NewGrailError(grail.OutputInvalid, "no infographic image returned")
+	}
+	return result, nil
+}
+
+func infographicPrompt(kp KeyPoints, style string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Create an infographic titled %q.\n", kp.Title)
+	if kp.Summary != "" {
+		fmt.Fprintf(&b, "Subtitle: %s\n", kp.Summary)
+	}
+	b.WriteString("Sections (render each heading and its text legibly):\n")
+	for i, p := range kp.Points {
+		fmt.Fprintf(&b, "%d. %s: %s\n", i+1, p.Heading, p.Detail)
+	}
+	fmt.Fprintf(&b, "Visual style: %s.", style)
+	return b.String()
+}
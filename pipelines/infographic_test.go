@@ -0,0 +1,45 @@
+package pipelines_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/pipelines"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestPDFToInfographic(t *testing.T) {
+	var imagePrompt string
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			if _, _, ok := grail.GetJSONOutput(req.Output); ok {
+				return grail.Response{
+					Outputs: []grail.OutputPart{grail.NewJSONOutputPart([]byte(
+						`{"title":"Bitcoin","summary":"Peer-to-peer cash.","points":[{"heading":"No banks","detail":"Payments go directly between parties."}]}`,
+					))},
+				}, nil
+			}
+			imagePrompt, _ = grail.AsTextInput(req.Inputs[0])
+			return grail.Response{
+				Outputs: []grail.OutputPart{grail.NewImageOutputPart([]byte("png"), "image/png", "")},
+			}, nil
+		},
+	}
+	client := grail.NewClient(prov)
+
+	res, err := pipelines.PDFToInfographic(context.Background(), client, []byte("%PDF-1.4"), "watercolor")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.KeyPoints.Title != "Bitcoin" || len(res.KeyPoints.Points) != 1 {
+		t.Fatalf("unexpected key points: %+v", res.KeyPoints)
+	}
+	if len(res.Images) != 1 {
+		t.Fatalf("expected 1 image, got %d", len(res.Images))
+	}
+	if imagePrompt != res.ImagePrompt || !strings.Contains(imagePrompt, "No banks") || !strings.Contains(imagePrompt, "watercolor") {
+		t.Fatalf("unexpected image prompt: %q", imagePrompt)
+	}
+}
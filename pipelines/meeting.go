@@ -0,0 +1,300 @@
+package pipelines
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/montanaflynn/grail"
+)
+
+// MeetingOptions configures Diarize and SummarizeMeeting.
+type MeetingOptions struct {
+	// Attendees are the names of people expected in the recording, which
+	// helps attribute speakers who don't introduce themselves.
+	Attendees []string
+	Language  string          // language of the notes, e.g. "German" (default: the meeting's language)
+	Tier      grail.ModelTier // tier for transcription and the final notes (default best)
+	ChunkTier grail.ModelTier // tier for per-chunk notes on long meetings (default fast)
+	ChunkSize int             // max characters of transcript per chunk (default DefaultChunkSize)
+}
+
+// Utterance is a stretch of speech by one speaker.
+type Utterance struct {
+	Speaker string `json:"speaker"` // a name, or "Speaker 1", "Speaker 2", ... when unknown
+	Start   string `json:"start"`   // offset into the recording, "mm:ss" or "h:mm:ss"
+	Text    string `json:"text"`
+}
+
+// Diarization is the result of Diarize.
+type Diarization struct {
+	Utterances []Utterance
+	Speakers   []string // distinct speakers, in order of first appearance
+	Usage      grail.Usage
+}
+
+// MeetingNotes is the result of SummarizeMeeting.
+type MeetingNotes struct {
+	Title       string       `json:"title"`
+	Summary     string       `json:"summary"`
+	Attendees   []Attendee   `json:"attendees"`
+	Decisions   []Decision   `json:"decisions"`
+	ActionItems []ActionItem `json:"action_items"`
+	// Transcript is the diarized recording, with speaker labels replaced by
+	// attendee names where the notes identified them.
+	Transcript []Utterance `json:"-"`
+	Usage      grail.Usage `json:"-"` // summed across every call
+}
+
+// Attendee is a person who spoke in the meeting.
+type Attendee struct {
+	Name    string `json:"name"`    // empty if never identified
+	Speaker string `json:"speaker"` // the speaker label used in the transcript
+}
+
+// Decision is something the meeting agreed on.
+type Decision struct {
+	Decision string `json:"decision"`
+	Time     string `json:"time"` // when it was made in the recording; empty if unclear
+}
+
+// ActionItem is a task someone took on.
+type ActionItem struct {
+	Task  string `json:"task"`
+	Owner string `json:"owner"` // an attendee name or speaker label; empty if unassigned
+	Due   string `json:"due"`   // as stated, e.g. "Friday"; empty if none
+	Time  string `json:"time"`  // when it was assigned in the recording; empty if unclear
+}
+
+var diarizationSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"utterances": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"speaker": map[string]any{"type": "string"},
+					"start":   map[string]any{"type": "string"},
+					"text":    map[string]any{"type": "string"},
+				},
+				"required": []string{"speaker", "start", "text"},
+			},
+		},
+	},
+	"required": []string{"utterances"},
+}
+
+var meetingNotesSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"title":   map[string]any{"type": "string"},
+		"summary": map[string]any{"type": "string"},
+		"attendees": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name":    map[string]any{"type": "string"},
+					"speaker": map[string]any{"type": "string"},
+				},
+				"required": []string{"name", "speaker"},
+			},
+		},
+		"decisions": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"decision": map[string]any{"type": "string"},
+					"time":     map[string]any{"type": "string"},
+				},
+				"required": []string{"decision", "time"},
+			},
+		},
+		"action_items": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"task":  map[string]any{"type": "string"},
+					"owner": map[string]any{"type": "string"},
+					"due":   map[string]any{"type": "string"},
+					"time":  map[string]any{"type": "string"},
+				},
+				"required": []string{"task", "owner", "due", "time"},
+			},
+		},
+	},
+	"required": []string{"title", "summary", "attendees", "decisions", "action_items"},
+}
+
+// Diarize transcribes audio, an audio or video file input, labeling who
+// spoke when. Providers that don't accept audio fail the request with
+// Unsupported (Gemini does; see grail.ProviderCapabilities).
+func Diarize(ctx context.Context, client grail.Client, audio grail.Input, opts ...MeetingOptions) (Diarization, error) {
+	o := meetingDefaults(opts)
+	if err := checkAudio(audio); err != nil {
+		return Diarization{}, err
+	}
+	prompt := "Transcribe this recording verbatim, splitting it into utterances each time the speaker changes. " +
+		"Label speakers by name when they are named or introduce themselves, and otherwise as Speaker 1, Speaker 2, and so on, " +
+		"using the same label for the same voice throughout. Give each utterance's start as mm:ss, or h:mm:ss past an hour. "
+	if len(o.Attendees) > 0 {
+		prompt += "The expected attendees are: " + strings.Join(o.Attendees, ", ") + ". "
+	}
+	prompt += "Respond with only a JSON object with the field utterances, each with speaker, start, and text."
+
+	res, err := client.Generate(ctx, grail.Request{
+		Inputs: []grail.Input{grail.InputText(prompt), audio},
+		Output: grail.OutputJSON(diarizationSchema),
+		Tier:   o.Tier,
+	})
+	if err != nil {
+		return Diarization{}, err
+	}
+	d := Diarization{Usage: res.Usage}
+	var out struct {
+		Utterances []Utterance `json:"utterances"`
+	}
+	if err := res.DecodeJSON(&out); err != nil {
+		return d, grail.NewGrailError(grail.OutputInvalid, fmt.Sprintf("decode transcript: %v", err)).WithCause(err)
+	}
+	seen := map[string]bool{}
+	for _, u := range out.Utterances {
+		if strings.TrimSpace(u.Text) == "" {
+			continue
+		}
+		d.Utterances = append(d.Utterances, u)
+		if !seen[u.Speaker] {
+			seen[u.Speaker] = true
+			d.Speakers = append(d.Speakers, u.Speaker)
+		}
+	}
+	return d, nil
+}
+
+// SummarizeMeeting produces structured notes from a meeting recording: it
+// diarizes audio, then extracts attendees, decisions, and action items from
+// the transcript. Transcripts longer than opts.ChunkSize are split into
+// chunks that are noted separately with the fast tier and merged with the
+// best tier, as in Summarize.
+func SummarizeMeeting(ctx context.Context, client grail.Client, audio grail.Input, opts ...MeetingOptions) (MeetingNotes, error) {
+	o := meetingDefaults(opts)
+	d, err := Diarize(ctx, client, audio, o)
+	if err != nil {
+		return MeetingNotes{Usage: d.Usage}, err
+	}
+	notes := MeetingNotes{Transcript: d.Utterances, Usage: d.Usage}
+	if len(d.Utterances) == 0 {
+		return notes, grail.NewGrailError(grail.OutputInvalid, "no speech found in the recording")
+	}
+
+	var transcript strings.Builder
+	for _, u := range d.Utterances {
+		fmt.Fprintf(&transcript, "[%s] %s: %s\n", u.Start, u.Speaker, u.Text)
+	}
+	chunks := ChunkText(transcript.String(), o.ChunkSize)
+
+	var final string
+	if len(chunks) == 1 {
+		final = meetingNotesInstructions(o) + "\n\nTranscript:\n" + chunks[0]
+	} else {
+		var b strings.Builder
+		b.WriteString(meetingNotesInstructions(o))
+		b.WriteString(" The transcript was split into parts that have already been noted; merge their notes, " +
+			"combining duplicates and keeping the speaker labels consistent:\n")
+		for i, chunk := range chunks {
+			res, err := client.Generate(ctx, grail.Request{
+				Inputs: []grail.Input{grail.InputText(fmt.Sprintf("%s\n\nThis is part %d of %d of the transcript:\n%s",
+					meetingNotesInstructions(o), i+1, len(chunks), chunk))},
+				Output: grail.OutputJSON(meetingNotesSchema),
+				Tier:   o.ChunkTier,
+			})
+			if err != nil {
+				return notes, err
+			}
+			notes.Usage = notes.Usage.Add(res.Usage)
+			var partial json.RawMessage
+			if err := res.DecodeJSON(&partial); err != nil {
+				return notes, grail.NewGrailError(grail.OutputInvalid, fmt.Sprintf("decode notes for part %d: %v", i+1, err)).WithCause(err)
+			}
+			fmt.Fprintf(&b, "\n[part %d]\n%s\n", i+1, partial)
+		}
+		final = b.String()
+	}
+
+	res, err := client.Generate(ctx, grail.Request{
+		Inputs: []grail.Input{grail.InputText(final)},
+		Output: grail.OutputJSON(meetingNotesSchema),
+		Tier:   o.Tier,
+	})
+	if err != nil {
+		return notes, err
+	}
+	notes.Usage = notes.Usage.Add(res.Usage)
+	if err := res.DecodeJSON(&notes); err != nil {
+		return notes, grail.NewGrailError(grail.OutputInvalid, fmt.Sprintf("decode meeting notes: %v", err)).WithCause(err)
+	}
+
+	names := map[string]string{}
+	for _, a := range notes.Attendees {
+		if a.Name != "" && a.Speaker != "" {
+			names[a.Speaker] = a.Name
+		}
+	}
+	for i, u := range notes.Transcript {
+		if name, ok := names[u.Speaker]; ok {
+			notes.Transcript[i].Speaker = name
+		}
+	}
+	for i, item := range notes.ActionItems {
+		if name, ok := names[item.Owner]; ok {
+			notes.ActionItems[i].Owner = name
+		}
+	}
+	return notes, nil
+}
+
+func meetingDefaults(opts []MeetingOptions) MeetingOptions {
+	var o MeetingOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Tier == "" {
+		o.Tier = grail.ModelTierBest
+	}
+	if o.ChunkTier == "" {
+		o.ChunkTier = grail.ModelTierFast
+	}
+	return o
+}
+
+// checkAudio rejects inputs that aren't audio or video files.
+func checkAudio(in grail.Input) error {
+	_, mime, _, ok := grail.AsFileInput(in)
+	if !ok {
+		_, _, mime, _, ok = grail.AsFileReaderInput(in)
+	}
+	if !ok || !(strings.HasPrefix(mime, "audio/") || strings.HasPrefix(mime, "video/")) {
+		return grail.NewGrailError(grail.InvalidArgument, "recording must be an audio or video file input")
+	}
+	return nil
+}
+
+func meetingNotesInstructions(o MeetingOptions) string {
+	s := "Write notes on this meeting transcript, where each line is [time] speaker: words. " +
+		"Give a short title and a summary of one paragraph. " +
+		"List the attendees who spoke, each with their name if the transcript reveals it (or an empty string) and their speaker label. " +
+		"List the decisions the meeting made and the action items people took on, each with its owner's speaker label " +
+		"(or an empty string if unassigned), any due date as stated, and the time it came up. " +
+		"Only include decisions and action items that were actually agreed, not suggestions."
+	if len(o.Attendees) > 0 {
+		s += " The expected attendees are: " + strings.Join(o.Attendees, ", ") + "."
+	}
+	if o.Language != "" {
+		s += fmt.Sprintf(" Write the title, summary, decisions, and tasks in %s.", o.Language)
+	}
+	return s + " Respond with only a JSON object with the fields title, summary, attendees, decisions, and action_items."
+}
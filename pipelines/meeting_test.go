@@ -0,0 +1,67 @@
+package pipelines_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/pipelines"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestSummarizeMeeting(t *testing.T) {
+	transcript := `{"utterances":[
+		{"speaker":"Speaker 1","start":"00:03","text":"Let's ship the beta on Monday."},
+		{"speaker":"Speaker 2","start":"00:09","text":"Agreed. I'll write the release notes by Friday."},
+		{"speaker":"Speaker 1","start":"00:15","text":""}]}`
+	notes := `{"title":"Beta launch","summary":"The team agreed to ship the beta.",
+		"attendees":[{"name":"Priya","speaker":"Speaker 1"},{"name":"","speaker":"Speaker 2"}],
+		"decisions":[{"decision":"Ship the beta on Monday","time":"00:03"}],
+		"action_items":[{"task":"Write release notes","owner":"Speaker 2","due":"Friday","time":"00:09"},
+			{"task":"Announce the beta","owner":"Speaker 1","due":"","time":"00:03"}]}`
+	var calls []grail.Request
+	prov := &mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		calls = append(calls, req)
+		reply := notes
+		if len(calls) == 1 {
+			reply = transcript
+		}
+		return grail.Response{Outputs: []grail.OutputPart{grail.NewJSONOutputPart([]byte(reply))}}, nil
+	}}
+	client := grail.NewClient(prov)
+	audio := grail.InputFile([]byte("ID3 audio"), "audio/mpeg")
+
+	n, err := pipelines.SummarizeMeeting(context.Background(), client, audio, pipelines.MeetingOptions{Attendees: []string{"Priya"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 || len(calls[0].Inputs) != 2 {
+		t.Fatalf("expected a transcription call with the audio and a notes call, got %d calls", len(calls))
+	}
+	if prompt, _ := grail.AsTextInput(calls[1].Inputs[0]); !strings.Contains(prompt, "[00:09] Speaker 2: Agreed.") {
+		t.Fatalf("transcript missing from notes prompt: %s", prompt)
+	}
+	if len(n.Transcript) != 2 || n.Transcript[0].Speaker != "Priya" || n.Transcript[1].Speaker != "Speaker 2" {
+		t.Fatalf("unexpected transcript %+v", n.Transcript)
+	}
+	if len(n.Decisions) != 1 || n.ActionItems[0].Owner != "Speaker 2" || n.ActionItems[1].Owner != "Priya" {
+		t.Fatalf("unexpected notes %+v", n)
+	}
+
+	// A transcript longer than the chunk size is noted in parts, then merged.
+	calls = nil
+	if _, err := pipelines.SummarizeMeeting(context.Background(), client, audio, pipelines.MeetingOptions{ChunkSize: 60}); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 4 || calls[1].Tier != grail.ModelTierFast || calls[3].Tier != grail.ModelTierBest {
+		t.Fatalf("expected transcription, two parts, and a merge; got %d calls", len(calls))
+	}
+	if prompt, _ := grail.AsTextInput(calls[3].Inputs[0]); !strings.Contains(prompt, "[part 2]") {
+		t.Fatalf("partial notes missing from merge prompt: %s", prompt)
+	}
+
+	if _, err := pipelines.Diarize(context.Background(), client, grail.InputText("hello")); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for a text input, got %v", err)
+	}
+}
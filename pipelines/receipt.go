@@ -0,0 +1,154 @@
+package pipelines
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/montanaflynn/grail"
+)
+
+// Receipt is the structured content of a receipt or invoice. Amounts are in
+// Currency, as printed; fields the document doesn't show are zero.
+type Receipt struct {
+	Kind     string     `json:"kind"` // "receipt" or "invoice"
+	Vendor   Party      `json:"vendor"`
+	Customer Party      `json:"customer"` // the billed party, usually only on invoices
+	Number   string     `json:"number"`   // receipt or invoice number
+	Date     string     `json:"date"`     // YYYY-MM-DD
+	DueDate  string     `json:"due_date"` // YYYY-MM-DD, invoices only
+	Currency string     `json:"currency"` // ISO 4217 code, e.g. "USD"
+	Items    []LineItem `json:"items"`
+	Subtotal float64    `json:"subtotal"`
+	Tax      float64    `json:"tax"`
+	Tip      float64    `json:"tip"`
+	Total    float64    `json:"total"`
+	// Confidence is the model's confidence in the extraction as a whole,
+	// from 0 to 1.
+	Confidence float64 `json:"confidence"`
+}
+
+// Party is a vendor or customer.
+type Party struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	TaxID   string `json:"tax_id"`
+}
+
+// LineItem is one line of a receipt or invoice.
+type LineItem struct {
+	Description string  `json:"description"`
+	Quantity    float64 `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	Amount      float64 `json:"amount"`
+	Confidence  float64 `json:"confidence"` // 0 to 1; low for smudged or ambiguous lines
+}
+
+// ReceiptResult is the outcome of ExtractReceipt.
+type ReceiptResult struct {
+	Receipt Receipt
+	// Warnings are arithmetic inconsistencies between the extracted amounts,
+	// which usually mean a misread line; review those receipts by hand.
+	Warnings []string
+	Response grail.Response
+}
+
+var partySchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"name":    map[string]any{"type": "string"},
+		"address": map[string]any{"type": "string"},
+		"tax_id":  map[string]any{"type": "string"},
+	},
+	"required": []string{"name", "address", "tax_id"},
+}
+
+var receiptSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"kind":     map[string]any{"type": "string", "enum": []string{"receipt", "invoice"}},
+		"vendor":   partySchema,
+		"customer": partySchema,
+		"number":   map[string]any{"type": "string"},
+		"date":     map[string]any{"type": "string"},
+		"due_date": map[string]any{"type": "string"},
+		"currency": map[string]any{"type": "string"},
+		"items": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"description": map[string]any{"type": "string"},
+					"quantity":    map[string]any{"type": "number"},
+					"unit_price":  map[string]any{"type": "number"},
+					"amount":      map[string]any{"type": "number"},
+					"confidence":  map[string]any{"type": "number"},
+				},
+				"required": []string{"description", "quantity", "unit_price", "amount", "confidence"},
+			},
+		},
+		"subtotal":   map[string]any{"type": "number"},
+		"tax":        map[string]any{"type": "number"},
+		"tip":        map[string]any{"type": "number"},
+		"total":      map[string]any{"type": "number"},
+		"confidence": map[string]any{"type": "number"},
+	},
+	"required": []string{"kind", "vendor", "customer", "number", "date", "due_date", "currency", "items",
+		"subtotal", "tax", "tip", "total", "confidence"},
+}
+
+// ExtractReceipt reads a receipt or invoice from one or more pages, each a
+// PDF or an image, in a single request, and returns its vendor, line items,
+// and totals. The amounts are cross-checked and inconsistencies reported in
+// ReceiptResult.Warnings.
+func ExtractReceipt(ctx context.Context, client grail.Client, pages ...[]byte) (ReceiptResult, error) {
+	if len(pages) == 0 {
+		return ReceiptResult{}, grail.NewGrailError(grail.InvalidArgument, "at least one page is required")
+	}
+	inputs := []grail.Input{grail.InputText("Extract this receipt or invoice; the pages that follow are one document. " +
+		"Copy names, numbers, and descriptions exactly as printed. Give dates as YYYY-MM-DD and the currency as an ISO 4217 code. " +
+		"Amounts are numbers without currency symbols; use 0 for amounts and empty strings for fields the document doesn't show. " +
+		"Give each line item, and the extraction as a whole, a confidence from 0 to 1, lower where the print is unclear. " +
+		"Respond with only a JSON object with the fields kind, vendor, customer, number, date, due_date, currency, items, " +
+		"subtotal, tax, tip, total, and confidence.")}
+	for i, page := range pages {
+		name := fmt.Sprintf("page-%d", i+1)
+		if bytes.HasPrefix(page, []byte("%PDF")) {
+			inputs = append(inputs, grail.InputPDF(page, grail.WithFileName(name+".pdf")))
+		} else {
+			inputs = append(inputs, grail.InputImage(page, grail.WithFileName(name)))
+		}
+	}
+
+	res, err := client.Generate(ctx, grail.Request{Inputs: inputs, Output: grail.OutputJSON(receiptSchema)})
+	if err != nil {
+		return ReceiptResult{}, err
+	}
+	result := ReceiptResult{Response: res}
+	if err := res.DecodeJSON(&result.Receipt); err != nil {
+		return result, grail.NewGrailError(grail.OutputInvalid, fmt.Sprintf("decode receipt: %v", err)).WithCause(err)
+	}
+	result.Warnings = checkReceipt(result.Receipt)
+	return result, nil
+}
+
+// checkReceipt reports amounts that don't add up, allowing for rounding.
+func checkReceipt(r Receipt) []string {
+	var warnings []string
+	mismatch := func(a, b float64) bool { return math.Abs(a-b) > 0.01*float64(len(r.Items)+1) }
+	var sum float64
+	for i, item := range r.Items {
+		sum += item.Amount
+		if item.Quantity != 0 && item.UnitPrice != 0 && math.Abs(item.Quantity*item.UnitPrice-item.Amount) > 0.01 {
+			warnings = append(warnings, fmt.Sprintf("item %d (%s): %g × %.2f is not %.2f", i+1, item.Description, item.Quantity, item.UnitPrice, item.Amount))
+		}
+	}
+	if len(r.Items) > 0 && r.Subtotal != 0 && mismatch(sum, r.Subtotal) {
+		warnings = append(warnings, fmt.Sprintf("items sum to %.2f, not the subtotal %.2f", sum, r.Subtotal))
+	}
+	if r.Subtotal != 0 && r.Total != 0 && mismatch(r.Subtotal+r.Tax+r.Tip, r.Total) {
+		warnings = append(warnings, fmt.Sprintf("subtotal %.2f + tax %.2f + tip %.2f is not the total %.2f", r.Subtotal, r.Tax, r.Tip, r.Total))
+	}
+	return warnings
+}
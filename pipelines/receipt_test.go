@@ -0,0 +1,54 @@
+package pipelines_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/pipelines"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestExtractReceipt(t *testing.T) {
+	reply := `{"kind":"receipt","vendor":{"name":"Blue Bottle","address":"","tax_id":""},
+		"customer":{"name":"","address":"","tax_id":""},"number":"1042","date":"2026-03-14","due_date":"","currency":"USD",
+		"items":[{"description":"Latte","quantity":2,"unit_price":5.5,"amount":11,"confidence":0.95},
+			{"description":"Croissant","quantity":1,"unit_price":4.25,"amount":4.25,"confidence":0.6}],
+		"subtotal":15.25,"tax":1.37,"tip":3,"total":19.62,"confidence":0.9}`
+	var inputs []grail.Input
+	prov := &mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		inputs = req.Inputs
+		return grail.Response{Outputs: []grail.OutputPart{grail.NewJSONOutputPart([]byte(reply))}}, nil
+	}}
+	client := grail.NewClient(prov)
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	res, err := pipelines.ExtractReceipt(context.Background(), client, []byte("%PDF-1.4 page one"), png)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) != 3 {
+		t.Fatalf("expected instructions and two pages, got %d inputs", len(inputs))
+	}
+	r := res.Receipt
+	if r.Vendor.Name != "Blue Bottle" || len(r.Items) != 2 || r.Items[1].Confidence != 0.6 || r.Total != 19.62 {
+		t.Fatalf("unexpected receipt %+v", r)
+	}
+	if len(res.Warnings) != 0 {
+		t.Fatalf("unexpected warnings %v", res.Warnings)
+	}
+
+	reply = strings.Replace(reply, `"total":19.62`, `"total":29.62`, 1)
+	res, err = pipelines.ExtractReceipt(context.Background(), client, png)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Warnings) != 1 || !strings.Contains(res.Warnings[0], "29.62") {
+		t.Fatalf("expected a total mismatch warning, got %v", res.Warnings)
+	}
+
+	if _, err := pipelines.ExtractReceipt(context.Background(), client); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Fatalf("expected InvalidArgument without pages, got %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+package pipelines
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/montanaflynn/grail"
+)
+
+// SummaryLength controls how long the final summary is.
+type SummaryLength string
+
+const (
+	SummaryShort  SummaryLength = "short"  // two or three sentences
+	SummaryMedium SummaryLength = "medium" // one paragraph
+	SummaryLong   SummaryLength = "long"   // several paragraphs
+)
+
+// SummaryOptions configures Summarize.
+type SummaryOptions struct {
+	Length    SummaryLength   // default SummaryMedium
+	Style     string          // optional free-form style, e.g. "executive brief" or "bullet points"
+	Tier      grail.ModelTier // tier for the final summary (default best)
+	ChunkTier grail.ModelTier // tier for per-chunk summaries (default fast)
+	ChunkSize int             // max characters per text chunk (default DefaultChunkSize)
+}
+
+// Summary is the structured result of Summarize.
+type Summary struct {
+	Text   string         // the final summary
+	Points []SummaryPoint // key points with references into Chunks
+	Chunks []ChunkSummary // the source chunks and their intermediate summaries
+	Usage  grail.Usage    // summed across every call
+}
+
+// SummaryPoint is a key point and the chunks that support it.
+type SummaryPoint struct {
+	Point  string `json:"point"`
+	Chunks []int  `json:"chunks"` // indexes into Summary.Chunks
+}
+
+// ChunkSummary is one source chunk and, when the input needed chunking, its
+// intermediate summary.
+type ChunkSummary struct {
+	Index   int
+	Source  grail.Input // the text chunk or file input that was summarized
+	Summary string      // empty when the input fit in a single chunk
+	Points  []string
+}
+
+var chunkSummarySchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"summary": map[string]any{"type": "string"},
+		"points":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+	},
+	"required": []string{"summary", "points"},
+}
+
+var finalSummarySchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"summary": map[string]any{"type": "string"},
+		"points": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"point":  map[string]any{"type": "string"},
+					"chunks": map[string]any{"type": "array", "items": map[string]any{"type": "integer"}},
+				},
+				"required": []string{"point", "chunks"},
+			},
+		},
+	},
+	"required": []string{"summary", "points"},
+}
+
+// Summarize produces a structured summary of inputs. Text inputs are split into
+// chunks of at most opts.ChunkSize characters and file inputs (PDFs, images)
+// each form their own chunk. When there is more than one chunk, every chunk is
+// summarized with the fast tier and the partial summaries are combined with the
+// best tier; otherwise the input is summarized in a single best-tier call.
+// Chunks are summarized sequentially.
+func Summarize(ctx context.Context, client grail.Client, inputs []grail.Input, opts SummaryOptions) (Summary, error) {
+	if opts.Length == "" {
+		opts.Length = SummaryMedium
+	}
+	if opts.Tier == "" {
+		opts.Tier = grail.ModelTierBest
+	}
+	if opts.ChunkTier == "" {
+		opts.ChunkTier = grail.ModelTierFast
+	}
+
+	sources := chunkInputs(inputs, opts.ChunkSize)
+	if len(sources) == 0 {
+		return Summary{}, grail.NewGrailError(grail.InvalidArgument, "inputs must not be empty")
+	}
+
+	var out Summary
+	for i, src := range sources {
+		out.Chunks = append(out.Chunks, ChunkSummary{Index: i, Source: src})
+	}
+
+	var finalInputs []grail.Input
+	if len(sources) == 1 {
+		finalInputs = []grail.Input{
+			grail.InputText(finalSummaryInstructions(opts) + " Cite the document as chunk 0."),
+			sources[0],
+		}
+	} else {
+		for i := range out.Chunks {
+			res, err := client.Generate(ctx, grail.Request{
+				Inputs: []grail.Input{
+					grail.InputText(fmt.Sprintf("This is part %d of %d of a larger document. Summarize it in a few sentences "+
+						"and list its most important points. Respond with only a JSON object with the fields summary (string) "+
+						"and points (array of strings).", i+1, len(sources))),
+					out.Chunks[i].Source,
+				},
+				Output: grail.OutputJSON(chunkSummarySchema),
+				Tier:   opts.ChunkTier,
+			})
+			if err != nil {
+				return out, err
+			}
+			out.Usage = out.Usage.Add(res.Usage)
+			var partial struct {
+				Summary string   `json:"summary"`
+				Points  []string `json:"points"`
+			}
+			if err := res.DecodeJSON(&partial); err != nil {
+				return out, grail.NewGrailError(grail.OutputInvalid, fmt.Sprintf("decode summary of chunk %d: %v", i, err)).WithCause(err)
+			}
+			out.Chunks[i].Summary = partial.Summary
+			out.Chunks[i].Points = partial.Points
+		}
+
+		var b strings.Builder
+		b.WriteString(finalSummaryInstructions(opts))
+		b.WriteString(" The document was split into chunks that have already been summarized:\n")
+		for _, c := range out.Chunks {
+			fmt.Fprintf(&b, "\n[chunk %d]\n%s\n", c.Index, c.Summary)
+			for _, p := range c.Points {
+				fmt.Fprintf(&b, "- %s\n", p)
+			}
+		}
+		finalInputs = []grail.Input{grail.InputText(b.String())}
+	}
+
+	res, err := client.Generate(ctx, grail.Request{
+		Inputs: finalInputs,
+		Output: grail.OutputJSON(finalSummarySchema),
+		Tier:   opts.Tier,
+	})
+	if err != nil {
+		return out, err
+	}
+	out.Usage = out.Usage.Add(res.Usage)
+
+	var final struct {
+		Summary string         `json:"summary"`
+		Points  []SummaryPoint `json:"points"`
+	}
+	if err := res.DecodeJSON(&final); err != nil {
+		return out, grail.NewGrailError(grail.OutputInvalid, fmt.Sprintf("decode final summary: %v", err)).WithCause(err)
+	}
+	out.Text = final.Summary
+	for _, p := range final.Points {
+		// Drop references the model invented.
+		refs := p.Chunks[:0]
+		for _, c := range p.Chunks {
+			if c >= 0 && c < len(out.Chunks) {
+				refs = append(refs, c)
+			}
+		}
+		p.Chunks = refs
+		out.Points = append(out.Points, p)
+	}
+	return out, nil
+}
+
+func finalSummaryInstructions(opts SummaryOptions) string {
+	var length string
+	switch opts.Length {
+	case SummaryShort:
+		length = "two or three sentences"
+	case SummaryLong:
+		length = "several paragraphs"
+	default:
+		length = "one paragraph"
+	}
+	s := fmt.Sprintf("Write a summary of the document that is %s long", length)
+	if opts.Style != "" {
+		s += fmt.Sprintf(", in the style: %s", opts.Style)
+	}
+	return s + ". Also list the key points, each with the chunk numbers that support it. " +
+		"Respond with only a JSON object with the fields summary (string) and points " +
+		"(array of objects with point (string) and chunks (array of integers))."
+}
+
+// chunkInputs groups consecutive text inputs and splits them into chunks; each
+// non-text input becomes a chunk of its own.
+func chunkInputs(inputs []grail.Input, size int) []grail.Input {
+	var out []grail.Input
+	var text []string
+	flushText := func() {
+		for _, c := range ChunkText(strings.Join(text, "\n\n"), size) {
+			out = append(out, grail.InputText(c))
+		}
+		text = nil
+	}
+	for _, in := range inputs {
+		if t, ok := grail.AsTextInput(in); ok {
+			text = append(text, t)
+			continue
+		}
+		flushText()
+		out = append(out, in)
+	}
+	flushText()
+	return out
+}
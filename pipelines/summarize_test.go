@@ -0,0 +1,78 @@
+package pipelines_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/pipelines"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestChunkText(t *testing.T) {
+	text := strings.Repeat("alpha beta gamma. ", 20) + "\n\n" + strings.Repeat("delta ", 10)
+	chunks := pipelines.ChunkText(text, 100)
+	if len(chunks) < 2 {
+		t.Fatalf("expected multiple chunks, got %d", len(chunks))
+	}
+	for i, c := range chunks {
+		if len([]rune(c)) > 100 {
+			t.Fatalf("chunk %d exceeds size: %d", i, len(c))
+		}
+	}
+	if got := pipelines.ChunkText("short", 100); len(got) != 1 || got[0] != "short" {
+		t.Fatalf("expected single chunk, got %q", got)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	var tiers []grail.ModelTier
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			tiers = append(tiers, req.Tier)
+			prompt, _ := grail.AsTextInput(req.Inputs[0])
+			body := `{"summary":"part","points":["p"]}`
+			if strings.HasPrefix(prompt, "Write a summary") {
+				body = `{"summary":"whole","points":[{"point":"key","chunks":[0,1,7]}]}`
+			}
+			return grail.Response{
+				Outputs: []grail.OutputPart{grail.NewJSONOutputPart([]byte(body))},
+				Usage:   grail.Usage{TotalTokens: 1},
+			}, nil
+		},
+	}
+	client := grail.NewClient(prov)
+
+	inputs := []grail.Input{
+		grail.InputText(strings.Repeat("word ", 50)),
+		grail.InputPDF([]byte("%PDF-1.4")),
+	}
+	sum, err := pipelines.Summarize(context.Background(), client, inputs, pipelines.SummaryOptions{ChunkSize: 100})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sum.Text != "whole" {
+		t.Fatalf("expected final summary, got %q", sum.Text)
+	}
+	if len(sum.Chunks) != 4 {
+		t.Fatalf("expected 3 text chunks plus the PDF, got %d", len(sum.Chunks))
+	}
+	if _, _, _, ok := grail.AsFileInput(sum.Chunks[3].Source); !ok {
+		t.Fatalf("expected PDF to be its own chunk")
+	}
+	if got := sum.Points[0].Chunks; len(got) != 2 {
+		t.Fatalf("expected out-of-range chunk reference to be dropped, got %v", got)
+	}
+	if sum.Usage.TotalTokens != 5 {
+		t.Fatalf("expected usage from 5 calls, got %d", sum.Usage.TotalTokens)
+	}
+	for _, tier := range tiers[:4] {
+		if tier != grail.ModelTierFast {
+			t.Fatalf("expected fast tier for chunks, got %q", tier)
+		}
+	}
+	if tiers[4] != grail.ModelTierBest {
+		t.Fatalf("expected best tier for final summary, got %q", tiers[4])
+	}
+}
@@ -0,0 +1,259 @@
+package pipelines
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/montanaflynn/grail"
+)
+
+// DefaultPagesPerChunk is the default number of PDF pages ExtractTables reads
+// per request.
+const DefaultPagesPerChunk = 5
+
+// TableOptions configures ExtractTables.
+type TableOptions struct {
+	PagesPerChunk int             // PDF pages read per request (default DefaultPagesPerChunk)
+	Tier          grail.ModelTier // model tier for extraction (default: provider default)
+}
+
+// Table is a table extracted from a document. Cells are strings exactly as
+// printed; rows are padded to a common width.
+type Table struct {
+	Title  string // the table's caption or heading, if it has one
+	Header []string
+	Rows   [][]string
+	Pages  []int // the 1-based pages the table appears on, in order
+}
+
+// Records returns the header, if any, followed by the rows.
+func (t Table) Records() [][]string {
+	if len(t.Header) == 0 {
+		return t.Rows
+	}
+	return append([][]string{t.Header}, t.Rows...)
+}
+
+// WriteCSV writes the table's records to w as CSV.
+func (t Table) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(t.Records()); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// CSV returns the table's records as CSV.
+func (t Table) CSV() string {
+	var b strings.Builder
+	_ = t.WriteCSV(&b) // writes to a strings.Builder don't fail
+	return b.String()
+}
+
+// TablesResult is the outcome of ExtractTables.
+type TablesResult struct {
+	Tables    []Table
+	PageCount int // as reported by the model; 1 for images
+	Usage     grail.Usage
+}
+
+var tableChunkSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"page_count": map[string]any{"type": "integer"},
+		"tables": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"title":      map[string]any{"type": "string"},
+					"first_page": map[string]any{"type": "integer"},
+					"last_page":  map[string]any{"type": "integer"},
+					"continues":  map[string]any{"type": "boolean"},
+					"header":     map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+					"rows": map[string]any{
+						"type":  "array",
+						"items": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+					},
+				},
+				"required": []string{"title", "first_page", "last_page", "continues", "header", "rows"},
+			},
+		},
+	},
+	"required": []string{"page_count", "tables"},
+}
+
+// chunkTable is a table as the model reports it for one chunk of pages.
+type chunkTable struct {
+	Title     string     `json:"title"`
+	FirstPage int        `json:"first_page"`
+	LastPage  int        `json:"last_page"`
+	Continues bool       `json:"continues"` // continues a table cut off at the end of the previous page
+	Header    []string   `json:"header"`
+	Rows      [][]string `json:"rows"`
+}
+
+// ExtractTables finds the tables in doc, a PDF or an image, and returns their
+// cells with the pages each appears on. PDFs are read opts.PagesPerChunk pages
+// per request, and tables that run across pages, within a chunk or across
+// chunks, are stitched into one, dropping headers repeated on each page.
+func ExtractTables(ctx context.Context, client grail.Client, doc []byte, opts ...TableOptions) (TablesResult, error) {
+	var o TableOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.PagesPerChunk <= 0 {
+		o.PagesPerChunk = DefaultPagesPerChunk
+	}
+	if len(doc) == 0 {
+		return TablesResult{}, grail.NewGrailError(grail.InvalidArgument, "document must not be empty")
+	}
+
+	var result TablesResult
+	if !bytes.HasPrefix(doc, []byte("%PDF")) {
+		tables, _, err := extractTableChunk(ctx, client, grail.InputImage(doc, grail.WithFileName("page-1")), 1, 1, o, &result.Usage)
+		if err != nil {
+			return result, err
+		}
+		result.PageCount = 1
+		result.Tables = stitchTables(tables)
+		return result, nil
+	}
+
+	input := grail.InputPDF(doc, grail.WithFileName("document.pdf"))
+	var all []chunkTable
+	for first := 1; result.PageCount == 0 || first <= result.PageCount; first += o.PagesPerChunk {
+		last := first + o.PagesPerChunk - 1
+		if result.PageCount > 0 {
+			last = min(last, result.PageCount)
+		}
+		tables, pageCount, err := extractTableChunk(ctx, client, input, first, last, o, &result.Usage)
+		if err != nil {
+			return result, err
+		}
+		all = append(all, tables...)
+		if result.PageCount == 0 {
+			// The first chunk reports how many pages there are; a document
+			// without a count is treated as fitting in that chunk.
+			result.PageCount = pageCount
+			if pageCount <= 0 {
+				result.PageCount = max(lastPage(all), 1)
+			}
+		}
+	}
+	result.Tables = stitchTables(all)
+	return result, nil
+}
+
+func lastPage(tables []chunkTable) int {
+	n := 0
+	for _, t := range tables {
+		n = max(n, t.LastPage)
+	}
+	return n
+}
+
+// extractTableChunk asks for the tables on pages first through last.
+func extractTableChunk(ctx context.Context, client grail.Client, doc grail.Input, first, last int, o TableOptions, usage *grail.Usage) ([]chunkTable, int, error) {
+	pages := fmt.Sprintf("page %d", first)
+	if last > first {
+		pages = fmt.Sprintf("pages %d to %d", first, last)
+	}
+	prompt := fmt.Sprintf("Extract every table on %s of this document, in reading order, ignoring tables on other pages. "+
+		"Copy each cell's text exactly as printed, using an empty string for empty cells; give merged cells' text in the first cell they cover. "+
+		"Put column headings in header, not rows, and leave header empty if the table has none. "+
+		"A table that runs across several of these pages is one table; give its first and last page, and don't repeat headings reprinted on later pages. "+
+		"Set continues to true only if the table's first page is %d and it continues a table cut off at the bottom of the previous page. "+
+		"Give page_count, the number of pages in the whole document. "+
+		"Respond with only a JSON object with the fields page_count and tables, each table with title, first_page, last_page, continues, header, and rows.",
+		pages, first)
+
+	res, err := client.Generate(ctx, grail.Request{
+		Inputs: []grail.Input{grail.InputText(prompt), doc},
+		Output: grail.OutputJSON(tableChunkSchema),
+		Tier:   o.Tier,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	*usage = usage.Add(res.Usage)
+	var out struct {
+		PageCount int          `json:"page_count"`
+		Tables    []chunkTable `json:"tables"`
+	}
+	if err := res.DecodeJSON(&out); err != nil {
+		return nil, 0, grail.NewGrailError(grail.OutputInvalid, fmt.Sprintf("decode tables for %s: %v", pages, err)).WithCause(err)
+	}
+	for i := range out.Tables {
+		t := &out.Tables[i]
+		t.FirstPage = min(max(t.FirstPage, first), last)
+		t.LastPage = min(max(t.LastPage, t.FirstPage), last)
+		t.Continues = t.Continues && t.FirstPage == first && first > 1
+	}
+	return out.Tables, out.PageCount, nil
+}
+
+// stitchTables converts chunk tables to Tables, appending each table that
+// continues from the previous page to the table before it when the columns
+// line up.
+func stitchTables(chunks []chunkTable) []Table {
+	var tables []Table
+	for _, ct := range chunks {
+		rows := ct.Rows
+		if n := len(tables); n > 0 && ct.Continues {
+			prev := &tables[n-1]
+			width := max(len(prev.Header), rowWidth(prev.Rows))
+			if prev.Pages[len(prev.Pages)-1] == ct.FirstPage-1 && (len(ct.Header) == 0 || len(ct.Header) == width) &&
+				(len(rows) == 0 || rowWidth(rows) <= width) {
+				// A header reprinted on the continuation page isn't data; one
+				// that differs was really the first row.
+				if len(ct.Header) > 0 && !slices.Equal(ct.Header, prev.Header) {
+					rows = append([][]string{ct.Header}, rows...)
+				}
+				prev.Rows = append(prev.Rows, padRows(rows, width)...)
+				prev.Pages = appendPages(prev.Pages, ct.FirstPage, ct.LastPage)
+				continue
+			}
+		}
+		width := max(len(ct.Header), rowWidth(rows))
+		tables = append(tables, Table{
+			Title:  ct.Title,
+			Header: ct.Header,
+			Rows:   padRows(rows, width),
+			Pages:  appendPages(nil, ct.FirstPage, ct.LastPage),
+		})
+	}
+	return tables
+}
+
+func rowWidth(rows [][]string) int {
+	n := 0
+	for _, r := range rows {
+		n = max(n, len(r))
+	}
+	return n
+}
+
+func padRows(rows [][]string, width int) [][]string {
+	for i, r := range rows {
+		for len(r) < width {
+			r = append(r, "")
+		}
+		rows[i] = r
+	}
+	return rows
+}
+
+func appendPages(pages []int, first, last int) []int {
+	for p := first; p <= last; p++ {
+		if !slices.Contains(pages, p) {
+			pages = append(pages, p)
+		}
+	}
+	return pages
+}
@@ -0,0 +1,71 @@
+package pipelines_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/pipelines"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestExtractTables_StitchesAcrossChunks(t *testing.T) {
+	replies := map[string]string{
+		"pages 1 to 2": `{"page_count":3,"tables":[
+			{"title":"Fees","first_page":1,"last_page":1,"continues":false,"header":["Item","Cost"],"rows":[["Setup","100"]]},
+			{"title":"Staff","first_page":2,"last_page":2,"continues":false,"header":["Name","Role"],"rows":[["Ada","Lead"],["Lin"]]}]}`,
+		"page 3": `{"page_count":3,"tables":[
+			{"title":"","first_page":3,"last_page":3,"continues":true,"header":["Name","Role"],"rows":[["Sam","Dev"]]}]}`,
+	}
+	var asked []string
+	prov := &mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		prompt, _ := grail.AsTextInput(req.Inputs[0])
+		for pages, reply := range replies {
+			if strings.Contains(prompt, "on "+pages+" of") {
+				asked = append(asked, pages)
+				return grail.Response{Outputs: []grail.OutputPart{grail.NewJSONOutputPart([]byte(reply))}}, nil
+			}
+		}
+		t.Fatalf("unexpected prompt %q", prompt)
+		return grail.Response{}, nil
+	}}
+
+	res, err := pipelines.ExtractTables(context.Background(), grail.NewClient(prov), []byte("%PDF-1.7 three pages"),
+		pipelines.TableOptions{PagesPerChunk: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(asked, []string{"pages 1 to 2", "page 3"}) {
+		t.Fatalf("asked for %v", asked)
+	}
+	if res.PageCount != 3 || len(res.Tables) != 2 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	staff := res.Tables[1]
+	if !slices.Equal(staff.Pages, []int{2, 3}) {
+		t.Fatalf("staff table pages = %v", staff.Pages)
+	}
+	want := "Name,Role\nAda,Lead\nLin,\nSam,Dev\n"
+	if got := staff.CSV(); got != want {
+		t.Fatalf("CSV = %q, want %q", got, want)
+	}
+}
+
+func TestExtractTables_Image(t *testing.T) {
+	calls := 0
+	prov := &mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		calls++
+		reply := `{"page_count":1,"tables":[{"title":"","first_page":1,"last_page":1,"continues":true,"header":[],"rows":[["a","b"]]}]}`
+		return grail.Response{Outputs: []grail.OutputPart{grail.NewJSONOutputPart([]byte(reply))}}, nil
+	}}
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	res, err := pipelines.ExtractTables(context.Background(), grail.NewClient(prov), png)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 || res.PageCount != 1 || len(res.Tables) != 1 || !slices.Equal(res.Tables[0].Records()[0], []string{"a", "b"}) {
+		t.Fatalf("unexpected result %+v after %d calls", res, calls)
+	}
+}
@@ -0,0 +1,267 @@
+package grail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+//
+// Batch plans
+//
+
+// PlanOptions configures PlanBatch.
+type PlanOptions struct {
+	// Prices estimate cost per model. Models without a price are counted as
+	// unpriced.
+	Prices PriceTable
+	// OutputTokens is the expected output of each text or JSON request, in
+	// tokens. Estimates cover input tokens only when it's zero.
+	OutputTokens int
+}
+
+// Plan describes what a batch would do, without doing it: the requests per
+// model with estimated tokens and cost, the files that would be sent, and the
+// requests that would fail before reaching the provider.
+type Plan struct {
+	Requests   int             `json:"requests"`
+	Models     []PlanModel     `json:"models"`
+	Files      []PlanFile      `json:"files,omitempty"`
+	Violations []PlanViolation `json:"violations,omitempty"`
+	Cost       float64         `json:"cost_usd"` // estimated, over priced models
+	Unpriced   int             `json:"unpriced,omitempty"`
+}
+
+// PlanModel is the part of a plan served by one model, or by several for
+// requests that use more than one (OpenAI image generation uses a text model
+// and an image model).
+type PlanModel struct {
+	Model        string  `json:"model"`
+	Requests     int     `json:"requests"`
+	InputTokens  int     `json:"input_tokens"` // estimated
+	OutputTokens int     `json:"output_tokens,omitempty"`
+	Images       int     `json:"images,omitempty"`
+	Cost         float64 `json:"cost_usd"`
+	Priced       bool    `json:"priced"`
+}
+
+// PlanFile is a file a batch would send. Identical files are listed once.
+type PlanFile struct {
+	Name     string `json:"name,omitempty"`
+	MIME     string `json:"mime"`
+	Size     int64  `json:"size"` // -1 if unknown
+	Ref      string `json:"ref,omitempty"`
+	Requests []int  `json:"requests"` // indexes of the requests sending it
+}
+
+// PlanViolation is a request that would fail before reaching the provider:
+// invalid input, an unsupported capability, a size limit, a policy.
+type PlanViolation struct {
+	Index   int       `json:"index"`
+	ID      string    `json:"id,omitempty"` // the request's "id" metadata
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+// planner is implemented by clients that can prepare requests without
+// sending them.
+type planner interface {
+	prepare(ctx context.Context, req Request) (preparedRequest, error)
+	describeModels(req Request) string
+}
+
+// PlanBatch plans running reqs through c with GenerateBatch, applying the
+// client's defaults, model selection, and pre-flight checks to each request
+// without sending anything. Token counts are estimated as the scheduler
+// estimates them. Print the plan with Write before running the batch.
+func PlanBatch(ctx context.Context, c Client, reqs []Request, opts PlanOptions) Plan {
+	pl, _ := c.(planner)
+	plan := Plan{Requests: len(reqs)}
+	models := map[string]*PlanModel{}
+	files := map[string]int{} // index in plan.Files by ref
+	for i, req := range reqs {
+		id := req.Metadata["id"]
+		if pl != nil {
+			p, err := pl.prepare(ctx, req)
+			if err != nil {
+				plan.Violations = append(plan.Violations, PlanViolation{Index: i, ID: id, Code: GetErrorCode(err), Message: err.Error()})
+				continue
+			}
+			req = p.req
+		} else if err := validateRequest(req); err != nil {
+			plan.Violations = append(plan.Violations, PlanViolation{Index: i, ID: id, Code: GetErrorCode(err), Message: err.Error()})
+			continue
+		}
+
+		model := req.Model
+		if pl != nil {
+			model = pl.describeModels(req)
+		}
+		if model == "" {
+			model = "(provider default)"
+		}
+		m := models[model]
+		if m == nil {
+			m = &PlanModel{Model: model}
+			models[model] = m
+		}
+		m.Requests++
+		usage := Usage{InputTokens: estimateTokens(req)}
+		if spec, ok := GetImageSpec(req.Output); ok {
+			m.Images += max(spec.Count, 1)
+		} else {
+			usage.OutputTokens = opts.OutputTokens
+		}
+		m.InputTokens += usage.InputTokens
+		m.OutputTokens += usage.OutputTokens
+		// Usage is recorded against the first model, as UsageTracker does.
+		if price, ok := opts.Prices[strings.Split(model, ",")[0]]; ok {
+			m.Priced = true
+			m.Cost += price.Cost(usage)
+		}
+
+		for _, in := range req.Inputs {
+			var f PlanFile
+			switch v := in.(type) {
+			case fileInput:
+				f = PlanFile{Name: v.Name, MIME: v.MIME, Size: int64(len(v.Data)), Ref: AttachmentRef(v.Data)}
+			case fileReaderInput:
+				f = PlanFile{Name: v.Name, MIME: v.MIME, Size: v.Size}
+			default:
+				continue
+			}
+			if k, ok := files[f.Ref]; ok && f.Ref != "" {
+				if rs := plan.Files[k].Requests; rs[len(rs)-1] != i {
+					plan.Files[k].Requests = append(rs, i)
+				}
+				continue
+			}
+			if f.Ref != "" {
+				files[f.Ref] = len(plan.Files)
+			}
+			f.Requests = []int{i}
+			plan.Files = append(plan.Files, f)
+		}
+	}
+
+	for _, m := range models {
+		plan.Models = append(plan.Models, *m)
+		if m.Priced {
+			plan.Cost += m.Cost
+		} else {
+			plan.Unpriced += m.Requests
+		}
+	}
+	sort.Slice(plan.Models, func(i, j int) bool { return plan.Models[i].Model < plan.Models[j].Model })
+	return plan
+}
+
+func (c *client) describeModels(req Request) string {
+	if describer, ok := c.provider.(ModelDescriber); ok {
+		return describer.DescribeModels(req)
+	}
+	return req.Model
+}
+
+// Write prints the plan for a person to review:
+//
+//	Plan: 3 requests, 1 file, 1 violation.
+//
+//	  MODEL     REQUESTS  INPUT TOKENS  OUTPUT TOKENS  IMAGES  EST. COST
+//	+ gpt-5.4   2         1,204         1,000          0       $0.0123
+//
+//	Files to send:
+//	+ report.pdf (application/pdf, 1.2 MB) in request 0, 1
+//
+//	Violations:
+//	! request 2 (id q3): invalid_argument: at least one input is required
+//
+//	Estimated cost: $0.0123
+func (p Plan) Write(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Plan: %s, %s, %s.\n", plural(p.Requests, "request"), plural(len(p.Files), "file"), plural(len(p.Violations), "violation"))
+
+	if len(p.Models) > 0 {
+		b.WriteString("\n")
+		tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "  MODEL\tREQUESTS\tINPUT TOKENS\tOUTPUT TOKENS\tIMAGES\tEST. COST")
+		for _, m := range p.Models {
+			cost := "unpriced"
+			if m.Priced {
+				cost = fmt.Sprintf("$%.4f", m.Cost)
+			}
+			fmt.Fprintf(tw, "+ %s\t%d\t%s\t%s\t%d\t%s\n", m.Model, m.Requests, thousands(m.InputTokens), thousands(m.OutputTokens), m.Images, cost)
+		}
+		tw.Flush()
+	}
+
+	if len(p.Files) > 0 {
+		b.WriteString("\nFiles to send:\n")
+		for _, f := range p.Files {
+			name := f.Name
+			if name == "" {
+				name = f.Ref
+			}
+			if name == "" {
+				name = "(unnamed)"
+			}
+			size := "unknown size"
+			if f.Size >= 0 {
+				size = byteSize(f.Size)
+			}
+			reqs := make([]string, len(f.Requests))
+			for i, r := range f.Requests {
+				reqs[i] = fmt.Sprint(r)
+			}
+			fmt.Fprintf(&b, "+ %s (%s, %s) in request %s\n", name, f.MIME, size, strings.Join(reqs, ", "))
+		}
+	}
+
+	if len(p.Violations) > 0 {
+		b.WriteString("\nViolations:\n")
+		for _, v := range p.Violations {
+			fmt.Fprintf(&b, "! request %d", v.Index)
+			if v.ID != "" {
+				fmt.Fprintf(&b, " (id %s)", v.ID)
+			}
+			fmt.Fprintf(&b, ": %s\n", v.Message)
+		}
+	}
+
+	fmt.Fprintf(&b, "\nEstimated cost: $%.4f", p.Cost)
+	if p.Unpriced > 0 {
+		fmt.Fprintf(&b, " (%s unpriced)", plural(p.Unpriced, "request"))
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func thousands(n int) string {
+	s := fmt.Sprint(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+func byteSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
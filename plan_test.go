@@ -0,0 +1,63 @@
+package grail_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestPlanBatch(t *testing.T) {
+	prov := &mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		t.Fatal("planning must not call the provider")
+		return grail.Response{}, nil
+	}}
+	client := grail.NewClient(prov, grail.WithDefaultModel("text-1"))
+	report := grail.InputFile([]byte("%PDF-1.4 report"), "application/pdf", grail.WithFileName("report.pdf"))
+	reqs := []grail.Request{
+		{Inputs: []grail.Input{grail.InputText("Summarize."), report}, Output: grail.OutputText()},
+		{Inputs: []grail.Input{grail.InputText("List the risks."), report}, Output: grail.OutputText()},
+		{Output: grail.OutputText(), Metadata: map[string]string{"id": "empty"}},
+		{Inputs: []grail.Input{grail.InputText("A fox")}, Output: grail.OutputImage(grail.ImageSpec{Count: 2}), Model: "image-1"},
+	}
+
+	plan := grail.PlanBatch(context.Background(), client, reqs, grail.PlanOptions{
+		Prices:       grail.PriceTable{"text-1": {InputPerMTok: 1, OutputPerMTok: 10}},
+		OutputTokens: 100,
+	})
+	if plan.Requests != 4 || len(plan.Models) != 2 || plan.Unpriced != 1 {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+	text := plan.Models[1]
+	if text.Model != "text-1" || text.Requests != 2 || text.OutputTokens != 200 || !text.Priced || text.Cost <= 0.002 {
+		t.Fatalf("unexpected text line %+v", text)
+	}
+	if img := plan.Models[0]; img.Model != "image-1" || img.Images != 2 || img.Priced {
+		t.Fatalf("unexpected image line %+v", img)
+	}
+	if len(plan.Files) != 1 || plan.Files[0].Name != "report.pdf" || len(plan.Files[0].Requests) != 2 {
+		t.Fatalf("expected the shared file once, got %+v", plan.Files)
+	}
+	if len(plan.Violations) != 1 || plan.Violations[0].Index != 2 || plan.Violations[0].ID != "empty" ||
+		plan.Violations[0].Code != grail.InvalidArgument {
+		t.Fatalf("unexpected violations %+v", plan.Violations)
+	}
+
+	var b strings.Builder
+	if err := plan.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"Plan: 4 requests, 1 file, 1 violation.",
+		"+ report.pdf (application/pdf, 15 B) in request 0, 1",
+		"! request 2 (id empty): invalid_argument:",
+		"(1 request unpriced)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("plan output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package grail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/montanaflynn/grail/internal/imaging"
+	"golang.org/x/text/unicode/norm"
+)
+
+//
+// Response post-processing
+//
+
+// PostProcessor transforms output parts before Generate returns them. Each
+// field handles one output type; nil fields leave parts of that type alone.
+// The context carries the request's metadata (see MetadataFromContext), so
+// processors can vary by tenant or feature, and processors can attach
+// warnings to the response with AddWarning.
+//
+// An error that is a GrailError (such as Refused) is returned from Generate
+// as is; other errors become OutputInvalid errors.
+type PostProcessor struct {
+	Text  func(ctx context.Context, text string) (string, error)
+	JSON  func(ctx context.Context, data []byte) ([]byte, error)
+	Image func(ctx context.Context, data []byte, mime string) ([]byte, string, error)
+}
+
+// WithPostProcessors runs pp, in order, on every response's output parts.
+// They run after image safety checks and WithImagePostProcessing, and before
+// response size limits and usage tracking.
+func WithPostProcessors(pp ...PostProcessor) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.postProcessors = append(co.postProcessors[:len(co.postProcessors):len(co.postProcessors)], pp...)
+	})
+}
+
+type (
+	warningsKey     struct{}
+	providerInfoKey struct{}
+)
+
+// AddWarning attaches w to the response a PostProcessor is processing. It does
+// nothing when ctx doesn't come from a PostProcessor.
+func AddWarning(ctx context.Context, w Warning) {
+	if ws, ok := ctx.Value(warningsKey{}).(*[]Warning); ok {
+		*ws = append(*ws, w)
+	}
+}
+
+func postProcess(ctx context.Context, res *Response, pp []PostProcessor) error {
+	ctx = context.WithValue(ctx, warningsKey{}, &res.Warnings)
+	ctx = context.WithValue(ctx, providerInfoKey{}, res.Provider)
+	for i, part := range res.Outputs {
+		var err error
+		for _, p := range pp {
+			if part, err = p.apply(ctx, part); err != nil {
+				var ge GrailError
+				if errors.As(err, &ge) {
+					return err
+				}
+				return NewGrailError(OutputInvalid, fmt.Sprintf("output %d: post-processing failed: %v", i, err)).
+					WithCause(err).WithProviderName(res.Provider.Name).WithRequestID(res.RequestID)
+			}
+		}
+		res.Outputs[i] = part
+	}
+	return nil
+}
+
+func (p PostProcessor) apply(ctx context.Context, part OutputPart) (OutputPart, error) {
+	switch v := part.(type) {
+	case textOutputPart:
+		if p.Text != nil {
+			text, err := p.Text(ctx, v.Text)
+			if err != nil {
+				return part, err
+			}
+			v.Text = text
+		}
+		return v, nil
+	case jsonOutputPart:
+		if p.JSON != nil {
+			data, err := p.JSON(ctx, v.JSON)
+			if err != nil {
+				return part, err
+			}
+			v.JSON = data
+		}
+		return v, nil
+	case imageOutputPart:
+		if p.Image != nil {
+			data, mime, err := p.Image(ctx, v.Data, v.MIME)
+			if err != nil {
+				return part, err
+			}
+			v.Data, v.MIME = data, mime
+		}
+		return v, nil
+	}
+	return part, nil
+}
+
+// TrimSpace removes leading and trailing whitespace from text outputs.
+func TrimSpace() PostProcessor {
+	return PostProcessor{Text: func(_ context.Context, text string) (string, error) {
+		return strings.TrimSpace(text), nil
+	}}
+}
+
+// NormalizeUnicode converts text and JSON outputs to Unicode Normalization
+// Form C, so equivalent strings compare and index equal.
+func NormalizeUnicode() PostProcessor {
+	return PostProcessor{
+		Text: func(_ context.Context, text string) (string, error) {
+			return norm.NFC.String(text), nil
+		},
+		JSON: func(_ context.Context, data []byte) ([]byte, error) {
+			return norm.NFC.Bytes(data), nil
+		},
+	}
+}
+
+// ResizeImages downscales image outputs to fit within maxWidth by maxHeight,
+// preserving aspect ratio. Smaller images are left unchanged.
+func ResizeImages(maxWidth, maxHeight int) PostProcessor {
+	return PostProcessor{Image: func(_ context.Context, data []byte, mime string) ([]byte, string, error) {
+		return imaging.Transform(data, imaging.Options{
+			Format:    formatFromMIME(mime),
+			MaxWidth:  maxWidth,
+			MaxHeight: maxHeight,
+		})
+	}}
+}
+
+// StripMarkdown converts Markdown text outputs to plain text: headings,
+// emphasis, links, images, quotes, rules, and code fences lose their markup
+// but keep their text, and "*" or "+" bullets become "-".
+func StripMarkdown() PostProcessor {
+	return PostProcessor{Text: func(_ context.Context, text string) (string, error) {
+		return stripMarkdown(text), nil
+	}}
+}
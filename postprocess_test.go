@@ -0,0 +1,67 @@
+package grail_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestPostProcessors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 64, 32))); err != nil {
+		t.Fatal(err)
+	}
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{Outputs: []grail.OutputPart{
+				grail.NewTextOutputPart("\n# Café\n\nSee **the** [menu](https://example.com) and `code`.  \n"),
+				grail.NewImageOutputPart(buf.Bytes(), "image/png", ""),
+			}}, nil
+		},
+	}
+
+	var tenant string
+	record := grail.PostProcessor{Text: func(ctx context.Context, text string) (string, error) {
+		tenant = grail.MetadataFromContext(ctx)["tenant"]
+		return text, nil
+	}}
+	client := grail.NewClient(prov, grail.WithPostProcessors(
+		grail.StripMarkdown(), grail.TrimSpace(), grail.NormalizeUnicode(), grail.ResizeImages(16, 16), record,
+	))
+	ctx := grail.ContextWithMetadata(context.Background(), "tenant", "acme")
+	res, err := client.Generate(ctx, grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, _ := res.Text(); got != "Café\n\nSee the menu and code." {
+		t.Fatalf("unexpected text %q", got)
+	}
+	imgs, _ := res.Images()
+	cfg, err := png.DecodeConfig(bytes.NewReader(imgs[0]))
+	if err != nil || cfg.Width != 16 || cfg.Height != 8 {
+		t.Fatalf("expected 16x8 image, got %dx%d (%v)", cfg.Width, cfg.Height, err)
+	}
+	if tenant != "acme" {
+		t.Fatalf("expected request metadata in processor context, got tenant %q", tenant)
+	}
+
+	fail := grail.PostProcessor{Text: func(context.Context, string) (string, error) { return "", errors.New("boom") }}
+	_, err = client.With(grail.WithPostProcessors(fail)).Generate(ctx, grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()})
+	if grail.GetErrorCode(err) != grail.OutputInvalid {
+		t.Fatalf("expected OutputInvalid, got %v", err)
+	}
+
+	refuse := grail.PostProcessor{Text: func(context.Context, string) (string, error) {
+		return "", grail.NewGrailError(grail.Refused, "blocked")
+	}}
+	_, err = grail.NewClient(prov, grail.WithPostProcessors(refuse)).Generate(ctx, grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()})
+	if grail.GetErrorCode(err) != grail.Refused {
+		t.Fatalf("expected processor's Refused error to pass through, got %v", err)
+	}
+}
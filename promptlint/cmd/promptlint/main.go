@@ -0,0 +1,48 @@
+// Command promptlint checks prompt template files for common problems:
+// conflicting instructions, excessive length, and empty prompts. It prints
+// one line per finding and exits 1 if there were any.
+//
+// Usage:
+//
+//	go run ./promptlint/cmd/promptlint prompts/*.tmpl
+//	go run ./promptlint/cmd/promptlint -max-chars 8000 -disable length prompts/summary.txt
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/montanaflynn/grail/promptlint"
+)
+
+func main() {
+	maxChars := flag.Int("max-chars", 0, "longest prompt in characters (default 32000)")
+	disable := flag.String("disable", "", "comma-separated rules to skip")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: promptlint [-max-chars N] [-disable rule,...] FILE...")
+		os.Exit(2)
+	}
+
+	opts := promptlint.Options{MaxChars: *maxChars}
+	if *disable != "" {
+		opts.Disable = strings.Split(*disable, ",")
+	}
+	found := false
+	for _, path := range flag.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "promptlint: %v\n", err)
+			os.Exit(2)
+		}
+		for _, f := range promptlint.LintText(string(data), opts) {
+			fmt.Printf("%s: %s\n", path, f)
+			found = true
+		}
+	}
+	if found {
+		os.Exit(1)
+	}
+}
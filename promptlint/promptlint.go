@@ -0,0 +1,213 @@
+// Package promptlint flags common prompt problems before they cost a model
+// call: conflicting instructions, JSON requests without format guidance,
+// excessive length, and template placeholders left unfilled.
+//
+// Lint requests as they're built, or every request a client sends with
+// Middleware:
+//
+//	client := grail.NewClient(provider, grail.WithMiddleware(promptlint.Middleware(promptlint.Options{}, nil)))
+//
+// Prompt template files can be checked with LintText, or from the command
+// line with cmd/promptlint.
+package promptlint
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/montanaflynn/grail"
+)
+
+// Severity is how serious a finding is.
+type Severity string
+
+const (
+	// Warning findings are likely to hurt output quality.
+	Warning Severity = "warning"
+	// Error findings are almost certainly bugs, like an unfilled placeholder.
+	Error Severity = "error"
+)
+
+// Rule names, for Finding.Rule and Options.Disable.
+const (
+	RuleConflict    = "conflict"    // instructions that contradict each other
+	RuleJSONFormat  = "json-format" // JSON output with no schema and no mention of JSON
+	RuleLength      = "length"      // prompt text longer than Options.MaxChars
+	RuleEmptyInput  = "empty-input" // text input with nothing but whitespace
+	RulePlaceholder = "placeholder" // template placeholder sent to the model
+)
+
+// Finding is one problem found in a prompt.
+type Finding struct {
+	Rule     string
+	Severity Severity
+	Message  string
+	Input    int // index of the text input it concerns, -1 for the whole prompt
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s %s: %s", f.Severity, f.Rule, f.Message)
+}
+
+// Options configures linting. The zero value enables every rule with the
+// default length limit.
+type Options struct {
+	// MaxChars is the longest prompt, in characters across text inputs,
+	// before RuleLength reports it (default 32000, about 8000 tokens).
+	MaxChars int
+	// Disable lists rules to skip.
+	Disable []string
+}
+
+func (o Options) enabled(rule string) bool {
+	return !slices.Contains(o.Disable, rule)
+}
+
+func (o Options) maxChars() int {
+	if o.MaxChars > 0 {
+		return o.MaxChars
+	}
+	return 32000
+}
+
+// conflicts are pairs of instructions that can't both be followed.
+var conflicts = []struct {
+	a, b *regexp.Regexp
+}{
+	{
+		regexp.MustCompile(`(?i)\b(be (concise|brief)|briefly|keep it short|in one sentence|short answer)\b`),
+		regexp.MustCompile(`(?i)\b(in (great )?detail|be (thorough|comprehensive)|elaborate on|as long as possible)\b`),
+	},
+	{
+		regexp.MustCompile(`(?i)\b((respond|reply|answer|output) (only )?(in|as|with) json|json only|only (return |output )?json)\b`),
+		regexp.MustCompile(`(?i)\b((use|in|as|format .{0,20}as) markdown|markdown format(ted)?)\b`),
+	},
+	{
+		regexp.MustCompile(`(?i)\buse (bullet points|bullets|a bulleted list)\b`),
+		regexp.MustCompile(`(?i)\b(no|don't use|do not use|avoid|never use|without) (bullet points|bullets|bulleted lists?)\b`),
+	},
+	{
+		regexp.MustCompile(`(?i)\b(formal|professional) (tone|language|register)\b`),
+		regexp.MustCompile(`(?i)\b(casual|informal|playful|conversational) (tone|language|register)\b`),
+	},
+}
+
+var (
+	mentionsJSON = regexp.MustCompile(`(?i)\bjson\b`)
+	placeholder  = regexp.MustCompile(`\{\{[^{}]*\}\}|\$\{\w+\}`)
+)
+
+// Lint checks req's text inputs, as sent: placeholders are reported as errors.
+func Lint(req grail.Request, opts Options) []Finding {
+	var texts []string
+	var idx []int
+	for i, in := range req.Inputs {
+		if text, ok := grail.AsTextInput(in); ok {
+			texts = append(texts, text)
+			idx = append(idx, i)
+		}
+	}
+	findings := lint(texts, idx, opts)
+
+	if opts.enabled(RulePlaceholder) {
+		for k, text := range texts {
+			if m := placeholder.FindString(text); m != "" {
+				findings = append(findings, Finding{Rule: RulePlaceholder, Severity: Error, Input: idx[k],
+					Message: fmt.Sprintf("unfilled template placeholder %s", m)})
+			}
+		}
+	}
+	if schema, _, ok := grail.GetJSONOutput(req.Output); ok && schema == nil && opts.enabled(RuleJSONFormat) &&
+		!slices.ContainsFunc(texts, mentionsJSON.MatchString) {
+		findings = append(findings, Finding{Rule: RuleJSONFormat, Severity: Warning, Input: -1,
+			Message: "JSON output has no schema and the prompt doesn't describe the JSON to return"})
+	}
+	return findings
+}
+
+// LintText checks a prompt template. Placeholders are expected in templates
+// and aren't reported.
+func LintText(text string, opts Options) []Finding {
+	return lint([]string{text}, []int{0}, opts)
+}
+
+func lint(texts []string, idx []int, opts Options) []Finding {
+	var findings []Finding
+	total := 0
+	for k, text := range texts {
+		total += len([]rune(text))
+		if strings.TrimSpace(text) == "" && opts.enabled(RuleEmptyInput) {
+			findings = append(findings, Finding{Rule: RuleEmptyInput, Severity: Warning, Input: idx[k], Message: "text input is empty"})
+		}
+	}
+	if total > opts.maxChars() && opts.enabled(RuleLength) {
+		findings = append(findings, Finding{Rule: RuleLength, Severity: Warning, Input: -1,
+			Message: fmt.Sprintf("prompt is %d characters, over the %d limit", total, opts.maxChars())})
+	}
+	if opts.enabled(RuleConflict) {
+		for _, c := range conflicts {
+			a, ai := firstMatch(c.a, texts)
+			b, bi := firstMatch(c.b, texts)
+			if a == "" || b == "" {
+				continue
+			}
+			input := -1
+			if ai == bi {
+				input = idx[ai]
+			}
+			findings = append(findings, Finding{Rule: RuleConflict, Severity: Warning, Input: input,
+				Message: fmt.Sprintf("conflicting instructions %q and %q", a, b)})
+		}
+	}
+	return findings
+}
+
+func firstMatch(re *regexp.Regexp, texts []string) (string, int) {
+	for i, text := range texts {
+		if m := re.FindString(text); m != "" {
+			return m, i
+		}
+	}
+	return "", -1
+}
+
+// Check lints req and returns an InvalidArgument error listing its
+// error-severity findings, if any.
+func Check(req grail.Request, opts Options) error {
+	return errorFor(Lint(req, opts))
+}
+
+func errorFor(findings []Finding) error {
+	var errs []string
+	for _, f := range findings {
+		if f.Severity == Error {
+			errs = append(errs, f.Rule+": "+f.Message)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return grail.NewGrailError(grail.InvalidArgument, "prompt lint: "+strings.Join(errs, "; "))
+}
+
+// Middleware lints every request the client sends, after defaults are
+// applied. Requests with error-severity findings fail with InvalidArgument
+// before reaching the provider; warnings are passed to onWarning, if set, and
+// the request proceeds.
+func Middleware(opts Options, onWarning func(req grail.Request, findings []Finding)) grail.Middleware {
+	return func(next grail.GenerateFunc) grail.GenerateFunc {
+		return func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			findings := Lint(req, opts)
+			if err := errorFor(findings); err != nil {
+				return grail.Response{}, err
+			}
+			if len(findings) > 0 && onWarning != nil {
+				onWarning(req, findings)
+			}
+			return next(ctx, req)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package promptlint_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/promptlint"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func rules(findings []promptlint.Finding) string {
+	var names []string
+	for _, f := range findings {
+		names = append(names, f.Rule)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name string
+		req  grail.Request
+		want string
+	}{
+		{"clean", grail.Request{Inputs: []grail.Input{grail.InputText("Summarize the report. Be concise.")}, Output: grail.OutputText()}, ""},
+		{"conflict across inputs", grail.Request{Inputs: []grail.Input{
+			grail.InputText("Be concise."), grail.InputText("Explain the tradeoffs in detail."),
+		}, Output: grail.OutputText()}, "conflict"},
+		{"bullets", grail.Request{Inputs: []grail.Input{grail.InputText("Use bullet points. Do not use bullet points.")}, Output: grail.OutputText()}, "conflict"},
+		{"json without guidance", grail.Request{Inputs: []grail.Input{grail.InputText("List the risks.")}, Output: grail.OutputJSON(nil)}, "json-format"},
+		{"json with guidance", grail.Request{Inputs: []grail.Input{grail.InputText(`Return JSON like {"risks": []}.`)}, Output: grail.OutputJSON(nil)}, ""},
+		{"json with schema", grail.Request{Inputs: []grail.Input{grail.InputText("List the risks.")}, Output: grail.OutputJSON(map[string]any{"type": "object"})}, ""},
+		{"placeholder", grail.Request{Inputs: []grail.Input{grail.InputText("Summarize {{.Document}}.")}, Output: grail.OutputText()}, "placeholder"},
+		{"empty", grail.Request{Inputs: []grail.Input{grail.InputText("  ")}, Output: grail.OutputText()}, "empty-input"},
+		{"long", grail.Request{Inputs: []grail.Input{grail.InputText(strings.Repeat("word ", 7000))}, Output: grail.OutputText()}, "length"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules(promptlint.Lint(tt.req, promptlint.Options{})); got != tt.want {
+				t.Fatalf("got rules %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	long := grail.Request{Inputs: []grail.Input{grail.InputText(strings.Repeat("word ", 7000))}, Output: grail.OutputText()}
+	if got := promptlint.Lint(long, promptlint.Options{Disable: []string{promptlint.RuleLength}}); len(got) != 0 {
+		t.Fatalf("disabled rule reported: %v", got)
+	}
+	if got := rules(promptlint.LintText("Hello {{.Name}}, be brief but be thorough.", promptlint.Options{})); got != "conflict" {
+		t.Fatalf("LintText got %q", got)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	calls := 0
+	prov := &mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		calls++
+		return grail.Response{}, nil
+	}}
+	var warned []promptlint.Finding
+	client := grail.NewClient(prov, grail.WithMiddleware(promptlint.Middleware(promptlint.Options{}, func(_ grail.Request, f []promptlint.Finding) {
+		warned = append(warned, f...)
+	})))
+
+	_, err := client.Generate(context.Background(), grail.Request{Inputs: []grail.Input{grail.InputText("Hi ${name}")}, Output: grail.OutputText()})
+	if grail.GetErrorCode(err) != grail.InvalidArgument || calls != 0 {
+		t.Fatalf("expected an unfilled placeholder to fail before the provider, got %v (%d calls)", err, calls)
+	}
+	if _, err := client.Generate(context.Background(), grail.Request{Inputs: []grail.Input{grail.InputText("Be brief, in great detail.")}, Output: grail.OutputText()}); err != nil || calls != 1 {
+		t.Fatalf("expected warnings to let the request through, got %v (%d calls)", err, calls)
+	}
+	if rules(warned) != "conflict" {
+		t.Fatalf("warnings = %v", warned)
+	}
+}
@@ -0,0 +1,149 @@
+package grail
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+//
+// Proofreading
+//
+
+// Warning codes set by proofreading. WarningProofreadIssues lists what the
+// proofreader found; WarningProofreadFailed is set instead when the pass
+// failed and FailOpen returned the text unchecked.
+const (
+	WarningProofreadIssues = "proofread_issues"
+	WarningProofreadFailed = "proofread_failed"
+)
+
+// Proofreading configures a second pass that checks text outputs for
+// grammar, spelling, and style before Generate returns them.
+type Proofreading struct {
+	// StyleGuide is the house style to check against, such as "Use American
+	// spelling. Avoid exclamation marks." Without one, only grammar and
+	// spelling are checked.
+	StyleGuide string
+	// Model proofreads. It defaults to the provider's fast tier.
+	Model string
+	// Correct replaces text outputs with the corrected versions. By
+	// default they're returned as generated, and the corrections are only
+	// reported.
+	Correct bool
+	// FailOpen returns text unchecked (with a warning) when the proofreading
+	// pass fails. By default its error fails the request.
+	FailOpen bool
+}
+
+// ProofreadResult is the proofreading of one text output.
+type ProofreadResult struct {
+	Original  string
+	Corrected string
+	Issues    []string // what was corrected, one per issue
+	Usage     Usage    // the proofreading pass's own usage
+}
+
+// Changed reports whether the proofreader corrected anything.
+func (p ProofreadResult) Changed() bool {
+	return p.Original != p.Corrected
+}
+
+// WithProofreading sends user-facing text outputs through a second, fast
+// model that corrects grammar, spelling, and style against p.StyleGuide.
+// The original and corrected versions are available from
+// Response.Proofread, and responses with corrections get a
+// WarningProofreadIssues warning. Constrained text outputs (OutputText with
+// WithEnum or WithPattern) aren't proofread.
+//
+// Proofreading runs after post-processors, so it checks the final text. Its
+// usage is reported on each ProofreadResult rather than the response, and
+// recorded by WithUsageTracker like any other request.
+func WithProofreading(p Proofreading) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.proofreading = &p
+	})
+}
+
+// Proofread returns the proofreading of each text output, in order, if the
+// client proofreads them (see WithProofreading).
+func (r Response) Proofread() []ProofreadResult {
+	return r.proofread
+}
+
+var proofreadSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"corrected": map[string]any{"type": "string"},
+		"issues":    map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+	},
+	"required":             []string{"corrected", "issues"},
+	"additionalProperties": false,
+}
+
+// proofread runs the proofreading pass over res's text outputs.
+func (c *client) proofread(ctx context.Context, req Request, res *Response, p Proofreading) error {
+	var issues int
+	for i, part := range res.Outputs {
+		tp, ok := part.(textOutputPart)
+		if !ok {
+			continue
+		}
+		result, err := c.proofreadText(ctx, req, tp.Text, p)
+		if err != nil {
+			if !p.FailOpen {
+				return NewGrailError(GetErrorCode(err), fmt.Sprintf("output %d: proofreading failed: %v", i, err)).
+					WithCause(err).WithProviderName(res.Provider.Name).WithRequestID(res.RequestID)
+			}
+			res.Warnings = append(res.Warnings, Warning{Code: WarningProofreadFailed, Message: fmt.Sprintf("output %d: %v", i, err)})
+			continue
+		}
+		res.proofread = append(res.proofread, result)
+		issues += len(result.Issues)
+		if p.Correct {
+			tp.Text = result.Corrected
+			res.Outputs[i] = tp
+		}
+	}
+	if issues > 0 {
+		res.Warnings = append(res.Warnings, Warning{Code: WarningProofreadIssues, Message: fmt.Sprintf("proofreading found %d issues", issues)})
+	}
+	return nil
+}
+
+func (c *client) proofreadText(ctx context.Context, req Request, text string, p Proofreading) (ProofreadResult, error) {
+	var b strings.Builder
+	b.WriteString("Proofread the text below for grammar and spelling")
+	if p.StyleGuide != "" {
+		b.WriteString(", and for compliance with this style guide:\n\n" + p.StyleGuide + "\n\n")
+	} else {
+		b.WriteString(". ")
+	}
+	b.WriteString("Return the text with only the changes needed, keeping its meaning, tone, and formatting, " +
+		"and list each issue you corrected. If there are none, return the text unchanged with no issues.")
+
+	check := Request{
+		Inputs:   []Input{InputText(b.String()), InputText("Text:\n\n" + text)},
+		Output:   OutputJSON(proofreadSchema),
+		Model:    p.Model,
+		Metadata: req.Metadata,
+	}
+	if check.Model == "" {
+		check.Tier = ModelTierFast
+	}
+	res, err := c.generate(ctx, check)
+	if err != nil {
+		return ProofreadResult{}, err
+	}
+	var out struct {
+		Corrected string   `json:"corrected"`
+		Issues    []string `json:"issues"`
+	}
+	if err := res.DecodeJSON(&out); err != nil {
+		return ProofreadResult{}, NewGrailError(OutputInvalid, fmt.Sprintf("decode proofreading: %v", err)).WithCause(err)
+	}
+	if out.Corrected == "" {
+		out.Corrected = text
+	}
+	return ProofreadResult{Original: text, Corrected: out.Corrected, Issues: out.Issues, Usage: res.Usage}, nil
+}
@@ -0,0 +1,87 @@
+package grail_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestProofreading(t *testing.T) {
+	var checks []grail.Request
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			if _, _, ok := grail.GetJSONOutput(req.Output); ok {
+				checks = append(checks, req)
+				return grail.Response{
+					Usage:   grail.Usage{InputTokens: 3},
+					Outputs: []grail.OutputPart{grail.NewJSONOutputPart([]byte(`{"corrected": "Their order has shipped.", "issues": ["there -> their"]}`))},
+				}, nil
+			}
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("There order has shipped!")}}, nil
+		},
+	}
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("Write a shipping notice.")}, Output: grail.OutputText()}
+	guide := grail.Proofreading{StyleGuide: "Avoid exclamation marks."}
+
+	res, err := grail.NewClient(prov, grail.WithProofreading(guide)).Generate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text, _ := res.Text(); text != "There order has shipped!" {
+		t.Errorf("expected the original text without Correct, got %q", text)
+	}
+	results := res.Proofread()
+	if len(results) != 1 || !results[0].Changed() || results[0].Corrected != "Their order has shipped." || results[0].Usage.InputTokens != 3 {
+		t.Fatalf("unexpected proofreading %+v", results)
+	}
+	if len(res.Warnings) != 1 || res.Warnings[0].Code != grail.WarningProofreadIssues {
+		t.Errorf("expected proofread_issues, got %+v", res.Warnings)
+	}
+	if len(checks) != 1 || checks[0].Tier != grail.ModelTierFast {
+		t.Fatalf("expected one fast-tier check, got %+v", checks)
+	}
+	if prompt, _ := grail.AsTextInput(checks[0].Inputs[0]); !strings.Contains(prompt, guide.StyleGuide) {
+		t.Errorf("expected the style guide in the prompt, got %q", prompt)
+	}
+
+	guide.Correct = true
+	res, err = grail.NewClient(prov, grail.WithProofreading(guide)).Generate(context.Background(), req)
+	if text, _ := res.Text(); err != nil || text != "Their order has shipped." {
+		t.Errorf("expected the corrected text, got %q (%v)", text, err)
+	}
+
+	// Constrained answers aren't proofread.
+	checks = nil
+	enum := grail.Request{Inputs: req.Inputs, Output: grail.OutputText(grail.WithEnum("There order has shipped!"))}
+	if _, err := grail.NewClient(prov, grail.WithProofreading(guide)).Generate(context.Background(), enum); err != nil || len(checks) != 0 {
+		t.Errorf("expected no proofreading of an enum answer, got %d checks (%v)", len(checks), err)
+	}
+}
+
+func TestProofreadingFailure(t *testing.T) {
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			if _, _, ok := grail.GetJSONOutput(req.Output); ok {
+				return grail.Response{}, grail.NewGrailError(grail.Unavailable, "overloaded")
+			}
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("Hello.")}}, nil
+		},
+	}
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()}
+
+	_, err := grail.NewClient(prov, grail.WithProofreading(grail.Proofreading{})).Generate(context.Background(), req)
+	if grail.GetErrorCode(err) != grail.Unavailable {
+		t.Errorf("expected the proofreading error, got %v", err)
+	}
+
+	res, err := grail.NewClient(prov, grail.WithProofreading(grail.Proofreading{FailOpen: true})).Generate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text, _ := res.Text(); text != "Hello." || len(res.Warnings) != 1 || res.Warnings[0].Code != grail.WarningProofreadFailed {
+		t.Errorf("expected the unchecked text with a warning, got %q %+v", text, res.Warnings)
+	}
+}
@@ -0,0 +1,392 @@
+// Package fake provides a deterministic grail.Provider that synthesizes
+// plausible outputs without calling any API, for end-to-end tests and demos.
+//
+// Outputs are derived from a hash of the request, so the same request always
+// gets the same response and different requests get different ones:
+//
+//   - text: lorem ipsum sentences, streamed a word at a time
+//   - images: solid-color PNGs (256x256 unless set with ImageOptions)
+//   - JSON: a document conforming to the request's schema
+//
+// Example usage:
+//
+//	client := grail.NewClient(fake.New())
+//	res, _ := client.Generate(ctx, grail.Request{
+//		Inputs: []grail.Input{grail.InputText("Describe a sunset")},
+//		Output: grail.OutputImage(grail.ImageSpec{Count: 2}),
+//		ProviderOptions: []grail.ProviderOption{fake.ImageOptions{Width: 64, Height: 64}},
+//	})
+package fake
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/internal/jsonschema"
+)
+
+var (
+	// TextModel is the model named in text and JSON responses.
+	TextModel = grail.Model{
+		Name: "fake-text",
+		Role: grail.ModelRoleText,
+		Tier: grail.ModelTierBest,
+		Capabilities: grail.ModelCapabilities{
+			TextGeneration:     true,
+			ImageUnderstanding: true,
+			PDFUnderstanding:   true,
+			JSONOutput:         true,
+		},
+	}
+
+	// ImageModel is the model named in image responses.
+	ImageModel = grail.Model{
+		Name: "fake-image",
+		Role: grail.ModelRoleImage,
+		Tier: grail.ModelTierBest,
+		Capabilities: grail.ModelCapabilities{
+			ImageGeneration: true,
+		},
+	}
+)
+
+const (
+	// DefaultImageSize is the width and height of images when no ImageOptions
+	// are given.
+	DefaultImageSize = 256
+
+	// DefaultTextWords is the length of text outputs when no TextOptions are
+	// given.
+	DefaultTextWords = 40
+)
+
+// TextOptions sets the length of synthesized text.
+type TextOptions struct {
+	Words int
+}
+
+func (TextOptions) ApplyProviderOption() {}
+
+// ImageOptions sets the size of synthesized images.
+type ImageOptions struct {
+	Width, Height int
+}
+
+func (ImageOptions) ApplyProviderOption() {}
+
+// Provider synthesizes responses from request hashes. The zero value is ready
+// to use.
+type Provider struct{}
+
+// New returns a fake provider.
+func New() *Provider { return &Provider{} }
+
+// Name returns the provider name.
+func (p *Provider) Name() string { return "fake" }
+
+// Capabilities implements grail.CapabilityReporter.
+func (p *Provider) Capabilities() grail.ProviderCapabilities {
+	return grail.ProviderCapabilities{
+		TextOutput:     true,
+		ImageOutput:    true,
+		JSONOutput:     true,
+		NativeJSON:     true,
+		InputMIMETypes: []string{"image/*", "application/pdf", "text/*", "audio/*", "video/*"},
+		Streaming:      true,
+		ModelListing:   true,
+	}
+}
+
+// ListModels implements grail.ModelLister.
+func (p *Provider) ListModels(_ context.Context) ([]grail.Model, error) {
+	return []grail.Model{TextModel, ImageModel}, nil
+}
+
+// WrapTransport implements grail.TransportAware. The fake provider makes no
+// network requests, so there's nothing to wrap, and it can be used in air-gap
+// mode (see grail.WithAirGap).
+func (p *Provider) WrapTransport(func(http.RoundTripper) http.RoundTripper) {}
+
+// ResolveModel implements grail.ModelResolver. Every tier resolves to the
+// same model.
+func (p *Provider) ResolveModel(role grail.ModelRole, _ grail.ModelTier) (string, error) {
+	if role == grail.ModelRoleImage {
+		return ImageModel.Name, nil
+	}
+	return TextModel.Name, nil
+}
+
+// DoGenerate implements grail.ProviderExecutor.
+func (p *Provider) DoGenerate(ctx context.Context, req grail.Request) (grail.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return grail.Response{}, grail.NewGrailError(grail.Timeout, "fake: request cancelled").WithCause(err).WithProviderName(p.Name())
+	}
+	sum := requestHash(req)
+	rng := rand.New(rand.NewPCG(binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:16])))
+	res := grail.Response{
+		Provider:  grail.ProviderInfo{Name: p.Name()},
+		RequestID: "fake-" + hex.EncodeToString(sum[:6]),
+	}
+	inputTokens := inputWords(req.Inputs)
+
+	switch {
+	case grail.IsTextOutput(req.Output):
+		words := DefaultTextWords
+		for _, opt := range req.ProviderOptions {
+			if to, ok := opt.(TextOptions); ok && to.Words > 0 {
+				words = to.Words
+			}
+		}
+		text := lorem(rng, words)
+		res.Outputs = []grail.OutputPart{grail.NewTextOutputPart(text)}
+		res.Provider.Route = "text"
+		res.Provider.Models = []grail.ModelUse{{Role: "language", Name: modelName(req.Model, TextModel)}}
+		res.Usage = usage(inputTokens, words)
+
+	case isImageOutput(req.Output):
+		spec, _ := grail.GetImageSpec(req.Output)
+		count := max(spec.Count, 1)
+		w, h := DefaultImageSize, DefaultImageSize
+		for _, opt := range req.ProviderOptions {
+			if io, ok := opt.(ImageOptions); ok {
+				if io.Width > 0 {
+					w = io.Width
+				}
+				if io.Height > 0 {
+					h = io.Height
+				}
+			}
+		}
+		for i := range count {
+			data, err := solidPNG(rng, w, h)
+			if err != nil {
+				return grail.Response{}, grail.NewGrailError(grail.Internal, fmt.Sprintf("fake: encode image: %v", err)).WithCause(err).WithProviderName(p.Name())
+			}
+			res.Outputs = append(res.Outputs, grail.NewImageOutputPart(data, "image/png", fmt.Sprintf("fake-%d.png", i+1)))
+		}
+		res.Provider.Route = "images"
+		res.Provider.Models = []grail.ModelUse{{Role: "image_generation", Name: modelName(req.Model, ImageModel)}}
+		res.Usage = usage(inputTokens, 0)
+
+	default:
+		schema, _, ok := grail.GetJSONOutput(req.Output)
+		if !ok {
+			return grail.Response{}, grail.NewGrailError(grail.Unsupported, "fake: unsupported output type").WithProviderName(p.Name())
+		}
+		s, err := jsonschema.Normalize(schema)
+		if err != nil {
+			return grail.Response{}, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("fake: %v", err)).WithCause(err).WithProviderName(p.Name())
+		}
+		if s == nil {
+			s = map[string]any{"type": "object", "properties": map[string]any{"text": map[string]any{"type": "string"}}}
+		}
+		data, err := json.Marshal(synthesize(rng, s, 0))
+		if err != nil {
+			return grail.Response{}, grail.NewGrailError(grail.Internal, fmt.Sprintf("fake: encode JSON: %v", err)).WithCause(err).WithProviderName(p.Name())
+		}
+		res.Outputs = []grail.OutputPart{grail.NewJSONOutputPart(data)}
+		res.Provider.Route = "json"
+		res.Provider.Models = []grail.ModelUse{{Role: "language", Name: modelName(req.Model, TextModel)}}
+		res.Usage = usage(inputTokens, len(data)/4)
+	}
+	return res, nil
+}
+
+// StreamGenerate implements grail.TextStreamer, emitting the text of
+// DoGenerate's response a word at a time.
+func (p *Provider) StreamGenerate(ctx context.Context, req grail.Request, emit func(text string)) (grail.Response, error) {
+	res, err := p.DoGenerate(ctx, req)
+	if err != nil {
+		return res, err
+	}
+	if text, ok := res.Text(); ok {
+		for word := range strings.SplitAfterSeq(text, " ") {
+			emit(word)
+		}
+	}
+	return res, nil
+}
+
+func isImageOutput(out grail.Output) bool {
+	_, ok := grail.GetImageSpec(out)
+	return ok
+}
+
+func modelName(requested string, def grail.Model) string {
+	if requested != "" {
+		return requested
+	}
+	return def.Name
+}
+
+func usage(in, out int) grail.Usage {
+	return grail.Usage{InputTokens: in, OutputTokens: out, TotalTokens: in + out}
+}
+
+// requestHash identifies a request by its model, output, inputs, and options.
+// File readers are identified by name, type, and size, without reading them.
+func requestHash(req grail.Request) [sha256.Size]byte {
+	h := sha256.New()
+	fmt.Fprintf(h, "model=%s tier=%s output=%s\n", req.Model, req.Tier, describe(req.Output))
+	for _, in := range req.Inputs {
+		if text, ok := grail.AsTextInput(in); ok {
+			fmt.Fprintf(h, "text %d %s\n", len(text), text)
+		} else if data, mime, name, ok := grail.AsFileInput(in); ok {
+			fmt.Fprintf(h, "file %s %s %d\n", mime, name, len(data))
+			h.Write(data)
+		} else if _, size, mime, name, ok := grail.AsFileReaderInput(in); ok {
+			fmt.Fprintf(h, "reader %s %s %d\n", mime, name, size)
+		}
+	}
+	for _, opt := range req.ProviderOptions {
+		fmt.Fprintf(h, "option %s\n", describe(opt))
+	}
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// describe renders v by type and JSON encoding, which unlike %#v doesn't
+// include pointer addresses.
+func describe(v any) string {
+	data, _ := json.Marshal(v)
+	return fmt.Sprintf("%T%s", v, data)
+}
+
+func inputWords(inputs []grail.Input) int {
+	n := 0
+	for _, in := range inputs {
+		if text, ok := grail.AsTextInput(in); ok {
+			n += len(strings.Fields(text))
+		}
+	}
+	return n
+}
+
+var loremWords = strings.Fields(`lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod
+tempor incididunt ut labore et dolore magna aliqua enim ad minim veniam quis nostrud exercitation
+ullamco laboris nisi aliquip ex ea commodo consequat duis aute irure in reprehenderit voluptate velit
+esse cillum fugiat nulla pariatur excepteur sint occaecat cupidatat non proident sunt culpa qui
+officia deserunt mollit anim id est laborum`)
+
+// lorem returns n lorem ipsum words as sentences of 6 to 14 words.
+func lorem(rng *rand.Rand, n int) string {
+	var b strings.Builder
+	for left := n; left > 0; {
+		size := min(left, 6+rng.IntN(9))
+		for i := range size {
+			w := loremWords[rng.IntN(len(loremWords))]
+			if i == 0 {
+				if b.Len() > 0 {
+					b.WriteByte(' ')
+				}
+				w = strings.ToUpper(w[:1]) + w[1:]
+			} else {
+				b.WriteByte(' ')
+			}
+			b.WriteString(w)
+		}
+		b.WriteByte('.')
+		left -= size
+	}
+	return b.String()
+}
+
+func solidPNG(rng *rand.Rand, w, h int) ([]byte, error) {
+	c := color.RGBA{R: uint8(rng.IntN(256)), G: uint8(rng.IntN(256)), B: uint8(rng.IntN(256)), A: 255}
+	// A single-color palette makes every pixel c without filling the image.
+	img := image.NewPaletted(image.Rect(0, 0, w, h), color.Palette{c})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// synthesize returns a value conforming to schema s (type, properties,
+// items, enum, and const are honored).
+func synthesize(rng *rand.Rand, s map[string]any, depth int) any {
+	if c, ok := s["const"]; ok {
+		return c
+	}
+	if enum, ok := s["enum"].([]any); ok && len(enum) > 0 {
+		return enum[rng.IntN(len(enum))]
+	}
+	switch schemaType(s) {
+	case "object":
+		out := map[string]any{}
+		props, _ := s["properties"].(map[string]any)
+		for _, k := range sortedKeys(props) {
+			if ps, ok := props[k].(map[string]any); ok && depth < 8 {
+				out[k] = synthesize(rng, ps, depth+1)
+			}
+		}
+		return out
+	case "array":
+		items, _ := s["items"].(map[string]any)
+		if items == nil || depth >= 8 {
+			return []any{}
+		}
+		n := 1 + rng.IntN(3)
+		if minItems, ok := s["minItems"].(float64); ok && n < int(minItems) {
+			n = int(minItems)
+		}
+		out := make([]any, n)
+		for i := range out {
+			out[i] = synthesize(rng, items, depth+1)
+		}
+		return out
+	case "integer":
+		return rng.IntN(100)
+	case "number":
+		return float64(rng.IntN(10000)) / 100
+	case "boolean":
+		return rng.IntN(2) == 1
+	case "null":
+		return nil
+	default:
+		return lorem(rng, 1+rng.IntN(4))
+	}
+}
+
+// schemaType returns s's type in lower case, the first non-null one if s
+// lists several, or "object" if s has properties but no type.
+func schemaType(s map[string]any) string {
+	switch t := s["type"].(type) {
+	case string:
+		return strings.ToLower(t)
+	case []any:
+		for _, name := range t {
+			if n, ok := name.(string); ok && !strings.EqualFold(n, "null") {
+				return strings.ToLower(n)
+			}
+		}
+		return "null"
+	}
+	if _, ok := s["properties"]; ok {
+		return "object"
+	}
+	return "string"
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,110 @@
+package fake_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/internal/jsonschema"
+	"github.com/montanaflynn/grail/providers/fake"
+)
+
+func TestFake_Deterministic(t *testing.T) {
+	client := grail.NewClient(fake.New())
+	generate := func(prompt string) string {
+		res, err := client.Generate(context.Background(), grail.Request{
+			Inputs:          []grail.Input{grail.InputText(prompt)},
+			Output:          grail.OutputText(),
+			ProviderOptions: []grail.ProviderOption{fake.TextOptions{Words: 12}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, _ := res.Text()
+		return text
+	}
+	a, b, c := generate("hello"), generate("hello"), generate("goodbye")
+	if a != b {
+		t.Fatalf("expected identical requests to get identical text:\n%s\n%s", a, b)
+	}
+	if a == c {
+		t.Fatalf("expected different requests to get different text")
+	}
+	if n := len(bytes.Fields([]byte(a))); n != 12 {
+		t.Fatalf("expected 12 words, got %d: %s", n, a)
+	}
+}
+
+func TestFake_Images(t *testing.T) {
+	res, err := grail.NewClient(fake.New()).Generate(context.Background(), grail.Request{
+		Inputs:          []grail.Input{grail.InputText("a red square")},
+		Output:          grail.OutputImage(grail.ImageSpec{Count: 2}),
+		ProviderOptions: []grail.ProviderOption{fake.ImageOptions{Width: 64, Height: 32}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	images, _ := res.Images()
+	if len(images) != 2 || bytes.Equal(images[0], images[1]) {
+		t.Fatalf("expected 2 distinct images, got %d", len(images))
+	}
+	img, err := png.Decode(bytes.NewReader(images[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 64 || b.Dy() != 32 {
+		t.Fatalf("expected 64x32 image, got %v", b)
+	}
+}
+
+func TestFake_JSONConformsToSchema(t *testing.T) {
+	schema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"title":  map[string]any{"type": "string"},
+			"rating": map[string]any{"type": "integer"},
+			"mood":   map[string]any{"type": "string", "enum": []string{"happy", "sad"}},
+			"tags":   map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+			"author": map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"verified": map[string]any{"type": "boolean"}},
+				"required":   []string{"verified"},
+			},
+		},
+		"required": []string{"title", "rating", "mood", "tags", "author"},
+	}
+	res, err := grail.NewClient(fake.New()).Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("review")},
+		Output: grail.OutputJSON(schema),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data json.RawMessage
+	if err := res.DecodeJSON(&data); err != nil {
+		t.Fatal(err)
+	}
+	if err := jsonschema.Validate(schema, data); err != nil {
+		t.Fatalf("synthesized JSON %s does not match schema: %v", data, err)
+	}
+}
+
+func TestFake_Stream(t *testing.T) {
+	var words []string
+	ctx := grail.ContextWithTextStream(context.Background(), func(text string) { words = append(words, text) })
+	res, err := grail.NewClient(fake.New()).Generate(ctx, grail.Request{
+		Inputs:          []grail.Input{grail.InputText("hello")},
+		Output:          grail.OutputText(),
+		ProviderOptions: []grail.ProviderOption{fake.TextOptions{Words: 12}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := res.Text(); len(words) != 12 || strings.Join(words, "") != text {
+		t.Fatalf("expected the text a word at a time, got %q", words)
+	}
+}
@@ -0,0 +1,165 @@
+package gemini
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/montanaflynn/grail"
+)
+
+// BillingExport implements grail.UsageSource with a CSV file of Cloud Billing
+// export rows, for reconciling a grail.UsageTracker against Google's billing
+// records. Gemini has no usage API; export the rows with a query over the
+// standard billing export table in BigQuery, such as:
+//
+//	SELECT usage_start_time, sku.description AS sku_description,
+//	       usage.amount AS usage_amount, usage.unit AS usage_unit, cost
+//	FROM `project.dataset.gcp_billing_export_v1_XXXXXX`
+//	WHERE service.description = 'Gemini API'
+//
+// Columns are found by header name; others are ignored. Rows are attributed
+// to models and token directions by their SKU description.
+type BillingExport struct {
+	// Path is the CSV file, read on every call.
+	Path string
+	// SKUModel maps a SKU description to a model name and whether it bills
+	// input (true) or output (false) tokens. By default SKUs like "Generate
+	// content input token count Gemini 2.5 Pro" map to "gemini-2.5-pro".
+	SKUModel func(sku string) (model string, input bool, ok bool)
+}
+
+// Name returns "gemini".
+func (b BillingExport) Name() string { return "gemini" }
+
+// ProviderUsage reads the export's token usage and cost per model within
+// period. Requests aren't billed, so they aren't reported.
+func (b BillingExport) ProviderUsage(ctx context.Context, period grail.Period) ([]grail.ProviderUsage, error) {
+	f, err := os.Open(b.Path)
+	if err != nil {
+		return nil, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("open billing export: %v", err)).WithCause(err).WithProviderName("gemini")
+	}
+	defer f.Close()
+	usage, err := b.read(f, period)
+	if err != nil {
+		return nil, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("read billing export: %v", err)).WithCause(err).WithProviderName("gemini")
+	}
+	return usage, nil
+}
+
+func (b BillingExport) read(r io.Reader, period grail.Period) ([]grail.ProviderUsage, error) {
+	skuModel := b.SKUModel
+	if skuModel == nil {
+		skuModel = defaultSKUModel
+	}
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	col := map[string]int{}
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, name := range []string{"usage_start_time", "sku_description", "usage_amount", "cost"} {
+		if _, ok := col[name]; !ok {
+			return nil, fmt.Errorf("missing %q column", name)
+		}
+	}
+
+	byModel := map[string]*grail.ProviderUsage{}
+	var order []string
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, err := parseBillingTime(row[col["usage_start_time"]])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if !period.Contains(start) {
+			continue
+		}
+		model, input, ok := skuModel(row[col["sku_description"]])
+		if !ok {
+			continue
+		}
+		amount, err := strconv.ParseFloat(row[col["usage_amount"]], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: usage_amount: %w", line, err)
+		}
+		cost, err := strconv.ParseFloat(row[col["cost"]], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: cost: %w", line, err)
+		}
+
+		u := byModel[model]
+		if u == nil {
+			u = &grail.ProviderUsage{Model: model, HasCost: true}
+			byModel[model] = u
+			order = append(order, model)
+		}
+		tokens := int(amount)
+		if input {
+			u.Usage.InputTokens += tokens
+		} else {
+			u.Usage.OutputTokens += tokens
+		}
+		u.Usage.TotalTokens += tokens
+		u.Cost += cost
+	}
+
+	out := make([]grail.ProviderUsage, 0, len(order))
+	for _, m := range order {
+		out = append(out, *byModel[m])
+	}
+	return out, nil
+}
+
+// BigQuery writes timestamps as "2026-03-01 00:00:00 UTC"; other tools use
+// RFC 3339.
+func parseBillingTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05 MST", "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid usage_start_time %q", s)
+}
+
+// skuStopWords end the model name in a SKU description.
+var skuStopWords = map[string]bool{
+	"input": true, "output": true, "token": true, "tokens": true, "count": true,
+	"for": true, "with": true, "long": true, "short": true, "context": true,
+	"cached": true, "caching": true, "thinking": true, "text": true, "audio": true,
+	"video": true, "characters": true,
+}
+
+func defaultSKUModel(sku string) (string, bool, bool) {
+	words := strings.Fields(strings.ToLower(sku))
+	input := slices.Contains(words, "input")
+	if !input && !slices.Contains(words, "output") {
+		return "", false, false
+	}
+	start := slices.Index(words, "gemini")
+	if start < 0 {
+		return "", false, false
+	}
+	end := start + 1
+	for end < len(words) && !skuStopWords[words[end]] {
+		end++
+	}
+	return strings.Join(words[start:end], "-"), input, true
+}
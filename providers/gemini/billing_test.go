@@ -0,0 +1,48 @@
+package gemini
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+)
+
+func TestBillingExport(t *testing.T) {
+	csv := `usage_start_time,sku_description,usage_amount,usage_unit,cost,project
+2026-03-02 00:00:00 UTC,Generate content input token count Gemini 2.5 Pro,1200,count,0.0015,p
+2026-03-02 00:00:00 UTC,Gemini 2.5 Pro output token count,300,count,0.003,p
+2026-03-03T00:00:00Z,Generate content output token count Gemini 2.5 Flash Image,100,count,0.004,p
+2026-03-03T00:00:00Z,Grounding with Google Search,5,requests,0.175,p
+2026-04-01 00:00:00 UTC,Gemini 2.5 Pro input token count,999,count,1,p
+`
+	path := filepath.Join(t.TempDir(), "billing.csv")
+	if err := os.WriteFile(path, []byte(csv), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := BillingExport{Path: path}.ProviderUsage(context.Background(), grail.Month(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(usage) != 2 {
+		t.Fatalf("expected usage for two models, got %+v", usage)
+	}
+	pro := usage[0]
+	if pro.Model != "gemini-2.5-pro" || pro.Usage.InputTokens != 1200 || pro.Usage.OutputTokens != 300 || !pro.HasCost || math.Abs(pro.Cost-0.0045) > 1e-9 {
+		t.Fatalf("unexpected usage %+v", pro)
+	}
+	if usage[1].Model != "gemini-2.5-flash-image" || usage[1].Usage.OutputTokens != 100 {
+		t.Fatalf("unexpected usage %+v", usage[1])
+	}
+
+	if err := os.WriteFile(path, []byte("sku_description,cost\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (BillingExport{Path: path}).ProviderUsage(context.Background(), grail.Period{}); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for missing columns, got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package gemini
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+)
+
+// generateContentServer emulates the GenerateContent endpoint, answering every
+// request with status and body.
+func generateContentServer(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1beta/models/"+DefaultTextModelName+":generateContent" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("X-Goog-Api-Key"); got != "dummy" {
+			t.Errorf("unexpected API key %q", got)
+		}
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func errorBody(code int, status string) string {
+	return fmt.Sprintf(`{"error":{"code":%d,"message":"%s","status":"%s"}}`, code, status, status)
+}
+
+func TestGemini_Contract(t *testing.T) {
+	cases := []struct {
+		name      string
+		status    int
+		body      string
+		code      grail.ErrorCode
+		retryable bool
+	}{
+		{"ok", http.StatusOK, `{"candidates":[{"content":{"role":"model","parts":[{"text":"Hello there."}]},"finishReason":"STOP"}],
+			"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":3,"totalTokenCount":8},"modelVersion":"gemini-3.1-pro-preview-001"}`, "", false},
+		{"rate_limited", http.StatusTooManyRequests, errorBody(429, "RESOURCE_EXHAUSTED"), grail.RateLimited, true},
+		{"server_error", http.StatusInternalServerError, errorBody(500, "INTERNAL"), grail.Unavailable, true},
+		{"unauthorized", http.StatusForbidden, errorBody(403, "PERMISSION_DENIED"), grail.Unauthorized, false},
+		{"prompt_blocked", http.StatusOK, `{"promptFeedback":{"blockReason":"SAFETY"}}`, grail.Refused, false},
+		{"safety_stop", http.StatusOK, `{"candidates":[{"finishReason":"SAFETY"}]}`, grail.Refused, false},
+		{"malformed", http.StatusOK, `{"candidates":[`, grail.Internal, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := generateContentServer(t, tc.status, tc.body)
+			p, err := New(context.Background(), WithAPIKey("dummy"), WithBaseURL(srv.URL))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			res, err := grail.NewClient(p).Generate(context.Background(), grail.Request{
+				Inputs: []grail.Input{grail.InputText("Say hello.")},
+				Output: grail.OutputText(),
+			})
+
+			if tc.code == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				text, _ := res.Text()
+				if text != "Hello there." || res.Usage.TotalTokens != 8 {
+					t.Fatalf("unexpected response: %q %+v", text, res.Usage)
+				}
+				if m := res.Provider.Models; len(m) != 1 || m[0].Name != DefaultTextModelName || m[0].Version != "gemini-3.1-pro-preview-001" {
+					t.Fatalf("unexpected models %+v", m)
+				}
+				return
+			}
+			if got := grail.GetErrorCode(err); got != tc.code {
+				t.Fatalf("expected %s, got %s (%v)", tc.code, got, err)
+			}
+			if grail.IsRetryable(err) != tc.retryable {
+				t.Fatalf("expected retryable=%v for %v", tc.retryable, err)
+			}
+		})
+	}
+}
@@ -27,11 +27,17 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/internal/httplog"
 
+	"cloud.google.com/go/auth/credentials"
+	"cloud.google.com/go/auth/httptransport"
 	"google.golang.org/genai"
 )
 
@@ -56,6 +62,10 @@ type settings struct {
 	textModel  string
 	imageModel string
 	logger     *slog.Logger
+	httpClient *http.Client
+	baseURL    string
+	project    string
+	location   string
 }
 
 // WithAPIKey sets the API key to use.
@@ -95,12 +105,39 @@ func WithLogger(l *slog.Logger) Option {
 	}
 }
 
+// WithBaseURL overrides the API base URL (default
+// https://generativelanguage.googleapis.com/), for proxies, gateways, and test
+// servers.
+func WithBaseURL(url string) Option {
+	return func(s *settings) { s.baseURL = url }
+}
+
+// WithVertex sends requests through Vertex AI in project and location (a
+// region such as "europe-west4", a multi-region such as "eu", or "global"),
+// keeping data processing within the location for data residency. It
+// authenticates with Application Default Credentials instead of an API key,
+// unless WithHTTPClient supplies an authenticated client. The location is
+// recorded in grail.ProviderInfo.Region.
+func WithVertex(project, location string) Option {
+	return func(s *settings) { s.project, s.location = project, location }
+}
+
+// WithHTTPClient sets the HTTP client used for API calls. The provider wraps
+// its transport to log wire requests at debug level through the provider's
+// logger.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(s *settings) { s.httpClient = hc }
+}
+
 // Provider is a Gemini-backed implementation of grail.Provider.
 type Provider struct {
 	client     *genai.Client
 	textModel  string
 	imageModel string
+	mu         sync.RWMutex // guards log and the model catalog slots
 	log        *slog.Logger
+	transport  *httplog.Transport
+	region     string // Vertex AI location, "" for the Gemini API
 
 	// Model catalog slots
 	bestTextModel  grail.Model
@@ -159,7 +196,16 @@ type TextOptions struct {
 	MaxTokens    *int32
 	Temperature  *float32
 	TopP         *float32
+	TopK         *float32
 	SystemPrompt string
+	// CandidateCount requests several alternative responses. Each candidate
+	// is returned as its own text output part, in order (see Response.Texts).
+	CandidateCount int32
+	// ResponseLogprobs requests token log probabilities, with TopLogprobs
+	// alternatives per step. Each candidate's average log probability is
+	// logged with the response at debug level.
+	ResponseLogprobs bool
+	TopLogprobs      *int32
 }
 
 func (TextOptions) ApplyProviderOption() {}
@@ -216,6 +262,23 @@ func WithImageSize(size ImageSize) ImageOption {
 	}
 }
 
+// vertexHTTPClient returns an HTTP client authorized with Application Default
+// Credentials. genai only does this itself when it creates the HTTP client,
+// and the provider always supplies its own to log wire requests.
+func vertexHTTPClient() (*http.Client, error) {
+	creds, err := credentials.DetectDefault(&credentials.DetectOptions{
+		Scopes: []string{"https://www.googleapis.com/auth/cloud-platform"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("gemini: find Vertex AI credentials: %w", err)
+	}
+	hc, err := httptransport.NewClient(&httptransport.Options{Credentials: creds})
+	if err != nil {
+		return nil, fmt.Errorf("gemini: new Vertex AI HTTP client: %w", err)
+	}
+	return hc, nil
+}
+
 // New constructs a Gemini provider using functional options.
 func New(ctx context.Context, opts ...Option) (*Provider, error) {
 	cfg := settings{
@@ -227,7 +290,21 @@ func New(ctx context.Context, opts ...Option) (*Provider, error) {
 		opt(&cfg)
 	}
 
+	vertex := cfg.location != ""
 	switch {
+	case vertex:
+		if cfg.project == "" {
+			return nil, errors.New("gemini: WithVertex requires a project")
+		}
+		// Vertex AI authenticates with credentials, not an API key.
+		cfg.apiKey = ""
+		if cfg.httpClient == nil {
+			hc, err := vertexHTTPClient()
+			if err != nil {
+				return nil, err
+			}
+			cfg.httpClient = hc
+		}
 	case cfg.apiKeySet && cfg.apiKey == "":
 		return nil, ErrAPIKeyRequired
 	case !cfg.apiKeySet && cfg.apiKey == "":
@@ -237,38 +314,86 @@ func New(ctx context.Context, opts ...Option) (*Provider, error) {
 		}
 	}
 
+	p := &Provider{
+		textModel:  cfg.textModel,
+		imageModel: cfg.imageModel,
+		log:        cfg.logger,
+		region:     cfg.location,
+		// Initialize model catalog with defaults
+		bestTextModel:  Gemini3_1Pro,
+		fastTextModel:  Gemini3_5Flash,
+		bestImageModel: Gemini3ProImage,
+		fastImageModel: Gemini3_1FlashImage,
+	}
+
+	p.transport = &httplog.Transport{
+		Logger:   p.logger,
+		Provider: "gemini",
+		Level:    slog.LevelDebug,
+		MaxBody:  httplog.DefaultMaxBody,
+	}
 	clientConfig := &genai.ClientConfig{
-		Backend: genai.BackendGeminiAPI,
+		Backend:    genai.BackendGeminiAPI,
+		HTTPClient: httplog.Wrap(cfg.httpClient, p.transport),
+		HTTPOptions: genai.HTTPOptions{
+			BaseURL: cfg.baseURL,
+		},
 	}
 	if cfg.apiKey != "" {
 		clientConfig.APIKey = cfg.apiKey
 	}
+	if vertex {
+		clientConfig.Backend = genai.BackendVertexAI
+		clientConfig.Project, clientConfig.Location = cfg.project, cfg.location
+	}
 
 	client, err := genai.NewClient(ctx, clientConfig)
 	if err != nil {
 		return nil, fmt.Errorf("new gemini client: %w", err)
 	}
+	p.client = client
 
-	return &Provider{
-		client:     client,
-		textModel:  cfg.textModel,
-		imageModel: cfg.imageModel,
-		log:        cfg.logger,
-		// Initialize model catalog with defaults
-		bestTextModel:  Gemini3_1Pro,
-		fastTextModel:  Gemini3_5Flash,
-		bestImageModel: Gemini3ProImage,
-		fastImageModel: Gemini3_1FlashImage,
-	}, nil
+	return p, nil
 }
 
 // SetLogger allows the client to inject a logger.
 func (c *Provider) SetLogger(l *slog.Logger) {
 	if l != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
 		c.log = l
 	}
 }
 
+func (c *Provider) logger() *slog.Logger {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.log
+}
+
+// Capabilities implements grail.CapabilityReporter. Files are sent inline,
+// which the Gemini API limits to 20 MB.
+func (c *Provider) Capabilities() grail.ProviderCapabilities {
+	return grail.ProviderCapabilities{
+		TextOutput:     true,
+		ImageOutput:    true,
+		VideoOutput:    true,
+		JSONOutput:     true,
+		InputMIMETypes: []string{"image/*", "application/pdf", "text/*", "audio/*", "video/*"},
+		MaxFileSize:    20 * 1024 * 1024,
+		Streaming:      true,
+		Tools:          true,
+		History:        true,
+		Logprobs:       true,
+		ModelListing:   true,
+	}
+}
+
+// WrapTransport implements grail.TransportAware.
+func (c *Provider) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	c.transport.WrapBase(wrap)
+}
+
 // Name returns the provider name.
 func (c *Provider) Name() string {
 	return "gemini"
@@ -277,31 +402,65 @@ func (c *Provider) Name() string {
 // ModelCatalog implementation
 
 // SetBestTextModel sets the model to use for best-quality text generation.
-func (c *Provider) SetBestTextModel(model grail.Model) { c.bestTextModel = model }
+func (c *Provider) SetBestTextModel(model grail.Model) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bestTextModel = model
+}
 
 // SetFastTextModel sets the model to use for fast text generation.
-func (c *Provider) SetFastTextModel(model grail.Model) { c.fastTextModel = model }
+func (c *Provider) SetFastTextModel(model grail.Model) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fastTextModel = model
+}
 
 // SetBestImageModel sets the model to use for best-quality image generation.
-func (c *Provider) SetBestImageModel(model grail.Model) { c.bestImageModel = model }
+func (c *Provider) SetBestImageModel(model grail.Model) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bestImageModel = model
+}
 
 // SetFastImageModel sets the model to use for fast image generation.
-func (c *Provider) SetFastImageModel(model grail.Model) { c.fastImageModel = model }
+func (c *Provider) SetFastImageModel(model grail.Model) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fastImageModel = model
+}
 
 // BestTextModel returns the model used for best-quality text generation.
-func (c *Provider) BestTextModel() grail.Model { return c.bestTextModel }
+func (c *Provider) BestTextModel() grail.Model {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.bestTextModel
+}
 
 // FastTextModel returns the model used for fast text generation.
-func (c *Provider) FastTextModel() grail.Model { return c.fastTextModel }
+func (c *Provider) FastTextModel() grail.Model {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.fastTextModel
+}
 
 // BestImageModel returns the model used for best-quality image generation.
-func (c *Provider) BestImageModel() grail.Model { return c.bestImageModel }
+func (c *Provider) BestImageModel() grail.Model {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.bestImageModel
+}
 
 // FastImageModel returns the model used for fast image generation.
-func (c *Provider) FastImageModel() grail.Model { return c.fastImageModel }
+func (c *Provider) FastImageModel() grail.Model {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.fastImageModel
+}
 
 // AllModels returns all configured models.
 func (c *Provider) AllModels() []grail.Model {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return []grail.Model{
 		c.bestTextModel,
 		c.fastTextModel,
@@ -312,6 +471,8 @@ func (c *Provider) AllModels() []grail.Model {
 		Gemini3Flash,
 		Gemini25Flash,
 		Gemini25FlashLite,
+		Veo3_1,
+		Veo3_1Fast,
 	}
 }
 
@@ -320,8 +481,38 @@ func (c *Provider) ListModels(ctx context.Context) ([]grail.Model, error) {
 	return c.AllModels(), nil
 }
 
+// LiveModels implements grail.LiveModelLister with the Models API. Names are
+// returned without their "models/" (or, on Vertex AI,
+// "publishers/google/models/") prefix.
+func (c *Provider) LiveModels(ctx context.Context) ([]string, error) {
+	var names []string
+	for m, err := range c.client.Models.All(ctx) {
+		if err != nil {
+			return nil, apiError("list models", err)
+		}
+		names = append(names, m.Name[strings.LastIndex(m.Name, "/")+1:])
+	}
+	return names, nil
+}
+
+// ConfiguredModels implements grail.LiveModelLister.
+func (c *Provider) ConfiguredModels() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return map[string]string{
+		"text":       c.textModel,
+		"image":      c.imageModel,
+		"best_text":  c.bestTextModel.Name,
+		"fast_text":  c.fastTextModel.Name,
+		"best_image": c.bestImageModel.Name,
+		"fast_image": c.fastImageModel.Name,
+	}
+}
+
 // ResolveModel resolves a role+tier to a model name.
 func (c *Provider) ResolveModel(role grail.ModelRole, tier grail.ModelTier) (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	switch {
 	case role == grail.ModelRoleText && tier == grail.ModelTierBest:
 		return c.bestTextModel.Name, nil
@@ -331,6 +522,10 @@ func (c *Provider) ResolveModel(role grail.ModelRole, tier grail.ModelTier) (str
 		return c.bestImageModel.Name, nil
 	case role == grail.ModelRoleImage && tier == grail.ModelTierFast:
 		return c.fastImageModel.Name, nil
+	case role == grail.ModelRoleVideo && tier == grail.ModelTierBest:
+		return Veo3_1.Name, nil
+	case role == grail.ModelRoleVideo && tier == grail.ModelTierFast:
+		return Veo3_1Fast.Name, nil
 	default:
 		return "", fmt.Errorf("gemini: no %s model with tier %s", role, tier)
 	}
@@ -345,82 +540,129 @@ func (c *Provider) DescribeModels(req grail.Request) string {
 	if _, isImage := grail.GetImageSpec(req.Output); isImage {
 		return c.imageModel
 	}
+	if _, isVideo := grail.GetVideoSpec(req.Output); isVideo {
+		return DefaultVideoModelName
+	}
 	return c.textModel
 }
 
 // DoGenerate implements the ProviderExecutor interface.
 func (c *Provider) DoGenerate(ctx context.Context, req grail.Request) (grail.Response, error) {
-	// Convert inputs to Gemini format
-	parts, err := c.toGenAIParts(req.Inputs)
+	return c.generate(ctx, req, nil)
+}
+
+// StreamGenerate implements grail.TextStreamer with streamed content
+// generation. Other outputs are generated as by DoGenerate.
+func (c *Provider) StreamGenerate(ctx context.Context, req grail.Request, emit func(text string)) (grail.Response, error) {
+	return c.generate(ctx, req, emit)
+}
+
+// generate routes req by output type, streaming text output to emit if it's
+// set.
+func (c *Provider) generate(ctx context.Context, req grail.Request, emit func(text string)) (grail.Response, error) {
+	// Videos are generated from a prompt, not a conversation.
+	if spec, isVideo := grail.GetVideoSpec(req.Output); isVideo {
+		return c.generateVideo(ctx, req, spec)
+	}
+
+	// Convert history and inputs to Gemini format
+	contents, err := c.historyContents(req.History)
+	if err != nil {
+		return grail.Response{}, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("failed to convert history: %v", err)).WithCause(err).WithProviderName("gemini")
+	}
+	current, err := c.toGenAIContents(req.Inputs)
 	if err != nil {
 		return grail.Response{}, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("failed to convert inputs: %v", err)).WithCause(err).WithProviderName("gemini")
 	}
+	contents = append(contents, current...)
 
 	// Determine output type and route accordingly
 	if grail.IsTextOutput(req.Output) {
-		return c.generateText(ctx, req, parts)
+		return c.generateText(ctx, req, contents, emit)
 	}
 	if spec, isImage := grail.GetImageSpec(req.Output); isImage {
-		return c.generateImage(ctx, req, parts, spec)
+		return c.generateImage(ctx, req, contents, spec)
 	}
 	if schema, strict, isJSON := grail.GetJSONOutput(req.Output); isJSON {
-		return c.generateJSON(ctx, req, parts, schema, strict)
+		return c.generateJSON(ctx, req, contents, schema, strict)
 	}
 	return grail.Response{}, grail.NewGrailError(grail.Unsupported, fmt.Sprintf("unsupported output type: %T", req.Output)).WithProviderName("gemini")
 }
 
-func (c *Provider) generateText(ctx context.Context, req grail.Request, parts []*genai.Part) (grail.Response, error) {
+func (c *Provider) generateText(ctx context.Context, req grail.Request, contents []*genai.Content, emit func(text string)) (grail.Response, error) {
 	// Extract text options from provider options
 	var textOpts TextOptions
 	modelName := c.textModel
-	// Request.Model takes precedence over provider default and ProviderOptions
-	if req.Model != "" {
-		modelName = req.Model
-	} else {
-		// Fall back to ProviderOptions if Request.Model not set
-		for _, opt := range req.ProviderOptions {
-			if to, ok := opt.(TextOptions); ok {
-				textOpts = to
-				if to.Model != "" {
-					modelName = to.Model
-				}
+	for _, opt := range req.ProviderOptions {
+		if to, ok := opt.(TextOptions); ok {
+			textOpts = to
+			if to.Model != "" {
+				modelName = to.Model
 			}
 		}
 	}
+	// Request.Model takes precedence over provider default and TextOptions.Model
+	if req.Model != "" {
+		modelName = req.Model
+	}
 
-	if c.log != nil {
-		c.log.Debug("generate text request", slog.String("model", modelName))
+	if log := c.logger(); log != nil {
+		log.Debug("generate text request", slog.String("model", modelName))
 	}
 
 	config := &genai.GenerateContentConfig{}
 	c.applyTextOptions(config, textOpts)
-
-	contents := []*genai.Content{
-		genai.NewContentFromParts(parts, genai.RoleUser),
+	config.Tools = toFunctionTools(req.Tools)
+	// Enum answers use Gemini's enum mode, which can only produce one of
+	// the values. It can't be combined with function calling.
+	if enum, _, _ := grail.GetTextConstraints(req.Output); len(enum) > 0 && len(req.Tools) == 0 {
+		config.ResponseMIMEType = "text/x.enum"
+		config.ResponseSchema = &genai.Schema{Type: genai.TypeString, Enum: enum}
 	}
 
-	resp, err := c.client.Models.GenerateContent(ctx, modelName, contents, config)
+	var resp *genai.GenerateContentResponse
+	var err error
+	if emit != nil {
+		resp, err = c.streamContent(ctx, modelName, contents, config, emit)
+	} else {
+		resp, err = c.client.Models.GenerateContent(ctx, modelName, contents, config)
+	}
 	if err != nil {
-		return grail.Response{}, grail.NewGrailError(grail.Internal, fmt.Sprintf("generate text failed: %v", err)).WithCause(err).WithProviderName("gemini").WithRetryable(isRetryableError(err))
+		return grail.Response{}, apiError("generate text", err)
+	}
+	if reason, ok := blocked(resp); ok {
+		return grail.Response{}, grail.NewGrailError(grail.Refused, "gemini blocked the response: "+reason).WithProviderName("gemini")
 	}
 
-	text := resp.Text()
+	texts := candidateTexts(resp)
 	usage := extractUsage(resp)
 
-	if c.log != nil {
-		c.log.Debug("generate text response", slog.Any("usage", usage))
+	if log := c.logger(); log != nil {
+		attrs := []any{slog.Any("usage", usage)}
+		if lp := avgLogprobs(resp); lp != nil {
+			attrs = append(attrs, slog.Any("avg_logprobs", lp))
+		}
+		log.Debug("generate text response", attrs...)
 	}
 
+	calls := extractToolCalls(resp)
+	var outputs []grail.OutputPart
+	for _, text := range texts {
+		if text != "" || len(calls) == 0 {
+			outputs = append(outputs, grail.NewTextOutputPart(text))
+		}
+	}
+	outputs = append(outputs, calls...)
+
 	return grail.Response{
-		Outputs: []grail.OutputPart{
-			grail.NewTextOutputPart(text),
-		},
-		Usage: usage,
+		Outputs: outputs,
+		Usage:   usage,
 		Provider: grail.ProviderInfo{
-			Name:  "gemini",
-			Route: "generate_content",
+			Name:   "gemini",
+			Route:  "generate_content",
+			Region: c.region,
 			Models: []grail.ModelUse{
-				{Role: "language", Name: modelName},
+				{Role: "language", Name: modelName, Version: resp.ModelVersion},
 			},
 		},
 		RequestID: "",
@@ -428,7 +670,50 @@ func (c *Provider) generateText(ctx context.Context, req grail.Request, parts []
 	}, nil
 }
 
-func (c *Provider) generateImage(ctx context.Context, req grail.Request, parts []*genai.Part, spec grail.ImageSpec) (grail.Response, error) {
+// streamContent generates content with streaming on, passing the first
+// candidate's text to emit as it arrives, and returns the chunks merged into
+// one response: each candidate's parts in order, with its last finish reason
+// and the last chunk's usage.
+func (c *Provider) streamContent(ctx context.Context, modelName string, contents []*genai.Content, config *genai.GenerateContentConfig, emit func(text string)) (*genai.GenerateContentResponse, error) {
+	var resp *genai.GenerateContentResponse
+	var cands []*genai.Candidate
+	for chunk, err := range c.client.Models.GenerateContentStream(ctx, modelName, contents, config) {
+		if err != nil {
+			return nil, err
+		}
+		for i, cand := range chunk.Candidates {
+			if cand == nil {
+				continue
+			}
+			for len(cands) <= i {
+				cands = append(cands, &genai.Candidate{Index: int32(len(cands)), Content: &genai.Content{Role: genai.RoleModel}})
+			}
+			merged := *cand
+			merged.Content = cands[i].Content
+			cands[i] = &merged
+			if cand.Content == nil {
+				continue
+			}
+			merged.Content.Parts = append(merged.Content.Parts, cand.Content.Parts...)
+			if i != 0 {
+				continue
+			}
+			for _, part := range cand.Content.Parts {
+				if part != nil && !part.Thought && part.Text != "" {
+					emit(part.Text)
+				}
+			}
+		}
+		resp = chunk
+	}
+	if resp == nil {
+		return nil, errors.New("stream ended without a response")
+	}
+	resp.Candidates = cands
+	return resp, nil
+}
+
+func (c *Provider) generateImage(ctx context.Context, req grail.Request, contents []*genai.Content, spec grail.ImageSpec) (grail.Response, error) {
 	// Extract image options from provider options
 	var imageOpts ImageOptions
 	modelName := c.imageModel
@@ -452,43 +737,44 @@ func (c *Provider) generateImage(ctx context.Context, req grail.Request, parts [
 		}
 	}
 
-	if c.log != nil {
-		c.log.Debug("generate image request", slog.String("model", modelName))
+	if log := c.logger(); log != nil {
+		log.Debug("generate image request", slog.String("model", modelName))
 	}
 
 	config := &genai.GenerateContentConfig{}
 	c.applyImageOptions(config, imageOpts, &cfg)
 
-	contents := []*genai.Content{
-		genai.NewContentFromParts(parts, genai.RoleUser),
-	}
-
 	resp, err := c.client.Models.GenerateContent(ctx, modelName, contents, config)
 	if err != nil {
-		return grail.Response{}, grail.NewGrailError(grail.Internal, fmt.Sprintf("generate image failed: %v", err)).WithCause(err).WithProviderName("gemini").WithRetryable(isRetryableError(err))
+		return grail.Response{}, apiError("generate image", err)
+	}
+	if reason, ok := blocked(resp); ok {
+		return grail.Response{}, grail.NewGrailError(grail.Refused, "gemini blocked the response: "+reason).WithProviderName("gemini")
 	}
 
 	images := extractImages(resp)
 	usage := extractUsage(resp)
 
-	if c.log != nil {
-		c.log.Debug("generate image response", slog.Int("images", len(images)), slog.Any("usage", usage))
+	if log := c.logger(); log != nil {
+		log.Debug("generate image response", slog.Int("images", len(images)), slog.Any("usage", usage))
 	}
 
+	// Every image produced by Gemini image models carries a SynthID watermark.
 	outputParts := make([]grail.OutputPart, 0, len(images))
 	for _, img := range images {
-		outputParts = append(outputParts, grail.NewImageOutputPart(img.Data, img.MIME, ""))
+		outputParts = append(outputParts, grail.NewImageOutputPart(img.Data, img.MIME, "", grail.WithSynthID()))
 	}
 
 	return grail.Response{
 		Outputs: outputParts,
 		Usage:   usage,
 		Provider: grail.ProviderInfo{
-			Name:  "gemini",
-			Route: "generate_content",
+			Name:   "gemini",
+			Route:  "generate_content",
+			Region: c.region,
 			Models: []grail.ModelUse{
-				{Role: "language", Name: modelName},
-				{Role: "image_generation", Name: modelName},
+				{Role: "language", Name: modelName, Version: resp.ModelVersion},
+				{Role: "image_generation", Name: modelName, Version: resp.ModelVersion},
 			},
 		},
 		RequestID: "",
@@ -496,69 +782,77 @@ func (c *Provider) generateImage(ctx context.Context, req grail.Request, parts [
 	}, nil
 }
 
-func (c *Provider) generateJSON(ctx context.Context, req grail.Request, parts []*genai.Part, schema any, strict bool) (grail.Response, error) {
+func (c *Provider) generateJSON(ctx context.Context, req grail.Request, contents []*genai.Content, schema any, strict bool) (grail.Response, error) {
 	// Extract text options from provider options
 	var textOpts TextOptions
 	modelName := c.textModel
-	// Request.Model takes precedence over provider default and ProviderOptions
-	if req.Model != "" {
-		modelName = req.Model
-	} else {
-		// Fall back to ProviderOptions if Request.Model not set
-		for _, opt := range req.ProviderOptions {
-			if to, ok := opt.(TextOptions); ok {
-				textOpts = to
-				if to.Model != "" {
-					modelName = to.Model
-				}
+	for _, opt := range req.ProviderOptions {
+		if to, ok := opt.(TextOptions); ok {
+			textOpts = to
+			if to.Model != "" {
+				modelName = to.Model
 			}
 		}
 	}
+	// Request.Model takes precedence over provider default and TextOptions.Model
+	if req.Model != "" {
+		modelName = req.Model
+	}
 
-	if c.log != nil {
-		c.log.Debug("generate JSON request", slog.String("model", modelName))
+	if log := c.logger(); log != nil {
+		log.Debug("generate JSON request", slog.String("model", modelName))
 	}
 
 	config := &genai.GenerateContentConfig{}
 	c.applyTextOptions(config, textOpts)
+	config.Tools = toFunctionTools(req.Tools)
 	// Note: Gemini may support JSON mode via response_mime_type or similar
 	// For now, we'll generate text and validate as JSON
-
-	contents := []*genai.Content{
-		genai.NewContentFromParts(parts, genai.RoleUser),
-	}
+	config.ResponseLogprobs = slices.Contains(grail.GetConfidenceMethods(req.Output), grail.ConfidenceLogprobs)
 
 	resp, err := c.client.Models.GenerateContent(ctx, modelName, contents, config)
 	if err != nil {
-		return grail.Response{}, grail.NewGrailError(grail.Internal, fmt.Sprintf("generate JSON failed: %v", err)).WithCause(err).WithProviderName("gemini").WithRetryable(isRetryableError(err))
+		return grail.Response{}, apiError("generate JSON", err)
+	}
+	if reason, ok := blocked(resp); ok {
+		return grail.Response{}, grail.NewGrailError(grail.Refused, "gemini blocked the response: "+reason).WithProviderName("gemini")
 	}
 
 	text := resp.Text()
 	usage := extractUsage(resp)
-
-	// Validate JSON if strict mode
-	jsonBytes := []byte(text)
-	if strict {
-		var test any
-		if err := json.Unmarshal(jsonBytes, &test); err != nil {
-			return grail.Response{}, grail.NewGrailError(grail.OutputInvalid, fmt.Sprintf("invalid JSON output: %v", err)).WithProviderName("gemini")
+	calls := extractToolCalls(resp)
+
+	// A response that only calls tools has no JSON yet.
+	var outputs []grail.OutputPart
+	if text != "" || len(calls) == 0 {
+		// Validate JSON if strict mode
+		jsonBytes := []byte(text)
+		if strict {
+			var test any
+			if err := json.Unmarshal(jsonBytes, &test); err != nil {
+				return grail.Response{}, grail.NewGrailError(grail.OutputInvalid, fmt.Sprintf("invalid JSON output: %v", err)).WithProviderName("gemini")
+			}
 		}
+		var opts []grail.JSONPartOpt
+		if config.ResponseLogprobs {
+			opts = append(opts, grail.WithTokenLogprobs(extractLogprobs(resp)))
+		}
+		outputs = append(outputs, grail.NewJSONOutputPart(jsonBytes, opts...))
 	}
 
-	if c.log != nil {
-		c.log.Debug("generate JSON response", slog.Any("usage", usage))
+	if log := c.logger(); log != nil {
+		log.Debug("generate JSON response", slog.Any("usage", usage), slog.Int("tool_calls", len(calls)))
 	}
 
 	return grail.Response{
-		Outputs: []grail.OutputPart{
-			grail.NewJSONOutputPart(jsonBytes),
-		},
-		Usage: usage,
+		Outputs: append(outputs, calls...),
+		Usage:   usage,
 		Provider: grail.ProviderInfo{
-			Name:  "gemini",
-			Route: "generate_content",
+			Name:   "gemini",
+			Route:  "generate_content",
+			Region: c.region,
 			Models: []grail.ModelUse{
-				{Role: "language", Name: modelName},
+				{Role: "language", Name: modelName, Version: resp.ModelVersion},
 			},
 		},
 		RequestID: "",
@@ -566,10 +860,80 @@ func (c *Provider) generateJSON(ctx context.Context, req grail.Request, parts []
 	}, nil
 }
 
-// toGenAIParts converts grail.Inputs to Gemini API format.
-func (c *Provider) toGenAIParts(inputs []grail.Input) ([]*genai.Part, error) {
+// extractLogprobs returns the log probabilities of the first candidate's
+// tokens, in order.
+func extractLogprobs(resp *genai.GenerateContentResponse) []grail.TokenLogprob {
+	if len(resp.Candidates) == 0 || resp.Candidates[0].LogprobsResult == nil {
+		return nil
+	}
+	var out []grail.TokenLogprob
+	for _, c := range resp.Candidates[0].LogprobsResult.ChosenCandidates {
+		if c != nil {
+			out = append(out, grail.TokenLogprob{Token: c.Token, Logprob: float64(c.LogProbability)})
+		}
+	}
+	return out
+}
+
+// historyContents converts earlier turns to Gemini API format: user turns as
+// their inputs are converted, assistant turns as model turns.
+func (c *Provider) historyContents(history []grail.Message) ([]*genai.Content, error) {
+	var contents []*genai.Content
+	for i, m := range history {
+		if m.Role == grail.TurnAssistant {
+			var parts []*genai.Part
+			for _, in := range m.Inputs {
+				if text, ok := grail.AsTextInput(in); ok {
+					parts = append(parts, genai.NewPartFromText(text))
+				}
+			}
+			contents = append(contents, genai.NewContentFromParts(parts, genai.RoleModel))
+			continue
+		}
+		user, err := c.toGenAIContents(m.Inputs)
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", i, err)
+		}
+		contents = append(contents, user...)
+	}
+	return contents, nil
+}
+
+// toGenAIContents converts grail.Inputs to Gemini API format: one user turn,
+// with each run of tool results split out into a model turn making the calls
+// and a user turn answering them.
+func (c *Provider) toGenAIContents(inputs []grail.Input) ([]*genai.Content, error) {
+	var contents []*genai.Content
 	out := make([]*genai.Part, 0, len(inputs))
+	var calls, results []*genai.Part
+	flush := func() {
+		if len(out) > 0 {
+			contents = append(contents, genai.NewContentFromParts(out, genai.RoleUser))
+			out = nil
+		}
+		if len(calls) > 0 {
+			contents = append(contents,
+				genai.NewContentFromParts(calls, genai.RoleModel),
+				genai.NewContentFromParts(results, genai.RoleUser))
+			calls, results = nil, nil
+		}
+	}
 	for i, input := range inputs {
+		if call, output, isResult := grail.AsToolResultInput(input); isResult {
+			if len(out) > 0 {
+				flush()
+			}
+			callPart, resultPart, err := toolResultParts(call, output)
+			if err != nil {
+				return nil, fmt.Errorf("input %d: %w", i, err)
+			}
+			calls, results = append(calls, callPart), append(results, resultPart)
+			continue
+		}
+		if len(calls) > 0 {
+			flush()
+		}
+
 		text, isText := grail.AsTextInput(input)
 		if isText {
 			out = append(out, genai.NewPartFromText(text))
@@ -596,7 +960,8 @@ func (c *Provider) toGenAIParts(inputs []grail.Input) ([]*genai.Part, error) {
 		// TODO: support streaming if Gemini API supports it
 		return nil, fmt.Errorf("input %d: FileReaderInput not yet supported", i)
 	}
-	return out, nil
+	flush()
+	return contents, nil
 }
 
 func (c *Provider) applyTextOptions(config *genai.GenerateContentConfig, opts TextOptions) {
@@ -616,6 +981,51 @@ func (c *Provider) applyTextOptions(config *genai.GenerateContentConfig, opts Te
 	if opts.MaxTokens != nil {
 		config.MaxOutputTokens = int32(*opts.MaxTokens)
 	}
+	if opts.TopK != nil {
+		config.TopK = genai.Ptr(*opts.TopK)
+	}
+	if opts.CandidateCount > 0 {
+		config.CandidateCount = opts.CandidateCount
+	}
+	if opts.ResponseLogprobs {
+		config.ResponseLogprobs = true
+		if opts.TopLogprobs != nil {
+			config.Logprobs = genai.Ptr(*opts.TopLogprobs)
+		}
+	}
+}
+
+// candidateTexts returns the text of every candidate in resp. A response with
+// a single candidate (the default) yields resp.Text().
+func candidateTexts(resp *genai.GenerateContentResponse) []string {
+	if len(resp.Candidates) <= 1 {
+		return []string{resp.Text()}
+	}
+	texts := make([]string, 0, len(resp.Candidates))
+	for _, cand := range resp.Candidates {
+		var b strings.Builder
+		if cand.Content != nil {
+			for _, part := range cand.Content.Parts {
+				if part != nil && !part.Thought {
+					b.WriteString(part.Text)
+				}
+			}
+		}
+		texts = append(texts, b.String())
+	}
+	return texts
+}
+
+// avgLogprobs returns each candidate's average token log probability, or nil
+// if logprobs weren't requested.
+func avgLogprobs(resp *genai.GenerateContentResponse) []float64 {
+	var out []float64
+	for _, cand := range resp.Candidates {
+		if cand.LogprobsResult != nil || cand.AvgLogprobs != 0 {
+			out = append(out, cand.AvgLogprobs)
+		}
+	}
+	return out
 }
 
 func (c *Provider) applyImageOptions(config *genai.GenerateContentConfig, opts ImageOptions, imgCfg *imageConfig) {
@@ -670,7 +1080,22 @@ func extractUsage(resp *genai.GenerateContentResponse) grail.Usage {
 		InputTokens:  int(resp.UsageMetadata.PromptTokenCount),
 		OutputTokens: int(resp.UsageMetadata.CandidatesTokenCount),
 		TotalTokens:  int(resp.UsageMetadata.TotalTokenCount),
+
+		CachedInputTokens: int(resp.UsageMetadata.CachedContentTokenCount),
+		ImageInputTokens:  imageTokens(resp.UsageMetadata.PromptTokensDetails),
+		ImageOutputTokens: imageTokens(resp.UsageMetadata.CandidatesTokensDetails),
+	}
+}
+
+// imageTokens sums the image tokens of a per-modality breakdown.
+func imageTokens(details []*genai.ModalityTokenCount) int {
+	n := 0
+	for _, d := range details {
+		if d != nil && d.Modality == genai.MediaModalityImage {
+			n += int(d.TokenCount)
+		}
 	}
+	return n
 }
 
 func extractWarnings(resp *genai.GenerateContentResponse) []grail.Warning {
@@ -679,6 +1104,40 @@ func extractWarnings(resp *genai.GenerateContentResponse) []grail.Warning {
 	return nil
 }
 
+// apiError converts an SDK error into a GrailError coded by HTTP status.
+func apiError(op string, err error) error {
+	code, retryable := grail.Internal, isRetryableError(err)
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		code, retryable = grail.CodeFromHTTPStatus(apiErr.Code)
+	}
+	return grail.NewGrailError(code, fmt.Sprintf("%s failed: %v", op, err)).WithCause(err).WithProviderName("gemini").WithRetryable(retryable)
+}
+
+// blocked reports whether Gemini blocked the prompt, or stopped every
+// candidate on a safety or policy filter before producing any content.
+func blocked(resp *genai.GenerateContentResponse) (string, bool) {
+	if fb := resp.PromptFeedback; fb != nil && fb.BlockReason != "" {
+		return "prompt blocked (" + string(fb.BlockReason) + ")", true
+	}
+	var reason genai.FinishReason
+	for _, cand := range resp.Candidates {
+		if cand.Content != nil && len(cand.Content.Parts) > 0 {
+			return "", false
+		}
+		switch cand.FinishReason {
+		case genai.FinishReasonSafety, genai.FinishReasonBlocklist, genai.FinishReasonProhibitedContent,
+			genai.FinishReasonSPII, genai.FinishReasonRecitation, genai.FinishReasonImageSafety,
+			genai.FinishReasonImageProhibitedContent, genai.FinishReasonImageRecitation:
+			reason = cand.FinishReason
+		}
+	}
+	if reason == "" {
+		return "", false
+	}
+	return "finished with " + string(reason), true
+}
+
 func isRetryableError(err error) bool {
 	// Gemini SDK errors that are retryable
 	errStr := err.Error()
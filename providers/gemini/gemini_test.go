@@ -2,9 +2,13 @@ package gemini
 
 import (
 	"context"
+	"io"
+	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/montanaflynn/grail"
+	"google.golang.org/genai"
 )
 
 // Compile-time check that Provider implements grail.Provider.
@@ -47,3 +51,150 @@ func TestGemini_New_APIKeyHandling(t *testing.T) {
 		}
 	})
 }
+
+func TestGemini_ConcurrentCatalogUpdates(t *testing.T) {
+	p, err := New(context.Background(), WithAPIKey("dummy"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			if i%2 == 0 {
+				p.SetFastTextModel(Gemini3Flash)
+			} else {
+				p.SetFastTextModel(Gemini3_5Flash)
+			}
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		name, err := p.ResolveModel(grail.ModelRoleText, grail.ModelTierFast)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if name != Gemini3Flash.Name && name != Gemini3_5Flash.Name {
+			t.Fatalf("unexpected model %q", name)
+		}
+		_ = p.AllModels()
+	}
+	<-done
+}
+
+func TestGemini_ApplyTextOptions(t *testing.T) {
+	p := &Provider{}
+	config := &genai.GenerateContentConfig{}
+	p.applyTextOptions(config, TextOptions{
+		TopK:             genai.Ptr[float32](40),
+		CandidateCount:   3,
+		ResponseLogprobs: true,
+		TopLogprobs:      genai.Ptr[int32](5),
+	})
+	if config.TopK == nil || *config.TopK != 40 || config.CandidateCount != 3 {
+		t.Fatalf("unexpected sampling config: %+v", config)
+	}
+	if !config.ResponseLogprobs || config.Logprobs == nil || *config.Logprobs != 5 {
+		t.Fatalf("unexpected logprobs config: %+v", config)
+	}
+
+	resp := &genai.GenerateContentResponse{Candidates: []*genai.Candidate{
+		{Content: genai.NewContentFromText("first", genai.RoleModel), AvgLogprobs: -0.5},
+		{Content: genai.NewContentFromText("second", genai.RoleModel), AvgLogprobs: -1.5},
+	}}
+	if texts := candidateTexts(resp); len(texts) != 2 || texts[0] != "first" || texts[1] != "second" {
+		t.Fatalf("unexpected candidate texts: %q", texts)
+	}
+	if lp := avgLogprobs(resp); len(lp) != 2 || lp[1] != -1.5 {
+		t.Fatalf("unexpected logprobs: %v", lp)
+	}
+}
+
+func TestGemini_Vertex(t *testing.T) {
+	var url string
+	hc := &http.Client{Transport: stubTransport(func(r *http.Request) (*http.Response, error) {
+		url = r.URL.String()
+		res := `{"candidates":[{"content":{"role":"model","parts":[{"text":"hi"}]},"finishReason":"STOP"}]}`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(res)),
+			Request:    r,
+		}, nil
+	})}
+	p, err := New(context.Background(), WithVertex("acme", "europe-west4"), WithHTTPClient(hc))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res, err := p.DoGenerate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("hello")},
+		Output: grail.OutputText(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "https://europe-west4-aiplatform.googleapis.com/v1beta1/projects/acme/locations/europe-west4/publishers/google/models/" + DefaultTextModelName + ":generateContent"
+	if url != want || res.Provider.Region != "europe-west4" {
+		t.Fatalf("expected the regional Vertex AI endpoint, got %s and region %q", url, res.Provider.Region)
+	}
+}
+
+func TestGemini_Stream(t *testing.T) {
+	var path string
+	hc := &http.Client{Transport: stubTransport(func(r *http.Request) (*http.Response, error) {
+		path = r.URL.Path
+		chunks := []string{
+			`{"candidates":[{"content":{"role":"model","parts":[{"text":"Hello"}]}}]}`,
+			`{"candidates":[{"content":{"role":"model","parts":[{"text":" there."}]},"finishReason":"STOP"}],
+				"usageMetadata":{"promptTokenCount":3,"candidatesTokenCount":2,"totalTokenCount":5}}`,
+		}
+		var events strings.Builder
+		for _, chunk := range chunks {
+			events.WriteString("data: " + strings.Join(strings.Fields(chunk), " ") + "\n\n")
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       io.NopCloser(strings.NewReader(events.String())),
+			Request:    r,
+		}, nil
+	})}
+	p, err := New(context.Background(), WithAPIKey("dummy"), WithHTTPClient(hc))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var deltas []string
+	res, err := p.StreamGenerate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("hello")},
+		Output: grail.OutputText(),
+	}, func(text string) { deltas = append(deltas, text) })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(path, ":streamGenerateContent") {
+		t.Errorf("expected the streaming endpoint, got %s", path)
+	}
+	if text, _ := res.Text(); strings.Join(deltas, "|") != "Hello| there." || text != "Hello there." || res.Usage.OutputTokens != 2 {
+		t.Fatalf("unexpected stream %q for %q (%+v)", deltas, text, res.Usage)
+	}
+}
+
+func TestExtractUsage_ImageTokens(t *testing.T) {
+	resp := &genai.GenerateContentResponse{UsageMetadata: &genai.GenerateContentResponseUsageMetadata{
+		PromptTokenCount:     300,
+		CandidatesTokenCount: 1300,
+		TotalTokenCount:      1600,
+		PromptTokensDetails: []*genai.ModalityTokenCount{
+			{Modality: genai.MediaModalityText, TokenCount: 42},
+			{Modality: genai.MediaModalityImage, TokenCount: 258},
+		},
+		CandidatesTokensDetails: []*genai.ModalityTokenCount{
+			{Modality: genai.MediaModalityImage, TokenCount: 1290},
+			{Modality: genai.MediaModalityText, TokenCount: 10},
+		},
+	}}
+	u := extractUsage(resp)
+	if u.ImageInputTokens != 258 || u.ImageOutputTokens != 1290 || u.InputTokens != 300 {
+		t.Errorf("unexpected usage %+v", u)
+	}
+}
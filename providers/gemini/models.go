@@ -31,6 +31,16 @@ var (
 			ImageUnderstanding: true,
 		},
 	}
+
+	// Veo3_1 is the best quality video generation model, with native audio.
+	Veo3_1 = grail.Model{
+		Name: "veo-3.1-generate-preview",
+		Role: grail.ModelRoleVideo,
+		Tier: grail.ModelTierBest,
+		Capabilities: grail.ModelCapabilities{
+			VideoGeneration: true,
+		},
+	}
 )
 
 // Fast models - speed/cost optimized
@@ -60,6 +70,16 @@ var (
 			ImageUnderstanding: true,
 		},
 	}
+
+	// Veo3_1Fast is a faster, cheaper video generation model.
+	Veo3_1Fast = grail.Model{
+		Name: "veo-3.1-fast-generate-preview",
+		Role: grail.ModelRoleVideo,
+		Tier: grail.ModelTierFast,
+		Capabilities: grail.ModelCapabilities{
+			VideoGeneration: true,
+		},
+	}
 )
 
 // Other models - available but not set as default best/fast
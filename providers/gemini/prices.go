@@ -0,0 +1,29 @@
+package gemini
+
+import (
+	"maps"
+
+	"github.com/montanaflynn/grail"
+)
+
+// ListPrices are the Gemini API's paid-tier list prices, in USD per million
+// tokens, for prompts up to 200K tokens. Longer prompts, batch requests, and
+// Vertex AI discounts aren't reflected, and Veo, billed per second of
+// video, isn't listed. Models missing here get no Cost; correct or extend
+// the table with grail.WithPrices.
+var ListPrices = grail.PriceTable{
+	Gemini3_1Pro.Name:           {InputPerMTok: 2, OutputPerMTok: 12, CachedInputPerMTok: 0.20},
+	Gemini3Pro.Name:             {InputPerMTok: 2, OutputPerMTok: 12, CachedInputPerMTok: 0.20},
+	Gemini3Flash.Name:           {InputPerMTok: 0.50, OutputPerMTok: 3, CachedInputPerMTok: 0.05},
+	Gemini3ProImage.Name:        {InputPerMTok: 2, OutputPerMTok: 12, ImageOutputPerMTok: 120},
+	Gemini3ProImagePreview.Name: {InputPerMTok: 2, OutputPerMTok: 12, ImageOutputPerMTok: 120},
+	Gemini25Flash.Name:          {InputPerMTok: 0.30, OutputPerMTok: 2.50, CachedInputPerMTok: 0.03},
+	Gemini25FlashLite.Name:      {InputPerMTok: 0.10, OutputPerMTok: 0.40, CachedInputPerMTok: 0.01},
+	Gemini25FlashImage.Name:     {InputPerMTok: 0.30, OutputPerMTok: 2.50, ImageOutputPerMTok: 30},
+}
+
+// Prices implements grail.PriceLister with a copy of ListPrices, so callers
+// can change it without affecting other providers.
+func (p *Provider) Prices() grail.PriceTable {
+	return maps.Clone(ListPrices)
+}
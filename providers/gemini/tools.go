@@ -0,0 +1,75 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/montanaflynn/grail"
+	"google.golang.org/genai"
+)
+
+// toFunctionTools converts grail tools to Gemini function declarations. The
+// parameter schemas are passed as JSON Schema, as with response schemas.
+func toFunctionTools(tools []grail.Tool) []*genai.Tool {
+	if len(tools) == 0 {
+		return nil
+	}
+	decls := make([]*genai.FunctionDeclaration, 0, len(tools))
+	for _, t := range tools {
+		decls = append(decls, &genai.FunctionDeclaration{
+			Name:                 t.Name,
+			Description:          t.Description,
+			ParametersJsonSchema: t.Parameters,
+		})
+	}
+	return []*genai.Tool{{FunctionDeclarations: decls}}
+}
+
+// toolResultParts returns the function call part that produced a tool result
+// and the function response part answering it. Outputs that are JSON objects
+// are sent as the response; anything else is wrapped as {"output": ...}.
+func toolResultParts(call grail.ToolCall, output string) (*genai.Part, *genai.Part, error) {
+	var args map[string]any
+	if len(call.Arguments) > 0 {
+		if err := json.Unmarshal(call.Arguments, &args); err != nil {
+			return nil, nil, fmt.Errorf("tool call %s: arguments must be a JSON object: %w", call.Name, err)
+		}
+	}
+	var response map[string]any
+	if err := json.Unmarshal([]byte(output), &response); err != nil || response == nil {
+		response = map[string]any{"output": output}
+	}
+	callPart := &genai.Part{
+		FunctionCall:     &genai.FunctionCall{ID: call.ID, Name: call.Name, Args: args},
+		ThoughtSignature: call.Signature,
+	}
+	resultPart := &genai.Part{
+		FunctionResponse: &genai.FunctionResponse{ID: call.ID, Name: call.Name, Response: response},
+	}
+	return callPart, resultPart, nil
+}
+
+// extractToolCalls returns the function calls in the first candidate of resp
+// as output parts.
+func extractToolCalls(resp *genai.GenerateContentResponse) []grail.OutputPart {
+	if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return nil
+	}
+	var parts []grail.OutputPart
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if part == nil || part.FunctionCall == nil {
+			continue
+		}
+		args, err := json.Marshal(part.FunctionCall.Args)
+		if err != nil || part.FunctionCall.Args == nil {
+			args = []byte("{}")
+		}
+		parts = append(parts, grail.NewToolCallOutputPart(grail.ToolCall{
+			ID:        part.FunctionCall.ID,
+			Name:      part.FunctionCall.Name,
+			Arguments: args,
+			Signature: part.ThoughtSignature,
+		}))
+	}
+	return parts
+}
@@ -0,0 +1,165 @@
+package gemini
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/montanaflynn/grail"
+	"google.golang.org/genai"
+)
+
+// DefaultVideoModelName is the Veo model used for video output when no
+// override is provided.
+const DefaultVideoModelName = "veo-3.1-generate-preview"
+
+// videoPollInterval is how often a video generation operation is checked.
+var videoPollInterval = 10 * time.Second
+
+// VideoOptions provides Veo-specific video generation options.
+type VideoOptions struct {
+	Model          string
+	NegativePrompt string // what the video shouldn't show
+	Resolution     string // "720p" or "1080p"
+	GenerateAudio  *bool  // Veo 3 models generate audio unless false
+}
+
+func (VideoOptions) ApplyProviderOption() {}
+
+// generateVideo starts a Veo generation operation and polls it until the
+// video is ready.
+func (c *Provider) generateVideo(ctx context.Context, req grail.Request, spec grail.VideoSpec) (grail.Response, error) {
+	var opts VideoOptions
+	for _, opt := range req.ProviderOptions {
+		if vo, ok := opt.(VideoOptions); ok {
+			opts = vo
+		}
+	}
+	modelName := DefaultVideoModelName
+	if opts.Model != "" {
+		modelName = opts.Model
+	}
+	if req.Model != "" {
+		modelName = req.Model
+	}
+
+	prompt, image, err := videoPrompt(req.Inputs)
+	if err != nil {
+		return grail.Response{}, grail.NewGrailError(grail.InvalidArgument, err.Error()).WithProviderName("gemini")
+	}
+	config := &genai.GenerateVideosConfig{
+		NumberOfVideos: 1,
+		AspectRatio:    spec.AspectRatio,
+		NegativePrompt: opts.NegativePrompt,
+		Resolution:     opts.Resolution,
+		GenerateAudio:  opts.GenerateAudio,
+	}
+	if spec.DurationSeconds > 0 {
+		d := int32(spec.DurationSeconds)
+		config.DurationSeconds = &d
+	}
+
+	if log := c.logger(); log != nil {
+		log.Debug("generate video request", slog.String("model", modelName))
+	}
+	op, err := c.client.Models.GenerateVideos(ctx, modelName, prompt, image, config)
+	if err != nil {
+		return grail.Response{}, apiError("generate video", err)
+	}
+	for !op.Done {
+		select {
+		case <-ctx.Done():
+			return grail.Response{}, grail.NewGrailError(grail.Timeout, fmt.Sprintf("video generation %s didn't finish: %v", op.Name, ctx.Err())).
+				WithCause(ctx.Err()).WithProviderName("gemini").WithDetail("operation", op.Name)
+		case <-time.After(videoPollInterval):
+		}
+		if op, err = c.client.Operations.GetVideosOperation(ctx, op, nil); err != nil {
+			return grail.Response{}, apiError("poll video generation", err)
+		}
+	}
+	if op.Error != nil {
+		return grail.Response{}, operationError(op.Error)
+	}
+	if op.Response == nil || len(op.Response.GeneratedVideos) == 0 {
+		if op.Response != nil && op.Response.RAIMediaFilteredCount > 0 {
+			return grail.Response{}, grail.NewGrailError(grail.Refused, "gemini blocked the video: "+strings.Join(op.Response.RAIMediaFilteredReasons, "; ")).WithProviderName("gemini")
+		}
+		return grail.Response{}, grail.NewGrailError(grail.Internal, "video generation returned no video").WithProviderName("gemini")
+	}
+
+	// Veo watermarks every video with SynthID.
+	var outputs []grail.OutputPart
+	for _, v := range op.Response.GeneratedVideos {
+		if v.Video == nil {
+			continue
+		}
+		data := v.Video.VideoBytes
+		if len(data) == 0 {
+			if data, err = c.client.Files.Download(ctx, genai.NewDownloadURIFromGeneratedVideo(v), nil); err != nil {
+				return grail.Response{}, apiError("download video", err)
+			}
+		}
+		mime := v.Video.MIMEType
+		if mime == "" {
+			mime = "video/mp4"
+		}
+		outputs = append(outputs, grail.NewVideoOutputPart(data, mime, "", grail.WithSynthID()))
+	}
+
+	if log := c.logger(); log != nil {
+		log.Debug("generate video response", slog.Int("videos", len(outputs)), slog.String("operation", op.Name))
+	}
+	return grail.Response{
+		Outputs: outputs,
+		Provider: grail.ProviderInfo{
+			Name:   "gemini",
+			Route:  "generate_videos",
+			Region: c.region,
+			Models: []grail.ModelUse{{Role: "video_generation", Name: modelName}},
+		},
+		RequestID: op.Name,
+	}, nil
+}
+
+// videoPrompt splits inputs into the prompt and an optional first frame.
+func videoPrompt(inputs []grail.Input) (string, *genai.Image, error) {
+	var prompt []string
+	var image *genai.Image
+	for i, in := range inputs {
+		if text, ok := grail.AsTextInput(in); ok {
+			prompt = append(prompt, text)
+			continue
+		}
+		data, mime, _, ok := grail.AsFileInput(in)
+		if mime == "" {
+			mime = grail.SniffImageMIME(data)
+		}
+		if !ok || !strings.HasPrefix(mime, "image/") || image != nil {
+			return "", nil, fmt.Errorf("input %d: video prompts take text and at most one image", i)
+		}
+		image = &genai.Image{ImageBytes: data, MIMEType: mime}
+	}
+	return strings.Join(prompt, "\n\n"), image, nil
+}
+
+// operationError converts a failed long-running operation's status, coded
+// by its gRPC status code.
+func operationError(status map[string]any) error {
+	msg, _ := status["message"].(string)
+	code, retryable := grail.Internal, false
+	switch c, _ := status["code"].(float64); int(c) {
+	case 3, 9, 11: // INVALID_ARGUMENT, FAILED_PRECONDITION, OUT_OF_RANGE
+		code = grail.InvalidArgument
+	case 7, 16: // PERMISSION_DENIED, UNAUTHENTICATED
+		code = grail.Unauthorized
+	case 8: // RESOURCE_EXHAUSTED
+		code, retryable = grail.RateLimited, true
+	case 4: // DEADLINE_EXCEEDED
+		code, retryable = grail.Timeout, true
+	case 14: // UNAVAILABLE
+		code, retryable = grail.Unavailable, true
+	}
+	return grail.NewGrailError(code, "video generation failed: "+msg).WithProviderName("gemini").WithRetryable(retryable)
+}
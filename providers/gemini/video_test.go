@@ -0,0 +1,113 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+)
+
+func TestGemini_GenerateVideo(t *testing.T) {
+	videoPollInterval = time.Millisecond
+	t.Cleanup(func() { videoPollInterval = 10 * time.Second })
+
+	var polls atomic.Int32
+	var started map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1beta/models/" + DefaultVideoModelName + ":predictLongRunning":
+			json.NewDecoder(r.Body).Decode(&started)
+			io.WriteString(w, `{"name":"models/veo/operations/op1"}`)
+		case "/v1beta/models/veo/operations/op1":
+			if polls.Add(1) < 2 {
+				io.WriteString(w, `{"name":"models/veo/operations/op1"}`)
+				return
+			}
+			io.WriteString(w, `{"name":"models/veo/operations/op1","done":true,"response":{"generateVideoResponse":{"generatedSamples":[{"video":{"uri":"`+
+				"http://"+r.Host+`/v1beta/files/abc123:download?alt=media"}}]}}}`)
+		case "/v1beta/files/abc123:download":
+			w.Header().Set("Content-Type", "video/mp4")
+			io.WriteString(w, "mp4 data")
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	p, err := New(context.Background(), WithAPIKey("dummy"), WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res, err := grail.NewClient(p).Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("A fox running through snow")},
+		Output: grail.OutputVideo(grail.VideoSpec{DurationSeconds: 8, AspectRatio: "9:16"}),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	videos := res.Videos()
+	if len(videos) != 1 || string(videos[0].Data) != "mp4 data" || videos[0].MIME != "video/mp4" || !videos[0].SynthID {
+		t.Fatalf("unexpected videos %+v", videos)
+	}
+	if polls.Load() != 2 || res.RequestID != "models/veo/operations/op1" {
+		t.Errorf("expected the operation to be polled until done, got %d polls and request ID %q", polls.Load(), res.RequestID)
+	}
+	params, _ := started["parameters"].(map[string]any)
+	if params["durationSeconds"] != 8.0 || params["aspectRatio"] != "9:16" {
+		t.Errorf("expected the spec in the request parameters, got %v", started)
+	}
+}
+
+func TestGemini_GenerateVideoErrors(t *testing.T) {
+	videoPollInterval = time.Millisecond
+	t.Cleanup(func() { videoPollInterval = 10 * time.Second })
+
+	cases := []struct {
+		name string
+		body string
+		code grail.ErrorCode
+	}{
+		{"failed", `{"name":"op","done":true,"error":{"code":8,"message":"quota exceeded"}}`, grail.RateLimited},
+		{"filtered", `{"name":"op","done":true,"response":{"generateVideoResponse":{"raiMediaFilteredCount":1,"raiMediaFilteredReasons":["celebrity"]}}}`, grail.Refused},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, tc.body)
+			}))
+			t.Cleanup(srv.Close)
+			p, err := New(context.Background(), WithAPIKey("dummy"), WithBaseURL(srv.URL))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			_, err = p.DoGenerate(context.Background(), grail.Request{
+				Inputs: []grail.Input{grail.InputText("A fox")},
+				Output: grail.OutputVideo(grail.VideoSpec{}),
+			})
+			if got := grail.GetErrorCode(err); got != tc.code {
+				t.Errorf("expected %s, got %v", tc.code, err)
+			}
+		})
+	}
+
+	p, err := New(context.Background(), WithAPIKey("dummy"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = p.DoGenerate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("A fox"), grail.InputPDF([]byte("%PDF"))},
+		Output: grail.OutputVideo(grail.VideoSpec{}),
+	})
+	if grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected InvalidArgument for a PDF input, got %v", err)
+	}
+}
@@ -35,9 +35,11 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/internal/httplog"
 )
 
 const (
@@ -117,7 +119,9 @@ type Provider struct {
 	imageModel string
 	baseURL    string
 	httpClient *http.Client
+	mu         sync.RWMutex // guards log and the model catalog slots
 	log        *slog.Logger
+	transport  *httplog.Transport
 
 	// Model catalog slots
 	bestImageModel grail.Model
@@ -153,9 +157,15 @@ func New(opts ...Option) (*Provider, error) {
 		apiKey:     s.apiKey,
 		imageModel: s.imageModel,
 		baseURL:    s.baseURL,
-		httpClient: s.httpClient,
 		log:        s.logger,
 	}
+	p.transport = &httplog.Transport{
+		Logger:   p.logger,
+		Provider: "modelslab",
+		Level:    slog.LevelDebug,
+		MaxBody:  httplog.DefaultMaxBody,
+	}
+	p.httpClient = httplog.Wrap(s.httpClient, p.transport)
 
 	// Default model catalog
 	p.bestImageModel = Flux
@@ -168,7 +178,31 @@ func New(opts ...Option) (*Provider, error) {
 func (p *Provider) Name() string { return "modelslab" }
 
 // SetLogger implements grail.LoggerAware.
-func (p *Provider) SetLogger(l *slog.Logger) { p.log = l }
+func (p *Provider) SetLogger(l *slog.Logger) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.log = l
+}
+
+func (p *Provider) logger() *slog.Logger {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.log
+}
+
+// Capabilities implements grail.CapabilityReporter. Only text-to-image
+// generation is supported.
+func (p *Provider) Capabilities() grail.ProviderCapabilities {
+	return grail.ProviderCapabilities{
+		ImageOutput:  true,
+		ModelListing: true,
+	}
+}
+
+// WrapTransport implements grail.TransportAware.
+func (p *Provider) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	p.transport.WrapBase(wrap)
+}
 
 // ListModels implements grail.ModelLister.
 func (p *Provider) ListModels(_ context.Context) ([]grail.Model, error) {
@@ -177,6 +211,8 @@ func (p *Provider) ListModels(_ context.Context) ([]grail.Model, error) {
 
 // ResolveModel implements grail.ModelResolver for tier-based model selection.
 func (p *Provider) ResolveModel(role grail.ModelRole, tier grail.ModelTier) (string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	if role != grail.ModelRoleImage {
 		return "", grail.NewGrailError(grail.Unsupported,
 			fmt.Sprintf("modelslab: unsupported model role %q (only %q is supported)", role, grail.ModelRoleImage))
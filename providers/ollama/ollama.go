@@ -0,0 +1,547 @@
+// Package ollama provides an Ollama implementation of the grail.Provider
+// interface, for models running locally (or on a host you run) rather than
+// behind a hosted API. It supports text and JSON output, and image inputs for
+// multimodal models such as llava.
+//
+// Example usage:
+//
+//	provider, err := ollama.New(ollama.WithTextModel("llama3.2"))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	client := grail.NewClient(provider)
+//	res, err := client.Generate(ctx, grail.Request{
+//		Inputs: []grail.Input{grail.InputText("Why is the sky blue?")},
+//		Output: grail.OutputText(),
+//	})
+//
+// The provider talks to http://localhost:11434 unless overridden with
+// WithBaseURL or the OLLAMA_HOST environment variable. No API key is needed.
+//
+// API docs: https://github.com/ollama/ollama/blob/main/docs/api.md
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/internal/httplog"
+	"github.com/montanaflynn/grail/internal/jsonschema"
+)
+
+const (
+	// DefaultBaseURL is the address the Ollama server listens on by default.
+	DefaultBaseURL = "http://localhost:11434"
+
+	// DefaultTextModel is the default model used when none is specified.
+	DefaultTextModel = "llama3.2"
+
+	// DefaultEmbeddingModel is the model used by Embed when none is
+	// specified.
+	DefaultEmbeddingModel = "nomic-embed-text"
+
+	// WarningTruncated is the Response.Warnings code for output cut off at
+	// the token limit.
+	WarningTruncated = "ollama_truncated"
+)
+
+// visionFamilies are the model families (as reported by /api/tags) whose
+// models accept images.
+var visionFamilies = []string{"clip", "mllama", "gemma3", "qwen25vl", "llava"}
+
+// Option configures the Ollama provider.
+type Option func(*settings)
+
+type settings struct {
+	textModel      string
+	embeddingModel string
+	baseURL        string
+	httpClient     *http.Client
+	logger         *slog.Logger
+}
+
+// WithTextModel overrides the default text model (default: "llama3.2"). The
+// model must already be pulled on the server.
+func WithTextModel(model string) Option {
+	return func(s *settings) { s.textModel = model }
+}
+
+// WithEmbeddingModel overrides the model used by Embed (default:
+// "nomic-embed-text").
+func WithEmbeddingModel(model string) Option {
+	return func(s *settings) { s.embeddingModel = model }
+}
+
+// WithBaseURL overrides the server address (default: OLLAMA_HOST, or
+// http://localhost:11434).
+func WithBaseURL(url string) Option {
+	return func(s *settings) { s.baseURL = url }
+}
+
+// WithHTTPClient sets a custom HTTP client.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(s *settings) { s.httpClient = hc }
+}
+
+// WithLogger sets a custom logger for provider-level logs.
+func WithLogger(l *slog.Logger) Option {
+	return func(s *settings) {
+		if l != nil {
+			s.logger = l
+		}
+	}
+}
+
+// TextOptions provides Ollama-specific text generation options. Unset fields
+// use the model's defaults from its Modelfile.
+type TextOptions struct {
+	Model        string
+	SystemPrompt string
+	Temperature  *float64
+	TopP         *float64
+	TopK         *int
+	NumPredict   *int // maximum tokens to generate
+	NumCtx       *int // context window size
+	Seed         *int
+	// KeepAlive is how long the server keeps the model loaded after the
+	// request, as a duration string ("5m", "1h", "-1" for indefinitely).
+	KeepAlive string
+}
+
+func (TextOptions) ApplyProviderOption() {}
+
+// Provider is an Ollama-backed implementation of grail.Provider.
+type Provider struct {
+	textModel      string
+	embeddingModel string
+	baseURL        string
+	httpClient     *http.Client
+	mu             sync.RWMutex // guards log
+	log            *slog.Logger
+	transport      *httplog.Transport
+}
+
+// New creates a new Ollama provider. It doesn't contact the server; a server
+// that isn't running surfaces as an Unavailable error from the first request.
+func New(opts ...Option) (*Provider, error) {
+	s := &settings{
+		textModel:      DefaultTextModel,
+		embeddingModel: DefaultEmbeddingModel,
+		baseURL:        baseURLFromEnv(),
+		httpClient:     &http.Client{Timeout: 10 * time.Minute}, // local models can be slow to load
+		logger:         slog.Default(),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	p := &Provider{
+		textModel:      s.textModel,
+		embeddingModel: s.embeddingModel,
+		baseURL:        strings.TrimRight(s.baseURL, "/"),
+		log:            s.logger,
+	}
+	p.transport = &httplog.Transport{
+		Logger:   p.logger,
+		Provider: "ollama",
+		Level:    slog.LevelDebug,
+		MaxBody:  httplog.DefaultMaxBody,
+	}
+	p.httpClient = httplog.Wrap(s.httpClient, p.transport)
+
+	return p, nil
+}
+
+// baseURLFromEnv reads OLLAMA_HOST the way the ollama CLI does, where the
+// scheme is optional.
+func baseURLFromEnv() string {
+	host := strings.TrimSpace(os.Getenv("OLLAMA_HOST"))
+	if host == "" {
+		return DefaultBaseURL
+	}
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	return host
+}
+
+// Name returns the provider name.
+func (p *Provider) Name() string { return "ollama" }
+
+// SetLogger implements grail.LoggerAware.
+func (p *Provider) SetLogger(l *slog.Logger) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.log = l
+}
+
+func (p *Provider) logger() *slog.Logger {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.log
+}
+
+// Capabilities implements grail.CapabilityReporter. JSON output is
+// constrained by the request's schema; text files are inlined into the
+// prompt.
+func (p *Provider) Capabilities() grail.ProviderCapabilities {
+	return grail.ProviderCapabilities{
+		TextOutput:     true,
+		JSONOutput:     true,
+		NativeJSON:     true,
+		InputMIMETypes: []string{"image/*", "text/*", "application/json"},
+		History:        true,
+		ModelListing:   true,
+	}
+}
+
+// WrapTransport implements grail.TransportAware.
+func (p *Provider) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	p.transport.WrapBase(wrap)
+}
+
+// ResolveModel implements grail.ModelResolver. A local server has whatever
+// models were pulled, so every tier resolves to the configured text model.
+func (p *Provider) ResolveModel(role grail.ModelRole, _ grail.ModelTier) (string, error) {
+	if role != grail.ModelRoleText {
+		return "", grail.NewGrailError(grail.Unsupported,
+			fmt.Sprintf("ollama: unsupported model role %q (only %q is supported)", role, grail.ModelRoleText))
+	}
+	return p.textModel, nil
+}
+
+// tagsResponse is the JSON response from /api/tags.
+type tagsResponse struct {
+	Models []struct {
+		Name    string `json:"name"`
+		Details struct {
+			Family   string   `json:"family"`
+			Families []string `json:"families"`
+		} `json:"details"`
+	} `json:"models"`
+}
+
+// ListModels implements grail.ModelLister with the models pulled on the
+// server, from /api/tags. Models in a vision family (llava, mllama, ...) are
+// reported with ImageUnderstanding.
+func (p *Provider) ListModels(ctx context.Context) ([]grail.Model, error) {
+	var tags tagsResponse
+	if err := p.do(ctx, http.MethodGet, "/api/tags", nil, &tags); err != nil {
+		return nil, err
+	}
+	models := make([]grail.Model, 0, len(tags.Models))
+	for _, m := range tags.Models {
+		families := append([]string{m.Details.Family}, m.Details.Families...)
+		vision := slices.ContainsFunc(families, func(f string) bool { return slices.Contains(visionFamilies, f) })
+		models = append(models, grail.Model{
+			Name: m.Name,
+			Role: grail.ModelRoleText,
+			Capabilities: grail.ModelCapabilities{
+				TextGeneration:     true,
+				ImageUnderstanding: vision,
+				JSONOutput:         true,
+			},
+		})
+		// Models pulled without a tag are listed as name:latest but can be
+		// requested by name alone.
+		if name, ok := strings.CutSuffix(m.Name, ":latest"); ok {
+			alias := models[len(models)-1]
+			alias.Name = name
+			models = append(models, alias)
+		}
+	}
+	return models, nil
+}
+
+// LiveModels implements grail.LiveModelLister.
+func (p *Provider) LiveModels(ctx context.Context) ([]string, error) {
+	models, err := p.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(models))
+	for i, m := range models {
+		names[i] = m.Name
+	}
+	return names, nil
+}
+
+// ConfiguredModels implements grail.LiveModelLister.
+func (p *Provider) ConfiguredModels() map[string]string {
+	return map[string]string{"text": p.textModel}
+}
+
+// Embed implements grail.Embedder using /api/embed, so a
+// grail.SemanticCache can match prompts without leaving the machine.
+func (p *Provider) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	var out struct {
+		Embeddings [][]float64 `json:"embeddings"`
+	}
+	in := map[string]any{"model": p.embeddingModel, "input": texts}
+	if err := p.do(ctx, http.MethodPost, "/api/embed", in, &out); err != nil {
+		return nil, err
+	}
+	if len(out.Embeddings) != len(texts) {
+		return nil, grail.NewGrailError(grail.OutputInvalid, "Ollama returned the wrong number of embeddings").WithProviderName(p.Name())
+	}
+	return out.Embeddings, nil
+}
+
+// chatMessage is a message in a /api/chat request or response.
+type chatMessage struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"` // base64, without a data: prefix
+}
+
+// chatRequest is the JSON body sent to /api/chat.
+type chatRequest struct {
+	Model     string         `json:"model"`
+	Messages  []chatMessage  `json:"messages"`
+	Stream    bool           `json:"stream"`
+	Format    any            `json:"format,omitempty"` // "json" or a JSON schema
+	Options   map[string]any `json:"options,omitempty"`
+	KeepAlive string         `json:"keep_alive,omitempty"`
+}
+
+// chatResponse is the JSON response from /api/chat with streaming off.
+type chatResponse struct {
+	Model           string      `json:"model"`
+	CreatedAt       string      `json:"created_at"`
+	Message         chatMessage `json:"message"`
+	DoneReason      string      `json:"done_reason"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	EvalCount       int         `json:"eval_count"`
+}
+
+// DoGenerate implements grail.ProviderExecutor.
+func (p *Provider) DoGenerate(ctx context.Context, req grail.Request) (grail.Response, error) {
+	if _, ok := grail.GetImageSpec(req.Output); ok {
+		return grail.Response{}, grail.NewGrailError(grail.Unsupported,
+			"ollama: image output is not supported (use grail.OutputText or grail.OutputJSON)").
+			WithProviderName(p.Name())
+	}
+
+	var opts TextOptions
+	for _, opt := range req.ProviderOptions {
+		if to, ok := opt.(TextOptions); ok {
+			opts = to
+		}
+	}
+	model := req.Model
+	if opts.Model != "" {
+		model = opts.Model
+	}
+	if model == "" {
+		model = p.textModel
+	}
+
+	msg, err := p.userMessage(req.Inputs)
+	if err != nil {
+		return grail.Response{}, err
+	}
+	body := chatRequest{Model: model, KeepAlive: opts.KeepAlive}
+	if opts.SystemPrompt != "" {
+		body.Messages = append(body.Messages, chatMessage{Role: "system", Content: opts.SystemPrompt})
+	}
+	for _, m := range req.History {
+		if m.Role == grail.TurnAssistant {
+			var texts []string
+			for _, in := range m.Inputs {
+				if text, ok := grail.AsTextInput(in); ok {
+					texts = append(texts, text)
+				}
+			}
+			body.Messages = append(body.Messages, chatMessage{Role: "assistant", Content: strings.Join(texts, "\n\n")})
+			continue
+		}
+		user, err := p.userMessage(m.Inputs)
+		if err != nil {
+			return grail.Response{}, err
+		}
+		body.Messages = append(body.Messages, user)
+	}
+	body.Messages = append(body.Messages, msg)
+	body.Options = modelOptions(opts)
+
+	route := "chat"
+	if schema, _, ok := grail.GetJSONOutput(req.Output); ok {
+		s, err := jsonschema.Normalize(schema)
+		if err != nil {
+			return grail.Response{}, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("ollama: %v", err)).
+				WithCause(err).WithProviderName(p.Name())
+		}
+		body.Format = "json"
+		if s != nil {
+			body.Format = s
+		}
+		route = "chat/json"
+	}
+
+	var out chatResponse
+	if err := p.do(ctx, http.MethodPost, "/api/chat", body, &out); err != nil {
+		return grail.Response{}, err
+	}
+
+	part := grail.NewTextOutputPart(out.Message.Content)
+	if body.Format != nil {
+		part = grail.NewJSONOutputPart([]byte(out.Message.Content))
+	}
+	res := grail.Response{
+		Outputs: []grail.OutputPart{part},
+		Usage: grail.Usage{
+			InputTokens:  out.PromptEvalCount,
+			OutputTokens: out.EvalCount,
+			TotalTokens:  out.PromptEvalCount + out.EvalCount,
+		},
+		Provider: grail.ProviderInfo{
+			Name:   p.Name(),
+			Route:  route,
+			Models: []grail.ModelUse{{Role: "language", Name: model}},
+		},
+	}
+	if out.DoneReason == "length" {
+		res.Warnings = append(res.Warnings, grail.Warning{Code: WarningTruncated,
+			Message: "ollama: output was truncated at the token limit (raise TextOptions.NumPredict)"})
+	}
+	return res, nil
+}
+
+// userMessage builds the user turn: text inputs and text files joined into
+// the content, images attached.
+func (p *Provider) userMessage(inputs []grail.Input) (chatMessage, error) {
+	msg := chatMessage{Role: "user"}
+	var texts []string
+	for _, input := range inputs {
+		if text, ok := grail.AsTextInput(input); ok {
+			texts = append(texts, text)
+			continue
+		}
+		data, mime, name, ok := grail.AsFileInput(input)
+		if !ok {
+			r, _, rmime, rname, isReader := grail.AsFileReaderInput(input)
+			if !isReader {
+				continue
+			}
+			var err error
+			if data, err = io.ReadAll(r); err != nil {
+				return msg, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("ollama: read file input %q: %v", rname, err)).
+					WithCause(err).WithProviderName(p.Name())
+			}
+			mime, name = rmime, rname
+		}
+		if mime == "" {
+			mime = grail.SniffImageMIME(data)
+		}
+		switch {
+		case strings.HasPrefix(mime, "image/"):
+			msg.Images = append(msg.Images, base64.StdEncoding.EncodeToString(data))
+		case strings.HasPrefix(mime, "text/"), mime == "application/json":
+			texts = append(texts, fmt.Sprintf("File %s:\n%s", name, data))
+		default:
+			return msg, grail.NewGrailError(grail.Unsupported,
+				fmt.Sprintf("ollama: unsupported file input type %q", mime)).WithProviderName(p.Name())
+		}
+	}
+	if len(texts) == 0 && len(msg.Images) == 0 {
+		return msg, grail.NewGrailError(grail.InvalidArgument,
+			"ollama: at least one text or image input is required").WithProviderName(p.Name())
+	}
+	msg.Content = strings.Join(texts, "\n\n")
+	return msg, nil
+}
+
+// modelOptions maps TextOptions to the request's options object.
+func modelOptions(o TextOptions) map[string]any {
+	m := map[string]any{}
+	if o.Temperature != nil {
+		m["temperature"] = *o.Temperature
+	}
+	if o.TopP != nil {
+		m["top_p"] = *o.TopP
+	}
+	if o.TopK != nil {
+		m["top_k"] = *o.TopK
+	}
+	if o.NumPredict != nil {
+		m["num_predict"] = *o.NumPredict
+	}
+	if o.NumCtx != nil {
+		m["num_ctx"] = *o.NumCtx
+	}
+	if o.Seed != nil {
+		m["seed"] = *o.Seed
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+// do sends a JSON request to the server and decodes the JSON response into
+// out, mapping failures to grail errors.
+func (p *Provider) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return grail.NewGrailError(grail.Internal, "failed to marshal request").WithCause(err).WithProviderName(p.Name())
+		}
+		body = bytes.NewReader(data)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, body)
+	if err != nil {
+		return grail.NewGrailError(grail.Internal, "failed to create request").WithCause(err).WithProviderName(p.Name())
+	}
+	if in != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return grail.NewGrailError(grail.Timeout, "Ollama request cancelled").WithCause(err).WithProviderName(p.Name())
+		}
+		return grail.NewGrailError(grail.Unavailable,
+			fmt.Sprintf("Ollama server unreachable at %s (is `ollama serve` running?)", p.baseURL)).
+			WithCause(err).WithRetryable(true).WithProviderName(p.Name())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&apiErr)
+		msg := apiErr.Error
+		if msg == "" {
+			msg = fmt.Sprintf("unexpected HTTP status %d", resp.StatusCode)
+		}
+		code, retryable := grail.CodeFromHTTPStatus(resp.StatusCode)
+		if resp.StatusCode == http.StatusNotFound {
+			msg += " (pull the model with `ollama pull`)"
+		}
+		return grail.NewGrailError(code, "Ollama API error: "+msg).
+			WithRetryable(retryable).WithProviderName(p.Name()).
+			WithDetail("status", fmt.Sprint(resp.StatusCode))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return grail.NewGrailError(grail.Internal, "failed to decode response").WithCause(err).WithProviderName(p.Name())
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package ollama_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/ollama"
+)
+
+// pngHeader is enough of a PNG for MIME sniffing.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func newServer(t *testing.T, chat func(body map[string]any) (int, string)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			w.Write([]byte(`{"models":[
+				{"name":"llama3.2:latest","details":{"family":"llama","families":["llama"]}},
+				{"name":"llava:13b","details":{"family":"llama","families":["llama","clip"]}}]}`))
+		case "/api/embed":
+			w.Write([]byte(`{"model":"nomic-embed-text","embeddings":[[0.1,0.2],[0.3,0.4]]}`))
+		case "/api/chat":
+			var body map[string]any
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("decode chat request: %v", err)
+			}
+			status, out := chat(body)
+			w.WriteHeader(status)
+			w.Write([]byte(out))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOllama_Text(t *testing.T) {
+	var got map[string]any
+	srv := newServer(t, func(body map[string]any) (int, string) {
+		got = body
+		return 200, `{"model":"llama3.2","message":{"role":"assistant","content":"Rayleigh scattering."},
+			"done":true,"done_reason":"stop","prompt_eval_count":7,"eval_count":3}`
+	})
+	p, _ := ollama.New(ollama.WithBaseURL(srv.URL))
+	temp := 0.2
+	res, err := grail.NewClient(p).Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("Why is the sky blue?")},
+		Output: grail.OutputText(),
+		ProviderOptions: []grail.ProviderOption{ollama.TextOptions{
+			SystemPrompt: "Be brief.", Temperature: &temp,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := res.Text(); text != "Rayleigh scattering." {
+		t.Errorf("text = %q", text)
+	}
+	if res.Usage.TotalTokens != 10 {
+		t.Errorf("usage = %+v", res.Usage)
+	}
+	if got["model"] != ollama.DefaultTextModel || got["stream"] != false {
+		t.Errorf("request = %v", got)
+	}
+	msgs := got["messages"].([]any)
+	if len(msgs) != 2 || msgs[0].(map[string]any)["role"] != "system" {
+		t.Errorf("messages = %v", msgs)
+	}
+	if opts := got["options"].(map[string]any); opts["temperature"] != 0.2 {
+		t.Errorf("options = %v", opts)
+	}
+}
+
+func TestOllama_JSONWithImage(t *testing.T) {
+	var got map[string]any
+	srv := newServer(t, func(body map[string]any) (int, string) {
+		got = body
+		return 200, `{"message":{"role":"assistant","content":"{\"animal\":\"cat\"}"},"done":true}`
+	})
+	p, _ := ollama.New(ollama.WithBaseURL(srv.URL))
+	schema := map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"animal": map[string]any{"type": "string"}},
+		"required":   []string{"animal"},
+	}
+	res, err := grail.NewClient(p).Generate(context.Background(), grail.Request{
+		Model:  "llava:13b",
+		Inputs: []grail.Input{grail.InputText("What animal is this?"), grail.InputImage(pngHeader)},
+		Output: grail.OutputJSON(schema),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct{ Animal string }
+	if err := res.DecodeJSON(&out); err != nil || out.Animal != "cat" {
+		t.Fatalf("DecodeJSON = %+v, %v", out, err)
+	}
+	if format, ok := got["format"].(map[string]any); !ok || format["type"] != "object" {
+		t.Errorf("format = %v", got["format"])
+	}
+	msg := got["messages"].([]any)[0].(map[string]any)
+	if images, _ := msg["images"].([]any); len(images) != 1 {
+		t.Errorf("images = %v", msg["images"])
+	}
+}
+
+func TestOllama_ImageNeedsVisionModel(t *testing.T) {
+	srv := newServer(t, func(map[string]any) (int, string) {
+		t.Error("request should be rejected before reaching the server")
+		return 500, ""
+	})
+	p, _ := ollama.New(ollama.WithBaseURL(srv.URL))
+	_, err := grail.NewClient(p).Generate(context.Background(), grail.Request{
+		Model:  "llama3.2",
+		Inputs: []grail.Input{grail.InputText("Describe"), grail.InputImage(pngHeader)},
+		Output: grail.OutputText(),
+	})
+	if grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Fatalf("err = %v, want InvalidArgument", err)
+	}
+}
+
+func TestOllama_ListModels(t *testing.T) {
+	srv := newServer(t, nil)
+	p, _ := ollama.New(ollama.WithBaseURL(srv.URL))
+	models, err := grail.NewClient(p).ListModels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	vision := map[string]bool{}
+	for _, m := range models {
+		vision[m.Name] = m.Capabilities.ImageUnderstanding
+	}
+	want := map[string]bool{"llama3.2:latest": false, "llama3.2": false, "llava:13b": true}
+	if len(vision) != len(want) {
+		t.Fatalf("models = %v", vision)
+	}
+	for name, v := range want {
+		if got, ok := vision[name]; !ok || got != v {
+			t.Errorf("%s: vision = %v, %v; want %v", name, got, ok, v)
+		}
+	}
+}
+
+func TestOllama_ModelNotFound(t *testing.T) {
+	srv := newServer(t, func(map[string]any) (int, string) {
+		return 404, `{"error":"model \"mistral\" not found, try pulling it first"}`
+	})
+	p, _ := ollama.New(ollama.WithBaseURL(srv.URL))
+	_, err := p.DoGenerate(context.Background(), grail.Request{
+		Model:  "mistral",
+		Inputs: []grail.Input{grail.InputText("hi")},
+		Output: grail.OutputText(),
+	})
+	if !grail.IsNotFound(err) {
+		t.Fatalf("err = %v, want NotFound", err)
+	}
+}
+
+func TestOllama_Unreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	p, _ := ollama.New(ollama.WithBaseURL(srv.URL))
+	_, err := p.DoGenerate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("hi")},
+		Output: grail.OutputText(),
+	})
+	if grail.GetErrorCode(err) != grail.Unavailable {
+		t.Fatalf("err = %v, want Unavailable", err)
+	}
+}
+
+func TestOllama_Embed(t *testing.T) {
+	srv := newServer(t, nil)
+	p, _ := ollama.New(ollama.WithBaseURL(srv.URL))
+	var _ grail.Embedder = p
+	vectors, err := p.Embed(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 2 || vectors[1][1] != 0.4 {
+		t.Fatalf("vectors = %v", vectors)
+	}
+	if _, err := p.Embed(context.Background(), []string{"only one"}); grail.GetErrorCode(err) != grail.OutputInvalid {
+		t.Fatalf("err = %v, want OutputInvalid for a count mismatch", err)
+	}
+}
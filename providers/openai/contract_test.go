@@ -0,0 +1,102 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+)
+
+// responsesServer emulates the Responses API endpoint, answering every request
+// with status and body.
+func responsesServer(t *testing.T, status int, body string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/responses" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer dummy" {
+			t.Errorf("unexpected authorization %q", got)
+		}
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		// Keep the SDK's retries of 429 and 5xx fast.
+		w.Header().Set("Retry-After-Ms", "1")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func errorBody(kind string) string {
+	return fmt.Sprintf(`{"error":{"message":"%s","type":"%s","code":null}}`, kind, kind)
+}
+
+func TestOpenAI_Contract(t *testing.T) {
+	cases := []struct {
+		name      string
+		status    int
+		body      string
+		code      grail.ErrorCode
+		retryable bool
+	}{
+		{"ok", http.StatusOK, `{"id":"resp_ok","object":"response","status":"completed","model":"gpt-5.4-2026-03-05",
+			"output":[{"type":"message","id":"msg_1","role":"assistant","status":"completed",
+				"content":[{"type":"output_text","text":"Hello there.","annotations":[]}]}],
+			"usage":{"input_tokens":5,"output_tokens":3,"total_tokens":8}}`, "", false},
+		{"rate_limited", http.StatusTooManyRequests, errorBody("rate_limit_exceeded"), grail.RateLimited, true},
+		{"server_error", http.StatusInternalServerError, errorBody("server_error"), grail.Unavailable, true},
+		{"unauthorized", http.StatusUnauthorized, errorBody("invalid_api_key"), grail.Unauthorized, false},
+		{"model_not_found", http.StatusNotFound, errorBody("model_not_found"), grail.NotFound, false},
+		{"refusal", http.StatusOK, `{"id":"resp_no","object":"response","status":"completed","model":"gpt-5.4",
+			"output":[{"type":"message","id":"msg_1","role":"assistant","status":"completed",
+				"content":[{"type":"refusal","refusal":"I can't help with that."}]}]}`, grail.Refused, false},
+		{"content_filter", http.StatusOK, `{"id":"resp_cf","object":"response","status":"incomplete","model":"gpt-5.4",
+			"incomplete_details":{"reason":"content_filter"},"output":[]}`, grail.Refused, false},
+		{"malformed", http.StatusOK, `{"id":"resp_bad","output":[`, grail.Internal, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, calls := responsesServer(t, tc.status, tc.body)
+			p, err := New(WithAPIKey("dummy"), WithBaseURL(srv.URL+"/v1/"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			res, err := grail.NewClient(p).Generate(context.Background(), grail.Request{
+				Inputs: []grail.Input{grail.InputText("Say hello.")},
+				Output: grail.OutputText(),
+			})
+
+			if tc.code == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				text, _ := res.Text()
+				if text != "Hello there." || res.RequestID != "resp_ok" || res.Usage.TotalTokens != 8 {
+					t.Fatalf("unexpected response: %q %q %+v", text, res.RequestID, res.Usage)
+				}
+				if m := res.Provider.Models; len(m) != 1 || m[0].Name != DefaultTextModelName || m[0].Version != "gpt-5.4-2026-03-05" {
+					t.Fatalf("unexpected models %+v", m)
+				}
+				return
+			}
+			if got := grail.GetErrorCode(err); got != tc.code {
+				t.Fatalf("expected %s, got %s (%v)", tc.code, got, err)
+			}
+			if grail.IsRetryable(err) != tc.retryable {
+				t.Fatalf("expected retryable=%v for %v", tc.retryable, err)
+			}
+			if tc.retryable && calls.Load() < 2 {
+				t.Fatalf("expected the SDK to retry, got %d calls", calls.Load())
+			}
+		})
+	}
+}
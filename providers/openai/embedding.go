@@ -0,0 +1,36 @@
+package openai
+
+import (
+	"context"
+
+	"github.com/montanaflynn/grail"
+	"github.com/openai/openai-go/v3"
+)
+
+// EmbeddingModel is the model used by Embed.
+const EmbeddingModel = openai.EmbeddingModelTextEmbedding3Small
+
+// Embed implements grail.Embedder using the embeddings endpoint, so a
+// grail.SemanticCache can match prompts for any provider:
+//
+//	cache := &grail.SemanticCache{Embedder: openaiProvider}
+func (p *Provider) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	resp, err := p.client.Embeddings.New(ctx, openai.EmbeddingNewParams{
+		Model: EmbeddingModel,
+		Input: openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: texts},
+	})
+	if err != nil {
+		return nil, apiError("embeddings", err)
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, grail.NewGrailError(grail.OutputInvalid, "openai embeddings returned the wrong number of vectors").WithProviderName("openai")
+	}
+	vectors := make([][]float64, len(texts))
+	for _, d := range resp.Data {
+		if d.Index < 0 || int(d.Index) >= len(vectors) {
+			return nil, grail.NewGrailError(grail.OutputInvalid, "openai embeddings returned an out-of-range index").WithProviderName("openai")
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
@@ -0,0 +1,53 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/montanaflynn/grail"
+	"github.com/openai/openai-go/v3"
+)
+
+// UploadFile implements grail.FileUploader with the Files API, so a
+// grail.AttachmentStore uploads each large file once and later requests
+// reference it by ID. Files are uploaded for the "user_data" purpose and
+// persist until deleted.
+func (p *Provider) UploadFile(ctx context.Context, data []byte, mime, name string) (string, error) {
+	if name == "" {
+		name = "file"
+		if ext := extensionFor(mime); ext != "" {
+			name += ext
+		}
+	}
+	f, err := p.client.Files.New(ctx, openai.FileNewParams{
+		File:    openai.File(bytes.NewReader(data), name, mime),
+		Purpose: openai.FilePurposeUserData,
+	})
+	if err != nil {
+		return "", apiError("file upload", err)
+	}
+	if f.ID == "" {
+		return "", grail.NewGrailError(grail.OutputInvalid, "openai file upload returned no ID").WithProviderName("openai")
+	}
+	return f.ID, nil
+}
+
+// extensionFor returns a file extension for the MIME types the Files API
+// checks names against.
+func extensionFor(mime string) string {
+	switch mime {
+	case "application/pdf":
+		return ".pdf"
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/webp":
+		return ".webp"
+	case "image/gif":
+		return ".gif"
+	case "text/plain":
+		return ".txt"
+	}
+	return ""
+}
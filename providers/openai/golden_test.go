@@ -0,0 +1,129 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/internal/golden"
+)
+
+// goldenRequests are sent through the provider and the wire payloads compared
+// with testdata/golden, so changes to how requests are built show up as diffs.
+var goldenRequests = []struct {
+	name string
+	req  grail.Request
+}{
+	{"text", grail.Request{
+		Inputs: []grail.Input{grail.InputText("Write a haiku about the sea.")},
+		Output: grail.OutputText(),
+	}},
+	{"text_options", grail.Request{
+		Inputs: []grail.Input{grail.InputText("Summarize this.")},
+		Output: grail.OutputText(),
+		Model:  "gpt-5.4-mini",
+		ProviderOptions: []grail.ProviderOption{TextOptions{
+			SystemPrompt: "Be brief.",
+			MaxTokens:    ptr[int32](200),
+			Temperature:  ptr[float32](0.5),
+			ServiceTier:  ServiceTierFlex,
+		}},
+	}},
+	{"multimodal", grail.Request{
+		Inputs: []grail.Input{
+			grail.InputText("Compare the image and the document."),
+			grail.InputFile([]byte("\x89PNG\r\n\x1a\nfake"), "image/png", grail.WithFileName("chart.png")),
+			grail.InputFile([]byte("%PDF-1.4 fake"), "application/pdf", grail.WithFileName("report.pdf")),
+		},
+		Output: grail.OutputText(),
+	}},
+	{"json_schema", grail.Request{
+		Inputs: []grail.Input{grail.InputText("Extract the person.")},
+		Output: grail.OutputJSON(map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"name": map[string]any{"type": "string"},
+				"age":  map[string]any{"type": "integer"},
+			},
+			"required":             []string{"name", "age"},
+			"additionalProperties": false,
+		}),
+	}},
+	{"json_logprobs", grail.Request{
+		Inputs: []grail.Input{grail.InputText("Extract the person.")},
+		Output: grail.OutputJSON(nil, grail.WithConfidence(grail.ConfidenceLogprobs)),
+	}},
+	{"tools", grail.Request{
+		Inputs: []grail.Input{
+			grail.InputText("What's the weather in Paris?"),
+			grail.InputToolResult(grail.ToolCall{
+				ID:        "call_1",
+				Name:      "get_weather",
+				Arguments: []byte(`{"city":"Paris"}`),
+			}, `{"temp_c":18}`),
+		},
+		Output: grail.OutputText(),
+		Tools: []grail.Tool{{
+			Name:        "get_weather",
+			Description: "Get the current weather in a city.",
+			Parameters: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"city": map[string]any{"type": "string"}},
+				"required":   []string{"city"},
+			},
+		}},
+	}},
+	{"history", grail.Request{
+		History: []grail.Message{
+			grail.UserMessage(grail.InputText("My name is Ada.")),
+			grail.AssistantMessage("Nice to meet you, Ada!"),
+		},
+		Inputs: []grail.Input{grail.InputText("What's my name?")},
+		Output: grail.OutputText(),
+	}},
+	{"image", grail.Request{
+		Inputs:          []grail.Input{grail.InputText("A lighthouse at dusk.")},
+		Output:          grail.OutputImage(grail.ImageSpec{Count: 1}),
+		ProviderOptions: []grail.ProviderOption{WithImageSize(ImageSize1024x1024), WithImageFormat(ImageFormatWEBP)},
+	}},
+}
+
+func TestOpenAI_GoldenPayloads(t *testing.T) {
+	for _, tc := range goldenRequests {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls []map[string]any
+			hc := &http.Client{Transport: stubTransport(func(r *http.Request) (*http.Response, error) {
+				data, _ := io.ReadAll(r.Body)
+				var body any
+				if err := json.Unmarshal(data, &body); err != nil {
+					t.Fatalf("decode request: %v", err)
+				}
+				calls = append(calls, map[string]any{"method": r.Method, "path": r.URL.Path, "body": body})
+				res := `{"id":"resp_1","object":"response","status":"completed","model":"gpt-5.4","output":[]}`
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": []string{"application/json"}},
+					Body:       io.NopCloser(strings.NewReader(res)),
+					Request:    r,
+				}, nil
+			})}
+			p, err := New(WithAPIKey("dummy"), WithHTTPClient(hc))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			// Only the outgoing payload matters; empty responses may fail to parse.
+			p.DoGenerate(context.Background(), tc.req)
+			if len(calls) == 0 {
+				t.Fatalf("no request sent")
+			}
+			data, _ := json.Marshal(calls)
+			golden.Assert(t, tc.name, golden.JSON(t, data))
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
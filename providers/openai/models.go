@@ -107,4 +107,25 @@ var (
 			ImageUnderstanding: true,
 		},
 	}
+
+	// Sora2Pro is the best quality video generation model, with higher
+	// resolutions.
+	Sora2Pro = grail.Model{
+		Name: string(openai.VideoModelSora2Pro),
+		Role: grail.ModelRoleVideo,
+		Tier: grail.ModelTierBest,
+		Capabilities: grail.ModelCapabilities{
+			VideoGeneration: true,
+		},
+	}
+
+	// Sora2 is the faster, lower-cost video generation model.
+	Sora2 = grail.Model{
+		Name: string(openai.VideoModelSora2),
+		Role: grail.ModelRoleVideo,
+		Tier: grail.ModelTierFast,
+		Capabilities: grail.ModelCapabilities{
+			VideoGeneration: true,
+		},
+	}
 )
@@ -0,0 +1,65 @@
+package openai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/montanaflynn/grail"
+	"github.com/openai/openai-go/v3"
+)
+
+// ModerationModel is the model used by ClassifyImage.
+const ModerationModel = "omni-moderation-latest"
+
+// ClassifyImage implements grail.ImageClassifier using the OpenAI moderation
+// endpoint, so a provider can check images generated by any provider:
+//
+//	client := grail.NewClient(gemini, grail.WithImageSafety(grail.ImageSafety{
+//		Classifier: openaiProvider,
+//		Action:     grail.SafetyBlur,
+//	}))
+func (p *Provider) ClassifyImage(ctx context.Context, data []byte, mime string) (grail.SafetyVerdict, error) {
+	if mime == "" {
+		mime = grail.SniffImageMIME(data)
+	}
+	dataURL := fmt.Sprintf("data:%s;base64,%s", mime, base64.StdEncoding.EncodeToString(data))
+	resp, err := p.client.Moderations.New(ctx, openai.ModerationNewParams{
+		Model: ModerationModel,
+		Input: openai.ModerationNewParamsInputUnion{
+			OfModerationMultiModalArray: []openai.ModerationMultiModalInputUnionParam{{
+				OfImageURL: &openai.ModerationImageURLInputParam{
+					ImageURL: openai.ModerationImageURLInputImageURLParam{URL: dataURL},
+				},
+			}},
+		},
+	})
+	if err != nil {
+		return grail.SafetyVerdict{}, apiError("moderation", err)
+	}
+	if len(resp.Results) == 0 {
+		return grail.SafetyVerdict{}, grail.NewGrailError(grail.OutputInvalid, "openai moderation returned no results").WithProviderName("openai")
+	}
+	return moderationVerdict(resp.Results[0]), nil
+}
+
+func moderationVerdict(m openai.Moderation) grail.SafetyVerdict {
+	v := grail.SafetyVerdict{Flagged: m.Flagged}
+	// Decode the raw JSON so categories added to the API show up without an
+	// SDK update.
+	var categories map[string]bool
+	if err := json.Unmarshal([]byte(m.Categories.RawJSON()), &categories); err == nil {
+		for name, flagged := range categories {
+			if flagged {
+				v.Categories = append(v.Categories, name)
+			}
+		}
+	}
+	sort.Strings(v.Categories)
+	if err := json.Unmarshal([]byte(m.CategoryScores.RawJSON()), &v.Scores); err != nil {
+		v.Scores = nil
+	}
+	return v
+}
@@ -24,6 +24,10 @@
 //   - gpt-image-2 (default)
 //   - gpt-image-1
 //   - gpt-image-1-mini
+//
+// Available video models (grail.OutputVideo):
+//   - sora-2 (default)
+//   - sora-2-pro
 package openai
 
 import (
@@ -33,10 +37,14 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/internal/httplog"
 
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
@@ -67,7 +75,11 @@ type settings struct {
 	textModel  string
 	imageModel string
 	logger     *slog.Logger
+	httpClient *http.Client
+	baseURL    string
 	imgFormat  string
+	adminKey   string
+	region     string
 }
 
 // WithAPIKey sets the API key explicitly.
@@ -88,6 +100,12 @@ func WithAPIKeyFromEnv(env string) Option {
 	}
 }
 
+// WithAdminKey sets the admin key ProviderUsage reads the organization Usage
+// API with. It defaults to OPENAI_ADMIN_KEY.
+func WithAdminKey(key string) Option {
+	return func(s *settings) { s.adminKey = key }
+}
+
 // WithTextModel overrides the default text model (default: gpt-5.4).
 func WithTextModel(model string) Option {
 	return func(s *settings) { s.textModel = model }
@@ -108,13 +126,44 @@ func WithLogger(l *slog.Logger) Option {
 	}
 }
 
+// WithBaseURL overrides the API base URL (default https://api.openai.com/v1,
+// or OPENAI_BASE_URL when set), for proxies, gateways, and test servers.
+func WithBaseURL(url string) Option {
+	return func(s *settings) { s.baseURL = url }
+}
+
+// regionBaseURLs are the data residency endpoints WithRegion selects.
+var regionBaseURLs = map[string]string{
+	"us": "https://us.api.openai.com/v1/",
+	"eu": "https://eu.api.openai.com/v1/",
+}
+
+// WithRegion sends requests to a data residency endpoint: "us" or "eu"
+// (https://eu.api.openai.com/v1). The project the API key belongs to must be
+// set up for the region. The region is recorded in grail.ProviderInfo.Region.
+// WithBaseURL takes precedence over the endpoint, for proxies in front of it.
+func WithRegion(region string) Option {
+	return func(s *settings) { s.region = region }
+}
+
+// WithHTTPClient sets the HTTP client used for API calls. The provider wraps
+// its transport to log wire requests at debug level through the provider's
+// logger.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(s *settings) { s.httpClient = hc }
+}
+
 // Provider is an OpenAI-backed implementation of grail.Provider.
 type Provider struct {
 	client     openai.Client
 	textModel  string
 	imageModel string
+	mu         sync.RWMutex // guards log and the model catalog slots
 	log        *slog.Logger
+	transport  *httplog.Transport
 	imgFormat  string
+	adminKey   string
+	region     string // data residency region, "" for the default endpoint
 
 	// Model catalog slots
 	bestTextModel  grail.Model
@@ -193,6 +242,25 @@ var ImageModerations = map[string]ImageModeration{
 	"low":  ImageModerationLow,
 }
 
+// ServiceTier enumerates OpenAI processing tiers.
+type ServiceTier string
+
+const (
+	ServiceTierAuto     ServiceTier = "auto"
+	ServiceTierDefault  ServiceTier = "default"
+	ServiceTierFlex     ServiceTier = "flex"
+	ServiceTierScale    ServiceTier = "scale"
+	ServiceTierPriority ServiceTier = "priority"
+)
+
+// Truncation enumerates how OpenAI handles input exceeding the context window.
+type Truncation string
+
+const (
+	TruncationAuto     Truncation = "auto"     // drop items from the start of the conversation
+	TruncationDisabled Truncation = "disabled" // fail the request
+)
+
 // TextOptions provides OpenAI-specific text generation options.
 type TextOptions struct {
 	Model        string
@@ -200,10 +268,51 @@ type TextOptions struct {
 	Temperature  *float32
 	TopP         *float32
 	SystemPrompt string
+
+	// PreviousResponseID continues the conversation of an earlier response
+	// (its Response.RequestID) on the server, so prior turns don't need to be
+	// resent. The earlier response must have been stored.
+	PreviousResponseID string
+	// Store controls whether OpenAI retains the response for later
+	// PreviousResponseID use (the API default is true).
+	Store             *bool
+	ParallelToolCalls *bool
+	Truncation        Truncation
+	ServiceTier       ServiceTier
 }
 
 func (TextOptions) ApplyProviderOption() {}
 
+func applyTextOptions(params *responses.ResponseNewParams, opts TextOptions) {
+	if opts.SystemPrompt != "" {
+		params.Instructions = param.NewOpt(opts.SystemPrompt)
+	}
+	if opts.MaxTokens != nil {
+		params.MaxOutputTokens = openai.Int(int64(*opts.MaxTokens))
+	}
+	if opts.Temperature != nil {
+		params.Temperature = openai.Float(float64(*opts.Temperature))
+	}
+	if opts.TopP != nil {
+		params.TopP = openai.Float(float64(*opts.TopP))
+	}
+	if opts.PreviousResponseID != "" {
+		params.PreviousResponseID = param.NewOpt(opts.PreviousResponseID)
+	}
+	if opts.Store != nil {
+		params.Store = param.NewOpt(*opts.Store)
+	}
+	if opts.ParallelToolCalls != nil {
+		params.ParallelToolCalls = param.NewOpt(*opts.ParallelToolCalls)
+	}
+	if opts.Truncation != "" {
+		params.Truncation = responses.ResponseNewParamsTruncation(opts.Truncation)
+	}
+	if opts.ServiceTier != "" {
+		params.ServiceTier = responses.ResponseNewParamsServiceTier(opts.ServiceTier)
+	}
+}
+
 // ImageOptions provides OpenAI-specific image generation options.
 type ImageOptions struct {
 	Model        string
@@ -315,34 +424,91 @@ func New(opts ...Option) (*Provider, error) {
 		}
 	}
 
-	clientOpts := []option.RequestOption{}
-	if cfg.apiKey != "" {
-		clientOpts = append(clientOpts, option.WithAPIKey(cfg.apiKey))
+	if cfg.adminKey == "" {
+		cfg.adminKey = strings.TrimSpace(os.Getenv("OPENAI_ADMIN_KEY"))
+	}
+	if cfg.region != "" {
+		regional, ok := regionBaseURLs[cfg.region]
+		if !ok {
+			return nil, fmt.Errorf("openai: unknown region %q (supported: us, eu)", cfg.region)
+		}
+		if cfg.baseURL == "" {
+			cfg.baseURL = regional
+		}
 	}
 
-	cl := openai.NewClient(clientOpts...)
-
-	return &Provider{
-		client:     cl,
+	p := &Provider{
 		textModel:  cfg.textModel,
 		imageModel: cfg.imageModel,
 		log:        cfg.logger,
 		imgFormat:  cfg.imgFormat,
+		adminKey:   cfg.adminKey,
+		region:     cfg.region,
 		// Initialize model catalog with defaults
 		bestTextModel:  GPT5_4,
 		fastTextModel:  GPT5_4Mini,
 		bestImageModel: GPTImage2,
 		fastImageModel: GPTImage1Mini,
-	}, nil
+	}
+
+	p.transport = &httplog.Transport{
+		Logger:   p.logger,
+		Provider: "openai",
+		Level:    slog.LevelDebug,
+		MaxBody:  httplog.DefaultMaxBody,
+	}
+	clientOpts := []option.RequestOption{
+		option.WithHTTPClient(httplog.Wrap(cfg.httpClient, p.transport)),
+	}
+	if cfg.apiKey != "" {
+		clientOpts = append(clientOpts, option.WithAPIKey(cfg.apiKey))
+	}
+	if cfg.baseURL != "" {
+		clientOpts = append(clientOpts, option.WithBaseURL(cfg.baseURL))
+	}
+	p.client = openai.NewClient(clientOpts...)
+
+	return p, nil
 }
 
 // SetLogger allows the client to inject a logger.
 func (p *Provider) SetLogger(l *slog.Logger) {
 	if l != nil {
+		p.mu.Lock()
+		defer p.mu.Unlock()
 		p.log = l
 	}
 }
 
+func (p *Provider) logger() *slog.Logger {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.log
+}
+
+// Capabilities implements grail.CapabilityReporter. Files other than images
+// and PDFs are sent as generic file inputs.
+func (p *Provider) Capabilities() grail.ProviderCapabilities {
+	return grail.ProviderCapabilities{
+		TextOutput:     true,
+		ImageOutput:    true,
+		VideoOutput:    true,
+		JSONOutput:     true,
+		InputMIMETypes: []string{"image/*", "application/pdf", "text/*", "application/json"},
+		MaxFileSize:    50 * 1024 * 1024,
+		Streaming:      true,
+		Tools:          true,
+		History:        true,
+		Logprobs:       true,
+		ModelListing:   true,
+	}
+}
+
+// WrapTransport implements grail.TransportAware.
+func (p *Provider) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	p.transport.WrapBase(wrap)
+}
+
 // Name returns the provider name.
 func (p *Provider) Name() string {
 	return "openai"
@@ -351,36 +517,72 @@ func (p *Provider) Name() string {
 // ModelCatalog implementation
 
 // SetBestTextModel sets the model to use for best-quality text generation.
-func (p *Provider) SetBestTextModel(model grail.Model) { p.bestTextModel = model }
+func (p *Provider) SetBestTextModel(model grail.Model) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bestTextModel = model
+}
 
 // SetFastTextModel sets the model to use for fast text generation.
-func (p *Provider) SetFastTextModel(model grail.Model) { p.fastTextModel = model }
+func (p *Provider) SetFastTextModel(model grail.Model) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fastTextModel = model
+}
 
 // SetBestImageModel sets the model to use for best-quality image generation.
-func (p *Provider) SetBestImageModel(model grail.Model) { p.bestImageModel = model }
+func (p *Provider) SetBestImageModel(model grail.Model) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bestImageModel = model
+}
 
 // SetFastImageModel sets the model to use for fast image generation.
-func (p *Provider) SetFastImageModel(model grail.Model) { p.fastImageModel = model }
+func (p *Provider) SetFastImageModel(model grail.Model) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fastImageModel = model
+}
 
 // BestTextModel returns the model used for best-quality text generation.
-func (p *Provider) BestTextModel() grail.Model { return p.bestTextModel }
+func (p *Provider) BestTextModel() grail.Model {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.bestTextModel
+}
 
 // FastTextModel returns the model used for fast text generation.
-func (p *Provider) FastTextModel() grail.Model { return p.fastTextModel }
+func (p *Provider) FastTextModel() grail.Model {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.fastTextModel
+}
 
 // BestImageModel returns the model used for best-quality image generation.
-func (p *Provider) BestImageModel() grail.Model { return p.bestImageModel }
+func (p *Provider) BestImageModel() grail.Model {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.bestImageModel
+}
 
 // FastImageModel returns the model used for fast image generation.
-func (p *Provider) FastImageModel() grail.Model { return p.fastImageModel }
+func (p *Provider) FastImageModel() grail.Model {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.fastImageModel
+}
 
 // AllModels returns all configured models.
 func (p *Provider) AllModels() []grail.Model {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	return []grail.Model{
 		p.bestTextModel,
 		p.fastTextModel,
 		p.bestImageModel,
 		p.fastImageModel,
+		Sora2Pro,
+		Sora2,
 	}
 }
 
@@ -389,8 +591,37 @@ func (p *Provider) ListModels(ctx context.Context) ([]grail.Model, error) {
 	return p.AllModels(), nil
 }
 
+// LiveModels implements grail.LiveModelLister with the Models API.
+func (p *Provider) LiveModels(ctx context.Context) ([]string, error) {
+	var names []string
+	iter := p.client.Models.ListAutoPaging(ctx)
+	for iter.Next() {
+		names = append(names, iter.Current().ID)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, apiError("list models", err)
+	}
+	return names, nil
+}
+
+// ConfiguredModels implements grail.LiveModelLister.
+func (p *Provider) ConfiguredModels() map[string]string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return map[string]string{
+		"text":       p.textModel,
+		"image":      p.imageModel,
+		"best_text":  p.bestTextModel.Name,
+		"fast_text":  p.fastTextModel.Name,
+		"best_image": p.bestImageModel.Name,
+		"fast_image": p.fastImageModel.Name,
+	}
+}
+
 // ResolveModel resolves a role+tier to a model name.
 func (p *Provider) ResolveModel(role grail.ModelRole, tier grail.ModelTier) (string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	switch {
 	case role == grail.ModelRoleText && tier == grail.ModelTierBest:
 		return p.bestTextModel.Name, nil
@@ -400,6 +631,10 @@ func (p *Provider) ResolveModel(role grail.ModelRole, tier grail.ModelTier) (str
 		return p.bestImageModel.Name, nil
 	case role == grail.ModelRoleImage && tier == grail.ModelTierFast:
 		return p.fastImageModel.Name, nil
+	case role == grail.ModelRoleVideo && tier == grail.ModelTierBest:
+		return Sora2Pro.Name, nil
+	case role == grail.ModelRoleVideo && tier == grail.ModelTierFast:
+		return Sora2.Name, nil
 	default:
 		return "", fmt.Errorf("openai: no %s model with tier %s", role, tier)
 	}
@@ -434,96 +669,131 @@ func (p *Provider) DescribeModels(req grail.Request) string {
 		return textModel + "," + imageModel
 	}
 
+	if _, isVideo := grail.GetVideoSpec(req.Output); isVideo {
+		if req.Model != "" {
+			return req.Model
+		}
+		return string(DefaultVideoModelName)
+	}
+
 	return req.Model
 }
 
 // DoGenerate implements the ProviderExecutor interface.
 func (p *Provider) DoGenerate(ctx context.Context, req grail.Request) (grail.Response, error) {
-	// Convert inputs to OpenAI format
-	item, err := p.toResponseInput(req.Inputs)
+	return p.generate(ctx, req, nil)
+}
+
+// StreamGenerate implements grail.TextStreamer with the Responses API's
+// server-sent events. Other outputs are generated as by DoGenerate.
+func (p *Provider) StreamGenerate(ctx context.Context, req grail.Request, emit func(text string)) (grail.Response, error) {
+	return p.generate(ctx, req, emit)
+}
+
+// generate routes req by output type, streaming text output to emit if it's
+// set.
+func (p *Provider) generate(ctx context.Context, req grail.Request, emit func(text string)) (grail.Response, error) {
+	// Videos are generated from a prompt, not a conversation.
+	if spec, isVideo := grail.GetVideoSpec(req.Output); isVideo {
+		return p.generateVideo(ctx, req, spec)
+	}
+
+	// Convert history and inputs to OpenAI format
+	items, err := p.toHistoryInput(req.History)
+	if err != nil {
+		return grail.Response{}, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("failed to convert history: %v", err)).WithCause(err).WithProviderName("openai")
+	}
+	current, err := p.toResponseInput(req.Inputs)
 	if err != nil {
 		return grail.Response{}, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("failed to convert inputs: %v", err)).WithCause(err).WithProviderName("openai")
 	}
+	items = append(items, current...)
 
 	// Determine output type and route accordingly
 	if grail.IsTextOutput(req.Output) {
-		return p.generateText(ctx, req, item)
+		return p.generateText(ctx, req, items, emit)
 	}
 	if spec, isImage := grail.GetImageSpec(req.Output); isImage {
-		return p.generateImage(ctx, req, item, spec)
+		return p.generateImage(ctx, req, items, spec)
 	}
 	if schema, strict, isJSON := grail.GetJSONOutput(req.Output); isJSON {
-		return p.generateJSON(ctx, req, item, schema, strict)
+		return p.generateJSON(ctx, req, items, schema, strict)
 	}
 	return grail.Response{}, grail.NewGrailError(grail.Unsupported, fmt.Sprintf("unsupported output type: %T", req.Output)).WithProviderName("openai")
 }
 
-func (p *Provider) generateText(ctx context.Context, req grail.Request, item responses.ResponseInputItemUnionParam) (grail.Response, error) {
+func (p *Provider) generateText(ctx context.Context, req grail.Request, items responses.ResponseInputParam, emit func(text string)) (grail.Response, error) {
 	// Extract text options from provider options
 	var textOpts TextOptions
 	model := p.textModel
-	// Request.Model takes precedence over provider default and ProviderOptions
-	if req.Model != "" {
-		model = req.Model
-	} else {
-		// Fall back to ProviderOptions if Request.Model not set
-		for _, opt := range req.ProviderOptions {
-			if to, ok := opt.(TextOptions); ok {
-				textOpts = to
-				if to.Model != "" {
-					model = to.Model
-				}
+	for _, opt := range req.ProviderOptions {
+		if to, ok := opt.(TextOptions); ok {
+			textOpts = to
+			if to.Model != "" {
+				model = to.Model
 			}
 		}
 	}
+	// Request.Model takes precedence over provider default and TextOptions.Model
+	if req.Model != "" {
+		model = req.Model
+	}
 
-	if p.log != nil {
-		p.log.Debug("openai generate text request", slog.String("model", model))
+	if log := p.logger(); log != nil {
+		log.Debug("openai generate text request", slog.String("model", model))
 	}
 
 	params := responses.ResponseNewParams{
 		Model: shared.ChatModel(model),
 		Input: responses.ResponseNewParamsInputUnion{
-			OfInputItemList: responses.ResponseInputParam{item},
+			OfInputItemList: items,
 		},
 	}
 
-	if textOpts.SystemPrompt != "" {
-		params.Instructions = param.NewOpt(textOpts.SystemPrompt)
-	}
-	if textOpts.MaxTokens != nil {
-		params.MaxOutputTokens = openai.Int(int64(*textOpts.MaxTokens))
-	}
-	if textOpts.Temperature != nil {
-		params.Temperature = openai.Float(float64(*textOpts.Temperature))
-	}
-	if textOpts.TopP != nil {
-		params.TopP = openai.Float(float64(*textOpts.TopP))
+	applyTextOptions(&params, textOpts)
+	if len(req.Tools) > 0 {
+		tools, err := toFunctionTools(req.Tools)
+		if err != nil {
+			return grail.Response{}, err
+		}
+		params.Tools = tools
 	}
 
-	resp, err := p.client.Responses.New(ctx, params)
+	var resp *responses.Response
+	var err error
+	if emit != nil {
+		resp, err = p.streamResponse(ctx, params, emit)
+	} else {
+		resp, err = p.client.Responses.New(ctx, params)
+	}
 	if err != nil {
-		ge := grail.NewGrailError(grail.Internal, fmt.Sprintf("openai generate text failed: %v", err)).WithCause(err).WithProviderName("openai").WithRetryable(isRetryableError(err))
-		return grail.Response{}, ge
+		return grail.Response{}, apiError("generate text", err)
+	}
+	if reason, ok := refusal(resp); ok {
+		return grail.Response{}, grail.NewGrailError(grail.Refused, "openai refused: "+reason).WithProviderName("openai").WithRequestID(resp.ID)
 	}
 
 	text := resp.OutputText()
 	usage := extractUsage(resp)
+	calls := extractToolCalls(resp)
 
-	if p.log != nil {
-		p.log.Debug("openai generate text response", slog.Any("usage", usage))
+	if log := p.logger(); log != nil {
+		log.Debug("openai generate text response", slog.Any("usage", usage), slog.Int("tool_calls", len(calls)))
 	}
 
+	var outputs []grail.OutputPart
+	if text != "" || len(calls) == 0 {
+		outputs = append(outputs, grail.NewTextOutputPart(text))
+	}
 	return grail.Response{
-		Outputs: []grail.OutputPart{
-			grail.NewTextOutputPart(text),
-		},
-		Usage: usage,
+		Outputs: append(outputs, calls...),
+		Usage:   usage,
 		Provider: grail.ProviderInfo{
-			Name:  "openai",
-			Route: "responses",
+			Name:   "openai",
+			Route:  "responses",
+			Region: p.region,
 			Models: []grail.ModelUse{
-				{Role: "language", Name: model},
+				{Role: "language", Name: model, Version: resp.Model},
 			},
 		},
 		RequestID: resp.ID,
@@ -531,7 +801,34 @@ func (p *Provider) generateText(ctx context.Context, req grail.Request, item res
 	}, nil
 }
 
-func (p *Provider) generateImage(ctx context.Context, req grail.Request, item responses.ResponseInputItemUnionParam, spec grail.ImageSpec) (grail.Response, error) {
+// streamResponse creates a response with streaming on, passing output text
+// deltas to emit, and returns the completed response.
+func (p *Provider) streamResponse(ctx context.Context, params responses.ResponseNewParams, emit func(text string)) (*responses.Response, error) {
+	stream := p.client.Responses.NewStreaming(ctx, params)
+	defer stream.Close()
+	var resp *responses.Response
+	for stream.Next() {
+		ev := stream.Current()
+		switch ev.Type {
+		case "response.output_text.delta":
+			emit(ev.Delta)
+		case "response.completed", "response.incomplete", "response.failed":
+			r := ev.Response
+			resp = &r
+		case "error":
+			return nil, fmt.Errorf("stream error %s: %s", ev.Code, ev.Message)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, errors.New("stream ended before the response completed")
+	}
+	return resp, nil
+}
+
+func (p *Provider) generateImage(ctx context.Context, req grail.Request, items responses.ResponseInputParam, spec grail.ImageSpec) (grail.Response, error) {
 	// Extract image options from provider options
 	var imageOpts ImageOptions
 	model := p.textModel
@@ -594,7 +891,7 @@ func (p *Provider) generateImage(ctx context.Context, req grail.Request, item re
 	params := responses.ResponseNewParams{
 		Model: shared.ChatModel(model),
 		Input: responses.ResponseNewParamsInputUnion{
-			OfInputItemList: responses.ResponseInputParam{item},
+			OfInputItemList: items,
 		},
 		Tools: []responses.ToolUnionParam{
 			{
@@ -607,7 +904,7 @@ func (p *Provider) generateImage(ctx context.Context, req grail.Request, item re
 		params.Instructions = param.NewOpt(imageOpts.SystemPrompt)
 	}
 
-	if p.log != nil {
+	if log := p.logger(); log != nil {
 		// Log detailed request information
 		logFields := []any{
 			slog.String("language_model", model),
@@ -627,23 +924,22 @@ func (p *Provider) generateImage(ctx context.Context, req grail.Request, item re
 		}
 		// Try to marshal the full params for complete visibility
 		if paramsJSON, err := json.MarshalIndent(params, "", "  "); err == nil {
-			p.log.Debug("openai generate image request (full params)", append(logFields, slog.String("params", string(paramsJSON)))...)
+			log.Debug("openai generate image request (full params)", append(logFields, slog.String("params", string(paramsJSON)))...)
 		} else {
-			p.log.Debug("openai generate image request", logFields...)
+			log.Debug("openai generate image request", logFields...)
 		}
 	}
 
 	resp, err := p.client.Responses.New(ctx, params)
 	if err != nil {
-		ge := grail.NewGrailError(grail.Internal, fmt.Sprintf("openai generate image failed: %v", err)).WithCause(err).WithProviderName("openai").WithRetryable(isRetryableError(err))
-		return grail.Response{}, ge
+		return grail.Response{}, apiError("generate image", err)
 	}
 
 	images := extractImagesFromResponse(resp, string(cfg.format))
 	usage := extractUsage(resp)
 
-	if p.log != nil {
-		p.log.Debug("openai generate image response", slog.Int("images", len(images)), slog.Any("usage", usage))
+	if log := p.logger(); log != nil {
+		log.Debug("openai generate image response", slog.Int("images", len(images)), slog.Any("usage", usage))
 	}
 
 	outputParts := make([]grail.OutputPart, 0, len(images))
@@ -655,10 +951,11 @@ func (p *Provider) generateImage(ctx context.Context, req grail.Request, item re
 		Outputs: outputParts,
 		Usage:   usage,
 		Provider: grail.ProviderInfo{
-			Name:  "openai",
-			Route: "responses",
+			Name:   "openai",
+			Route:  "responses",
+			Region: p.region,
 			Models: []grail.ModelUse{
-				{Role: "language", Name: model},
+				{Role: "language", Name: model, Version: resp.Model},
 				{Role: "image_generation", Name: imageModel},
 			},
 		},
@@ -667,83 +964,92 @@ func (p *Provider) generateImage(ctx context.Context, req grail.Request, item re
 	}, nil
 }
 
-func (p *Provider) generateJSON(ctx context.Context, req grail.Request, item responses.ResponseInputItemUnionParam, schema any, strict bool) (grail.Response, error) {
+func (p *Provider) generateJSON(ctx context.Context, req grail.Request, items responses.ResponseInputParam, schema any, strict bool) (grail.Response, error) {
 	// JSON output is similar to text, but with response format
 	var textOpts TextOptions
 	model := p.textModel
-	// Request.Model takes precedence over provider default and ProviderOptions
-	if req.Model != "" {
-		model = req.Model
-	} else {
-		// Fall back to ProviderOptions if Request.Model not set
-		for _, opt := range req.ProviderOptions {
-			if to, ok := opt.(TextOptions); ok {
-				textOpts = to
-				if to.Model != "" {
-					model = to.Model
-				}
+	for _, opt := range req.ProviderOptions {
+		if to, ok := opt.(TextOptions); ok {
+			textOpts = to
+			if to.Model != "" {
+				model = to.Model
 			}
 		}
 	}
+	// Request.Model takes precedence over provider default and TextOptions.Model
+	if req.Model != "" {
+		model = req.Model
+	}
 
-	if p.log != nil {
-		p.log.Debug("openai generate JSON request", slog.String("model", model))
+	if log := p.logger(); log != nil {
+		log.Debug("openai generate JSON request", slog.String("model", model))
 	}
 
 	params := responses.ResponseNewParams{
 		Model: shared.ChatModel(model),
 		Input: responses.ResponseNewParamsInputUnion{
-			OfInputItemList: responses.ResponseInputParam{item},
+			OfInputItemList: items,
 		},
 		// Note: JSON mode may not be available in all SDK versions
 		// If ResponseFormat is not available, we'll validate JSON manually
 	}
 
-	if textOpts.SystemPrompt != "" {
-		params.Instructions = param.NewOpt(textOpts.SystemPrompt)
-	}
-	if textOpts.MaxTokens != nil {
-		params.MaxOutputTokens = openai.Int(int64(*textOpts.MaxTokens))
-	}
-	if textOpts.Temperature != nil {
-		params.Temperature = openai.Float(float64(*textOpts.Temperature))
+	applyTextOptions(&params, textOpts)
+	if len(req.Tools) > 0 {
+		tools, err := toFunctionTools(req.Tools)
+		if err != nil {
+			return grail.Response{}, err
+		}
+		params.Tools = tools
 	}
-	if textOpts.TopP != nil {
-		params.TopP = openai.Float(float64(*textOpts.TopP))
+	logprobs := slices.Contains(grail.GetConfidenceMethods(req.Output), grail.ConfidenceLogprobs)
+	if logprobs {
+		params.Include = append(params.Include, responses.ResponseIncludableMessageOutputTextLogprobs)
 	}
 
 	resp, err := p.client.Responses.New(ctx, params)
 	if err != nil {
-		ge := grail.NewGrailError(grail.Internal, fmt.Sprintf("openai generate JSON failed: %v", err)).WithCause(err).WithProviderName("openai").WithRetryable(isRetryableError(err))
-		return grail.Response{}, ge
+		return grail.Response{}, apiError("generate JSON", err)
+	}
+	if reason, ok := refusal(resp); ok {
+		return grail.Response{}, grail.NewGrailError(grail.Refused, "openai refused: "+reason).WithProviderName("openai").WithRequestID(resp.ID)
 	}
 
 	text := resp.OutputText()
 	usage := extractUsage(resp)
-
-	// Validate JSON if strict mode
-	jsonBytes := []byte(text)
-	if strict {
-		var test any
-		if err := json.Unmarshal(jsonBytes, &test); err != nil {
-			return grail.Response{}, grail.NewGrailError(grail.OutputInvalid, fmt.Sprintf("invalid JSON output: %v", err)).WithProviderName("openai")
+	calls := extractToolCalls(resp)
+
+	// A response that only calls tools has no JSON yet.
+	var outputs []grail.OutputPart
+	if text != "" || len(calls) == 0 {
+		// Validate JSON if strict mode
+		jsonBytes := []byte(text)
+		if strict {
+			var test any
+			if err := json.Unmarshal(jsonBytes, &test); err != nil {
+				return grail.Response{}, grail.NewGrailError(grail.OutputInvalid, fmt.Sprintf("invalid JSON output: %v", err)).WithProviderName("openai")
+			}
+		}
+		var opts []grail.JSONPartOpt
+		if logprobs {
+			opts = append(opts, grail.WithTokenLogprobs(extractLogprobs(resp)))
 		}
+		outputs = append(outputs, grail.NewJSONOutputPart(jsonBytes, opts...))
 	}
 
-	if p.log != nil {
-		p.log.Debug("openai generate JSON response", slog.Any("usage", usage))
+	if log := p.logger(); log != nil {
+		log.Debug("openai generate JSON response", slog.Any("usage", usage), slog.Int("tool_calls", len(calls)))
 	}
 
 	return grail.Response{
-		Outputs: []grail.OutputPart{
-			grail.NewJSONOutputPart(jsonBytes),
-		},
-		Usage: usage,
+		Outputs: append(outputs, calls...),
+		Usage:   usage,
 		Provider: grail.ProviderInfo{
-			Name:  "openai",
-			Route: "responses",
+			Name:   "openai",
+			Route:  "responses",
+			Region: p.region,
 			Models: []grail.ModelUse{
-				{Role: "language", Name: model},
+				{Role: "language", Name: model, Version: resp.Model},
 			},
 		},
 		RequestID: resp.ID,
@@ -751,10 +1057,80 @@ func (p *Provider) generateJSON(ctx context.Context, req grail.Request, item res
 	}, nil
 }
 
+// toHistoryInput converts earlier turns to OpenAI Response API format: user
+// turns as their inputs are converted, assistant turns as assistant messages.
+func (p *Provider) toHistoryInput(history []grail.Message) (responses.ResponseInputParam, error) {
+	var items responses.ResponseInputParam
+	for i, m := range history {
+		if m.Role == grail.TurnAssistant {
+			var texts []string
+			for _, in := range m.Inputs {
+				if text, ok := grail.AsTextInput(in); ok {
+					texts = append(texts, text)
+				}
+			}
+			items = append(items, responses.ResponseInputItemUnionParam{
+				OfMessage: &responses.EasyInputMessageParam{
+					Role:    responses.EasyInputMessageRoleAssistant,
+					Type:    responses.EasyInputMessageTypeMessage,
+					Content: responses.EasyInputMessageContentUnionParam{OfString: param.NewOpt(strings.Join(texts, "\n\n"))},
+				},
+			})
+			continue
+		}
+		user, err := p.toResponseInput(m.Inputs)
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", i, err)
+		}
+		items = append(items, user...)
+	}
+	return items, nil
+}
+
 // toResponseInput converts grail.Inputs to OpenAI Response API format.
-func (p *Provider) toResponseInput(inputs []grail.Input) (responses.ResponseInputItemUnionParam, error) {
+func (p *Provider) toResponseInput(inputs []grail.Input) (responses.ResponseInputParam, error) {
+	var items responses.ResponseInputParam
 	content := make(responses.ResponseInputMessageContentListParam, 0, len(inputs))
+	// flush ends the user message so far, so tool calls and their results
+	// are placed between the messages around them.
+	flush := func() {
+		if len(content) == 0 {
+			return
+		}
+		items = append(items, responses.ResponseInputItemUnionParam{
+			OfMessage: &responses.EasyInputMessageParam{
+				Role:    responses.EasyInputMessageRoleUser,
+				Type:    responses.EasyInputMessageTypeMessage,
+				Content: responses.EasyInputMessageContentUnionParam{OfInputItemContentList: content},
+			},
+		})
+		content = nil
+	}
 	for i, input := range inputs {
+		if call, output, isResult := grail.AsToolResultInput(input); isResult {
+			flush()
+			args := string(call.Arguments)
+			if args == "" {
+				args = "{}"
+			}
+			// Calls made by providers without call IDs are matched by name.
+			if call.ID == "" {
+				call.ID = call.Name
+			}
+			items = append(items,
+				responses.ResponseInputItemUnionParam{OfFunctionCall: &responses.ResponseFunctionToolCallParam{
+					CallID:    call.ID,
+					Name:      call.Name,
+					Arguments: args,
+				}},
+				responses.ResponseInputItemUnionParam{OfFunctionCallOutput: &responses.ResponseInputItemFunctionCallOutputParam{
+					CallID: call.ID,
+					Output: responses.ResponseInputItemFunctionCallOutputOutputUnionParam{OfString: param.NewOpt(output)},
+				}},
+			)
+			continue
+		}
+
 		text, isText := grail.AsTextInput(input)
 		if isText {
 			content = append(content, responses.ResponseInputContentUnionParam{
@@ -768,7 +1144,7 @@ func (p *Provider) toResponseInput(inputs []grail.Input) (responses.ResponseInpu
 		data, mime, name, isFile := grail.AsFileInput(input)
 		if isFile {
 			if len(data) == 0 {
-				return responses.ResponseInputItemUnionParam{}, fmt.Errorf("input %d: file data is empty", i)
+				return nil, fmt.Errorf("input %d: file data is empty", i)
 			}
 
 			// Detect MIME if empty (e.g., from InputImage)
@@ -793,7 +1169,7 @@ func (p *Provider) toResponseInput(inputs []grail.Input) (responses.ResponseInpu
 			if mime == "application/pdf" {
 				// Validate PDF magic bytes
 				if len(data) < 4 || string(data[0:4]) != "%PDF" {
-					return responses.ResponseInputItemUnionParam{}, fmt.Errorf("input %d: invalid PDF data (missing PDF header)", i)
+					return nil, fmt.Errorf("input %d: invalid PDF data (missing PDF header)", i)
 				}
 				b64 := base64.StdEncoding.EncodeToString(data)
 				dataURL := fmt.Sprintf("data:%s;base64,%s", mime, b64)
@@ -831,18 +1207,48 @@ func (p *Provider) toResponseInput(inputs []grail.Input) (responses.ResponseInpu
 			continue
 		}
 
+		if id, mime, name, isUploaded := grail.AsUploadedFileInput(input); isUploaded {
+			if strings.HasPrefix(mime, "image/") {
+				content = append(content, responses.ResponseInputContentUnionParam{
+					OfInputImage: &responses.ResponseInputImageParam{
+						Detail: responses.ResponseInputImageDetailAuto,
+						FileID: param.NewOpt(id),
+					},
+				})
+				continue
+			}
+			file := &responses.ResponseInputFileParam{
+				FileID: param.NewOpt(id),
+				Type:   constant.InputFile("").Default(),
+			}
+			if name != "" {
+				file.Filename = param.NewOpt(name)
+			}
+			content = append(content, responses.ResponseInputContentUnionParam{OfInputFile: file})
+			continue
+		}
+
 		// FileReaderInput - read into memory for now
 		// TODO: support streaming if OpenAI API supports it
-		return responses.ResponseInputItemUnionParam{}, fmt.Errorf("input %d: FileReaderInput not yet supported", i)
+		return nil, fmt.Errorf("input %d: FileReaderInput not yet supported", i)
 	}
 
-	return responses.ResponseInputItemUnionParam{
-		OfMessage: &responses.EasyInputMessageParam{
-			Role:    responses.EasyInputMessageRoleUser,
-			Type:    responses.EasyInputMessageTypeMessage,
-			Content: responses.EasyInputMessageContentUnionParam{OfInputItemContentList: content},
-		},
-	}, nil
+	flush()
+	return items, nil
+}
+
+// extractLogprobs returns the log probabilities of the response's output
+// text tokens, in order.
+func extractLogprobs(resp *responses.Response) []grail.TokenLogprob {
+	var out []grail.TokenLogprob
+	for _, item := range resp.Output {
+		for _, content := range item.Content {
+			for _, lp := range content.Logprobs {
+				out = append(out, grail.TokenLogprob{Token: lp.Token, Logprob: lp.Logprob})
+			}
+		}
+	}
+	return out
 }
 
 func extractImagesFromResponse(resp *responses.Response, outputFormat string) []imageData {
@@ -894,6 +1300,8 @@ func extractUsage(resp *responses.Response) grail.Usage {
 		InputTokens:  int(usage.InputTokens),
 		OutputTokens: int(usage.OutputTokens),
 		TotalTokens:  int(usage.TotalTokens),
+
+		CachedInputTokens: int(usage.InputTokensDetails.CachedTokens),
 	}
 }
 
@@ -903,6 +1311,32 @@ func extractWarnings(resp *responses.Response) []grail.Warning {
 	return nil
 }
 
+// apiError converts an SDK error into a GrailError coded by HTTP status.
+func apiError(op string, err error) error {
+	code, retryable := grail.Internal, isRetryableError(err)
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		code, retryable = grail.CodeFromHTTPStatus(apiErr.StatusCode)
+	}
+	return grail.NewGrailError(code, fmt.Sprintf("openai %s failed: %v", op, err)).WithCause(err).WithProviderName("openai").WithRetryable(retryable)
+}
+
+// refusal reports whether the model declined to answer, either with a refusal
+// message or by stopping on the content filter.
+func refusal(resp *responses.Response) (string, bool) {
+	for _, item := range resp.Output {
+		for _, c := range item.Content {
+			if c.Type == "refusal" {
+				return c.Refusal, true
+			}
+		}
+	}
+	if resp.Status == "incomplete" && resp.IncompleteDetails.Reason == "content_filter" {
+		return "response stopped by content filter", true
+	}
+	return "", false
+}
+
 func isRetryableError(err error) bool {
 	// OpenAI SDK errors that are retryable
 	errStr := err.Error()
@@ -1,7 +1,15 @@
 package openai
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/montanaflynn/grail"
 )
@@ -48,3 +56,424 @@ func TestOpenAI_New_APIKeyHandling(t *testing.T) {
 		}
 	})
 }
+
+type stubTransport func(*http.Request) (*http.Response, error)
+
+func (f stubTransport) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestOpenAI_ClassifyImage(t *testing.T) {
+	var body map[string]any
+	hc := &http.Client{Transport: stubTransport(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/v1/moderations" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		res := `{"id":"modr-1","model":"omni-moderation-latest","results":[{"flagged":true,
+			"categories":{"sexual":false,"violence":true,"violence/graphic":true},
+			"category_scores":{"sexual":0.01,"violence":0.93,"violence/graphic":0.71},
+			"category_applied_input_types":{}}]}`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(res)),
+			Request:    r,
+		}, nil
+	})}
+	p, err := New(WithAPIKey("dummy"), WithHTTPClient(hc))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	v, err := p.ClassifyImage(context.Background(), []byte("\x89PNG\r\n\x1a\nrest"), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !v.Flagged || strings.Join(v.Categories, ",") != "violence,violence/graphic" || v.Scores["violence"] != 0.93 {
+		t.Fatalf("unexpected verdict: %+v", v)
+	}
+	input, _ := body["input"].([]any)
+	if len(input) != 1 || !strings.Contains(fmt.Sprint(input[0]), "data:image/png;base64,") || body["model"] != ModerationModel {
+		t.Fatalf("unexpected request body: %v", body)
+	}
+}
+
+func TestOpenAI_TextOptionsParams(t *testing.T) {
+	var body map[string]any
+	hc := &http.Client{Transport: stubTransport(func(r *http.Request) (*http.Response, error) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		res := `{"id":"resp_2","object":"response","status":"completed","model":"gpt-5.4","output":[]}`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(res)),
+			Request:    r,
+		}, nil
+	})}
+	p, err := New(WithAPIKey("dummy"), WithHTTPClient(hc))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	store, parallel := false, true
+	res, err := p.DoGenerate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("and then?")},
+		Output: grail.OutputText(),
+		Model:  "gpt-5.4",
+		ProviderOptions: []grail.ProviderOption{TextOptions{
+			PreviousResponseID: "resp_1",
+			Store:              &store,
+			ParallelToolCalls:  &parallel,
+			Truncation:         TruncationAuto,
+			ServiceTier:        ServiceTierFlex,
+		}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.RequestID != "resp_2" {
+		t.Fatalf("expected response ID as request ID, got %q", res.RequestID)
+	}
+	want := map[string]any{
+		"previous_response_id": "resp_1",
+		"store":                false,
+		"parallel_tool_calls":  true,
+		"truncation":           "auto",
+		"service_tier":         "flex",
+	}
+	for k, v := range want {
+		if body[k] != v {
+			t.Errorf("%s: got %v, want %v", k, body[k], v)
+		}
+	}
+}
+
+func TestOpenAI_ServerSideState(t *testing.T) {
+	var bodies []map[string]any
+	hc := &http.Client{Transport: stubTransport(func(r *http.Request) (*http.Response, error) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		bodies = append(bodies, body)
+		res := fmt.Sprintf(`{"id":"resp_%d","object":"response","status":"completed","model":"gpt-5.4","output":[]}`, len(bodies))
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(res)),
+			Request:    r,
+		}, nil
+	})}
+	p, err := New(WithAPIKey("dummy"), WithHTTPClient(hc))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	temp := float32(0.2)
+	session := grail.NewSession(grail.NewClient(p), WithServerSideState(),
+		grail.WithSessionRequest(grail.Request{ProviderOptions: []grail.ProviderOption{TextOptions{Temperature: &temp}}}))
+
+	for _, msg := range []string{"hello", "again", "once more"} {
+		if _, err := session.Send(context.Background(), grail.InputText(msg)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	session.Reset()
+	if _, err := session.Send(context.Background(), grail.InputText("fresh")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantPrev := []any{nil, "resp_1", "resp_2", nil}
+	for i, body := range bodies {
+		if body["previous_response_id"] != wantPrev[i] {
+			t.Errorf("turn %d: previous_response_id = %v, want %v", i, body["previous_response_id"], wantPrev[i])
+		}
+		if body["store"] != true || body["temperature"] == nil {
+			t.Errorf("turn %d: expected store and session options, got %v", i, body)
+		}
+		if input, _ := body["input"].([]any); len(input) != 1 {
+			t.Errorf("turn %d: expected only the new message, got %v", i, body["input"])
+		}
+	}
+	if turns := session.Transcript().Turns; len(turns) != 2 || turns[1].RequestID != "resp_4" {
+		t.Fatalf("expected transcript of the fresh conversation, got %+v", turns)
+	}
+}
+
+func TestOpenAI_LiveModels(t *testing.T) {
+	hc := &http.Client{Transport: stubTransport(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/v1/models" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		res := `{"object":"list","data":[{"id":"gpt-5.4","object":"model","created":1,"owned_by":"openai"},
+			{"id":"gpt-image-2","object":"model","created":1,"owned_by":"openai"}]}`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(res)),
+			Request:    r,
+		}, nil
+	})}
+	p, err := New(WithAPIKey("dummy"), WithHTTPClient(hc), WithTextModel("gpt-4-retired"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stale, err := grail.StaleModels(context.Background(), p, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var uses []string
+	for _, s := range stale {
+		uses = append(uses, s.Use+"="+s.Model)
+	}
+	if strings.Join(uses, ",") != "fast_image=gpt-image-1-mini,fast_text=gpt-5.4-mini,text=gpt-4-retired" {
+		t.Fatalf("unexpected stale models %v", uses)
+	}
+}
+
+func TestOpenAI_Region(t *testing.T) {
+	var host string
+	hc := &http.Client{Transport: stubTransport(func(r *http.Request) (*http.Response, error) {
+		host = r.URL.Host
+		res := `{"id":"resp_1","object":"response","status":"completed","model":"gpt-5.4",
+			"output":[{"type":"message","id":"msg_1","role":"assistant","status":"completed",
+				"content":[{"type":"output_text","text":"hi","annotations":[]}]}]}`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(res)),
+			Request:    r,
+		}, nil
+	})}
+	p, err := New(WithAPIKey("dummy"), WithHTTPClient(hc), WithRegion("eu"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res, err := p.DoGenerate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("hello")},
+		Output: grail.OutputText(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if host != "eu.api.openai.com" || res.Provider.Region != "eu" {
+		t.Fatalf("expected the EU endpoint, got host %q and region %q", host, res.Provider.Region)
+	}
+
+	if _, err := New(WithAPIKey("dummy"), WithRegion("mars")); err == nil {
+		t.Fatal("expected an unknown region to fail")
+	}
+}
+
+func TestOpenAI_AttachmentUpload(t *testing.T) {
+	var uploads int
+	var bodies []string
+	hc := &http.Client{Transport: stubTransport(func(r *http.Request) (*http.Response, error) {
+		res := `{"id":"resp_1","object":"response","status":"completed","model":"gpt-5.4","output":[]}`
+		if r.URL.Path == "/v1/files" {
+			uploads++
+			if err := r.ParseMultipartForm(1 << 20); err != nil || r.FormValue("purpose") != "user_data" {
+				t.Fatalf("unexpected upload: %v, purpose %q", err, r.FormValue("purpose"))
+			}
+			res = `{"id":"file-abc","object":"file","bytes":8,"filename":"doc.pdf","purpose":"user_data","created_at":0}`
+		} else {
+			data, _ := io.ReadAll(r.Body)
+			bodies = append(bodies, string(data))
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(res)),
+			Request:    r,
+		}, nil
+	})}
+	p, err := New(WithAPIKey("dummy"), WithHTTPClient(hc))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client := grail.NewClient(p, grail.WithAttachmentStore(&grail.AttachmentStore{UploadThreshold: 1}))
+	for range 2 {
+		if _, err := client.Generate(context.Background(), grail.Request{
+			Inputs: []grail.Input{grail.InputText("summarize"), grail.InputPDF([]byte("%PDF-1.7"), grail.WithFileName("doc.pdf"))},
+			Output: grail.OutputText(),
+		}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if uploads != 1 || len(bodies) != 2 {
+		t.Fatalf("expected one upload and two generations, got %d and %d", uploads, len(bodies))
+	}
+	for i, body := range bodies {
+		if !strings.Contains(body, `"file_id":"file-abc"`) || strings.Contains(body, "base64") {
+			t.Errorf("generation %d: expected the file by ID, got %s", i, body)
+		}
+	}
+}
+
+func TestOpenAI_ToolCalls(t *testing.T) {
+	hc := &http.Client{Transport: stubTransport(func(r *http.Request) (*http.Response, error) {
+		res := `{"id":"resp_1","object":"response","status":"completed","model":"gpt-5.4","output":[` +
+			`{"type":"function_call","id":"fc_1","call_id":"call_1","name":"get_weather","arguments":"{\"city\":\"Paris\"}","status":"completed"}]}`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(res)),
+			Request:    r,
+		}, nil
+	})}
+	p, err := New(WithAPIKey("dummy"), WithHTTPClient(hc))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res, err := p.DoGenerate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("What's the weather in Paris?")},
+		Output: grail.OutputText(),
+		Tools:  []grail.Tool{{Name: "get_weather"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	calls := res.ToolCalls()
+	if len(calls) != 1 || calls[0].ID != "call_1" || calls[0].Name != "get_weather" || string(calls[0].Arguments) != `{"city":"Paris"}` {
+		t.Fatalf("unexpected tool calls %+v", calls)
+	}
+	if len(res.Outputs) != 1 {
+		t.Errorf("expected only the tool call, got %d outputs", len(res.Outputs))
+	}
+}
+
+func TestOpenAI_Transcribe(t *testing.T) {
+	var form url.Values
+	hc := &http.Client{Transport: stubTransport(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/v1/audio/transcriptions" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("parse upload: %v", err)
+		}
+		form = r.MultipartForm.Value
+		res := `{"text":"Hello there.","language":"english","duration":2.5,
+			"segments":[{"id":0,"start":0,"end":1.2,"text":"Hello"},{"id":1,"start":1.2,"end":2.5,"text":" there."}],
+			"usage":{"type":"duration","seconds":3}}`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(res)),
+			Request:    r,
+		}, nil
+	})}
+	p, err := New(WithAPIKey("dummy"), WithHTTPClient(hc))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client := grail.NewClient(p)
+
+	tr, err := client.Transcribe(context.Background(), grail.InputFile([]byte("ID3audio"), "audio/mpeg"),
+		grail.WithTimestamps(), grail.WithTranscriptionLanguage("en"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tr.Text != "Hello there." || tr.Duration != 2500*time.Millisecond || len(tr.Segments) != 2 || tr.Segments[1].Start != 1200*time.Millisecond {
+		t.Fatalf("unexpected transcription %+v", tr)
+	}
+	if form.Get("model") != string(TimestampedTranscriptionModel) || form.Get("response_format") != "verbose_json" || form.Get("language") != "en" {
+		t.Errorf("unexpected form %v", form)
+	}
+}
+
+func TestOpenAI_Speak(t *testing.T) {
+	var body map[string]any
+	hc := &http.Client{Transport: stubTransport(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/v1/audio/speech" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("unexpected body: %v", err)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"audio/pcm"}},
+			Body:       io.NopCloser(strings.NewReader("samples")),
+			Request:    r,
+		}, nil
+	})}
+	p, err := New(WithAPIKey("dummy"), WithHTTPClient(hc))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	speech, err := grail.NewClient(p).Speak(context.Background(), "Hello there.", grail.WithSpeechFormat("pcm"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(speech.Data) != "samples" || speech.MIME != "audio/pcm" || speech.Provider.Models[0].Name != string(SpeechModel) {
+		t.Fatalf("unexpected speech %+v", speech)
+	}
+	if body["input"] != "Hello there." || body["voice"] != SpeechVoice || body["response_format"] != "pcm" {
+		t.Errorf("unexpected request %v", body)
+	}
+}
+
+func TestOpenAI_Stream(t *testing.T) {
+	var stream bool
+	hc := &http.Client{Transport: stubTransport(func(r *http.Request) (*http.Response, error) {
+		var body struct {
+			Stream bool `json:"stream"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("unexpected body: %v", err)
+		}
+		stream = body.Stream
+		completed := `{"id":"resp_1","object":"response","status":"completed","model":"gpt-5.4",
+			"output":[{"type":"message","id":"msg_1","role":"assistant","status":"completed",
+			"content":[{"type":"output_text","text":"Hello there.","annotations":[]}]}],
+			"usage":{"input_tokens":3,"output_tokens":2,"total_tokens":5}}`
+		var events strings.Builder
+		for _, ev := range []string{
+			`{"type":"response.output_text.delta","delta":"Hello"}`,
+			`{"type":"response.output_text.delta","delta":" there."}`,
+			`{"type":"response.completed","response":` + strings.Join(strings.Fields(completed), " ") + `}`,
+		} {
+			fmt.Fprintf(&events, "data: %s\n\n", ev)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       io.NopCloser(strings.NewReader(events.String())),
+			Request:    r,
+		}, nil
+	})}
+	p, err := New(WithAPIKey("dummy"), WithHTTPClient(hc))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var deltas []string
+	ctx := grail.ContextWithTextStream(context.Background(), func(text string) { deltas = append(deltas, text) })
+	res, err := grail.NewClient(p).Generate(ctx, grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text, _ := res.Text(); !stream || strings.Join(deltas, "|") != "Hello| there." || text != "Hello there." {
+		t.Fatalf("unexpected stream %q for %q", deltas, text)
+	}
+	if res.RequestID != "resp_1" || res.Usage.OutputTokens != 2 {
+		t.Errorf("unexpected response %+v", res)
+	}
+}
+
+func TestOpenAI_Prices(t *testing.T) {
+	p, err := New(WithAPIKey("dummy"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	prices := p.Prices()
+	if prices[GPT5_4.Name] != ListPrices[GPT5_4.Name] {
+		t.Fatalf("expected the list prices, got %+v", prices[GPT5_4.Name])
+	}
+	prices[GPT5_4.Name] = grail.Price{}
+	delete(prices, GPT4o.Name)
+	if ListPrices[GPT5_4.Name].InputPerMTok != 2.50 || len(p.Prices()) != len(ListPrices) {
+		t.Fatal("expected changes to the returned table not to reach ListPrices")
+	}
+}
@@ -0,0 +1,26 @@
+package openai
+
+import (
+	"maps"
+
+	"github.com/montanaflynn/grail"
+)
+
+// ListPrices are OpenAI's standard-tier list prices for text models, in USD
+// per million tokens. Image generation runs as a Responses API tool whose
+// tokens aren't reported in the response's usage, so image and video models
+// aren't listed and their cost isn't included. Models missing here get no
+// Cost; correct or extend the table with grail.WithPrices.
+var ListPrices = grail.PriceTable{
+	GPT5_4.Name:     {InputPerMTok: 2.50, OutputPerMTok: 15, CachedInputPerMTok: 0.25},
+	GPT5_4Mini.Name: {InputPerMTok: 0.75, OutputPerMTok: 4.50, CachedInputPerMTok: 0.075},
+	GPT5_4Nano.Name: {InputPerMTok: 0.20, OutputPerMTok: 1.25, CachedInputPerMTok: 0.02},
+	GPT5_2.Name:     {InputPerMTok: 1.75, OutputPerMTok: 14, CachedInputPerMTok: 0.175},
+	GPT4o.Name:      {InputPerMTok: 2.50, OutputPerMTok: 10, CachedInputPerMTok: 1.25},
+}
+
+// Prices implements grail.PriceLister with a copy of ListPrices, so callers
+// can change it without affecting other providers.
+func (p *Provider) Prices() grail.PriceTable {
+	return maps.Clone(ListPrices)
+}
@@ -0,0 +1,58 @@
+package openai
+
+import (
+	"sync"
+
+	"github.com/montanaflynn/grail"
+)
+
+// WithServerSideState makes a grail.Session keep its context on OpenAI's
+// servers: each turn sends only its new inputs, chained to the previous turn
+// with TextOptions.PreviousResponseID, so long conversations don't resend
+// their history. Responses are stored (TextOptions.Store) so they can be
+// chained. Turns answered by another provider break the chain, and the next
+// OpenAI turn starts fresh.
+func WithServerSideState() grail.SessionOption {
+	return grail.WithSessionState(&serverState{})
+}
+
+type serverState struct {
+	mu     sync.Mutex
+	lastID string
+}
+
+func (s *serverState) PrepareRequest(req *grail.Request) {
+	s.mu.Lock()
+	lastID := s.lastID
+	s.mu.Unlock()
+
+	store := true
+	// The provider applies the last TextOptions, so that's the one to extend.
+	for i := len(req.ProviderOptions) - 1; i >= 0; i-- {
+		if to, ok := req.ProviderOptions[i].(TextOptions); ok {
+			to.PreviousResponseID = lastID
+			if to.Store == nil {
+				to.Store = &store
+			}
+			req.ProviderOptions[i] = to
+			return
+		}
+	}
+	req.ProviderOptions = append(req.ProviderOptions, TextOptions{PreviousResponseID: lastID, Store: &store})
+}
+
+func (s *serverState) RecordResponse(res grail.Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if res.Provider.Name == "openai" {
+		s.lastID = res.RequestID
+	} else {
+		s.lastID = ""
+	}
+}
+
+func (s *serverState) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastID = ""
+}
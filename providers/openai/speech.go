@@ -0,0 +1,82 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/montanaflynn/grail"
+	"github.com/openai/openai-go/v3"
+)
+
+// Defaults used by SynthesizeSpeech when the request doesn't pick a model or
+// voice.
+const (
+	SpeechModel = openai.SpeechModelGPT4oMiniTTS
+	SpeechVoice = "alloy"
+)
+
+// SynthesizeSpeech implements grail.SpeechSynthesizer with the audio speech
+// endpoint.
+func (p *Provider) SynthesizeSpeech(ctx context.Context, req grail.SpeechRequest) (grail.Speech, error) {
+	model := openai.SpeechModel(req.Model)
+	if model == "" {
+		model = SpeechModel
+	}
+	voice := req.Voice
+	if voice == "" {
+		voice = SpeechVoice
+	}
+	format := openai.AudioSpeechNewParamsResponseFormat(req.Format)
+	if format == "" {
+		format = openai.AudioSpeechNewParamsResponseFormatMP3
+	}
+	params := openai.AudioSpeechNewParams{
+		Input:          req.Text,
+		Model:          model,
+		Voice:          openai.AudioSpeechNewParamsVoiceUnion{OfString: openai.String(voice)},
+		ResponseFormat: format,
+	}
+	if req.Instructions != "" {
+		params.Instructions = openai.String(req.Instructions)
+	}
+
+	resp, err := p.client.Audio.Speech.New(ctx, params)
+	if err != nil {
+		return grail.Speech{}, apiError("speech", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return grail.Speech{}, grail.NewGrailError(grail.Unavailable, fmt.Sprintf("read speech: %v", err)).WithCause(err).WithProviderName("openai").WithRetryable(true)
+	}
+	return grail.Speech{
+		Data: data,
+		MIME: speechMIME(format),
+		Provider: grail.ProviderInfo{
+			Name:   "openai",
+			Route:  "speech",
+			Models: []grail.ModelUse{{Role: "speech", Name: string(model)}},
+		},
+	}, nil
+}
+
+// speechMIME returns the MIME type of the speech endpoint's formats. PCM is
+// raw 24kHz 16-bit little-endian mono samples.
+func speechMIME(format openai.AudioSpeechNewParamsResponseFormat) string {
+	switch format {
+	case openai.AudioSpeechNewParamsResponseFormatMP3:
+		return "audio/mpeg"
+	case openai.AudioSpeechNewParamsResponseFormatOpus:
+		return "audio/ogg"
+	case openai.AudioSpeechNewParamsResponseFormatAAC:
+		return "audio/aac"
+	case openai.AudioSpeechNewParamsResponseFormatFLAC:
+		return "audio/flac"
+	case openai.AudioSpeechNewParamsResponseFormatWAV:
+		return "audio/wav"
+	case openai.AudioSpeechNewParamsResponseFormatPCM:
+		return "audio/pcm"
+	}
+	return "application/octet-stream"
+}
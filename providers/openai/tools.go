@@ -0,0 +1,73 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/montanaflynn/grail"
+	"github.com/openai/openai-go/v3/packages/param"
+	"github.com/openai/openai-go/v3/responses"
+)
+
+// toFunctionTools converts grail tools to Responses API function tools.
+// Strict mode is off, since it requires every property to be listed as
+// required, which arbitrary schemas don't do.
+func toFunctionTools(tools []grail.Tool) ([]responses.ToolUnionParam, error) {
+	out := make([]responses.ToolUnionParam, 0, len(tools))
+	for _, t := range tools {
+		params, err := toolParameters(t.Parameters)
+		if err != nil {
+			return nil, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("tool %s: %v", t.Name, err)).WithCause(err).WithProviderName("openai")
+		}
+		fn := &responses.FunctionToolParam{
+			Name:       t.Name,
+			Parameters: params,
+			Strict:     param.NewOpt(false),
+		}
+		if t.Description != "" {
+			fn.Description = param.NewOpt(t.Description)
+		}
+		out = append(out, responses.ToolUnionParam{OfFunction: fn})
+	}
+	return out, nil
+}
+
+// toolParameters returns a tool's parameter schema as a map, defaulting to
+// an object without properties.
+func toolParameters(schema any) (map[string]any, error) {
+	if schema == nil {
+		return map[string]any{"type": "object", "properties": map[string]any{}}, nil
+	}
+	if m, ok := schema.(map[string]any); ok {
+		return m, nil
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("encode parameters: %w", err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parameters must be a JSON object: %w", err)
+	}
+	return m, nil
+}
+
+// extractToolCalls returns the function calls in resp as output parts.
+func extractToolCalls(resp *responses.Response) []grail.OutputPart {
+	if resp == nil {
+		return nil
+	}
+	var parts []grail.OutputPart
+	for _, item := range resp.Output {
+		if item.Type != "function_call" {
+			continue
+		}
+		call := item.AsFunctionCall()
+		parts = append(parts, grail.NewToolCallOutputPart(grail.ToolCall{
+			ID:        call.CallID,
+			Name:      call.Name,
+			Arguments: json.RawMessage(call.Arguments),
+		}))
+	}
+	return parts
+}
@@ -0,0 +1,100 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"time"
+
+	"github.com/montanaflynn/grail"
+	"github.com/openai/openai-go/v3"
+)
+
+// Models used by Transcribe when the request doesn't pick one. Only Whisper
+// returns timestamps, so it transcribes requests that ask for them.
+const (
+	TranscriptionModel            = openai.AudioModelGPT4oTranscribe
+	TimestampedTranscriptionModel = openai.AudioModelWhisper1
+)
+
+// Transcribe implements grail.Transcriber with the audio transcriptions
+// endpoint.
+func (p *Provider) Transcribe(ctx context.Context, req grail.TranscriptionRequest) (grail.Transcription, error) {
+	model := openai.AudioModel(req.Model)
+	if model == "" {
+		model = TranscriptionModel
+		if req.Timestamps {
+			model = TimestampedTranscriptionModel
+		}
+	}
+	name := req.Name
+	if name == "" {
+		name = "audio" + audioExtension(req.MIME)
+	}
+	params := openai.AudioTranscriptionNewParams{
+		File:           openai.File(bytes.NewReader(req.Audio), name, req.MIME),
+		Model:          model,
+		ResponseFormat: openai.AudioResponseFormatJSON,
+	}
+	if req.Language != "" {
+		params.Language = openai.String(req.Language)
+	}
+	if req.Prompt != "" {
+		params.Prompt = openai.String(req.Prompt)
+	}
+	if req.Timestamps {
+		params.ResponseFormat = openai.AudioResponseFormatVerboseJSON
+		params.TimestampGranularities = []string{"segment"}
+	}
+
+	resp, err := p.client.Audio.Transcriptions.New(ctx, params)
+	if err != nil {
+		return grail.Transcription{}, apiError("transcription", err)
+	}
+	t := grail.Transcription{
+		Text:     resp.Text,
+		Language: resp.Language,
+		Duration: seconds(resp.Duration),
+		Usage: grail.Usage{
+			InputTokens:  int(resp.Usage.InputTokens),
+			OutputTokens: int(resp.Usage.OutputTokens),
+			TotalTokens:  int(resp.Usage.TotalTokens),
+		},
+		Provider: grail.ProviderInfo{
+			Name:   "openai",
+			Route:  "transcriptions",
+			Models: []grail.ModelUse{{Role: "transcription", Name: string(model)}},
+		},
+	}
+	if t.Duration == 0 {
+		t.Duration = seconds(resp.Usage.Seconds)
+	}
+	for _, s := range resp.Segments {
+		t.Segments = append(t.Segments, grail.TranscriptSegment{Start: seconds(s.Start), End: seconds(s.End), Text: s.Text})
+	}
+	return t, nil
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Round(s * float64(time.Second)))
+}
+
+// audioExtension returns a file extension for the audio formats the
+// transcriptions endpoint detects by name.
+func audioExtension(mime string) string {
+	switch mime {
+	case "audio/mpeg", "audio/mp3":
+		return ".mp3"
+	case "audio/mp4", "audio/m4a", "audio/x-m4a":
+		return ".m4a"
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		return ".wav"
+	case "audio/webm":
+		return ".webm"
+	case "audio/ogg":
+		return ".ogg"
+	case "audio/flac":
+		return ".flac"
+	}
+	return ""
+}
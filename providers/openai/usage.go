@@ -0,0 +1,89 @@
+package openai
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/montanaflynn/grail"
+	"github.com/openai/openai-go/v3/option"
+)
+
+// usagePage is a page of the organization Usage API, bucketed by day and
+// grouped by model.
+type usagePage struct {
+	Data []struct {
+		Results []struct {
+			Model            string `json:"model"`
+			InputTokens      int    `json:"input_tokens"`
+			OutputTokens     int    `json:"output_tokens"`
+			NumModelRequests int    `json:"num_model_requests"`
+		} `json:"results"`
+	} `json:"data"`
+	HasMore  bool   `json:"has_more"`
+	NextPage string `json:"next_page"`
+}
+
+// ProviderUsage implements grail.UsageSource with the organization Usage API,
+// for reconciling a grail.UsageTracker against OpenAI's records. It reads
+// completions (Responses API) and image usage per model, and needs an admin
+// key (see WithAdminKey). The API reports whole days in UTC, so periods
+// should start and end at midnight UTC. Costs aren't reported per model.
+func (p *Provider) ProviderUsage(ctx context.Context, period grail.Period) ([]grail.ProviderUsage, error) {
+	if p.adminKey == "" {
+		return nil, grail.NewGrailError(grail.Unauthorized, "openai usage requires an admin key (set OPENAI_ADMIN_KEY or use WithAdminKey)").WithProviderName("openai")
+	}
+	if period.Start.IsZero() {
+		return nil, grail.NewGrailError(grail.InvalidArgument, "openai usage requires a period start").WithProviderName("openai")
+	}
+
+	byModel := map[string]*grail.ProviderUsage{}
+	var order []string
+	for _, kind := range []string{"completions", "images"} {
+		page := ""
+		for {
+			opts := []option.RequestOption{
+				option.WithAPIKey(p.adminKey),
+				option.WithQuery("start_time", strconv.FormatInt(period.Start.Unix(), 10)),
+				option.WithQuery("bucket_width", "1d"),
+				option.WithQuery("group_by", "model"),
+				option.WithQuery("limit", "31"),
+			}
+			if !period.End.IsZero() {
+				opts = append(opts, option.WithQuery("end_time", strconv.FormatInt(period.End.Unix(), 10)))
+			}
+			if page != "" {
+				opts = append(opts, option.WithQuery("page", page))
+			}
+			var res usagePage
+			if err := p.client.Get(ctx, "organization/usage/"+kind, nil, &res, opts...); err != nil {
+				return nil, apiError("usage", err)
+			}
+			for _, bucket := range res.Data {
+				for _, r := range bucket.Results {
+					u := byModel[r.Model]
+					if u == nil {
+						u = &grail.ProviderUsage{Model: r.Model}
+						byModel[r.Model] = u
+						order = append(order, r.Model)
+					}
+					u.Requests += r.NumModelRequests
+					u.Usage = u.Usage.Add(grail.Usage{
+						InputTokens:  r.InputTokens,
+						OutputTokens: r.OutputTokens,
+						TotalTokens:  r.InputTokens + r.OutputTokens,
+					})
+				}
+			}
+			if !res.HasMore || res.NextPage == "" {
+				break
+			}
+			page = res.NextPage
+		}
+	}
+
+	out := make([]grail.ProviderUsage, 0, len(order))
+	for _, m := range order {
+		out = append(out, *byModel[m])
+	}
+	return out, nil
+}
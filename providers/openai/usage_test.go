@@ -0,0 +1,60 @@
+package openai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+)
+
+func TestOpenAI_ProviderUsage(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer admin" {
+			t.Errorf("expected the admin key, got %q", got)
+		}
+		q := r.URL.Query()
+		if q.Get("start_time") != "1772323200" || q.Get("group_by") != "model" || q.Get("bucket_width") != "1d" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/organization/usage/completions" && q.Get("page") == "":
+			io.WriteString(w, `{"object":"page","has_more":true,"next_page":"p2","data":[{"object":"bucket","results":[
+				{"object":"organization.usage.completions.result","model":"gpt-5.4","input_tokens":100,"output_tokens":40,"num_model_requests":2}]}]}`)
+		case r.URL.Path == "/v1/organization/usage/completions" && q.Get("page") == "p2":
+			io.WriteString(w, `{"object":"page","has_more":false,"data":[{"object":"bucket","results":[
+				{"object":"organization.usage.completions.result","model":"gpt-5.4","input_tokens":50,"output_tokens":10,"num_model_requests":1}]}]}`)
+		case r.URL.Path == "/v1/organization/usage/images":
+			io.WriteString(w, `{"object":"page","has_more":false,"data":[{"object":"bucket","results":[
+				{"object":"organization.usage.images.result","model":"gpt-image-2","images":3,"num_model_requests":3}]}]}`)
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	p, err := New(WithAPIKey("dummy"), WithAdminKey("admin"), WithBaseURL(srv.URL+"/v1/"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	usage, err := p.ProviderUsage(context.Background(), grail.Month(start))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(usage) != 2 || usage[0].Model != "gpt-5.4" || usage[0].Requests != 3 ||
+		usage[0].Usage.InputTokens != 150 || usage[0].Usage.OutputTokens != 50 || usage[1].Requests != 3 {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+
+	t.Setenv("OPENAI_ADMIN_KEY", "")
+	p, _ = New(WithAPIKey("dummy"), WithBaseURL(srv.URL+"/v1/"))
+	if _, err := p.ProviderUsage(context.Background(), grail.Month(start)); grail.GetErrorCode(err) != grail.Unauthorized {
+		t.Fatalf("expected Unauthorized without an admin key, got %v", err)
+	}
+}
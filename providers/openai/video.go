@@ -0,0 +1,155 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/montanaflynn/grail"
+	"github.com/openai/openai-go/v3"
+)
+
+// DefaultVideoModelName is the Sora model used for video output when no
+// override is provided.
+const DefaultVideoModelName = openai.VideoModelSora2
+
+// videoPollInterval is how often a video job is checked.
+var videoPollInterval = 10 * time.Second
+
+// VideoOptions provides Sora-specific video generation options.
+type VideoOptions struct {
+	Model string
+	// Size overrides the resolution picked from VideoSpec.AspectRatio, such
+	// as "1792x1024" for sora-2-pro.
+	Size string
+}
+
+func (VideoOptions) ApplyProviderOption() {}
+
+// videoSizes are the default resolutions for each aspect ratio.
+var videoSizes = map[string]openai.VideoSize{
+	"16:9": openai.VideoSize1280x720,
+	"9:16": openai.VideoSize720x1280,
+}
+
+// generateVideo starts a Sora video job and polls it until the video is
+// ready.
+func (p *Provider) generateVideo(ctx context.Context, req grail.Request, spec grail.VideoSpec) (grail.Response, error) {
+	var opts VideoOptions
+	for _, opt := range req.ProviderOptions {
+		if vo, ok := opt.(VideoOptions); ok {
+			opts = vo
+		}
+	}
+	model := DefaultVideoModelName
+	if opts.Model != "" {
+		model = openai.VideoModel(opts.Model)
+	}
+	if req.Model != "" {
+		model = openai.VideoModel(req.Model)
+	}
+
+	params := openai.VideoNewParams{Model: model}
+	var prompt []string
+	for i, in := range req.Inputs {
+		if text, ok := grail.AsTextInput(in); ok {
+			prompt = append(prompt, text)
+			continue
+		}
+		data, mimeType, name, ok := grail.AsFileInput(in)
+		if mimeType == "" {
+			mimeType = grail.SniffImageMIME(data)
+		}
+		if !ok || !strings.HasPrefix(mimeType, "image/") || params.InputReference.OfFile != nil {
+			return grail.Response{}, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("input %d: video prompts take text and at most one image", i)).WithProviderName("openai")
+		}
+		if name == "" {
+			name = "reference" + extensionFor(mimeType)
+		}
+		params.InputReference.OfFile = openai.File(bytes.NewReader(data), name, mimeType)
+	}
+	params.Prompt = strings.Join(prompt, "\n\n")
+	switch spec.DurationSeconds {
+	case 0:
+	case 4, 8, 12:
+		params.Seconds = openai.VideoSeconds(strconv.Itoa(spec.DurationSeconds))
+	default:
+		return grail.Response{}, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("sora videos are 4, 8, or 12 seconds long, not %d", spec.DurationSeconds)).WithProviderName("openai")
+	}
+	if opts.Size != "" {
+		params.Size = openai.VideoSize(opts.Size)
+	} else if spec.AspectRatio != "" {
+		size, ok := videoSizes[spec.AspectRatio]
+		if !ok {
+			return grail.Response{}, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("sora videos are 16:9 or 9:16, not %s", spec.AspectRatio)).WithProviderName("openai")
+		}
+		params.Size = size
+	}
+
+	if log := p.logger(); log != nil {
+		log.Debug("openai generate video request", slog.String("model", string(model)))
+	}
+	video, err := p.client.Videos.New(ctx, params)
+	if err != nil {
+		return grail.Response{}, apiError("video", err)
+	}
+	for video.Status == openai.VideoStatusQueued || video.Status == openai.VideoStatusInProgress {
+		select {
+		case <-ctx.Done():
+			return grail.Response{}, grail.NewGrailError(grail.Timeout, fmt.Sprintf("video %s didn't finish: %v", video.ID, ctx.Err())).
+				WithCause(ctx.Err()).WithProviderName("openai").WithRequestID(video.ID)
+		case <-time.After(videoPollInterval):
+		}
+		if video, err = p.client.Videos.Get(ctx, video.ID); err != nil {
+			return grail.Response{}, apiError("video status", err)
+		}
+	}
+	if video.Status != openai.VideoStatusCompleted {
+		code := grail.Internal
+		if strings.Contains(video.Error.Code, "moderation") || strings.Contains(video.Error.Code, "policy") {
+			code = grail.Refused
+		}
+		return grail.Response{}, grail.NewGrailError(code, fmt.Sprintf("video generation %s: %s", video.Status, video.Error.Message)).
+			WithProviderName("openai").WithRequestID(video.ID).WithDetail("error_code", video.Error.Code)
+	}
+
+	content, err := p.client.Videos.DownloadContent(ctx, video.ID, openai.VideoDownloadContentParams{})
+	if err != nil {
+		return grail.Response{}, apiError("video download", err)
+	}
+	defer content.Body.Close()
+	data, err := io.ReadAll(content.Body)
+	if err != nil {
+		return grail.Response{}, grail.NewGrailError(grail.Unavailable, fmt.Sprintf("read video: %v", err)).WithCause(err).WithProviderName("openai").WithRetryable(true)
+	}
+	mimeType, _, _ := mime.ParseMediaType(content.Header.Get("Content-Type"))
+	if !strings.HasPrefix(mimeType, "video/") {
+		mimeType = "video/mp4"
+	}
+
+	if log := p.logger(); log != nil {
+		log.Debug("openai generate video response", slog.String("id", video.ID), slog.Int("bytes", len(data)))
+	}
+	return grail.Response{
+		Outputs: []grail.OutputPart{grail.NewVideoOutputPart(data, mimeType, video.ID+videoExtension(mimeType))},
+		Provider: grail.ProviderInfo{
+			Name:   "openai",
+			Route:  "videos",
+			Models: []grail.ModelUse{{Role: "video_generation", Name: string(video.Model)}},
+		},
+		RequestID: video.ID,
+	}, nil
+}
+
+func videoExtension(mimeType string) string {
+	if mimeType == "video/webm" {
+		return ".webm"
+	}
+	return ".mp4"
+}
@@ -0,0 +1,100 @@
+package openai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+)
+
+func TestOpenAI_GenerateVideo(t *testing.T) {
+	videoPollInterval = time.Millisecond
+	t.Cleanup(func() { videoPollInterval = 10 * time.Second })
+
+	var form map[string][]string
+	var polls int
+	hc := &http.Client{Transport: stubTransport(func(r *http.Request) (*http.Response, error) {
+		body, contentType := `{"id":"video_1","status":"queued","model":"sora-2"}`, "application/json"
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/videos":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				t.Fatalf("parse upload: %v", err)
+			}
+			form = r.MultipartForm.Value
+			if len(r.MultipartForm.File["input_reference"]) != 1 {
+				t.Errorf("expected the image as the input reference")
+			}
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/videos/video_1":
+			if polls++; polls > 1 {
+				body = `{"id":"video_1","status":"completed","model":"sora-2"}`
+			}
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/videos/video_1/content":
+			body, contentType = "mp4 data", "video/mp4"
+		default:
+			t.Fatalf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{contentType}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    r,
+		}, nil
+	})}
+	p, err := New(WithAPIKey("dummy"), WithHTTPClient(hc))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res, err := grail.NewClient(p).Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("The fox starts running"), grail.InputImage([]byte("\x89PNG\r\n\x1a\n"))},
+		Output: grail.OutputVideo(grail.VideoSpec{DurationSeconds: 8, AspectRatio: "9:16"}),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	videos := res.Videos()
+	if len(videos) != 1 || string(videos[0].Data) != "mp4 data" || videos[0].MIME != "video/mp4" || res.RequestID != "video_1" {
+		t.Fatalf("unexpected response %+v", res)
+	}
+	if polls != 2 {
+		t.Errorf("expected the job to be polled until it completed, got %d polls", polls)
+	}
+	if form["seconds"][0] != "8" || form["size"][0] != "720x1280" || form["prompt"][0] != "The fox starts running" {
+		t.Errorf("unexpected form %v", form)
+	}
+}
+
+func TestOpenAI_GenerateVideoErrors(t *testing.T) {
+	hc := &http.Client{Transport: stubTransport(func(r *http.Request) (*http.Response, error) {
+		body := `{"id":"video_2","status":"failed","error":{"code":"moderation_blocked","message":"blocked by moderation"}}`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    r,
+		}, nil
+	})}
+	p, err := New(WithAPIKey("dummy"), WithHTTPClient(hc))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	generate := func(spec grail.VideoSpec) error {
+		_, err := p.DoGenerate(context.Background(), grail.Request{
+			Inputs: []grail.Input{grail.InputText("A fox")},
+			Output: grail.OutputVideo(spec),
+		})
+		return err
+	}
+	if err := generate(grail.VideoSpec{}); grail.GetErrorCode(err) != grail.Refused {
+		t.Errorf("expected Refused for a moderated video, got %v", err)
+	}
+	if err := generate(grail.VideoSpec{DurationSeconds: 5}); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected InvalidArgument for an unsupported duration, got %v", err)
+	}
+	if err := generate(grail.VideoSpec{AspectRatio: "1:1"}); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected InvalidArgument for an unsupported aspect ratio, got %v", err)
+	}
+}
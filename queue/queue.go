@@ -0,0 +1,246 @@
+// Package queue runs grail as a generation worker behind a message queue:
+// a Worker consumes request messages, generates them with GenerateBatch,
+// and publishes the responses. Brokers (Kafka, NATS, SQS, ...) plug in
+// through the Consumer and Publisher interfaces.
+//
+// Request messages are JSON grail.RequestRecords, as made by
+// grail.NewRequestRecord. Result messages are JSON Results.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/montanaflynn/grail"
+)
+
+// Message is a message consumed from or published to a queue.
+type Message struct {
+	// ID identifies the message to its broker, such as an SQS receipt
+	// handle or a Kafka "topic/partition/offset".
+	ID string
+	// Key is the partition or ordering key. Results are published with
+	// their request's key.
+	Key     string
+	Body    []byte
+	Headers map[string]string
+	// Attempt is the delivery attempt, from 1, for brokers that count
+	// redeliveries; 0 if the broker doesn't.
+	Attempt int
+}
+
+// Consumer receives request messages.
+type Consumer interface {
+	// Receive blocks until at least one message is available and returns up
+	// to max of them.
+	Receive(ctx context.Context, max int) ([]Message, error)
+	// Ack marks messages as processed, so they aren't delivered again.
+	Ack(ctx context.Context, msgs ...Message) error
+	// Nack returns messages to the queue for redelivery. Brokers without
+	// per-message redelivery, such as Kafka, can leave their offsets
+	// uncommitted or route them to a retry topic.
+	Nack(ctx context.Context, msgs ...Message) error
+}
+
+// Publisher publishes result messages.
+type Publisher interface {
+	Publish(ctx context.Context, msgs ...Message) error
+}
+
+// Result is the body of a result message: the response to a request
+// message, or the error that took its place.
+type Result struct {
+	MessageID string                `json:"message_id"`    // the request message's ID
+	Key       string                `json:"key,omitempty"` // the request's grail.RequestKey
+	Response  *grail.ResponseRecord `json:"response,omitempty"`
+	Error     *Error                `json:"error,omitempty"`
+}
+
+// Error describes a failed request.
+type Error struct {
+	Message   string            `json:"message"`
+	Code      grail.ErrorCode   `json:"code"`
+	Retryable bool              `json:"retryable,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+func newError(err error) *Error {
+	e := &Error{Message: err.Error(), Code: grail.GetErrorCode(err), Retryable: grail.IsRetryable(err)}
+	var ge grail.GrailError
+	if errors.As(err, &ge) {
+		e.Details = ge.Details()
+	}
+	return e
+}
+
+// Worker consumes request messages, generates them, and publishes their
+// results. Received messages are generated together with GenerateBatch, and
+// no more are received while MaxInFlight are being worked on, so a slow
+// provider slows consumption rather than piling up messages in memory.
+//
+// A message's result is published before the message is acked, so a crash
+// in between redelivers it (at-least-once). Requests that fail with a
+// retryable error are nacked for redelivery until MaxAttempts; other
+// failures, including messages that aren't request records, are published
+// as error results and acked.
+type Worker struct {
+	Client    grail.Client
+	Consumer  Consumer
+	Publisher Publisher
+	// MaxInFlight bounds the messages being worked on at once (default 16).
+	MaxInFlight int
+	// MaxAttempts is the delivery attempt after which a retryable failure is
+	// published as an error instead of nacked (default 3). It needs a broker
+	// that counts attempts; otherwise retryable failures are always nacked.
+	MaxAttempts int
+	// Options decodes the requests' provider options.
+	Options grail.OptionDecoder
+	// OnError is called with errors publishing results and acking or
+	// nacking messages, which the worker otherwise survives.
+	OnError func(error)
+}
+
+// Run works until ctx is canceled or Receive fails, then waits for messages
+// in flight. Messages whose generation was cut short by the cancellation are
+// nacked. Run returns nil when ctx is canceled, and Receive's error
+// otherwise.
+func (w *Worker) Run(ctx context.Context) error {
+	maxInFlight := w.MaxInFlight
+	if maxInFlight <= 0 {
+		maxInFlight = 16
+	}
+	slots := make(chan struct{}, maxInFlight)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		// Wait for a free slot, then claim the rest without waiting.
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil
+		}
+		free := 1
+	claim:
+		for free < maxInFlight {
+			select {
+			case slots <- struct{}{}:
+				free++
+			default:
+				break claim
+			}
+		}
+
+		msgs, err := w.Consumer.Receive(ctx, free)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// Give back the slots Receive didn't fill.
+		for range free - len(msgs) {
+			<-slots
+		}
+		if len(msgs) == 0 {
+			continue
+		}
+		wg.Go(func() {
+			defer func() {
+				for range msgs {
+					<-slots
+				}
+			}()
+			w.process(ctx, msgs)
+		})
+	}
+}
+
+// process generates msgs as a batch and settles each one.
+func (w *Worker) process(ctx context.Context, msgs []Message) {
+	maxAttempts := w.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+	results := make([]*Result, len(msgs))
+	var reqs []grail.Request
+	var batched []int // the messages reqs were decoded from
+	for i, m := range msgs {
+		results[i] = &Result{MessageID: m.ID}
+		var rec grail.RequestRecord
+		if err := json.Unmarshal(m.Body, &rec); err != nil {
+			results[i].Error = newError(grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("decode request message: %v", err)).WithCause(err))
+			continue
+		}
+		req, err := rec.Request(w.Options)
+		if err != nil {
+			results[i].Error = newError(err)
+			continue
+		}
+		results[i].Key = rec.Key
+		reqs = append(reqs, req)
+		batched = append(batched, i)
+	}
+
+	var nack []Message
+	if len(reqs) > 0 {
+		for _, br := range grail.GenerateBatch(ctx, w.Client, reqs, grail.BatchOptions{Concurrency: len(reqs)}) {
+			i := batched[br.Index]
+			if br.Err == nil {
+				rr := grail.NewResponseRecord(br.Response)
+				results[i].Response = &rr
+				continue
+			}
+			attempt := msgs[i].Attempt
+			if ctx.Err() != nil || grail.IsRetryable(br.Err) && (attempt == 0 || attempt < maxAttempts) {
+				nack = append(nack, msgs[i])
+				results[i] = nil
+				continue
+			}
+			results[i].Error = newError(br.Err)
+		}
+	}
+
+	// Settling outlives cancellation, so a shutdown doesn't strand messages
+	// that finished.
+	sctx := context.WithoutCancel(ctx)
+	var out, done []Message
+	for i, r := range results {
+		if r == nil {
+			continue
+		}
+		body, err := json.Marshal(r)
+		if err != nil {
+			w.report(grail.NewGrailError(grail.Internal, fmt.Sprintf("encode result of message %s: %v", r.MessageID, err)).WithCause(err))
+			nack = append(nack, msgs[i])
+			continue
+		}
+		out = append(out, Message{Key: msgs[i].Key, Body: body, Headers: msgs[i].Headers})
+		done = append(done, msgs[i])
+	}
+	if len(out) > 0 {
+		if err := w.Publisher.Publish(sctx, out...); err != nil {
+			w.report(err)
+			nack = append(nack, done...)
+			done = nil
+		}
+	}
+	if len(done) > 0 {
+		if err := w.Consumer.Ack(sctx, done...); err != nil {
+			w.report(err)
+		}
+	}
+	if len(nack) > 0 {
+		if err := w.Consumer.Nack(sctx, nack...); err != nil {
+			w.report(err)
+		}
+	}
+}
+
+func (w *Worker) report(err error) {
+	if w.OnError != nil {
+		w.OnError(err)
+	}
+}
@@ -0,0 +1,149 @@
+package queue_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+	"github.com/montanaflynn/grail/queue"
+)
+
+// memQueue is an in-memory broker that redelivers nacked messages with their
+// attempt counted.
+type memQueue struct {
+	in chan queue.Message
+
+	mu        sync.Mutex
+	acked     []string
+	published []queue.Message
+	settled   chan struct{}
+}
+
+func (q *memQueue) Receive(ctx context.Context, max int) ([]queue.Message, error) {
+	var msgs []queue.Message
+	select {
+	case m := <-q.in:
+		msgs = append(msgs, m)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	for len(msgs) < max {
+		select {
+		case m := <-q.in:
+			msgs = append(msgs, m)
+		default:
+			return msgs, nil
+		}
+	}
+	return msgs, nil
+}
+
+func (q *memQueue) Ack(ctx context.Context, msgs ...queue.Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, m := range msgs {
+		q.acked = append(q.acked, m.ID)
+		q.settled <- struct{}{}
+	}
+	return nil
+}
+
+func (q *memQueue) Nack(ctx context.Context, msgs ...queue.Message) error {
+	for _, m := range msgs {
+		m.Attempt++
+		q.in <- m
+	}
+	return nil
+}
+
+func (q *memQueue) Publish(ctx context.Context, msgs ...queue.Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.published = append(q.published, msgs...)
+	return nil
+}
+
+func TestWorker(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	mp := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			time.Sleep(5 * time.Millisecond)
+			prompt, _ := grail.AsTextInput(req.Inputs[0])
+			switch prompt {
+			case "refuse":
+				return grail.Response{}, grail.NewGrailError(grail.Refused, "no")
+			case "overloaded":
+				return grail.Response{}, grail.NewGrailError(grail.Unavailable, "try later")
+			}
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("echo " + prompt)}}, nil
+		},
+	}
+
+	q := &memQueue{in: make(chan queue.Message, 64), settled: make(chan struct{}, 64)}
+	prompts := []string{"a", "b", "c", "d", "e", "f", "refuse", "overloaded"}
+	for i, p := range prompts {
+		rec, err := grail.NewRequestRecord(grail.Request{Inputs: []grail.Input{grail.InputText(p)}, Output: grail.OutputText()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := json.Marshal(rec)
+		q.in <- queue.Message{ID: fmt.Sprint(i), Key: p, Body: body, Attempt: 1}
+	}
+	q.in <- queue.Message{ID: "bad", Body: []byte("not json"), Attempt: 1}
+
+	w := &queue.Worker{Client: grail.NewClient(mp), Consumer: q, Publisher: q, MaxInFlight: 3, MaxAttempts: 2}
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- w.Run(ctx) }()
+	for range len(prompts) + 1 {
+		select {
+		case <-q.settled:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for messages to be acked")
+		}
+	}
+	cancel()
+	if err := <-errc; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if peak.Load() > 3 {
+		t.Errorf("expected at most 3 requests in flight, got %d", peak.Load())
+	}
+	results := map[string]queue.Result{}
+	for _, m := range q.published {
+		var r queue.Result
+		if err := json.Unmarshal(m.Body, &r); err != nil {
+			t.Fatal(err)
+		}
+		results[r.MessageID] = r
+	}
+	if len(results) != len(prompts)+1 {
+		t.Fatalf("expected a result per message, got %d", len(results))
+	}
+	if r := results["0"]; r.Response == nil || r.Key == "" {
+		t.Errorf("unexpected result %+v", r)
+	} else if text, _ := r.Response.Response().Text(); text != "echo a" {
+		t.Errorf("unexpected response %q", text)
+	}
+	if r := results["6"]; r.Error == nil || r.Error.Code != grail.Refused || r.Error.Retryable {
+		t.Errorf("expected a refusal result, got %+v", r)
+	}
+	if r := results["7"]; r.Error == nil || r.Error.Code != grail.Unavailable || !r.Error.Retryable {
+		t.Errorf("expected a retryable failure to be published after its last attempt, got %+v", r)
+	}
+	if r := results["bad"]; r.Error == nil || !strings.Contains(r.Error.Message, "decode request message") {
+		t.Errorf("expected an undecodable message to fail, got %+v", r)
+	}
+}
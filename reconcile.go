@@ -0,0 +1,141 @@
+package grail
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+)
+
+//
+// Usage reconciliation
+//
+
+// ProviderUsage is the usage a provider's own records show for one model over
+// a period.
+type ProviderUsage struct {
+	Model    string  `json:"model"`
+	Requests int     `json:"requests,omitempty"` // 0 if the provider doesn't report requests
+	Usage    Usage   `json:"usage"`
+	Cost     float64 `json:"cost_usd,omitempty"`
+	HasCost  bool    `json:"has_cost,omitempty"` // Cost is reported
+}
+
+// UsageSource reports usage from a provider's usage or billing records. The
+// openai and gemini packages provide implementations.
+type UsageSource interface {
+	// Name returns the provider name, as recorded in UsageRecord.Provider.
+	Name() string
+	ProviderUsage(ctx context.Context, period Period) ([]ProviderUsage, error)
+}
+
+// Reconciliation compares a tracker's records with a provider's, model by
+// model.
+type Reconciliation struct {
+	Provider string          `json:"provider"`
+	Period   Period          `json:"period"`
+	Lines    []ReconcileLine `json:"lines"`
+}
+
+// ReconcileLine compares one model's usage.
+type ReconcileLine struct {
+	Model    string        `json:"model"`
+	Local    CostLine      `json:"local"`
+	Provider ProviderUsage `json:"provider"`
+	Flagged  bool          `json:"flagged"`
+	Reasons  []string      `json:"reasons,omitempty"` // why the line is flagged
+}
+
+// Flagged returns the lines whose usage disagrees.
+func (r Reconciliation) Flagged() []ReconcileLine {
+	var out []ReconcileLine
+	for _, l := range r.Lines {
+		if l.Flagged {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// Reconcile fetches src's usage for period and compares it with the records
+// for the same provider, flagging models whose input tokens, output tokens,
+// requests, or cost differ by more than tolerance, as a fraction of the larger
+// value (0.02 allows 2%). Requests and cost are only compared when the
+// provider reports them, and cost only when every local record is priced.
+//
+// Provider records cover the whole account, so usage from outside grail shows
+// up as a discrepancy, as do records pruned from the tracker. Providers also
+// report with some delay; reconcile periods that ended a while ago.
+func (t *UsageTracker) Reconcile(ctx context.Context, src UsageSource, period Period, tolerance float64) (Reconciliation, error) {
+	remote, err := src.ProviderUsage(ctx, period)
+	if err != nil {
+		return Reconciliation{}, err
+	}
+	rec := Reconciliation{Provider: src.Name(), Period: period}
+
+	local := map[string]*CostLine{}
+	for _, r := range t.Records(period) {
+		if r.Provider != rec.Provider {
+			continue
+		}
+		if local[r.Model] == nil {
+			local[r.Model] = &CostLine{Key: r.Model}
+		}
+		local[r.Model].add(r)
+	}
+	provider := map[string]*ProviderUsage{}
+	for _, u := range remote {
+		p := provider[u.Model]
+		if p == nil {
+			p = &ProviderUsage{Model: u.Model}
+			provider[u.Model] = p
+		}
+		p.Requests += u.Requests
+		p.Usage = p.Usage.Add(u.Usage)
+		p.Cost += u.Cost
+		p.HasCost = p.HasCost || u.HasCost
+	}
+
+	models := map[string]bool{}
+	for m := range local {
+		models[m] = true
+	}
+	for m := range provider {
+		models[m] = true
+	}
+	for m := range models {
+		line := ReconcileLine{Model: m, Local: CostLine{Key: m}, Provider: ProviderUsage{Model: m}}
+		if l := local[m]; l != nil {
+			line.Local = *l
+		}
+		if p := provider[m]; p != nil {
+			line.Provider = *p
+		}
+		line.Reasons = compareUsage(line.Local, line.Provider, tolerance)
+		line.Flagged = len(line.Reasons) > 0
+		rec.Lines = append(rec.Lines, line)
+	}
+	sort.Slice(rec.Lines, func(i, j int) bool { return rec.Lines[i].Model < rec.Lines[j].Model })
+	return rec, nil
+}
+
+func compareUsage(l CostLine, p ProviderUsage, tolerance float64) []string {
+	var reasons []string
+	differs := func(a, b float64) bool {
+		return math.Abs(a-b) > tolerance*math.Max(math.Abs(a), math.Abs(b))
+	}
+	check := func(what string, local, provider int) {
+		if differs(float64(local), float64(provider)) {
+			reasons = append(reasons, fmt.Sprintf("%s: local %d, provider %d", what, local, provider))
+		}
+	}
+	check("input tokens", l.Usage.InputTokens, p.Usage.InputTokens)
+	check("output tokens", l.Usage.OutputTokens, p.Usage.OutputTokens)
+	if p.Requests > 0 {
+		check("requests", l.Requests, p.Requests)
+	}
+	if p.HasCost && l.Unpriced == 0 && differs(l.Cost, p.Cost) {
+		reasons = append(reasons, fmt.Sprintf("cost: local $%.4f, provider $%.4f", l.Cost, p.Cost))
+	}
+	return reasons
+}
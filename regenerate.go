@@ -0,0 +1,118 @@
+package grail
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+//
+// Regeneration
+//
+
+// Request returns the request that produced r, as the caller passed it to
+// Generate. Responses rebuilt from a ResponseRecord rebuild it from the
+// recorded request, decoding its provider options with options, which may
+// be nil if it has none. Responses without a request, such as ones built by
+// providers, are an InvalidArgument error.
+func (r Response) Request(options OptionDecoder) (Request, error) {
+	switch {
+	case r.request != nil:
+		return *r.request, nil
+	case r.recorded != nil:
+		return r.recorded.Request(options)
+	}
+	return Request{}, NewGrailError(InvalidArgument, "response has no request; it must come from Generate")
+}
+
+// requestRecord records the request that produced r, or returns nil if
+// there's none or it can't be recorded (see NewRequestRecord). Streamed file
+// inputs were consumed by the request, so requests with them aren't
+// recorded.
+func (r Response) requestRecord() *RequestRecord {
+	if r.recorded != nil {
+		return r.recorded
+	}
+	if r.request == nil {
+		return nil
+	}
+	for _, in := range r.request.Inputs {
+		if _, ok := in.(fileReaderInput); ok {
+			return nil
+		}
+	}
+	rec, err := NewRequestRecord(*r.request)
+	if err != nil {
+		return nil
+	}
+	return &rec
+}
+
+// WithOptionDecoder sets how Regenerate decodes the provider options of
+// requests recorded with responses, for responses rebuilt from a
+// ResponseRecord, such as ProviderOptionTypes(openai.TextOptions{}).
+func WithOptionDecoder(options OptionDecoder) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.optionDecoder = options
+	})
+}
+
+// Regenerate revises prev's output according to feedback, for "try again
+// with changes": the request that produced prev is sent again with prev's
+// output and the feedback appended, and the model is asked to change only
+// what the feedback asks for. The revision keeps the original output type,
+// model selection, and options, and can itself be regenerated.
+//
+// prev must have been returned by Generate on a client, or rebuilt from a
+// ResponseRecord of one, with provider options decoded per
+// WithOptionDecoder. Requests with streamed file inputs (InputFileReader)
+// can't be regenerated, since the stream was consumed by the first request.
+func (c *client) Regenerate(ctx context.Context, prev Response, feedback string) (Response, error) {
+	req, err := prev.Request(c.opts.optionDecoder)
+	if err != nil {
+		return Response{}, err
+	}
+	if strings.TrimSpace(feedback) == "" {
+		return Response{}, NewGrailError(InvalidArgument, "feedback must not be empty")
+	}
+	for i, in := range req.Inputs {
+		if _, ok := in.(fileReaderInput); ok {
+			return Response{}, NewGrailError(InvalidArgument, fmt.Sprintf("input %d: streamed file inputs can't be sent again", i))
+		}
+	}
+	revision, err := revisionInputs(prev, feedback)
+	if err != nil {
+		return Response{}, err
+	}
+	req.Inputs = append(req.Inputs[:len(req.Inputs):len(req.Inputs)], revision...)
+	return c.Generate(ctx, req)
+}
+
+// revisionInputs shows the model its previous output and asks for a
+// revision.
+func revisionInputs(prev Response, feedback string) ([]Input, error) {
+	var inputs []Input
+	var kind string
+	for _, part := range prev.Outputs {
+		switch v := part.(type) {
+		case textOutputPart:
+			inputs = append(inputs, InputText("Your previous response was:\n\n"+v.Text))
+			kind = "response"
+		case jsonOutputPart:
+			inputs = append(inputs, InputText("Your previous response was:\n\n"+string(v.JSON)))
+			kind = "response"
+		case imageOutputPart:
+			if kind == "" {
+				inputs = append(inputs, InputText("These are the images you generated before:"))
+			}
+			inputs = append(inputs, InputFile(v.Data, v.MIME, WithFileName(v.Name)))
+			kind = "images"
+		}
+	}
+	if len(inputs) == 0 {
+		return nil, NewGrailError(InvalidArgument, "response has no output to revise")
+	}
+	inputs = append(inputs, InputText(fmt.Sprintf("Revise your %s according to this feedback. "+
+		"Change only what the feedback asks for and keep everything else as it was.\n\nFeedback: %s", kind, feedback)))
+	return inputs, nil
+}
@@ -0,0 +1,105 @@
+package grail_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestRegenerate(t *testing.T) {
+	var got grail.Request
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			got = req
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("draft " + string(rune('0'+len(req.Inputs))))}}, nil
+		},
+	}
+	client := grail.NewClient(prov)
+	ctx := context.Background()
+
+	first, err := client.Generate(ctx, grail.Request{
+		Inputs: []grail.Input{grail.InputText("Write a tagline for a bakery.")},
+		Output: grail.OutputText(),
+		Model:  "m1",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req, err := first.Request(nil); err != nil || req.Model != "m1" {
+		t.Fatalf("expected the request to be kept with the response, got %+v", req)
+	}
+
+	second, err := client.Regenerate(ctx, first, "Make it shorter.")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Model != "m1" || len(got.Inputs) != 3 {
+		t.Fatalf("expected the original request with two inputs appended, got model %q and %d inputs", got.Model, len(got.Inputs))
+	}
+	prevText, _ := grail.AsTextInput(got.Inputs[1])
+	feedback, _ := grail.AsTextInput(got.Inputs[2])
+	if !strings.Contains(prevText, "draft 1") || !strings.Contains(feedback, "Make it shorter.") {
+		t.Errorf("expected the previous output and feedback, got %q and %q", prevText, feedback)
+	}
+
+	if _, err := client.Regenerate(ctx, second, "Add a pun."); err != nil || len(got.Inputs) != 5 {
+		t.Fatalf("expected a revision to be regenerated, got %v with %d inputs", err, len(got.Inputs))
+	}
+
+	if _, err := client.Regenerate(ctx, grail.Response{}, "again"); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected InvalidArgument without a request, got %v", err)
+	}
+	if _, err := client.Regenerate(ctx, first, " "); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected InvalidArgument without feedback, got %v", err)
+	}
+}
+
+func TestRegenerateRecorded(t *testing.T) {
+	var got grail.Request
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			got = req
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("draft")}}, nil
+		},
+	}
+	client := grail.NewClient(prov, grail.WithOptionDecoder(grail.ProviderOptionTypes(recordOption{})))
+	ctx := context.Background()
+	first, err := client.Generate(ctx, grail.Request{
+		Inputs:          []grail.Input{grail.InputText("Write a tagline for a bakery.")},
+		Output:          grail.OutputText(),
+		Model:           "m1",
+		ProviderOptions: []grail.ProviderOption{recordOption{Effort: "low"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A response stored as JSON and loaded again can be regenerated.
+	data, err := json.Marshal(grail.NewResponseRecord(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec grail.ResponseRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatal(err)
+	}
+	loaded := rec.Response()
+	if _, err := client.Regenerate(ctx, loaded, "Make it shorter."); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Model != "m1" || len(got.Inputs) != 3 || len(got.ProviderOptions) != 1 || got.ProviderOptions[0] != (recordOption{Effort: "low"}) {
+		t.Fatalf("expected the recorded request with two inputs appended, got %+v", got)
+	}
+	if text, _ := grail.AsTextInput(got.Inputs[0]); text != "Write a tagline for a bakery." {
+		t.Errorf("unexpected first input %q", text)
+	}
+
+	// Without a decoder, the recorded provider options can't be rebuilt.
+	if _, err := grail.NewClient(prov).Regenerate(ctx, loaded, "again"); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected InvalidArgument without an option decoder, got %v", err)
+	}
+}
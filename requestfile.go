@@ -0,0 +1,339 @@
+package grail
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+//
+// JSONL request files
+//
+
+// RequestSpec is one line of a JSONL request file: a request described in
+// plain data, for running datasets through GenerateBatch.
+//
+//	{"id": "q1", "prompt": "Summarize {{.title}}.", "vars": {"title": "the report"}, "attachments": ["report.pdf"]}
+//	{"id": "q2", "prompt": "A watercolor fox", "output": "image", "images": 2}
+type RequestSpec struct {
+	// ID identifies the line in results. It's stored in the request's
+	// metadata under "id"; lines without one get their line number.
+	ID string `json:"id,omitempty"`
+	// Prompt is the text input. With Vars, it's a text/template executed
+	// with Vars as data.
+	Prompt string         `json:"prompt"`
+	Vars   map[string]any `json:"vars,omitempty"`
+	// Attachments are paths of files to send after the prompt. Relative
+	// paths are resolved against RequestFileOptions.BaseDir.
+	Attachments []string `json:"attachments,omitempty"`
+	// Output is "text" (the default), "json", or "image".
+	Output string          `json:"output,omitempty"`
+	Schema json.RawMessage `json:"schema,omitempty"` // JSON output schema
+	Images int             `json:"images,omitempty"` // image count
+	Model  string          `json:"model,omitempty"`
+	Tier   ModelTier       `json:"tier,omitempty"`
+	// Options are provider options, decoded by
+	// RequestFileOptions.DecodeOptions.
+	Options  json.RawMessage   `json:"options,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// RequestFileOptions configures ReadRequests.
+type RequestFileOptions struct {
+	// BaseDir resolves relative attachment paths. LoadRequests defaults it
+	// to the file's directory.
+	BaseDir string
+	// DecodeOptions turns a line's options into provider options. Lines
+	// with options fail to load without it.
+	DecodeOptions func(raw json.RawMessage) ([]ProviderOption, error)
+}
+
+// LoadRequests reads a JSONL request file (see RequestSpec).
+func LoadRequests(path string, opts RequestFileOptions) ([]Request, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, NewGrailError(InvalidArgument, fmt.Sprintf("failed to open request file: %v", err)).WithCause(err)
+	}
+	defer f.Close()
+	if opts.BaseDir == "" {
+		opts.BaseDir = filepath.Dir(path)
+	}
+	return ReadRequests(f, opts)
+}
+
+// ReadRequests reads JSONL request specs from r and builds their requests.
+// Blank lines are skipped. Attachments are read as each line is built.
+func ReadRequests(r io.Reader, opts RequestFileOptions) ([]Request, error) {
+	var reqs []Request
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 64*1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var spec RequestSpec
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&spec); err != nil {
+			return nil, NewGrailError(InvalidArgument, fmt.Sprintf("line %d: %v", n, err)).WithCause(err)
+		}
+		if spec.ID == "" {
+			spec.ID = strconv.Itoa(n)
+		}
+		req, err := spec.Request(opts)
+		if err != nil {
+			return nil, NewGrailError(InvalidArgument, fmt.Sprintf("line %d: %v", n, err)).WithCause(err)
+		}
+		reqs = append(reqs, req)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, NewGrailError(InvalidArgument, fmt.Sprintf("failed to read request file: %v", err)).WithCause(err)
+	}
+	return reqs, nil
+}
+
+// Request builds the request s describes.
+func (s RequestSpec) Request(opts RequestFileOptions) (Request, error) {
+	prompt := s.Prompt
+	if s.Vars != nil {
+		tmpl, err := template.New(s.ID).Option("missingkey=error").Parse(s.Prompt)
+		if err != nil {
+			return Request{}, fmt.Errorf("parse prompt template: %w", err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, s.Vars); err != nil {
+			return Request{}, fmt.Errorf("execute prompt template: %w", err)
+		}
+		prompt = b.String()
+	}
+
+	req := Request{
+		Model:    s.Model,
+		Tier:     s.Tier,
+		Metadata: copyMetadata(s.Metadata),
+	}
+	if s.ID != "" {
+		if req.Metadata == nil {
+			req.Metadata = map[string]string{}
+		}
+		req.Metadata["id"] = s.ID
+	}
+	if prompt != "" {
+		req.Inputs = append(req.Inputs, InputText(prompt))
+	}
+	for _, path := range s.Attachments {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(opts.BaseDir, path)
+		}
+		in, err := InputFileFromPath(path, WithFileName(filepath.Base(path)))
+		if err != nil {
+			return Request{}, err
+		}
+		req.Inputs = append(req.Inputs, in)
+	}
+
+	switch s.Output {
+	case "", "text":
+		req.Output = OutputText()
+	case "json":
+		var schema any
+		if len(s.Schema) > 0 {
+			if err := json.Unmarshal(s.Schema, &schema); err != nil {
+				return Request{}, fmt.Errorf("invalid schema: %w", err)
+			}
+		}
+		req.Output = OutputJSON(schema)
+	case "image":
+		req.Output = OutputImage(ImageSpec{Count: s.Images})
+	default:
+		return Request{}, fmt.Errorf("unknown output %q", s.Output)
+	}
+
+	if len(s.Options) > 0 {
+		if opts.DecodeOptions == nil {
+			return Request{}, fmt.Errorf("options set but no DecodeOptions configured")
+		}
+		po, err := opts.DecodeOptions(s.Options)
+		if err != nil {
+			return Request{}, fmt.Errorf("invalid options: %w", err)
+		}
+		req.ProviderOptions = po
+	}
+	return req, nil
+}
+
+// ResultRecord is one line written by ResultWriter.
+type ResultRecord struct {
+	ID        string          `json:"id,omitempty"`
+	Index     int             `json:"index"`
+	Text      string          `json:"text,omitempty"`
+	JSON      json.RawMessage `json:"json,omitempty"`
+	Images    []string        `json:"images,omitempty"` // file paths, or data URIs without an image directory
+	Provider  string          `json:"provider,omitempty"`
+	Models    []ModelUse      `json:"models,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+	Usage     *Usage          `json:"usage,omitempty"`
+	Warnings  []Warning       `json:"warnings,omitempty"`
+	Resumed   bool            `json:"resumed,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Code      ErrorCode       `json:"code,omitempty"`
+}
+
+// ResultWriter appends batch results to a JSONL file, one ResultRecord per
+// line. It's safe for concurrent use.
+type ResultWriter struct {
+	mu       sync.Mutex
+	w        io.Writer
+	imageDir string
+	keys     KeyProvider
+}
+
+// ResultWriterOption configures NewResultWriter.
+type ResultWriterOption interface{ applyResultWriterOpt(*ResultWriter) }
+
+type resultWriterOptFunc func(*ResultWriter)
+
+func (f resultWriterOptFunc) applyResultWriterOpt(rw *ResultWriter) { f(rw) }
+
+// WithResultEncryption encrypts each result with AES-GCM under keys (see
+// Seal), so prompts' answers aren't written in plaintext. A sealed line keeps
+// only its index in the clear, and is bound to it; read it with
+// DecodeResultRecord. Images are embedded in their sealed records rather than
+// saved in the image directory.
+func WithResultEncryption(keys KeyProvider) ResultWriterOption {
+	return resultWriterOptFunc(func(rw *ResultWriter) {
+		rw.keys = keys
+	})
+}
+
+// NewResultWriter writes results to w. Images are saved in imageDir, named
+// by result ID, and referenced by path; with an empty imageDir they're
+// embedded as data URIs.
+func NewResultWriter(w io.Writer, imageDir string, opts ...ResultWriterOption) *ResultWriter {
+	rw := &ResultWriter{w: w, imageDir: imageDir}
+	for _, opt := range opts {
+		opt.applyResultWriterOpt(rw)
+	}
+	return rw
+}
+
+// sealedResultRecord is the line written for an encrypted result.
+type sealedResultRecord struct {
+	Index  int    `json:"index"`
+	Sealed []byte `json:"sealed"`
+}
+
+func resultAAD(index int) []byte {
+	return []byte("grail/result\x00" + strconv.Itoa(index))
+}
+
+// DecodeResultRecord decodes a line written by ResultWriter, opening it with
+// keys if it was encrypted (see WithResultEncryption). With keys, the line
+// must be encrypted.
+func DecodeResultRecord(line []byte, keys KeyProvider) (ResultRecord, error) {
+	var sealed sealedResultRecord
+	isSealed := json.Unmarshal(line, &sealed) == nil && sealed.Sealed != nil
+	switch {
+	case isSealed && keys == nil:
+		return ResultRecord{}, NewGrailError(InvalidArgument, "result is encrypted; decode it with keys")
+	case !isSealed && keys != nil:
+		return ResultRecord{}, NewGrailError(InvalidArgument, "result is not encrypted")
+	case isSealed:
+		var err error
+		if line, err = Open(keys, sealed.Sealed, resultAAD(sealed.Index)); err != nil {
+			return ResultRecord{}, err
+		}
+	}
+	var rec ResultRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return ResultRecord{}, NewGrailError(InvalidArgument, fmt.Sprintf("decode result: %v", err)).WithCause(err)
+	}
+	if isSealed && rec.Index != sealed.Index {
+		return ResultRecord{}, NewGrailError(InvalidArgument, "result index doesn't match its sealed record")
+	}
+	return rec, nil
+}
+
+// Write appends the result of req.
+func (rw *ResultWriter) Write(req Request, r BatchResult) error {
+	rec := ResultRecord{ID: req.Metadata["id"], Index: r.Index, Resumed: r.Resumed}
+	if r.Err != nil {
+		rec.Error, rec.Code = r.Err.Error(), GetErrorCode(r.Err)
+	} else {
+		res := r.Response
+		usage := res.Usage
+		rec.Provider, rec.Models, rec.RequestID, rec.Usage, rec.Warnings = res.Provider.Name, res.Provider.Models, res.RequestID, &usage, res.Warnings
+		rec.Text, _ = res.Text()
+		for _, part := range res.Outputs {
+			if p, ok := part.(jsonOutputPart); ok {
+				rec.JSON = json.RawMessage(p.JSON)
+				break
+			}
+		}
+		for i, img := range res.ImageOutputs() {
+			ref, err := rw.saveImage(rec, i, img)
+			if err != nil {
+				return err
+			}
+			rec.Images = append(rec.Images, ref)
+		}
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return NewGrailError(Internal, fmt.Sprintf("encode result: %v", err)).WithCause(err)
+	}
+	if rw.keys != nil {
+		sealed, err := Seal(rw.keys, line, resultAAD(rec.Index))
+		if err != nil {
+			return err
+		}
+		if line, err = json.Marshal(sealedResultRecord{Index: rec.Index, Sealed: sealed}); err != nil {
+			return NewGrailError(Internal, fmt.Sprintf("encode result: %v", err)).WithCause(err)
+		}
+	}
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if _, err := rw.w.Write(append(line, '\n')); err != nil {
+		return NewGrailError(Internal, fmt.Sprintf("write result: %v", err)).WithCause(err)
+	}
+	return nil
+}
+
+func (rw *ResultWriter) saveImage(rec ResultRecord, i int, img ImageOutputInfo) (string, error) {
+	if rw.imageDir == "" || rw.keys != nil {
+		return "data:" + img.MIME + ";base64," + base64.StdEncoding.EncodeToString(img.Data), nil
+	}
+	name := rec.ID
+	if name == "" {
+		name = strconv.Itoa(rec.Index)
+	}
+	path := filepath.Join(rw.imageDir, fmt.Sprintf("%s-%02d%s", filepath.Base(name), i+1, imageExt(img.MIME)))
+	if err := img.Save(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func imageExt(mime string) string {
+	switch mime {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	default:
+		return ".png"
+	}
+}
@@ -0,0 +1,138 @@
+package grail_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/fake"
+)
+
+func TestRequestFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("quarterly numbers"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Join([]string{
+		`{"id": "sum", "prompt": "Summarize {{.title}}.", "vars": {"title": "the notes"}, "attachments": ["notes.txt"], "metadata": {"team": "ops"}}`,
+		``,
+		`{"prompt": "Extract", "output": "json", "schema": {"type": "object", "properties": {"total": {"type": "integer"}}, "required": ["total"]}}`,
+		`{"id": "art", "prompt": "A fox", "output": "image", "images": 2, "options": {"width": 8, "height": 4}}`,
+	}, "\n")
+	path := filepath.Join(dir, "requests.jsonl")
+	if err := os.WriteFile(path, []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs, err := grail.LoadRequests(path, grail.RequestFileOptions{
+		DecodeOptions: func(raw json.RawMessage) ([]grail.ProviderOption, error) {
+			var o fake.ImageOptions
+			err := json.Unmarshal(raw, &o)
+			return []grail.ProviderOption{o}, err
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(reqs) != 3 || reqs[1].Metadata["id"] != "3" || reqs[0].Metadata["team"] != "ops" {
+		t.Fatalf("unexpected requests: %+v", reqs)
+	}
+	if text, _ := grail.AsTextInput(reqs[0].Inputs[0]); text != "Summarize the notes." || len(reqs[0].Inputs) != 2 {
+		t.Fatalf("expected the rendered prompt and the attachment, got %+v", reqs[0].Inputs)
+	}
+
+	results := grail.GenerateBatch(context.Background(), grail.NewClient(fake.New()), reqs, grail.BatchOptions{})
+	var out bytes.Buffer
+	imageDir := t.TempDir()
+	w := grail.NewResultWriter(&out, imageDir)
+	for i, r := range results {
+		if err := w.Write(reqs[i], r); err != nil {
+			t.Fatalf("write result %d: %v", i, err)
+		}
+	}
+
+	var recs []grail.ResultRecord
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var rec grail.ResultRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("decode result: %v", err)
+		}
+		recs = append(recs, rec)
+	}
+	if len(recs) != 3 || recs[0].ID != "sum" || recs[0].Text == "" || recs[0].Error != "" {
+		t.Fatalf("unexpected text result: %+v", recs)
+	}
+	var v struct{ Total int }
+	if err := json.Unmarshal(recs[1].JSON, &v); err != nil || recs[1].ID != "3" {
+		t.Fatalf("unexpected JSON result %+v (%v)", recs[1], err)
+	}
+	if len(recs[2].Images) != 2 || recs[2].Images[1] != filepath.Join(imageDir, "art-02.png") {
+		t.Fatalf("unexpected image result: %+v", recs[2])
+	}
+	if _, err := os.Stat(recs[2].Images[0]); err != nil {
+		t.Fatalf("expected saved image: %v", err)
+	}
+
+	for _, bad := range []string{
+		`{"prompt": "x", "temperature": 1}`,
+		`{"prompt": "Hi {{.name}}", "vars": {}}`,
+		`{"prompt": "x", "output": "audio"}`,
+		`{"prompt": "x", "options": {"width": 8}}`,
+		`{"prompt": "x", "attachments": ["missing.pdf"]}`,
+	} {
+		_, err := grail.ReadRequests(strings.NewReader("\n"+bad), grail.RequestFileOptions{BaseDir: dir})
+		if grail.GetErrorCode(err) != grail.InvalidArgument || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("%s: expected InvalidArgument naming the line, got %v", bad, err)
+		}
+	}
+}
+
+func TestResultWriter_Encryption(t *testing.T) {
+	reqs := []grail.Request{
+		{Inputs: []grail.Input{grail.InputText("Write a secret.")}, Output: grail.OutputText(), Metadata: map[string]string{"id": "a"}},
+		{Inputs: []grail.Input{grail.InputText("Draw a secret.")}, Output: grail.OutputImage(grail.ImageSpec{}), Metadata: map[string]string{"id": "b"}},
+	}
+	results := grail.GenerateBatch(context.Background(), grail.NewClient(fake.New()), reqs, grail.BatchOptions{})
+	keys := grail.StaticKey(bytes.Repeat([]byte{4}, 32))
+	var out bytes.Buffer
+	imageDir := t.TempDir()
+	w := grail.NewResultWriter(&out, imageDir, grail.WithResultEncryption(keys))
+	for i, r := range results {
+		if err := w.Write(reqs[i], r); err != nil {
+			t.Fatalf("write result %d: %v", i, err)
+		}
+	}
+	if bytes.Contains(out.Bytes(), []byte(`"id"`)) {
+		t.Fatalf("results written in plaintext: %s", out.Bytes())
+	}
+	if files, _ := os.ReadDir(imageDir); len(files) != 0 {
+		t.Fatalf("expected images embedded in their records, got %d files", len(files))
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	for i, line := range lines {
+		rec, err := grail.DecodeResultRecord([]byte(line), keys)
+		if err != nil || rec.ID != reqs[i].Metadata["id"] {
+			t.Fatalf("decode result %d: %+v, %v", i, rec, err)
+		}
+		if i == 1 && (len(rec.Images) != 1 || !strings.HasPrefix(rec.Images[0], "data:image/")) {
+			t.Fatalf("expected an embedded image, got %+v", rec.Images)
+		}
+	}
+	if _, err := grail.DecodeResultRecord([]byte(lines[0]), nil); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Fatalf("expected decoding without keys to fail, got %v", err)
+	}
+	// A record can't be passed off as another request's result.
+	moved := strings.Replace(lines[0], `"index":0`, `"index":1`, 1)
+	if _, err := grail.DecodeResultRecord([]byte(moved), keys); err == nil {
+		t.Fatal("expected a record moved to another index to fail")
+	}
+	if _, err := grail.DecodeResultRecord([]byte(`{"index":0,"text":"forged"}`), keys); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Fatalf("expected a plaintext record to fail with keys, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package grail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//
+// JSON output for command-line tools
+//
+
+// ResponseJSON is a Response, or the error that took its place, in a form for
+// command-line tools to print so their output composes with jq and shell
+// pipelines. Write prints it as one line.
+type ResponseJSON struct {
+	Provider  string            `json:"provider,omitempty"` // the provider's name, set with errors too
+	Outputs   []OutputPartJSON  `json:"outputs,omitempty"`
+	Usage     *Usage            `json:"usage,omitempty"`
+	Cost      *Cost             `json:"cost,omitempty"`
+	Info      *ProviderInfo     `json:"provider_info,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+	Warnings  []Warning         `json:"warnings,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Code      ErrorCode         `json:"code,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+	// Request is the request that produced the response, as recorded in a
+	// ResponseRecord.
+	Request *RequestRecord `json:"request,omitempty"`
+}
+
+// OutputPartJSON is one output part. Images and videos are referenced by the
+// path they were saved at, or embedded as base64 when not saved.
+type OutputPartJSON struct {
+	Type   string          `json:"type"` // "text", "json", "image", "video", or "tool_call"
+	Text   string          `json:"text,omitempty"`
+	JSON   json.RawMessage `json:"json,omitempty"` // JSON output or tool call arguments
+	File   string          `json:"file,omitempty"`
+	Data   []byte          `json:"data,omitempty"`
+	Name   string          `json:"name,omitempty"`
+	MIME   string          `json:"mime,omitempty"`
+	Size   int             `json:"size,omitempty"`
+	Ref    string          `json:"ref,omitempty"`     // "sha256:<hex>", as AttachmentRef
+	CallID string          `json:"call_id,omitempty"` // tool call ID
+
+	Confidence map[string]float64 `json:"confidence,omitempty"` // per-field scores (see WithConfidence)
+}
+
+// NewResponseJSON converts res. Images and videos are saved in imageDir,
+// created if needed, and named by their content hash so repeated runs don't
+// pile up copies; with an empty imageDir they're embedded.
+func NewResponseJSON(res Response, imageDir string) (ResponseJSON, error) {
+	usage, info := res.Usage, res.Provider
+	out := ResponseJSON{
+		Provider:  res.Provider.Name,
+		Usage:     &usage,
+		Cost:      res.Cost,
+		Info:      &info,
+		RequestID: res.RequestID,
+		Warnings:  res.Warnings,
+		Request:   res.requestRecord(),
+	}
+	for _, part := range res.Outputs {
+		switch v := part.(type) {
+		case textOutputPart:
+			out.Outputs = append(out.Outputs, OutputPartJSON{Type: "text", Text: v.Text})
+		case jsonOutputPart:
+			out.Outputs = append(out.Outputs, OutputPartJSON{Type: "json", JSON: json.RawMessage(v.JSON), Confidence: v.Confidence})
+		case toolCallOutputPart:
+			out.Outputs = append(out.Outputs, OutputPartJSON{Type: "tool_call", Name: v.Call.Name, CallID: v.Call.ID, JSON: v.Call.Arguments})
+		case imageOutputPart:
+			o := OutputPartJSON{Type: "image", Name: v.Name, MIME: v.MIME, Size: len(v.Data), Ref: AttachmentRef(v.Data)}
+			if imageDir == "" {
+				o.Data = v.Data
+			} else {
+				if err := os.MkdirAll(imageDir, 0o755); err != nil {
+					return ResponseJSON{}, NewGrailError(Internal, fmt.Sprintf("make image dir: %v", err)).WithCause(err)
+				}
+				o.File = filepath.Join(imageDir, strings.TrimPrefix(o.Ref, "sha256:")[:16]+imageExt(v.MIME))
+				if err := (ImageOutputInfo{Data: v.Data, MIME: v.MIME}).Save(o.File); err != nil {
+					return ResponseJSON{}, err
+				}
+			}
+			out.Outputs = append(out.Outputs, o)
+		case videoOutputPart:
+			o := OutputPartJSON{Type: "video", Name: v.Name, MIME: v.MIME, Size: len(v.Data), Ref: AttachmentRef(v.Data)}
+			if imageDir == "" {
+				o.Data = v.Data
+			} else {
+				if err := os.MkdirAll(imageDir, 0o755); err != nil {
+					return ResponseJSON{}, NewGrailError(Internal, fmt.Sprintf("make image dir: %v", err)).WithCause(err)
+				}
+				o.File = filepath.Join(imageDir, strings.TrimPrefix(o.Ref, "sha256:")[:16]+videoExt(v.MIME))
+				if err := (VideoOutputInfo{Data: v.Data}).Save(o.File); err != nil {
+					return ResponseJSON{}, err
+				}
+			}
+			out.Outputs = append(out.Outputs, o)
+		}
+	}
+	return out, nil
+}
+
+// ErrorJSON describes a failed request from provider.
+func ErrorJSON(provider string, err error) ResponseJSON {
+	out := ResponseJSON{Provider: provider, Error: err.Error(), Code: GetErrorCode(err)}
+	var ge GrailError
+	if errors.As(err, &ge) {
+		out.Details = ge.Details()
+	}
+	return out
+}
+
+// Write prints r as one line of JSON.
+func (r ResponseJSON) Write(w io.Writer) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return NewGrailError(Internal, fmt.Sprintf("encode response: %v", err)).WithCause(err)
+	}
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
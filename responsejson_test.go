@@ -0,0 +1,84 @@
+package grail_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+)
+
+func TestResponseJSON(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\nstub")
+	res := grail.Response{
+		Outputs: []grail.OutputPart{
+			grail.NewTextOutputPart("a fox"),
+			grail.NewJSONOutputPart([]byte(`{"n":1}`)),
+			grail.NewImageOutputPart(png, "image/png", "fox.png"),
+		},
+		Usage:     grail.Usage{InputTokens: 3, TotalTokens: 3},
+		Provider:  grail.ProviderInfo{Name: "mock"},
+		RequestID: "req-1",
+	}
+
+	dir := t.TempDir()
+	out, err := grail.NewResponseJSON(res, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := out.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(buf.String(), "\n") != 1 {
+		t.Fatalf("want one line, got %q", buf.String())
+	}
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["provider"] != "mock" || got["request_id"] != "req-1" {
+		t.Fatalf("unexpected response %v", got)
+	}
+	outputs := got["outputs"].([]any)
+	if len(outputs) != 3 || outputs[0].(map[string]any)["text"] != "a fox" {
+		t.Fatalf("unexpected outputs %v", outputs)
+	}
+	if n := outputs[1].(map[string]any)["json"].(map[string]any)["n"]; n != 1.0 {
+		t.Fatalf("json output not embedded as JSON: %v", outputs[1])
+	}
+	img := outputs[2].(map[string]any)
+	if img["ref"] != grail.AttachmentRef(png) || img["data"] != nil {
+		t.Fatalf("unexpected image output %v", img)
+	}
+	saved, err := os.ReadFile(img["file"].(string))
+	if err != nil || !bytes.Equal(saved, png) {
+		t.Fatalf("image not saved at %v: %v", img["file"], err)
+	}
+
+	// Without a directory, images are embedded.
+	out, err = grail.NewResponseJSON(res, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := out.Outputs[2]; o.File != "" || !bytes.Equal(o.Data, png) {
+		t.Fatalf("image not embedded: %+v", o)
+	}
+}
+
+func TestErrorJSON(t *testing.T) {
+	err := grail.NewGrailError(grail.RateLimited, "slow down").WithProviderName("mock")
+	var buf bytes.Buffer
+	if werr := grail.ErrorJSON("mock", err).Write(&buf); werr != nil {
+		t.Fatal(werr)
+	}
+	var got grail.ResponseJSON
+	if jerr := json.Unmarshal(buf.Bytes(), &got); jerr != nil {
+		t.Fatal(jerr)
+	}
+	if got.Provider != "mock" || got.Code != grail.RateLimited || got.Error == "" || got.Usage != nil || got.Outputs != nil {
+		t.Fatalf("unexpected error JSON %+v", got)
+	}
+}
@@ -0,0 +1,272 @@
+package grail_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/fake"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestReviewGate(t *testing.T) {
+	prov, _ := scripted(`{"data": {"number": "INV-7", "total": 120.5}, "confidence": {"number": 0.95, "total": 0.4}}`)
+	var notified []grail.ReviewItem
+	gate := &grail.ReviewGate{
+		Store:    &grail.MemoryReviewStore{},
+		Criteria: []grail.ReviewCriterion{grail.ReviewLowConfidence(0.5)},
+		Notify: func(ctx context.Context, item grail.ReviewItem) error {
+			notified = append(notified, item)
+			return nil
+		},
+	}
+	client := grail.NewClient(prov, grail.WithReviewGate(gate))
+	ctx := context.Background()
+	req := grail.Request{
+		Inputs: []grail.Input{grail.InputText("Extract the invoice.")},
+		Output: grail.OutputJSON(invoiceSchema, grail.WithConfidence()),
+	}
+
+	res, err := client.Generate(ctx, req)
+	id, ok := grail.ReviewID(err)
+	if !ok || grail.GetErrorCode(err) != grail.PendingReview {
+		t.Fatalf("expected the response to be held, got %v", err)
+	}
+	if len(res.Outputs) != 0 || res.Usage.InputTokens != 5 {
+		t.Errorf("expected usage without outputs, got %+v", res)
+	}
+	if len(notified) != 1 || notified[0].ID != id || notified[0].Reasons[0] != "low confidence: total" {
+		t.Fatalf("unexpected notifications %+v", notified)
+	}
+	if pending, _ := gate.Store.Pending(ctx); len(pending) != 1 {
+		t.Fatalf("expected one pending item, got %d", len(pending))
+	}
+
+	done := make(chan error)
+	go func() {
+		res, err := gate.Await(ctx, id)
+		if err == nil && res.LowConfidence(0.5)[0] != "total" {
+			t.Errorf("expected the held response, got %+v", res)
+		}
+		done <- err
+	}()
+	if _, err := gate.ApproveResponse(ctx, id, "checked the total"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Await didn't return after approval")
+	}
+	if _, err := gate.ApproveResponse(ctx, id, ""); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected deciding twice to fail, got %v", err)
+	}
+
+	_, err = client.Generate(ctx, req)
+	id, _ = grail.ReviewID(err)
+	if err := gate.RejectResponse(ctx, id, "wrong total"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := gate.Await(ctx, id); !grail.IsRefused(err) {
+		t.Errorf("expected a rejected response to be refused, got %v", err)
+	}
+	if _, err := gate.Await(ctx, "missing"); !grail.IsNotFound(err) {
+		t.Errorf("expected NotFound for an unknown ID, got %v", err)
+	}
+}
+
+func TestReviewGate_ConcurrentDecisions(t *testing.T) {
+	gate := &grail.ReviewGate{Store: &grail.MemoryReviewStore{}}
+	ctx := context.Background()
+	if err := gate.Store.Put(ctx, grail.ReviewItem{ID: "contested", Status: grail.ReviewPending}); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		wg                 sync.WaitGroup
+		approved, rejected atomic.Int32
+	)
+	for i := range 20 {
+		wg.Go(func() {
+			var err error
+			if i%2 == 0 {
+				if _, err = gate.ApproveResponse(ctx, "contested", ""); err == nil {
+					approved.Add(1)
+				}
+			} else if err = gate.RejectResponse(ctx, "contested", ""); err == nil {
+				rejected.Add(1)
+			}
+			if err != nil && grail.GetErrorCode(err) != grail.InvalidArgument {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+	item, _ := gate.Store.Get(ctx, "contested")
+	if approved.Load()+rejected.Load() != 1 || (approved.Load() == 1) != (item.Status == grail.ReviewApproved) {
+		t.Fatalf("expected exactly one decision to win and be stored, got %d approved, %d rejected, stored %s",
+			approved.Load(), rejected.Load(), item.Status)
+	}
+}
+
+func TestReviewGateAwaitTimeout(t *testing.T) {
+	gate := &grail.ReviewGate{Store: &grail.MemoryReviewStore{}, PollInterval: time.Millisecond}
+	ctx := context.Background()
+	if err := gate.Store.Put(ctx, grail.ReviewItem{ID: "slow", Status: grail.ReviewPending}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Polling many times, then giving up, leaves nothing registered.
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := gate.Await(waitCtx, "slow"); grail.GetErrorCode(err) != grail.Timeout {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if n := grail.ReviewWaiters(gate); n != 0 {
+		t.Errorf("expected no waiters after Await returned, got %d", n)
+	}
+}
+
+func TestReviewGateNotifyErrorWithoutLogger(t *testing.T) {
+	prov, _ := scripted(`{"data": {"number": "INV-7", "total": 120.5}, "confidence": {"number": 0.95, "total": 0.4}}`)
+	gate := &grail.ReviewGate{
+		Store:    &grail.MemoryReviewStore{},
+		Criteria: []grail.ReviewCriterion{grail.ReviewLowConfidence(0.5)},
+		Notify: func(ctx context.Context, item grail.ReviewItem) error {
+			return errors.New("queue down")
+		},
+	}
+	client := grail.NewClient(prov, grail.WithReviewGate(gate), grail.WithLogger(nil))
+	_, err := client.Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("Extract the invoice.")},
+		Output: grail.OutputJSON(invoiceSchema, grail.WithConfidence()),
+	})
+	if grail.GetErrorCode(err) != grail.PendingReview {
+		t.Fatalf("expected the response to be held, got %v", err)
+	}
+}
+
+func TestReviewGateCriteria(t *testing.T) {
+	refusing := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{}, grail.NewGrailError(grail.Refused, "can't help with that")
+		},
+	}
+	gate := &grail.ReviewGate{
+		Store:    &grail.MemoryReviewStore{},
+		Criteria: []grail.ReviewCriterion{grail.ReviewRefusals()},
+	}
+	ctx := context.Background()
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()}
+
+	_, err := grail.NewClient(refusing, grail.WithReviewGate(gate)).Generate(ctx, req)
+	id, ok := grail.ReviewID(err)
+	if !ok {
+		t.Fatalf("expected the refusal to be held, got %v", err)
+	}
+	// Approving a refusal confirms it.
+	if _, err := gate.ApproveResponse(ctx, id, ""); !grail.IsRefused(err) {
+		t.Errorf("expected the refusal back, got %v", err)
+	}
+
+	// Responses no criterion matches pass straight through.
+	prov, _ := scripted("hello")
+	if res, err := grail.NewClient(prov, grail.WithReviewGate(gate)).Generate(ctx, req); err != nil || res.Texts()[0] != "hello" {
+		t.Fatalf("expected the response, got %v (%v)", res.Texts(), err)
+	}
+
+	criterion := grail.ReviewWarnings(grail.WarningImageFlagged)
+	res := grail.Response{Warnings: []grail.Warning{{Code: grail.WarningImageFlagged}, {Code: grail.WarningImageFlagged}}}
+	if reason, hold := criterion(req, res, nil); !hold || reason != "flagged: image_flagged" {
+		t.Errorf("unexpected verdict %q %v", reason, hold)
+	}
+}
+
+func TestReviewWebhook(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	notify := grail.ReviewWebhook(srv.URL, srv.Client())
+	item := grail.ReviewItem{
+		ID:       "abc",
+		Reasons:  []string{"refused"},
+		Request:  grail.Request{Metadata: map[string]string{"user": "42"}},
+		Response: grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("hi")}},
+	}
+	if err := notify(context.Background(), item); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got["id"] != "abc" || got["metadata"].(map[string]any)["user"] != "42" || got["response"] == nil {
+		t.Errorf("unexpected payload %v", got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	if err := grail.ReviewWebhook(failing.URL, failing.Client())(context.Background(), item); err == nil {
+		t.Error("expected an error for a failed delivery")
+	}
+	// Called other than by a client, it has no HTTP client to default to.
+	if err := grail.ReviewWebhook(srv.URL, nil)(context.Background(), item); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected a webhook without an HTTP client to fail, got %v", err)
+	}
+}
+
+func TestReviewWebhook_ClientHTTP(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+	hold := func(req grail.Request, res grail.Response, err error) (string, bool) { return "always", true }
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()}
+	generate := func(opts ...grail.ClientOption) error {
+		t.Helper()
+		gate := &grail.ReviewGate{
+			Store:    &grail.MemoryReviewStore{},
+			Criteria: []grail.ReviewCriterion{hold},
+			Notify:   grail.ReviewWebhook(srv.URL, nil),
+		}
+		_, err := grail.NewClient(fake.New(), append(opts, grail.WithReviewGate(gate))...).Generate(context.Background(), req)
+		if grail.GetErrorCode(err) != grail.PendingReview {
+			t.Fatalf("expected the response to be held, got %v", err)
+		}
+		var ge grail.GrailError
+		errors.As(err, &ge)
+		if msg := ge.Details()["notify_error"]; msg != "" {
+			return errors.New(msg)
+		}
+		return nil
+	}
+
+	// The webhook uses the holding client's HTTP client...
+	var sent atomic.Int32
+	hc := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		sent.Add(1)
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	if err := generate(grail.WithHTTPClient(hc)); err != nil || sent.Load() != 1 || calls.Load() != 1 {
+		t.Fatalf("expected the client's HTTP client to deliver, got %v (%d sent, %d received)", err, sent.Load(), calls.Load())
+	}
+
+	// ...held to its air-gap allowlist.
+	if err := generate(grail.WithAirGap(grail.AirGap{AllowedHosts: []string{"api.example.com"}})); err == nil || calls.Load() != 1 {
+		t.Fatalf("expected an air-gapped client to block the webhook, got %v (%d received)", err, calls.Load())
+	}
+	if err := generate(grail.WithAirGap(grail.AirGap{AllowedHosts: []string{srv.Listener.Addr().String()}})); err != nil || calls.Load() != 2 {
+		t.Fatalf("expected an allowed webhook host to be notified, got %v (%d received)", err, calls.Load())
+	}
+}
@@ -0,0 +1,444 @@
+package grail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//
+// Human review
+//
+
+// ReviewStatus is where a held response is in review.
+type ReviewStatus string
+
+const (
+	ReviewPending  ReviewStatus = "pending"
+	ReviewApproved ReviewStatus = "approved"
+	ReviewRejected ReviewStatus = "rejected"
+)
+
+// ReviewItem is a response held for a reviewer, along with the request that
+// produced it.
+type ReviewItem struct {
+	ID       string
+	Reasons  []string // why the response was held, one per matching criterion
+	Request  Request
+	Response Response
+	Err      error // the error Generate returned, such as a refusal
+	Status   ReviewStatus
+	Note     string // the reviewer's note
+	Created  time.Time
+	Decided  time.Time
+}
+
+// ReviewStore holds responses awaiting review. MemoryReviewStore keeps them
+// in memory; share a persistent store between processes to review from one
+// and serve from another. Implementations must be safe for concurrent use.
+type ReviewStore interface {
+	// Put saves an item, replacing any with the same ID.
+	Put(ctx context.Context, item ReviewItem) error
+	// Get returns the item with the given ID, failing with NotFound if
+	// there is none.
+	Get(ctx context.Context, id string) (ReviewItem, error)
+	// Decide records a decision on a pending item and returns the decided
+	// item. The status check and the update are atomic: if the item was
+	// already decided, Decide fails with InvalidArgument and changes
+	// nothing, so two reviewers can't both decide it.
+	Decide(ctx context.Context, id string, status ReviewStatus, note string, decided time.Time) (ReviewItem, error)
+	// Pending returns the items awaiting a decision, oldest first.
+	Pending(ctx context.Context) ([]ReviewItem, error)
+}
+
+// MemoryReviewStore is a ReviewStore held in memory. The zero value is ready
+// to use.
+type MemoryReviewStore struct {
+	mu    sync.Mutex
+	items map[string]ReviewItem
+}
+
+// Put implements ReviewStore.
+func (s *MemoryReviewStore) Put(ctx context.Context, item ReviewItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.items == nil {
+		s.items = map[string]ReviewItem{}
+	}
+	s.items[item.ID] = item
+	return nil
+}
+
+// Get implements ReviewStore.
+func (s *MemoryReviewStore) Get(ctx context.Context, id string) (ReviewItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	if !ok {
+		return ReviewItem{}, NewGrailError(NotFound, fmt.Sprintf("review %q not found", id))
+	}
+	return item, nil
+}
+
+// Decide implements ReviewStore.
+func (s *MemoryReviewStore) Decide(ctx context.Context, id string, status ReviewStatus, note string, decided time.Time) (ReviewItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	if !ok {
+		return ReviewItem{}, NewGrailError(NotFound, fmt.Sprintf("review %q not found", id))
+	}
+	if item.Status != ReviewPending {
+		return ReviewItem{}, NewGrailError(InvalidArgument, fmt.Sprintf("review %q is already %s", id, item.Status))
+	}
+	item.Status, item.Note, item.Decided = status, note, decided
+	s.items[id] = item
+	return item, nil
+}
+
+// Pending implements ReviewStore.
+func (s *MemoryReviewStore) Pending(ctx context.Context) ([]ReviewItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var items []ReviewItem
+	for _, item := range s.items {
+		if item.Status == ReviewPending {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Created.Before(items[j].Created) })
+	return items, nil
+}
+
+// ReviewCriterion decides whether a response needs a reviewer. It's given
+// the request, the response and the error Generate would return, and
+// reports why the response should be held.
+type ReviewCriterion func(req Request, res Response, err error) (reason string, hold bool)
+
+// ReviewLowConfidence holds JSON outputs with a field scored below threshold
+// (see WithConfidence).
+func ReviewLowConfidence(threshold float64) ReviewCriterion {
+	return func(req Request, res Response, err error) (string, bool) {
+		if err != nil {
+			return "", false
+		}
+		low := res.LowConfidence(threshold)
+		if len(low) == 0 {
+			return "", false
+		}
+		return fmt.Sprintf("low confidence: %s", strings.Join(low, ", ")), true
+	}
+}
+
+// ReviewRefusals holds requests the model or a safety check refused, so a
+// reviewer can look at what was asked.
+func ReviewRefusals() ReviewCriterion {
+	return func(req Request, res Response, err error) (string, bool) {
+		if !IsRefused(err) {
+			return "", false
+		}
+		return "refused", true
+	}
+}
+
+// ReviewWarnings holds responses carrying any of the given warning codes,
+// such as policy flags from WithImageSafety (WarningImageFlagged) or
+// WithLexicon (WarningLexiconMatched).
+func ReviewWarnings(codes ...string) ReviewCriterion {
+	return func(req Request, res Response, err error) (string, bool) {
+		if err != nil {
+			return "", false
+		}
+		var flagged []string
+		for _, w := range res.Warnings {
+			if slices.Contains(codes, w.Code) && !slices.Contains(flagged, w.Code) {
+				flagged = append(flagged, w.Code)
+			}
+		}
+		if len(flagged) == 0 {
+			return "", false
+		}
+		return fmt.Sprintf("flagged: %s", strings.Join(flagged, ", ")), true
+	}
+}
+
+// ReviewGate holds responses matching any of its criteria for a person to
+// approve or reject before they're used. Install it with WithReviewGate.
+type ReviewGate struct {
+	// Store keeps the held responses. It's required.
+	Store ReviewStore
+	// Criteria decide which responses are held.
+	Criteria []ReviewCriterion
+	// Notify, if set, is called with each newly held item, for example to
+	// alert a review queue with ReviewWebhook.
+	Notify func(ctx context.Context, item ReviewItem) error
+	// PollInterval is how often Await checks the store for decisions made by
+	// other processes. It defaults to a second.
+	PollInterval time.Duration
+
+	mu      sync.Mutex
+	waiters map[string][]chan struct{}
+}
+
+// WithReviewGate runs g's criteria on every response and error before
+// Generate returns. A held response is saved to g.Store, g.Notify is
+// called, and Generate fails with PendingReview; the review ID is in the
+// error's "review_id" detail (see ReviewID). The caller can then Await the
+// decision or pick it up later.
+//
+// The gate sees the finished response, after post-processing and confidence
+// scoring, unlike Middleware.
+func WithReviewGate(g *ReviewGate) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.reviewGate = g
+	})
+}
+
+// ReviewID returns the review ID of a PendingReview error.
+func ReviewID(err error) (string, bool) {
+	var ge GrailError
+	if !errors.As(err, &ge) || ge.Code() != PendingReview {
+		return "", false
+	}
+	id, ok := ge.Details()["review_id"]
+	return id, ok
+}
+
+// review holds res for review if any of the gate's criteria match.
+func (c *client) review(ctx context.Context, req Request, res Response, err error) (Response, error) {
+	g := c.opts.reviewGate
+	if g == nil || GetErrorCode(err) == PendingReview {
+		return res, err
+	}
+	var reasons []string
+	for _, crit := range g.Criteria {
+		if reason, hold := crit(req, res, err); hold {
+			reasons = append(reasons, reason)
+		}
+	}
+	if len(reasons) == 0 {
+		return res, err
+	}
+	if g.Store == nil {
+		return Response{}, NewGrailError(InvalidArgument, "review gate has no store")
+	}
+
+	id, idErr := newReviewID()
+	if idErr != nil {
+		return Response{}, NewGrailError(Internal, fmt.Sprintf("review ID: %v", idErr)).WithCause(idErr)
+	}
+	item := ReviewItem{
+		ID:       id,
+		Reasons:  reasons,
+		Request:  req,
+		Response: res,
+		Err:      err,
+		Status:   ReviewPending,
+		Created:  time.Now(),
+	}
+	// Fail closed: a response that can't be held isn't returned either.
+	if putErr := g.Store.Put(ctx, item); putErr != nil {
+		return Response{}, NewGrailError(Internal, fmt.Sprintf("hold response for review: %v", putErr)).WithCause(putErr)
+	}
+
+	pending := NewGrailError(PendingReview, fmt.Sprintf("response held for review (%s)", strings.Join(reasons, "; "))).
+		WithDetail("review_id", id).
+		WithRequestID(res.RequestID)
+	if g.Notify != nil {
+		if notifyErr := g.Notify(context.WithValue(ctx, reviewClientKey{}, c), item); notifyErr != nil {
+			if c.log != nil {
+				c.log.Warn("review notification failed", slog.String("review_id", id), slog.String("error", notifyErr.Error()))
+			}
+			pending = pending.WithDetail("notify_error", notifyErr.Error())
+		}
+	}
+	// Usage is kept so stats and accounting see what the request cost.
+	return Response{Provider: res.Provider, Usage: res.Usage, RequestID: res.RequestID}, pending
+}
+
+func newReviewID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ApproveResponse releases a held response. It returns the response and
+// error as they were generated, so approving a held refusal confirms it.
+func (g *ReviewGate) ApproveResponse(ctx context.Context, id, note string) (Response, error) {
+	item, err := g.decide(ctx, id, ReviewApproved, note)
+	if err != nil {
+		return Response{}, err
+	}
+	return item.Response, item.Err
+}
+
+// RejectResponse discards a held response. Waiting callers get a Refused
+// error carrying the note.
+func (g *ReviewGate) RejectResponse(ctx context.Context, id, note string) error {
+	_, err := g.decide(ctx, id, ReviewRejected, note)
+	return err
+}
+
+func (g *ReviewGate) decide(ctx context.Context, id string, status ReviewStatus, note string) (ReviewItem, error) {
+	item, err := g.Store.Decide(ctx, id, status, note, time.Now())
+	if err != nil {
+		return ReviewItem{}, err
+	}
+
+	g.mu.Lock()
+	for _, ch := range g.waiters[id] {
+		close(ch)
+	}
+	delete(g.waiters, id)
+	g.mu.Unlock()
+	return item, nil
+}
+
+// Await blocks until the held response with the given ID is decided or ctx
+// is done. An approved item returns its response and error as generated; a
+// rejected one fails with Refused.
+func (g *ReviewGate) Await(ctx context.Context, id string) (Response, error) {
+	interval := g.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	// Register before reading the store so a decision made in between still
+	// wakes us.
+	wake, stop := g.wait(id)
+	defer stop()
+	for {
+		item, err := g.Store.Get(ctx, id)
+		if err != nil {
+			return Response{}, err
+		}
+		switch item.Status {
+		case ReviewApproved:
+			return item.Response, item.Err
+		case ReviewRejected:
+			msg := "rejected by reviewer"
+			if item.Note != "" {
+				msg += ": " + item.Note
+			}
+			return Response{}, NewGrailError(Refused, msg).WithDetail("review_id", id)
+		}
+		select {
+		case <-ctx.Done():
+			return Response{}, NewGrailError(Timeout, "waiting for review").WithCause(ctx.Err()).WithDetail("review_id", id)
+		case <-wake:
+			wake = nil // closed; the store has the decision
+		case <-ticker.C:
+		}
+	}
+}
+
+// wait returns a channel closed when id is decided through g, and a func
+// that unregisters it.
+func (g *ReviewGate) wait(id string) (<-chan struct{}, func()) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.waiters == nil {
+		g.waiters = map[string][]chan struct{}{}
+	}
+	ch := make(chan struct{})
+	g.waiters[id] = append(g.waiters[id], ch)
+	return ch, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		waiters := slices.DeleteFunc(g.waiters[id], func(w chan struct{}) bool { return w == ch })
+		if len(waiters) == 0 {
+			delete(g.waiters, id)
+			return
+		}
+		g.waiters[id] = waiters
+	}
+}
+
+// reviewNotification is the body ReviewWebhook posts.
+type reviewNotification struct {
+	ID       string            `json:"id"`
+	Reasons  []string          `json:"reasons"`
+	Created  time.Time         `json:"created"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Response ResponseJSON      `json:"response"`
+}
+
+// reviewClientKey is the context key under which Notify finds the client
+// that held the item.
+type reviewClientKey struct{}
+
+// notifyHTTPClient returns the HTTP client notifications are sent with: the
+// client's own, held to its air-gap allowlist.
+func (c *client) notifyHTTPClient() *http.Client {
+	cfg := c.opts.airGap
+	if cfg == nil {
+		return c.httpClient
+	}
+	hc := *c.httpClient
+	hc.Transport = &egressGuard{base: hc.Transport, allowed: cfg.AllowedHosts, log: func() *slog.Logger { return c.log }}
+	return &hc
+}
+
+// ReviewWebhook returns a ReviewGate.Notify that POSTs each held item to url
+// as JSON: its ID, reasons, creation time, request metadata, and the
+// response (or error) as ResponseJSON with images embedded. A non-2xx status
+// is an error.
+//
+// With a nil hc, notifications are sent with the HTTP client of the client
+// that held the item (see WithHTTPClient), under its TLS policy and, in
+// air-gap mode, its allowed hosts. Called other than by a client, it needs
+// hc.
+func ReviewWebhook(url string, hc *http.Client) func(ctx context.Context, item ReviewItem) error {
+	return func(ctx context.Context, item ReviewItem) error {
+		hc := hc
+		if hc == nil {
+			c, ok := ctx.Value(reviewClientKey{}).(*client)
+			if !ok {
+				return NewGrailError(InvalidArgument, "review webhook: no HTTP client")
+			}
+			hc = c.notifyHTTPClient()
+		}
+		note := reviewNotification{ID: item.ID, Reasons: item.Reasons, Created: item.Created, Metadata: item.Request.Metadata}
+		if item.Err != nil {
+			note.Response = ErrorJSON(item.Response.Provider.Name, item.Err)
+		} else {
+			out, err := NewResponseJSON(item.Response, "")
+			if err != nil {
+				return err
+			}
+			note.Response = out
+		}
+		body, err := json.Marshal(note)
+		if err != nil {
+			return err
+		}
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		resp, err := hc.Do(httpReq)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("review webhook: %s", resp.Status)
+		}
+		return nil
+	}
+}
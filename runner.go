@@ -0,0 +1,223 @@
+package grail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//
+// Tool execution loop
+//
+
+// DefaultMaxToolIterations is the number of model calls Runner makes per Run
+// when its MaxIterations is zero.
+const DefaultMaxToolIterations = 10
+
+// ToolFunc runs a tool call with the model's arguments and returns its result
+// for the model, usually JSON. A returned error is reported to the model, not
+// to the caller of Run, so the model can correct its arguments or carry on
+// without the tool.
+type ToolFunc func(ctx context.Context, args json.RawMessage) (string, error)
+
+// ToolFuncOf adapts a typed function to ToolFunc: arguments are decoded into
+// A, and the result is sent as is if it's a string and as JSON otherwise.
+func ToolFuncOf[A, R any](fn func(ctx context.Context, args A) (R, error)) ToolFunc {
+	return func(ctx context.Context, raw json.RawMessage) (string, error) {
+		var args A
+		if err := (ToolCall{Arguments: raw}).DecodeArguments(&args); err != nil {
+			return "", err
+		}
+		res, err := fn(ctx, args)
+		if err != nil {
+			return "", err
+		}
+		if s, ok := any(res).(string); ok {
+			return s, nil
+		}
+		data, err := json.Marshal(res)
+		if err != nil {
+			return "", fmt.Errorf("encode result: %w", err)
+		}
+		return string(data), nil
+	}
+}
+
+// ToolStep is one tool call made during a Run.
+type ToolStep struct {
+	Iteration int // the model call that requested it, from 1
+	Call      ToolCall
+	Output    string // what the model was sent
+	Err       error  // the tool's error, if it failed
+	Duration  time.Duration
+}
+
+// RunResult is the outcome of Runner.Run.
+type RunResult struct {
+	Response   Response   // the final response
+	Steps      []ToolStep // every tool call, in order
+	Iterations int        // the number of model calls made
+	Usage      Usage      // summed across every model call
+	// Inputs is the conversation: the request's inputs followed by every
+	// tool result, ready to extend for a follow-up request.
+	Inputs []Input
+}
+
+// Runner lets a model use Go functions: it offers the registered tools with
+// each request, runs the calls the model makes, sends the results back, and
+// repeats until the model answers without calling a tool:
+//
+//	runner := grail.NewRunner(client)
+//	runner.Register(weatherTool, grail.ToolFuncOf(getWeather))
+//	result, err := runner.Run(ctx, grail.Request{
+//		Inputs: []grail.Input{grail.InputText("Should I bring an umbrella to Paris?")},
+//		Output: grail.OutputText(),
+//	})
+//
+// Calls the model makes together run concurrently. A Runner is safe for
+// concurrent use.
+type Runner struct {
+	Client Client
+	// MaxIterations bounds the model calls per Run (default
+	// DefaultMaxToolIterations). A model still calling tools after the last
+	// one fails the Run with OutputInvalid.
+	MaxIterations int
+
+	mu    sync.RWMutex
+	tools []Tool
+	funcs map[string]ToolFunc
+}
+
+// NewRunner returns a Runner for c without tools.
+func NewRunner(c Client) *Runner {
+	return &Runner{Client: c}
+}
+
+// Register offers tool to the model and runs fn for its calls, replacing any
+// tool registered with the same name.
+func (r *Runner) Register(tool Tool, fn ToolFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.funcs == nil {
+		r.funcs = map[string]ToolFunc{}
+	}
+	if _, ok := r.funcs[tool.Name]; ok {
+		for i, t := range r.tools {
+			if t.Name == tool.Name {
+				r.tools = append(r.tools[:i:i], r.tools[i+1:]...)
+				break
+			}
+		}
+	}
+	r.tools = append(r.tools, tool)
+	r.funcs[tool.Name] = fn
+}
+
+// Tools returns the registered tools.
+func (r *Runner) Tools() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Tool(nil), r.tools...)
+}
+
+// Run sends req with the registered tools added to its own and loops until
+// the model gives a final answer. Tools in req.Tools without a registered
+// function can still be called; the model is told they aren't available.
+// On error the result holds the steps taken so far.
+func (r *Runner) Run(ctx context.Context, req Request) (RunResult, error) {
+	r.mu.RLock()
+	funcs := make(map[string]ToolFunc, len(r.funcs))
+	for name, fn := range r.funcs {
+		funcs[name] = fn
+	}
+	tools := append([]Tool(nil), req.Tools...)
+	for _, t := range r.tools {
+		if !hasTool(tools, t.Name) {
+			tools = append(tools, t)
+		}
+	}
+	r.mu.RUnlock()
+
+	limit := r.MaxIterations
+	if limit <= 0 {
+		limit = DefaultMaxToolIterations
+	}
+	req.Tools = tools
+	req.Inputs = append([]Input(nil), req.Inputs...)
+
+	var result RunResult
+	for result.Iterations < limit {
+		result.Iterations++
+		res, err := r.Client.Generate(ctx, req)
+		result.Usage = result.Usage.Add(res.Usage)
+		result.Inputs = req.Inputs
+		if err != nil {
+			return result, err
+		}
+		result.Response = res
+		calls := res.ToolCalls()
+		if len(calls) == 0 {
+			return result, nil
+		}
+		steps := runToolCalls(ctx, funcs, calls, result.Iterations)
+		for _, step := range steps {
+			req.Inputs = append(req.Inputs, InputToolResult(step.Call, step.Output))
+		}
+		result.Steps = append(result.Steps, steps...)
+		result.Inputs = req.Inputs
+		if err := ctx.Err(); err != nil {
+			return result, NewGrailError(Timeout, "tool run canceled").WithCause(err)
+		}
+	}
+	return result, NewGrailError(OutputInvalid, fmt.Sprintf("model still calling tools after %d iterations", limit)).
+		WithDetail("iterations", strconv.Itoa(limit))
+}
+
+func hasTool(tools []Tool, name string) bool {
+	for _, t := range tools {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// runToolCalls runs calls concurrently and returns their steps in call order.
+func runToolCalls(ctx context.Context, funcs map[string]ToolFunc, calls []ToolCall, iteration int) []ToolStep {
+	steps := make([]ToolStep, len(calls))
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		steps[i] = ToolStep{Iteration: iteration, Call: call}
+		fn, ok := funcs[call.Name]
+		if !ok {
+			steps[i].Err = NewGrailError(NotFound, fmt.Sprintf("tool %s is not available", call.Name))
+			steps[i].Output = "error: " + steps[i].Err.Error()
+			continue
+		}
+		wg.Go(func() {
+			start := time.Now()
+			out, err := callTool(ctx, fn, call.Arguments)
+			steps[i].Duration = time.Since(start)
+			steps[i].Output, steps[i].Err = out, err
+			if err != nil {
+				steps[i].Output = "error: " + err.Error()
+			}
+		})
+	}
+	wg.Wait()
+	return steps
+}
+
+// callTool runs fn, turning a panic into an error so one broken tool doesn't
+// take down the run.
+func callTool(ctx context.Context, fn ToolFunc, args json.RawMessage) (out string, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("tool panicked: %v", p)
+		}
+	}()
+	return fn(ctx, args)
+}
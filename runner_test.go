@@ -0,0 +1,103 @@
+package grail_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestRunner(t *testing.T) {
+	var requests []grail.Request
+	prov := toolProvider{&mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			requests = append(requests, req)
+			usage := grail.Usage{InputTokens: 10, OutputTokens: 1}
+			if len(requests) == 1 {
+				return grail.Response{Usage: usage, Outputs: []grail.OutputPart{
+					grail.NewToolCallOutputPart(grail.ToolCall{ID: "1", Name: "get_weather", Arguments: []byte(`{"city":"Paris"}`)}),
+					grail.NewToolCallOutputPart(grail.ToolCall{ID: "2", Name: "get_weather", Arguments: []byte(`{"city":"Oslo"}`)}),
+					grail.NewToolCallOutputPart(grail.ToolCall{ID: "3", Name: "book_flight", Arguments: []byte(`{}`)}),
+				}}, nil
+			}
+			return grail.Response{Usage: usage, Outputs: []grail.OutputPart{grail.NewTextOutputPart("Bring an umbrella to Oslo.")}}, nil
+		},
+	}}
+	runner := grail.NewRunner(grail.NewClient(prov))
+	runner.Register(weatherTool, grail.ToolFuncOf(func(ctx context.Context, args struct{ City string }) (map[string]any, error) {
+		if args.City == "Oslo" {
+			return nil, errors.New("station offline")
+		}
+		return map[string]any{"rain": false}, nil
+	}))
+
+	result, err := runner.Run(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("Where should I bring an umbrella?")},
+		Output: grail.OutputText(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text, _ := result.Response.Text(); text != "Bring an umbrella to Oslo." || result.Iterations != 2 {
+		t.Fatalf("unexpected result %q after %d iterations", text, result.Iterations)
+	}
+	if len(requests[0].Tools) != 1 || requests[0].Tools[0].Name != "get_weather" {
+		t.Errorf("expected the registered tool to be offered, got %+v", requests[0].Tools)
+	}
+	if result.Usage.InputTokens != 20 {
+		t.Errorf("expected usage summed across calls, got %+v", result.Usage)
+	}
+
+	if len(result.Steps) != 3 {
+		t.Fatalf("expected 3 steps, got %d", len(result.Steps))
+	}
+	if s := result.Steps[0]; s.Output != `{"rain":false}` || s.Err != nil {
+		t.Errorf("unexpected first step %+v", s)
+	}
+	if s := result.Steps[1]; s.Err == nil || !strings.Contains(s.Output, "station offline") {
+		t.Errorf("expected the tool error to be sent to the model, got %+v", s)
+	}
+	if s := result.Steps[2]; grail.GetErrorCode(s.Err) != grail.NotFound {
+		t.Errorf("expected an unknown tool to be reported, got %+v", s)
+	}
+
+	sent := requests[1].Inputs
+	if len(sent) != 4 || len(result.Inputs) != 4 {
+		t.Fatalf("expected the prompt and three tool results, got %d inputs (%d in result)", len(sent), len(result.Inputs))
+	}
+	for i, id := range []string{"1", "2", "3"} {
+		if call, _, ok := grail.AsToolResultInput(sent[i+1]); !ok || call.ID != id {
+			t.Errorf("input %d: expected the result of call %s, got %+v", i+1, id, call)
+		}
+	}
+}
+
+func TestRunner_MaxIterations(t *testing.T) {
+	calls := 0
+	prov := toolProvider{&mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			calls++
+			return grail.Response{Outputs: []grail.OutputPart{
+				grail.NewToolCallOutputPart(grail.ToolCall{ID: "1", Name: "get_weather"}),
+			}}, nil
+		},
+	}}
+	runner := &grail.Runner{Client: grail.NewClient(prov), MaxIterations: 3}
+	runner.Register(weatherTool, func(ctx context.Context, args json.RawMessage) (string, error) {
+		panic("boom")
+	})
+	result, err := runner.Run(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("loop")},
+		Output: grail.OutputText(),
+	})
+	if grail.GetErrorCode(err) != grail.OutputInvalid || calls != 3 || len(result.Steps) != 3 {
+		t.Fatalf("expected OutputInvalid after 3 calls, got %v after %d calls, %d steps", err, calls, len(result.Steps))
+	}
+	if !strings.Contains(result.Steps[0].Output, "panicked") {
+		t.Errorf("expected the panic to be reported, got %q", result.Steps[0].Output)
+	}
+}
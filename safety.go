@@ -0,0 +1,138 @@
+package grail
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/montanaflynn/grail/internal/imaging"
+)
+
+//
+// Image safety checks
+//
+
+// SafetyVerdict is a classifier's judgment of one image.
+type SafetyVerdict struct {
+	Flagged    bool
+	Categories []string           // flagged categories, e.g. "sexual", "violence"
+	Scores     map[string]float64 // per-category scores from 0 to 1, if available
+}
+
+// ImageClassifier classifies generated images for safety. Implementations can
+// call a provider's moderation API (openai.Provider implements it) or run a
+// local model.
+type ImageClassifier interface {
+	ClassifyImage(ctx context.Context, data []byte, mime string) (SafetyVerdict, error)
+}
+
+// ImageClassifierFunc adapts a function to ImageClassifier.
+type ImageClassifierFunc func(ctx context.Context, data []byte, mime string) (SafetyVerdict, error)
+
+// ClassifyImage implements ImageClassifier.
+func (f ImageClassifierFunc) ClassifyImage(ctx context.Context, data []byte, mime string) (SafetyVerdict, error) {
+	return f(ctx, data, mime)
+}
+
+// SafetyAction is what happens to an image a classifier flags.
+type SafetyAction int
+
+const (
+	// SafetyFlag returns the image unchanged with a warning.
+	SafetyFlag SafetyAction = iota
+	// SafetyBlur replaces the image with a heavily blurred copy.
+	SafetyBlur
+	// SafetyBlock removes the image from the response. If no outputs
+	// remain, Generate fails with Refused.
+	SafetyBlock
+)
+
+// Warning codes set by image safety checks. The message names the output and
+// the flagged categories.
+const (
+	WarningImageFlagged = "image_flagged"
+	WarningImageBlurred = "image_blurred"
+	WarningImageBlocked = "image_blocked"
+)
+
+// ImageSafety configures post-generation safety checks of image outputs.
+type ImageSafety struct {
+	Classifier ImageClassifier
+	Action     SafetyAction
+	// FailOpen returns images unchecked (with a warning) when the classifier
+	// errors. By default a classifier error fails the request.
+	FailOpen bool
+}
+
+// WithImageSafety classifies every image output before Generate returns and
+// applies s.Action to flagged images. Checks run before WithImagePostProcessing,
+// so thumbnails and hashes are made from the blurred image.
+func WithImageSafety(s ImageSafety) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.imageSafety = &s
+	})
+}
+
+func checkImageSafety(ctx context.Context, res *Response, s ImageSafety) error {
+	if s.Classifier == nil {
+		return nil
+	}
+	outputs := res.Outputs[:0:0]
+	blocked := 0
+	for i, part := range res.Outputs {
+		img, ok := part.(imageOutputPart)
+		if !ok {
+			outputs = append(outputs, part)
+			continue
+		}
+		verdict, err := s.Classifier.ClassifyImage(ctx, img.Data, img.MIME)
+		if err != nil {
+			if !s.FailOpen {
+				return NewGrailError(Internal, fmt.Sprintf("output %d: image safety check failed: %v", i, err)).
+					WithCause(err).WithProviderName(res.Provider.Name).WithRequestID(res.RequestID)
+			}
+			res.Warnings = append(res.Warnings, Warning{Code: WarningImageFlagged, Message: fmt.Sprintf("output %d: not checked: %v", i, err)})
+			outputs = append(outputs, img)
+			continue
+		}
+		if !verdict.Flagged {
+			outputs = append(outputs, img)
+			continue
+		}
+
+		msg := fmt.Sprintf("output %d: flagged for %s", i, verdict.describe())
+		switch s.Action {
+		case SafetyBlock:
+			blocked++
+			res.Warnings = append(res.Warnings, Warning{Code: WarningImageBlocked, Message: msg})
+			continue
+		case SafetyBlur:
+			data, mime, err := imaging.Blur(img.Data)
+			if err != nil {
+				return NewGrailError(Internal, fmt.Sprintf("output %d: blur flagged image: %v", i, err)).
+					WithCause(err).WithProviderName(res.Provider.Name).WithRequestID(res.RequestID)
+			}
+			img.Data, img.MIME = data, mime
+			res.Warnings = append(res.Warnings, Warning{Code: WarningImageBlurred, Message: msg})
+		default:
+			res.Warnings = append(res.Warnings, Warning{Code: WarningImageFlagged, Message: msg})
+		}
+		outputs = append(outputs, img)
+	}
+	res.Outputs = outputs
+	if blocked > 0 && len(outputs) == 0 {
+		return NewGrailError(Refused, fmt.Sprintf("all %d image outputs were blocked by the safety check", blocked)).
+			WithProviderName(res.Provider.Name).WithRequestID(res.RequestID)
+	}
+	return nil
+}
+
+func (v SafetyVerdict) describe() string {
+	if len(v.Categories) == 0 {
+		return "unsafe content"
+	}
+	cats := append([]string(nil), v.Categories...)
+	sort.Strings(cats)
+	return strings.Join(cats, ", ")
+}
@@ -0,0 +1,100 @@
+package grail_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/png"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestImageSafety(t *testing.T) {
+	var safe, unsafe bytes.Buffer
+	if err := png.Encode(&safe, scene(64, 48, 0)); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	if err := png.Encode(&unsafe, scene(64, 48, 10)); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{Outputs: []grail.OutputPart{
+				grail.NewImageOutputPart(safe.Bytes(), "image/png", "safe"),
+				grail.NewImageOutputPart(unsafe.Bytes(), "image/png", "unsafe"),
+			}}, nil
+		},
+	}
+	classifier := grail.ImageClassifierFunc(func(ctx context.Context, data []byte, mime string) (grail.SafetyVerdict, error) {
+		if bytes.Equal(data, unsafe.Bytes()) {
+			return grail.SafetyVerdict{Flagged: true, Categories: []string{"violence"}}, nil
+		}
+		return grail.SafetyVerdict{}, nil
+	})
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("draw")}, Output: grail.OutputImage(grail.ImageSpec{Count: 2})}
+	generate := func(s grail.ImageSafety) (grail.Response, error) {
+		return grail.NewClient(prov, grail.WithImageSafety(s)).Generate(context.Background(), req)
+	}
+
+	t.Run("flag", func(t *testing.T) {
+		res, err := generate(grail.ImageSafety{Classifier: classifier})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(res.ImageOutputs()) != 2 || len(res.Warnings) != 1 || res.Warnings[0].Code != grail.WarningImageFlagged {
+			t.Fatalf("expected both images and one flag, got %d images, %+v", len(res.ImageOutputs()), res.Warnings)
+		}
+	})
+
+	t.Run("blur", func(t *testing.T) {
+		res, err := generate(grail.ImageSafety{Classifier: classifier, Action: grail.SafetyBlur})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		images := res.ImageOutputs()
+		if !bytes.Equal(images[0].Data, safe.Bytes()) || bytes.Equal(images[1].Data, unsafe.Bytes()) {
+			t.Fatalf("expected only the flagged image to change")
+		}
+		if cfg, err := png.DecodeConfig(bytes.NewReader(images[1].Data)); err != nil || cfg.Width != 64 || cfg.Height != 48 {
+			t.Fatalf("expected blurred image at original size, got %+v (%v)", cfg, err)
+		}
+		if res.Warnings[0].Code != grail.WarningImageBlurred {
+			t.Fatalf("expected blurred warning, got %+v", res.Warnings)
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		res, err := generate(grail.ImageSafety{Classifier: classifier, Action: grail.SafetyBlock})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if images := res.ImageOutputs(); len(images) != 1 || images[0].Name != "safe" {
+			t.Fatalf("expected only the safe image, got %+v", images)
+		}
+		if res.Warnings[0].Code != grail.WarningImageBlocked {
+			t.Fatalf("expected blocked warning, got %+v", res.Warnings)
+		}
+
+		all := grail.ImageClassifierFunc(func(ctx context.Context, data []byte, mime string) (grail.SafetyVerdict, error) {
+			return grail.SafetyVerdict{Flagged: true}, nil
+		})
+		if _, err := generate(grail.ImageSafety{Classifier: all, Action: grail.SafetyBlock}); grail.GetErrorCode(err) != grail.Refused {
+			t.Fatalf("expected refused when every image is blocked, got %v", err)
+		}
+	})
+
+	t.Run("classifier errors", func(t *testing.T) {
+		failing := grail.ImageClassifierFunc(func(ctx context.Context, data []byte, mime string) (grail.SafetyVerdict, error) {
+			return grail.SafetyVerdict{}, errors.New("classifier down")
+		})
+		if _, err := generate(grail.ImageSafety{Classifier: failing}); err == nil {
+			t.Fatalf("expected classifier error to fail closed")
+		}
+		res, err := generate(grail.ImageSafety{Classifier: failing, FailOpen: true})
+		if err != nil || len(res.ImageOutputs()) != 2 || len(res.Warnings) != 2 {
+			t.Fatalf("expected unchecked images with warnings, got %+v (%v)", res.Warnings, err)
+		}
+	})
+}
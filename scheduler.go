@@ -0,0 +1,338 @@
+package grail
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+//
+// Quota-aware scheduling
+//
+
+// Quota is a provider rate limit for one model. Zero fields are unlimited.
+type Quota struct {
+	RPM int // requests per minute
+	TPM int // tokens per minute (input and output)
+}
+
+// Priority orders requests waiting for quota: higher priorities are
+// dispatched first. The zero value is PriorityNormal.
+type Priority int
+
+const (
+	PriorityBackground  Priority = -10 // batch and other work that can wait
+	PriorityNormal      Priority = 0
+	PriorityInteractive Priority = 10 // user-facing calls
+)
+
+// Scheduler queues requests and dispatches them within per-model quotas, so
+// bursts (batch jobs, fan-out pipelines) are spread out instead of hitting
+// provider rate limits. Requests for the same model are dispatched by
+// priority (Request.Priority), then in arrival order, so interactive calls
+// overtake queued background work. Requests already dispatched are not
+// interrupted. Attach a scheduler to clients with WithScheduler; clients
+// sharing a scheduler share its quotas.
+//
+// Token use is estimated before dispatch, text by its size and files by type
+// (images by their dimensions, PDFs by their pages), and corrected with the
+// reported usage when the response arrives.
+//
+// Quotas are per process unless the scheduler is given a SharedState with
+// SetSharedState.
+type Scheduler struct {
+	mu       sync.Mutex
+	shared   SharedState
+	defaults Quota
+	quotas   map[string]Quota
+	queues   map[string]*modelQueue
+	wake     chan struct{} // closed and replaced whenever capacity may have changed
+	now      func() time.Time
+}
+
+type modelQueue struct {
+	waiters []*schedWaiter
+	window  []*schedEntry // dispatches in the last minute, oldest first
+}
+
+type schedWaiter struct {
+	priority Priority
+	tokens   int
+}
+
+type schedEntry struct {
+	at     time.Time
+	tokens int
+}
+
+const quotaWindow = time.Minute
+
+// NewScheduler returns a scheduler that applies defaults to every model
+// without its own quota.
+func NewScheduler(defaults Quota) *Scheduler {
+	return &Scheduler{
+		defaults: defaults,
+		quotas:   map[string]Quota{},
+		queues:   map[string]*modelQueue{},
+		wake:     make(chan struct{}),
+		now:      time.Now,
+	}
+}
+
+// WithScheduler dispatches every Generate call through s.
+func WithScheduler(s *Scheduler) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.scheduler = s
+	})
+}
+
+// SetQuota sets the quota for a model. The empty model name covers requests
+// that leave model selection to the provider.
+func (s *Scheduler) SetQuota(model string, q Quota) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quotas[model] = q
+	s.broadcast()
+}
+
+// SetSharedState counts dispatches in state, so schedulers in every replica
+// of a service sharing it keep within their quotas together. Shared counts
+// use fixed one-minute windows. Priorities still only order the requests
+// waiting in each process. If state fails, the scheduler falls back to
+// counting dispatches locally.
+func (s *Scheduler) SetSharedState(state SharedState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shared = state
+	s.broadcast()
+}
+
+// Queued returns the number of requests waiting for quota, across all models.
+func (s *Scheduler) Queued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, q := range s.queues {
+		n += len(q.waiters)
+	}
+	return n
+}
+
+// Acquire waits until a request for model estimated to use tokens fits the
+// model's quota and no request of higher priority is waiting, or ctx is done.
+// The returned release function must be called with the request's actual
+// usage (zero if unknown) once it completes.
+func (s *Scheduler) Acquire(ctx context.Context, model string, tokens int, priority Priority) (release func(Usage), err error) {
+	s.mu.Lock()
+	s.prune()
+	w := &schedWaiter{priority: priority, tokens: tokens}
+	q := s.queue(model)
+	q.insert(w)
+	// A new head may be able to go now.
+	s.broadcast()
+
+	for {
+		var wait time.Duration
+		if q.waiters[0] == w {
+			release, d, ok := s.tryAdmit(ctx, model, q, w)
+			if ok {
+				s.mu.Unlock()
+				return release, nil
+			}
+			wait = d
+		}
+		wake := s.wake
+		s.mu.Unlock()
+
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			expired = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-wake:
+		case <-expired:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		s.mu.Lock()
+		if err := ctx.Err(); err != nil {
+			q.remove(w)
+			s.prune()
+			s.broadcast()
+			s.mu.Unlock()
+			return nil, err
+		}
+	}
+}
+
+// tryAdmit dispatches w, the head of q, if the quota allows, and returns its
+// release function. Otherwise it returns how long to wait before trying
+// again. s.mu must be held; it's released while shared counts are updated.
+func (s *Scheduler) tryAdmit(ctx context.Context, model string, q *modelQueue, w *schedWaiter) (func(Usage), time.Duration, bool) {
+	if s.shared != nil {
+		shared, quota := s.shared, s.quota(model)
+		s.mu.Unlock()
+		settle, wait, err := reserveShared(ctx, shared, s.now(), model, w.tokens, quota)
+		s.mu.Lock()
+		switch {
+		case err == nil && settle != nil:
+			q.remove(w)
+			s.broadcast()
+			return settle, 0, true
+		case err == nil:
+			return nil, wait, false
+		case ctx.Err() != nil:
+			return nil, 0, false
+		}
+		// The shared state failed; count the dispatch locally instead.
+	}
+	wait, ok := s.admit(model, q, w)
+	if !ok {
+		return nil, wait, false
+	}
+	entry := q.window[len(q.window)-1]
+	return func(u Usage) { s.settle(entry, u) }, 0, true
+}
+
+func (s *Scheduler) quota(model string) Quota {
+	if q, ok := s.quotas[model]; ok {
+		return q
+	}
+	return s.defaults
+}
+
+// reserveShared counts a dispatch of tokens in model's current shared window
+// and returns the function that corrects its token count. If the dispatch
+// doesn't fit, it's uncounted and settle is nil, with how long until the
+// next window.
+func reserveShared(ctx context.Context, state SharedState, now time.Time, model string, tokens int, quota Quota) (settle func(Usage), wait time.Duration, err error) {
+	start := now.Truncate(quotaWindow)
+	next := start.Add(quotaWindow).Sub(now)
+	key := fmt.Sprintf("grail:quota:%s:%d", model, start.Unix())
+	ttl := 2 * quotaWindow
+	if quota.RPM > 0 {
+		n, err := state.Incr(ctx, key+":requests", 1, ttl)
+		if err != nil {
+			return nil, 0, err
+		}
+		if n > float64(quota.RPM) {
+			_, err := state.Incr(ctx, key+":requests", -1, ttl)
+			return nil, next, err
+		}
+	}
+	if quota.TPM > 0 {
+		n, err := state.Incr(ctx, key+":tokens", float64(tokens), ttl)
+		if err != nil {
+			return nil, 0, err
+		}
+		// As locally, a request larger than the whole quota goes through on
+		// an empty window.
+		if n > float64(quota.TPM) && n > float64(tokens) {
+			_, err := state.Incr(ctx, key+":tokens", -float64(tokens), ttl)
+			if quota.RPM > 0 && err == nil {
+				_, err = state.Incr(ctx, key+":requests", -1, ttl)
+			}
+			return nil, next, err
+		}
+	}
+	return func(u Usage) {
+		if quota.TPM > 0 && u.TotalTokens > 0 && u.TotalTokens != tokens {
+			state.Incr(context.WithoutCancel(ctx), key+":tokens", float64(u.TotalTokens-tokens), ttl)
+		}
+	}, 0, nil
+}
+
+// admit dispatches w, the head of q, if the quota allows. Otherwise it returns
+// how long until the oldest dispatch leaves the window. s.mu must be held.
+func (s *Scheduler) admit(model string, q *modelQueue, w *schedWaiter) (time.Duration, bool) {
+	quota := s.quota(model)
+	now := s.now()
+	q.expire(now)
+	used := 0
+	for _, e := range q.window {
+		used += e.tokens
+	}
+	fits := (quota.RPM <= 0 || len(q.window) < quota.RPM) &&
+		// A request larger than the whole quota is let through on an empty
+		// window rather than blocking forever.
+		(quota.TPM <= 0 || used+w.tokens <= quota.TPM || len(q.window) == 0)
+	if !fits {
+		return quotaWindow - now.Sub(q.window[0].at), false
+	}
+	q.remove(w)
+	q.window = append(q.window, &schedEntry{at: now, tokens: w.tokens})
+	// The next waiter may fit too.
+	s.broadcast()
+	return 0, true
+}
+
+// settle replaces a dispatch's estimated tokens with its actual usage.
+func (s *Scheduler) settle(e *schedEntry, u Usage) {
+	if u.TotalTokens <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.tokens = u.TotalTokens
+	s.broadcast()
+}
+
+func (s *Scheduler) queue(model string) *modelQueue {
+	q, ok := s.queues[model]
+	if !ok {
+		q = &modelQueue{}
+		s.queues[model] = q
+	}
+	return q
+}
+
+// prune drops the queues of models with nothing waiting and no dispatches
+// left in their window, so a scheduler serving many models doesn't keep a
+// queue for every model it has seen. s.mu must be held.
+func (s *Scheduler) prune() {
+	now := s.now()
+	for model, q := range s.queues {
+		if len(q.waiters) == 0 {
+			q.expire(now)
+			if len(q.window) == 0 {
+				delete(s.queues, model)
+			}
+		}
+	}
+}
+
+func (s *Scheduler) broadcast() {
+	close(s.wake)
+	s.wake = make(chan struct{})
+}
+
+// insert adds w behind waiters of the same or higher priority.
+func (q *modelQueue) insert(w *schedWaiter) {
+	i := len(q.waiters)
+	for i > 0 && q.waiters[i-1].priority < w.priority {
+		i--
+	}
+	q.waiters = slices.Insert(q.waiters, i, w)
+}
+
+// expire drops dispatches that have left the window.
+func (q *modelQueue) expire(now time.Time) {
+	for len(q.window) > 0 && now.Sub(q.window[0].at) >= quotaWindow {
+		q.window = q.window[1:]
+	}
+}
+
+func (q *modelQueue) remove(w *schedWaiter) {
+	for i, x := range q.waiters {
+		if x == w {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package grail_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestScheduler_Quota(t *testing.T) {
+	calls := 0
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			calls++
+			return grail.Response{
+				Outputs: []grail.OutputPart{grail.NewTextOutputPart("ok")},
+				Usage:   grail.Usage{InputTokens: 40, OutputTokens: 60, TotalTokens: 100},
+			}, nil
+		},
+	}
+	sched := grail.NewScheduler(grail.Quota{RPM: 2})
+	sched.SetQuota("small", grail.Quota{TPM: 100})
+	client := grail.NewClient(prov, grail.WithScheduler(sched))
+
+	generate := func(model string, wait time.Duration) error {
+		ctx, cancel := context.WithTimeout(context.Background(), wait)
+		defer cancel()
+		_, err := client.Generate(ctx, grail.Request{Model: model, Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()})
+		return err
+	}
+
+	for i := range 2 {
+		if err := generate("default", time.Second); err != nil {
+			t.Fatalf("request %d within quota: %v", i, err)
+		}
+	}
+	err := generate("default", 50*time.Millisecond)
+	if grail.GetErrorCode(err) != grail.Timeout {
+		t.Fatalf("expected timeout waiting for RPM quota, got %v", err)
+	}
+
+	// The first request's reported usage (100 tokens) replaces its estimate,
+	// using up a 100 TPM quota.
+	if err := generate("small", time.Second); err != nil {
+		t.Fatalf("first request within TPM quota: %v", err)
+	}
+	if err := generate("small", 50*time.Millisecond); err == nil {
+		t.Fatalf("expected TPM quota to hold the second request")
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 dispatched requests, got %d", calls)
+	}
+	if n := sched.Queued(); n != 0 {
+		t.Fatalf("expected cancelled waiters to leave the queue, got %d", n)
+	}
+}
+
+func TestScheduler_OversizedRequest(t *testing.T) {
+	sched := grail.NewScheduler(grail.Quota{TPM: 10})
+	release, err := sched.Acquire(context.Background(), "m", 1000, grail.PriorityNormal)
+	if err != nil {
+		t.Fatalf("expected a request larger than the quota to run on an empty window: %v", err)
+	}
+	release(grail.Usage{})
+}
+
+func TestScheduler_FIFO(t *testing.T) {
+	sched := grail.NewScheduler(grail.Quota{TPM: 100})
+	release, err := sched.Acquire(context.Background(), "m", 100, grail.PriorityNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A waiting large request blocks smaller ones queued behind it.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := sched.Acquire(ctx, "m", 50, grail.PriorityNormal)
+		done <- err
+	}()
+	for sched.Queued() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := sched.Acquire(ctx, "m", 1, grail.PriorityNormal); err == nil {
+		t.Fatalf("expected later request to wait behind the queue head")
+	}
+	if err := <-done; err == nil {
+		t.Fatalf("expected head to keep waiting for quota")
+	}
+	release(grail.Usage{TotalTokens: 100})
+}
+
+func TestScheduler_Priority(t *testing.T) {
+	sched := grail.NewScheduler(grail.Quota{TPM: 100})
+	release, err := sched.Acquire(context.Background(), "m", 100, grail.PriorityNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan string, 2)
+	acquire := func(name string, p grail.Priority) {
+		if _, err := sched.Acquire(context.Background(), "m", 40, p); err == nil {
+			order <- name
+		}
+	}
+	go acquire("batch", grail.PriorityBackground)
+	for sched.Queued() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	go acquire("user", grail.PriorityInteractive)
+	for sched.Queued() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Actual usage below the estimate frees room for both waiters.
+	release(grail.Usage{TotalTokens: 10})
+	if first, second := <-order, <-order; first != "user" || second != "batch" {
+		t.Fatalf("expected interactive request first, got %s then %s", first, second)
+	}
+}
+
+func TestDefaultPriority(t *testing.T) {
+	var got grail.Priority
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			got = req.Priority
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("ok")}}, nil
+		},
+	}
+	batch := grail.NewClient(prov).With(grail.WithDefaultPriority(grail.PriorityBackground))
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()}
+	if _, err := batch.Generate(context.Background(), req); err != nil || got != grail.PriorityBackground {
+		t.Fatalf("expected default background priority, got %v (%v)", got, err)
+	}
+	req.Priority = grail.PriorityInteractive
+	if _, err := batch.Generate(context.Background(), req); err != nil || got != grail.PriorityInteractive {
+		t.Fatalf("expected request priority to win, got %v (%v)", got, err)
+	}
+}
+
+// failingState is a SharedState whose backend is down.
+type failingState struct{}
+
+func (failingState) Incr(ctx context.Context, key string, delta float64, ttl time.Duration) (float64, error) {
+	return 0, grail.NewGrailError(grail.Unavailable, "state down")
+}
+
+func (failingState) Push(ctx context.Context, key string, value []byte, max int, ttl time.Duration) error {
+	return grail.NewGrailError(grail.Unavailable, "state down")
+}
+
+func (failingState) List(ctx context.Context, key string) ([][]byte, error) {
+	return nil, grail.NewGrailError(grail.Unavailable, "state down")
+}
+
+func TestScheduler_SharedState(t *testing.T) {
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("ok")}}, nil
+		},
+	}
+	generate := func(client grail.Client, wait time.Duration) error {
+		ctx, cancel := context.WithTimeout(context.Background(), wait)
+		defer cancel()
+		_, err := client.Generate(ctx, grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()})
+		return err
+	}
+
+	// Two replicas' schedulers share one RPM quota.
+	state := grail.NewMemoryState()
+	var clients []grail.Client
+	for range 2 {
+		sched := grail.NewScheduler(grail.Quota{RPM: 2})
+		sched.SetSharedState(state)
+		clients = append(clients, grail.NewClient(prov, grail.WithScheduler(sched)))
+	}
+	if err := generate(clients[0], time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(clients[1], time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(clients[0], 50*time.Millisecond); grail.GetErrorCode(err) != grail.Timeout {
+		t.Fatalf("expected the shared quota to hold the third request, got %v", err)
+	}
+
+	// A failing state falls back to the local quota.
+	sched := grail.NewScheduler(grail.Quota{RPM: 1})
+	sched.SetSharedState(failingState{})
+	client := grail.NewClient(prov, grail.WithScheduler(sched))
+	if err := generate(client, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(client, 50*time.Millisecond); grail.GetErrorCode(err) != grail.Timeout {
+		t.Fatalf("expected the local quota to hold the second request, got %v", err)
+	}
+}
+
+func TestScheduler_DropsIdleQueues(t *testing.T) {
+	sched := grail.NewScheduler(grail.Quota{RPM: 1})
+	now := time.Now()
+	grail.SetSchedulerClock(sched, func() time.Time { return now })
+	acquire := func(model string, wait time.Duration) error {
+		ctx, cancel := context.WithTimeout(context.Background(), wait)
+		defer cancel()
+		release, err := sched.Acquire(ctx, model, 1, grail.PriorityNormal)
+		if err == nil {
+			release(grail.Usage{})
+		}
+		return err
+	}
+
+	// A dispatch keeps its model's queue while it counts against the quota,
+	// and a request given up on doesn't leave one behind.
+	if err := acquire("a", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := acquire("a", 10*time.Millisecond); err == nil {
+		t.Fatal("expected the quota to hold the second request")
+	}
+	if n := grail.SchedulerQueues(sched); n != 1 {
+		t.Fatalf("expected 1 queue, got %d", n)
+	}
+
+	// Once the window has passed, the queue is dropped.
+	now = now.Add(2 * time.Minute)
+	for _, model := range []string{"b", "c"} {
+		if err := acquire(model, time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := grail.SchedulerQueues(sched); n != 2 {
+		t.Errorf("expected the queues of b and c only, got %d", n)
+	}
+}
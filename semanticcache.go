@@ -0,0 +1,336 @@
+package grail
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//
+// Semantic response cache
+//
+
+// Embedder turns texts into embedding vectors, one per text, for similarity
+// search. openai.Provider and ollama.Provider implement it.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+}
+
+// EmbedderFunc adapts a function to Embedder.
+type EmbedderFunc func(ctx context.Context, texts []string) ([][]float64, error)
+
+// Embed implements Embedder.
+func (f EmbedderFunc) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	return f(ctx, texts)
+}
+
+// SemanticCacheKey is the metadata key that opts a request out of a
+// SemanticCache: set it to "off" on the request, or on its context with
+// ContextWithMetadata, to always call the provider and not cache the result.
+const SemanticCacheKey = "semantic_cache"
+
+// WarningSemanticCacheHit is the warning code set on responses served from a
+// SemanticCache. The message gives the similarity to the cached prompt.
+const WarningSemanticCacheHit = "semantic_cache_hit"
+
+// DefaultSemanticThreshold is the similarity SemanticCache uses when its
+// Threshold is zero.
+const DefaultSemanticThreshold = 0.95
+
+// SemanticCache returns an earlier response when a request's prompt means
+// nearly the same as one it has seen, not only when it's identical, which
+// saves most provider calls for FAQ-style traffic. Install its middleware:
+//
+//	cache := &grail.SemanticCache{Embedder: openaiProvider, TTL: 24 * time.Hour}
+//	client := grail.NewClient(provider, grail.WithMiddleware(cache.Middleware()))
+//
+// Only requests whose inputs are all text are cached. A cached response is
+// only returned for a request with the same output (including its schema),
+// model, tier, provider options, and ScopeKeys metadata, and is served with
+// zero usage and a WarningSemanticCacheHit warning. Pick Threshold with care: prompts that
+// differ in one detail ("refund policy in France" and "... in Germany") can
+// embed very close together.
+type SemanticCache struct {
+	Embedder Embedder
+	// Threshold is the cosine similarity from 0 to 1 at or above which a
+	// cached response is returned (default DefaultSemanticThreshold).
+	Threshold float64
+	TTL       time.Duration // how long responses are served; zero for no expiry
+	// MaxEntries bounds the cache; the oldest entries are evicted first
+	// (default 1000).
+	MaxEntries int
+	// ScopeKeys are the metadata keys whose values a request must share
+	// with a cached one to be served its response, so tenants or users
+	// sharing a cache don't get each other's answers (default
+	// DefaultTenantKey). Add a user key to keep answers per user.
+	ScopeKeys []string
+	// State, if set, keeps responses there instead of in memory, so the
+	// replicas of a service share them (see SharedState). There, MaxEntries
+	// bounds the entries for each request shape (output, model, and
+	// options), and Len and Clear don't see them. A failing State is
+	// treated as a miss.
+	State SharedState
+	// Keys, if set, encrypts the entries kept in State with AES-GCM (see
+	// Seal), embeddings included. Entries that aren't encrypted with them
+	// are skipped.
+	Keys KeyProvider
+
+	mu      sync.Mutex
+	entries []semanticEntry
+	hits    atomic.Int64
+	misses  atomic.Int64
+}
+
+type semanticEntry struct {
+	shape  string // hash of everything but the prompt that must match
+	vector []float64
+	norm   float64
+	res    Response
+	time   time.Time
+}
+
+// Stats returns the number of requests served from the cache and the number
+// of cacheable requests that weren't.
+func (c *SemanticCache) Stats() (hits, misses int64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+// Len returns the number of cached responses, including expired ones not yet
+// evicted.
+func (c *SemanticCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Clear drops every cached response.
+func (c *SemanticCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
+
+// Middleware returns the middleware that serves and fills the cache. Failing
+// to embed a prompt doesn't fail the request; it's sent to the provider
+// uncached.
+func (c *SemanticCache) Middleware() Middleware {
+	return func(next GenerateFunc) GenerateFunc {
+		return func(ctx context.Context, req Request) (Response, error) {
+			prompt, ok := semanticPrompt(req)
+			if !ok || c.Embedder == nil || strings.EqualFold(req.Metadata[SemanticCacheKey], "off") {
+				return next(ctx, req)
+			}
+			vectors, err := c.Embedder.Embed(ctx, []string{prompt})
+			if err != nil || len(vectors) != 1 || len(vectors[0]) == 0 {
+				return next(ctx, req)
+			}
+			e := semanticEntry{shape: c.shape(req), vector: vectors[0], norm: vectorNorm(vectors[0])}
+			if res, sim, ok := c.lookup(ctx, e); ok {
+				c.hits.Add(1)
+				res.Usage = Usage{}
+				res.Warnings = append(res.Warnings[:len(res.Warnings):len(res.Warnings)],
+					Warning{Code: WarningSemanticCacheHit, Message: fmt.Sprintf("served from semantic cache (similarity %.3f)", sim)})
+				return res, nil
+			}
+			c.misses.Add(1)
+			res, err := next(ctx, req)
+			if err == nil {
+				e.res, e.time = res, time.Now()
+				c.store(ctx, e)
+			}
+			return res, err
+		}
+	}
+}
+
+// sharedSemanticEntry is a semanticEntry as kept in a SharedState, under a
+// key made from its shape.
+type sharedSemanticEntry struct {
+	Vector   []float64      `json:"vector"`
+	Response ResponseRecord `json:"response"`
+	Time     time.Time      `json:"time"`
+}
+
+func semanticStateKey(shape string) string {
+	return "grail:semantic:" + shape
+}
+
+// lookup returns the most similar live entry with the same shape, if it's
+// similar enough.
+func (c *SemanticCache) lookup(ctx context.Context, e semanticEntry) (Response, float64, bool) {
+	entries := c.entries
+	if c.State != nil {
+		values, err := c.State.List(ctx, semanticStateKey(e.shape))
+		if err != nil {
+			return Response{}, 0, false
+		}
+		entries = nil
+		for _, v := range values {
+			var se sharedSemanticEntry
+			if openJSON(c.Keys, v, &se, semanticStateKey(e.shape)) != nil {
+				continue
+			}
+			entries = append(entries, semanticEntry{shape: e.shape, vector: se.Vector, norm: vectorNorm(se.Vector), res: se.Response.Response(), time: se.Time})
+		}
+	} else {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	threshold := c.Threshold
+	if threshold <= 0 {
+		threshold = DefaultSemanticThreshold
+	}
+	var best *semanticEntry
+	bestSim := -1.0
+	for i := range entries {
+		cand := &entries[i]
+		if cand.shape != e.shape || len(cand.vector) != len(e.vector) || c.expired(cand) {
+			continue
+		}
+		if sim := cosine(cand.vector, cand.norm, e.vector, e.norm); sim > bestSim {
+			best, bestSim = cand, sim
+		}
+	}
+	if best == nil || bestSim < threshold {
+		return Response{}, 0, false
+	}
+	// The client edits responses in place as it post-processes them.
+	return cloneResponse(best.res), bestSim, true
+}
+
+func (c *SemanticCache) store(ctx context.Context, e semanticEntry) {
+	limit := c.MaxEntries
+	if limit <= 0 {
+		limit = 1000
+	}
+	if c.State != nil {
+		data, err := sealJSON(c.Keys, sharedSemanticEntry{Vector: e.vector, Response: NewResponseRecord(e.res), Time: e.time}, semanticStateKey(e.shape))
+		if err == nil {
+			c.State.Push(context.WithoutCancel(ctx), semanticStateKey(e.shape), data, limit, c.TTL)
+		}
+		return
+	}
+	e.res = cloneResponse(e.res)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	live := c.entries[:0]
+	for _, old := range c.entries {
+		if !c.expired(&old) {
+			live = append(live, old)
+		}
+	}
+	c.entries = append(live, e)
+	if n := len(c.entries) - limit; n > 0 {
+		c.entries = append(c.entries[:0], c.entries[n:]...)
+	}
+}
+
+func (c *SemanticCache) expired(e *semanticEntry) bool {
+	return c.TTL > 0 && time.Since(e.time) > c.TTL
+}
+
+// semanticPrompt joins a request's text inputs, reporting false if it has
+// any other inputs or earlier turns, whose answers depend on more than the
+// prompt.
+func semanticPrompt(req Request) (string, bool) {
+	if len(req.History) > 0 {
+		return "", false
+	}
+	texts := make([]string, 0, len(req.Inputs))
+	for _, in := range req.Inputs {
+		text, ok := AsTextInput(in)
+		if !ok {
+			return "", false
+		}
+		texts = append(texts, text)
+	}
+	prompt := strings.Join(texts, "\n\n")
+	return prompt, strings.TrimSpace(prompt) != ""
+}
+
+// shape hashes the parts of a request other than its prompt, which must match
+// exactly for a cached response to be reused.
+func (c *SemanticCache) shape(req Request) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "model=%s tier=%s\n", req.Model, req.Tier)
+	scopes := c.ScopeKeys
+	if scopes == nil {
+		scopes = []string{DefaultTenantKey}
+	}
+	for _, k := range scopes {
+		fmt.Fprintf(h, "scope %q=%q\n", k, req.Metadata[k])
+	}
+	data, _ := json.Marshal(req.Output)
+	fmt.Fprintf(h, "output %T%s\n", req.Output, data)
+	for _, opt := range req.ProviderOptions {
+		data, _ := json.Marshal(opt)
+		fmt.Fprintf(h, "option %T%s\n", opt, data)
+	}
+	for _, t := range req.Tools {
+		data, _ := json.Marshal(t)
+		fmt.Fprintf(h, "tool %s\n", data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func vectorNorm(v []float64) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += x * x
+	}
+	return math.Sqrt(sum)
+}
+
+func cosine(a []float64, an float64, b []float64, bn float64) float64 {
+	if an == 0 || bn == 0 {
+		return 0
+	}
+	var dot float64
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return dot / (an * bn)
+}
+
+// cloneResponse returns a copy of res that shares no slices or maps with it.
+func cloneResponse(res Response) Response {
+	res.Outputs = slices.Clone(res.Outputs)
+	for i, part := range res.Outputs {
+		switch v := part.(type) {
+		case imageOutputPart:
+			v.Data, v.Thumbnail = bytes.Clone(v.Data), bytes.Clone(v.Thumbnail)
+			if v.Hash != nil {
+				hash := *v.Hash
+				v.Hash = &hash
+			}
+			res.Outputs[i] = v
+		case videoOutputPart:
+			v.Data = bytes.Clone(v.Data)
+			res.Outputs[i] = v
+		case jsonOutputPart:
+			v.JSON, v.Logprobs, v.Confidence = bytes.Clone(v.JSON), slices.Clone(v.Logprobs), maps.Clone(v.Confidence)
+			res.Outputs[i] = v
+		case toolCallOutputPart:
+			v.Call.Arguments, v.Call.Signature = bytes.Clone(v.Call.Arguments), bytes.Clone(v.Call.Signature)
+			res.Outputs[i] = v
+		}
+	}
+	res.Warnings = slices.Clone(res.Warnings)
+	res.Provider.Models = slices.Clone(res.Provider.Models)
+	if res.Cost != nil {
+		cost := *res.Cost
+		res.Cost = &cost
+	}
+	return res
+}
@@ -0,0 +1,216 @@
+package grail_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+// wordEmbedder embeds texts as counts of a few words, so prompts that share
+// those words are similar.
+var wordEmbedder = grail.EmbedderFunc(func(ctx context.Context, texts []string) ([][]float64, error) {
+	vocab := []string{"refund", "policy", "shipping", "time", "what", "is", "the"}
+	out := make([][]float64, len(texts))
+	for i, text := range texts {
+		v := make([]float64, len(vocab))
+		for _, w := range strings.Fields(strings.ToLower(strings.Trim(text, "?"))) {
+			for j, vw := range vocab {
+				if w == vw {
+					v[j]++
+				}
+			}
+		}
+		out[i] = v
+	}
+	return out, nil
+})
+
+func TestSemanticCache(t *testing.T) {
+	calls := 0
+	prov := &mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		calls++
+		return grail.Response{
+			Outputs: []grail.OutputPart{grail.NewTextOutputPart("answer")},
+			Usage:   grail.Usage{InputTokens: 10, OutputTokens: 5, TotalTokens: 15},
+		}, nil
+	}}
+	cache := &grail.SemanticCache{Embedder: wordEmbedder, Threshold: 0.9}
+	client := grail.NewClient(prov, grail.WithMiddleware(cache.Middleware()))
+	ask := func(ctx context.Context, prompt string, md map[string]string) grail.Response {
+		t.Helper()
+		res, err := client.Generate(ctx, grail.Request{
+			Inputs:   []grail.Input{grail.InputText(prompt)},
+			Output:   grail.OutputText(),
+			Metadata: md,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	ctx := context.Background()
+
+	ask(ctx, "What is the refund policy?", nil)
+	res := ask(ctx, "what is the REFUND policy", nil)
+	if calls != 1 {
+		t.Fatalf("expected a cache hit, got %d provider calls", calls)
+	}
+	if res.Usage.TotalTokens != 0 || len(res.Warnings) != 1 || res.Warnings[0].Code != grail.WarningSemanticCacheHit {
+		t.Fatalf("unexpected hit response: usage %+v, warnings %+v", res.Usage, res.Warnings)
+	}
+
+	ask(ctx, "What is the shipping time?", nil)
+	if calls != 2 {
+		t.Fatalf("expected a miss for a different question, got %d provider calls", calls)
+	}
+
+	// JSON output never matches a cached text response.
+	client.Generate(ctx, grail.Request{
+		Inputs: []grail.Input{grail.InputText("What is the refund policy?")},
+		Output: grail.OutputJSON(nil),
+	})
+	if calls != 3 {
+		t.Fatalf("expected a provider call for a different output, got %d calls", calls)
+	}
+
+	// Opting out, on the request or its context, always calls the provider.
+	ask(ctx, "What is the refund policy?", map[string]string{grail.SemanticCacheKey: "off"})
+	ask(grail.ContextWithMetadata(ctx, grail.SemanticCacheKey, "off"), "What is the refund policy?", nil)
+	if calls != 5 {
+		t.Fatalf("expected opted-out requests to reach the provider, got %d calls", calls)
+	}
+	if hits, misses := cache.Stats(); hits != 1 || misses != 3 {
+		t.Fatalf("stats = %d hits, %d misses", hits, misses)
+	}
+
+	cache.TTL = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	ask(ctx, "What is the refund policy?", nil)
+	if calls != 6 {
+		t.Fatalf("expected expired entries to be ignored, got %d calls", calls)
+	}
+	cache.Clear()
+	if cache.Len() != 0 {
+		t.Fatalf("Len after Clear = %d", cache.Len())
+	}
+}
+
+func TestSemanticCache_SharedState(t *testing.T) {
+	calls := 0
+	prov := &mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		calls++
+		return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("answer")}}, nil
+	}}
+	// Two replicas with their own caches sharing one state.
+	state := grail.NewMemoryState()
+	var clients []grail.Client
+	for range 2 {
+		cache := &grail.SemanticCache{Embedder: wordEmbedder, Threshold: 0.9, State: state}
+		clients = append(clients, grail.NewClient(prov, grail.WithMiddleware(cache.Middleware())))
+	}
+	ctx := context.Background()
+	for i, prompt := range []string{"What is the refund policy?", "what is the REFUND policy"} {
+		res, err := clients[i].Generate(ctx, grail.Request{Inputs: []grail.Input{grail.InputText(prompt)}, Output: grail.OutputText()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text, _ := res.Text(); text != "answer" {
+			t.Fatalf("unexpected response %q", text)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected the second replica to hit the first's entry, got %d provider calls", calls)
+	}
+}
+
+// pushRecorder is a SharedState that records the values pushed to it.
+type pushRecorder struct {
+	grail.SharedState
+	pushed [][]byte
+}
+
+func (s *pushRecorder) Push(ctx context.Context, key string, value []byte, max int, ttl time.Duration) error {
+	s.pushed = append(s.pushed, value)
+	return s.SharedState.Push(ctx, key, value, max, ttl)
+}
+
+func TestSemanticCache_Encryption(t *testing.T) {
+	calls := 0
+	prov := &mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		calls++
+		return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("the secret answer")}}, nil
+	}}
+	state := &pushRecorder{SharedState: grail.NewMemoryState()}
+	keys := grail.StaticKey(bytes.Repeat([]byte{5}, 32))
+	ask := func(keys grail.KeyProvider) string {
+		t.Helper()
+		cache := &grail.SemanticCache{Embedder: wordEmbedder, State: state, Keys: keys}
+		client := grail.NewClient(prov, grail.WithMiddleware(cache.Middleware()))
+		res, err := client.Generate(context.Background(), grail.Request{Inputs: []grail.Input{grail.InputText("What is the refund policy?")}, Output: grail.OutputText()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, _ := res.Text()
+		return text
+	}
+
+	ask(keys)
+	if len(state.pushed) != 1 || bytes.Contains(state.pushed[0], []byte("secret")) {
+		t.Fatalf("expected one encrypted entry, got %q", state.pushed)
+	}
+	if got := ask(keys); got != "the secret answer" || calls != 1 {
+		t.Fatalf("expected a hit on the encrypted entry, got %q after %d calls", got, calls)
+	}
+	if ask(nil); calls != 2 {
+		t.Fatalf("expected a cache without keys to skip the encrypted entry, got %d calls", calls)
+	}
+}
+
+func TestSemanticCache_Isolation(t *testing.T) {
+	calls := 0
+	prov := &mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		calls++
+		return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("answer")}}, nil
+	}}
+	cache := &grail.SemanticCache{Embedder: wordEmbedder}
+	client := grail.NewClient(prov,
+		grail.WithMiddleware(cache.Middleware()),
+		grail.WithPostProcessors(grail.PostProcessor{Text: func(ctx context.Context, text string) (string, error) {
+			return text + "!", nil
+		}}),
+	)
+	ask := func(tenant string) string {
+		t.Helper()
+		res, err := client.Generate(context.Background(), grail.Request{
+			Inputs:   []grail.Input{grail.InputText("What is the refund policy?")},
+			Output:   grail.OutputText(),
+			Metadata: map[string]string{grail.DefaultTenantKey: tenant},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, _ := res.Text()
+		return text
+	}
+
+	// Post-processing a served response doesn't change the cached one.
+	for range 3 {
+		if text := ask("acme"); text != "answer!" {
+			t.Fatalf("expected the response to be post-processed once, got %q", text)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected cache hits, got %d provider calls", calls)
+	}
+
+	// Tenants don't get each other's answers.
+	ask("initech")
+	if calls != 2 {
+		t.Errorf("expected a miss for another tenant, got %d provider calls", calls)
+	}
+}
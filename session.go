@@ -0,0 +1,163 @@
+package grail
+
+import (
+	"context"
+	"sync"
+)
+
+//
+// Sessions
+//
+
+// Session is a conversation with a client. It records every turn in a
+// Transcript and lets a SessionState strategy carry context between turns.
+// Without a state strategy each message is sent on its own; provider
+// strategies such as openai.WithServerSideState keep the context on the
+// provider's side. A Session is safe for concurrent use, but turns are sent
+// one at a time.
+type Session struct {
+	client Client
+	opts   sessionOpt
+
+	mu         sync.Mutex
+	transcript *Transcript
+}
+
+// SessionState carries conversation context between the turns of a Session.
+type SessionState interface {
+	// PrepareRequest adjusts a turn's request before it is sent.
+	PrepareRequest(req *Request)
+	// RecordResponse is called with each successful turn's response.
+	RecordResponse(res Response)
+	// Reset forgets all context.
+	Reset()
+}
+
+// SessionOption configures a Session.
+type SessionOption interface{ applySessionOpt(*sessionOpt) }
+
+type sessionOpt struct {
+	state        SessionState
+	template     Request
+	transcript   *Transcript
+	store        HistoryStore
+	conversation string
+	prices       PriceTable
+}
+
+type sessionOptFunc func(*sessionOpt)
+
+func (f sessionOptFunc) applySessionOpt(so *sessionOpt) { f(so) }
+
+// WithSessionState sets the strategy that carries context between turns.
+func WithSessionState(s SessionState) SessionOption {
+	return sessionOptFunc(func(so *sessionOpt) {
+		so.state = s
+	})
+}
+
+// WithSessionRequest sets the model, tier, priority, provider options, and
+// metadata used for every turn sent with Send. Its Inputs and Output are ignored.
+func WithSessionRequest(req Request) SessionOption {
+	return sessionOptFunc(func(so *sessionOpt) {
+		so.template = Request{
+			Model:           req.Model,
+			Tier:            req.Tier,
+			Priority:        req.Priority,
+			ProviderOptions: req.ProviderOptions,
+			Metadata:        copyMetadata(req.Metadata),
+		}
+	})
+}
+
+// WithSessionTranscript continues the conversation recorded in t, such as one
+// loaded with ImportTranscript or taken from another session: new turns are
+// appended to it. WithSessionHistory picks up the conversation from the
+// transcript; other state strategies start empty, and it's up to the caller
+// to restore any context they need.
+func WithSessionTranscript(t *Transcript) SessionOption {
+	return sessionOptFunc(func(so *sessionOpt) {
+		so.transcript = t
+	})
+}
+
+// NewSession starts a conversation with c.
+func NewSession(c Client, opts ...SessionOption) *Session {
+	s := &Session{client: c}
+	for _, opt := range opts {
+		if opt != nil {
+			opt.applySessionOpt(&s.opts)
+		}
+	}
+	s.transcript = s.opts.transcript
+	if s.transcript == nil {
+		s.transcript = NewTranscript()
+	} else if h, ok := s.opts.state.(*historyState); ok {
+		h.restore(s.transcript)
+	}
+	return s
+}
+
+// Send sends a user message and returns the text reply.
+func (s *Session) Send(ctx context.Context, inputs ...Input) (Response, error) {
+	req := s.opts.template
+	req.Inputs = inputs
+	req.Output = OutputText()
+	return s.Generate(ctx, req)
+}
+
+// Generate sends req as the next turn. Unlike Send, the request's own model,
+// output, and options are used as given.
+func (s *Session) Generate(ctx context.Context, req Request) (Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sent := req
+	sent.ProviderOptions = append([]ProviderOption(nil), req.ProviderOptions...)
+	if s.opts.store != nil {
+		history, err := s.opts.store.Load(ctx, s.opts.conversation)
+		if err != nil {
+			return Response{}, err
+		}
+		sent.History = append(history, req.History...)
+	}
+	if s.opts.state != nil {
+		s.opts.state.PrepareRequest(&sent)
+	}
+	res, err := s.client.Generate(ctx, sent)
+	if err != nil {
+		return res, err
+	}
+	if s.opts.state != nil {
+		s.opts.state.RecordResponse(res)
+	}
+	// Record what the caller asked for, not what the state strategy added.
+	s.transcript.Record(req, res)
+	if s.opts.prices != nil {
+		s.opts.prices.priceTurn(&s.transcript.Turns[len(s.transcript.Turns)-1])
+	}
+	if s.opts.store != nil {
+		if err := s.opts.store.Append(ctx, s.opts.conversation, turnMessages(req.Inputs, res)...); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// Transcript returns the session's transcript. It is shared with the session,
+// so it must not be modified while turns are in flight.
+func (s *Session) Transcript() *Transcript {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.transcript
+}
+
+// Reset starts a new conversation with an empty transcript.
+func (s *Session) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transcript = NewTranscript()
+	if s.opts.state != nil {
+		s.opts.state.Reset()
+	}
+}
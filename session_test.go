@@ -0,0 +1,88 @@
+package grail_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+type turnCounter struct {
+	prepared, recorded int
+}
+
+type turnOption struct{ Turn int }
+
+func (turnOption) ApplyProviderOption() {}
+
+func (c *turnCounter) PrepareRequest(req *grail.Request) {
+	c.prepared++
+	req.ProviderOptions = append(req.ProviderOptions, turnOption{Turn: c.recorded + 1})
+}
+
+func (c *turnCounter) RecordResponse(grail.Response) { c.recorded++ }
+func (c *turnCounter) Reset()                        { c.prepared, c.recorded = 0, 0 }
+
+func TestSession(t *testing.T) {
+	var turns []int
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			if req.Model != "session-model" {
+				t.Fatalf("expected session model, got %q", req.Model)
+			}
+			for _, opt := range req.ProviderOptions {
+				if to, ok := opt.(turnOption); ok {
+					turns = append(turns, to.Turn)
+				}
+			}
+			if text, _ := grail.AsTextInput(req.Inputs[0]); text == "fail" {
+				return grail.Response{}, grail.NewGrailError(grail.Unavailable, "down")
+			}
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("ok")}}, nil
+		},
+	}
+	state := &turnCounter{}
+	session := grail.NewSession(grail.NewClient(prov), grail.WithSessionState(state), grail.WithSessionRequest(grail.Request{Model: "session-model"}))
+
+	for _, msg := range []string{"one", "fail", "two"} {
+		_, err := session.Send(context.Background(), grail.InputText(msg))
+		if (msg == "fail") != (err != nil) {
+			t.Fatalf("%s: unexpected error %v", msg, err)
+		}
+	}
+	if len(turns) != 3 || turns[0] != 1 || turns[1] != 2 || turns[2] != 2 {
+		t.Fatalf("expected state to see turns 1, 2, 2, got %v", turns)
+	}
+	if state.recorded != 2 {
+		t.Fatalf("expected 2 recorded responses, got %d", state.recorded)
+	}
+	tr := session.Transcript()
+	if len(tr.Turns) != 4 {
+		t.Fatalf("expected failed turn to be left out of the transcript, got %d turns", len(tr.Turns))
+	}
+
+	session.Reset()
+	if len(session.Transcript().Turns) != 0 || state.recorded != 0 {
+		t.Fatalf("expected reset to clear the transcript and state")
+	}
+}
+
+func TestSessionTranscript(t *testing.T) {
+	prov := &mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("ok")}}, nil
+	}}
+	first := grail.NewSession(grail.NewClient(prov))
+	if _, err := first.Send(context.Background(), grail.InputText("one")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Continuing with another client appends to the same transcript.
+	second := grail.NewSession(grail.NewClient(prov), grail.WithSessionTranscript(first.Transcript()))
+	if _, err := second.Send(context.Background(), grail.InputText("two")); err != nil {
+		t.Fatal(err)
+	}
+	if tr := second.Transcript(); tr != first.Transcript() || len(tr.Turns) != 4 || tr.Turns[2].Parts[0].Text != "two" {
+		t.Fatalf("expected the second session to continue the transcript, got %+v", tr.Turns)
+	}
+}
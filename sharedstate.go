@@ -0,0 +1,105 @@
+package grail
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+//
+// Shared state
+//
+
+// SharedState is state kept outside the process, so that the replicas of a
+// service share rate limits (Scheduler.SetSharedState), budgets
+// (Budget.State), and cached responses (SemanticCache.State) instead of each
+// enforcing or filling its own. stores/redis implements it on Redis;
+// MemoryState keeps it in process.
+type SharedState interface {
+	// Incr adds delta to the number at key and returns the sum. A missing key
+	// starts at zero and expires after ttl (never if zero); incrementing an
+	// existing key doesn't extend its expiry.
+	Incr(ctx context.Context, key string, delta float64, ttl time.Duration) (float64, error)
+	// Push appends value to the list at key, keeps only its newest max
+	// values (all of them if max is zero), and sets the list to expire after
+	// ttl (never if zero).
+	Push(ctx context.Context, key string, value []byte, max int, ttl time.Duration) error
+	// List returns the values of the list at key, oldest first.
+	List(ctx context.Context, key string) ([][]byte, error)
+}
+
+// MemoryState is a SharedState held in memory, for a single process and for
+// tests.
+type MemoryState struct {
+	mu    sync.Mutex
+	nums  map[string]memoryNum
+	lists map[string]memoryList
+	now   func() time.Time
+}
+
+type memoryNum struct {
+	value   float64
+	expires time.Time
+}
+
+type memoryList struct {
+	values  [][]byte
+	expires time.Time
+}
+
+// NewMemoryState returns an empty MemoryState.
+func NewMemoryState() *MemoryState {
+	return &MemoryState{nums: map[string]memoryNum{}, lists: map[string]memoryList{}, now: time.Now}
+}
+
+func (m *MemoryState) expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return m.now().Add(ttl)
+}
+
+func (m *MemoryState) live(expires time.Time) bool {
+	return expires.IsZero() || m.now().Before(expires)
+}
+
+// Incr implements SharedState.
+func (m *MemoryState) Incr(ctx context.Context, key string, delta float64, ttl time.Duration) (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.nums[key]
+	if !ok || !m.live(n.expires) {
+		n = memoryNum{expires: m.expiry(ttl)}
+	}
+	n.value += delta
+	m.nums[key] = n
+	return n.value, nil
+}
+
+// Push implements SharedState.
+func (m *MemoryState) Push(ctx context.Context, key string, value []byte, max int, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l := m.lists[key]
+	if !m.live(l.expires) {
+		l.values = nil
+	}
+	l.values = append(l.values, append([]byte(nil), value...))
+	if max > 0 && len(l.values) > max {
+		l.values = l.values[len(l.values)-max:]
+	}
+	l.expires = m.expiry(ttl)
+	m.lists[key] = l
+	return nil
+}
+
+// List implements SharedState.
+func (m *MemoryState) List(ctx context.Context, key string) ([][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.lists[key]
+	if !ok || !m.live(l.expires) {
+		return nil, nil
+	}
+	return append([][]byte(nil), l.values...), nil
+}
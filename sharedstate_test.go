@@ -0,0 +1,38 @@
+package grail_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+)
+
+func TestMemoryState(t *testing.T) {
+	state := grail.NewMemoryState()
+	ctx := context.Background()
+
+	if n, _ := state.Incr(ctx, "n", 2, 0); n != 2 {
+		t.Errorf("expected 2, got %v", n)
+	}
+	if n, _ := state.Incr(ctx, "n", -0.5, 0); n != 1.5 {
+		t.Errorf("expected 1.5, got %v", n)
+	}
+	if n, _ := state.Incr(ctx, "short", 1, time.Nanosecond); n != 1 {
+		t.Errorf("expected 1, got %v", n)
+	}
+	time.Sleep(time.Millisecond)
+	if n, _ := state.Incr(ctx, "short", 1, time.Nanosecond); n != 1 {
+		t.Errorf("expected an expired counter to restart, got %v", n)
+	}
+
+	for _, v := range []string{"a", "b", "c"} {
+		state.Push(ctx, "l", []byte(v), 2, time.Hour)
+	}
+	if vals, _ := state.List(ctx, "l"); len(vals) != 2 || string(vals[0]) != "b" || string(vals[1]) != "c" {
+		t.Errorf("expected the newest 2 values, got %q", vals)
+	}
+	if vals, _ := state.List(ctx, "missing"); vals != nil {
+		t.Errorf("expected no values, got %q", vals)
+	}
+}
@@ -0,0 +1,103 @@
+package grail
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+//
+// Speech synthesis
+//
+
+// Speech is audio spoken from text.
+type Speech struct {
+	Data     []byte
+	MIME     string // such as "audio/mpeg", or "audio/pcm" for raw samples
+	Provider ProviderInfo
+}
+
+// SpeechRequest is what a SpeechSynthesizer is asked to say.
+type SpeechRequest struct {
+	Text         string
+	Model        string // empty for the provider's default
+	Voice        string // empty for the provider's default
+	Instructions string // how to speak, such as "calm and friendly"
+	Format       string // "mp3", "wav", "pcm", and so on; empty for the provider's default
+}
+
+// SpeechSynthesizer is an optional interface for providers with a
+// text-to-speech endpoint, used by Client.Speak.
+type SpeechSynthesizer interface {
+	SynthesizeSpeech(ctx context.Context, req SpeechRequest) (Speech, error)
+}
+
+// SpeakOpt configures Client.Speak.
+type SpeakOpt interface{ applySpeakOpt(*SpeechRequest) }
+
+type speakOptFunc func(*SpeechRequest)
+
+func (f speakOptFunc) applySpeakOpt(sr *SpeechRequest) { f(sr) }
+
+// WithSpeechModel picks the model that speaks, such as "gpt-4o-mini-tts"
+// for OpenAI.
+func WithSpeechModel(model string) SpeakOpt {
+	return speakOptFunc(func(sr *SpeechRequest) {
+		sr.Model = model
+	})
+}
+
+// WithVoice picks the voice, such as "alloy" for OpenAI.
+func WithVoice(voice string) SpeakOpt {
+	return speakOptFunc(func(sr *SpeechRequest) {
+		sr.Voice = voice
+	})
+}
+
+// WithSpeechInstructions describes how to speak, such as the tone, pace, and
+// accent, for models that take instructions.
+func WithSpeechInstructions(instructions string) SpeakOpt {
+	return speakOptFunc(func(sr *SpeechRequest) {
+		sr.Instructions = instructions
+	})
+}
+
+// WithSpeechFormat picks the audio format, such as "mp3", "wav", or "pcm"
+// for raw 16-bit samples a speaker can play as they arrive.
+func WithSpeechFormat(format string) SpeakOpt {
+	return speakOptFunc(func(sr *SpeechRequest) {
+		sr.Format = format
+	})
+}
+
+// Speak turns text into speech with the provider's text-to-speech endpoint
+// (openai.Provider). Providers without one fail with Unsupported.
+func (c *client) Speak(ctx context.Context, text string, opts ...SpeakOpt) (Speech, error) {
+	if err := c.life.enter(); err != nil {
+		return Speech{}, err
+	}
+	defer c.life.leave()
+
+	if strings.TrimSpace(text) == "" {
+		return Speech{}, NewGrailError(InvalidArgument, "text must not be empty")
+	}
+	sr := SpeechRequest{Text: text}
+	for _, opt := range opts {
+		if opt != nil {
+			opt.applySpeakOpt(&sr)
+		}
+	}
+	s, ok := c.provider.(SpeechSynthesizer)
+	if !ok {
+		name := c.provider.Name()
+		return Speech{}, NewGrailError(Unsupported, fmt.Sprintf("provider %s does not support speech synthesis", name)).WithProviderName(name)
+	}
+	speech, err := s.SynthesizeSpeech(c.withTransport(ctx), sr)
+	if err != nil {
+		return Speech{}, err
+	}
+	if speech.Provider.Name == "" {
+		speech.Provider.Name = c.provider.Name()
+	}
+	return speech, nil
+}
@@ -0,0 +1,104 @@
+package grail
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"sort"
+	"time"
+)
+
+//
+// Stale model detection
+//
+
+// LiveModelLister is an optional interface for providers that can ask their
+// API which models it serves, so configured models that have been retired
+// can be caught before requests start failing.
+type LiveModelLister interface {
+	// LiveModels returns the names of the models the API serves now.
+	LiveModels(ctx context.Context) ([]string, error)
+	// ConfiguredModels returns the models the provider uses by default,
+	// keyed by use ("text", "image", "best_text", and so on).
+	ConfiguredModels() map[string]string
+}
+
+// StaleModel is a configured model the provider no longer serves.
+type StaleModel struct {
+	Use   string // what the model is configured for, e.g. "text" or "default"
+	Model string
+}
+
+// StaleModels compares the models p is configured to use, and extra models
+// (keyed by use), with the models its API serves, and returns the configured
+// models that are missing, sorted by use. Providers that don't implement
+// LiveModelLister are reported as Unsupported.
+func StaleModels(ctx context.Context, p Provider, extra map[string]string) ([]StaleModel, error) {
+	lister, ok := p.(LiveModelLister)
+	if !ok {
+		return nil, NewGrailError(Unsupported, "provider "+p.Name()+" does not support live model listing")
+	}
+	live, err := lister.LiveModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	served := make(map[string]bool, len(live))
+	for _, m := range live {
+		served[m] = true
+	}
+	configured := maps.Clone(lister.ConfiguredModels())
+	if configured == nil {
+		configured = map[string]string{}
+	}
+	for use, m := range extra {
+		configured[use] = m
+	}
+	var stale []StaleModel
+	for use, m := range configured {
+		if m != "" && !served[m] {
+			stale = append(stale, StaleModel{Use: use, Model: m})
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Use < stale[j].Use })
+	return stale, nil
+}
+
+// WithStaleModelCheck checks, in the background on the client's first
+// Generate call, that the provider still serves the models it's configured
+// with and the client's default model, logging a warning for each one that
+// has been retired. Checks that fail, and providers that don't implement
+// LiveModelLister, are logged at debug level. Child clients share the
+// parent's check. It's skipped on clients without a logger, and in air-gap
+// mode (see WithAirGap).
+func WithStaleModelCheck() ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.staleModelCheck = true
+	})
+}
+
+func (c *client) checkModelsOnce() {
+	// The check only logs, so there's nothing to do without a logger.
+	if !c.opts.staleModelCheck || c.opts.airGap != nil || c.provider == nil || c.log == nil {
+		return
+	}
+	c.modelCheck.Do(func() {
+		extra := map[string]string{}
+		if c.defaults != nil && c.defaults.Model != "" {
+			extra["default"] = c.defaults.Model
+		}
+		log := c.log
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			stale, err := StaleModels(ctx, c.provider, extra)
+			if err != nil {
+				log.Debug("stale model check failed", slog.String("provider", c.provider.Name()), slog.String("error", err.Error()))
+				return
+			}
+			for _, s := range stale {
+				log.Warn("configured model is not served by provider; it may have been retired",
+					slog.String("provider", c.provider.Name()), slog.String("use", s.Use), slog.String("model", s.Model))
+			}
+		}()
+	})
+}
@@ -0,0 +1,95 @@
+package grail_test
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+type liveProvider struct {
+	mock.Provider
+	live  []string
+	calls int
+}
+
+func (p *liveProvider) LiveModels(ctx context.Context) ([]string, error) {
+	p.calls++
+	return p.live, nil
+}
+
+func (p *liveProvider) ConfiguredModels() map[string]string {
+	return map[string]string{"text": "model-a", "image": "model-old", "fast_text": ""}
+}
+
+// lineWriter sends each log line to a channel.
+type lineWriter chan string
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestStaleModels(t *testing.T) {
+	prov := &liveProvider{
+		Provider: mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("ok")}}, nil
+		}},
+		live: []string{"model-a", "model-b"},
+	}
+
+	stale, err := grail.StaleModels(context.Background(), prov, map[string]string{"pinned": "model-b"})
+	if err != nil || len(stale) != 1 || stale[0] != (grail.StaleModel{Use: "image", Model: "model-old"}) {
+		t.Fatalf("expected only the retired image model, got %+v (%v)", stale, err)
+	}
+	if _, err := grail.StaleModels(context.Background(), &mock.Provider{}, nil); grail.GetErrorCode(err) != grail.Unsupported {
+		t.Fatalf("expected Unsupported without live listing, got %v", err)
+	}
+
+	lines := make(lineWriter, 10)
+	client := grail.NewClient(prov,
+		grail.WithStaleModelCheck(),
+		grail.WithDefaultModel("model-gone"),
+		grail.WithLogger(slog.New(slog.NewTextHandler(lines, &slog.HandlerOptions{Level: slog.LevelWarn}))),
+	)
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText(), Model: "model-a"}
+	for range 2 {
+		if _, err := client.With().Generate(context.Background(), req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	var warned []string
+	for range 2 {
+		select {
+		case line := <-lines:
+			warned = append(warned, line)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for stale model warnings, got %q", warned)
+		}
+	}
+	if !strings.Contains(warned[0], "use=default model=model-gone") || !strings.Contains(warned[1], "use=image model=model-old") {
+		t.Fatalf("unexpected warnings %q", warned)
+	}
+	if prov.calls != 2 {
+		t.Fatalf("expected one background check shared with children, got %d listings", prov.calls-1)
+	}
+}
+
+func TestStaleModelsWithoutLogger(t *testing.T) {
+	prov := &liveProvider{Provider: mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("ok")}}, nil
+	}}}
+	client := grail.NewClient(prov, grail.WithStaleModelCheck(), grail.WithLogger(nil))
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()}
+	if _, err := client.Generate(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if prov.calls != 0 {
+		t.Fatalf("expected no check without a logger, got %d listings", prov.calls)
+	}
+}
@@ -0,0 +1,141 @@
+package grail
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+//
+// Client statistics
+//
+
+// CircuitState is the state of a provider's circuit breaker.
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"    // calls flow normally
+	CircuitOpen     CircuitState = "open"      // calls are rejected
+	CircuitHalfOpen CircuitState = "half_open" // trial calls are let through
+)
+
+// CircuitReporter is an optional interface for providers (or provider
+// wrappers) that guard calls with a circuit breaker.
+type CircuitReporter interface {
+	CircuitState() CircuitState
+}
+
+// ClientStats is a snapshot of a client's activity since it was created.
+// Child clients from With share their parent's statistics.
+type ClientStats struct {
+	Provider string            `json:"provider"`
+	Closed   bool              `json:"closed"`
+	InFlight int               `json:"in_flight"`
+	Queued   int               `json:"queued,omitempty"` // waiting on a Scheduler
+	Requests int               `json:"requests"`
+	Errors   map[ErrorCode]int `json:"errors,omitempty"`
+	Usage    Usage             `json:"usage"`
+	Cost     *CostLine         `json:"cost,omitempty"` // with a UsageTracker
+	Latency  LatencyStats      `json:"latency"`
+	Circuit  CircuitState      `json:"circuit,omitempty"` // with a CircuitReporter
+}
+
+// LatencyStats summarizes the duration of recent Generate calls.
+type LatencyStats struct {
+	Samples int           `json:"samples"`
+	Mean    time.Duration `json:"mean_ns"`
+	P50     time.Duration `json:"p50_ns"`
+	P95     time.Duration `json:"p95_ns"`
+	P99     time.Duration `json:"p99_ns"`
+	Max     time.Duration `json:"max_ns"`
+}
+
+// latencyWindow is how many recent calls latency percentiles cover.
+const latencyWindow = 1024
+
+type clientStats struct {
+	mu        sync.Mutex
+	requests  int
+	errors    map[ErrorCode]int
+	usage     Usage
+	latencies []time.Duration // ring buffer of the last latencyWindow calls
+	next      int
+}
+
+func (s *clientStats) record(d time.Duration, res Response, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if err != nil {
+		if s.errors == nil {
+			s.errors = map[ErrorCode]int{}
+		}
+		s.errors[GetErrorCode(err)]++
+	} else {
+		s.usage = s.usage.Add(res.Usage)
+	}
+	if len(s.latencies) < latencyWindow {
+		s.latencies = append(s.latencies, d)
+	} else {
+		s.latencies[s.next] = d
+		s.next = (s.next + 1) % latencyWindow
+	}
+}
+
+// Stats returns a snapshot of the client's activity.
+func (c *client) Stats() ClientStats {
+	c.stats.mu.Lock()
+	st := ClientStats{
+		Requests: c.stats.requests,
+		Usage:    c.stats.usage,
+		Latency:  latencyStats(slices.Clone(c.stats.latencies)),
+	}
+	if len(c.stats.errors) > 0 {
+		st.Errors = make(map[ErrorCode]int, len(c.stats.errors))
+		for code, n := range c.stats.errors {
+			st.Errors[code] = n
+		}
+	}
+	c.stats.mu.Unlock()
+
+	c.life.mu.Lock()
+	st.Closed, st.InFlight = c.life.closed, c.life.inflight
+	c.life.mu.Unlock()
+
+	if c.provider != nil {
+		st.Provider = c.provider.Name()
+	}
+	if c.scheduler != nil {
+		st.Queued = c.scheduler.Queued()
+	}
+	if c.usageTracker != nil {
+		total := c.usageTracker.Report(Period{}).Total
+		st.Cost = &total
+	}
+	if cr, ok := c.provider.(CircuitReporter); ok {
+		st.Circuit = cr.CircuitState()
+	}
+	return st
+}
+
+func latencyStats(ds []time.Duration) LatencyStats {
+	if len(ds) == 0 {
+		return LatencyStats{}
+	}
+	slices.Sort(ds)
+	var sum time.Duration
+	for _, d := range ds {
+		sum += d
+	}
+	at := func(p float64) time.Duration {
+		return ds[int(p*float64(len(ds)-1))]
+	}
+	return LatencyStats{
+		Samples: len(ds),
+		Mean:    sum / time.Duration(len(ds)),
+		P50:     at(0.50),
+		P95:     at(0.95),
+		P99:     at(0.99),
+		Max:     ds[len(ds)-1],
+	}
+}
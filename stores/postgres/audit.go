@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/montanaflynn/grail"
+)
+
+// Record implements grail.AuditLog.
+func (s *Store) Record(ctx context.Context, e grail.AuditEntry) error {
+	metadata, err := json.Marshal(e.Metadata)
+	if err != nil {
+		return storeError("record audit entry", err)
+	}
+	usage, err := json.Marshal(e.Usage)
+	if err != nil {
+		return storeError("record audit entry", err)
+	}
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO `+AuditTable+`
+		(time, duration_ms, key, provider, model, request_id, metadata, usage, code, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		e.Time, float64(e.Duration)/float64(time.Millisecond), e.Key, e.Provider, e.Model, e.RequestID,
+		metadata, usage, string(e.Code), e.Error); err != nil {
+		return storeError("record audit entry", err)
+	}
+	return nil
+}
+
+// AuditEntries returns the audit entries recorded in period, oldest first.
+func (s *Store) AuditEntries(ctx context.Context, period grail.Period) ([]grail.AuditEntry, error) {
+	var (
+		where []string
+		args  []any
+	)
+	if !period.Start.IsZero() {
+		args = append(args, period.Start)
+		where = append(where, fmt.Sprintf("time >= $%d", len(args)))
+	}
+	if !period.End.IsZero() {
+		args = append(args, period.End)
+		where = append(where, fmt.Sprintf("time < $%d", len(args)))
+	}
+	query := `SELECT time, duration_ms, key, provider, model, request_id, metadata, usage, code, error FROM ` + AuditTable
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	rows, err := s.db.QueryContext(ctx, query+` ORDER BY time, id`, args...)
+	if err != nil {
+		return nil, storeError("list audit entries", err)
+	}
+	defer rows.Close()
+	var entries []grail.AuditEntry
+	for rows.Next() {
+		var (
+			e               grail.AuditEntry
+			ms              float64
+			code            string
+			metadata, usage []byte
+		)
+		if err := rows.Scan(&e.Time, &ms, &e.Key, &e.Provider, &e.Model, &e.RequestID, &metadata, &usage, &code, &e.Error); err != nil {
+			return nil, storeError("list audit entries", err)
+		}
+		if err := json.Unmarshal(metadata, &e.Metadata); err != nil {
+			return nil, storeError("list audit entries", err)
+		}
+		if err := json.Unmarshal(usage, &e.Usage); err != nil {
+			return nil, storeError("list audit entries", err)
+		}
+		e.Duration = time.Duration(ms * float64(time.Millisecond))
+		e.Code = grail.ErrorCode(code)
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storeError("list audit entries", err)
+	}
+	return entries, nil
+}
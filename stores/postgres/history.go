@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/montanaflynn/grail"
+)
+
+// Append implements grail.HistoryStore.
+func (s *Store) Append(ctx context.Context, conversation string, msgs ...grail.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	rows := make([][]byte, len(msgs))
+	for i, m := range msgs {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("postgres: message %d: %v", i, err)).WithCause(err)
+		}
+		rows[i] = data
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storeError("append", err)
+	}
+	defer tx.Rollback()
+	// Appends to one conversation take turns, so they don't race for the
+	// next sequence number.
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, conversation); err != nil {
+		return storeError("append", err)
+	}
+	var last int64
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(seq), 0) FROM `+MessagesTable+` WHERE conversation = $1`, conversation).Scan(&last); err != nil {
+		return storeError("append", err)
+	}
+	for i, row := range rows {
+		seq := last + int64(i) + 1
+		if row, err = seal(s.keys, row, messageAAD(conversation, seq)); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO `+MessagesTable+` (conversation, seq, message) VALUES ($1, $2, $3)`,
+			conversation, seq, row); err != nil {
+			return storeError("append", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return storeError("append", err)
+	}
+	return nil
+}
+
+// Load implements grail.HistoryStore.
+func (s *Store) Load(ctx context.Context, conversation string) ([]grail.Message, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT seq, message FROM `+MessagesTable+` WHERE conversation = $1 ORDER BY seq`, conversation)
+	if err != nil {
+		return nil, storeError("load", err)
+	}
+	defer rows.Close()
+	var msgs []grail.Message
+	for rows.Next() {
+		var (
+			seq  int64
+			data []byte
+		)
+		if err := rows.Scan(&seq, &data); err != nil {
+			return nil, storeError("load", err)
+		}
+		data, err := open(s.keys, data, messageAAD(conversation, seq), fmt.Sprintf("conversation %q", conversation))
+		if err != nil {
+			return nil, err
+		}
+		var m grail.Message
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, storeError("load", err)
+		}
+		msgs = append(msgs, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storeError("load", err)
+	}
+	return msgs, nil
+}
+
+// Trim implements grail.HistoryStore.
+func (s *Store) Trim(ctx context.Context, conversation string, keep int) error {
+	var err error
+	if keep <= 0 {
+		_, err = s.db.ExecContext(ctx, `DELETE FROM `+MessagesTable+` WHERE conversation = $1`, conversation)
+	} else {
+		_, err = s.db.ExecContext(ctx, `DELETE FROM `+MessagesTable+` WHERE conversation = $1 AND seq <= (
+			SELECT MAX(seq) - $2 FROM `+MessagesTable+` WHERE conversation = $1)`, conversation, keep)
+	}
+	if err != nil {
+		return storeError("trim", err)
+	}
+	return nil
+}
+
+// Conversations returns the IDs of the stored conversations, sorted.
+func (s *Store) Conversations(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT conversation FROM `+MessagesTable+` ORDER BY conversation`)
+	if err != nil {
+		return nil, storeError("list conversations", err)
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, storeError("list conversations", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storeError("list conversations", err)
+	}
+	return ids, nil
+}
+
+func messageAAD(conversation string, seq int64) string {
+	return fmt.Sprintf("grail/history\x00%s\x00%d", conversation, seq)
+}
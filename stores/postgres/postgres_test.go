@@ -0,0 +1,294 @@
+package postgres_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/stores/postgres"
+)
+
+// open connects to the database in GRAIL_POSTGRES_URL, skipping the test if
+// it isn't set. Tests use IDs of their own, so they can share a database.
+func open(t *testing.T, opts ...postgres.Option) (*postgres.Store, string) {
+	t.Helper()
+	url := os.Getenv("GRAIL_POSTGRES_URL")
+	if url == "" {
+		t.Skip("GRAIL_POSTGRES_URL not set")
+	}
+	store, err := postgres.Open(context.Background(), url, opts...)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store, fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano())
+}
+
+func TestStore(t *testing.T) {
+	store, id := open(t)
+	ctx := context.Background()
+
+	// Migrating again is a no-op.
+	again, err := postgres.Open(ctx, os.Getenv("GRAIL_POSTGRES_URL"))
+	if err != nil {
+		t.Fatalf("Open again: %v", err)
+	}
+	again.Close()
+
+	err = store.Append(ctx, id,
+		grail.UserMessage(grail.InputText("My name is Ada."), grail.InputFile([]byte("notes"), "text/plain")),
+		grail.AssistantMessage("Nice to meet you, Ada!"),
+	)
+	if err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	msgs, err := store.Load(ctx, id)
+	if err != nil || len(msgs) != 2 {
+		t.Fatalf("Load = %d messages, %v", len(msgs), err)
+	}
+	if data, mime, _, ok := grail.AsFileInput(msgs[0].Inputs[1]); !ok || string(data) != "notes" || mime != "text/plain" {
+		t.Errorf("file = %q %q", data, mime)
+	}
+	if err := store.Trim(ctx, id, 1); err != nil {
+		t.Fatalf("Trim: %v", err)
+	}
+	if msgs, _ := store.Load(ctx, id); len(msgs) != 1 || msgs[0].Role != grail.TurnAssistant {
+		t.Errorf("after Trim = %+v", msgs)
+	}
+	if err := store.Trim(ctx, id, 0); err != nil {
+		t.Fatalf("Trim: %v", err)
+	}
+	if msgs, _ := store.Load(ctx, id); len(msgs) != 0 {
+		t.Errorf("after Trim(0) = %d messages", len(msgs))
+	}
+}
+
+func TestStore_ConcurrentAppend(t *testing.T) {
+	store, id := open(t)
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() {
+			if err := store.Append(ctx, id, grail.UserMessage(grail.InputText("hi")), grail.AssistantMessage("hello")); err != nil {
+				t.Errorf("Append: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+	if msgs, err := store.Load(ctx, id); err != nil || len(msgs) != 40 {
+		t.Errorf("Load = %d messages, %v", len(msgs), err)
+	}
+}
+
+func TestReviewStore(t *testing.T) {
+	store, id := open(t)
+	reviews := store.Reviews(nil)
+	ctx := context.Background()
+
+	item := grail.ReviewItem{
+		ID:       id,
+		Reasons:  []string{"low confidence: total"},
+		Request:  grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()},
+		Response: grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("hello")}},
+		Err:      grail.NewGrailError(grail.Refused, "no").WithDetail("reason", "policy"),
+		Status:   grail.ReviewPending,
+		Created:  time.Now(),
+	}
+	if err := reviews.Put(ctx, item); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	got, err := reviews.Get(ctx, id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if text, _ := got.Response.Text(); text != "hello" || len(got.Reasons) != 1 || got.Status != grail.ReviewPending {
+		t.Errorf("unexpected item %+v", got)
+	}
+	if prompt, _ := grail.AsTextInput(got.Request.Inputs[0]); prompt != "hi" {
+		t.Errorf("unexpected request %+v", got.Request)
+	}
+	if got.Err == nil || got.Err.Error() != item.Err.Error() {
+		t.Errorf("expected %v, got %v", item.Err, got.Err)
+	}
+	if !pending(t, reviews, id) {
+		t.Error("expected the item to be pending")
+	}
+
+	// Of concurrent decisions, exactly one wins.
+	var (
+		wg      sync.WaitGroup
+		decided atomic.Int32
+	)
+	for range 5 {
+		wg.Go(func() {
+			_, err := reviews.Decide(ctx, id, grail.ReviewApproved, "fine", time.Now())
+			switch {
+			case err == nil:
+				decided.Add(1)
+			case grail.GetErrorCode(err) != grail.InvalidArgument:
+				t.Errorf("Decide: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+	if decided.Load() != 1 {
+		t.Errorf("expected one decision to win, got %d", decided.Load())
+	}
+	if got, _ := reviews.Get(ctx, id); got.Status != grail.ReviewApproved || got.Note != "fine" || got.Decided.IsZero() {
+		t.Errorf("unexpected decided item %+v", got)
+	}
+	if pending(t, reviews, id) {
+		t.Error("expected the decided item not to be pending")
+	}
+	if _, err := reviews.Get(ctx, id+"-missing"); grail.GetErrorCode(err) != grail.NotFound {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+}
+
+func pending(t *testing.T, reviews grail.ReviewStore, id string) bool {
+	t.Helper()
+	items, err := reviews.Pending(context.Background())
+	if err != nil {
+		t.Fatalf("Pending: %v", err)
+	}
+	for _, item := range items {
+		if item.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestResultStore(t *testing.T) {
+	store, id := open(t)
+	results := store.Results()
+	ctx := context.Background()
+
+	if _, ok, err := results.Get(ctx, id); ok || err != nil {
+		t.Fatalf("expected no result, got %v %v", ok, err)
+	}
+	rec := grail.NewResponseRecord(grail.Response{
+		Outputs: []grail.OutputPart{grail.NewTextOutputPart("hello")},
+		Usage:   grail.Usage{TotalTokens: 3},
+	})
+	if err := results.Put(ctx, id, rec); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	got, ok, err := results.Get(ctx, id)
+	if !ok || err != nil {
+		t.Fatalf("Get = %v, %v", ok, err)
+	}
+	if text, _ := got.Response().Text(); text != "hello" || got.Usage.TotalTokens != 3 {
+		t.Errorf("unexpected result %+v", got)
+	}
+}
+
+func TestStore_Encryption(t *testing.T) {
+	keys := grail.StaticKey(bytes.Repeat([]byte{8}, 32))
+	store, id := open(t, postgres.WithEncryption(keys))
+	plain, _ := open(t)
+	ctx := context.Background()
+
+	if err := store.Append(ctx, id, grail.UserMessage(grail.InputText("My secret is Ada.")), grail.AssistantMessage("Hi, Ada!")); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if msgs, err := store.Load(ctx, id); err != nil || len(msgs) != 2 {
+		t.Fatalf("Load = %d messages, %v", len(msgs), err)
+	}
+	item := grail.ReviewItem{
+		ID:       id,
+		Request:  grail.Request{Inputs: []grail.Input{grail.InputText("a secret")}, Output: grail.OutputText()},
+		Response: grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("a secret answer")}},
+		Status:   grail.ReviewPending,
+		Created:  time.Now(),
+	}
+	if err := store.Reviews(nil).Put(ctx, item); err != nil {
+		t.Fatalf("Put review: %v", err)
+	}
+	if got, err := store.Reviews(nil).Get(ctx, id); err != nil || got.Status != grail.ReviewPending {
+		t.Fatalf("Get review = %+v, %v", got, err)
+	}
+	rec := grail.NewResponseRecord(grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("a secret result")}})
+	if err := store.Results().Put(ctx, id, rec); err != nil {
+		t.Fatalf("Put result: %v", err)
+	}
+	if got, ok, err := store.Results().Get(ctx, id); !ok || err != nil {
+		t.Fatalf("Get result = %+v, %v, %v", got, ok, err)
+	}
+
+	db, err := sql.Open("pgx", os.Getenv("GRAIL_POSTGRES_URL"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, q := range []string{
+		`SELECT COUNT(*) FROM ` + postgres.MessagesTable + ` WHERE conversation = $1 AND message::text LIKE '%secret%'`,
+		`SELECT COUNT(*) FROM ` + postgres.ReviewsTable + ` WHERE id = $1 AND item::text LIKE '%secret%'`,
+		`SELECT COUNT(*) FROM ` + postgres.ResultsTable + ` WHERE key = $1 AND response::text LIKE '%secret%'`,
+	} {
+		var n int
+		if err := db.QueryRowContext(ctx, q, id).Scan(&n); err != nil || n != 0 {
+			t.Errorf("%s: %d rows in plaintext, %v", q, n, err)
+		}
+	}
+
+	// Without keys, encrypted rows can't be read; with them, plaintext rows
+	// can't.
+	if _, err := plain.Load(ctx, id); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected loading without keys to fail, got %v", err)
+	}
+	if _, err := plain.Reviews(nil).Get(ctx, id); err == nil {
+		t.Error("expected reading a review without keys to fail")
+	}
+	if _, _, err := plain.Results().Get(ctx, id); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected reading a result without keys to fail, got %v", err)
+	}
+	if err := plain.Append(ctx, id+"-plain", grail.UserMessage(grail.InputText("hi"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load(ctx, id+"-plain"); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected a plaintext message to fail, got %v", err)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	store, id := open(t)
+	ctx := context.Background()
+	start := time.Now().Truncate(time.Microsecond) // Postgres keeps microseconds
+
+	entries := []grail.AuditEntry{
+		{Time: start, Duration: 1500 * time.Millisecond, Key: id, Provider: "mock", Model: "m", Metadata: map[string]string{"tenant": "acme"}, Usage: grail.Usage{TotalTokens: 4}},
+		{Time: start.Add(time.Millisecond), Key: id, Code: grail.Refused, Error: "refused: no"},
+	}
+	for _, e := range entries {
+		if err := store.Record(ctx, e); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	got, err := store.AuditEntries(ctx, grail.Period{Start: start, End: start.Add(time.Second)})
+	if err != nil {
+		t.Fatalf("AuditEntries: %v", err)
+	}
+	var mine []grail.AuditEntry
+	for _, e := range got {
+		if e.Key == id {
+			mine = append(mine, e)
+		}
+	}
+	if len(mine) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(mine))
+	}
+	if e := mine[0]; e.Duration != 1500*time.Millisecond || e.Metadata["tenant"] != "acme" || e.Usage.TotalTokens != 4 {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if e := mine[1]; e.Code != grail.Refused || e.Error != "refused: no" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+}
@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/montanaflynn/grail"
+)
+
+// ResultStore is a grail.ResultStore in a Store's database, for
+// grail.Activity. It is safe for concurrent use.
+type ResultStore struct {
+	db   *sql.DB
+	keys grail.KeyProvider
+}
+
+var _ grail.ResultStore = (*ResultStore)(nil)
+
+// Results returns the store's grail.ResultStore.
+func (s *Store) Results() *ResultStore {
+	return &ResultStore{db: s.db, keys: s.keys}
+}
+
+// Get implements grail.ResultStore.
+func (r *ResultStore) Get(ctx context.Context, key string) (grail.ResponseRecord, bool, error) {
+	var data []byte
+	err := r.db.QueryRowContext(ctx, `SELECT response FROM `+ResultsTable+` WHERE key = $1`, key).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return grail.ResponseRecord{}, false, nil
+	}
+	if err != nil {
+		return grail.ResponseRecord{}, false, storeError("get result", err)
+	}
+	data, err = open(r.keys, data, resultAAD(key), fmt.Sprintf("result %q", key))
+	if err != nil {
+		return grail.ResponseRecord{}, false, err
+	}
+	var res grail.ResponseRecord
+	if err := json.Unmarshal(data, &res); err != nil {
+		return grail.ResponseRecord{}, false, storeError("get result", err)
+	}
+	return res, true, nil
+}
+
+// Put implements grail.ResultStore, replacing any result under key.
+func (r *ResultStore) Put(ctx context.Context, key string, res grail.ResponseRecord) error {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("postgres: result %q: %v", key, err)).WithCause(err)
+	}
+	if data, err = seal(r.keys, data, resultAAD(key)); err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, `INSERT INTO `+ResultsTable+` (key, response) VALUES ($1, $2)
+		ON CONFLICT (key) DO UPDATE SET response = EXCLUDED.response, created_at = now()`, key, data); err != nil {
+		return storeError("put result", err)
+	}
+	return nil
+}
+
+func resultAAD(key string) string {
+	return "grail/result\x00" + key
+}
@@ -0,0 +1,208 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/montanaflynn/grail"
+)
+
+// ReviewStore is a grail.ReviewStore in a Store's database. It is safe for
+// concurrent use.
+type ReviewStore struct {
+	db      *sql.DB
+	options grail.OptionDecoder
+	keys    grail.KeyProvider
+}
+
+var _ grail.ReviewStore = (*ReviewStore)(nil)
+
+// Reviews returns the store's grail.ReviewStore. Requests are saved as
+// grail.RequestRecords, so their provider options must survive a JSON round
+// trip; options decodes them when items are read, and may be nil if requests
+// have none.
+func (s *Store) Reviews(options grail.OptionDecoder) *ReviewStore {
+	return &ReviewStore{db: s.db, options: options, keys: s.keys}
+}
+
+// reviewJSON is the part of a review item kept as JSON.
+type reviewJSON struct {
+	Reasons  []string             `json:"reasons,omitempty"`
+	Request  grail.RequestRecord  `json:"request"`
+	Response grail.ResponseRecord `json:"response"`
+	Error    *reviewError         `json:"error,omitempty"`
+	Note     string               `json:"note,omitempty"`
+}
+
+type reviewError struct {
+	Code      grail.ErrorCode   `json:"code"`
+	Message   string            `json:"message"`
+	Retryable bool              `json:"retryable,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// Put implements grail.ReviewStore. The item's error is kept as a
+// grail.GrailError with the same code, message, and details.
+func (r *ReviewStore) Put(ctx context.Context, item grail.ReviewItem) error {
+	return r.put(ctx, r.db, item)
+}
+
+// Decide implements grail.ReviewStore. The item's row is locked until the
+// decision is saved.
+func (r *ReviewStore) Decide(ctx context.Context, id string, status grail.ReviewStatus, note string, decided time.Time) (grail.ReviewItem, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return grail.ReviewItem{}, storeError("decide review", err)
+	}
+	defer tx.Rollback()
+	row := tx.QueryRowContext(ctx, `SELECT id, status, item, created_at, decided_at FROM `+ReviewsTable+` WHERE id = $1 FOR UPDATE`, id)
+	item, err := r.scan(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return grail.ReviewItem{}, grail.NewGrailError(grail.NotFound, fmt.Sprintf("review %q not found", id))
+	}
+	if err != nil {
+		return grail.ReviewItem{}, storeError("decide review", err)
+	}
+	if item.Status != grail.ReviewPending {
+		return grail.ReviewItem{}, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("review %q is already %s", id, item.Status))
+	}
+	item.Status, item.Note, item.Decided = status, note, decided
+	if err := r.put(ctx, tx, item); err != nil {
+		return grail.ReviewItem{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return grail.ReviewItem{}, storeError("decide review", err)
+	}
+	return item, nil
+}
+
+// execer is a *sql.DB or *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// put saves item through db, the store's database or a transaction in it.
+func (r *ReviewStore) put(ctx context.Context, db execer, item grail.ReviewItem) error {
+	req, err := grail.NewRequestRecord(item.Request)
+	if err != nil {
+		return err
+	}
+	rec := reviewJSON{
+		Reasons:  item.Reasons,
+		Request:  req,
+		Response: grail.NewResponseRecord(item.Response),
+		Note:     item.Note,
+	}
+	if item.Err != nil {
+		code := grail.GetErrorCode(item.Err)
+		rec.Error = &reviewError{Code: code, Message: strings.TrimPrefix(item.Err.Error(), string(code)+": ")}
+		var ge grail.GrailError
+		if errors.As(item.Err, &ge) {
+			rec.Error.Retryable = ge.Retryable()
+			rec.Error.Details = ge.Details()
+		}
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("postgres: review %q: %v", item.ID, err)).WithCause(err)
+	}
+	if data, err = seal(r.keys, data, reviewAAD(item.ID)); err != nil {
+		return err
+	}
+	var decided sql.NullTime
+	if !item.Decided.IsZero() {
+		decided = sql.NullTime{Time: item.Decided, Valid: true}
+	}
+	_, err = db.ExecContext(ctx, `INSERT INTO `+ReviewsTable+` (id, status, item, created_at, decided_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO UPDATE SET status = EXCLUDED.status, item = EXCLUDED.item,
+			created_at = EXCLUDED.created_at, decided_at = EXCLUDED.decided_at`,
+		item.ID, string(item.Status), data, item.Created, decided)
+	if err != nil {
+		return storeError("put review", err)
+	}
+	return nil
+}
+
+// Get implements grail.ReviewStore.
+func (r *ReviewStore) Get(ctx context.Context, id string) (grail.ReviewItem, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT id, status, item, created_at, decided_at FROM `+ReviewsTable+` WHERE id = $1`, id)
+	item, err := r.scan(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return grail.ReviewItem{}, grail.NewGrailError(grail.NotFound, fmt.Sprintf("review %q not found", id))
+	}
+	if err != nil {
+		return grail.ReviewItem{}, storeError("get review", err)
+	}
+	return item, nil
+}
+
+// Pending implements grail.ReviewStore.
+func (r *ReviewStore) Pending(ctx context.Context) ([]grail.ReviewItem, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, status, item, created_at, decided_at FROM `+ReviewsTable+`
+		WHERE status = $1 ORDER BY created_at`, string(grail.ReviewPending))
+	if err != nil {
+		return nil, storeError("list pending reviews", err)
+	}
+	defer rows.Close()
+	var items []grail.ReviewItem
+	for rows.Next() {
+		item, err := r.scan(rows)
+		if err != nil {
+			return nil, storeError("list pending reviews", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storeError("list pending reviews", err)
+	}
+	return items, nil
+}
+
+func (r *ReviewStore) scan(row interface{ Scan(...any) error }) (grail.ReviewItem, error) {
+	var (
+		item    grail.ReviewItem
+		status  string
+		data    []byte
+		decided sql.NullTime
+	)
+	if err := row.Scan(&item.ID, &status, &data, &item.Created, &decided); err != nil {
+		return grail.ReviewItem{}, err
+	}
+	data, err := open(r.keys, data, reviewAAD(item.ID), fmt.Sprintf("review %q", item.ID))
+	if err != nil {
+		return grail.ReviewItem{}, err
+	}
+	var rec reviewJSON
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return grail.ReviewItem{}, err
+	}
+	req, err := rec.Request.Request(r.options)
+	if err != nil {
+		return grail.ReviewItem{}, err
+	}
+	item.Status = grail.ReviewStatus(status)
+	item.Reasons = rec.Reasons
+	item.Request = req
+	item.Response = rec.Response.Response()
+	item.Note = rec.Note
+	if rec.Error != nil {
+		ge := grail.NewGrailError(rec.Error.Code, rec.Error.Message).WithRetryable(rec.Error.Retryable)
+		for k, v := range rec.Error.Details {
+			ge = ge.WithDetail(k, v)
+		}
+		item.Err = ge
+	}
+	if decided.Valid {
+		item.Decided = decided.Time
+	}
+	return item, nil
+}
+
+func reviewAAD(id string) string {
+	return "grail/review\x00" + id
+}
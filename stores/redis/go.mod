@@ -0,0 +1,20 @@
+module github.com/montanaflynn/grail/stores/redis
+
+go 1.25.0
+
+require (
+	github.com/alicebob/miniredis/v2 v2.37.0
+	github.com/montanaflynn/grail v0.0.0
+	github.com/redis/go-redis/v9 v9.17.2
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+	golang.org/x/image v0.38.0 // indirect
+	golang.org/x/net v0.53.0 // indirect
+	golang.org/x/text v0.36.0 // indirect
+)
+
+replace github.com/montanaflynn/grail => ../..
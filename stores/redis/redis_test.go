@@ -0,0 +1,88 @@
+package redis_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+	"github.com/montanaflynn/grail/stores/redis"
+)
+
+func TestState(t *testing.T) {
+	mr := miniredis.RunT(t)
+	state, err := redis.Open("redis://"+mr.Addr(), "test:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+	ctx := context.Background()
+
+	if n, err := state.Incr(ctx, "n", 1.5, time.Minute); err != nil || n != 1.5 {
+		t.Fatalf("expected 1.5, got %v (%v)", n, err)
+	}
+	if n, _ := state.Incr(ctx, "n", 2, time.Hour); n != 3.5 {
+		t.Errorf("expected 3.5, got %v", n)
+	}
+	if ttl := mr.TTL("test:n"); ttl != time.Minute {
+		t.Errorf("expected the first increment to set the expiry, got %v", ttl)
+	}
+	mr.FastForward(time.Minute)
+	if n, _ := state.Incr(ctx, "n", 1, time.Minute); n != 1 {
+		t.Errorf("expected an expired counter to restart, got %v", n)
+	}
+
+	for _, v := range []string{"a", "b", "c"} {
+		if err := state.Push(ctx, "l", []byte(v), 2, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vals, err := state.List(ctx, "l")
+	if err != nil || len(vals) != 2 || string(vals[0]) != "b" || string(vals[1]) != "c" {
+		t.Errorf("expected the newest 2 values, got %q (%v)", vals, err)
+	}
+
+	mr.Close()
+	if _, err := state.Incr(ctx, "n", 1, 0); grail.GetErrorCode(err) != grail.Unavailable {
+		t.Errorf("expected an unavailable error, got %v", err)
+	}
+}
+
+func TestSharedBudget(t *testing.T) {
+	mr := miniredis.RunT(t)
+	state, err := redis.Open("redis://"+mr.Addr(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+
+	mp := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{
+				Outputs:  []grail.OutputPart{grail.NewTextOutputPart("ok")},
+				Usage:    grail.Usage{InputTokens: 1_000_000, TotalTokens: 1_000_000},
+				Provider: grail.ProviderInfo{Models: []grail.ModelUse{{Name: "m"}}},
+			}, nil
+		},
+	}
+	prices := grail.PriceTable{"m": {InputPerMTok: 2}}
+	// Two replicas, each with its own Budget, sharing the Redis state.
+	var clients []grail.Client
+	for range 2 {
+		b := &grail.Budget{Limit: 3, Prices: prices, State: state}
+		clients = append(clients, grail.NewClient(mp, grail.WithMiddleware(b.Middleware())))
+	}
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()}
+	ctx := context.Background()
+	if _, err := clients[0].Generate(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clients[1].Generate(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clients[0].Generate(ctx, req); grail.GetErrorCode(err) != grail.BudgetExceeded {
+		t.Errorf("expected the shared budget to be spent, got %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+// Package s3 is a grail.BlobStore in an S3-compatible bucket: Amazon S3,
+// MinIO, or Google Cloud Storage through its S3 interoperability API. A
+// client configured with it saves generated images and videos to the bucket
+// and hands them to end users as presigned URLs:
+//
+//	store, err := s3.Open(s3.Config{
+//		Endpoint: "s3.us-east-1.amazonaws.com",
+//		Region:   "us-east-1",
+//		Bucket:   "myapp-media",
+//		Prefix:   "grail/",
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	client := grail.NewClient(provider, grail.WithBlobStore(store))
+//
+//	res, err := client.Generate(ctx, req)
+//	...
+//	urls, err := res.OutputURLs(ctx, time.Hour)
+//
+// For GCS, use the endpoint "storage.googleapis.com" with an HMAC key; for a
+// local MinIO, its host and port with Insecure set.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/montanaflynn/grail"
+)
+
+// Config says which bucket to keep blobs in and how to reach it.
+type Config struct {
+	Endpoint string // host and optional port, such as "s3.amazonaws.com" or "localhost:9000"
+	Region   string // set it to skip looking up the bucket's region
+	Bucket   string
+	Prefix   string // prepended to every key, to keep apps sharing a bucket apart
+
+	// AccessKey and SecretKey are static credentials. Without them,
+	// credentials come from the environment: AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY, MINIO_ROOT_USER and MINIO_ROOT_PASSWORD, the
+	// AWS shared credentials file, or the instance's IAM role.
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+
+	// Insecure connects over plain HTTP, for a local MinIO.
+	Insecure bool
+	// Transport, if set, carries the store's requests.
+	Transport http.RoundTripper
+}
+
+// Store is a grail.BlobStore in a bucket. It is safe for concurrent use.
+type Store struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+var _ grail.BlobStore = (*Store)(nil)
+
+// Open returns a store in the bucket cfg describes. The bucket must exist;
+// Open doesn't contact it.
+func Open(cfg Config) (*Store, error) {
+	creds := credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, cfg.SessionToken)
+	if cfg.AccessKey == "" {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.EnvMinio{},
+			&credentials.FileAWSCredentials{},
+			&credentials.IAM{},
+		})
+	}
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:     creds,
+		Secure:    !cfg.Insecure,
+		Region:    cfg.Region,
+		Transport: cfg.Transport,
+	})
+	if err != nil {
+		return nil, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("s3: %v", err)).WithCause(err)
+	}
+	return New(client, cfg.Bucket, cfg.Prefix), nil
+}
+
+// New returns a store in bucket through an existing client. Keys are
+// prefixed with prefix.
+func New(client *minio.Client, bucket, prefix string) *Store {
+	return &Store{client: client, bucket: bucket, prefix: prefix}
+}
+
+// Put implements grail.BlobStore.
+func (s *Store) Put(ctx context.Context, key string, data []byte, mime string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, s.prefix+key, bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: mime})
+	if err != nil {
+		return storeError("put "+key, err)
+	}
+	return nil
+}
+
+// Get implements grail.BlobStore.
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, s.prefix+key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, storeError("get "+key, err)
+	}
+	defer obj.Close()
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		return nil, storeError("get "+key, err)
+	}
+	return data, nil
+}
+
+// URL implements grail.BlobStore with a presigned GET URL. S3 allows ttl up
+// to 7 days.
+func (s *Store) URL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, s.prefix+key, ttl, nil)
+	if err != nil {
+		return "", storeError("presign "+key, err)
+	}
+	return u.String(), nil
+}
+
+// storeError codes err by the bucket's response: a missing key is NotFound,
+// and other statuses map as with provider errors. Errors without a response
+// are Unavailable.
+func storeError(op string, err error) error {
+	code, retryable := grail.Unavailable, true
+	resp := minio.ToErrorResponse(err)
+	switch {
+	case resp.Code == minio.NoSuchKey:
+		code, retryable = grail.NotFound, false
+	case resp.StatusCode != 0:
+		code, retryable = grail.CodeFromHTTPStatus(resp.StatusCode)
+	}
+	return grail.NewGrailError(code, fmt.Sprintf("s3: %s: %v", op, err)).WithCause(err).WithRetryable(retryable)
+}
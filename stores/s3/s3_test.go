@@ -0,0 +1,141 @@
+package s3_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+	"github.com/montanaflynn/grail/stores/s3"
+)
+
+// fakeS3 serves path-style PUT and GET object requests from memory.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+			data = unchunk(data)
+		}
+		f.objects[r.URL.Path] = data
+		f.types[r.URL.Path] = r.Header.Get("Content-Type")
+		w.Header().Set("ETag", `"etag"`)
+	case http.MethodGet, http.MethodHead:
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+			return
+		}
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Content-Type", f.types[r.URL.Path])
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Write(data)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// unchunk decodes an aws-chunked body: "<hex size>;chunk-signature=...\r\n"
+// and the chunk's data, until an empty chunk.
+func unchunk(body []byte) []byte {
+	var data []byte
+	for {
+		header, rest, _ := strings.Cut(string(body), "\r\n")
+		sizeHex, _, _ := strings.Cut(header, ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil || size == 0 || int(size) > len(rest) {
+			return data
+		}
+		data = append(data, rest[:size]...)
+		body = []byte(strings.TrimPrefix(rest[size:], "\r\n"))
+	}
+}
+
+func open(t *testing.T) (*s3.Store, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{objects: map[string][]byte{}, types: map[string]string{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	store, err := s3.Open(s3.Config{
+		Endpoint:  strings.TrimPrefix(srv.URL, "http://"),
+		Region:    "us-east-1",
+		Bucket:    "media",
+		Prefix:    "app/",
+		AccessKey: "key",
+		SecretKey: "secret",
+		Insecure:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, fake
+}
+
+func TestStore(t *testing.T) {
+	store, fake := open(t)
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "a/b.txt", []byte("hello"), "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got := string(fake.objects["/media/app/a/b.txt"]); got != "hello" || fake.types["/media/app/a/b.txt"] != "text/plain" {
+		t.Errorf("unexpected object %q (%s)", got, fake.types["/media/app/a/b.txt"])
+	}
+	data, err := store.Get(ctx, "a/b.txt")
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("Get = %q, %v", data, err)
+	}
+	if _, err := store.Get(ctx, "missing"); grail.GetErrorCode(err) != grail.NotFound {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+
+	raw, err := store.URL(ctx, "a/b.txt", time.Hour)
+	if err != nil {
+		t.Fatalf("URL: %v", err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Path != "/media/app/a/b.txt" || u.Query().Get("X-Amz-Expires") != "3600" || u.Query().Get("X-Amz-Signature") == "" {
+		t.Errorf("expected a presigned URL, got %s", raw)
+	}
+}
+
+func TestClientOutputs(t *testing.T) {
+	store, fake := open(t)
+	png := []byte("\x89PNG\r\n\x1a\nimage")
+	mp := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewImageOutputPart(png, "image/png", "")}}, nil
+		},
+	}
+	client := grail.NewClient(mp, grail.WithBlobStore(store))
+	res, err := client.Generate(context.Background(), grail.Request{Inputs: []grail.Input{grail.InputText("a cat")}, Output: grail.OutputImage(grail.ImageSpec{})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := grail.OutputBlobKey(res.Outputs[0])
+	if string(fake.objects["/media/app/"+key]) != string(png) {
+		t.Errorf("expected the image to be saved under %s", key)
+	}
+	urls, err := res.OutputURLs(context.Background(), time.Minute)
+	if err != nil || len(urls) != 1 || !strings.Contains(urls[0], "/media/app/"+key+"?") {
+		t.Errorf("unexpected URLs %q (%v)", urls, err)
+	}
+}
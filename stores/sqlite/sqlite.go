@@ -0,0 +1,252 @@
+// Package sqlite is a grail.HistoryStore backed by a SQLite database, so chat
+// apps can persist conversations and resume them after a restart:
+//
+//	store, err := sqlite.Open("chat.db")
+//	if err != nil {
+//		return err
+//	}
+//	defer store.Close()
+//	session := grail.NewSession(client, grail.WithSessionStore(store, conversationID))
+//
+// Messages are stored as JSON, files included, in one table; WithEncryption
+// seals them so conversations aren't kept in plaintext. The driver is pure Go
+// (modernc.org/sqlite), so no cgo is needed.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/montanaflynn/grail"
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+)
+
+// Table is the name of the table messages are stored in.
+const Table = "grail_messages"
+
+const schema = `CREATE TABLE IF NOT EXISTS ` + Table + ` (
+	conversation TEXT NOT NULL,
+	seq          INTEGER NOT NULL,
+	message      TEXT NOT NULL,
+	created_at   TEXT NOT NULL,
+	PRIMARY KEY (conversation, seq)
+)`
+
+// Store is a grail.HistoryStore in a SQLite database. It is safe for
+// concurrent use.
+type Store struct {
+	db    *sql.DB
+	owned bool // opened by Open, so closed by Close
+	keys  grail.KeyProvider
+}
+
+var _ grail.HistoryStore = (*Store)(nil)
+
+// Option configures Open and New.
+type Option func(*Store)
+
+// WithEncryption encrypts messages with AES-GCM under keys (see grail.Seal),
+// each bound to its conversation and position, so they can't be moved
+// between conversations or reordered. Conversation IDs are kept in the clear.
+// Loading a conversation with messages that aren't encrypted with keys fails.
+func WithEncryption(keys grail.KeyProvider) Option {
+	return func(s *Store) {
+		s.keys = keys
+	}
+}
+
+// Open opens the SQLite database at path, creating it and its table if
+// needed.
+func Open(path string, opts ...Option) (*Store, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, storeError("open", err)
+	}
+	// SQLite allows one writer at a time; one connection keeps writes from
+	// failing with "database is locked" under concurrent use.
+	db.SetMaxOpenConns(1)
+	s, err := New(db, opts...)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	s.owned = true
+	return s, nil
+}
+
+// New returns a store in an already open database, creating its table if
+// needed. Close leaves db open.
+func New(db *sql.DB, opts ...Option) (*Store, error) {
+	if _, err := db.Exec(schema); err != nil {
+		return nil, storeError("create table", err)
+	}
+	s := &Store{db: db}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// Close closes the database if the store opened it.
+func (s *Store) Close() error {
+	if !s.owned {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// Append implements grail.HistoryStore.
+func (s *Store) Append(ctx context.Context, conversation string, msgs ...grail.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	rows := make([][]byte, len(msgs))
+	for i, m := range msgs {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("sqlite: message %d: %v", i, err)).WithCause(err)
+		}
+		rows[i] = data
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storeError("append", err)
+	}
+	defer tx.Rollback()
+	var last int64
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(seq), 0) FROM `+Table+` WHERE conversation = ?`, conversation).Scan(&last); err != nil {
+		return storeError("append", err)
+	}
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	for i, row := range rows {
+		seq := last + int64(i) + 1
+		if row, err = s.seal(conversation, seq, row); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO `+Table+` (conversation, seq, message, created_at) VALUES (?, ?, ?, ?)`,
+			conversation, seq, string(row), now); err != nil {
+			return storeError("append", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return storeError("append", err)
+	}
+	return nil
+}
+
+// Load implements grail.HistoryStore.
+func (s *Store) Load(ctx context.Context, conversation string) ([]grail.Message, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT seq, message FROM `+Table+` WHERE conversation = ? ORDER BY seq`, conversation)
+	if err != nil {
+		return nil, storeError("load", err)
+	}
+	defer rows.Close()
+	var msgs []grail.Message
+	for rows.Next() {
+		var (
+			seq  int64
+			data string
+		)
+		if err := rows.Scan(&seq, &data); err != nil {
+			return nil, storeError("load", err)
+		}
+		plain, err := s.open(conversation, seq, []byte(data))
+		if err != nil {
+			return nil, err
+		}
+		var m grail.Message
+		if err := json.Unmarshal(plain, &m); err != nil {
+			return nil, storeError("load", err)
+		}
+		msgs = append(msgs, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storeError("load", err)
+	}
+	return msgs, nil
+}
+
+// Trim implements grail.HistoryStore.
+func (s *Store) Trim(ctx context.Context, conversation string, keep int) error {
+	var err error
+	if keep <= 0 {
+		_, err = s.db.ExecContext(ctx, `DELETE FROM `+Table+` WHERE conversation = ?`, conversation)
+	} else {
+		_, err = s.db.ExecContext(ctx, `DELETE FROM `+Table+` WHERE conversation = ? AND seq <= (
+			SELECT MAX(seq) - ? FROM `+Table+` WHERE conversation = ?)`, conversation, keep, conversation)
+	}
+	if err != nil {
+		return storeError("trim", err)
+	}
+	return nil
+}
+
+// Conversations returns the IDs of the stored conversations, sorted.
+func (s *Store) Conversations(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT conversation FROM `+Table+` ORDER BY conversation`)
+	if err != nil {
+		return nil, storeError("list conversations", err)
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, storeError("list conversations", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storeError("list conversations", err)
+	}
+	return ids, nil
+}
+
+// sealedMessage is a message's row in an encrypted store.
+type sealedMessage struct {
+	Sealed []byte `json:"sealed"`
+}
+
+func messageAAD(conversation string, seq int64) []byte {
+	return fmt.Appendf(nil, "grail/history\x00%s\x00%d", conversation, seq)
+}
+
+// seal encrypts a message's JSON for its row, if the store is encrypted.
+func (s *Store) seal(conversation string, seq int64, data []byte) ([]byte, error) {
+	if s.keys == nil {
+		return data, nil
+	}
+	sealed, err := grail.Seal(s.keys, data, messageAAD(conversation, seq))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(sealedMessage{Sealed: sealed})
+}
+
+// open returns the message JSON in a row, decrypting it if the store is
+// encrypted.
+func (s *Store) open(conversation string, seq int64, data []byte) ([]byte, error) {
+	var row sealedMessage
+	sealed := json.Unmarshal(data, &row) == nil && row.Sealed != nil
+	switch {
+	case sealed && s.keys == nil:
+		return nil, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("sqlite: conversation %q is encrypted; open the store WithEncryption", conversation))
+	case !sealed && s.keys != nil:
+		return nil, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("sqlite: conversation %q has a message that isn't encrypted", conversation))
+	case sealed:
+		plain, err := grail.Open(s.keys, row.Sealed, messageAAD(conversation, seq))
+		if err != nil {
+			return nil, grail.NewGrailError(grail.InvalidArgument, fmt.Sprintf("sqlite: conversation %q: %v", conversation, err)).WithCause(err)
+		}
+		return plain, nil
+	}
+	return data, nil
+}
+
+func storeError(op string, err error) error {
+	return grail.NewGrailError(grail.Internal, fmt.Sprintf("sqlite: %s: %v", op, err)).WithCause(err)
+}
@@ -0,0 +1,142 @@
+package sqlite_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/stores/sqlite"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "chat.db")
+	store, err := sqlite.Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	err = store.Append(ctx, "ada",
+		grail.UserMessage(grail.InputText("My name is Ada."), grail.InputFile([]byte("notes"), "text/plain")),
+		grail.AssistantMessage("Nice to meet you, Ada!"),
+	)
+	if err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := store.Append(ctx, "bob", grail.UserMessage(grail.InputText("Hi."))); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	store.Close()
+
+	// Conversations survive reopening the database.
+	store, err = sqlite.Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer store.Close()
+	msgs, err := store.Load(ctx, "ada")
+	if err != nil || len(msgs) != 2 {
+		t.Fatalf("Load = %d messages, %v", len(msgs), err)
+	}
+	if data, mime, _, ok := grail.AsFileInput(msgs[0].Inputs[1]); !ok || string(data) != "notes" || mime != "text/plain" {
+		t.Errorf("file = %q %q", data, mime)
+	}
+	if text, _ := grail.AsTextInput(msgs[1].Inputs[0]); msgs[1].Role != grail.TurnAssistant || text != "Nice to meet you, Ada!" {
+		t.Errorf("reply = %+v", msgs[1])
+	}
+	if ids, err := store.Conversations(ctx); err != nil || len(ids) != 2 || ids[0] != "ada" {
+		t.Errorf("Conversations = %v, %v", ids, err)
+	}
+
+	if err := store.Trim(ctx, "ada", 1); err != nil {
+		t.Fatalf("Trim: %v", err)
+	}
+	if msgs, _ := store.Load(ctx, "ada"); len(msgs) != 1 || msgs[0].Role != grail.TurnAssistant {
+		t.Errorf("after Trim = %+v", msgs)
+	}
+	if err := store.Trim(ctx, "ada", 0); err != nil {
+		t.Fatalf("Trim: %v", err)
+	}
+	if msgs, _ := store.Load(ctx, "ada"); len(msgs) != 0 {
+		t.Errorf("after Trim(0) = %d messages", len(msgs))
+	}
+}
+
+func TestStore_ConcurrentAppend(t *testing.T) {
+	ctx := context.Background()
+	store, err := sqlite.Open(filepath.Join(t.TempDir(), "chat.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer store.Close()
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() {
+			if err := store.Append(ctx, "ada", grail.UserMessage(grail.InputText("hi")), grail.AssistantMessage("hello")); err != nil {
+				t.Errorf("Append: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+	if msgs, _ := store.Load(ctx, "ada"); len(msgs) != 40 {
+		t.Errorf("Load = %d messages, want 40", len(msgs))
+	}
+}
+
+func TestStore_Encryption(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "chat.db")
+	keys := grail.StaticKey(bytes.Repeat([]byte{8}, 32))
+	store, err := sqlite.Open(path, sqlite.WithEncryption(keys))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer store.Close()
+	if err := store.Append(ctx, "ada", grail.UserMessage(grail.InputText("My name is Ada.")), grail.AssistantMessage("Hi, Ada!")); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	msgs, err := store.Load(ctx, "ada")
+	if err != nil || len(msgs) != 2 {
+		t.Fatalf("Load = %d messages, %v", len(msgs), err)
+	}
+	if text, _ := grail.AsTextInput(msgs[1].Inputs[0]); text != "Hi, Ada!" {
+		t.Errorf("reply = %q", text)
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var plain int
+	db.QueryRow(`SELECT COUNT(*) FROM ` + sqlite.Table + ` WHERE message LIKE '%Ada%'`).Scan(&plain)
+	if plain != 0 {
+		t.Fatalf("%d messages stored in plaintext", plain)
+	}
+
+	// Without keys, or with messages moved or left unencrypted, loading fails.
+	unkeyed, err := sqlite.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unkeyed.Load(ctx, "ada"); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected loading without keys to fail, got %v", err)
+	}
+	for _, swap := range []string{"-seq", "3 + seq"} {
+		if _, err := db.Exec(`UPDATE ` + sqlite.Table + ` SET seq = ` + swap + ` WHERE conversation = 'ada'`); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.Load(ctx, "ada"); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected reordered messages to fail, got %v", err)
+	}
+	if err := unkeyed.Append(ctx, "bob", grail.UserMessage(grail.InputText("Hi."))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load(ctx, "bob"); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected a plaintext message to fail, got %v", err)
+	}
+}
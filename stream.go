@@ -0,0 +1,115 @@
+package grail
+
+import (
+	"context"
+	"sync"
+)
+
+//
+// Streaming
+//
+
+// TextStreamer is an optional interface for providers that can deliver text
+// output as it's generated. StreamGenerate is DoGenerate with emit called
+// with each piece of text as it arrives; the Response it returns holds the
+// whole output, as DoGenerate's would.
+type TextStreamer interface {
+	StreamGenerate(ctx context.Context, req Request, emit func(text string)) (Response, error)
+}
+
+type textStreamKey struct{}
+
+// clientStreamKey scopes a stream to the client call it was given to, so
+// calls the client makes on other clients with the same context (reviewers,
+// judges, tools) don't write into it.
+type clientStreamKey struct{ c *client }
+
+// ContextWithTextStream returns a context that makes Generate calls on it
+// deliver their text output to fn as it's generated, from providers that
+// implement TextStreamer:
+//
+//	ctx := grail.ContextWithTextStream(ctx, func(text string) { fmt.Print(text) })
+//	res, err := session.Generate(ctx, req)
+//
+// Providers that can't stream, responses from a cache, and middleware that
+// answers without the provider deliver the text to fn once it's complete.
+// fn sees the provider's text as it comes; the Response has it after
+// post-processing (locale formatting, constraints), and when the client
+// calls the provider again to repair output, the later calls aren't
+// streamed. A call that fails after streaming text isn't retried with a
+// fallback model (WithModelFallback), since fn can't take the text back.
+// JSON and image outputs aren't streamed.
+//
+// fn is called on the goroutine reading the response and must not block.
+func ContextWithTextStream(ctx context.Context, fn func(text string)) context.Context {
+	return context.WithValue(ctx, textStreamKey{}, fn)
+}
+
+// textStream is a ContextWithTextStream function and whether it's been
+// called for a Generate call.
+type textStream struct {
+	mu   sync.Mutex
+	fn   func(text string)
+	sent bool
+}
+
+func (s *textStream) emit(text string) {
+	if text == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = true
+	s.fn(text)
+}
+
+func (s *textStream) started() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sent
+}
+
+// finish delivers res's text if none was streamed and req asked for text,
+// itself or through the client's defaults.
+func (s *textStream) finish(req Request, res Response) {
+	if s.started() || (req.Output != nil && !IsTextOutput(req.Output)) {
+		return
+	}
+	for _, part := range res.Outputs {
+		if _, ok := part.(jsonOutputPart); ok {
+			return
+		}
+	}
+	if text, ok := res.Text(); ok {
+		s.emit(text)
+	}
+}
+
+// takeTextStream moves ctx's text stream function, if any, to a stream for
+// this client's call.
+func (c *client) takeTextStream(ctx context.Context) (context.Context, *textStream) {
+	fn, _ := ctx.Value(textStreamKey{}).(func(text string))
+	if fn == nil {
+		return ctx, nil
+	}
+	s := &textStream{fn: fn}
+	ctx = context.WithValue(ctx, textStreamKey{}, nil)
+	return context.WithValue(ctx, clientStreamKey{c}, s), s
+}
+
+// streamStarted reports whether text has been streamed for the call.
+func (c *client) streamStarted(ctx context.Context) bool {
+	s, _ := ctx.Value(clientStreamKey{c}).(*textStream)
+	return s != nil && s.started()
+}
+
+// doGenerate calls the provider, streaming text output to the call's text
+// stream when the provider can and nothing has been streamed yet.
+func (c *client) doGenerate(ctx context.Context, req Request) (Response, error) {
+	if s, _ := ctx.Value(clientStreamKey{c}).(*textStream); s != nil && !s.started() && IsTextOutput(req.Output) {
+		if ts, ok := c.provider.(TextStreamer); ok {
+			return ts.StreamGenerate(ctx, req, s.emit)
+		}
+	}
+	return c.provider.DoGenerate(ctx, req)
+}
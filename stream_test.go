@@ -0,0 +1,81 @@
+package grail_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+// streamingProvider streams the text of its mock's response in chunks.
+type streamingProvider struct {
+	resolvingProvider
+	chunks []string
+	calls  int
+}
+
+func (p *streamingProvider) StreamGenerate(ctx context.Context, req grail.Request, emit func(text string)) (grail.Response, error) {
+	p.calls++
+	for _, chunk := range p.chunks {
+		emit(chunk)
+	}
+	return p.DoGenerate(ctx, req)
+}
+
+func TestContextWithTextStream(t *testing.T) {
+	text := func(s string) func(context.Context, grail.Request) (grail.Response, error) {
+		return func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart(s)}}, nil
+		}
+	}
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()}
+	var got []string
+	ctx := grail.ContextWithTextStream(context.Background(), func(text string) { got = append(got, text) })
+
+	// Text is delivered as the provider streams it, on every call made with
+	// the context.
+	prov := &streamingProvider{resolvingProvider: resolvingProvider{mock.Provider{GenerateFn: text("Hello there.")}}, chunks: []string{"Hello", " there."}}
+	session := grail.NewSession(grail.NewClient(prov), grail.WithSessionHistory())
+	for range 2 {
+		res, err := session.Generate(ctx, req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if text, _ := res.Text(); text != "Hello there." {
+			t.Fatalf("unexpected text %q", text)
+		}
+	}
+	if strings.Join(got, "|") != "Hello| there.|Hello| there." || prov.calls != 2 {
+		t.Errorf("unexpected stream %q after %d calls", got, prov.calls)
+	}
+
+	// Providers that can't stream deliver the text once it's complete, and
+	// JSON isn't streamed.
+	got = nil
+	client := grail.NewClient(&mock.Provider{GenerateFn: text("Hi.")})
+	if _, err := client.Generate(ctx, req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.Generate(ctx, grail.Request{Inputs: req.Inputs, Output: grail.OutputJSON(nil)}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(got, "|") != "Hi." {
+		t.Errorf("expected the whole text once, got %q", got)
+	}
+
+	// A call that fails after streaming isn't retried with another model.
+	got = nil
+	failing := &streamingProvider{resolvingProvider: resolvingProvider{mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		return grail.Response{}, grail.NewGrailError(grail.NotFound, "model retired not found")
+	}}}, chunks: []string{"Hel"}}
+	retired := req
+	retired.Model = "retired"
+	if _, err := grail.NewClient(failing, grail.WithModelFallback()).Generate(ctx, retired); !grail.IsNotFound(err) {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+	if strings.Join(got, "|") != "Hel" || failing.calls != 1 {
+		t.Errorf("expected one streamed call, got %q after %d calls", got, failing.calls)
+	}
+}
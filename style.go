@@ -0,0 +1,90 @@
+package grail
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+//
+// Image style presets
+//
+
+// Style is a named image aesthetic shared across providers: prompt text that
+// describes the look, and options tuned for each provider. The styles package
+// registers a set of presets; teams can register their own.
+type Style struct {
+	Name   string
+	Prompt string // appended to the inputs of image requests
+	// ProviderOptions are applied for the provider with the matching name
+	// ("openai", "gemini", ...), before the request's own options, so a
+	// request can still override them.
+	ProviderOptions map[string][]ProviderOption
+}
+
+var styles = struct {
+	sync.RWMutex
+	m map[string]Style
+}{m: map[string]Style{}}
+
+// RegisterStyle makes s available to WithStyle by its name, replacing any
+// style registered under the same name.
+func RegisterStyle(s Style) {
+	styles.Lock()
+	defer styles.Unlock()
+	styles.m[s.Name] = s
+}
+
+// LookupStyle returns the style registered under name.
+func LookupStyle(name string) (Style, bool) {
+	styles.RLock()
+	defer styles.RUnlock()
+	s, ok := styles.m[name]
+	return s, ok
+}
+
+// Styles returns the names of the registered styles, sorted.
+func Styles() []string {
+	styles.RLock()
+	defer styles.RUnlock()
+	names := make([]string, 0, len(styles.m))
+	for name := range styles.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithStyle applies the registered style name to the client's image
+// requests; text and JSON requests are unaffected. Use it on a child client
+// to style some requests:
+//
+//	logos := client.With(grail.WithStyle("logo"))
+//
+// Requests fail with InvalidArgument if no style is registered under name
+// when they're made.
+func WithStyle(name string) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.style = name
+	})
+}
+
+func (c *client) applyStyle(req Request) (Request, error) {
+	if c.opts.style == "" {
+		return req, nil
+	}
+	if _, ok := GetImageSpec(req.Output); !ok {
+		return req, nil
+	}
+	s, ok := LookupStyle(c.opts.style)
+	if !ok {
+		return req, NewGrailError(InvalidArgument, fmt.Sprintf("unknown style %q", c.opts.style))
+	}
+	if s.Prompt != "" {
+		req.Inputs = append(req.Inputs[:len(req.Inputs):len(req.Inputs)], InputText(s.Prompt))
+	}
+	if opts := s.ProviderOptions[c.provider.Name()]; len(opts) > 0 {
+		req.ProviderOptions = append(append(make([]ProviderOption, 0, len(opts)+len(req.ProviderOptions)), opts...), req.ProviderOptions...)
+	}
+	return req, nil
+}
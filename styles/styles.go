@@ -0,0 +1,74 @@
+// Package styles registers named image style presets with grail: prompt text
+// and provider-tuned options for a consistent look across providers. Import it
+// for its side effect and apply a preset with grail.WithStyle:
+//
+//	import _ "github.com/montanaflynn/grail/styles"
+//
+//	logos := client.With(grail.WithStyle(styles.Logo))
+//	res, err := logos.Generate(ctx, grail.Request{
+//		Inputs: []grail.Input{grail.InputText("A fox for a coffee roaster")},
+//		Output: grail.OutputImage(grail.ImageSpec{}),
+//	})
+//
+// Register house styles the same way, with grail.RegisterStyle.
+package styles
+
+import (
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/gemini"
+	"github.com/montanaflynn/grail/providers/openai"
+)
+
+// Names of the registered presets.
+const (
+	Logo        = "logo"
+	Watercolor  = "watercolor"
+	ProductShot = "product-shot"
+	Infographic = "infographic"
+)
+
+// Presets are the styles this package registers.
+var Presets = []grail.Style{
+	{
+		Name: Logo,
+		Prompt: "Style: a logo. Simple, bold, flat vector shapes with a limited palette, centered on a plain " +
+			"background, legible at small sizes, no photographic detail, no mockups.",
+		ProviderOptions: map[string][]grail.ProviderOption{
+			"openai": {openai.WithImageSize(openai.ImageSize1024x1024), openai.WithImageBackground(openai.ImageBackgroundTransparent), openai.WithImageFormat(openai.ImageFormatPNG)},
+			"gemini": {gemini.WithImageAspectRatio(gemini.ImageAspectRatio1_1)},
+		},
+	},
+	{
+		Name: Watercolor,
+		Prompt: "Style: a watercolor painting. Soft washes and wet-on-wet blooms, visible paper texture, " +
+			"loose edges, gentle light.",
+		ProviderOptions: map[string][]grail.ProviderOption{
+			"openai": {openai.WithImageSize(openai.ImageSize1536x1024)},
+			"gemini": {gemini.WithImageAspectRatio(gemini.ImageAspectRatio3_2)},
+		},
+	},
+	{
+		Name: ProductShot,
+		Prompt: "Style: a commercial product photograph. The product alone on a seamless neutral backdrop, " +
+			"soft studio lighting, subtle shadow, sharp focus, true-to-life color.",
+		ProviderOptions: map[string][]grail.ProviderOption{
+			"openai": {openai.WithImageSize(openai.ImageSize1024x1024), openai.WithImageBackground(openai.ImageBackgroundOpaque)},
+			"gemini": {gemini.WithImageAspectRatio(gemini.ImageAspectRatio1_1), gemini.WithImageSize(gemini.ImageSize2K)},
+		},
+	},
+	{
+		Name: Infographic,
+		Prompt: "Style: an infographic. Clean modern flat design with clear sections, simple icons, and a " +
+			"limited color palette; short, correctly spelled labels; clear visual hierarchy.",
+		ProviderOptions: map[string][]grail.ProviderOption{
+			"openai": {openai.WithImageSize(openai.ImageSize1024x1536)},
+			"gemini": {gemini.WithImageAspectRatio(gemini.ImageAspectRatio3_4), gemini.WithImageSize(gemini.ImageSize2K)},
+		},
+	},
+}
+
+func init() {
+	for _, s := range Presets {
+		grail.RegisterStyle(s)
+	}
+}
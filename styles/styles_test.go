@@ -0,0 +1,49 @@
+package styles_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+	"github.com/montanaflynn/grail/styles"
+)
+
+func TestWithStyle(t *testing.T) {
+	var got grail.Request
+	prov := &mock.Provider{NameVal: "gemini", GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		got = req
+		return grail.Response{}, nil
+	}}
+	client := grail.NewClient(prov).With(grail.WithStyle(styles.Watercolor))
+
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("A lighthouse")}, Output: grail.OutputImage(grail.ImageSpec{})}
+	if _, err := client.Generate(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Inputs) != 2 || len(got.ProviderOptions) != 1 {
+		t.Fatalf("style not applied: %d inputs, %d provider options", len(got.Inputs), len(got.ProviderOptions))
+	}
+	if text, _ := grail.AsTextInput(got.Inputs[1]); text != styles.Presets[1].Prompt {
+		t.Fatalf("unexpected style prompt %q", text)
+	}
+
+	// Text requests are left alone.
+	client.Generate(context.Background(), grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()})
+	if len(got.Inputs) != 1 || len(got.ProviderOptions) != 0 {
+		t.Fatalf("style applied to a text request: %+v", got)
+	}
+
+	unknown := grail.NewClient(prov, grail.WithStyle("brutalist"))
+	if _, err := unknown.Generate(context.Background(), req); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for an unknown style, got %v", err)
+	}
+}
+
+func TestPresetsRegistered(t *testing.T) {
+	for _, s := range styles.Presets {
+		if _, ok := grail.LookupStyle(s.Name); !ok {
+			t.Errorf("%s not registered", s.Name)
+		}
+	}
+}
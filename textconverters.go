@@ -0,0 +1,420 @@
+package grail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+//
+// Built-in text converters
+//
+
+// PlainTextConverter reads a file as text, decoding it from the charset in
+// its MIME type (UTF-8 by default) and normalizing line endings.
+type PlainTextConverter struct{}
+
+// ConvertText implements TextConverter.
+func (PlainTextConverter) ConvertText(ctx context.Context, data []byte, mimeType string) (string, error) {
+	_, params, _ := mime.ParseMediaType(mimeType)
+	return decodeText(data, params["charset"])
+}
+
+// MarkdownConverter reads a Markdown file as text, keeping its markup, which
+// models read well, unless Strip is set.
+type MarkdownConverter struct {
+	Strip bool // remove markup, keeping the text it wraps
+}
+
+// ConvertText implements TextConverter.
+func (m MarkdownConverter) ConvertText(ctx context.Context, data []byte, mimeType string) (string, error) {
+	text, err := PlainTextConverter{}.ConvertText(ctx, data, mimeType)
+	if err != nil || !m.Strip {
+		return text, err
+	}
+	return strings.TrimSpace(stripMarkdown(text)), nil
+}
+
+// HTMLConverter renders an HTML page as Markdown: headings, paragraphs,
+// lists, links, code, quotes, and tables keep their structure, and scripts,
+// styles, and the document head are left out.
+type HTMLConverter struct{}
+
+// ConvertText implements TextConverter.
+func (HTMLConverter) ConvertText(ctx context.Context, data []byte, mimeType string) (string, error) {
+	_, params, _ := mime.ParseMediaType(mimeType)
+	text, err := decodeText(data, params["charset"])
+	if err != nil {
+		return "", err
+	}
+	doc, err := html.Parse(strings.NewReader(text))
+	if err != nil {
+		return "", err
+	}
+	var r htmlRenderer
+	r.render(doc)
+	return tidyText(r.b.String()), nil
+}
+
+// EmailConverter renders an email (.eml) as its main headers followed by its
+// body: the plain text part, or the HTML part rendered by HTMLConverter.
+// Attachments are listed by name.
+type EmailConverter struct{}
+
+// ConvertText implements TextConverter.
+func (EmailConverter) ConvertText(ctx context.Context, data []byte, mimeType string) (string, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	dec := mime.WordDecoder{CharsetReader: charsetReader}
+	var b strings.Builder
+	for _, key := range []string{"From", "To", "Cc", "Date", "Subject"} {
+		if v := msg.Header.Get(key); v != "" {
+			if decoded, err := dec.DecodeHeader(v); err == nil {
+				v = decoded
+			}
+			fmt.Fprintf(&b, "%s: %s\n", key, v)
+		}
+	}
+	var body emailBody
+	if err := body.read(ctx, msg.Header, msg.Body); err != nil {
+		return "", err
+	}
+	text := body.plain
+	if text == "" {
+		text = body.html
+	}
+	if text != "" {
+		b.WriteString("\n" + text + "\n")
+	}
+	if len(body.attachments) > 0 {
+		fmt.Fprintf(&b, "\nAttachments: %s\n", strings.Join(body.attachments, ", "))
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// emailBody collects an email's text, HTML, and attachment names.
+type emailBody struct {
+	plain, html string
+	attachments []string
+}
+
+func (e *emailBody) read(ctx context.Context, header map[string][]string, body io.Reader) error {
+	get := func(key string) string {
+		if v := header[key]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	mediaType, params, err := mime.ParseMediaType(get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+	switch strings.ToLower(get("Content-Transfer-Encoding")) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, newlineStripper{body})
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := e.read(ctx, part.Header, part); err != nil {
+				return err
+			}
+		}
+	}
+
+	disposition, dparams, _ := mime.ParseMediaType(get("Content-Disposition"))
+	name := dparams["filename"]
+	if name == "" {
+		name = params["name"]
+	}
+	if disposition == "attachment" || (name != "" && !strings.HasPrefix(mediaType, "text/")) {
+		if name == "" {
+			name = mediaType
+		}
+		e.attachments = append(e.attachments, name)
+		return nil
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	switch mediaType {
+	case "text/plain":
+		if e.plain == "" {
+			e.plain, err = decodeText(data, params["charset"])
+			e.plain = strings.TrimSpace(e.plain)
+		}
+	case "text/html":
+		if e.html == "" {
+			e.html, err = HTMLConverter{}.ConvertText(ctx, data, get("Content-Type"))
+		}
+	}
+	return err
+}
+
+// newlineStripper drops line breaks from base64 bodies.
+type newlineStripper struct{ r io.Reader }
+
+func (n newlineStripper) Read(p []byte) (int, error) {
+	for {
+		k, err := n.r.Read(p)
+		j := 0
+		for _, c := range p[:k] {
+			if c != '\r' && c != '\n' {
+				p[j] = c
+				j++
+			}
+		}
+		if j > 0 || err != nil {
+			return j, err
+		}
+	}
+}
+
+// decodeText decodes data from charset (UTF-8 if empty), dropping any byte
+// order mark and normalizing line endings.
+func decodeText(data []byte, charset string) (string, error) {
+	if charset != "" && !strings.EqualFold(charset, "utf-8") && !strings.EqualFold(charset, "us-ascii") {
+		r, err := charsetReader(charset, bytes.NewReader(data))
+		if err != nil {
+			return "", err
+		}
+		if data, err = io.ReadAll(r); err != nil {
+			return "", err
+		}
+	}
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.ToValidUTF8(text, "\ufffd"), nil
+}
+
+func charsetReader(charset string, r io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+	return enc.NewDecoder().Reader(r), nil
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// tidyText trims trailing spaces from lines and collapses runs of blank
+// lines.
+func tidyText(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// htmlRenderer writes HTML as Markdown.
+type htmlRenderer struct {
+	b     strings.Builder
+	pre   int
+	lists []int // item counters of the enclosing lists; -1 for unordered
+}
+
+// block starts a new paragraph.
+func (r *htmlRenderer) block() {
+	if s := r.b.String(); s != "" && !strings.HasSuffix(s, "\n\n") {
+		if strings.HasSuffix(s, "\n") {
+			r.b.WriteString("\n")
+		} else {
+			r.b.WriteString("\n\n")
+		}
+	}
+}
+
+// line starts a new line.
+func (r *htmlRenderer) line() {
+	if s := r.b.String(); s != "" && !strings.HasSuffix(s, "\n") {
+		r.b.WriteString("\n")
+	}
+}
+
+func (r *htmlRenderer) atLineStart() bool {
+	s := r.b.String()
+	return s == "" || strings.HasSuffix(s, "\n") || strings.HasSuffix(s, " ")
+}
+
+func (r *htmlRenderer) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.render(c)
+	}
+}
+
+// inner renders n's children on their own.
+func (r *htmlRenderer) inner(n *html.Node) string {
+	sub := htmlRenderer{pre: r.pre, lists: r.lists}
+	sub.children(n)
+	return tidyText(sub.b.String())
+}
+
+func (r *htmlRenderer) render(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if r.pre > 0 {
+			r.b.WriteString(n.Data)
+			return
+		}
+		text := strings.Join(strings.Fields(n.Data), " ")
+		if text == "" {
+			if n.Data != "" && !r.atLineStart() {
+				r.b.WriteString(" ")
+			}
+			return
+		}
+		if startsWithSpace(n.Data) && !r.atLineStart() {
+			r.b.WriteString(" ")
+		}
+		r.b.WriteString(text)
+		if endsWithSpace(n.Data) {
+			r.b.WriteString(" ")
+		}
+		return
+	case html.ElementNode:
+	default:
+		r.children(n)
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Head, atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Svg, atom.Iframe:
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		r.block()
+		level := int(n.Data[1] - '0')
+		r.b.WriteString(strings.Repeat("#", level) + " " + strings.ReplaceAll(r.inner(n), "\n", " "))
+		r.block()
+	case atom.Br:
+		r.b.WriteString("\n")
+	case atom.Hr:
+		r.block()
+		r.b.WriteString("---")
+		r.block()
+	case atom.Pre:
+		r.block()
+		sub := htmlRenderer{pre: r.pre + 1}
+		sub.children(n)
+		r.b.WriteString("```\n" + strings.Trim(sub.b.String(), "\n") + "\n```")
+		r.block()
+	case atom.Code:
+		if r.pre > 0 {
+			r.children(n)
+			return
+		}
+		r.b.WriteString("`" + r.inner(n) + "`")
+	case atom.Strong, atom.B:
+		if text := r.inner(n); text != "" {
+			r.b.WriteString("**" + text + "**")
+		}
+	case atom.Em, atom.I:
+		if text := r.inner(n); text != "" {
+			r.b.WriteString("*" + text + "*")
+		}
+	case atom.A:
+		text, href := r.inner(n), attr(n, "href")
+		switch {
+		case text == "":
+		case href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "javascript:") || href == text:
+			r.b.WriteString(text)
+		default:
+			r.b.WriteString("[" + text + "](" + href + ")")
+		}
+	case atom.Img:
+		if alt := attr(n, "alt"); alt != "" {
+			r.b.WriteString("[image: " + alt + "]")
+		}
+	case atom.Ul, atom.Ol:
+		if len(r.lists) == 0 {
+			r.block()
+		} else {
+			r.line()
+		}
+		counter := -1
+		if n.DataAtom == atom.Ol {
+			counter = 0
+		}
+		r.lists = append(r.lists, counter)
+		r.children(n)
+		r.lists = r.lists[:len(r.lists)-1]
+		if len(r.lists) == 0 {
+			r.block()
+		}
+	case atom.Li:
+		r.line()
+		depth := len(r.lists)
+		marker := "- "
+		if depth > 0 && r.lists[depth-1] >= 0 {
+			r.lists[depth-1]++
+			marker = fmt.Sprintf("%d. ", r.lists[depth-1])
+		}
+		// Nested lists and further paragraphs are indented under the
+		// item.
+		r.b.WriteString(marker + strings.ReplaceAll(r.inner(n), "\n", "\n  "))
+		r.line()
+	case atom.Blockquote:
+		r.block()
+		r.b.WriteString("> " + strings.ReplaceAll(r.inner(n), "\n", "\n> "))
+		r.block()
+	case atom.Tr:
+		r.line()
+		var cells []string
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.DataAtom == atom.Td || c.DataAtom == atom.Th {
+				cells = append(cells, strings.ReplaceAll(r.inner(c), "\n", " "))
+			}
+		}
+		r.b.WriteString("| " + strings.Join(cells, " | ") + " |")
+		r.line()
+	case atom.Table:
+		r.block()
+		r.children(n)
+		r.block()
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Header, atom.Footer, atom.Main, atom.Nav,
+		atom.Aside, atom.Figure, atom.Figcaption, atom.Dl, atom.Dt, atom.Dd, atom.Address, atom.Form, atom.Fieldset:
+		r.block()
+		r.children(n)
+		r.block()
+	default:
+		r.children(n)
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+func startsWithSpace(s string) bool { return s != "" && strings.ContainsRune(" \t\r\n", rune(s[0])) }
+func endsWithSpace(s string) bool {
+	return s != "" && strings.ContainsRune(" \t\r\n", rune(s[len(s)-1]))
+}
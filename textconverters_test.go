@@ -0,0 +1,107 @@
+package grail_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+)
+
+func TestHTMLConverter(t *testing.T) {
+	page := `<!doctype html>
+<html><head><title>Ignored</title><style>p { color: red }</style></head>
+<body>
+  <nav><a href="#main">Skip</a></nav>
+  <h1>Quarterly  report</h1>
+  <p>Revenue grew <strong>12%</strong>, see <a href="https://example.com/q3">the
+  details</a>.<br>Costs were flat.</p>
+  <ul><li>North</li><li>South<ol><li>Coast</li><li>Inland</li></ol></li></ul>
+  <table><tr><th>Region</th><th>Sales</th></tr><tr><td>North</td><td>10</td></tr></table>
+  <pre><code>total := a + b
+  return total</code></pre>
+  <blockquote><p>Best quarter yet.</p></blockquote>
+  <img src="chart.png" alt="Sales chart">
+  <script>track()</script>
+</body></html>`
+	got, err := grail.HTMLConverter{}.ConvertText(context.Background(), []byte(page), "text/html; charset=utf-8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Skip\n\n" +
+		"# Quarterly report\n\n" +
+		"Revenue grew **12%**, see [the details](https://example.com/q3).\nCosts were flat.\n\n" +
+		"- North\n- South\n  1. Coast\n  2. Inland\n\n" +
+		"| Region | Sales |\n| North | 10 |\n\n" +
+		"```\ntotal := a + b\n  return total\n```\n\n" +
+		"> Best quarter yet.\n\n" +
+		"[image: Sales chart]"
+	if got != want {
+		t.Errorf("got:\n%s\n\nwant:\n%s", got, want)
+	}
+}
+
+func TestEmailConverter(t *testing.T) {
+	eml := strings.ReplaceAll(`From: Ada <ada@example.com>
+To: team@example.com
+Subject: =?utf-8?q?Caf=C3=A9_plans?=
+Date: Mon, 2 Jun 2025 10:00:00 +0000
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="outer"
+
+--outer
+Content-Type: multipart/alternative; boundary="inner"
+
+--inner
+Content-Type: text/plain; charset=iso-8859-1
+Content-Transfer-Encoding: quoted-printable
+
+Let's meet at the caf=E9.
+--inner
+Content-Type: text/html
+
+<p>Let's meet at the <b>café</b>.</p>
+--inner--
+--outer
+Content-Type: application/pdf; name="menu.pdf"
+Content-Disposition: attachment; filename="menu.pdf"
+Content-Transfer-Encoding: base64
+
+JVBERg==
+--outer--
+`, "\n", "\r\n")
+	got, err := grail.EmailConverter{}.ConvertText(context.Background(), []byte(eml), "message/rfc822")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "From: Ada <ada@example.com>\nTo: team@example.com\nDate: Mon, 2 Jun 2025 10:00:00 +0000\nSubject: Café plans\n\n" +
+		"Let's meet at the café.\n\nAttachments: menu.pdf"
+	if got != want {
+		t.Errorf("got:\n%s\n\nwant:\n%s", got, want)
+	}
+
+	htmlOnly := "Subject: Hi\r\nContent-Type: text/html\r\n\r\n<h2>Hello</h2><p>there</p>"
+	if got, _ := (grail.EmailConverter{}).ConvertText(context.Background(), []byte(htmlOnly), "message/rfc822"); got != "Subject: Hi\n\n## Hello\n\nthere" {
+		t.Errorf("unexpected HTML email %q", got)
+	}
+}
+
+func TestPlainTextAndMarkdownConverters(t *testing.T) {
+	ctx := context.Background()
+	if got, _ := (grail.PlainTextConverter{}).ConvertText(ctx, []byte("\ufeffa,b\r\n1,2\r\n"), "text/csv"); got != "a,b\n1,2\n" {
+		t.Errorf("unexpected CSV %q", got)
+	}
+	if got, _ := (grail.PlainTextConverter{}).ConvertText(ctx, []byte("caf\xe9"), "text/plain; charset=windows-1252"); got != "café" {
+		t.Errorf("unexpected decoded text %q", got)
+	}
+	if _, err := (grail.PlainTextConverter{}).ConvertText(ctx, []byte("x"), "text/plain; charset=klingon"); err == nil {
+		t.Error("expected an unknown charset to fail")
+	}
+	md := []byte("# Title\n\nSome **bold** [link](https://example.com).")
+	if got, _ := (grail.MarkdownConverter{}).ConvertText(ctx, md, "text/markdown"); got != string(md) {
+		t.Errorf("expected Markdown to be kept, got %q", got)
+	}
+	if got, _ := (grail.MarkdownConverter{Strip: true}).ConvertText(ctx, md, "text/markdown"); got != "Title\n\nSome bold link." {
+		t.Errorf("unexpected stripped Markdown %q", got)
+	}
+}
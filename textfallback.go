@@ -0,0 +1,156 @@
+package grail
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+//
+// Text fallback for unsupported file types
+//
+
+// WarningFileConverted is set on a response whose request had files sent as
+// text by WithTextFallback.
+const WarningFileConverted = "file_converted"
+
+// TextConverter renders a file as text for a model, such as HTMLConverter
+// for web pages. mime is the file's MIME type with any parameters, such as
+// "text/html; charset=iso-8859-1".
+type TextConverter interface {
+	ConvertText(ctx context.Context, data []byte, mime string) (string, error)
+}
+
+// TextConverterFunc adapts a function to a TextConverter, such as one
+// running an external tool like pandoc for Word documents.
+type TextConverterFunc func(ctx context.Context, data []byte, mime string) (string, error)
+
+// ConvertText implements TextConverter.
+func (f TextConverterFunc) ConvertText(ctx context.Context, data []byte, mime string) (string, error) {
+	return f(ctx, data, mime)
+}
+
+// DefaultTextConverters returns the converters WithTextFallback uses by
+// default, by MIME type: HTMLConverter for HTML, MarkdownConverter for
+// Markdown, EmailConverter for emails (.eml), and PlainTextConverter for
+// other text, JSON, XML, and YAML.
+func DefaultTextConverters() map[string]TextConverter {
+	return map[string]TextConverter{
+		"text/html":             HTMLConverter{},
+		"application/xhtml+xml": HTMLConverter{},
+		"text/markdown":         MarkdownConverter{},
+		"message/rfc822":        EmailConverter{},
+		"text/*":                PlainTextConverter{},
+		"application/json":      PlainTextConverter{},
+		"application/xml":       PlainTextConverter{},
+		"application/yaml":      PlainTextConverter{},
+	}
+}
+
+// WithTextFallback sends files the provider doesn't accept (per its
+// ProviderCapabilities) as text, rendered by a TextConverter for their type,
+// so models can read web pages, emails, and other documents no provider
+// takes natively. Each converted file becomes a text input naming it,
+// keeping its WithDroppable marking, and the response gets a
+// WarningFileConverted warning. Files without a converter fail as usual.
+//
+// converters are keyed by MIME type, with "type/*" wildcards, and add to or
+// replace DefaultTextConverters; register converters for other types, such
+// as Word documents, there. A nil converter removes a default. Providers
+// that don't report capabilities get files as they are. Streamed files
+// (InputFileReader) aren't converted.
+func WithTextFallback(converters map[string]TextConverter) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		all := DefaultTextConverters()
+		for pattern, conv := range converters {
+			pattern = strings.ToLower(pattern)
+			if conv == nil {
+				delete(all, pattern)
+				continue
+			}
+			all[pattern] = conv
+		}
+		co.textConverters = all
+	})
+}
+
+// textConverter returns the converter for a MIME type without parameters.
+func (c *client) textConverter(mime string) (TextConverter, bool) {
+	if conv, ok := c.opts.textConverters[mime]; ok {
+		return conv, true
+	}
+	if typ, _, ok := strings.Cut(mime, "/"); ok {
+		if conv, ok := c.opts.textConverters[typ+"/*"]; ok {
+			return conv, true
+		}
+	}
+	conv, ok := c.opts.textConverters["*/*"]
+	return conv, ok
+}
+
+// convertFiles replaces the file inputs the provider doesn't accept with
+// their text.
+func (c *client) convertFiles(ctx context.Context, req Request) (Request, []Warning, error) {
+	caps, ok := c.Capabilities()
+	if !ok {
+		return req, nil, nil
+	}
+	var inputs []Input
+	var warnings []Warning
+	for i, in := range req.Inputs {
+		fi, base, conv := c.fileConverter(caps, in)
+		if conv == nil {
+			if inputs != nil {
+				inputs = append(inputs, in)
+			}
+			continue
+		}
+		text, err := conv.ConvertText(ctx, fi.Data, fi.MIME)
+		if err != nil {
+			if ctx.Err() != nil {
+				return req, nil, ctx.Err()
+			}
+			return req, nil, NewGrailError(InvalidArgument, fmt.Sprintf("input %d: convert %s file to text: %v", i, base, err)).WithCause(err)
+		}
+		if inputs == nil {
+			inputs = append(make([]Input, 0, len(req.Inputs)), req.Inputs[:i]...)
+		}
+		name := "a " + base + " file"
+		if fi.Name != "" {
+			name = fmt.Sprintf("the file %q", fi.Name)
+		}
+		inputs = append(inputs, textInput{
+			Text:            fmt.Sprintf("The contents of %s, as text:\n\n%s", name, text),
+			CacheBreakpoint: fi.CacheBreakpoint,
+			Droppable:       fi.Droppable,
+			Priority:        fi.Priority,
+		})
+		warnings = append(warnings, Warning{
+			Code:    WarningFileConverted,
+			Message: fmt.Sprintf("input %d: %s file sent as text, since provider %s doesn't accept it", i, base, c.provider.Name()),
+		})
+	}
+	if inputs != nil {
+		req.Inputs = inputs
+	}
+	return req, warnings, nil
+}
+
+// fileConverter returns the converter for in, and its MIME type without
+// parameters, if it's a file the provider doesn't accept.
+func (c *client) fileConverter(caps ProviderCapabilities, in Input) (fileInput, string, TextConverter) {
+	fi, ok := in.(fileInput)
+	if !ok || fi.MIME == "" {
+		return fi, "", nil
+	}
+	base, _, err := mime.ParseMediaType(fi.MIME)
+	if err != nil {
+		base = strings.ToLower(fi.MIME)
+	}
+	if caps.AcceptsMIME(base) {
+		return fi, base, nil
+	}
+	conv, _ := c.textConverter(base)
+	return fi, base, conv
+}
@@ -0,0 +1,75 @@
+package grail_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestWithTextFallback(t *testing.T) {
+	var sent []grail.Input
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			sent = req.Inputs
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewImageOutputPart([]byte("img"), "image/png", "")}}, nil
+		},
+	}
+	const docx = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	client := grail.NewClient(imageOnlyProvider{prov}, grail.WithTextFallback(map[string]grail.TextConverter{
+		docx: grail.TextConverterFunc(func(ctx context.Context, data []byte, mime string) (string, error) {
+			return "converted " + string(data), nil
+		}),
+		"application/json": nil,
+	}))
+	ctx := context.Background()
+	png := []byte("\x89PNG\r\n\x1a\n")
+	generate := func(c grail.Client, inputs ...grail.Input) (grail.Response, error) {
+		return c.Generate(ctx, grail.Request{Inputs: inputs, Output: grail.OutputImage(grail.ImageSpec{})})
+	}
+
+	// Files the provider doesn't accept are sent as text; ones it accepts
+	// are left alone.
+	res, err := generate(client,
+		grail.InputText("illustrate"),
+		grail.InputFile([]byte("<h1>Spring sale</h1>"), "text/html; charset=utf-8", grail.WithFileName("sale.html")),
+		grail.InputFile([]byte("memo"), docx, grail.WithDroppable(1)),
+		grail.InputImage(png),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 4 {
+		t.Fatalf("expected 4 inputs, got %d", len(sent))
+	}
+	if text, _ := grail.AsTextInput(sent[1]); text != "The contents of the file \"sale.html\", as text:\n\n# Spring sale" {
+		t.Errorf("unexpected HTML text %q", text)
+	}
+	if text, _ := grail.AsTextInput(sent[2]); !strings.HasSuffix(text, "converted memo") {
+		t.Errorf("unexpected docx text %q", text)
+	}
+	if p, ok := grail.InputPriority(sent[2]); !ok || p != 1 {
+		t.Error("expected the converted input to stay droppable")
+	}
+	if _, mime, _, ok := grail.AsFileInput(sent[3]); !ok || mime != "" {
+		t.Error("expected the image to be sent as is")
+	}
+	if len(res.Warnings) != 2 || res.Warnings[0].Code != grail.WarningFileConverted {
+		t.Errorf("unexpected warnings %+v", res.Warnings)
+	}
+
+	// Types without a converter fail as before.
+	if _, err := generate(client, grail.InputFile([]byte(`{}`), "application/json")); grail.GetErrorCode(err) != grail.Unsupported {
+		t.Errorf("expected a removed converter's type to be unsupported, got %v", err)
+	}
+
+	// Providers that don't report capabilities get files as they are.
+	if _, err := generate(grail.NewClient(prov, grail.WithTextFallback(nil)), grail.InputFile([]byte("<p>hi</p>"), "text/html")); err != nil {
+		t.Fatal(err)
+	}
+	if _, mime, _, ok := grail.AsFileInput(sent[0]); !ok || mime != "text/html" {
+		t.Error("expected the HTML file to be sent as is")
+	}
+}
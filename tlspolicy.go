@@ -0,0 +1,191 @@
+package grail
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+//
+// TLS enforcement
+//
+
+// TLSPolicy configures WithTLSPolicy.
+type TLSPolicy struct {
+	// MinVersion is the oldest TLS version allowed, such as
+	// tls.VersionTLS13 (default tls.VersionTLS12). A transport that already
+	// requires a newer version keeps it.
+	MinVersion uint16
+	// RootCAs, if set, replaces the system roots as the CAs servers'
+	// certificates must chain to, such as a private CA or the CA bundle of
+	// an inspection layer.
+	RootCAs *x509.CertPool
+	// Pins are SHA-256 hashes of the public keys servers' certificate chains
+	// must include at least one of, in base64 with an optional "sha256/"
+	// prefix (as printed by `openssl x509 -pubkey | openssl pkey -pubin
+	// -outform der | openssl dgst -sha256 -binary | base64`). With no pins,
+	// any certificate the roots verify is accepted.
+	Pins []string
+	// AllowPlaintextProxy allows proxies reached over plain HTTP (from the
+	// transport's Proxy setting, such as HTTPS_PROXY=http://...). Requests
+	// through them fail by default, since the proxy sees which hosts are
+	// contacted.
+	AllowPlaintextProxy bool
+}
+
+// WithTLSPolicy enforces encryption in transit on the client's HTTP
+// traffic, for security-sensitive deployments routing it through
+// inspection layers:
+//
+//   - Provider requests and the client's own downloads (InputFileFromURI,
+//     see WithHTTPClient) must use https and TLS p.MinVersion or newer,
+//     with certificates verified against p.RootCAs and matching p.Pins.
+//   - Requests through plaintext proxies fail, unless p.AllowPlaintextProxy.
+//   - Providers whose transport can't be configured (they don't implement
+//     TransportAware, or were built with an http.RoundTripper that isn't an
+//     *http.Transport) are refused.
+//
+// Requests the policy rejects fail with Unauthorized before any data is
+// sent. Like WithAirGap, the policy applies to the client's own calls, not
+// those of other clients sharing its provider, and is set up by NewClient,
+// so child clients created with With keep their parent's; setting it on a
+// child alone leaves its calls unconfigured, and the child refuses to
+// generate.
+func WithTLSPolicy(p TLSPolicy) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.tlsPolicy = &p
+	})
+}
+
+// enforceTLS configures the client's own HTTP client with the policy; its
+// provider calls are configured per call, by its transportScope.
+func (c *client) enforceTLS(policy TLSPolicy) {
+	hc := *c.httpClient
+	hc.Transport = policy.transport(hc.Transport)
+	c.httpClient = &hc
+}
+
+// checkTLSPolicy reports whether the client may generate under its TLS
+// policy.
+func (c *client) checkTLSPolicy() error {
+	if c.opts.tlsPolicy == nil || c.tlsEnforced {
+		return nil
+	}
+	return NewGrailError(Unsupported, fmt.Sprintf("TLS policy: provider %s's transport can't be configured", c.provider.Name())).
+		WithProviderName(c.provider.Name())
+}
+
+// tlsRejected surfaces a request the TLS policy rejected, which provider SDKs
+// report as an opaque transport failure, as Unauthorized.
+func (c *client) tlsRejected(err error) error {
+	var rejected *tlsPolicyError
+	if !errors.As(err, &rejected) {
+		return err
+	}
+	return NewGrailError(Unauthorized, rejected.Error()).WithCause(err).WithProviderName(c.provider.Name())
+}
+
+type tlsPolicyError struct {
+	reason string
+}
+
+func (e *tlsPolicyError) Error() string {
+	return "TLS policy: " + e.reason
+}
+
+// transport returns base configured to enforce the policy, or a transport
+// failing every request if base can't be configured.
+func (p TLSPolicy) transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t, ok := base.(*http.Transport)
+	if !ok {
+		return rejectTransport{&tlsPolicyError{reason: fmt.Sprintf("transport %T can't be configured", base)}}
+	}
+	t = t.Clone()
+
+	cfg := &tls.Config{}
+	if t.TLSClientConfig != nil {
+		cfg = t.TLSClientConfig.Clone()
+	}
+	cfg.InsecureSkipVerify = false
+	cfg.MinVersion = max(cfg.MinVersion, p.MinVersion, tls.VersionTLS12)
+	if p.RootCAs != nil {
+		cfg.RootCAs = p.RootCAs
+	}
+	if len(p.Pins) > 0 {
+		pins := make([]string, len(p.Pins))
+		for i, pin := range p.Pins {
+			pins[i] = strings.TrimPrefix(pin, "sha256/")
+		}
+		verify := cfg.VerifyConnection
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			if verify != nil {
+				if err := verify(cs); err != nil {
+					return err
+				}
+			}
+			if !pinned(cs.VerifiedChains, pins) {
+				return &tlsPolicyError{reason: fmt.Sprintf("certificate for %q matches no pinned key", cs.ServerName)}
+			}
+			return nil
+		}
+	}
+	t.TLSClientConfig = cfg
+
+	if proxy := t.Proxy; proxy != nil && !p.AllowPlaintextProxy {
+		t.Proxy = func(req *http.Request) (*url.URL, error) {
+			u, err := proxy(req)
+			if err == nil && u != nil && u.Scheme != "https" {
+				return nil, &tlsPolicyError{reason: fmt.Sprintf("proxy %s isn't reached over TLS", u.Redacted())}
+			}
+			return u, err
+		}
+	}
+	return httpsOnly{t}
+}
+
+// pinned reports whether a verified chain includes a pinned public key.
+func pinned(chains [][]*x509.Certificate, pins []string) bool {
+	for _, chain := range chains {
+		for _, cert := range chain {
+			sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			if slices.Contains(pins, base64.StdEncoding.EncodeToString(sum[:])) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// httpsOnly fails requests that aren't sent over https.
+type httpsOnly struct {
+	base http.RoundTripper
+}
+
+func (t httpsOnly) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return rejectTransport{&tlsPolicyError{reason: fmt.Sprintf("request to %s isn't encrypted", req.URL.Host)}}.RoundTrip(req)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// rejectTransport fails every request with err.
+type rejectTransport struct {
+	err error
+}
+
+func (t rejectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return nil, t.err
+}
@@ -0,0 +1,125 @@
+package grail_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/fake"
+	"github.com/montanaflynn/grail/providers/mock"
+	"github.com/montanaflynn/grail/providers/openai"
+)
+
+func TestTLSPolicy(t *testing.T) {
+	var calls atomic.Int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"resp_1","object":"response","status":"completed","model":"gpt-5.4",
+			"output":[{"type":"message","id":"msg_1","role":"assistant","status":"completed",
+				"content":[{"type":"output_text","text":"hi","annotations":[]}]}]}`)
+	})
+	srv := httptest.NewTLSServer(handler)
+	defer srv.Close()
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	sum := sha256.Sum256(srv.Certificate().RawSubjectPublicKeyInfo)
+	pin := "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("hello")}, Output: grail.OutputText()}
+
+	generate := func(baseURL string, policy grail.TLSPolicy, opts ...openai.Option) error {
+		t.Helper()
+		opts = append(opts, openai.WithAPIKey("dummy"), openai.WithBaseURL(baseURL+"/v1/"))
+		p, err := openai.New(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = grail.NewClient(p, grail.WithTLSPolicy(policy)).Generate(context.Background(), req)
+		return err
+	}
+
+	if err := generate(srv.URL, grail.TLSPolicy{MinVersion: tls.VersionTLS13, RootCAs: roots, Pins: []string{pin}}); err != nil {
+		t.Fatalf("expected a pinned TLS 1.3 server to work, got %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected 1 request, got %d", n)
+	}
+
+	// Without the test CA the server's certificate doesn't verify, even if
+	// the provider's transport skips verification.
+	insecure := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	if err := generate(srv.URL, grail.TLSPolicy{}, openai.WithHTTPClient(insecure)); err == nil {
+		t.Fatal("expected an unverified certificate to fail")
+	}
+
+	for name, tc := range map[string]struct {
+		url    string
+		policy grail.TLSPolicy
+		opts   []openai.Option
+	}{
+		"pin mismatch": {srv.URL, grail.TLSPolicy{RootCAs: roots, Pins: []string{"sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}}, nil},
+		"plaintext":    {plain.URL, grail.TLSPolicy{}, nil},
+		"plaintext proxy": {srv.URL, grail.TLSPolicy{RootCAs: roots}, []openai.Option{openai.WithHTTPClient(&http.Client{
+			Transport: &http.Transport{Proxy: http.ProxyURL(&url.URL{Scheme: "http", Host: "127.0.0.1:1"})},
+		})}},
+		"unconfigurable transport": {srv.URL, grail.TLSPolicy{RootCAs: roots}, []openai.Option{openai.WithHTTPClient(&http.Client{
+			Transport: roundTripFunc(srv.Client().Transport.RoundTrip),
+		})}},
+	} {
+		if err := generate(tc.url, tc.policy, tc.opts...); grail.GetErrorCode(err) != grail.Unauthorized {
+			t.Errorf("%s: expected Unauthorized, got %v", name, err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected rejected requests not to reach the servers, got %d requests", n)
+	}
+
+	// The policy applies only to the client that set it, not to other
+	// clients sharing its provider.
+	p, err := openai.New(openai.WithAPIKey("dummy"), openai.WithBaseURL(plain.URL+"/v1/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforced := grail.NewClient(p, grail.WithTLSPolicy(grail.TLSPolicy{}))
+	if _, err := grail.NewClient(p).Generate(context.Background(), req); err != nil {
+		t.Fatalf("expected a client without a TLS policy to be unaffected, got %v", err)
+	}
+	if _, err := enforced.Generate(context.Background(), req); grail.GetErrorCode(err) != grail.Unauthorized {
+		t.Fatalf("expected the client with the policy to keep it, got %v", err)
+	}
+
+	// Downloads are held to the policy too.
+	c := grail.NewClient(fake.New(), grail.WithTLSPolicy(grail.TLSPolicy{RootCAs: roots}))
+	if _, err := c.InputFileFromURI(context.Background(), srv.URL+"/file"); err != nil {
+		t.Fatalf("expected a verified download to work, got %v", err)
+	}
+	if _, err := c.InputFileFromURI(context.Background(), plain.URL+"/file"); err == nil {
+		t.Fatal("expected a plaintext download to fail")
+	}
+
+	// Providers whose transport can't be configured are refused; the fake
+	// provider makes no requests and is allowed.
+	if _, err := grail.NewClient(&mock.Provider{}, grail.WithTLSPolicy(grail.TLSPolicy{})).Generate(context.Background(), req); grail.GetErrorCode(err) != grail.Unsupported {
+		t.Fatalf("expected an unconfigurable provider to be refused, got %v", err)
+	}
+	if _, err := c.Generate(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error from the fake provider: %v", err)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
@@ -0,0 +1,114 @@
+package grail
+
+import (
+	"bytes"
+	"image"
+	"regexp"
+	"strings"
+)
+
+//
+// Token estimates
+//
+
+// Rough token costs of files, used where providers tokenize content rather
+// than bytes. They lean toward the high side of what providers charge.
+const (
+	bytesPerToken = 4
+
+	imageTileSize   = 512
+	imageTileTokens = 170
+	imageBaseTokens = 85
+	// imageTokens is for images whose dimensions aren't known: a 1024x1024
+	// image's worth.
+	imageTokens = 765
+
+	pdfPageTokens = 1000
+	// pdfBytesPerPage sizes PDFs whose pages can't be counted.
+	pdfBytesPerPage = 50 << 10
+
+	// Audio at about 32 tokens a second and 128 kbps, video at about 263
+	// tokens a second and 8 Mbps.
+	audioBytesPerToken = 16 << 10 / 32
+	videoBytesPerToken = 1 << 20 / 263
+)
+
+// estimateTokens approximates a request's input token use: text at about
+// four bytes per token, and files by type (images by their dimensions, PDFs
+// by their pages, audio and video by their length at typical bitrates).
+func estimateTokens(req Request) int {
+	text, files := inputsTokens(req.Inputs)
+	for _, m := range req.History {
+		t, f := inputsTokens(m.Inputs)
+		text += t
+		files += f
+	}
+	return text/bytesPerToken + files + 1
+}
+
+// inputsTokens returns the bytes of text in inputs and the tokens estimated
+// for their files.
+func inputsTokens(inputs []Input) (text, files int) {
+	for _, in := range inputs {
+		switch v := in.(type) {
+		case textInput:
+			text += len(v.Text)
+		case toolResultInput:
+			text += len(v.Call.Arguments) + len(v.Output)
+		case fileInput:
+			files += fileTokens(v.MIME, int64(len(v.Data)), v.Data)
+		case fileReaderInput:
+			if v.Size > 0 {
+				files += fileTokens(v.MIME, v.Size, nil)
+			}
+		case uploadedFileInput:
+			files += fileTokens(v.MIME, v.Size, nil)
+		}
+	}
+	return text, files
+}
+
+// fileTokens estimates the tokens of a file of size bytes. data, if not nil,
+// is the file's content.
+func fileTokens(mime string, size int64, data []byte) int {
+	switch {
+	case strings.HasPrefix(mime, "image/"):
+		return imageFileTokens(data)
+	case mime == "application/pdf":
+		pages := int(size / pdfBytesPerPage)
+		if data != nil {
+			pages = len(pdfPageRE.FindAll(data, -1))
+		}
+		return max(pages, 1) * pdfPageTokens
+	case strings.HasPrefix(mime, "audio/"):
+		return int(size/audioBytesPerToken) + 1
+	case strings.HasPrefix(mime, "video/"):
+		return int(size/videoBytesPerToken) + 1
+	}
+	return int(size/bytesPerToken) + 1
+}
+
+// pdfPageRE matches a PDF's page objects, and not its /Pages tree nodes.
+var pdfPageRE = regexp.MustCompile(`/Type\s*/Page\b`)
+
+// imageFileTokens estimates an image's tokens the way tiled vision models
+// count them: scaled to fit 2048x2048, then so its shorter side is at most
+// 768, and charged per 512-pixel tile.
+func imageFileTokens(data []byte) int {
+	if data == nil {
+		return imageTokens
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 {
+		return imageTokens
+	}
+	w, h := float64(cfg.Width), float64(cfg.Height)
+	if scale := 2048 / max(w, h); scale < 1 {
+		w, h = w*scale, h*scale
+	}
+	if scale := 768 / min(w, h); scale < 1 {
+		w, h = w*scale, h*scale
+	}
+	tiles := func(n float64) int { return (int(n) + imageTileSize - 1) / imageTileSize }
+	return imageBaseTokens + imageTileTokens*tiles(w)*tiles(h)
+}
@@ -0,0 +1,66 @@
+package grail_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewGray(image.Rect(0, 0, 1000, 1000))); err != nil {
+		t.Fatal(err)
+	}
+	pdf := []byte("%PDF-1.4\n1 0 obj << /Type /Pages /Count 3 >> endobj\n" +
+		"2 0 obj << /Type /Page >> endobj\n3 0 obj << /Type/Page >> endobj\n4 0 obj << /Type /Page >> endobj\n" +
+		strings.Repeat(" ", 4<<20))
+
+	tests := []struct {
+		name  string
+		input grail.Input
+		want  int
+	}{
+		{"text", grail.InputText(strings.Repeat("word", 100)), 101},
+		// Scaled to 768x768, four 512-pixel tiles.
+		{"image", grail.InputFile(img.Bytes(), "image/png"), 85 + 4*170 + 1},
+		{"undecodable image", grail.InputFile([]byte("not an image"), "image/png"), 765 + 1},
+		{"pdf", grail.InputFile(pdf, "application/pdf"), 3*1000 + 1},
+		{"audio", grail.InputFile(make([]byte, 1<<20), "audio/mpeg"), 2048 + 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := grail.EstimateTokens(grail.Request{Inputs: []grail.Input{tt.input}}); got != tt.want {
+				t.Errorf("expected %d tokens, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWithBudget_Files(t *testing.T) {
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("ok")}}, nil
+		},
+	}
+	client := grail.NewClient(prov,
+		grail.WithPrices(grail.PriceTable{"priced": {InputPerMTok: 1}}),
+		grail.WithBudget(0.01, 0),
+	)
+	// A one-page PDF padded to 4 MB is about 1,000 tokens, not the million
+	// its base64 would be.
+	pdf := []byte("%PDF-1.4\n1 0 obj << /Type /Page >> endobj\n" + strings.Repeat(" ", 4<<20))
+	_, err := client.Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("Summarize"), grail.InputFile(pdf, "application/pdf")},
+		Output: grail.OutputText(),
+		Model:  "priced",
+	})
+	if err != nil {
+		t.Errorf("expected the PDF to be estimated by its pages, got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package grail
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+//
+// Tool calling
+//
+
+// Tool is a function the model may call instead of, or before, answering.
+// Set Request.Tools to offer tools; calls come back as tool call output parts
+// (see Response.ToolCalls), and their results go back to the model with
+// InputToolResult in the next request:
+//
+//	res, _ := client.Generate(ctx, grail.Request{
+//		Inputs: inputs,
+//		Output: grail.OutputText(),
+//		Tools:  []grail.Tool{weatherTool},
+//	})
+//	for _, call := range res.ToolCalls() {
+//		inputs = append(inputs, grail.InputToolResult(call, lookUpWeather(call.Arguments)))
+//	}
+//
+// Providers that don't report ProviderCapabilities.Tools reject requests
+// with tools or tool results.
+type Tool struct {
+	Name        string // letters, digits, underscores, and dashes; at most 64
+	Description string // what the tool does and when to use it
+	// Parameters is the JSON Schema of the arguments object, as a
+	// map[string]any or any value that marshals to one; nil for a tool
+	// without arguments.
+	Parameters any
+}
+
+// ToolCall is a model's request to call a tool.
+type ToolCall struct {
+	ID        string          // the provider's call ID; empty if it doesn't assign one
+	Name      string          // the Tool's name
+	Arguments json.RawMessage // a JSON object conforming to the tool's Parameters
+	// Signature is opaque provider data that must be sent back with the
+	// call's result, such as Gemini's thought signature.
+	Signature []byte
+}
+
+// DecodeArguments unmarshals the call's arguments into dst.
+func (tc ToolCall) DecodeArguments(dst any) error {
+	args := tc.Arguments
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	if err := json.Unmarshal(args, dst); err != nil {
+		return NewGrailError(OutputInvalid, fmt.Sprintf("decode arguments of tool call %s: %v", tc.Name, err)).WithCause(err)
+	}
+	return nil
+}
+
+type toolCallOutputPart struct {
+	Call ToolCall
+}
+
+func (toolCallOutputPart) isOutputPart() {}
+
+// NewToolCallOutputPart returns an output part for a tool call, for providers.
+func NewToolCallOutputPart(call ToolCall) OutputPart {
+	return toolCallOutputPart{Call: call}
+}
+
+// ToolCalls returns the tool calls in the response, in order.
+func (r Response) ToolCalls() []ToolCall {
+	var calls []ToolCall
+	for _, part := range r.Outputs {
+		if tc, ok := part.(toolCallOutputPart); ok {
+			calls = append(calls, tc.Call)
+		}
+	}
+	return calls
+}
+
+type toolResultInput struct {
+	Call   ToolCall
+	Output string
+}
+
+func (toolResultInput) isInput() {}
+
+// InputToolResult returns the result of running call, to send back to the
+// model after the inputs of the request that produced it. The call is sent
+// along with its result, so the inputs needn't repeat it. output is usually
+// JSON but can be any text, including an error message for the model.
+func InputToolResult(call ToolCall, output string) Input {
+	return toolResultInput{Call: call, Output: output}
+}
+
+// AsToolResultInput returns the call and output of a tool result input.
+func AsToolResultInput(input Input) (ToolCall, string, bool) {
+	if tr, ok := input.(toolResultInput); ok {
+		return tr.Call, tr.Output, true
+	}
+	return ToolCall{}, "", false
+}
+
+// usesTools reports whether req offers tools or returns tool results.
+func usesTools(req Request) bool {
+	if len(req.Tools) > 0 {
+		return true
+	}
+	for _, in := range req.Inputs {
+		if _, ok := in.(toolResultInput); ok {
+			return true
+		}
+	}
+	for _, m := range req.History {
+		for _, in := range m.Inputs {
+			if _, ok := in.(toolResultInput); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+func validateTools(req Request) error {
+	seen := make(map[string]bool, len(req.Tools))
+	for i, t := range req.Tools {
+		if !toolNamePattern.MatchString(t.Name) {
+			return NewGrailError(InvalidArgument, fmt.Sprintf("tool %d: invalid name %q", i, t.Name))
+		}
+		if seen[t.Name] {
+			return NewGrailError(InvalidArgument, fmt.Sprintf("tool %d: duplicate name %q", i, t.Name))
+		}
+		seen[t.Name] = true
+	}
+	for i, in := range req.Inputs {
+		if tr, ok := in.(toolResultInput); ok && tr.Call.Name == "" {
+			return NewGrailError(InvalidArgument, fmt.Sprintf("input %d: tool result has no tool name", i))
+		}
+	}
+	return nil
+}
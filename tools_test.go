@@ -0,0 +1,113 @@
+package grail_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+type toolProvider struct{ *mock.Provider }
+
+func (toolProvider) Capabilities() grail.ProviderCapabilities {
+	return grail.ProviderCapabilities{TextOutput: true, Tools: true}
+}
+
+var weatherTool = grail.Tool{
+	Name:        "get_weather",
+	Description: "Get the current weather in a city.",
+	Parameters: map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"city": map[string]any{"type": "string"}},
+	},
+}
+
+func TestToolCalls(t *testing.T) {
+	var got grail.Request
+	prov := toolProvider{&mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			got = req
+			if _, _, ok := grail.AsToolResultInput(req.Inputs[len(req.Inputs)-1]); ok {
+				return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("18°C in Paris")}}, nil
+			}
+			return grail.Response{Outputs: []grail.OutputPart{
+				grail.NewToolCallOutputPart(grail.ToolCall{ID: "call_1", Name: "get_weather", Arguments: []byte(`{"city":"Paris"}`)}),
+			}}, nil
+		},
+	}}
+	client := grail.NewClient(prov)
+	ctx := context.Background()
+
+	inputs := []grail.Input{grail.InputText("What's the weather in Paris?")}
+	res, err := client.Generate(ctx, grail.Request{Inputs: inputs, Output: grail.OutputText(), Tools: []grail.Tool{weatherTool}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got.Tools) != 1 || got.Tools[0].Name != "get_weather" {
+		t.Fatalf("expected the tool to reach the provider, got %+v", got.Tools)
+	}
+	calls := res.ToolCalls()
+	if len(calls) != 1 || calls[0].ID != "call_1" {
+		t.Fatalf("expected one tool call, got %+v", calls)
+	}
+	var args struct{ City string }
+	if err := calls[0].DecodeArguments(&args); err != nil || args.City != "Paris" {
+		t.Fatalf("expected city Paris, got %q (%v)", args.City, err)
+	}
+	if _, ok := res.Text(); ok {
+		t.Errorf("expected no text alongside the tool call")
+	}
+
+	inputs = append(inputs, grail.InputToolResult(calls[0], `{"temp_c":18}`))
+	res, err = client.Generate(ctx, grail.Request{Inputs: inputs, Output: grail.OutputText(), Tools: []grail.Tool{weatherTool}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	call, output, ok := grail.AsToolResultInput(got.Inputs[1])
+	if !ok || call.Name != "get_weather" || output != `{"temp_c":18}` {
+		t.Fatalf("expected the tool result input, got %+v %q", call, output)
+	}
+	if text, _ := res.Text(); text != "18°C in Paris" {
+		t.Errorf("unexpected text %q", text)
+	}
+}
+
+func TestToolsValidation(t *testing.T) {
+	prov := toolProvider{&mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("ok")}}, nil
+		},
+	}}
+	client := grail.NewClient(prov)
+	for name, req := range map[string]grail.Request{
+		"bad name":      {Tools: []grail.Tool{{Name: "get weather"}}},
+		"duplicate":     {Tools: []grail.Tool{weatherTool, weatherTool}},
+		"unnamed call":  {Inputs: []grail.Input{grail.InputToolResult(grail.ToolCall{ID: "call_1"}, "{}")}},
+		"name too long": {Tools: []grail.Tool{{Name: string(make([]byte, 65))}}},
+	} {
+		req.Inputs = append([]grail.Input{grail.InputText("hi")}, req.Inputs...)
+		req.Output = grail.OutputText()
+		if _, err := client.Generate(context.Background(), req); grail.GetErrorCode(err) != grail.InvalidArgument {
+			t.Errorf("%s: expected InvalidArgument, got %v", name, err)
+		}
+	}
+}
+
+func TestToolsUnsupported(t *testing.T) {
+	called := false
+	prov := imageOnlyProvider{&mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			called = true
+			return grail.Response{}, nil
+		},
+	}}
+	_, err := grail.NewClient(prov).Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("a cat")},
+		Output: grail.OutputImage(grail.ImageSpec{}),
+		Tools:  []grail.Tool{weatherTool},
+	})
+	if grail.GetErrorCode(err) != grail.Unsupported || called {
+		t.Fatalf("expected Unsupported before dispatch, got %v (called %v)", err, called)
+	}
+}
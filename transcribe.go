@@ -0,0 +1,211 @@
+package grail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+//
+// Transcription
+//
+
+// Transcription is speech transcribed from audio.
+type Transcription struct {
+	Text     string
+	Language string              // the spoken language, if known
+	Duration time.Duration       // the audio's length, if known
+	Segments []TranscriptSegment // with WithTimestamps
+	Usage    Usage
+	Provider ProviderInfo
+}
+
+// TranscriptSegment is a span of a transcription with its place in the
+// audio.
+type TranscriptSegment struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// TranscriptionRequest is what a Transcriber is asked to transcribe.
+type TranscriptionRequest struct {
+	Audio      []byte
+	MIME       string
+	Name       string // the audio's file name, if known
+	Model      string // empty for the provider's default
+	Language   string // ISO 639-1 code, if known
+	Prompt     string // context such as names and terms used in the audio
+	Timestamps bool   // return segments with start and end times
+}
+
+// Transcriber is an optional interface for providers with a dedicated
+// speech-to-text endpoint. Client.Transcribe uses it when the provider
+// implements it, and falls back to asking the model to transcribe the audio
+// otherwise.
+type Transcriber interface {
+	Transcribe(ctx context.Context, req TranscriptionRequest) (Transcription, error)
+}
+
+// TranscribeOpt configures Client.Transcribe.
+type TranscribeOpt interface{ applyTranscribeOpt(*TranscriptionRequest) }
+
+type transcribeOptFunc func(*TranscriptionRequest)
+
+func (f transcribeOptFunc) applyTranscribeOpt(tr *TranscriptionRequest) { f(tr) }
+
+// WithTranscriptionModel picks the model that transcribes, such as
+// "whisper-1" or "gpt-4o-transcribe" for OpenAI.
+func WithTranscriptionModel(model string) TranscribeOpt {
+	return transcribeOptFunc(func(tr *TranscriptionRequest) {
+		tr.Model = model
+	})
+}
+
+// WithTranscriptionLanguage gives the spoken language as an ISO 639-1 code,
+// such as "de", which improves accuracy and speed.
+func WithTranscriptionLanguage(lang string) TranscribeOpt {
+	return transcribeOptFunc(func(tr *TranscriptionRequest) {
+		tr.Language = lang
+	})
+}
+
+// WithTranscriptionPrompt gives context for the audio, such as the names,
+// acronyms, and jargon it uses, so they're spelled right.
+func WithTranscriptionPrompt(prompt string) TranscribeOpt {
+	return transcribeOptFunc(func(tr *TranscriptionRequest) {
+		tr.Prompt = prompt
+	})
+}
+
+// WithTimestamps asks for the transcription split into segments with their
+// start and end times.
+func WithTimestamps() TranscribeOpt {
+	return transcribeOptFunc(func(tr *TranscriptionRequest) {
+		tr.Timestamps = true
+	})
+}
+
+// Transcribe transcribes speech from audio, an audio file input such as
+// InputFile(data, "audio/mpeg"). Providers with a speech-to-text endpoint
+// (openai.Provider, with Whisper and gpt-4o-transcribe) transcribe it
+// there; others that accept audio inputs (gemini.Provider) are asked to
+// transcribe it as a JSON request, whose timestamps are the model's
+// estimates.
+func (c *client) Transcribe(ctx context.Context, audio Input, opts ...TranscribeOpt) (Transcription, error) {
+	if err := c.life.enter(); err != nil {
+		return Transcription{}, err
+	}
+	defer c.life.leave()
+
+	var tr TranscriptionRequest
+	switch v := audio.(type) {
+	case fileInput:
+		tr.Audio, tr.MIME, tr.Name = v.Data, v.MIME, v.Name
+	case fileReaderInput:
+		data, err := io.ReadAll(v.R)
+		if err != nil {
+			return Transcription{}, NewGrailError(InvalidArgument, fmt.Sprintf("read audio: %v", err)).WithCause(err)
+		}
+		tr.Audio, tr.MIME, tr.Name = data, v.MIME, v.Name
+	default:
+		return Transcription{}, NewGrailError(InvalidArgument, fmt.Sprintf("audio must be a file input, got %T", audio))
+	}
+	if len(tr.Audio) == 0 {
+		return Transcription{}, NewGrailError(InvalidArgument, "audio must not be empty")
+	}
+	if !strings.HasPrefix(tr.MIME, "audio/") {
+		return Transcription{}, NewGrailError(InvalidArgument, fmt.Sprintf("audio has MIME type %q; expected audio/*", tr.MIME))
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt.applyTranscribeOpt(&tr)
+		}
+	}
+
+	if t, ok := c.provider.(Transcriber); ok {
+		res, err := t.Transcribe(c.withTransport(ctx), tr)
+		if err != nil {
+			return Transcription{}, err
+		}
+		if res.Provider.Name == "" {
+			res.Provider.Name = c.provider.Name()
+		}
+		return res, nil
+	}
+	return c.transcribeWithModel(ctx, tr)
+}
+
+var transcriptionSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"text":     map[string]any{"type": "string"},
+		"language": map[string]any{"type": "string", "description": "ISO 639-1 code of the spoken language"},
+		"segments": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"start": map[string]any{"type": "number", "description": "seconds from the start of the audio"},
+					"end":   map[string]any{"type": "number", "description": "seconds from the start of the audio"},
+					"text":  map[string]any{"type": "string"},
+				},
+				"required":             []string{"start", "end", "text"},
+				"additionalProperties": false,
+			},
+		},
+	},
+	"required":             []string{"text", "language", "segments"},
+	"additionalProperties": false,
+}
+
+// transcribeWithModel asks the model to transcribe the audio, for providers
+// without a speech-to-text endpoint.
+func (c *client) transcribeWithModel(ctx context.Context, tr TranscriptionRequest) (Transcription, error) {
+	instructions := "Transcribe the speech in this audio verbatim, without commentary."
+	if tr.Language != "" {
+		instructions += fmt.Sprintf(" It is spoken in %s.", tr.Language)
+	}
+	if tr.Prompt != "" {
+		instructions += " Context: " + tr.Prompt
+	}
+	if tr.Timestamps {
+		instructions += " Also split it into segments of a sentence or so, each with its start and end time."
+	} else {
+		instructions += " Leave segments empty."
+	}
+	res, err := c.generate(ctx, Request{
+		Inputs: []Input{InputText(instructions), InputFile(tr.Audio, tr.MIME, WithFileName(tr.Name))},
+		Output: OutputJSON(transcriptionSchema),
+		Model:  tr.Model,
+	})
+	if err != nil {
+		return Transcription{}, err
+	}
+	var out struct {
+		Text     string `json:"text"`
+		Language string `json:"language"`
+		Segments []struct {
+			Start float64 `json:"start"`
+			End   float64 `json:"end"`
+			Text  string  `json:"text"`
+		} `json:"segments"`
+	}
+	if err := res.DecodeJSON(&out); err != nil {
+		return Transcription{}, NewGrailError(OutputInvalid, fmt.Sprintf("decode transcription: %v", err)).WithCause(err).WithProviderName(res.Provider.Name)
+	}
+	t := Transcription{Text: out.Text, Language: out.Language, Usage: res.Usage, Provider: res.Provider}
+	if tr.Timestamps {
+		for _, s := range out.Segments {
+			t.Segments = append(t.Segments, TranscriptSegment{Start: seconds(s.Start), End: seconds(s.End), Text: s.Text})
+		}
+	}
+	return t, nil
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Round(s * float64(time.Second)))
+}
@@ -0,0 +1,55 @@
+package grail_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+type transcribingProvider struct {
+	*mock.Provider
+	got grail.TranscriptionRequest
+}
+
+func (p *transcribingProvider) Transcribe(ctx context.Context, req grail.TranscriptionRequest) (grail.Transcription, error) {
+	p.got = req
+	return grail.Transcription{Text: "Hello."}, nil
+}
+
+func TestTranscribe(t *testing.T) {
+	audio := grail.InputFile([]byte("ID3audio"), "audio/mpeg", grail.WithFileName("memo.mp3"))
+	ctx := context.Background()
+
+	prov := &transcribingProvider{Provider: &mock.Provider{}}
+	tr, err := grail.NewClient(prov).Transcribe(ctx, audio, grail.WithTranscriptionModel("whisper-1"), grail.WithTranscriptionPrompt("Grail"))
+	if err != nil || tr.Text != "Hello." || tr.Provider.Name != "mock" {
+		t.Fatalf("unexpected transcription %+v (%v)", tr, err)
+	}
+	if prov.got.Name != "memo.mp3" || prov.got.Model != "whisper-1" || prov.got.Prompt != "Grail" || string(prov.got.Audio) != "ID3audio" {
+		t.Errorf("unexpected request %+v", prov.got)
+	}
+
+	// Providers without a transcription endpoint are asked to transcribe.
+	mp, reqs := scripted(`{"text": "Hello there.", "language": "en", "segments": [{"start": 0, "end": 1.5, "text": "Hello there."}]}`)
+	tr, err = grail.NewClient(mp).Transcribe(ctx, audio, grail.WithTimestamps())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tr.Text != "Hello there." || len(tr.Segments) != 1 || tr.Segments[0].End != 1500*time.Millisecond || tr.Usage.InputTokens != 5 {
+		t.Errorf("unexpected transcription %+v", tr)
+	}
+	sent := (*reqs)[0]
+	if prompt, _ := grail.AsTextInput(sent.Inputs[0]); !strings.Contains(prompt, "segments") || len(sent.Inputs) != 2 {
+		t.Errorf("unexpected request %+v", sent)
+	}
+
+	for _, in := range []grail.Input{grail.InputText("hi"), grail.InputFile([]byte("%PDF"), "application/pdf"), grail.InputFile(nil, "audio/mpeg")} {
+		if _, err := grail.NewClient(mp).Transcribe(ctx, in); grail.GetErrorCode(err) != grail.InvalidArgument {
+			t.Errorf("expected InvalidArgument for %T, got %v", in, err)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package grail
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+//
+// Conversation transcripts
+//
+
+// TranscriptVersion is the format version written by Transcript.Export.
+const TranscriptVersion = 1
+
+// TurnRole identifies who produced a turn.
+type TurnRole string
+
+const (
+	TurnUser      TurnRole = "user"      // request inputs
+	TurnAssistant TurnRole = "assistant" // response outputs
+)
+
+// Transcript is a portable record of a conversation: the inputs of each request
+// and the outputs of each response, in order. File contents are stored once per
+// distinct content and referenced from turns by SHA-256 hash, so a transcript
+// can be exported without its attachments and the blobs kept elsewhere.
+type Transcript struct {
+	Version     int                   `json:"version"`
+	CreatedAt   time.Time             `json:"created_at"`
+	Turns       []Turn                `json:"turns"`
+	Attachments map[string]Attachment `json:"attachments,omitempty"` // keyed by Ref
+
+	data map[string][]byte
+}
+
+// Turn is one message of a conversation.
+type Turn struct {
+	Role      TurnRole   `json:"role"`
+	Parts     []TurnPart `json:"parts"`
+	Time      time.Time  `json:"time"`
+	Provider  string     `json:"provider,omitempty"`
+	Models    []ModelUse `json:"models,omitempty"`
+	RequestID string     `json:"request_id,omitempty"`
+	Usage     *Usage     `json:"usage,omitempty"`
+	Cost      *TurnCost  `json:"cost,omitempty"` // with WithSessionPrices
+}
+
+// TurnPart is a piece of a turn. Exactly one of Text, JSON, or Ref is set.
+type TurnPart struct {
+	Type   string          `json:"type"`           // "text", "json", "file", "image", "video", "tool_call", or "tool_result"
+	Text   string          `json:"text,omitempty"` // text, or a tool result's output
+	JSON   json.RawMessage `json:"json,omitempty"` // JSON output or tool call arguments
+	Ref    string          `json:"ref,omitempty"`  // attachment reference ("sha256:<hex>")
+	MIME   string          `json:"mime,omitempty"`
+	Name   string          `json:"name,omitempty"`    // file or tool name
+	CallID string          `json:"call_id,omitempty"` // tool call ID
+}
+
+// Attachment describes file content referenced by a transcript. Data is only
+// populated in exports made with includeData.
+type Attachment struct {
+	MIME string `json:"mime,omitempty"`
+	Size int    `json:"size"`
+	Data []byte `json:"data,omitempty"`
+}
+
+// NewTranscript returns an empty transcript.
+func NewTranscript() *Transcript {
+	return &Transcript{Version: TranscriptVersion, CreatedAt: time.Now().UTC()}
+}
+
+// AttachmentRef returns the reference a transcript uses for content.
+func AttachmentRef(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Record appends a request and its response to the transcript. Streamed file
+// inputs (InputFileReader) have already been consumed by the time a request
+// completes, so they are recorded by name and MIME type only.
+func (t *Transcript) Record(req Request, res Response) {
+	now := time.Now().UTC()
+	user := Turn{Role: TurnUser, Time: now}
+	for _, in := range req.Inputs {
+		switch v := in.(type) {
+		case textInput:
+			user.Parts = append(user.Parts, TurnPart{Type: "text", Text: v.Text})
+		case fileInput:
+			user.Parts = append(user.Parts, TurnPart{Type: "file", Ref: t.attach(v.Data, v.MIME), MIME: v.MIME, Name: v.Name})
+		case fileReaderInput:
+			user.Parts = append(user.Parts, TurnPart{Type: "file", MIME: v.MIME, Name: v.Name})
+		case toolResultInput:
+			user.Parts = append(user.Parts, TurnPart{Type: "tool_result", Text: v.Output, Name: v.Call.Name, CallID: v.Call.ID})
+		}
+	}
+
+	usage := res.Usage
+	assistant := Turn{
+		Role:      TurnAssistant,
+		Time:      now,
+		Provider:  res.Provider.Name,
+		Models:    res.Provider.Models,
+		RequestID: res.RequestID,
+		Usage:     &usage,
+	}
+	for _, out := range res.Outputs {
+		switch v := out.(type) {
+		case textOutputPart:
+			assistant.Parts = append(assistant.Parts, TurnPart{Type: "text", Text: v.Text})
+		case jsonOutputPart:
+			assistant.Parts = append(assistant.Parts, TurnPart{Type: "json", JSON: json.RawMessage(v.JSON)})
+		case imageOutputPart:
+			assistant.Parts = append(assistant.Parts, TurnPart{Type: "image", Ref: t.attach(v.Data, v.MIME), MIME: v.MIME, Name: v.Name})
+		case videoOutputPart:
+			assistant.Parts = append(assistant.Parts, TurnPart{Type: "video", Ref: t.attach(v.Data, v.MIME), MIME: v.MIME, Name: v.Name})
+		case toolCallOutputPart:
+			assistant.Parts = append(assistant.Parts, TurnPart{Type: "tool_call", JSON: v.Call.Arguments, Name: v.Call.Name, CallID: v.Call.ID})
+		}
+	}
+	t.Turns = append(t.Turns, user, assistant)
+}
+
+func (t *Transcript) attach(data []byte, mime string) string {
+	ref := AttachmentRef(data)
+	if t.Attachments == nil {
+		t.Attachments = map[string]Attachment{}
+		t.data = map[string][]byte{}
+	}
+	if _, ok := t.Attachments[ref]; !ok {
+		t.Attachments[ref] = Attachment{MIME: mime, Size: len(data)}
+		t.data[ref] = data
+	}
+	return ref
+}
+
+// AttachmentData returns the content for an attachment reference.
+func (t *Transcript) AttachmentData(ref string) ([]byte, bool) {
+	data, ok := t.data[ref]
+	return data, ok
+}
+
+// Inputs rebuilds the inputs of turn i, for replaying a user turn against a
+// client. Parts without content (streamed inputs) are skipped.
+func (t *Transcript) Inputs(i int) ([]Input, error) {
+	if i < 0 || i >= len(t.Turns) {
+		return nil, NewGrailError(InvalidArgument, fmt.Sprintf("turn %d out of range", i))
+	}
+	var inputs []Input
+	for _, p := range t.Turns[i].Parts {
+		switch {
+		case p.Type == "text":
+			inputs = append(inputs, InputText(p.Text))
+		case p.Type == "json":
+			inputs = append(inputs, InputText(string(p.JSON)))
+		case p.Ref != "":
+			data, ok := t.data[p.Ref]
+			if !ok {
+				return nil, NewGrailError(InvalidArgument, fmt.Sprintf("attachment %s not available", p.Ref))
+			}
+			var opts []FileOpt
+			if p.Name != "" {
+				opts = append(opts, WithFileName(p.Name))
+			}
+			inputs = append(inputs, InputFile(data, p.MIME, opts...))
+		}
+	}
+	return inputs, nil
+}
+
+// Export writes the transcript as JSON. With includeData, attachment contents
+// are embedded (base64); otherwise only their references, MIME types, and
+// sizes are written and the contents must be supplied on import.
+func (t *Transcript) Export(w io.Writer, includeData bool) error {
+	out := *t
+	out.Attachments = make(map[string]Attachment, len(t.Attachments))
+	for ref, a := range t.Attachments {
+		if includeData {
+			a.Data = t.data[ref]
+		}
+		out.Attachments[ref] = a
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return NewGrailError(Internal, fmt.Sprintf("encode transcript: %v", err)).WithCause(err)
+	}
+	return nil
+}
+
+// ImportTranscript reads a transcript written by Export. Attachments without
+// embedded data are fetched with resolve, which may be nil if every attachment
+// is embedded. Attachment contents are verified against their references.
+func ImportTranscript(r io.Reader, resolve func(ref string) ([]byte, error)) (*Transcript, error) {
+	var t Transcript
+	if err := json.NewDecoder(r).Decode(&t); err != nil {
+		return nil, NewGrailError(InvalidArgument, fmt.Sprintf("decode transcript: %v", err)).WithCause(err)
+	}
+	if t.Version > TranscriptVersion {
+		return nil, NewGrailError(Unsupported, fmt.Sprintf("transcript version %d is newer than supported version %d", t.Version, TranscriptVersion))
+	}
+	t.data = make(map[string][]byte, len(t.Attachments))
+	for ref, a := range t.Attachments {
+		data := a.Data
+		if data == nil {
+			if resolve == nil {
+				return nil, NewGrailError(InvalidArgument, fmt.Sprintf("attachment %s has no embedded data and no resolver was given", ref))
+			}
+			var err error
+			if data, err = resolve(ref); err != nil {
+				return nil, NewGrailError(InvalidArgument, fmt.Sprintf("resolve attachment %s: %v", ref, err)).WithCause(err)
+			}
+		}
+		if AttachmentRef(data) != ref {
+			return nil, NewGrailError(InvalidArgument, fmt.Sprintf("attachment %s does not match its content", ref))
+		}
+		a.Data = nil
+		t.Attachments[ref] = a
+		t.data[ref] = data
+	}
+	return &t, nil
+}
@@ -0,0 +1,85 @@
+package grail_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestTranscript(t *testing.T) {
+	pdf := []byte("%PDF-1.4 fake")
+	png := []byte("\x89PNG\r\n\x1a\nfake")
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{
+				Outputs:  []grail.OutputPart{grail.NewTextOutputPart("here"), grail.NewImageOutputPart(png, "image/png", "")},
+				Provider: grail.ProviderInfo{Name: "mock"},
+				Usage:    grail.Usage{TotalTokens: 3},
+			}, nil
+		},
+	}
+	client := grail.NewClient(prov)
+	tr := grail.NewTranscript()
+	for _, prompt := range []string{"first", "second"} {
+		req := grail.Request{
+			Inputs: []grail.Input{grail.InputText(prompt), grail.InputPDF(pdf, grail.WithFileName("doc.pdf"))},
+			Output: grail.OutputText(),
+		}
+		res, err := client.Generate(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		tr.Record(req, res)
+	}
+	if len(tr.Turns) != 4 || len(tr.Attachments) != 2 {
+		t.Fatalf("expected 4 turns and 2 deduplicated attachments, got %d and %d", len(tr.Turns), len(tr.Attachments))
+	}
+
+	t.Run("embedded round trip", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := tr.Export(&buf, true); err != nil {
+			t.Fatalf("export: %v", err)
+		}
+		got, err := grail.ImportTranscript(&buf, nil)
+		if err != nil {
+			t.Fatalf("import: %v", err)
+		}
+		inputs, err := got.Inputs(2)
+		if err != nil {
+			t.Fatalf("inputs: %v", err)
+		}
+		text, _ := grail.AsTextInput(inputs[0])
+		data, mime, name, _ := grail.AsFileInput(inputs[1])
+		if text != "second" || !bytes.Equal(data, pdf) || mime != "application/pdf" || name != "doc.pdf" {
+			t.Fatalf("unexpected inputs: %q %q %q %q", text, data, mime, name)
+		}
+	})
+
+	t.Run("references resolved on import", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := tr.Export(&buf, false); err != nil {
+			t.Fatalf("export: %v", err)
+		}
+		if strings.Contains(buf.String(), `"data"`) {
+			t.Fatalf("expected attachment data to be omitted")
+		}
+		exported := buf.String()
+		blobs := map[string][]byte{grail.AttachmentRef(pdf): pdf, grail.AttachmentRef(png): png}
+		got, err := grail.ImportTranscript(strings.NewReader(exported), func(ref string) ([]byte, error) { return blobs[ref], nil })
+		if err != nil {
+			t.Fatalf("import: %v", err)
+		}
+		if data, ok := got.AttachmentData(grail.AttachmentRef(png)); !ok || !bytes.Equal(data, png) {
+			t.Fatalf("expected image attachment to resolve")
+		}
+
+		_, err = grail.ImportTranscript(strings.NewReader(exported), func(ref string) ([]byte, error) { return []byte("tampered"), nil })
+		if grail.GetErrorCode(err) != grail.InvalidArgument {
+			t.Fatalf("expected invalid_argument for mismatched attachment, got %v", err)
+		}
+	})
+}
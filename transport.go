@@ -0,0 +1,173 @@
+package grail
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/montanaflynn/grail/internal/httplog"
+)
+
+//
+// HTTP transport instrumentation
+//
+
+// TransportAware is an optional interface for providers whose HTTP transport
+// the client can wrap. The wrapper sits beneath the provider SDK, so it sees
+// every attempt the SDK makes, including its internal retries.
+type TransportAware interface {
+	WrapTransport(wrap func(http.RoundTripper) http.RoundTripper)
+}
+
+// WithTransportLogging logs every provider HTTP round trip at level through the
+// client's logger: method, URL, status, duration, provider request ID, and the
+// attempt number within a Generate call. Bodies are never logged and
+// credential query parameters are redacted. It has no effect on providers that
+// don't implement TransportAware, and on child clients created with With.
+func WithTransportLogging(level LoggerLevel) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		l := slog.Level(level)
+		co.transportLogLevel = &l
+	})
+}
+
+// WithWireDump writes every provider HTTP round trip to a JSON file in dir,
+// named by the provider's request ID (the x-request-id header and its
+// equivalents): the exact request and response bodies, with credentials in
+// headers, query parameters, and JSON fields redacted. Dumps include inline
+// file data and can be large; use it for debugging, not in production. Like
+// WithTransportLogging, it has no effect on providers that don't implement
+// TransportAware, and on child clients created with With.
+func WithWireDump(dir string) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.wireDumpDir = dir
+	})
+}
+
+// transportScope is a client's configuration of its provider's HTTP
+// transport. Clients sharing a provider share its transport, so the
+// configuration isn't installed on it: it travels in each call's context to
+// the clientTransport beneath the provider's SDK, and applies only to the
+// calls of the client that set it.
+type transportScope struct {
+	c        *client
+	logLevel *slog.Level
+	dumpDir  string
+	airGap   *AirGap
+	tls      *TLSPolicy
+	attempts int
+
+	once sync.Once
+	rt   http.RoundTripper
+}
+
+type transportScopeKey struct{}
+
+// newTransportScope returns the transport configuration co asks for, or nil
+// if it asks for none.
+func (c *client) newTransportScope(co *clientOpt) *transportScope {
+	if co.transportLogLevel == nil && co.wireDumpDir == "" && co.airGap == nil && co.tlsPolicy == nil && co.attemptDeadlines < 2 {
+		return nil
+	}
+	return &transportScope{
+		c:        c,
+		logLevel: co.transportLogLevel,
+		dumpDir:  co.wireDumpDir,
+		airGap:   co.airGap,
+		tls:      co.tlsPolicy,
+		attempts: co.attemptDeadlines,
+	}
+}
+
+// installTransport installs the clientTransport beneath p's SDK, once per
+// provider however many clients share it, and gives c its configuration.
+func (c *client) installTransport(p Provider, co *clientOpt) {
+	ta, ok := p.(TransportAware)
+	if !ok {
+		return
+	}
+	ta.WrapTransport(func(base http.RoundTripper) http.RoundTripper {
+		if _, ok := base.(*clientTransport); ok {
+			return base
+		}
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		return &clientTransport{base: base, events: &eventTransport{base: base}}
+	})
+	c.transport = c.newTransportScope(co)
+	c.countAttempts = co.transportLogLevel != nil
+	c.egressGuarded = co.airGap != nil
+	c.tlsEnforced = co.tlsPolicy != nil
+	c.budgetAttempts = co.attemptDeadlines >= 2
+}
+
+// withTransport returns ctx carrying c's transport configuration, in place of
+// any from another client's call.
+func (c *client) withTransport(ctx context.Context) context.Context {
+	return context.WithValue(ctx, transportScopeKey{}, c.transport)
+}
+
+// roundTripper returns the transport layers of the configuration over base.
+func (s *transportScope) roundTripper(base http.RoundTripper) http.RoundTripper {
+	s.once.Do(func() {
+		rt := base
+		// The TLS policy goes first, so it configures the provider's own
+		// transport rather than a wrapper.
+		if s.tls != nil {
+			rt = s.tls.transport(rt)
+		}
+		if s.logLevel != nil {
+			rt = &httplog.Transport{
+				Base:     rt,
+				Logger:   func() *slog.Logger { return s.c.log },
+				Provider: s.c.provider.Name(),
+				Level:    *s.logLevel,
+				Attempts: true,
+			}
+		}
+		if s.dumpDir != "" {
+			rt = &httplog.Dumper{
+				Base:     rt,
+				Dir:      s.dumpDir,
+				Provider: s.c.provider.Name(),
+				OnError: func(err error) {
+					if s.c.log != nil {
+						s.c.log.Warn("wire dump failed", slog.String("error", err.Error()))
+					}
+				},
+			}
+		}
+		if s.airGap != nil {
+			rt = &egressGuard{base: rt, allowed: s.airGap.AllowedHosts, log: func() *slog.Logger { return s.c.log }}
+		}
+		if s.attempts >= 2 {
+			rt = &attemptBudget{base: rt, attempts: s.attempts}
+		}
+		s.rt = &eventTransport{base: rt}
+	})
+	return s.rt
+}
+
+// clientTransport applies the transport configuration of the client making
+// each request, and reports its retries to the client's events.
+type clientTransport struct {
+	base   http.RoundTripper
+	events *eventTransport // over base, for clients without configuration
+}
+
+type transportAppliedKey struct{}
+
+func (t *clientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if ctx.Value(transportAppliedKey{}) != nil {
+		// A clientTransport above this one has applied it already.
+		return t.base.RoundTrip(req)
+	}
+	req = req.WithContext(context.WithValue(ctx, transportAppliedKey{}, true))
+	if s, _ := ctx.Value(transportScopeKey{}).(*transportScope); s != nil {
+		return s.roundTripper(t.base).RoundTrip(req)
+	}
+	return t.events.RoundTrip(req)
+}
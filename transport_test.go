@@ -0,0 +1,211 @@
+package grail_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/openai"
+)
+
+// retryingProvider retries once on a 503, like provider SDKs do internally.
+type retryingProvider struct {
+	url string
+	rt  http.RoundTripper
+}
+
+func (p *retryingProvider) Name() string { return "retrying" }
+
+func (p *retryingProvider) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	p.rt = wrap(p.rt)
+}
+
+func (p *retryingProvider) DoGenerate(ctx context.Context, req grail.Request) (grail.Response, error) {
+	hc := &http.Client{Transport: p.rt}
+	for {
+		hreq, _ := http.NewRequestWithContext(ctx, http.MethodPost, p.url+"?key=secret", strings.NewReader(`{"api_key":"secret"}`))
+		res, err := hc.Do(hreq)
+		if err != nil {
+			return grail.Response{}, err
+		}
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		if res.StatusCode == http.StatusOK {
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("ok")}}, nil
+		}
+	}
+}
+
+func TestWithTransportLogging(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	var logs bytes.Buffer
+	client := grail.NewClient(&retryingProvider{url: srv.URL, rt: http.DefaultTransport},
+		grail.WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		grail.WithTransportLogging(grail.LoggerLevelInfo),
+	)
+	if _, err := client.Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("hi")},
+		Output: grail.OutputText(),
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := logs.String()
+	for _, want := range []string{"attempt=1", "status=503", "attempt=2", "status=200", "provider=retrying", "duration="} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in logs:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "secret") {
+		t.Fatalf("credentials or bodies leaked into logs:\n%s", out)
+	}
+}
+
+func TestWithWireDump(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-Request-Id", fmt.Sprintf("req_%d", calls))
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `{"output":"ok"}`)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	client := grail.NewClient(&retryingProvider{url: srv.URL, rt: http.DefaultTransport}, grail.WithWireDump(dir))
+	if _, err := client.Generate(context.Background(), grail.Request{
+		Inputs: []grail.Input{grail.InputText("hi")},
+		Output: grail.OutputText(),
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "retrying-req_2.json"))
+	if err != nil {
+		t.Fatalf("expected a dump named by request ID: %v", err)
+	}
+	var dump struct {
+		Request struct {
+			URL  string
+			Body map[string]string
+		}
+		Response struct {
+			Status int
+			Body   map[string]string
+		}
+	}
+	if err := json.Unmarshal(data, &dump); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Fatalf("expected credentials to be redacted:\n%s", data)
+	}
+	if dump.Response.Status != http.StatusOK || dump.Response.Body["output"] != "ok" || dump.Request.Body["api_key"] != "REDACTED" {
+		t.Fatalf("unexpected dump:\n%s", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "retrying-req_1.json")); err != nil {
+		t.Fatalf("expected the failed attempt to be dumped too: %v", err)
+	}
+}
+
+func TestNewClientWhileServing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"resp_1","object":"response","status":"completed","model":"gpt-5.4",
+			"output":[{"type":"message","id":"msg_1","role":"assistant","status":"completed",
+				"content":[{"type":"output_text","text":"hi","annotations":[]}]}]}`)
+	}))
+	defer srv.Close()
+	p, err := openai.New(openai.WithAPIKey("dummy"), openai.WithBaseURL(srv.URL+"/v1/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := grail.NewClient(p)
+
+	// Clients created on the shared provider while it serves requests wrap
+	// its transport; that mustn't race with the requests (run with -race).
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 10 {
+			grail.NewClient(p, grail.WithTransportLogging(grail.LoggerLevelDebug))
+		}
+	}()
+	for range 10 {
+		if _, err := client.Generate(context.Background(), grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	wg.Wait()
+}
+
+func TestWithTransportLogging_SharedProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	// Clients sharing a provider each log their own round trips, once, to
+	// their own logger; a client without transport logging logs none.
+	p := &retryingProvider{url: srv.URL, rt: http.DefaultTransport}
+	logs := make([]bytes.Buffer, 3)
+	clients := make([]grail.Client, 3)
+	for i := range clients {
+		opts := []grail.ClientOption{grail.WithLogger(slog.New(slog.NewTextHandler(&logs[i], nil)))}
+		if i < 2 {
+			opts = append(opts, grail.WithTransportLogging(grail.LoggerLevelInfo))
+		}
+		clients[i] = grail.NewClient(p, opts...)
+	}
+	req := grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()}
+	for _, c := range clients {
+		if _, err := c.Generate(context.Background(), req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	for i, want := range []int{1, 1, 0} {
+		if n := strings.Count(logs[i].String(), "http response"); n != want {
+			t.Errorf("expected client %d to log %d round trips, got %d:\n%s", i, want, n, logs[i].String())
+		}
+	}
+}
+
+func TestWithWireDump_SharedProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req_1")
+	}))
+	defer srv.Close()
+
+	// A client sharing a provider with one that dumps doesn't write dumps.
+	p := &retryingProvider{url: srv.URL, rt: http.DefaultTransport}
+	dir := t.TempDir()
+	grail.NewClient(p, grail.WithWireDump(dir))
+	if _, err := grail.NewClient(p).Generate(context.Background(), grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText()}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected no dumps from the other client, got %d", len(entries))
+	}
+}
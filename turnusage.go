@@ -0,0 +1,73 @@
+package grail
+
+import "maps"
+
+//
+// Per-turn usage and cost
+//
+
+// TurnCost is what an assistant turn cost, in USD.
+type TurnCost struct {
+	USD     float64 `json:"usd"`
+	Savings float64 `json:"savings_usd,omitempty"` // saved by the prompt cache
+}
+
+// WithSessionPrices prices each reply of a session, so chat products can
+// show and bill what every message cost. Assistant turns whose model has a
+// price get a Cost alongside their Usage; see Session.Usage for the running
+// total.
+func WithSessionPrices(prices PriceTable) SessionOption {
+	return sessionOptFunc(func(so *sessionOpt) {
+		so.prices = maps.Clone(prices)
+	})
+}
+
+// priceTurn sets the cost of an assistant turn whose model has a price.
+func (pt PriceTable) priceTurn(t *Turn) {
+	if t.Usage == nil || len(t.Models) == 0 {
+		return
+	}
+	p, ok := pt[t.Models[0].Name]
+	if !ok {
+		return
+	}
+	t.Cost = &TurnCost{USD: p.Cost(*t.Usage), Savings: p.Savings(*t.Usage)}
+}
+
+// ConversationUsage is the usage and cost of a conversation so far.
+type ConversationUsage struct {
+	Turns    int     `json:"turns"` // assistant turns
+	Usage    Usage   `json:"usage"`
+	Cost     float64 `json:"cost_usd"`
+	Savings  float64 `json:"savings_usd"`
+	Unpriced int     `json:"unpriced"` // turns without a Cost, not counted in it
+}
+
+// Usage totals the usage and cost of the transcript's assistant turns.
+func (t *Transcript) Usage() ConversationUsage {
+	var cu ConversationUsage
+	for _, turn := range t.Turns {
+		if turn.Role != TurnAssistant {
+			continue
+		}
+		cu.Turns++
+		if turn.Usage != nil {
+			cu.Usage = cu.Usage.Add(*turn.Usage)
+		}
+		if turn.Cost == nil {
+			cu.Unpriced++
+			continue
+		}
+		cu.Cost += turn.Cost.USD
+		cu.Savings += turn.Cost.Savings
+	}
+	return cu
+}
+
+// Usage totals the usage and cost of the session's replies. Each reply's own
+// usage and cost are on its turn in the transcript.
+func (s *Session) Usage() ConversationUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.transcript.Usage()
+}
@@ -0,0 +1,88 @@
+package grail_test
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestPriceCachedInput(t *testing.T) {
+	p := grail.Price{InputPerMTok: 2, OutputPerMTok: 8, CachedInputPerMTok: 0.5}
+	u := grail.Usage{InputTokens: 1_000_000, OutputTokens: 500_000, TotalTokens: 1_500_000, CachedInputTokens: 600_000}
+	if got := p.Cost(u); math.Abs(got-5.1) > 1e-9 {
+		t.Errorf("expected cost 5.1, got %v", got)
+	}
+	if got := p.Savings(u); math.Abs(got-0.9) > 1e-9 {
+		t.Errorf("expected savings 0.9, got %v", got)
+	}
+	undiscounted := grail.Price{InputPerMTok: 2, OutputPerMTok: 8}
+	if got := undiscounted.Cost(u); got != 6 || undiscounted.Savings(u) != 0 {
+		t.Errorf("expected cached tokens at the input price without a cached price, got %v", got)
+	}
+}
+
+func TestSessionTurnUsage(t *testing.T) {
+	mp := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			model := req.Model
+			if model == "" {
+				model = "priced"
+			}
+			return grail.Response{
+				Outputs:  []grail.OutputPart{grail.NewTextOutputPart("ok")},
+				Usage:    grail.Usage{InputTokens: 1000, OutputTokens: 100, TotalTokens: 1100, CachedInputTokens: 800},
+				Provider: grail.ProviderInfo{Name: "mock", Models: []grail.ModelUse{{Role: "language", Name: model}}},
+			}, nil
+		},
+	}
+	ctx := context.Background()
+	s := grail.NewSession(grail.NewClient(mp), grail.WithSessionPrices(grail.PriceTable{
+		"priced": {InputPerMTok: 1, OutputPerMTok: 10, CachedInputPerMTok: 0.1},
+	}))
+	if _, err := s.Send(ctx, grail.InputText("one")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := s.Generate(ctx, grail.Request{Inputs: []grail.Input{grail.InputText("two")}, Output: grail.OutputText(), Model: "unpriced"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	turns := s.Transcript().Turns
+	if turns[0].Cost != nil {
+		t.Errorf("expected no cost on user turns, got %+v", turns[0].Cost)
+	}
+	cost := turns[1].Cost
+	if cost == nil || math.Abs(cost.USD-0.00128) > 1e-12 || math.Abs(cost.Savings-0.00072) > 1e-12 {
+		t.Fatalf("unexpected turn cost %+v", cost)
+	}
+	if turns[1].Usage.CachedInputTokens != 800 {
+		t.Errorf("expected cached tokens on the turn, got %+v", turns[1].Usage)
+	}
+	if turns[3].Cost != nil {
+		t.Errorf("expected no cost for an unpriced model, got %+v", turns[3].Cost)
+	}
+
+	total := s.Usage()
+	if total.Turns != 2 || total.Unpriced != 1 || total.Usage.InputTokens != 2000 || total.Usage.CachedInputTokens != 1600 {
+		t.Errorf("unexpected totals %+v", total)
+	}
+	if math.Abs(total.Cost-0.00128) > 1e-12 || math.Abs(total.Savings-0.00072) > 1e-12 {
+		t.Errorf("expected only the priced turn's cost, got %+v", total)
+	}
+
+	// Costs survive an export, so a restored conversation keeps its bill.
+	var buf bytes.Buffer
+	if err := s.Transcript().Export(&buf, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	restored, err := grail.ImportTranscript(&buf, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := restored.Usage(); got != total {
+		t.Errorf("expected %+v after import, got %+v", total, got)
+	}
+}
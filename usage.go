@@ -0,0 +1,327 @@
+package grail
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//
+// Usage tracking and cost reports
+//
+
+// Price is what a model charges, in USD per million tokens.
+type Price struct {
+	InputPerMTok  float64 `json:"input_per_mtok"`
+	OutputPerMTok float64 `json:"output_per_mtok"`
+	// CachedInputPerMTok is charged for input tokens read from the prompt
+	// cache. Zero means cached tokens cost the same as other input.
+	CachedInputPerMTok float64 `json:"cached_input_per_mtok,omitempty"`
+	// ImageInputPerMTok and ImageOutputPerMTok are charged for image tokens
+	// (see Usage.ImageInputTokens). Zero means image tokens cost the same as
+	// text.
+	ImageInputPerMTok  float64 `json:"image_input_per_mtok,omitempty"`
+	ImageOutputPerMTok float64 `json:"image_output_per_mtok,omitempty"`
+}
+
+// Cost is what a request cost, in USD.
+type Cost struct {
+	Input   float64 `json:"input_usd"` // text, image, and cached input
+	Output  float64 `json:"output_usd"`
+	Total   float64 `json:"total_usd"`
+	Savings float64 `json:"savings_usd,omitempty"` // saved by the prompt cache, already off Input
+}
+
+// Estimate returns the cost of u at p, split into input and output.
+func (p Price) Estimate(u Usage) Cost {
+	imageIn, imageOut := p.ImageInputPerMTok, p.ImageOutputPerMTok
+	if imageIn == 0 {
+		imageIn = p.InputPerMTok
+	}
+	if imageOut == 0 {
+		imageOut = p.OutputPerMTok
+	}
+	c := Cost{Savings: p.Savings(u)}
+	c.Input = (float64(u.InputTokens-u.ImageInputTokens)*p.InputPerMTok+float64(u.ImageInputTokens)*imageIn)/1e6 - c.Savings
+	c.Output = (float64(u.OutputTokens-u.ImageOutputTokens)*p.OutputPerMTok + float64(u.ImageOutputTokens)*imageOut) / 1e6
+	c.Total = c.Input + c.Output
+	return c
+}
+
+// Cost returns the cost of u at p, in USD.
+func (p Price) Cost(u Usage) float64 {
+	return p.Estimate(u).Total
+}
+
+// Savings returns what the prompt cache saved on u at p, in USD: the
+// difference between its cached input tokens at the input and cached prices.
+func (p Price) Savings(u Usage) float64 {
+	if p.CachedInputPerMTok == 0 {
+		return 0
+	}
+	return float64(u.CachedInputTokens) * (p.InputPerMTok - p.CachedInputPerMTok) / 1e6
+}
+
+// PriceTable maps model names to prices.
+type PriceTable map[string]Price
+
+// DefaultTenantKey is the metadata key UsageTracker attributes usage to
+// tenants by.
+const DefaultTenantKey = "tenant"
+
+// UsageRecord is the usage of one successful Generate call.
+type UsageRecord struct {
+	Time     time.Time         `json:"time"`
+	Provider string            `json:"provider"`
+	Model    string            `json:"model"`
+	Tenant   string            `json:"tenant,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Usage    Usage             `json:"usage"`
+	Cost     float64           `json:"cost_usd"`
+	Priced   bool              `json:"priced"` // false when the model has no price
+}
+
+// UsageTracker records the usage and cost of every request made through
+// clients it is attached to (see WithUsageTracker). Records are kept in memory
+// until pruned.
+type UsageTracker struct {
+	// TenantKey is the metadata key identifying the tenant (default
+	// DefaultTenantKey).
+	TenantKey string
+
+	mu      sync.Mutex
+	prices  PriceTable
+	records []UsageRecord
+	now     func() time.Time
+}
+
+// NewUsageTracker returns a tracker that prices usage with prices. Models
+// missing from prices are tracked at the response's own Cost, if it has one,
+// and otherwise with zero cost and reported as unpriced.
+func NewUsageTracker(prices PriceTable) *UsageTracker {
+	return &UsageTracker{prices: maps.Clone(prices), now: time.Now}
+}
+
+// WithUsageTracker records every successful Generate call in t, with the
+// request's metadata (including context metadata).
+func WithUsageTracker(t *UsageTracker) ClientOption {
+	return clientOptFunc(func(co *clientOpt) {
+		co.usageTracker = t
+	})
+}
+
+// SetPrice sets or replaces the price of a model for future records.
+func (t *UsageTracker) SetPrice(model string, p Price) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.prices == nil {
+		t.prices = PriceTable{}
+	}
+	t.prices[model] = p
+}
+
+// Record adds the usage of a completed request.
+func (t *UsageTracker) Record(req Request, res Response) {
+	model := req.Model
+	if len(res.Provider.Models) > 0 {
+		model = res.Provider.Models[0].Name
+	}
+	tenantKey := t.tenantKey()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rec := UsageRecord{
+		Time:     t.now().UTC(),
+		Provider: res.Provider.Name,
+		Model:    model,
+		Tenant:   req.Metadata[tenantKey],
+		Metadata: copyMetadata(req.Metadata),
+		Usage:    res.Usage,
+	}
+	if p, ok := t.prices[model]; ok {
+		rec.Cost, rec.Priced = p.Cost(res.Usage), true
+	} else if res.Cost != nil {
+		rec.Cost, rec.Priced = res.Cost.Total, true
+	}
+	t.records = append(t.records, rec)
+}
+
+func (t *UsageTracker) tenantKey() string {
+	if t.TenantKey != "" {
+		return t.TenantKey
+	}
+	return DefaultTenantKey
+}
+
+// Records returns the records made within period.
+func (t *UsageTracker) Records(period Period) []UsageRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var out []UsageRecord
+	for _, r := range t.records {
+		if period.Contains(r.Time) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Prune drops records made before cutoff.
+func (t *UsageTracker) Prune(cutoff time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.records = slices.DeleteFunc(t.records, func(r UsageRecord) bool { return r.Time.Before(cutoff) })
+}
+
+// Period is a half-open time range [Start, End). A zero Start or End leaves
+// that side unbounded.
+type Period struct {
+	Start time.Time `json:"start,omitzero"`
+	End   time.Time `json:"end,omitzero"`
+}
+
+// Contains reports whether t falls within p.
+func (p Period) Contains(t time.Time) bool {
+	return (p.Start.IsZero() || !t.Before(p.Start)) && (p.End.IsZero() || t.Before(p.End))
+}
+
+// Month returns the calendar month containing t, in t's location.
+func Month(t time.Time) Period {
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return Period{Start: start, End: start.AddDate(0, 1, 0)}
+}
+
+// CostReport summarizes usage and cost over a period.
+type CostReport struct {
+	Period   Period                `json:"period"`
+	Total    CostLine              `json:"total"`
+	ByModel  []CostLine            `json:"by_model"`
+	ByTenant []CostLine            `json:"by_tenant,omitempty"`
+	ByTag    map[string][]CostLine `json:"by_tag,omitempty"` // keyed by metadata key
+}
+
+// CostLine is the usage and cost of one group of requests.
+type CostLine struct {
+	Key      string  `json:"key"`
+	Requests int     `json:"requests"`
+	Usage    Usage   `json:"usage"`
+	Cost     float64 `json:"cost_usd"`
+	Unpriced int     `json:"unpriced,omitempty"` // requests whose model had no price
+}
+
+func (l *CostLine) add(r UsageRecord) {
+	l.Requests++
+	l.Usage = l.Usage.Add(r.Usage)
+	l.Cost += r.Cost
+	if !r.Priced {
+		l.Unpriced++
+	}
+}
+
+// Report summarizes the records made within period, grouped by model, tenant,
+// and each other metadata key. Groups are sorted by descending cost.
+func (t *UsageTracker) Report(period Period) CostReport {
+	records := t.Records(period)
+	tenantKey := t.tenantKey()
+	rep := CostReport{Period: period, Total: CostLine{Key: "total"}}
+	byModel := map[string]*CostLine{}
+	byTenant := map[string]*CostLine{}
+	byTag := map[string]map[string]*CostLine{}
+	line := func(m map[string]*CostLine, key string) *CostLine {
+		if m[key] == nil {
+			m[key] = &CostLine{Key: key}
+		}
+		return m[key]
+	}
+	for _, r := range records {
+		rep.Total.add(r)
+		line(byModel, r.Model).add(r)
+		if r.Tenant != "" {
+			line(byTenant, r.Tenant).add(r)
+		}
+		for k, v := range r.Metadata {
+			if k == tenantKey {
+				continue
+			}
+			if byTag[k] == nil {
+				byTag[k] = map[string]*CostLine{}
+			}
+			line(byTag[k], v).add(r)
+		}
+	}
+	rep.ByModel = sortedLines(byModel)
+	rep.ByTenant = sortedLines(byTenant)
+	if len(byTag) > 0 {
+		rep.ByTag = make(map[string][]CostLine, len(byTag))
+		for k, m := range byTag {
+			rep.ByTag[k] = sortedLines(m)
+		}
+	}
+	return rep
+}
+
+func sortedLines(m map[string]*CostLine) []CostLine {
+	lines := make([]CostLine, 0, len(m))
+	for _, l := range m {
+		lines = append(lines, *l)
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].Cost != lines[j].Cost {
+			return lines[i].Cost > lines[j].Cost
+		}
+		return lines[i].Key < lines[j].Key
+	})
+	return lines
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r CostReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return NewGrailError(Internal, fmt.Sprintf("encode cost report: %v", err)).WithCause(err)
+	}
+	return nil
+}
+
+// WriteCSV writes the report as CSV with one row per group. The dimension
+// column is "total", "model", "tenant", or "tag:<metadata key>".
+func (r CostReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	write := func(dim string, l CostLine) {
+		cw.Write([]string{
+			dim, l.Key,
+			strconv.Itoa(l.Requests),
+			strconv.Itoa(l.Usage.InputTokens),
+			strconv.Itoa(l.Usage.OutputTokens),
+			strconv.Itoa(l.Usage.TotalTokens),
+			strconv.FormatFloat(l.Cost, 'f', 6, 64),
+			strconv.Itoa(l.Unpriced),
+		})
+	}
+	cw.Write([]string{"dimension", "key", "requests", "input_tokens", "output_tokens", "total_tokens", "cost_usd", "unpriced"})
+	write("total", r.Total)
+	for _, l := range r.ByModel {
+		write("model", l)
+	}
+	for _, l := range r.ByTenant {
+		write("tenant", l)
+	}
+	for _, k := range slices.Sorted(maps.Keys(r.ByTag)) {
+		for _, l := range r.ByTag[k] {
+			write("tag:"+k, l)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return NewGrailError(Internal, fmt.Sprintf("write cost report: %v", err)).WithCause(err)
+	}
+	return nil
+}
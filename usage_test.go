@@ -0,0 +1,137 @@
+package grail_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestUsageTrackerReport(t *testing.T) {
+	prov := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			return grail.Response{
+				Outputs:  []grail.OutputPart{grail.NewTextOutputPart("ok")},
+				Usage:    grail.Usage{InputTokens: 1000, OutputTokens: 500, TotalTokens: 1500},
+				Provider: grail.ProviderInfo{Name: "mock", Models: []grail.ModelUse{{Role: "language", Name: req.Model}}},
+			}, nil
+		},
+	}
+	tracker := grail.NewUsageTracker(grail.PriceTable{
+		"big":   {InputPerMTok: 10, OutputPerMTok: 30},
+		"small": {InputPerMTok: 1, OutputPerMTok: 2},
+	})
+	client := grail.NewClient(prov, grail.WithUsageTracker(tracker))
+
+	calls := []struct{ model, tenant, feature string }{
+		{"big", "acme", "chat"},
+		{"big", "acme", "search"},
+		{"small", "globex", "chat"},
+		{"unknown", "globex", "chat"},
+	}
+	for _, c := range calls {
+		ctx := grail.ContextWithMetadata(context.Background(), "tenant", c.tenant, "feature", c.feature)
+		if _, err := client.Generate(ctx, grail.Request{Inputs: []grail.Input{grail.InputText("hi")}, Output: grail.OutputText(), Model: c.model}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	rep := tracker.Report(grail.Month(time.Now()))
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	if rep.Total.Requests != 4 || rep.Total.Unpriced != 1 || !near(rep.Total.Cost, 2*0.025+0.002) {
+		t.Fatalf("unexpected total: %+v", rep.Total)
+	}
+	if len(rep.ByModel) != 3 || rep.ByModel[0].Key != "big" || rep.ByModel[0].Requests != 2 {
+		t.Fatalf("expected most expensive model first, got %+v", rep.ByModel)
+	}
+	if len(rep.ByTenant) != 2 || rep.ByTenant[0].Key != "acme" || !near(rep.ByTenant[1].Cost, 0.002) {
+		t.Fatalf("unexpected tenants: %+v", rep.ByTenant)
+	}
+	if _, ok := rep.ByTag["tenant"]; ok || len(rep.ByTag["feature"]) != 2 {
+		t.Fatalf("unexpected tags: %+v", rep.ByTag)
+	}
+	if empty := tracker.Report(grail.Period{End: time.Now().Add(-time.Hour)}); empty.Total.Requests != 0 {
+		t.Fatalf("expected no records before the period end, got %+v", empty.Total)
+	}
+
+	var buf bytes.Buffer
+	if err := rep.WriteCSV(&buf); err != nil {
+		t.Fatalf("write csv: %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(rows) != 1+1+3+2+2 || rows[1][0] != "total" || rows[8][0] != "tag:feature" {
+		t.Fatalf("unexpected csv (%v): %v", err, rows)
+	}
+	buf.Reset()
+	if err := rep.WriteJSON(&buf); err != nil || !json.Valid(buf.Bytes()) {
+		t.Fatalf("write json: %v", err)
+	}
+
+	tracker.Prune(time.Now().Add(time.Hour))
+	if n := len(tracker.Records(grail.Period{})); n != 0 {
+		t.Fatalf("expected prune to drop all records, got %d", n)
+	}
+}
+
+type stubUsageSource []grail.ProviderUsage
+
+func (stubUsageSource) Name() string { return "mock" }
+
+func (s stubUsageSource) ProviderUsage(ctx context.Context, period grail.Period) ([]grail.ProviderUsage, error) {
+	return s, nil
+}
+
+func TestUsageTrackerReconcile(t *testing.T) {
+	tracker := grail.NewUsageTracker(grail.PriceTable{"big": {InputPerMTok: 10, OutputPerMTok: 30}})
+	for _, model := range []string{"big", "big", "small"} {
+		tracker.Record(grail.Request{Model: model}, grail.Response{
+			Usage:    grail.Usage{InputTokens: 1000, OutputTokens: 500, TotalTokens: 1500},
+			Provider: grail.ProviderInfo{Name: "mock", Models: []grail.ModelUse{{Name: model}}},
+		})
+	}
+	tracker.Record(grail.Request{}, grail.Response{
+		Usage:    grail.Usage{InputTokens: 1},
+		Provider: grail.ProviderInfo{Name: "other", Models: []grail.ModelUse{{Name: "elsewhere"}}},
+	})
+
+	src := stubUsageSource{
+		{Model: "big", Requests: 2, Usage: grail.Usage{InputTokens: 1000, OutputTokens: 500}, Cost: 0.025, HasCost: true},
+		{Model: "big", Usage: grail.Usage{InputTokens: 1010, OutputTokens: 500}, Cost: 0.025, HasCost: true},
+		{Model: "small", Requests: 3, Usage: grail.Usage{InputTokens: 3000, OutputTokens: 500}},
+		{Model: "untracked", Usage: grail.Usage{InputTokens: 50}},
+	}
+	rec, err := tracker.Reconcile(context.Background(), src, grail.Period{}, 0.02)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rec.Provider != "mock" || len(rec.Lines) != 3 {
+		t.Fatalf("expected lines for big, small, and untracked only, got %+v", rec.Lines)
+	}
+	if big := rec.Lines[0]; big.Model != "big" || big.Flagged || big.Provider.Usage.InputTokens != 2010 {
+		t.Fatalf("expected big to agree within tolerance, got %+v", big)
+	}
+	flagged := rec.Flagged()
+	if len(flagged) != 2 || flagged[0].Model != "small" || flagged[1].Model != "untracked" {
+		t.Fatalf("unexpected flagged lines %+v", flagged)
+	}
+	want := []string{"input tokens: local 1000, provider 3000", "requests: local 1, provider 3"}
+	if got := flagged[0].Reasons; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("unexpected reasons %q", got)
+	}
+}
+
+func TestUsageJSON(t *testing.T) {
+	b, err := json.Marshal(grail.Usage{InputTokens: 3, OutputTokens: 2, TotalTokens: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"InputTokens":3,"OutputTokens":2,"TotalTokens":5}` {
+		t.Errorf("expected unreported breakdowns to be omitted, got %s", b)
+	}
+}
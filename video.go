@@ -0,0 +1,117 @@
+package grail
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+//
+// Video output
+//
+
+// VideoSpec describes the clip OutputVideo asks for. Zero values leave the
+// choice to the provider.
+type VideoSpec struct {
+	// DurationSeconds is the clip's length. Providers support a few fixed
+	// lengths, such as 4, 6, or 8 seconds for Veo and 4, 8, or 12 for Sora.
+	DurationSeconds int
+	// AspectRatio is "16:9" (landscape) or "9:16" (portrait).
+	AspectRatio string
+}
+
+type videoOutput struct {
+	Spec VideoSpec
+}
+
+func (videoOutput) isOutput() {}
+
+// OutputVideo asks for a generated video clip, from Gemini's Veo or OpenAI's
+// Sora models. The request's text inputs are the prompt, and an image input,
+// if any, is the first frame.
+//
+// Videos take minutes to generate. Providers start a generation job and poll
+// it until the video is ready, so give the request a context deadline to
+// match, and don't wrap the client in a short WithAttemptTimeout.
+func OutputVideo(spec VideoSpec) Output {
+	return videoOutput{Spec: spec}
+}
+
+// GetVideoSpec returns the spec of a video output.
+func GetVideoSpec(output Output) (VideoSpec, bool) {
+	if v, ok := output.(videoOutput); ok {
+		return v.Spec, true
+	}
+	return VideoSpec{}, false
+}
+
+type videoOutputPart struct {
+	Data    []byte
+	MIME    string
+	Name    string
+	SynthID bool // provider reports an invisible SynthID watermark
+}
+
+func (videoOutputPart) isOutputPart() {}
+
+// NewVideoOutputPart returns a video output part, for providers. WithSynthID
+// marks videos the provider watermarks.
+func NewVideoOutputPart(data []byte, mime, name string, opts ...ImagePartOpt) OutputPart {
+	o := &imagePartOpt{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt.applyImagePartOpt(o)
+		}
+	}
+	return videoOutputPart{Data: data, MIME: mime, Name: name, SynthID: o.synthID}
+}
+
+// VideoOutputInfo is a generated video.
+type VideoOutputInfo struct {
+	Data    []byte
+	MIME    string // usually "video/mp4"
+	Name    string
+	SynthID bool // the provider watermarks it with SynthID
+}
+
+// Save writes the video to path.
+func (v VideoOutputInfo) Save(path string) error {
+	if err := os.WriteFile(path, v.Data, 0o644); err != nil {
+		return NewGrailError(Internal, fmt.Sprintf("failed to save video: %v", err)).WithCause(err)
+	}
+	return nil
+}
+
+// Videos returns the response's video outputs.
+func (r Response) Videos() []VideoOutputInfo {
+	var videos []VideoOutputInfo
+	for _, part := range r.Outputs {
+		if v, ok := part.(videoOutputPart); ok {
+			videos = append(videos, VideoOutputInfo{Data: v.Data, MIME: v.MIME, Name: v.Name, SynthID: v.SynthID})
+		}
+	}
+	return videos
+}
+
+var aspectRatioRe = regexp.MustCompile(`^[1-9]\d*:[1-9]\d*$`)
+
+func validateVideoOutput(out videoOutput) error {
+	if out.Spec.DurationSeconds < 0 {
+		return NewGrailError(InvalidArgument, fmt.Sprintf("video duration must not be negative, got %d seconds", out.Spec.DurationSeconds))
+	}
+	if out.Spec.AspectRatio != "" && !aspectRatioRe.MatchString(out.Spec.AspectRatio) {
+		return NewGrailError(InvalidArgument, fmt.Sprintf("invalid video aspect ratio %q; expected a ratio like 16:9", out.Spec.AspectRatio))
+	}
+	return nil
+}
+
+func videoExt(mime string) string {
+	switch mime {
+	case "video/webm":
+		return ".webm"
+	case "video/quicktime":
+		return ".mov"
+	default:
+		return ".mp4"
+	}
+}
@@ -0,0 +1,53 @@
+package grail_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+func TestOutputVideo(t *testing.T) {
+	var got grail.Request
+	mp := &mock.Provider{
+		GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			got = req
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewVideoOutputPart([]byte("mp4"), "video/mp4", "fox.mp4", grail.WithSynthID())}}, nil
+		},
+	}
+	ctx := context.Background()
+	s := grail.NewSession(grail.NewClient(mp))
+	res, err := s.Generate(ctx, grail.Request{
+		Inputs: []grail.Input{grail.InputText("A fox in the snow")},
+		Output: grail.OutputVideo(grail.VideoSpec{DurationSeconds: 8, AspectRatio: "16:9"}),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if spec, ok := grail.GetVideoSpec(got.Output); !ok || spec.DurationSeconds != 8 || spec.AspectRatio != "16:9" {
+		t.Errorf("expected the provider to get the video spec, got %+v", got.Output)
+	}
+	videos := res.Videos()
+	if len(videos) != 1 || string(videos[0].Data) != "mp4" || videos[0].Name != "fox.mp4" || !videos[0].SynthID {
+		t.Fatalf("unexpected videos %+v", videos)
+	}
+	turn := s.Transcript().Turns[1]
+	if len(turn.Parts) != 1 || turn.Parts[0].Type != "video" || turn.Parts[0].Ref != grail.AttachmentRef([]byte("mp4")) {
+		t.Errorf("expected the video in the transcript, got %+v", turn.Parts)
+	}
+	rj, err := grail.NewResponseJSON(res, "")
+	if err != nil || len(rj.Outputs) != 1 || rj.Outputs[0].Type != "video" || rj.Outputs[0].MIME != "video/mp4" {
+		t.Errorf("unexpected response JSON %+v (%v)", rj, err)
+	}
+
+	for _, spec := range []grail.VideoSpec{{DurationSeconds: -1}, {AspectRatio: "wide"}} {
+		_, err := grail.NewClient(mp).Generate(ctx, grail.Request{
+			Inputs: []grail.Input{grail.InputText("A fox")},
+			Output: grail.OutputVideo(spec),
+		})
+		if grail.GetErrorCode(err) != grail.InvalidArgument {
+			t.Errorf("expected InvalidArgument for %+v, got %v", spec, err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Package vision provides ready-made image understanding helpers built on
+// grail.Client, replacing hand-written prompts for common tasks.
+//
+// Example usage:
+//
+//	alt, err := vision.AltText(ctx, client, imageData, nil)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	fmt.Printf("<img src=%q alt=%q>\n", "photo.jpg", alt.Short)
+package vision
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/montanaflynn/grail"
+)
+
+// Caption describes an image for people who can't see it.
+type Caption struct {
+	Short    string         `json:"short"`    // alt text: one sentence, at most 125 characters
+	Detailed string         `json:"detailed"` // a longer description for a caption or long description
+	Objects  []string       `json:"objects"`  // notable things in the image
+	Text     string         `json:"text"`     // text visible in the image, verbatim; empty if none
+	Response grail.Response `json:"-"`
+}
+
+// AltTextOptions configures AltText. The zero value works.
+type AltTextOptions struct {
+	// Context describes where the image appears, e.g. "product page for a
+	// hiking boot", so the caption focuses on what matters there.
+	Context string
+	// Language of the captions, e.g. "Spanish" (default English).
+	Language string
+	Model    string
+	Tier     grail.ModelTier
+}
+
+var captionSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"short":    map[string]any{"type": "string"},
+		"detailed": map[string]any{"type": "string"},
+		"objects":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		"text":     map[string]any{"type": "string"},
+	},
+	"required": []string{"short", "detailed", "objects", "text"},
+}
+
+// maxShort is the usual screen-reader guidance for alt text length.
+const maxShort = 125
+
+// AltText captions image (JPEG, PNG, WebP, ...) for accessibility: a short
+// alt text, a detailed description, the notable objects, and any text in
+// the image. opts may be nil.
+func AltText(ctx context.Context, client grail.Client, image []byte, opts *AltTextOptions) (Caption, error) {
+	if len(image) == 0 {
+		return Caption{}, grail.NewGrailError(grail.InvalidArgument, "image must not be empty")
+	}
+	if opts == nil {
+		opts = &AltTextOptions{}
+	}
+	res, err := client.Generate(ctx, grail.Request{
+		Inputs: []grail.Input{
+			grail.InputText(altTextPrompt(*opts)),
+			grail.InputImage(image),
+		},
+		Output: grail.OutputJSON(captionSchema),
+		Model:  opts.Model,
+		Tier:   opts.Tier,
+	})
+	if err != nil {
+		return Caption{}, err
+	}
+
+	c := Caption{Response: res}
+	if err := res.DecodeJSON(&c); err != nil {
+		return c, grail.NewGrailError(grail.OutputInvalid, fmt.Sprintf("decode caption: %v", err)).WithCause(err)
+	}
+	c.Short = strings.TrimSpace(c.Short)
+	if c.Short == "" {
+		return c, grail.NewGrailError(grail.OutputInvalid, "no alt text returned")
+	}
+	if r := []rune(c.Short); len(r) > maxShort {
+		c.Short = strings.TrimSpace(string(r[:maxShort-1])) + "…"
+	}
+	return c, nil
+}
+
+func altTextPrompt(o AltTextOptions) string {
+	var b strings.Builder
+	b.WriteString("Describe this image for someone using a screen reader.\n" +
+		"- short: alt text in one sentence of at most 125 characters. Describe what the image shows and why it matters; " +
+		"don't start with \"Image of\" or \"Picture of\".\n" +
+		"- detailed: two to four sentences covering the subject, setting, notable details, and colors where relevant.\n" +
+		"- objects: the notable objects, people, or elements, as short nouns.\n" +
+		"- text: any text visible in the image, verbatim, or an empty string if there is none.\n")
+	if o.Context != "" {
+		fmt.Fprintf(&b, "The image appears in this context: %s. Focus on what matters there.\n", o.Context)
+	}
+	if o.Language != "" {
+		fmt.Fprintf(&b, "Write short and detailed in %s; keep the visible text as written.\n", o.Language)
+	}
+	b.WriteString("Respond with only a JSON object with the fields short, detailed, objects (array of strings), and text.")
+	return b.String()
+}
@@ -0,0 +1,48 @@
+package vision_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+	"github.com/montanaflynn/grail/vision"
+)
+
+func TestAltText(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	reply := `{"short":"A red hiking boot on a mossy rock.","detailed":"A red leather hiking boot rests on a rock.","objects":["boot","rock"],"text":"TRAIL"}`
+	prov := &mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		prompt, _ := grail.AsTextInput(req.Inputs[0])
+		if !strings.Contains(prompt, "product page") || !strings.Contains(prompt, "Spanish") {
+			t.Fatalf("options missing from prompt: %s", prompt)
+		}
+		if len(req.Inputs) != 2 {
+			t.Fatalf("expected the image input, got %d inputs", len(req.Inputs))
+		}
+		return grail.Response{Outputs: []grail.OutputPart{grail.NewJSONOutputPart([]byte(reply))}}, nil
+	}}
+	client := grail.NewClient(prov)
+
+	c, err := vision.AltText(context.Background(), client, png, &vision.AltTextOptions{Context: "product page", Language: "Spanish"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Short != "A red hiking boot on a mossy rock." || len(c.Objects) != 2 || c.Text != "TRAIL" {
+		t.Fatalf("unexpected caption %+v", c)
+	}
+
+	reply = `{"short":"` + strings.Repeat("long ", 40) + `","detailed":"","objects":[],"text":""}`
+	c, err = vision.AltText(context.Background(), client, png, &vision.AltTextOptions{Context: "product page", Language: "Spanish"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len([]rune(c.Short)); n > 125 || !strings.HasSuffix(c.Short, "…") {
+		t.Fatalf("alt text not shortened: %d runes", n)
+	}
+
+	if _, err := vision.AltText(context.Background(), client, nil, nil); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for an empty image, got %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package vision
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/montanaflynn/grail"
+)
+
+// Severity is how badly an accessibility issue blocks users, using the
+// impact levels of axe and similar checkers.
+type Severity string
+
+const (
+	SeverityCritical Severity = "critical" // blocks some users from the task entirely
+	SeveritySerious  Severity = "serious"  // makes the task very hard for some users
+	SeverityModerate Severity = "moderate" // makes the task harder
+	SeverityMinor    Severity = "minor"    // an annoyance
+)
+
+var severities = []Severity{SeverityCritical, SeveritySerious, SeverityModerate, SeverityMinor}
+
+// UIElement is an element visible in a screenshot.
+type UIElement struct {
+	ID          string `json:"id"`          // "e1", "e2", ...; referenced by UIIssue.Elements
+	Type        string `json:"type"`        // button, link, text_field, checkbox, heading, image, ...
+	Label       string `json:"label"`       // visible text or the name a user would call it by
+	Interactive bool   `json:"interactive"` // whether it looks tappable or clickable
+	Bounds      Bounds `json:"bounds"`
+}
+
+// Bounds locates an element as fractions of the screenshot's width and
+// height, from its top-left corner.
+type Bounds struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// UIIssue is an accessibility problem found in a screenshot.
+type UIIssue struct {
+	Severity    Severity `json:"severity"`
+	Rule        string   `json:"rule"`     // e.g. "color-contrast", "target-size", "missing-label"
+	WCAG        string   `json:"wcag"`     // the WCAG 2.2 success criterion, e.g. "1.4.3"; empty if none applies
+	Elements    []string `json:"elements"` // IDs of the elements involved
+	Description string   `json:"description"`
+	Suggestion  string   `json:"suggestion"`
+}
+
+// UIAudit is the result of AuditUI.
+type UIAudit struct {
+	Screen   string         `json:"screen"` // what the screen is for, in a sentence
+	Elements []UIElement    `json:"elements"`
+	Issues   []UIIssue      `json:"issues"` // most severe first
+	Response grail.Response `json:"-"`
+}
+
+// Count returns the number of issues at severity s or worse.
+func (a UIAudit) Count(s Severity) int {
+	limit := slices.Index(severities, s)
+	n := 0
+	for _, issue := range a.Issues {
+		if i := slices.Index(severities, issue.Severity); i >= 0 && i <= limit {
+			n++
+		}
+	}
+	return n
+}
+
+// UIAuditOptions configures AuditUI. The zero value works.
+type UIAuditOptions struct {
+	// Context describes the screen, e.g. "checkout step 2 of a shopping
+	// app", so the audit can judge what users are trying to do there.
+	Context string
+	// Platform is "web", "ios", or "android", for platform-specific target
+	// size and labeling guidance (default: inferred from the screenshot).
+	Platform string
+	Model    string
+	Tier     grail.ModelTier
+}
+
+var elementTypes = []string{"button", "link", "text_field", "checkbox", "radio", "toggle", "dropdown", "slider",
+	"tab", "menu", "icon", "image", "heading", "text", "list", "dialog", "other"}
+
+var uiAuditSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"screen": map[string]any{"type": "string"},
+		"elements": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"id":          map[string]any{"type": "string"},
+					"type":        map[string]any{"type": "string", "enum": elementTypes},
+					"label":       map[string]any{"type": "string"},
+					"interactive": map[string]any{"type": "boolean"},
+					"bounds": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"x":      map[string]any{"type": "number"},
+							"y":      map[string]any{"type": "number"},
+							"width":  map[string]any{"type": "number"},
+							"height": map[string]any{"type": "number"},
+						},
+						"required": []string{"x", "y", "width", "height"},
+					},
+				},
+				"required": []string{"id", "type", "label", "interactive", "bounds"},
+			},
+		},
+		"issues": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"severity":    map[string]any{"type": "string", "enum": severities},
+					"rule":        map[string]any{"type": "string"},
+					"wcag":        map[string]any{"type": "string"},
+					"elements":    map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+					"description": map[string]any{"type": "string"},
+					"suggestion":  map[string]any{"type": "string"},
+				},
+				"required": []string{"severity", "rule", "wcag", "elements", "description", "suggestion"},
+			},
+		},
+	},
+	"required": []string{"screen", "elements", "issues"},
+}
+
+// AuditUI inventories the elements in an app or web screenshot and reviews
+// it for accessibility issues that are visible in pixels: contrast, target
+// size, unlabeled icons, text size, and the like. Issues that need the
+// markup or a screen reader to find, such as missing accessible names on
+// labeled-looking controls, are out of reach, so treat the audit as a first
+// pass rather than a conformance check. opts may be nil.
+func AuditUI(ctx context.Context, client grail.Client, screenshot []byte, opts *UIAuditOptions) (UIAudit, error) {
+	if len(screenshot) == 0 {
+		return UIAudit{}, grail.NewGrailError(grail.InvalidArgument, "screenshot must not be empty")
+	}
+	if opts == nil {
+		opts = &UIAuditOptions{}
+	}
+	res, err := client.Generate(ctx, grail.Request{
+		Inputs: []grail.Input{
+			grail.InputText(uiAuditPrompt(*opts)),
+			grail.InputImage(screenshot),
+		},
+		Output: grail.OutputJSON(uiAuditSchema),
+		Model:  opts.Model,
+		Tier:   opts.Tier,
+	})
+	if err != nil {
+		return UIAudit{}, err
+	}
+
+	a := UIAudit{Response: res}
+	if err := res.DecodeJSON(&a); err != nil {
+		return a, grail.NewGrailError(grail.OutputInvalid, fmt.Sprintf("decode UI audit: %v", err)).WithCause(err)
+	}
+	known := make(map[string]bool, len(a.Elements))
+	for _, e := range a.Elements {
+		known[e.ID] = true
+	}
+	for i := range a.Issues {
+		a.Issues[i].Elements = slices.DeleteFunc(a.Issues[i].Elements, func(id string) bool { return !known[id] })
+	}
+	slices.SortStableFunc(a.Issues, func(x, y UIIssue) int {
+		return rank(x.Severity) - rank(y.Severity)
+	})
+	return a, nil
+}
+
+// rank orders severities from most to least severe, with unknown ones last.
+func rank(s Severity) int {
+	if i := slices.Index(severities, s); i >= 0 {
+		return i
+	}
+	return len(severities)
+}
+
+func uiAuditPrompt(o UIAuditOptions) string {
+	var b strings.Builder
+	b.WriteString("Audit this app screenshot for accessibility.\n" +
+		"- screen: what the screen is for, in one sentence.\n" +
+		"- elements: every visible UI element in reading order, with ids e1, e2, and so on, its type, " +
+		"its label (visible text, or what a user would call an unlabeled icon), whether it is interactive, " +
+		"and its bounds as fractions of the screenshot's width and height from the top-left corner.\n" +
+		"- issues: accessibility problems you can see, such as low text or control contrast, touch targets smaller than 24 by 24 CSS pixels, " +
+		"icon-only controls with no visible label, text that is too small, information conveyed only by color, " +
+		"missing visible focus or error indicators, and placeholder text used as a label. " +
+		"For each give a severity (critical, serious, moderate, or minor), a short kebab-case rule name, the WCAG 2.2 success criterion number, " +
+		"the ids of the elements involved, a description, and a suggested fix. Don't report problems you can't see in the screenshot.\n")
+	if o.Context != "" {
+		fmt.Fprintf(&b, "The screen is: %s.\n", o.Context)
+	}
+	if o.Platform != "" {
+		fmt.Fprintf(&b, "The platform is %s; apply its guidelines for target sizes and labels as well.\n", o.Platform)
+	}
+	b.WriteString("Respond with only a JSON object with the fields screen, elements, and issues.")
+	return b.String()
+}
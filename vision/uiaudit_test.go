@@ -0,0 +1,46 @@
+package vision_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+	"github.com/montanaflynn/grail/vision"
+)
+
+func TestAuditUI(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	reply := `{"screen":"Sign-in form.","elements":[
+		{"id":"e1","type":"text_field","label":"Email","interactive":true,"bounds":{"x":0.1,"y":0.2,"width":0.8,"height":0.05}},
+		{"id":"e2","type":"icon","label":"close","interactive":true,"bounds":{"x":0.9,"y":0.02,"width":0.03,"height":0.02}}],
+		"issues":[
+		{"severity":"minor","rule":"placeholder-label","wcag":"3.3.2","elements":["e1"],"description":"Placeholder used as label.","suggestion":"Add a visible label."},
+		{"severity":"serious","rule":"target-size","wcag":"2.5.8","elements":["e2","e9"],"description":"Close icon is tiny.","suggestion":"Enlarge it."}]}`
+	prov := &mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+		prompt, _ := grail.AsTextInput(req.Inputs[0])
+		if !strings.Contains(prompt, "checkout") || !strings.Contains(prompt, "ios") {
+			t.Fatalf("options missing from prompt: %s", prompt)
+		}
+		return grail.Response{Outputs: []grail.OutputPart{grail.NewJSONOutputPart([]byte(reply))}}, nil
+	}}
+
+	a, err := vision.AuditUI(context.Background(), grail.NewClient(prov), png, &vision.UIAuditOptions{Context: "checkout", Platform: "ios"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Elements) != 2 || a.Elements[1].Bounds.Width != 0.03 {
+		t.Fatalf("unexpected elements %+v", a.Elements)
+	}
+	if a.Issues[0].Severity != vision.SeveritySerious || len(a.Issues[0].Elements) != 1 {
+		t.Fatalf("issues not sorted or unknown element kept: %+v", a.Issues)
+	}
+	if a.Count(vision.SeveritySerious) != 1 || a.Count(vision.SeverityMinor) != 2 {
+		t.Fatalf("unexpected counts %d, %d", a.Count(vision.SeveritySerious), a.Count(vision.SeverityMinor))
+	}
+
+	if _, err := vision.AuditUI(context.Background(), grail.NewClient(prov), nil, nil); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for an empty screenshot, got %v", err)
+	}
+}
@@ -0,0 +1,345 @@
+package grail
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//
+// Voice agents
+//
+
+// TurnDetection decides when the user has finished speaking, from the level
+// of the microphone audio. The zero value uses the defaults below.
+type TurnDetection struct {
+	// SampleRate is the microphone's rate in Hz (default 16000).
+	SampleRate int
+	// Threshold is the RMS level, from 0 to 1 of full scale, above which
+	// audio counts as speech (default 0.02).
+	Threshold float64
+	// Silence is how long the user must be quiet to end a turn (default
+	// 700ms).
+	Silence time.Duration
+	// MinSpeech is how much speech a turn needs; shorter sounds, such as
+	// clicks and coughs, are ignored (default 200ms).
+	MinSpeech time.Duration
+}
+
+func (td TurnDetection) withDefaults() TurnDetection {
+	if td.SampleRate <= 0 {
+		td.SampleRate = 16000
+	}
+	if td.Threshold <= 0 {
+		td.Threshold = 0.02
+	}
+	if td.Silence <= 0 {
+		td.Silence = 700 * time.Millisecond
+	}
+	if td.MinSpeech <= 0 {
+		td.MinSpeech = 200 * time.Millisecond
+	}
+	return td
+}
+
+// VoiceTurn is one exchange with a VoiceAgent.
+type VoiceTurn struct {
+	Heard  Transcription // what the user said
+	Reply  Response      // the model's final answer
+	Steps  []ToolStep    // tool calls made for the answer, in order
+	Speech Speech        // the answer, spoken
+}
+
+// VoiceAgent is a speech-to-speech assistant on top of a Session: it
+// listens to a microphone stream, detects when the user finishes speaking,
+// transcribes the turn (Client.Transcribe), answers it through the session
+// with the registered tools, and speaks the answer (Client.Speak) to a
+// speaker stream:
+//
+//	session := grail.NewSession(client, grail.WithSessionHistory())
+//	agent := grail.NewVoiceAgent(session)
+//	agent.Register(weatherTool, grail.ToolFuncOf(getWeather))
+//	agent.Speak = []grail.SpeakOpt{grail.WithSpeechFormat("pcm")}
+//	err := agent.Run(ctx, mic, speaker)
+//
+// The session must carry context between turns, with WithSessionHistory or
+// a provider's server-side state, for tool results and follow-up questions
+// to make sense to the model. A VoiceAgent handles one conversation at a
+// time.
+type VoiceAgent struct {
+	Session       *Session
+	TurnDetection TurnDetection
+	// Transcribe and Speak configure the transcription of each turn and the
+	// speech of each answer, such as WithTranscriptionLanguage and
+	// WithVoice.
+	Transcribe []TranscribeOpt
+	Speak      []SpeakOpt
+	// MaxToolIterations bounds the model calls per turn (default
+	// DefaultMaxToolIterations).
+	MaxToolIterations int
+
+	// OnSpeechStart is called when the user starts speaking, and
+	// OnSpeechEnd when they stop, with the turn's audio as WAV; both may be
+	// nil. OnTurn is called with each answered turn before it's spoken.
+	OnSpeechStart func()
+	OnSpeechEnd   func(audio []byte)
+	OnTurn        func(turn VoiceTurn)
+
+	mu    sync.RWMutex
+	tools []Tool
+	funcs map[string]ToolFunc
+}
+
+// NewVoiceAgent returns a VoiceAgent for s without tools.
+func NewVoiceAgent(s *Session) *VoiceAgent {
+	return &VoiceAgent{Session: s}
+}
+
+// Register offers tool to the model and runs fn for its calls, replacing any
+// tool registered with the same name.
+func (a *VoiceAgent) Register(tool Tool, fn ToolFunc) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.funcs == nil {
+		a.funcs = map[string]ToolFunc{}
+	}
+	if _, ok := a.funcs[tool.Name]; ok {
+		for i, t := range a.tools {
+			if t.Name == tool.Name {
+				a.tools = append(a.tools[:i:i], a.tools[i+1:]...)
+				break
+			}
+		}
+	}
+	a.tools = append(a.tools, tool)
+	a.funcs[tool.Name] = fn
+}
+
+// Run listens to mic, raw 16-bit little-endian mono PCM at
+// TurnDetection.SampleRate, and writes each spoken answer to speaker, in the
+// format picked with WithSpeechFormat. Audio the microphone picks up while
+// the agent is answering is dropped, so it doesn't hear itself. Run returns
+// nil when mic ends, after answering a turn in progress, and the first
+// error otherwise; a turn with nothing transcribed is skipped.
+//
+// Run stops waiting when ctx is done, but a read from mic already under way
+// can't be interrupted; close mic to end it.
+func (a *VoiceAgent) Run(ctx context.Context, mic io.Reader, speaker io.Writer) error {
+	td := a.TurnDetection.withDefaults()
+	const frameDur = 20 * time.Millisecond
+	frameSize := td.SampleRate * int(frameDur/time.Millisecond) / 1000 * 2
+
+	// Frames are read on their own goroutine, and dropped while a turn is
+	// being answered rather than queued behind it.
+	frames := make(chan []byte)
+	done := make(chan error, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+	var answering atomic.Bool
+	go func() {
+		for {
+			frame := make([]byte, frameSize)
+			n, err := io.ReadFull(mic, frame)
+			if n > 0 && !answering.Load() {
+				select {
+				case frames <- frame[:n]:
+				case <-stop:
+					return
+				}
+			}
+			if err != nil {
+				if errors.Is(err, io.ErrUnexpectedEOF) {
+					err = io.EOF
+				}
+				done <- err
+				return
+			}
+		}
+	}()
+
+	var turn bytes.Buffer
+	var speech, silence time.Duration
+	listening := false
+	finish := func() error {
+		audio := pcmToWAV(turn.Bytes(), td.SampleRate)
+		turn.Reset()
+		speech, silence, listening = 0, 0, false
+		if a.OnSpeechEnd != nil {
+			a.OnSpeechEnd(audio)
+		}
+		answering.Store(true)
+		defer answering.Store(false)
+		return a.answer(ctx, audio, speaker)
+	}
+	handle := func(frame []byte) error {
+		voiced := pcmLevel(frame) >= td.Threshold
+		switch {
+		case voiced:
+			turn.Write(frame)
+			speech += frameDur
+			silence = 0
+			// A sound is buffered from its start, but isn't speech until
+			// it's lasted MinSpeech.
+			if !listening && speech >= td.MinSpeech {
+				listening = true
+				if a.OnSpeechStart != nil {
+					a.OnSpeechStart()
+				}
+			}
+		case turn.Len() > 0:
+			turn.Write(frame)
+			silence += frameDur
+			if silence < td.Silence {
+				return nil
+			}
+			if !listening {
+				turn.Reset()
+				speech, silence = 0, 0
+				return nil
+			}
+			return finish()
+		}
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case frame := <-frames:
+			if err := handle(frame); err != nil {
+				return err
+			}
+		case err := <-done:
+			if listening {
+				if err := finish(); err != nil {
+					return err
+				}
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return NewGrailError(InvalidArgument, fmt.Sprintf("read microphone: %v", err)).WithCause(err)
+		}
+	}
+}
+
+// answer responds to a turn's audio and speaks the answer to speaker.
+func (a *VoiceAgent) answer(ctx context.Context, audio []byte, speaker io.Writer) error {
+	turn, err := a.Respond(ctx, InputFile(audio, "audio/wav", WithFileName("turn.wav")))
+	if err != nil || len(turn.Speech.Data) == 0 {
+		return err
+	}
+	if _, err := speaker.Write(turn.Speech.Data); err != nil {
+		return NewGrailError(Internal, fmt.Sprintf("write speaker: %v", err)).WithCause(err)
+	}
+	return nil
+}
+
+// Respond answers one turn of recorded speech, an audio file input: it
+// transcribes it, answers through the session, running tool calls until the
+// model replies in text, and speaks the reply. A turn with nothing
+// transcribed returns only Heard.
+func (a *VoiceAgent) Respond(ctx context.Context, audio Input) (VoiceTurn, error) {
+	client := a.Session.client
+	var turn VoiceTurn
+	var err error
+	if turn.Heard, err = client.Transcribe(ctx, audio, a.Transcribe...); err != nil {
+		return turn, err
+	}
+	heard := strings.TrimSpace(turn.Heard.Text)
+	if heard == "" {
+		return turn, nil
+	}
+
+	a.mu.RLock()
+	tools := append([]Tool(nil), a.tools...)
+	funcs := make(map[string]ToolFunc, len(a.funcs))
+	for name, fn := range a.funcs {
+		funcs[name] = fn
+	}
+	a.mu.RUnlock()
+	limit := a.MaxToolIterations
+	if limit <= 0 {
+		limit = DefaultMaxToolIterations
+	}
+	req := a.Session.opts.template
+	req.Inputs = []Input{InputText(heard)}
+	req.Output = OutputText()
+	req.Tools = tools
+	for i := 1; ; i++ {
+		if turn.Reply, err = a.Session.Generate(ctx, req); err != nil {
+			return turn, err
+		}
+		calls := turn.Reply.ToolCalls()
+		if len(calls) == 0 {
+			break
+		}
+		if i == limit {
+			return turn, NewGrailError(OutputInvalid, fmt.Sprintf("model still calling tools after %d iterations", limit))
+		}
+		steps := runToolCalls(ctx, funcs, calls, i)
+		turn.Steps = append(turn.Steps, steps...)
+		req.Inputs = nil
+		for _, step := range steps {
+			req.Inputs = append(req.Inputs, InputToolResult(step.Call, step.Output))
+		}
+	}
+
+	reply, _ := turn.Reply.Text()
+	if strings.TrimSpace(reply) == "" {
+		return turn, NewGrailError(OutputInvalid, "model gave no answer to speak").WithProviderName(turn.Reply.Provider.Name)
+	}
+	if a.OnTurn != nil {
+		a.OnTurn(turn)
+	}
+	turn.Speech, err = client.Speak(ctx, reply, a.Speak...)
+	return turn, err
+}
+
+// pcmLevel returns the RMS level of 16-bit little-endian samples, from 0 to
+// 1.
+func pcmLevel(frame []byte) float64 {
+	n := len(frame) / 2
+	if n == 0 {
+		return 0
+	}
+	var sum float64
+	for i := range n {
+		s := float64(int16(binary.LittleEndian.Uint16(frame[2*i:]))) / math.MaxInt16
+		sum += s * s
+	}
+	return math.Sqrt(sum / float64(n))
+}
+
+// pcmToWAV wraps 16-bit little-endian mono samples in a WAV header.
+func pcmToWAV(pcm []byte, sampleRate int) []byte {
+	var b bytes.Buffer
+	b.Grow(44 + len(pcm))
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(36+len(pcm)))
+	b.WriteString("WAVEfmt ")
+	for _, v := range []any{
+		uint32(16),             // fmt chunk size
+		uint16(1),              // PCM
+		uint16(1),              // mono
+		uint32(sampleRate),     // sample rate
+		uint32(sampleRate * 2), // byte rate
+		uint16(2),              // block align
+		uint16(16),             // bits per sample
+	} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(len(pcm)))
+	b.Write(pcm)
+	return b.Bytes()
+}
@@ -0,0 +1,149 @@
+package grail_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/montanaflynn/grail"
+	"github.com/montanaflynn/grail/providers/mock"
+)
+
+// voiceProvider transcribes each turn with the next of heard and speaks
+// text as is.
+type voiceProvider struct {
+	toolProvider
+	heard []string
+	audio []grail.TranscriptionRequest
+}
+
+func (p *voiceProvider) Transcribe(ctx context.Context, req grail.TranscriptionRequest) (grail.Transcription, error) {
+	p.audio = append(p.audio, req)
+	text := p.heard[0]
+	p.heard = p.heard[1:]
+	return grail.Transcription{Text: text}, nil
+}
+
+func (p *voiceProvider) SynthesizeSpeech(ctx context.Context, req grail.SpeechRequest) (grail.Speech, error) {
+	return grail.Speech{Data: []byte("<" + req.Voice + ":" + req.Text + ">"), MIME: "audio/pcm"}, nil
+}
+
+// tone returns ms of 16kHz 16-bit PCM at the given level, from 0 for silence
+// to 1 for full scale.
+func tone(ms int, level float64) []byte {
+	var b bytes.Buffer
+	for i := range 16 * ms {
+		s := int16(level * math.MaxInt16 * math.Sin(2*math.Pi*440*float64(i)/16000))
+		binary.Write(&b, binary.LittleEndian, s)
+	}
+	return b.Bytes()
+}
+
+// speakerWriter collects speech and signals each write.
+type speakerWriter struct {
+	bytes.Buffer
+	wrote chan struct{}
+}
+
+func (w *speakerWriter) Write(p []byte) (int, error) {
+	n, _ := w.Buffer.Write(p)
+	w.wrote <- struct{}{}
+	return n, nil
+}
+
+func TestVoiceAgent(t *testing.T) {
+	var requests []grail.Request
+	prov := &voiceProvider{
+		toolProvider: toolProvider{&mock.Provider{GenerateFn: func(ctx context.Context, req grail.Request) (grail.Response, error) {
+			requests = append(requests, req)
+			switch len(requests) {
+			case 1:
+				return grail.Response{Outputs: []grail.OutputPart{
+					grail.NewToolCallOutputPart(grail.ToolCall{ID: "1", Name: "get_weather", Arguments: []byte(`{"city":"Paris"}`)}),
+				}}, nil
+			case 2:
+				return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("It's sunny in Paris.")}}, nil
+			}
+			return grail.Response{Outputs: []grail.OutputPart{grail.NewTextOutputPart("You're welcome.")}}, nil
+		}}},
+		heard: []string{"What's the weather in Paris?", "Thanks!"},
+	}
+	agent := grail.NewVoiceAgent(grail.NewSession(grail.NewClient(prov), grail.WithSessionHistory()))
+	agent.Register(weatherTool, grail.ToolFuncOf(func(ctx context.Context, args struct{ City string }) (string, error) {
+		return "sunny", nil
+	}))
+	agent.Speak = []grail.SpeakOpt{grail.WithVoice("alloy")}
+	var starts int
+	var turns []grail.VoiceTurn
+	agent.OnSpeechStart = func() { starts++ }
+	agent.OnTurn = func(turn grail.VoiceTurn) { turns = append(turns, turn) }
+
+	// The second turn starts after the first is answered, since audio heard
+	// while answering is dropped. Its leading click is too short to count,
+	// and it's answered when the microphone closes.
+	mic, feed := io.Pipe()
+	speaker := &speakerWriter{wrote: make(chan struct{}, 2)}
+	go func() {
+		feed.Write(append(append(tone(100, 0), tone(500, 0.3)...), tone(1000, 0)...))
+		<-speaker.wrote
+		feed.Write(append(append(append(tone(200, 0), tone(40, 0.3)...), tone(800, 0)...), tone(400, 0.3)...))
+		feed.Close()
+	}()
+	done := make(chan error)
+	go func() { done <- agent.Run(context.Background(), mic, speaker) }()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := speaker.String(); got != "<alloy:It's sunny in Paris.><alloy:You're welcome.>" {
+		t.Errorf("unexpected speech %q", got)
+	}
+	if starts != 2 || len(turns) != 2 {
+		t.Fatalf("expected two turns, got %d starts and %d turns", starts, len(turns))
+	}
+	if turns[0].Heard.Text != "What's the weather in Paris?" || len(turns[0].Steps) != 1 || turns[0].Steps[0].Output != "sunny" {
+		t.Errorf("unexpected first turn %+v", turns[0])
+	}
+	if a := prov.audio[0]; a.MIME != "audio/wav" || !bytes.HasPrefix(a.Audio, []byte("RIFF")) {
+		t.Errorf("expected the turn as WAV, got %s", a.MIME)
+	}
+
+	// The tool result goes back through the session, and later turns see
+	// the conversation so far.
+	if len(requests) != 3 || len(requests[0].Tools) != 1 {
+		t.Fatalf("expected three requests offering the tool, got %d", len(requests))
+	}
+	last := func(req grail.Request) grail.Input { return req.Inputs[len(req.Inputs)-1] }
+	if _, out, ok := grail.AsToolResultInput(last(requests[1])); !ok || out != "sunny" {
+		t.Errorf("expected the tool result, got %+v", requests[1].Inputs)
+	}
+	if text, _ := grail.AsTextInput(last(requests[2])); text != "Thanks!" || len(requests[2].Inputs) < 3 {
+		t.Errorf("expected the second turn after the history, got %q of %d inputs", text, len(requests[2].Inputs))
+	}
+}
+
+func TestSpeak(t *testing.T) {
+	ctx := context.Background()
+	prov := &voiceProvider{toolProvider: toolProvider{&mock.Provider{}}}
+	speech, err := grail.NewClient(prov).Speak(ctx, "Hello.", grail.WithVoice("verse"))
+	if err != nil || string(speech.Data) != "<verse:Hello.>" || speech.Provider.Name != "mock" {
+		t.Fatalf("unexpected speech %+v (%v)", speech, err)
+	}
+	if _, err := grail.NewClient(&mock.Provider{}).Speak(ctx, "Hello."); grail.GetErrorCode(err) != grail.Unsupported {
+		t.Errorf("expected Unsupported without a speech endpoint, got %v", err)
+	}
+	if _, err := grail.NewClient(prov).Speak(ctx, " "); grail.GetErrorCode(err) != grail.InvalidArgument {
+		t.Errorf("expected InvalidArgument for empty text, got %v", err)
+	}
+
+	// A turn with nothing said isn't answered.
+	prov.heard = []string{" "}
+	turn, err := grail.NewVoiceAgent(grail.NewSession(grail.NewClient(prov))).Respond(ctx, grail.InputFile([]byte("RIFF"), "audio/wav"))
+	if err != nil || len(turn.Speech.Data) != 0 || strings.TrimSpace(turn.Heard.Text) != "" {
+		t.Errorf("expected an empty turn to be skipped, got %+v (%v)", turn, err)
+	}
+}